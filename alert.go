@@ -0,0 +1,55 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/alert"
+	"github.com/2018yuli/rulego/utils/json"
+)
+
+// AlertMsgType 发送到告警规则链的消息类型，消息内容为json格式的`alert.Alert`
+// 元数据包含ruleId、state、chainId和nodeId
+const AlertMsgType = "ALERT"
+
+// recordRelation 统计当前节点输出的关系，告警状态变化时把告警消息发送到告警规则链
+func (ctx *DefaultRuleContext) recordRelation(relationType string) {
+	alerts := ctx.config.Alerts
+	if alerts == nil || ctx.ruleChainCtx == nil || ctx.self == nil {
+		return
+	}
+	for _, item := range alerts.Record(ctx.ruleChainCtx.Id.Id, ctx.GetSelfId(), relationType) {
+		sendAlert(ctx.config, alerts.ChainId(), item)
+	}
+}
+
+// sendAlert 通过`Config.ChainExecutor`把告警消息发送到告警规则链
+func sendAlert(config types.Config, chainId string, item alert.Alert) {
+	if chainId == "" || config.ChainExecutor == nil {
+		return
+	}
+	data, _ := json.Marshal(item)
+	metadata := types.NewMetadata()
+	metadata.PutValue("ruleId", item.RuleId)
+	metadata.PutValue("state", item.State)
+	metadata.PutValue("chainId", item.ChainId)
+	metadata.PutValue("nodeId", item.NodeId)
+	msg := types.NewMsg(0, AlertMsgType, types.JSON, metadata, string(data))
+	if err := config.ChainExecutor(chainId, msg, nil); err != nil && config.Logger != nil {
+		types.Logf(config.Logger, types.LogLevelError, "send alert to chain %s error: %s", chainId, err)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/alert"
+	"github.com/2018yuli/rulego/utils/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAlerts(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var alertMsg types.RuleMsg
+	var alertChainId string
+	manager := alert.NewManager(alert.Config{
+		ChainId: "alarmChain",
+		Rules:   []alert.Rule{{Id: "filterFalse", NodeId: "s1", RelationType: types.False, Threshold: 50, MinCount: 2}},
+	})
+	config := NewConfig(types.WithAlerts(manager), types.WithChainExecutor(func(chainId string, msg types.RuleMsg, onEnd func(msg types.RuleMsg, err error)) error {
+		alertChainId = chainId
+		alertMsg = msg
+		wg.Done()
+		return nil
+	}))
+	ruleEngine, err := NewChainBuilder().Id("alertChain").
+		Node("jsFilter", types.Configuration{"jsScript": "return msg.temperature > 50;"}).
+		New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("alertChain")
+
+	for _, data := range []string{`{"temperature":60}`, `{"temperature":20}`, `{"temperature":10}`} {
+		var done sync.WaitGroup
+		done.Add(1)
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), data), func(msg types.RuleMsg, err error) {
+			done.Done()
+		})
+		waitTimeout(t, &done, time.Second*3)
+	}
+	waitTimeout(t, &wg, time.Second*3)
+
+	assert.Equal(t, "alarmChain", alertChainId)
+	assert.Equal(t, AlertMsgType, alertMsg.Type)
+	assert.Equal(t, alert.Firing, alertMsg.Metadata.GetValue("state"))
+	assert.Equal(t, "s1", alertMsg.Metadata.GetValue("nodeId"))
+	var item alert.Alert
+	assert.Nil(t, json.Unmarshal([]byte(alertMsg.Data), &item))
+	assert.Equal(t, "alertChain", item.ChainId)
+	assert.Equal(t, int64(2), item.Count)
+	assert.Equal(t, int64(3), item.Total)
+}
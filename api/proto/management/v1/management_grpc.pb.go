@@ -0,0 +1,356 @@
+//
+// Copyright 2023 The RuleGo Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 规则引擎gRPC管理接口定义，服务端由`endpoint/admin`实现，
+// management.pb.go和management_grpc.pb.go为生成的代码，修改后使用以下命令重新生成：
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	  --go-grpc_out=. --go-grpc_opt=paths=source_relative \
+//	  api/proto/management/v1/management.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: api/proto/management/v1/management.proto
+
+package managementv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Management_ListChains_FullMethodName  = "/rulego.management.v1.Management/ListChains"
+	Management_GetChain_FullMethodName    = "/rulego.management.v1.Management/GetChain"
+	Management_SaveChain_FullMethodName   = "/rulego.management.v1.Management/SaveChain"
+	Management_DeleteChain_FullMethodName = "/rulego.management.v1.Management/DeleteChain"
+	Management_GetMetrics_FullMethodName  = "/rulego.management.v1.Management/GetMetrics"
+	Management_StreamDebug_FullMethodName = "/rulego.management.v1.Management/StreamDebug"
+)
+
+// ManagementClient is the client API for Management service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ManagementClient interface {
+	// ListChains 查询规则引擎实例池中的规则链
+	ListChains(ctx context.Context, in *ListChainsRequest, opts ...grpc.CallOption) (*ListChainsResponse, error)
+	// GetChain 查询规则链DSL
+	GetChain(ctx context.Context, in *GetChainRequest, opts ...grpc.CallOption) (*Chain, error)
+	// SaveChain 创建规则链，已经存在则重新加载，对应`RuleGo.New`和`RuleEngine.ReloadSelf`
+	SaveChain(ctx context.Context, in *SaveChainRequest, opts ...grpc.CallOption) (*Chain, error)
+	// DeleteChain 删除规则链，对应`RuleGo.DelBy`
+	DeleteChain(ctx context.Context, in *DeleteChainRequest, opts ...grpc.CallOption) (*DeleteChainResponse, error)
+	// GetMetrics 查询节点运行指标，对应`RuleEngine.Metrics`
+	GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*GetMetricsResponse, error)
+	// StreamDebug 订阅规则链节点调试信息，只有debugMode=true的节点产生调试信息
+	StreamDebug(ctx context.Context, in *StreamDebugRequest, opts ...grpc.CallOption) (Management_StreamDebugClient, error)
+}
+
+type managementClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewManagementClient(cc grpc.ClientConnInterface) ManagementClient {
+	return &managementClient{cc}
+}
+
+func (c *managementClient) ListChains(ctx context.Context, in *ListChainsRequest, opts ...grpc.CallOption) (*ListChainsResponse, error) {
+	out := new(ListChainsResponse)
+	err := c.cc.Invoke(ctx, Management_ListChains_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) GetChain(ctx context.Context, in *GetChainRequest, opts ...grpc.CallOption) (*Chain, error) {
+	out := new(Chain)
+	err := c.cc.Invoke(ctx, Management_GetChain_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) SaveChain(ctx context.Context, in *SaveChainRequest, opts ...grpc.CallOption) (*Chain, error) {
+	out := new(Chain)
+	err := c.cc.Invoke(ctx, Management_SaveChain_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) DeleteChain(ctx context.Context, in *DeleteChainRequest, opts ...grpc.CallOption) (*DeleteChainResponse, error) {
+	out := new(DeleteChainResponse)
+	err := c.cc.Invoke(ctx, Management_DeleteChain_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*GetMetricsResponse, error) {
+	out := new(GetMetricsResponse)
+	err := c.cc.Invoke(ctx, Management_GetMetrics_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) StreamDebug(ctx context.Context, in *StreamDebugRequest, opts ...grpc.CallOption) (Management_StreamDebugClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Management_ServiceDesc.Streams[0], Management_StreamDebug_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managementStreamDebugClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Management_StreamDebugClient interface {
+	Recv() (*DebugEvent, error)
+	grpc.ClientStream
+}
+
+type managementStreamDebugClient struct {
+	grpc.ClientStream
+}
+
+func (x *managementStreamDebugClient) Recv() (*DebugEvent, error) {
+	m := new(DebugEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ManagementServer is the server API for Management service.
+// All implementations must embed UnimplementedManagementServer
+// for forward compatibility
+type ManagementServer interface {
+	// ListChains 查询规则引擎实例池中的规则链
+	ListChains(context.Context, *ListChainsRequest) (*ListChainsResponse, error)
+	// GetChain 查询规则链DSL
+	GetChain(context.Context, *GetChainRequest) (*Chain, error)
+	// SaveChain 创建规则链，已经存在则重新加载，对应`RuleGo.New`和`RuleEngine.ReloadSelf`
+	SaveChain(context.Context, *SaveChainRequest) (*Chain, error)
+	// DeleteChain 删除规则链，对应`RuleGo.DelBy`
+	DeleteChain(context.Context, *DeleteChainRequest) (*DeleteChainResponse, error)
+	// GetMetrics 查询节点运行指标，对应`RuleEngine.Metrics`
+	GetMetrics(context.Context, *GetMetricsRequest) (*GetMetricsResponse, error)
+	// StreamDebug 订阅规则链节点调试信息，只有debugMode=true的节点产生调试信息
+	StreamDebug(*StreamDebugRequest, Management_StreamDebugServer) error
+	mustEmbedUnimplementedManagementServer()
+}
+
+// UnimplementedManagementServer must be embedded to have forward compatible implementations.
+type UnimplementedManagementServer struct {
+}
+
+func (UnimplementedManagementServer) ListChains(context.Context, *ListChainsRequest) (*ListChainsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListChains not implemented")
+}
+func (UnimplementedManagementServer) GetChain(context.Context, *GetChainRequest) (*Chain, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChain not implemented")
+}
+func (UnimplementedManagementServer) SaveChain(context.Context, *SaveChainRequest) (*Chain, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveChain not implemented")
+}
+func (UnimplementedManagementServer) DeleteChain(context.Context, *DeleteChainRequest) (*DeleteChainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteChain not implemented")
+}
+func (UnimplementedManagementServer) GetMetrics(context.Context, *GetMetricsRequest) (*GetMetricsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetrics not implemented")
+}
+func (UnimplementedManagementServer) StreamDebug(*StreamDebugRequest, Management_StreamDebugServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamDebug not implemented")
+}
+func (UnimplementedManagementServer) mustEmbedUnimplementedManagementServer() {}
+
+// UnsafeManagementServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ManagementServer will
+// result in compilation errors.
+type UnsafeManagementServer interface {
+	mustEmbedUnimplementedManagementServer()
+}
+
+func RegisterManagementServer(s grpc.ServiceRegistrar, srv ManagementServer) {
+	s.RegisterService(&Management_ServiceDesc, srv)
+}
+
+func _Management_ListChains_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListChainsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).ListChains(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Management_ListChains_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).ListChains(ctx, req.(*ListChainsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_GetChain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).GetChain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Management_GetChain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).GetChain(ctx, req.(*GetChainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_SaveChain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SaveChainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).SaveChain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Management_SaveChain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).SaveChain(ctx, req.(*SaveChainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_DeleteChain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteChainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).DeleteChain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Management_DeleteChain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).DeleteChain(ctx, req.(*DeleteChainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_GetMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMetricsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).GetMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Management_GetMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).GetMetrics(ctx, req.(*GetMetricsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_StreamDebug_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamDebugRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ManagementServer).StreamDebug(m, &managementStreamDebugServer{stream})
+}
+
+type Management_StreamDebugServer interface {
+	Send(*DebugEvent) error
+	grpc.ServerStream
+}
+
+type managementStreamDebugServer struct {
+	grpc.ServerStream
+}
+
+func (x *managementStreamDebugServer) Send(m *DebugEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Management_ServiceDesc is the grpc.ServiceDesc for Management service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Management_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rulego.management.v1.Management",
+	HandlerType: (*ManagementServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListChains",
+			Handler:    _Management_ListChains_Handler,
+		},
+		{
+			MethodName: "GetChain",
+			Handler:    _Management_GetChain_Handler,
+		},
+		{
+			MethodName: "SaveChain",
+			Handler:    _Management_SaveChain_Handler,
+		},
+		{
+			MethodName: "DeleteChain",
+			Handler:    _Management_DeleteChain_Handler,
+		},
+		{
+			MethodName: "GetMetrics",
+			Handler:    _Management_GetMetrics_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamDebug",
+			Handler:       _Management_StreamDebug_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/proto/management/v1/management.proto",
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"time"
+)
+
+// DefaultAsyncTimeout 节点异步完成消息的默认超时时间
+const DefaultAsyncTimeout = time.Second * 30
+
+// ErrAsyncTimeout 节点异步完成消息超时
+var ErrAsyncTimeout = errors.New("async completion timeout")
+
+// AsyncCompletion 节点异步完成消息的句柄，通过`RuleContext.Async`获取
+// 每个句柄只能完成一次，重复完成或者超时后完成返回false并忽略
+type AsyncCompletion interface {
+	//TellSuccess 异步通知消息处理成功
+	TellSuccess(msg RuleMsg) bool
+	//TellFailure 异步通知消息处理失败
+	TellFailure(msg RuleMsg, err error) bool
+	//TellNext 异步使用指定的relationTypes发送消息到下一个节点
+	TellNext(msg RuleMsg, relationTypes ...string) bool
+	//Done 是否已经完成或者超时
+	Done() bool
+	//Cancel 放弃完成并且不发送消息，用于节点销毁时释放等待中的句柄
+	//已经完成或者超时返回false
+	Cancel() bool
+}
+
+// asyncCompletion AsyncCompletion默认实现
+type asyncCompletion struct {
+	ctx    RuleContext
+	onDone func(timedOut bool)
+	timer  clock.Timer
+	done   bool
+	lock   sync.Mutex
+}
+
+// NewAsyncCompletion 创建异步完成句柄，超过timeout没有完成则把msg以`ErrAsyncTimeout`发送到`Failure`链
+// timeout<=0使用`Config.AsyncTimeout`，onDone在完成或者超时并发送消息后调用一次，可以为空
+// 用于`RuleContext`实现
+func NewAsyncCompletion(ctx RuleContext, msg RuleMsg, timeout time.Duration, onDone func(timedOut bool)) AsyncCompletion {
+	config := ctx.Config()
+	if timeout <= 0 {
+		timeout = config.AsyncTimeout
+	}
+	if timeout <= 0 {
+		timeout = DefaultAsyncTimeout
+	}
+	c := &asyncCompletion{ctx: ctx, onDone: onDone}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.timer = config.GetClock().AfterFunc(timeout, func() {
+		if c.complete(true) {
+			defer c.finish(true)
+			if config.Logger != nil {
+				Logf(config.Logger, LogLevelWarn, "node %s async completion timeout after %s", ctx.GetSelfId(), timeout)
+			}
+			ctx.TellFailure(msg, ErrAsyncTimeout)
+		}
+	})
+	return c
+}
+
+func (c *asyncCompletion) TellSuccess(msg RuleMsg) bool {
+	if !c.complete(false) {
+		return false
+	}
+	defer c.finish(false)
+	c.ctx.TellSuccess(msg)
+	return true
+}
+
+func (c *asyncCompletion) TellFailure(msg RuleMsg, err error) bool {
+	if !c.complete(false) {
+		return false
+	}
+	defer c.finish(false)
+	c.ctx.TellFailure(msg, err)
+	return true
+}
+
+func (c *asyncCompletion) TellNext(msg RuleMsg, relationTypes ...string) bool {
+	if !c.complete(false) {
+		return false
+	}
+	defer c.finish(false)
+	c.ctx.TellNext(msg, relationTypes...)
+	return true
+}
+
+func (c *asyncCompletion) Done() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.done
+}
+
+func (c *asyncCompletion) Cancel() bool {
+	c.lock.Lock()
+	if c.done {
+		c.lock.Unlock()
+		return false
+	}
+	c.done = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.lock.Unlock()
+	c.finish(false)
+	return true
+}
+
+// complete 标记完成，第一次调用返回true
+func (c *asyncCompletion) complete(timedOut bool) bool {
+	c.lock.Lock()
+	if c.done {
+		c.lock.Unlock()
+		if !timedOut {
+			if logger := c.ctx.Config().Logger; logger != nil {
+				logger.Printf("node %s async completion ignored, already completed or timed out", c.ctx.GetSelfId())
+			}
+		}
+		return false
+	}
+	c.done = true
+	if !timedOut && c.timer != nil {
+		c.timer.Stop()
+	}
+	c.lock.Unlock()
+	return true
+}
+
+// finish 完成或者超时的消息发送后调用onDone
+func (c *asyncCompletion) finish(timedOut bool) {
+	if c.onDone != nil {
+		c.onDone(timedOut)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/utils/blob"
+	"strconv"
+)
+
+const (
+	//BlobRefKey 消息内容转存到BlobStore后，元数据中保存内容引用的key
+	BlobRefKey = "blobRef"
+	//BlobSizeKey 消息内容转存到BlobStore后，元数据中保存内容大小的key
+	BlobSizeKey = "blobSize"
+)
+
+// ErrMessageTooLarge 消息内容超过`Config.MaxMessageSize`并且没有配置BlobStore
+var ErrMessageTooLarge = errors.New("message too large")
+
+// MetadataOnlyNode 节点只使用消息类型和元数据，不需要加载转存到BlobStore的消息内容
+type MetadataOnlyNode interface {
+	MetadataOnly() bool
+}
+
+// IsMetadataOnly 节点是否只使用消息类型和元数据
+func IsMetadataOnly(node Node) bool {
+	v, ok := node.(MetadataOnlyNode)
+	return ok && v.MetadataOnly()
+}
+
+// OffloadMsg 消息内容超过maxSize时转存到store，msg.Data清空，元数据保存`BlobRefKey`和`BlobSizeKey`
+// 返回消息是否被转存
+func OffloadMsg(store blob.Store, msg RuleMsg, maxSize int) (RuleMsg, bool, error) {
+	if maxSize <= 0 || len(msg.Data) <= maxSize {
+		return msg, false, nil
+	}
+	data := []byte(msg.Data)
+	key := blob.Key(data)
+	if err := store.Put(key, data); err != nil {
+		return msg, false, err
+	}
+	msg = msg.Copy()
+	msg.Data = ""
+	msg.Metadata.PutValue(BlobRefKey, key)
+	msg.Metadata.PutValue(BlobSizeKey, strconv.Itoa(len(data)))
+	return msg, true, nil
+}
+
+// LoadMsg 从store加载被转存的消息内容，并删除元数据中的引用，消息没有被转存则原样返回
+func LoadMsg(store blob.Store, msg RuleMsg) (RuleMsg, error) {
+	key, _ := msg.Metadata.GetValue(BlobRefKey).(string)
+	if key == "" {
+		return msg, nil
+	}
+	data, err := store.Get(key)
+	if err != nil {
+		return msg, err
+	}
+	values := msg.Metadata.Values()
+	delete(values, BlobRefKey)
+	delete(values, BlobSizeKey)
+	msg = msg.Copy()
+	msg.Metadata = BuildMetadata(values)
+	msg.Data = string(data)
+	return msg, nil
+}
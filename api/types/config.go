@@ -17,7 +17,19 @@
 package types
 
 import (
+	"crypto/ed25519"
 	"github.com/2018yuli/rulego/pool"
+	"github.com/2018yuli/rulego/utils/alert"
+	"github.com/2018yuli/rulego/utils/audit"
+	"github.com/2018yuli/rulego/utils/blob"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/kv"
+	"github.com/2018yuli/rulego/utils/profile"
+	"github.com/2018yuli/rulego/utils/quarantine"
+	"github.com/2018yuli/rulego/utils/quota"
+	"github.com/2018yuli/rulego/utils/secrets"
+	"github.com/2018yuli/rulego/utils/state"
+	"io/fs"
 	"math"
 	"time"
 )
@@ -25,11 +37,14 @@ import (
 // Config 规则引擎配置
 type Config struct {
 	//OnDebug 节点调试信息回调函数，只有节点debugMode=true才会调用
+	//回调函数在规则链执行协程中同步调用，高吞吐场景可以使用`NewDebugDispatcher`异步分发、限速和去重
 	OnDebug func(flowType string, nodeId string, msg RuleMsg, relationType string, err error)
 	//OnEnd 规则链执行完成回调函数，如果有多个结束点，则执行多次
 	OnEnd func(msg RuleMsg, err error)
 	//JsMaxExecutionTime js脚本执行超时时间，默认2000毫秒
 	JsMaxExecutionTime time.Duration
+	//JsFetch js脚本fetch函数配置，为空则不允许js脚本访问网络
+	JsFetch *JsFetchConfig
 	//Pool 协程池接口
 	//如果不配置，则使用 go func 方式
 	//默认使用`pool.WorkerPool`。兼容ants协程池，可以使用ants协程池实现
@@ -44,8 +59,90 @@ type Config struct {
 	Parser Parser
 	//Logger 日志记录接口，默认使用：`DefaultLogger()`
 	Logger Logger
+	//LoggerFactory 按规则链和租户创建日志记录器，用于不同租户的规则链日志输出到不同的文件或者流
+	//创建规则引擎时调用，返回的日志记录器替换该规则链的Logger，返回nil则使用Logger
+	LoggerFactory LoggerFactory
+	//Clock 时钟，延迟、批量和定时相关的组件使用该时钟，默认使用系统时钟
+	//测试时可以使用`clock.NewVirtual`虚拟时钟快进时间
+	Clock clock.Clock
+	//Faults 故障注入配置，key为节点类型，用于测试规则链的容错能力，生产环境不要配置
+	Faults map[string]FaultConfig
+	//StateStore 节点状态存储，默认使用内存存储：`state.NewMemory()`
+	//可以使用`state.NewFile`文件存储或者`state/redis`包的Redis存储持久化节点状态
+	StateStore state.Store
+	//KVStore 跨规则链共享的key/value存储，js脚本通过store对象访问
+	//默认使用进程内共享的内存存储，可以使用`kv.NewFile`文件存储或者`kv/redis`包的Redis存储
+	KVStore kv.Store
+	//Tenant 租户ID，KVStore数据按租户隔离
+	Tenant string
+	//Quota 规则链执行配额和用量统计，为空则不统计
+	Quota *quota.Manager
+	//DegradedStart 节点初始化失败时，规则链以降级模式启动，而不是整个规则链加载失败
+	//降级节点不可用，到达该节点的消息发送到`Failure`链，并在后台按指数退避重新初始化
+	DegradedStart bool
+	//ReinitInterval 降级节点首次重新初始化的间隔，默认5秒
+	ReinitInterval time.Duration
+	//SecretsProvider 密钥提供者，配置后导出规则链时加密组件配置中标记了`sensitive:"true"`的字段，加载时解密
+	SecretsProvider secrets.Provider
+	//SecretKeyName 加密密钥在SecretsProvider中的名称，默认：RULEGO_CONFIG_KEY
+	SecretKeyName string
+	//TrustedKeys 信任的规则链签名公钥，key为密钥ID
+	//配置后只加载使用这些密钥签名的规则链和节点DSL，参考`sign.Sign`
+	//签名不覆盖$ref引用的文件和extends继承的基础规则链，配置后拒绝使用这两种引用的规则链
+	TrustedKeys map[string]ed25519.PublicKey
+	//AuditSink 规则链配置变更审计记录输出，为空则不记录，参考`audit.NewMemory`和`audit.NewFile`
+	AuditSink audit.Sink
+	//OnPanic 节点处理消息发生panic时的回调函数，用于处理毒消息
+	//消息元数据包含`PanicNodeKey`、`PanicErrorKey`和`PanicStackKey`
+	//为空则把消息发送到该节点的`Failure`链，否则交给该函数处理并结束该消息分支
+	OnPanic func(msg RuleMsg, err *PanicError)
+	//Quarantine 毒消息检测，同一条消息处理失败次数达到限制后放入隔离区，之后重新投递的相同消息不再执行
+	//为空则不检测，参考`quarantine.NewManager`
+	Quarantine *quarantine.Manager
+	//OrderingTimeout 规则链配置了顺序key时，等待上一条消息处理结束的最长时间，超时后继续处理下一条，默认30秒
+	OrderingTimeout time.Duration
+	//AsyncTimeout 节点通过`RuleContext.Async`异步完成消息的默认超时时间，默认30秒
+	AsyncTimeout time.Duration
+	//MaxMessageSize 消息内容最大字节数，0表示不限制
+	//超过限制的消息内容转存到BlobStore，没有配置BlobStore则拒绝输入的消息并返回`ErrMessageTooLarge`
+	MaxMessageSize int
+	//BlobStore 大消息内容存储，参考`blob.NewMemory`、`blob.NewFile`和`aws.NewS3Client`
+	BlobStore blob.Store
+	//ChainExecutor 把消息交给指定ID的规则链处理，onEnd在规则链每个结束点调用，用于组件调用其他规则链
+	//`rulego.NewConfig`默认使用`rulego.DefaultRuleGo.Execute`
+	ChainExecutor func(chainId string, msg RuleMsg, onEnd func(msg RuleMsg, err error)) error
+	//IncludeFS 节点配置中{"$ref": "路径"}引用的外部文件所在的文件系统，例如：os.DirFS("./chains")
+	//.json文件解析成json值，其他文件作为字符串，例如js脚本。`rulego.Load`加载的规则链默认使用规则链文件所在目录
+	IncludeFS fs.FS
+	//Alerts 基于节点关系比例的自动告警，告警和恢复通知发送到`alert.Config.ChainId`规则链，为空则不统计
+	//参考`alert.NewManager`
+	Alerts *alert.Manager
+	//DryRun dry-run模式，实现了`SideEffectNode`的节点不执行有副作用的操作，模拟成功并通过OnDryRun记录
+	//用于使用真实流量验证新规则链，不会写数据库、调用外部接口或者发布消息
+	DryRun bool
+	//OnDryRun dry-run模式下记录节点将要执行的操作
+	OnDryRun func(action DryRunAction)
+	//Profiler 按节点调用栈累计节点执行时间，为空则不统计，参考`profile.New`
+	//节点执行时间为OnMsg方法的执行时间，异步节点在OnMsg返回后的处理时间不计入
+	Profiler *profile.Profiler
+	//ReloadBufferSize 规则链重新加载期间最多暂存的消息数，默认1024，超过则返回`rulego.ErrReloadBufferFull`
+	//暂存的消息在新规则链完全初始化后交给新规则链处理，加载失败则交给原规则链处理
+	ReloadBufferSize int
+	//OnComponentMetrics 定期回调实现了`MetricsProvider`的节点的运行指标，例如dbClient连接池统计，为空则不收集
+	OnComponentMetrics func(chainId, nodeId string, metrics map[string]interface{})
+	//MetricsInterval OnComponentMetrics的回调间隔，默认10秒
+	MetricsInterval time.Duration
+	//Runtime 运行时可以修改的日志级别、调试信息采样比例、协程池大小和js脚本执行超时时间，为空则不能修改
+	//参考`NewRuntimeConfig`
+	Runtime *RuntimeConfig
 }
 
+// defaultStateStore 没有配置StateStore时使用的内存状态存储
+var defaultStateStore = state.NewMemory()
+
+// defaultKVStore 没有配置KVStore时使用的内存存储，同一个进程的所有规则引擎共享
+var defaultKVStore = kv.NewMemory(nil)
+
 // Option is a function type that modifies the Config.
 type Option func(*Config) error
 
@@ -54,15 +151,55 @@ func NewConfig(opts ...Option) Config {
 	c := &Config{
 		JsMaxExecutionTime: time.Millisecond * 2000,
 		Logger:             DefaultLogger(),
+		Clock:              clock.System,
+		StateStore:         state.NewMemory(),
 	}
 
 	// Apply the options to the Config.
 	for _, opt := range opts {
 		_ = opt(c)
 	}
+	if c.Runtime != nil {
+		c.Runtime.bind(*c)
+		c.Logger = c.Runtime.Logger(c.Logger)
+	}
 	return *c
 }
 
+// GetJsMaxExecutionTime 获取js脚本执行超时时间，配置了Runtime则使用Runtime当前的值
+func (c Config) GetJsMaxExecutionTime() time.Duration {
+	if c.Runtime != nil {
+		if d := c.Runtime.JsMaxExecutionTime(); d > 0 {
+			return d
+		}
+	}
+	return c.JsMaxExecutionTime
+}
+
+// GetClock 获取时钟，没有配置则返回系统时钟
+func (c Config) GetClock() clock.Clock {
+	if c.Clock == nil {
+		return clock.System
+	}
+	return c.Clock
+}
+
+// GetStateStore 获取节点状态存储，没有配置则返回默认的内存存储
+func (c Config) GetStateStore() state.Store {
+	if c.StateStore == nil {
+		return defaultStateStore
+	}
+	return c.StateStore
+}
+
+// GetKVStore 获取key/value存储，没有配置则返回进程内共享的内存存储
+func (c Config) GetKVStore() kv.Store {
+	if c.KVStore == nil {
+		return defaultKVStore
+	}
+	return c.KVStore
+}
+
 func DefaultPool() Pool {
 	wp := &pool.WorkerPool{MaxWorkersCount: math.MaxInt32}
 	wp.Start()
@@ -85,6 +222,14 @@ func WithOnDebug(onDebug func(flowType string, nodeId string, msg RuleMsg, relat
 	}
 }
 
+// WithOnPanic is an option that sets the on panic callback of the Config.
+func WithOnPanic(onPanic func(msg RuleMsg, err *PanicError)) Option {
+	return func(c *Config) error {
+		c.OnPanic = onPanic
+		return nil
+	}
+}
+
 // WithOnEnd is an option that sets the on end callback of the Config.
 func WithOnEnd(onEnd func(msg RuleMsg, err error)) Option {
 	return func(c *Config) error {
@@ -110,6 +255,14 @@ func WithDefaultPool() Option {
 	}
 }
 
+// WithJsFetch is an option that enables the js fetch function with the given sandbox config.
+func WithJsFetch(config JsFetchConfig) Option {
+	return func(c *Config) error {
+		c.JsFetch = &config
+		return nil
+	}
+}
+
 // WithJsMaxExecutionTime is an option that sets the js max execution time of the Config.
 func WithJsMaxExecutionTime(jsMaxExecutionTime time.Duration) Option {
 	return func(c *Config) error {
@@ -133,3 +286,201 @@ func WithLogger(logger Logger) Option {
 		return nil
 	}
 }
+
+// WithLoggerFactory is an option that sets the factory resolving a logger per rule chain and tenant.
+func WithLoggerFactory(factory LoggerFactory) Option {
+	return func(c *Config) error {
+		c.LoggerFactory = factory
+		return nil
+	}
+}
+
+// WithClock is an option that sets the clock of the Config.
+func WithClock(clock clock.Clock) Option {
+	return func(c *Config) error {
+		c.Clock = clock
+		return nil
+	}
+}
+
+// WithStateStore is an option that sets the node state store of the Config.
+func WithStateStore(store state.Store) Option {
+	return func(c *Config) error {
+		c.StateStore = store
+		return nil
+	}
+}
+
+// WithKVStore is an option that sets the shared key/value store of the Config.
+func WithKVStore(store kv.Store) Option {
+	return func(c *Config) error {
+		c.KVStore = store
+		return nil
+	}
+}
+
+// WithTenant is an option that sets the tenant of the Config.
+func WithTenant(tenant string) Option {
+	return func(c *Config) error {
+		c.Tenant = tenant
+		return nil
+	}
+}
+
+// WithQuarantine is an option that sets the poison message quarantine manager of the Config.
+func WithQuarantine(manager *quarantine.Manager) Option {
+	return func(c *Config) error {
+		c.Quarantine = manager
+		return nil
+	}
+}
+
+// WithOrderingTimeout is an option that sets the ordering timeout of the Config.
+func WithOrderingTimeout(timeout time.Duration) Option {
+	return func(c *Config) error {
+		c.OrderingTimeout = timeout
+		return nil
+	}
+}
+
+// WithAsyncTimeout is an option that sets the default async completion timeout of the Config.
+func WithAsyncTimeout(timeout time.Duration) Option {
+	return func(c *Config) error {
+		c.AsyncTimeout = timeout
+		return nil
+	}
+}
+
+// WithQuota is an option that sets the chain quota manager of the Config.
+func WithQuota(manager *quota.Manager) Option {
+	return func(c *Config) error {
+		c.Quota = manager
+		return nil
+	}
+}
+
+// WithDegradedStart is an option that lets rule chains start with degraded nodes
+// whose Init failed, re-initializing them in the background after reinitInterval.
+func WithDegradedStart(reinitInterval time.Duration) Option {
+	return func(c *Config) error {
+		c.DegradedStart = true
+		c.ReinitInterval = reinitInterval
+		return nil
+	}
+}
+
+// WithSecretsProvider is an option that sets the secrets provider and the name of the key
+// used to encrypt sensitive configuration fields.
+func WithSecretsProvider(provider secrets.Provider, keyName string) Option {
+	return func(c *Config) error {
+		c.SecretsProvider = provider
+		c.SecretKeyName = keyName
+		return nil
+	}
+}
+
+// WithTrustedKey is an option that adds a trusted Ed25519 public key. Once any key is
+// configured, only rule chains signed by a trusted key can be loaded.
+func WithTrustedKey(keyId string, publicKey ed25519.PublicKey) Option {
+	return func(c *Config) error {
+		if c.TrustedKeys == nil {
+			c.TrustedKeys = make(map[string]ed25519.PublicKey)
+		}
+		c.TrustedKeys[keyId] = publicKey
+		return nil
+	}
+}
+
+// WithAuditSink is an option that records rule chain configuration changes to the sink.
+func WithAuditSink(sink audit.Sink) Option {
+	return func(c *Config) error {
+		c.AuditSink = sink
+		return nil
+	}
+}
+
+// WithFault is an option that injects faults into all nodes of the given type.
+func WithFault(nodeType string, fault FaultConfig) Option {
+	return func(c *Config) error {
+		if c.Faults == nil {
+			c.Faults = make(map[string]FaultConfig)
+		}
+		c.Faults[nodeType] = fault
+		return nil
+	}
+}
+
+// WithMaxMessageSize is an option that sets the max message size and the blob store for oversize payloads of the Config.
+func WithMaxMessageSize(size int, store blob.Store) Option {
+	return func(c *Config) error {
+		c.MaxMessageSize = size
+		c.BlobStore = store
+		return nil
+	}
+}
+
+// WithIncludeFS is an option that sets the file system of the files referenced by $ref in the Config.
+func WithIncludeFS(fsys fs.FS) Option {
+	return func(c *Config) error {
+		c.IncludeFS = fsys
+		return nil
+	}
+}
+
+// WithChainExecutor is an option that sets the chain executor of the Config.
+func WithChainExecutor(executor func(chainId string, msg RuleMsg, onEnd func(msg RuleMsg, err error)) error) Option {
+	return func(c *Config) error {
+		c.ChainExecutor = executor
+		return nil
+	}
+}
+
+// WithAlerts is an option that sets the relation metrics alert manager of the Config.
+func WithAlerts(manager *alert.Manager) Option {
+	return func(c *Config) error {
+		c.Alerts = manager
+		return nil
+	}
+}
+
+// WithDryRun is an option that enables dry-run mode, side effects of nodes are recorded by onDryRun instead of executed.
+func WithDryRun(onDryRun func(action DryRunAction)) Option {
+	return func(c *Config) error {
+		c.DryRun = true
+		c.OnDryRun = onDryRun
+		return nil
+	}
+}
+
+// WithProfiler is an option that sets the node execution profiler of the Config.
+func WithProfiler(profiler *profile.Profiler) Option {
+	return func(c *Config) error {
+		c.Profiler = profiler
+		return nil
+	}
+}
+
+// WithReloadBufferSize is an option that sets the maximum number of messages buffered while the rule chain is reloading.
+func WithReloadBufferSize(size int) Option {
+	return func(c *Config) error {
+		c.ReloadBufferSize = size
+		return nil
+	}
+}
+
+// WithOnComponentMetrics is an option that sets the callback periodically receiving the metrics of components implementing MetricsProvider.
+func WithOnComponentMetrics(interval time.Duration, onMetrics func(chainId, nodeId string, metrics map[string]interface{})) Option {
+	return func(c *Config) error {
+		c.MetricsInterval = interval
+		c.OnComponentMetrics = onMetrics
+		return nil
+	}
+}
+
+// WithRuntimeConfig is an option that sets the runtime config which can change log level, debug sampling, pool size and js timeout at runtime.
+func WithRuntimeConfig(runtime *RuntimeConfig) Option {
+	return func(c *Config) error {
+		c.Runtime = runtime
+		return nil
+	}
+}
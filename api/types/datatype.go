@@ -0,0 +1,105 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// 消息数据类型语义：
+//
+//	JSON   msg.Data是合法的JSON文本
+//	TEXT   msg.Data是UTF-8文本，DataType为空时按TEXT处理
+//	BINARY msg.Data是base64(标准编码)编码的二进制数据
+//
+// 修改msg.Data的节点需要同时设置msg.DataType，需要指定数据类型的节点实现`DataTypeRequirer`，
+// 引擎在调用OnMsg前按照`ConvertDataType`自动转换，转换失败则把消息发送到`Failure`链
+
+// DataTypeKey js脚本返回结果中指定消息数据类型的key
+const DataTypeKey = "dataType"
+
+// ErrDataTypeConversion 消息数据类型转换失败
+var ErrDataTypeConversion = errors.New("data type conversion failed")
+
+// DataTypeRequirer 声明节点需要的消息数据类型，返回空表示不限制
+type DataTypeRequirer interface {
+	RequiredDataType() DataType
+}
+
+// DetectDataType 根据数据内容判断数据类型，JSON对象或者数组为JSON，UTF-8文本为TEXT，否则为BINARY
+// BINARY需要调用方把数据编码成base64
+func DetectDataType(data []byte) DataType {
+	trimmed := strings.TrimSpace(string(data))
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid(data) {
+		return JSON
+	}
+	if utf8.Valid(data) {
+		return TEXT
+	}
+	return BINARY
+}
+
+// ConvertDataType 把消息转换成指定数据类型，返回转换后的消息副本
+// 转换规则：
+//
+//	JSON->TEXT    不修改数据
+//	TEXT->JSON    数据必须是合法的JSON
+//	JSON/TEXT->BINARY  base64编码
+//	BINARY->TEXT  base64解码，解码结果必须是UTF-8文本
+//	BINARY->JSON  base64解码，解码结果必须是合法的JSON
+func ConvertDataType(msg RuleMsg, to DataType) (RuleMsg, error) {
+	from := msg.DataType
+	if from == "" {
+		from = TEXT
+	}
+	if from == to || to == "" {
+		return msg, nil
+	}
+	data := msg.Data
+	switch {
+	case from == JSON && to == TEXT:
+	case from == TEXT && to == JSON:
+		if !json.Valid([]byte(data)) {
+			return msg, fmt.Errorf("%w: %s to %s: invalid json", ErrDataTypeConversion, from, to)
+		}
+	case (from == JSON || from == TEXT) && to == BINARY:
+		data = base64.StdEncoding.EncodeToString([]byte(data))
+	case from == BINARY && (to == TEXT || to == JSON):
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return msg, fmt.Errorf("%w: %s to %s: %s", ErrDataTypeConversion, from, to, err)
+		}
+		if to == TEXT && !utf8.Valid(decoded) {
+			return msg, fmt.Errorf("%w: %s to %s: invalid utf-8", ErrDataTypeConversion, from, to)
+		}
+		if to == JSON && !json.Valid(decoded) {
+			return msg, fmt.Errorf("%w: %s to %s: invalid json", ErrDataTypeConversion, from, to)
+		}
+		data = string(decoded)
+	default:
+		return msg, fmt.Errorf("%w: unsupported %s to %s", ErrDataTypeConversion, from, to)
+	}
+	result := msg.Copy()
+	result.DataType = to
+	result.Data = data
+	return result, nil
+}
@@ -0,0 +1,216 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OnDebugFunc 节点调试信息回调函数
+type OnDebugFunc func(flowType string, nodeId string, msg RuleMsg, relationType string, err error)
+
+// DebugConfig 调试信息异步分发配置
+type DebugConfig struct {
+	//QueueSize 缓冲队列大小，队列满时丢弃调试信息，默认1024
+	QueueSize int
+	//Workers 调用回调函数的协程数，默认1
+	Workers int
+	//RatePerNode 每个节点每秒最多分发的调试信息数，超过则丢弃，0表示不限制
+	RatePerNode float64
+	//Burst 每个节点允许的突发数量，默认等于RatePerNode，最小1
+	Burst int
+	//DedupWindow 在该时间窗口内，同一个节点、同一条消息、相同流向和关系类型的调试信息只分发一次，0表示不去重
+	DedupWindow time.Duration
+	//Clock 时钟，默认使用系统时钟
+	Clock clock.Clock
+}
+
+// DebugStats 调试信息分发统计
+type DebugStats struct {
+	//Delivered 已经分发的数量
+	Delivered uint64 `json:"delivered"`
+	//DroppedQueueFull 队列满丢弃的数量
+	DroppedQueueFull uint64 `json:"droppedQueueFull"`
+	//DroppedRateLimited 超过节点速率限制丢弃的数量
+	DroppedRateLimited uint64 `json:"droppedRateLimited"`
+	//Deduplicated 重复丢弃的数量
+	Deduplicated uint64 `json:"deduplicated"`
+}
+
+type debugEvent struct {
+	flowType     string
+	nodeId       string
+	msg          RuleMsg
+	relationType string
+	err          error
+}
+
+type debugBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// DebugDispatcher 调试信息异步分发器，包装OnDebug回调函数，
+// 调试信息先放入有界队列再由后台协程回调，回调处理慢时丢弃调试信息，不会阻塞规则链执行
+// 使用示例：
+//
+//	dispatcher := types.NewDebugDispatcher(onDebug, types.DebugConfig{RatePerNode: 100})
+//	config := rulego.NewConfig(types.WithOnDebug(dispatcher.OnDebug))
+//	defer dispatcher.Close()
+type DebugDispatcher struct {
+	//stats 放在第一个字段，保证32位平台原子操作64位对齐
+	stats   DebugStats
+	onDebug OnDebugFunc
+	config  DebugConfig
+	queue   chan debugEvent
+	buckets map[string]*debugBucket
+	seen    map[string]time.Time
+	//lock 保护buckets和seen
+	lock sync.Mutex
+	//closeLock 保护queue关闭
+	closeLock sync.RWMutex
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewDebugDispatcher 创建调试信息异步分发器
+func NewDebugDispatcher(onDebug OnDebugFunc, config DebugConfig) *DebugDispatcher {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1024
+	}
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.Burst <= 0 {
+		config.Burst = int(config.RatePerNode)
+		if config.Burst < 1 {
+			config.Burst = 1
+		}
+	}
+	if config.Clock == nil {
+		config.Clock = clock.System
+	}
+	d := &DebugDispatcher{
+		onDebug: onDebug,
+		config:  config,
+		queue:   make(chan debugEvent, config.QueueSize),
+		buckets: make(map[string]*debugBucket),
+		seen:    make(map[string]time.Time),
+	}
+	for i := 0; i < config.Workers; i++ {
+		d.wg.Add(1)
+		go d.run()
+	}
+	return d
+}
+
+// OnDebug 把调试信息放入队列，不阻塞，可以作为`Config.OnDebug`回调函数
+func (d *DebugDispatcher) OnDebug(flowType string, nodeId string, msg RuleMsg, relationType string, err error) {
+	if !d.admit(flowType, nodeId, msg, relationType) {
+		return
+	}
+	d.closeLock.RLock()
+	defer d.closeLock.RUnlock()
+	if d.closed {
+		return
+	}
+	select {
+	case d.queue <- debugEvent{flowType: flowType, nodeId: nodeId, msg: msg, relationType: relationType, err: err}:
+	default:
+		atomic.AddUint64(&d.stats.DroppedQueueFull, 1)
+	}
+}
+
+// Stats 分发统计
+func (d *DebugDispatcher) Stats() DebugStats {
+	return DebugStats{
+		Delivered:          atomic.LoadUint64(&d.stats.Delivered),
+		DroppedQueueFull:   atomic.LoadUint64(&d.stats.DroppedQueueFull),
+		DroppedRateLimited: atomic.LoadUint64(&d.stats.DroppedRateLimited),
+		Deduplicated:       atomic.LoadUint64(&d.stats.Deduplicated),
+	}
+}
+
+// Close 停止接收调试信息，等待队列中的调试信息分发完成
+func (d *DebugDispatcher) Close() {
+	d.closeLock.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.closeLock.Unlock()
+	d.wg.Wait()
+}
+
+func (d *DebugDispatcher) run() {
+	defer d.wg.Done()
+	for event := range d.queue {
+		d.onDebug(event.flowType, event.nodeId, event.msg, event.relationType, event.err)
+		atomic.AddUint64(&d.stats.Delivered, 1)
+	}
+}
+
+// admit 检查去重和节点速率限制
+func (d *DebugDispatcher) admit(flowType string, nodeId string, msg RuleMsg, relationType string) bool {
+	if d.config.DedupWindow <= 0 && d.config.RatePerNode <= 0 {
+		return true
+	}
+	now := d.config.Clock.Now()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.config.DedupWindow > 0 {
+		key := nodeId + "|" + flowType + "|" + relationType + "|" + msg.Id
+		if last, ok := d.seen[key]; ok && now.Sub(last) < d.config.DedupWindow {
+			atomic.AddUint64(&d.stats.Deduplicated, 1)
+			return false
+		}
+		if len(d.seen) >= d.config.QueueSize*4 {
+			d.evictSeen(now)
+		}
+		d.seen[key] = now
+	}
+	if d.config.RatePerNode > 0 {
+		bucket, ok := d.buckets[nodeId]
+		if !ok {
+			bucket = &debugBucket{tokens: float64(d.config.Burst), last: now}
+			d.buckets[nodeId] = bucket
+		}
+		bucket.tokens += now.Sub(bucket.last).Seconds() * d.config.RatePerNode
+		if bucket.tokens > float64(d.config.Burst) {
+			bucket.tokens = float64(d.config.Burst)
+		}
+		bucket.last = now
+		if bucket.tokens < 1 {
+			atomic.AddUint64(&d.stats.DroppedRateLimited, 1)
+			return false
+		}
+		bucket.tokens--
+	}
+	return true
+}
+
+// evictSeen 删除超过去重窗口的记录，调用方需要持有锁
+func (d *DebugDispatcher) evictSeen(now time.Time) {
+	for key, last := range d.seen {
+		if now.Sub(last) >= d.config.DedupWindow {
+			delete(d.seen, key)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+// DryRunKey dry-run模式下模拟执行的节点在消息元数据中设置dryRun=true
+const DryRunKey = "dryRun"
+
+// DryRunAction dry-run模式下节点将要执行但没有执行的操作
+type DryRunAction struct {
+	//NodeId 节点ID
+	NodeId string
+	//NodeType 节点类型
+	NodeType string
+	//Operation 操作类型，例如：INSERT、POST、publish
+	Operation string
+	//Target 操作目标，例如：SQL语句、URL、主题
+	Target string
+	//Params 操作参数，例如：SQL参数、请求头
+	Params map[string]interface{}
+	//Data 发送的数据
+	Data string
+	//Msg 触发该操作的消息
+	Msg RuleMsg
+}
+
+// SideEffectNode 有外部副作用的节点，例如：写数据库、发送HTTP请求、发布MQTT消息
+// dry-run模式下，有副作用的操作不会执行，节点模拟成功发送到`Success`链，并通过`Config.OnDryRun`记录将要执行的操作
+type SideEffectNode interface {
+	//SideEffect 返回处理该消息将要执行的操作，返回false表示该消息没有副作用，例如：查询语句、GET请求，正常执行
+	SideEffect(msg RuleMsg) (DryRunAction, bool)
+}
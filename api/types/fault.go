@@ -0,0 +1,42 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFaultInjected 故障注入默认返回的错误
+var ErrFaultInjected = errors.New("fault injected")
+
+// FaultConfig 节点故障注入配置，用于在预发布环境测试规则链的容错能力
+// 通过`WithFault`配置到指定类型的节点，节点处理每条消息前按概率注入故障
+type FaultConfig struct {
+	//ErrorRate 注入错误的概率，范围0~1，消息不经过节点处理直接发送到`Failure`链
+	ErrorRate float64
+	//DropRate 丢弃消息的概率，范围0~1，被丢弃的消息不会经过节点处理，也不会发送到下一个节点
+	DropRate float64
+	//Latency 节点处理消息前增加的延迟
+	Latency time.Duration
+	//LatencyJitter 延迟随机抖动，实际延迟在 Latency ~ Latency+LatencyJitter 之间
+	LatencyJitter time.Duration
+	//Error 注入的错误，默认：ErrFaultInjected
+	Error error
+	//Rand 随机数函数，返回[0,1)，默认使用`math/rand.Float64`
+	Rand func() float64
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"net"
+	"strings"
+)
+
+// DefaultJsFetchMaxResponseBytes js脚本fetch函数默认最大响应大小
+const DefaultJsFetchMaxResponseBytes = 1 << 20
+
+// JsFetchConfig js脚本fetch函数沙箱配置
+// 只允许访问AllowedHosts中的http、https地址，请求超时不超过`Config.JsMaxExecutionTime`
+type JsFetchConfig struct {
+	//AllowedHosts 允许访问的主机，支持通配符前缀，例如：api.example.com、*.example.com、127.0.0.1:8080
+	//不带端口匹配所有端口，为空不允许访问任何主机
+	AllowedHosts []string
+	//MaxResponseBytes 最大响应大小，超过则请求失败，默认1MB
+	MaxResponseBytes int64
+}
+
+// HostAllowed 主机是否允许访问，host格式：主机名或者主机名:端口
+func (c JsFetchConfig) HostAllowed(host string) bool {
+	hostname, port := splitHostPort(host)
+	for _, allowed := range c.AllowedHosts {
+		allowedHost, allowedPort := splitHostPort(allowed)
+		if allowedPort != "" && allowedPort != port {
+			continue
+		}
+		if strings.HasPrefix(allowedHost, "*.") {
+			if strings.HasSuffix(hostname, allowedHost[1:]) {
+				return true
+			}
+		} else if hostname == allowedHost {
+			return true
+		}
+	}
+	return false
+}
+
+// splitHostPort 拆分主机名和端口，没有端口返回空，主机名转为小写
+func splitHostPort(host string) (string, string) {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = strings.Trim(host, "[]"), ""
+	}
+	return strings.ToLower(hostname), port
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+)
+
+func TestJsFetchConfigHostAllowed(t *testing.T) {
+	config := JsFetchConfig{AllowedHosts: []string{"api.example.com", "*.rulego.cc", "127.0.0.1:8080", "[::1]"}}
+	assert.True(t, config.HostAllowed("api.example.com"))
+	assert.True(t, config.HostAllowed("API.example.com:443"))
+	assert.False(t, config.HostAllowed("example.com"))
+	assert.True(t, config.HostAllowed("a.rulego.cc"))
+	assert.True(t, config.HostAllowed("a.b.rulego.cc:80"))
+	assert.False(t, config.HostAllowed("rulego.cc"))
+	assert.False(t, config.HostAllowed("evilrulego.cc"))
+	assert.True(t, config.HostAllowed("127.0.0.1:8080"))
+	assert.False(t, config.HostAllowed("127.0.0.1:9090"))
+	assert.False(t, config.HostAllowed("127.0.0.1"))
+	assert.True(t, config.HostAllowed("[::1]:9090"))
+	assert.False(t, JsFetchConfig{}.HostAllowed("api.example.com"))
+}
@@ -19,12 +19,75 @@ package types
 import (
 	"log"
 	"os"
+	"strings"
 )
 
 type Logger interface {
 	Printf(format string, v ...interface{})
 }
 
+// LevelLogger 支持日志级别的日志记录接口
+type LevelLogger interface {
+	Logger
+	Logf(level string, format string, v ...interface{})
+}
+
+// Logf 按指定级别输出日志，logger没有实现`LevelLogger`则使用Printf输出
+func Logf(logger Logger, level string, format string, v ...interface{}) {
+	if l, ok := logger.(LevelLogger); ok {
+		l.Logf(level, format, v...)
+	} else {
+		logger.Printf(format, v...)
+	}
+}
+
+// LogScope 日志记录器的作用范围
+type LogScope struct {
+	//ChainId 规则链ID
+	ChainId string
+	//Tenant 租户ID，参考Config.Tenant
+	Tenant string
+}
+
+// String 返回日志标签，例如：tenant=t1 chain=c1，为空的字段不输出
+func (s LogScope) String() string {
+	var labels []string
+	if s.Tenant != "" {
+		labels = append(labels, "tenant="+s.Tenant)
+	}
+	if s.ChainId != "" {
+		labels = append(labels, "chain="+s.ChainId)
+	}
+	return strings.Join(labels, " ")
+}
+
+// LoggerFactory 按规则链和租户创建日志记录器，返回nil则使用Config.Logger
+type LoggerFactory func(scope LogScope) Logger
+
+// NewScopedLogger 在每条日志前加上租户和规则链标签，例如：[tenant=t1 chain=c1] ...
+// logger实现了`LevelLogger`则保留日志级别
+func NewScopedLogger(logger Logger, scope LogScope) Logger {
+	prefix := scope.String()
+	if prefix == "" {
+		return logger
+	}
+	//标签中的%不作为格式化占位符
+	return &scopedLogger{logger: logger, prefix: "[" + strings.ReplaceAll(prefix, "%", "%%") + "] "}
+}
+
+type scopedLogger struct {
+	logger Logger
+	prefix string
+}
+
+func (l *scopedLogger) Printf(format string, v ...interface{}) {
+	l.logger.Printf(l.prefix+format, v...)
+}
+
+func (l *scopedLogger) Logf(level string, format string, v ...interface{}) {
+	Logf(l.logger, level, l.prefix+format, v...)
+}
+
 // this is a safeguard, breaking on compile time in case
 // `log.Logger` does not adhere to our `Logger` interface.
 // see https://golang.org/doc/faq#guarantee_satisfies_interface
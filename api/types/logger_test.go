@@ -0,0 +1,50 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"fmt"
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+)
+
+// levelTestLogger 记录输出的日志和级别
+type levelTestLogger struct {
+	lines []string
+}
+
+func (l *levelTestLogger) Printf(format string, v ...interface{}) {
+	l.Logf(LogLevelInfo, format, v...)
+}
+
+func (l *levelTestLogger) Logf(level string, format string, v ...interface{}) {
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, v...))
+}
+
+func TestScopedLogger(t *testing.T) {
+	assert.Equal(t, "tenant=t1 chain=c1", LogScope{ChainId: "c1", Tenant: "t1"}.String())
+	assert.Equal(t, "chain=c1", LogScope{ChainId: "c1"}.String())
+
+	target := &levelTestLogger{}
+	logger := NewScopedLogger(target, LogScope{ChainId: "c1", Tenant: "t1"})
+	logger.Printf("hello %s", "100%")
+	Logf(logger, LogLevelError, "error %d", 1)
+	assert.Equal(t, []string{"info [tenant=t1 chain=c1] hello 100%", "error [tenant=t1 chain=c1] error 1"}, target.lines)
+
+	//没有标签不包装
+	assert.Equal(t, Logger(target), NewScopedLogger(target, LogScope{}))
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import "fmt"
+
+// 节点panic时写入消息元数据的key
+const (
+	//PanicNodeKey 发生panic的节点ID
+	PanicNodeKey = "panicNode"
+	//PanicErrorKey panic的值
+	PanicErrorKey = "panicError"
+	//PanicStackKey panic的堆栈信息
+	PanicStackKey = "panicStack"
+)
+
+// PanicError 节点处理消息发生panic时的错误，包含panic的值和堆栈信息
+type PanicError struct {
+	//NodeId 节点ID
+	NodeId string
+	//NodeType 节点类型
+	NodeType string
+	//Value panic的值
+	Value interface{}
+	//Stack 堆栈信息
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("node %s(%s) panic: %v", e.NodeId, e.NodeType, e.Value)
+}
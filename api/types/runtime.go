@@ -0,0 +1,186 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// 日志级别
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+	LogLevelOff   = "off"
+)
+
+var logLevels = map[string]int{
+	LogLevelDebug: 0,
+	LogLevelInfo:  1,
+	LogLevelWarn:  2,
+	LogLevelError: 3,
+	LogLevelOff:   4,
+}
+
+// ErrPoolNotTunable 协程池不支持运行时修改大小
+var ErrPoolNotTunable = errors.New("pool does not support tune")
+
+// TunablePool 可以在运行时修改大小的协程池，`pool.WorkerPool`和ants协程池都实现了该接口
+type TunablePool interface {
+	Tune(size int)
+	Cap() int
+}
+
+// RuntimeSettings 运行时可以修改的引擎配置
+type RuntimeSettings struct {
+	//LogLevel 日志级别：debug、info、warn、error、off，没有指定级别的`Logger.Printf`按info级别输出
+	LogLevel string `json:"logLevel"`
+	//DebugSampleRate OnDebug调试信息采样比例，取值0-1，按消息ID采样，同一条消息的调试信息全部保留或者全部丢弃
+	DebugSampleRate float64 `json:"debugSampleRate"`
+	//PoolSize 协程池最大协程数，协程池实现了`TunablePool`才能修改，0表示未知
+	PoolSize int `json:"poolSize"`
+	//JsMaxExecutionTime js脚本执行超时时间，单位毫秒
+	JsMaxExecutionTime int64 `json:"jsMaxExecutionTime"`
+}
+
+// RuntimeConfig 可以在运行时修改的引擎配置，修改后立即对新的执行生效，不需要重新加载规则链
+// 通过`WithRuntimeConfig`配置，多个规则引擎可以共享同一个RuntimeConfig，
+// 管理接口可以通过`admin.WithRuntimeConfig`查询和修改
+type RuntimeConfig struct {
+	lock     sync.RWMutex
+	settings RuntimeSettings
+	pool     Pool
+}
+
+// NewRuntimeConfig 创建运行时配置，默认info日志级别，不采样调试信息
+// 协程池和js脚本执行超时时间在`NewConfig`时从Config读取
+func NewRuntimeConfig() *RuntimeConfig {
+	return &RuntimeConfig{settings: RuntimeSettings{LogLevel: LogLevelInfo, DebugSampleRate: 1}}
+}
+
+// bind 从Config读取初始的协程池和js脚本执行超时时间
+func (r *RuntimeConfig) bind(config Config) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.pool == nil && config.Pool != nil {
+		r.pool = config.Pool
+		if tunable, ok := r.pool.(TunablePool); ok && r.settings.PoolSize == 0 {
+			r.settings.PoolSize = tunable.Cap()
+		}
+	}
+	if r.settings.JsMaxExecutionTime == 0 {
+		r.settings.JsMaxExecutionTime = config.JsMaxExecutionTime.Milliseconds()
+	}
+}
+
+// Settings 当前配置
+func (r *RuntimeConfig) Settings() RuntimeSettings {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.settings
+}
+
+// Update 修改配置，校验失败则不修改任何配置
+func (r *RuntimeConfig) Update(settings RuntimeSettings) error {
+	if _, ok := logLevels[settings.LogLevel]; !ok {
+		return fmt.Errorf("invalid log level: %s", settings.LogLevel)
+	}
+	if settings.DebugSampleRate < 0 || settings.DebugSampleRate > 1 {
+		return fmt.Errorf("debug sample rate must be between 0 and 1: %v", settings.DebugSampleRate)
+	}
+	if settings.PoolSize < 0 {
+		return fmt.Errorf("invalid pool size: %d", settings.PoolSize)
+	}
+	if settings.JsMaxExecutionTime <= 0 {
+		return fmt.Errorf("invalid js max execution time: %d", settings.JsMaxExecutionTime)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if settings.PoolSize != r.settings.PoolSize {
+		tunable, ok := r.pool.(TunablePool)
+		if !ok || settings.PoolSize == 0 {
+			return ErrPoolNotTunable
+		}
+		tunable.Tune(settings.PoolSize)
+	}
+	r.settings = settings
+	return nil
+}
+
+// LogEnabled 是否输出指定级别的日志，r为空则全部输出
+func (r *RuntimeConfig) LogEnabled(level string) bool {
+	if r == nil {
+		return true
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return level != LogLevelOff && logLevels[level] >= logLevels[r.settings.LogLevel]
+}
+
+// SampleDebug 指定消息的调试信息是否分发，r为空则全部分发
+func (r *RuntimeConfig) SampleDebug(msgId string) bool {
+	if r == nil {
+		return true
+	}
+	r.lock.RLock()
+	rate := r.settings.DebugSampleRate
+	r.lock.RUnlock()
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(msgId))
+	return float64(h.Sum32()%10000) < rate*10000
+}
+
+// JsMaxExecutionTime js脚本执行超时时间
+func (r *RuntimeConfig) JsMaxExecutionTime() time.Duration {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return time.Duration(r.settings.JsMaxExecutionTime) * time.Millisecond
+}
+
+// Logger 包装日志记录器，按当前日志级别过滤
+func (r *RuntimeConfig) Logger(logger Logger) Logger {
+	if l, ok := logger.(*runtimeLogger); ok && l.runtime == r {
+		return logger
+	}
+	return &runtimeLogger{Logger: logger, runtime: r}
+}
+
+type runtimeLogger struct {
+	Logger
+	runtime *RuntimeConfig
+}
+
+func (l *runtimeLogger) Printf(format string, v ...interface{}) {
+	l.Logf(LogLevelInfo, format, v...)
+}
+
+func (l *runtimeLogger) Logf(level string, format string, v ...interface{}) {
+	if l.runtime.LogEnabled(level) {
+		l.Logger.Printf(format, v...)
+	}
+}
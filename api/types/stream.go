@@ -0,0 +1,160 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"github.com/2018yuli/rulego/utils/clock"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Complete 流式输出结束关系，`Stream.Close`成功结束时把输入消息发送到该关系
+const Complete = "Complete"
+
+// 流式输出消息元数据的key
+const (
+	//StreamIdKey 流ID，为输入消息ID
+	StreamIdKey = "streamId"
+	//StreamSeqKey 增量输出序号，从0开始，结束消息为输出总数
+	StreamSeqKey = "streamSeq"
+	//StreamEndKey 结束消息标记，值为true
+	StreamEndKey = "streamEnd"
+)
+
+// Stream 节点对一条输入消息流式输出多条消息的句柄，通过`RuleContext.Stream`获取
+// 用于SSE、分块REST响应、数据库逐行读取等场景。每条输出消息单独流转到下一个节点，
+// 最后通过Close发送结束信号。Close后或者超时后Emit返回false并忽略
+type Stream interface {
+	//Emit 发送一条增量输出消息，relationTypes为空则发送到`Success`关系
+	Emit(msg RuleMsg, relationTypes ...string) bool
+	//Close 结束流，err为空把输入消息发送到`Complete`关系，否则发送到`Failure`关系
+	Close(err error) bool
+	//Count 已经输出的消息数
+	Count() int
+}
+
+// stream Stream默认实现
+type stream struct {
+	ctx         RuleContext
+	msg         RuleMsg
+	idleTimeout time.Duration
+	onEmit      func()
+	onClose     func(timedOut bool)
+	timer       clock.Timer
+	generation  int
+	count       int
+	closed      bool
+	lock        sync.Mutex
+}
+
+// NewStream 创建流式输出句柄，超过idleTimeout没有输出则以`ErrAsyncTimeout`结束
+// idleTimeout<=0使用`Config.AsyncTimeout`，onEmit在每次输出时调用，onClose在结束并发送结束消息后调用一次，可以为空
+// 用于`RuleContext`实现
+func NewStream(ctx RuleContext, msg RuleMsg, idleTimeout time.Duration, onEmit func(), onClose func(timedOut bool)) Stream {
+	if idleTimeout <= 0 {
+		idleTimeout = ctx.Config().AsyncTimeout
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultAsyncTimeout
+	}
+	s := &stream{ctx: ctx, msg: msg.Copy(), idleTimeout: idleTimeout, onEmit: onEmit, onClose: onClose}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.resetTimer()
+	return s
+}
+
+func (s *stream) Emit(msg RuleMsg, relationTypes ...string) bool {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return false
+	}
+	seq := s.count
+	s.count++
+	s.resetTimer()
+	s.lock.Unlock()
+	if s.onEmit != nil {
+		s.onEmit()
+	}
+	msg.Metadata = msg.Metadata.Copy()
+	msg.Metadata.PutValue(StreamIdKey, s.msg.Id)
+	msg.Metadata.PutValue(StreamSeqKey, strconv.Itoa(seq))
+	if len(relationTypes) == 0 {
+		relationTypes = []string{Success}
+	}
+	s.ctx.TellNext(msg, relationTypes...)
+	return true
+}
+
+func (s *stream) Close(err error) bool {
+	return s.close(err, false)
+}
+
+func (s *stream) Count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.count
+}
+
+func (s *stream) close(err error, timedOut bool) bool {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return false
+	}
+	s.closed = true
+	if !timedOut {
+		s.timer.Stop()
+	}
+	count := s.count
+	s.lock.Unlock()
+	if s.onClose != nil {
+		defer s.onClose(timedOut)
+	}
+	msg := s.msg
+	msg.Metadata.PutValue(StreamIdKey, s.msg.Id)
+	msg.Metadata.PutValue(StreamSeqKey, strconv.Itoa(count))
+	msg.Metadata.PutValue(StreamEndKey, "true")
+	if err != nil {
+		s.ctx.TellFailure(msg, err)
+	} else {
+		s.ctx.TellNext(msg, Complete)
+	}
+	return true
+}
+
+// resetTimer 重新开始空闲计时，调用方需要持有锁
+func (s *stream) resetTimer() {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.generation++
+	generation := s.generation
+	s.timer = s.ctx.Config().GetClock().AfterFunc(s.idleTimeout, func() {
+		s.lock.Lock()
+		//已经重新计时
+		expired := generation == s.generation
+		s.lock.Unlock()
+		if expired && s.close(ErrAsyncTimeout, true) {
+			if logger := s.ctx.Config().Logger; logger != nil {
+				logger.Printf("node %s stream idle timeout after %s", s.ctx.GetSelfId(), s.idleTimeout)
+			}
+		}
+	})
+}
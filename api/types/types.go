@@ -20,6 +20,7 @@ import (
 	"context"
 	"github.com/2018yuli/rulego/utils/str"
 	"sync"
+	"time"
 )
 
 // 关系 节点与节点连接的关系，以下是常用的关系，可以自定义
@@ -92,6 +93,58 @@ type ComponentRegistry interface {
 	NewNode(nodeType string) (Node, error)
 	//GetComponents 获取所有组件列表
 	GetComponents() map[string]Node
+	//Describe 设置或者覆盖已注册组件的描述信息
+	Describe(descriptor ComponentDescriptor) error
+	//GetDescriptor 获取组件描述信息
+	GetDescriptor(nodeType string) (ComponentDescriptor, bool)
+	//Query 按分类、标签或者关键字查询组件，结果按分类和类型排序
+	Query(query ComponentQuery) []ComponentDescriptor
+	//Categories 获取所有组件分类
+	Categories() []string
+}
+
+// ComponentDescriptor 组件描述信息，用于管理界面和命令行工具展示组件面板
+type ComponentDescriptor struct {
+	//Type 组件类型
+	Type string `json:"type"`
+	//Category 分类，默认使用组件所在包名，例如：action、filter、transform
+	Category string `json:"category"`
+	//Tags 标签
+	Tags []string `json:"tags"`
+	//Description 描述
+	Description string `json:"description"`
+	//SensitiveFields 敏感配置字段，导出规则链时加密，默认读取组件配置中标记了`sensitive:"true"`的字段
+	SensitiveFields []string `json:"sensitiveFields,omitempty"`
+}
+
+// ComponentDescriber 组件可以实现该接口提供分类、标签和描述信息
+// 注册组件时自动读取
+type ComponentDescriber interface {
+	Descriptor() ComponentDescriptor
+}
+
+// HealthChecker 组件可以实现该接口提供健康检查，例如数据库连接、消息中间件连接状态
+// 管理接口的/readyz会调用该检查
+type HealthChecker interface {
+	//HealthCheck 检查组件是否可用，不可用返回错误
+	HealthCheck() error
+}
+
+// MetricsProvider 组件可以实现该接口提供运行指标，例如数据库连接池统计
+// 配置了`Config.OnComponentMetrics`时定期调用
+type MetricsProvider interface {
+	//Metrics 当前运行指标，返回nil表示没有指标
+	Metrics() map[string]interface{}
+}
+
+// ComponentQuery 组件查询条件，多个条件同时满足，空条件不过滤
+type ComponentQuery struct {
+	//Category 分类，完全匹配
+	Category string
+	//Tag 标签，完全匹配其中一个标签
+	Tag string
+	//Keyword 关键字，不区分大小写匹配类型、分类、标签或者描述
+	Keyword string
 }
 
 // Node 规则引擎节点组件接口
@@ -168,6 +221,18 @@ type RuleContext interface {
 	SetContext(c context.Context) RuleContext
 	//GetContext 获取用于不同组件实例共享信号量或者数据的上下文
 	GetContext() context.Context
+	//GetState 获取当前节点的状态，如果不存在ok=false
+	//状态作用域为规则链+节点，key可以使用消息字段(例如设备ID)区分不同消息的状态
+	GetState(key string) (value string, ok bool, err error)
+	//SetState 保存当前节点的状态，通过`Config.StateStore`持久化
+	SetState(key string, value string) error
+	//Async 声明当前消息由节点异步完成，用于基于回调的I/O，例如：MQTT确认、异步SDK
+	//节点可以在OnMsg返回后通过返回的句柄完成消息，超过timeout没有完成则把msg以`ErrAsyncTimeout`发送到`Failure`链
+	//timeout<=0使用`Config.AsyncTimeout`
+	Async(msg RuleMsg, timeout time.Duration) AsyncCompletion
+	//Stream 声明当前消息由节点流式输出，一条输入消息可以在一段时间内输出多条消息，最后通过Close发送结束信号
+	//超过idleTimeout没有输出则以`ErrAsyncTimeout`结束，idleTimeout<=0使用`Config.AsyncTimeout`
+	Stream(msg RuleMsg, idleTimeout time.Duration) Stream
 }
 
 // RuleContextOption 修改RuleContext选项的函数
@@ -185,6 +250,23 @@ func WithContext(c context.Context) RuleContextOption {
 	}
 }
 
+// AllNodeCompletedSetter 可以设置消息所有分支处理结束回调的RuleContext，规则引擎的RuleContext实现该接口
+type AllNodeCompletedSetter interface {
+	//SetOnAllNodeCompleted 设置当前消息所有分支处理结束后的回调，包括异步完成、流式输出和结束回调
+	SetOnAllNodeCompleted(onAllNodeCompleted func())
+}
+
+// WithOnAllNodeCompleted 当前消息所有分支处理结束后回调一次，在所有结束回调执行之后调用
+// 规则链有多个结束点时endFunc会执行多次，可以使用该回调在所有分支结束后确认消息
+// RuleContext没有实现AllNodeCompletedSetter则忽略
+func WithOnAllNodeCompleted(onAllNodeCompleted func()) RuleContextOption {
+	return func(rc RuleContext) {
+		if setter, ok := rc.(AllNodeCompletedSetter); ok {
+			setter.SetOnAllNodeCompleted(onAllNodeCompleted)
+		}
+	}
+}
+
 // JsEngine JavaScript脚本引擎
 type JsEngine interface {
 	//Execute 执行js脚本指定函数，js脚本在JsEngine实例化的时候进行初始化
@@ -195,6 +277,13 @@ type JsEngine interface {
 	Stop()
 }
 
+// ContextJsEngine js引擎可以实现该接口，按当前节点的规则上下文执行脚本，脚本可以访问当前节点的状态
+type ContextJsEngine interface {
+	JsEngine
+	//ExecuteWithContext 执行js脚本指定函数，ctx为当前节点的规则上下文
+	ExecuteWithContext(ctx RuleContext, functionName string, argumentList ...interface{}) (interface{}, error)
+}
+
 // Parser 规则链定义文件DSL解析器
 // 默认使用json方式，如果使用其他方式定义规则链，可以实现该接口
 // 然后通过该方式注册到规则引擎中：`rulego.NewConfig(WithParser(&MyParser{})`
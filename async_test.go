@@ -0,0 +1,100 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+// asyncTestNode 异步完成消息的测试组件，通过completions获取异步完成句柄
+type asyncTestNode struct {
+	completions chan types.AsyncCompletion
+}
+
+func (x *asyncTestNode) Type() string {
+	return "test/async"
+}
+
+func (x *asyncTestNode) New() types.Node {
+	return x
+}
+
+func (x *asyncTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *asyncTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	x.completions <- ctx.Async(msg, 0)
+	return nil
+}
+
+func (x *asyncTestNode) Destroy() {
+}
+
+func TestAsyncCompletion(t *testing.T) {
+	node := &asyncTestNode{completions: make(chan types.AsyncCompletion, 1)}
+	_ = Registry.Register(node)
+	defer Registry.Unregister("test/async")
+
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	config := NewConfig(types.WithClock(vc), types.WithAsyncTimeout(time.Second*5))
+	ruleEngine, err := NewChainBuilder().Id("async01").Node("test/async", nil).New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("async01")
+
+	var wg sync.WaitGroup
+	var endErr error
+	send := func() types.AsyncCompletion {
+		wg.Add(1)
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+			endErr = err
+			wg.Done()
+		})
+		select {
+		case completion := <-node.completions:
+			return completion
+		case <-time.After(time.Second * 3):
+			t.Fatal("node not called")
+			return nil
+		}
+	}
+
+	//OnMsg返回后完成消息
+	completion := send()
+	assert.Equal(t, int64(1), ruleEngine.Stats().AsyncPending)
+	assert.True(t, completion.TellSuccess(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}")))
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Nil(t, endErr)
+	assert.True(t, completion.Done())
+	//重复完成被忽略
+	assert.False(t, completion.TellFailure(types.RuleMsg{}, nil))
+	assert.Equal(t, ChainStats{}, ruleEngine.Stats())
+	assert.Equal(t, 0, vc.Pending())
+
+	//超时没有完成
+	completion = send()
+	vc.Advance(time.Second * 5)
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Equal(t, types.ErrAsyncTimeout, endErr)
+	assert.False(t, completion.TellSuccess(types.RuleMsg{}))
+	assert.Equal(t, ChainStats{AsyncTimeouts: 1}, ruleEngine.Stats())
+}
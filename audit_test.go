@@ -0,0 +1,72 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/audit"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	store := audit.NewMemory(0)
+	config := NewConfig(types.WithAuditSink(store), types.WithTenant("t1"))
+	ruleGo := &RuleGo{}
+	defer ruleGo.Stop()
+
+	builder := func(script string) []byte {
+		def, err := NewChainBuilder().Id("audit01").
+			Node("jsFilter", types.Configuration{"jsScript": script}).
+			Node("restApiCall", types.Configuration{"restEndpointUrlPattern": "http://127.0.0.1/api", "proxyPassword": "pass01"}).
+			DSL()
+		assert.Nil(t, err)
+		return def
+	}
+	ruleEngine, err := ruleGo.New("audit01", builder("return true;"), WithConfig(config), WithActor("lala"))
+	assert.Nil(t, err)
+	assert.Nil(t, ruleEngine.ReloadSelf(builder("return false;"), WithActor("lulu")))
+	assert.Nil(t, ruleEngine.ReloadSelf(builder("return false;")))
+	assert.NotNil(t, ruleEngine.ReloadSelf([]byte("{")))
+	assert.Nil(t, ruleEngine.ReloadChild(types.EmptyRuleNodeId, types.RuleNodeId{Id: "s1", Type: types.NODE},
+		[]byte(`{"id":"s1","type":"jsFilter","configuration":{"jsScript":"return 1;"}}`)))
+	ruleGo.DelBy("audit01", "admin")
+
+	events, err := store.Query(audit.Query{})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(events))
+	var actions []string
+	for _, event := range events {
+		assert.Equal(t, "audit01", event.ChainId)
+		assert.Equal(t, "t1", event.Tenant)
+		//敏感字段不记录到审计
+		assert.False(t, strings.Contains(event.Diff, "pass01"))
+		actions = append(actions, event.Action)
+	}
+	assert.Equal(t, []string{audit.ActionCreate, audit.ActionUpdate, audit.ActionReload, audit.ActionUpdate, audit.ActionUpdate, audit.ActionDelete}, actions)
+	assert.Equal(t, "lala", events[0].Actor)
+	assert.Equal(t, "lulu", events[1].Actor)
+	assert.True(t, strings.Contains(events[1].Diff, "-"))
+	assert.True(t, strings.Contains(events[1].Diff, "return false;"))
+	assert.True(t, strings.Contains(events[0].Diff, "******"))
+	assert.Equal(t, "", events[2].Diff)
+	assert.NotEqual(t, "", events[3].Error)
+	assert.Equal(t, "s1", events[4].NodeId)
+	assert.True(t, strings.Contains(events[4].Diff, "return 1;"))
+	assert.Equal(t, "admin", events[5].Actor)
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/blob"
+)
+
+// blobNode 包装需要消息内容的节点，调用OnMsg前从BlobStore加载被转存的消息内容
+type blobNode struct {
+	types.Node
+	store blob.Store
+}
+
+func newBlobNode(store blob.Store, node types.Node) types.Node {
+	return &blobNode{Node: node, store: store}
+}
+
+// OnMsg 加载消息内容，加载失败发送到`Failure`链
+func (x *blobNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	loaded, err := types.LoadMsg(x.store, msg)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	return x.Node.OnMsg(ctx, loaded)
+}
+
+// HealthCheck 检查原节点健康状态
+func (x *blobNode) HealthCheck() error {
+	if checker, ok := x.Node.(types.HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// Metrics 原节点运行指标
+func (x *blobNode) Metrics() map[string]interface{} {
+	return nodeMetrics(x.Node)
+}
+
+// limitSize 消息内容超过`Config.MaxMessageSize`时转存到BlobStore，没有配置BlobStore返回`types.ErrMessageTooLarge`
+// 转存失败返回错误
+func limitSize(config types.Config, msg types.RuleMsg) (types.RuleMsg, error) {
+	if config.MaxMessageSize <= 0 || len(msg.Data) <= config.MaxMessageSize {
+		return msg, nil
+	}
+	if config.BlobStore == nil {
+		return msg, types.ErrMessageTooLarge
+	}
+	msg, _, err := types.OffloadMsg(config.BlobStore, msg, config.MaxMessageSize)
+	return msg, err
+}
+
+// offload 节点输出的消息内容超过限制时转存到BlobStore，没有配置BlobStore或者转存失败保留原内容
+func (ctx *DefaultRuleContext) offload(msg types.RuleMsg) types.RuleMsg {
+	if ctx.config.BlobStore == nil {
+		return msg
+	}
+	offloaded, err := limitSize(ctx.config, msg)
+	if err != nil {
+		types.Logf(ctx.config.Logger, types.LogLevelError, "offload message=%s error=%s", msg.Id, err)
+		return msg
+	}
+	return offloaded
+}
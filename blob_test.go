@@ -0,0 +1,152 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/blob"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blobTestNode 需要消息内容的测试组件，记录收到的内容长度并在内容后追加"!"
+type blobTestNode struct {
+}
+
+func (x *blobTestNode) Type() string {
+	return "test/blob"
+}
+
+func (x *blobTestNode) New() types.Node {
+	return &blobTestNode{}
+}
+
+func (x *blobTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *blobTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	msg.Metadata.PutValue("contentSize", strconv.Itoa(len(msg.Data)))
+	msg.Data = msg.Data + "!"
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *blobTestNode) Destroy() {
+}
+
+// blobMetaTestNode 只使用元数据的测试组件
+type blobMetaTestNode struct {
+	blobTestNode
+}
+
+func (x *blobMetaTestNode) Type() string {
+	return "test/blobMeta"
+}
+
+func (x *blobMetaTestNode) New() types.Node {
+	return &blobMetaTestNode{}
+}
+
+func (x *blobMetaTestNode) MetadataOnly() bool {
+	return true
+}
+
+func (x *blobMetaTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	msg.Metadata.PutValue("metaContentSize", strconv.Itoa(len(msg.Data)))
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	_ = Registry.Register(&blobTestNode{})
+	_ = Registry.Register(&blobMetaTestNode{})
+	defer Registry.Unregister("test/blob")
+	defer Registry.Unregister("test/blobMeta")
+
+	store := blob.NewMemory()
+	ruleEngine, err := NewChainBuilder().Id("blob01").
+		Node("test/blob", nil).On(types.Success).
+		Node("test/blobMeta", nil).
+		New(WithConfig(NewConfig(types.WithMaxMessageSize(10, store))))
+	assert.Nil(t, err)
+	defer Del("blob01")
+
+	send := func(ruleEngine *RuleEngine, data string) (types.RuleMsg, error) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		var endMsg types.RuleMsg
+		var endErr error
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.TEXT, types.NewMetadata(), data), func(msg types.RuleMsg, err error) {
+			endMsg = msg
+			endErr = err
+			wg.Done()
+		})
+		waitTimeout(t, &wg, time.Second*3)
+		return endMsg, endErr
+	}
+
+	//小消息不转存
+	msg, err := send(ruleEngine, "small")
+	assert.Nil(t, err)
+	assert.Equal(t, "small!", msg.Data)
+	assert.Equal(t, "6", msg.Metadata.GetValue("metaContentSize"))
+	assert.Equal(t, 0, store.Len())
+
+	//大消息只在需要内容的节点加载
+	large := strings.Repeat("a", 20)
+	msg, err = send(ruleEngine, large)
+	assert.Nil(t, err)
+	assert.Equal(t, "", msg.Data)
+	assert.Equal(t, "20", msg.Metadata.GetValue("contentSize"))
+	assert.Equal(t, "0", msg.Metadata.GetValue("metaContentSize"))
+	assert.Equal(t, "21", msg.Metadata.GetValue(types.BlobSizeKey))
+	assert.Equal(t, 2, store.Len())
+
+	loaded, err := types.LoadMsg(store, msg)
+	assert.Nil(t, err)
+	assert.Equal(t, large+"!", loaded.Data)
+	assert.False(t, loaded.Metadata.Has(types.BlobRefKey))
+	assert.False(t, loaded.Metadata.Has(types.BlobSizeKey))
+
+	//没有配置BlobStore拒绝大消息
+	ruleEngine2, err := NewChainBuilder().Id("blob02").
+		Node("test/blob", nil).
+		New(WithConfig(NewConfig(types.WithMaxMessageSize(10, nil))))
+	assert.Nil(t, err)
+	defer Del("blob02")
+	_, err = send(ruleEngine2, large)
+	assert.Equal(t, types.ErrMessageTooLarge, err)
+	msg, err = send(ruleEngine2, "small")
+	assert.Nil(t, err)
+	assert.Equal(t, "small!", msg.Data)
+
+	//内容不存在发送到Failure链
+	var wg sync.WaitGroup
+	wg.Add(1)
+	metadata := types.NewMetadata()
+	metadata.PutValue(types.BlobRefKey, "missing")
+	ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.TEXT, metadata, ""), func(msg types.RuleMsg, err error) {
+		assert.Equal(t, blob.ErrNotFound, err)
+		wg.Done()
+	})
+	waitTimeout(t, &wg, time.Second*3)
+}
@@ -0,0 +1,272 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/json"
+)
+
+// ChainBuilder 规则链构建器，使用Go代码代替json DSL定义规则链
+// 构建结果和解析DSL得到的规则链结构完全一致，例如：
+//
+//	chain, err := rulego.NewChainBuilder().
+//		Id("rule01").
+//		Node("jsFilter", types.Configuration{"jsScript": "return msg.temperature > 50;"}).
+//		On(types.True).
+//		Node("restApiCall", types.Configuration{"restEndpointUrlPattern": "http://127.0.0.1:9099/api/alarm"}).
+//		Build()
+type ChainBuilder struct {
+	def RuleChain
+	//nodes 节点ID索引
+	nodes map[string]*RuleNode
+	//current 当前节点，后续的连接从该节点发出
+	current *RuleNode
+	//relations 待连接的关系类型
+	relations []string
+	err       error
+}
+
+// NewChainBuilder 创建规则链构建器
+func NewChainBuilder() *ChainBuilder {
+	return &ChainBuilder{
+		def:   RuleChain{RuleChain: RuleChainBaseInfo{Root: true}},
+		nodes: make(map[string]*RuleNode),
+	}
+}
+
+// Id 设置规则链ID
+func (b *ChainBuilder) Id(id string) *ChainBuilder {
+	b.def.RuleChain.ID = id
+	return b
+}
+
+// Name 设置规则链名称
+func (b *ChainBuilder) Name(name string) *ChainBuilder {
+	b.def.RuleChain.Name = name
+	return b
+}
+
+// Root 设置是否是根规则链，默认true
+func (b *ChainBuilder) Root(root bool) *ChainBuilder {
+	b.def.RuleChain.Root = root
+	return b
+}
+
+// Configuration 设置规则链配置
+func (b *ChainBuilder) Configuration(configuration types.Configuration) *ChainBuilder {
+	b.def.RuleChain.Configuration = configuration
+	return b
+}
+
+// OrderingKey 设置规则链顺序key，相同key的消息按顺序处理，例如：${deviceId}
+func (b *ChainBuilder) OrderingKey(key string) *ChainBuilder {
+	b.def.RuleChain.OrderingKey = key
+	return b
+}
+
+// Description 设置规则链描述
+func (b *ChainBuilder) Description(description string) *ChainBuilder {
+	b.def.RuleChain.Description = description
+	return b
+}
+
+// Requires 设置规则链依赖的其他规则链ID，参考 Startup
+func (b *ChainBuilder) Requires(chainIds ...string) *ChainBuilder {
+	b.def.RuleChain.Requires = append(b.def.RuleChain.Requires, chainIds...)
+	return b
+}
+
+// Template 添加规则链内的节点组模板，通过 Node(TemplateNodeType, types.Configuration{"template": id, "params": ...}) 实例化
+func (b *ChainBuilder) Template(template NodeTemplate) *ChainBuilder {
+	b.def.Metadata.Templates = append(b.def.Metadata.Templates, template)
+	return b
+}
+
+// Node 添加节点，节点ID自动生成：s1,s2...
+// 如果之前调用了On，则创建当前节点到该节点的连接
+func (b *ChainBuilder) Node(nodeType string, configuration types.Configuration) *ChainBuilder {
+	return b.NodeWithId(fmt.Sprintf("s%d", len(b.def.Metadata.Nodes)+1), nodeType, configuration)
+}
+
+// NodeWithId 添加指定ID的节点
+// 如果之前调用了On，则创建当前节点到该节点的连接
+func (b *ChainBuilder) NodeWithId(id string, nodeType string, configuration types.Configuration) *ChainBuilder {
+	if b.err != nil {
+		return b
+	}
+	if _, ok := b.nodes[id]; ok {
+		b.err = fmt.Errorf("duplicate node id=%s", id)
+		return b
+	}
+	node := &RuleNode{
+		Id:            id,
+		Type:          nodeType,
+		Configuration: configuration,
+	}
+	b.def.Metadata.Nodes = append(b.def.Metadata.Nodes, node)
+	b.nodes[id] = node
+	b.connect(id)
+	b.current = node
+	return b
+}
+
+// Named 设置当前节点名称
+func (b *ChainBuilder) Named(name string) *ChainBuilder {
+	if b.current != nil {
+		b.current.Name = name
+	}
+	return b
+}
+
+// Described 设置当前节点描述
+func (b *ChainBuilder) Described(description string) *ChainBuilder {
+	if b.current != nil {
+		b.current.Description = description
+	}
+	return b
+}
+
+// Debug 设置当前节点是否开启调试模式
+func (b *ChainBuilder) Debug(debugMode bool) *ChainBuilder {
+	if b.current != nil {
+		b.current.DebugMode = debugMode
+	}
+	return b
+}
+
+// InitMode 设置当前节点初始化方式，参考`InitModeLazy`和`InitModeAsync`
+func (b *ChainBuilder) InitMode(mode string) *ChainBuilder {
+	if b.current != nil {
+		b.current.InitMode = mode
+	}
+	return b
+}
+
+// On 指定当前节点到下一个节点的关系类型，可以指定多个，例如：Success、True
+func (b *ChainBuilder) On(relationTypes ...string) *ChainBuilder {
+	if b.err != nil {
+		return b
+	}
+	if b.current == nil {
+		b.err = errors.New("on must be called after node")
+		return b
+	}
+	b.relations = append(b.relations, relationTypes...)
+	return b
+}
+
+// To 把当前节点通过On指定的关系连接到已存在或者后续添加的节点
+// 当前节点不变，用于分支或者汇聚
+func (b *ChainBuilder) To(nodeId string) *ChainBuilder {
+	if b.err == nil {
+		b.connect(nodeId)
+	}
+	return b
+}
+
+// ToChain 把当前节点通过On指定的关系连接到子规则链
+func (b *ChainBuilder) ToChain(chainId string) *ChainBuilder {
+	if b.err != nil {
+		return b
+	}
+	if b.current == nil || len(b.relations) == 0 {
+		b.err = errors.New("toChain must be called after on")
+		return b
+	}
+	for _, relationType := range b.relations {
+		b.def.Metadata.RuleChainConnections = append(b.def.Metadata.RuleChainConnections, RuleChainConnection{
+			FromId: b.current.Id,
+			ToId:   chainId,
+			Type:   relationType,
+		})
+	}
+	b.relations = nil
+	return b
+}
+
+// From 切换当前节点为指定ID的节点，用于定义其他分支
+func (b *ChainBuilder) From(nodeId string) *ChainBuilder {
+	if b.err != nil {
+		return b
+	}
+	node, ok := b.nodes[nodeId]
+	if !ok {
+		b.err = fmt.Errorf("node id=%s not found", nodeId)
+		return b
+	}
+	b.current = node
+	b.relations = nil
+	return b
+}
+
+// connect 根据待连接的关系类型创建当前节点到目标节点的连接
+func (b *ChainBuilder) connect(toId string) {
+	if len(b.relations) == 0 || b.current == nil {
+		return
+	}
+	for _, relationType := range b.relations {
+		b.def.Metadata.Connections = append(b.def.Metadata.Connections, NodeConnection{
+			FromId: b.current.Id,
+			ToId:   toId,
+			Type:   relationType,
+		})
+	}
+	b.relations = nil
+}
+
+// Build 构建规则链定义，并检查连接的节点是否存在
+// 节点位置通过 AutoLayout 自动布局
+func (b *ChainBuilder) Build() (RuleChain, error) {
+	if b.err != nil {
+		return RuleChain{}, b.err
+	}
+	if len(b.relations) != 0 {
+		return RuleChain{}, fmt.Errorf("relation %v of node id=%s not connected", b.relations, b.current.Id)
+	}
+	if len(b.def.Metadata.Nodes) == 0 {
+		return RuleChain{}, errors.New("nodes can not empty")
+	}
+	for _, item := range b.def.Metadata.Connections {
+		if _, ok := b.nodes[item.ToId]; !ok {
+			return RuleChain{}, fmt.Errorf("connection toId=%s not found", item.ToId)
+		}
+	}
+	AutoLayout(&b.def, LayoutOptions{})
+	return b.def, nil
+}
+
+// DSL 构建规则链，并转换成json DSL
+func (b *ChainBuilder) DSL() ([]byte, error) {
+	def, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(def)
+}
+
+// New 构建规则链，并使用默认规则引擎实例池创建规则引擎
+// 规则引擎ID使用规则链ID
+func (b *ChainBuilder) New(opts ...RuleEngineOption) (*RuleEngine, error) {
+	dsl, err := b.DSL()
+	if err != nil {
+		return nil, err
+	}
+	return New(b.def.RuleChain.ID, dsl, opts...)
+}
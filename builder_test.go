@@ -0,0 +1,201 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"encoding/json"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+var builderRuleChain = `
+	{
+	  "ruleChain": {
+		"id": "builder01",
+		"name": "测试规则链",
+		"root": true
+	  },
+	  "metadata": {
+		"nodes": [
+		  {
+			"Id":"s1",
+			"type": "jsFilter",
+			"name": "过滤",
+			"debugMode": true,
+			"configuration": {
+			  "jsScript": "return msg.temperature > 50;"
+			}
+		  },
+		  {
+			"Id":"s2",
+			"type": "jsTransform",
+			"name": "转换",
+			"configuration": {
+			  "jsScript": "metadata['alarm']='true';return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+			}
+		  },
+		  {
+			"Id":"s3",
+			"type": "log",
+			"configuration": {
+			  "jsScript": "return 'alarm';"
+			}
+		  }
+		],
+		"connections": [
+		  {
+			"fromId": "s1",
+			"toId": "s2",
+			"type": "True"
+		  },
+		  {
+			"fromId": "s2",
+			"toId": "s3",
+			"type": "Success"
+		  },
+		  {
+			"fromId": "s1",
+			"toId": "s3",
+			"type": "False"
+		  }
+		]
+	  }
+	}
+`
+
+func newTestChainBuilder() *ChainBuilder {
+	return NewChainBuilder().Id("builder01").Name("测试规则链").
+		Node("jsFilter", types.Configuration{"jsScript": "return msg.temperature > 50;"}).Named("过滤").Debug(true).
+		On(types.True).
+		Node("jsTransform", types.Configuration{"jsScript": "metadata['alarm']='true';return {'msg':msg,'metadata':metadata,'msgType':msgType};"}).Named("转换").
+		On(types.Success).
+		Node("log", types.Configuration{"jsScript": "return 'alarm';"}).
+		From("s1").On(types.False).To("s3")
+}
+
+func TestChainBuilder(t *testing.T) {
+	def, err := newTestChainBuilder().Build()
+	assert.Nil(t, err)
+	expected, err := ParserRuleChain([]byte(builderRuleChain))
+	assert.Nil(t, err)
+
+	assert.Equal(t, expected.RuleChain.ID, def.RuleChain.ID)
+	assert.Equal(t, expected.RuleChain.Name, def.RuleChain.Name)
+	assert.Equal(t, len(expected.Metadata.Nodes), len(def.Metadata.Nodes))
+	for i, node := range expected.Metadata.Nodes {
+		assert.Equal(t, node.Id, def.Metadata.Nodes[i].Id)
+		assert.Equal(t, node.Type, def.Metadata.Nodes[i].Type)
+		assert.Equal(t, node.Name, def.Metadata.Nodes[i].Name)
+		assert.Equal(t, node.DebugMode, def.Metadata.Nodes[i].DebugMode)
+		assert.Equal(t, node.Configuration["jsScript"], def.Metadata.Nodes[i].Configuration["jsScript"])
+	}
+	assert.Equal(t, expected.Metadata.Connections, def.Metadata.Connections)
+
+	//运行时路由一致
+	config := NewConfig()
+	expectedCtx, err := InitRuleChainCtx(config, &expected)
+	assert.Nil(t, err)
+	ctx, err := InitRuleChainCtx(config, &def)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedCtx.nodeIds, ctx.nodeIds)
+	assert.Equal(t, expectedCtx.nodeRoutes, ctx.nodeRoutes)
+}
+
+func TestChainBuilderError(t *testing.T) {
+	_, err := NewChainBuilder().Build()
+	assert.NotNil(t, err)
+
+	_, err = NewChainBuilder().On(types.True).Node("log", nil).Build()
+	assert.NotNil(t, err)
+
+	_, err = NewChainBuilder().NodeWithId("a", "log", nil).NodeWithId("a", "log", nil).Build()
+	assert.NotNil(t, err)
+
+	_, err = NewChainBuilder().Node("log", nil).On(types.Success).Build()
+	assert.NotNil(t, err)
+
+	_, err = NewChainBuilder().Node("log", nil).From("s9").Build()
+	assert.NotNil(t, err)
+
+	_, err = NewChainBuilder().Node("log", nil).On(types.Success).To("s9").Build()
+	assert.NotNil(t, err)
+
+	_, err = NewChainBuilder().Node("notFound", nil).New()
+	assert.NotNil(t, err)
+}
+
+func TestChainBuilderNew(t *testing.T) {
+	ruleEngine, err := newTestChainBuilder().New()
+	assert.Nil(t, err)
+	defer Del("builder01")
+	_, ok := Get("builder01")
+	assert.True(t, ok)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	metaData := types.NewMetadata()
+	msg := types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, metaData, "{\"temperature\":60}")
+	ruleEngine.OnMsgWithEndFunc(msg, func(msg types.RuleMsg, err error) {
+		assert.Nil(t, err)
+		assert.Equal(t, "true", msg.Metadata.GetValue("alarm"))
+		wg.Done()
+	})
+	waitTimeout(t, &wg, time.Second*5)
+}
+
+func waitTimeout(t *testing.T, wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatal("wait timeout")
+	}
+}
+
+func TestDescriptionRoundTrip(t *testing.T) {
+	def, err := newTestChainBuilder().Description("温度告警").
+		From("s1").Described("温度过滤").Build()
+	assert.Nil(t, err)
+	def.Metadata.Connections[0].Description = "超过阈值"
+	dsl, err := json.Marshal(def)
+	assert.Nil(t, err)
+
+	ruleEngine, err := New("descriptionRoundTrip", dsl)
+	assert.Nil(t, err)
+	defer Del("descriptionRoundTrip")
+	exported, err := ParserRuleChain(ruleEngine.DSL())
+	assert.Nil(t, err)
+	assert.Equal(t, "温度告警", exported.RuleChain.Description)
+	assert.Equal(t, "温度过滤", exported.Metadata.Nodes[0].Description)
+	assert.Equal(t, "", exported.Metadata.Nodes[1].Description)
+	assert.Equal(t, "超过阈值", exported.Metadata.Connections[0].Description)
+
+	//未设置描述时不输出该字段
+	dsl, err = newTestChainBuilder().DSL()
+	assert.Nil(t, err)
+	var raw map[string]map[string]interface{}
+	assert.Nil(t, json.Unmarshal(dsl, &raw))
+	_, ok := raw["ruleChain"]["description"]
+	assert.False(t, ok)
+}
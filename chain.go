@@ -20,7 +20,9 @@ import (
 	"context"
 	"fmt"
 	"github.com/2018yuli/rulego/api/types"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 type RelationCache struct {
@@ -38,6 +40,8 @@ type RuleChainCtx struct {
 	Id types.RuleNodeId
 	//规则链定义
 	SelfDefinition *RuleChain
+	//生效的规则链基础信息，合并了继承的基础规则链
+	info RuleChainBaseInfo
 	//规则引擎配置
 	Config types.Config
 	//是否已经初始化
@@ -46,6 +50,8 @@ type RuleChainCtx struct {
 	componentsRegistry types.ComponentRegistry
 	//节点ID列表
 	nodeIds []types.RuleNodeId
+	//第一个节点在nodeIds的位置，展开模板后可能和定义中的firstNodeIndex不同
+	firstNodeIndex int
 	//组件列表
 	nodes map[types.RuleNodeId]types.NodeCtx
 	//组件路由关系
@@ -55,6 +61,10 @@ type RuleChainCtx struct {
 	relationCache map[RelationCache][]types.NodeCtx
 	//根上下文
 	rootRuleContext types.RuleContext
+	//运行统计，重新加载规则链时保留
+	stats *chainStats
+	//按顺序key串行处理消息的执行器，没有配置顺序key则为空
+	ordering *keyedExecutor
 	sync.RWMutex
 }
 
@@ -68,14 +78,29 @@ func InitRuleChainCtx(config types.Config, ruleChainDef *RuleChain) (*RuleChainC
 		relationCache:      make(map[RelationCache][]types.NodeCtx),
 		componentsRegistry: config.ComponentsRegistry,
 		initialized:        true,
+		stats:              &chainStats{},
 	}
-	if ruleChainDef.RuleChain.ID != "" {
-		ruleChainCtx.Id = types.RuleNodeId{Id: ruleChainDef.RuleChain.ID, Type: types.CHAIN}
+	if ruleChainDef.RuleChain.Extends != "" && len(config.TrustedKeys) > 0 {
+		return nil, ErrUnsignedReference
 	}
-	nodeLen := len(ruleChainDef.Metadata.Nodes)
+	//合并继承的基础规则链并展开模板实例节点，SelfDefinition保留原定义
+	resolved, err := ResolveChain(*ruleChainDef)
+	if err != nil {
+		return nil, err
+	}
+	ruleChainCtx.info = resolved.RuleChain
+	if resolved.RuleChain.OrderingKey != "" {
+		ruleChainCtx.ordering = newKeyedExecutor(config)
+	}
+	if resolved.RuleChain.ID != "" {
+		ruleChainCtx.Id = types.RuleNodeId{Id: resolved.RuleChain.ID, Type: types.CHAIN}
+	}
+	metadata := resolved.Metadata
+	ruleChainCtx.firstNodeIndex = metadata.FirstNodeIndex
+	nodeLen := len(metadata.Nodes)
 	ruleChainCtx.nodeIds = make([]types.RuleNodeId, nodeLen)
 	//加载所有节点信息
-	for index, item := range ruleChainDef.Metadata.Nodes {
+	for index, item := range metadata.Nodes {
 		if item.Id == "" {
 			item.Id = fmt.Sprintf(defaultNodeIdPrefix+"%d", index)
 		}
@@ -88,7 +113,7 @@ func InitRuleChainCtx(config types.Config, ruleChainDef *RuleChain) (*RuleChainC
 		ruleChainCtx.nodes[ruleNodeId] = ruleNodeCtx
 	}
 	//加载节点关系信息
-	for _, item := range ruleChainDef.Metadata.Connections {
+	for _, item := range metadata.Connections {
 		inNodeId := types.RuleNodeId{Id: item.FromId, Type: types.NODE}
 		outNodeId := types.RuleNodeId{Id: item.ToId, Type: types.NODE}
 		ruleNodeRelation := types.RuleNodeRelation{
@@ -106,7 +131,7 @@ func InitRuleChainCtx(config types.Config, ruleChainDef *RuleChain) (*RuleChainC
 		ruleChainCtx.nodeRoutes[inNodeId] = nodeRelations
 	}
 	//加载子规则链
-	for _, item := range ruleChainDef.Metadata.RuleChainConnections {
+	for _, item := range metadata.RuleChainConnections {
 		inNodeId := types.RuleNodeId{Id: item.FromId, Type: types.NODE}
 		outNodeId := types.RuleNodeId{Id: item.ToId, Type: types.CHAIN}
 		ruleChainRelation := types.RuleNodeRelation{
@@ -153,8 +178,7 @@ func (rc *RuleChainCtx) GetNodeByIndex(index int) (types.NodeCtx, bool) {
 
 // GetFirstNode 获取第一个节点，消息从该节点开始流转。默认是index=0的节点
 func (rc *RuleChainCtx) GetFirstNode() (types.NodeCtx, bool) {
-	var firstNodeIndex = rc.SelfDefinition.Metadata.FirstNodeIndex
-	return rc.GetNodeByIndex(firstNodeIndex)
+	return rc.GetNodeByIndex(rc.firstNodeIndex)
 }
 
 func (rc *RuleChainCtx) GetNodeRoutes(id types.RuleNodeId) ([]types.RuleNodeRelation, bool) {
@@ -242,6 +266,10 @@ func (rc *RuleChainCtx) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
 	rootCtx := rc.rootRuleContext.(*DefaultRuleContext)
 	rootCtxCopy := NewRuleContext(rootCtx.config, rootCtx.ruleChainCtx, rootCtx.from, rootCtx.self, rootCtx.pool, ctx.GetEndFunc(), ctx.GetContext())
 	rootCtxCopy.isFirst = rootCtx.isFirst
+	if parent, ok := ctx.(*DefaultRuleContext); ok {
+		//子规则链的分支属于同一条消息
+		rootCtxCopy.tracker = parent.tracker
+	}
 
 	rootCtxCopy.TellNext(msg)
 	return nil
@@ -257,7 +285,7 @@ func (rc *RuleChainCtx) Destroy() {
 }
 
 func (rc *RuleChainCtx) IsDebugMode() bool {
-	return rc.SelfDefinition.RuleChain.DebugMode
+	return rc.info.DebugMode
 }
 
 func (rc *RuleChainCtx) GetNodeId() types.RuleNodeId {
@@ -290,8 +318,147 @@ func (rc *RuleChainCtx) ReloadChild(ruleNodeId types.RuleNodeId, def []byte) err
 	return nil
 }
 
+// chainStats 规则链运行统计
+type chainStats struct {
+	//panics 节点panic次数
+	panics uint64
+	//asyncTimeouts 节点异步完成或者流式输出超时次数
+	asyncTimeouts uint64
+	//streamChunks 节点流式输出的消息数
+	streamChunks uint64
+	//asyncPending 节点异步处理中的消息数
+	asyncPending int64
+	//streamsActive 节点正在流式输出的流数量
+	streamsActive int64
+}
+
+// ChainStats 规则链运行统计
+type ChainStats struct {
+	//Panics 节点处理消息发生panic的次数
+	Panics uint64 `json:"panics"`
+	//AsyncPending 节点通过`RuleContext.Async`异步处理中的消息数
+	AsyncPending int64 `json:"asyncPending"`
+	//AsyncTimeouts 节点异步完成或者流式输出超时的次数，持续增长说明节点没有完成消息
+	AsyncTimeouts uint64 `json:"asyncTimeouts"`
+	//StreamsActive 节点通过`RuleContext.Stream`正在流式输出的流数量
+	StreamsActive int64 `json:"streamsActive"`
+	//StreamChunks 节点流式输出的消息数
+	StreamChunks uint64 `json:"streamChunks"`
+}
+
+// getStats 获取运行统计，可能为空
+func (rc *RuleChainCtx) getStats() *chainStats {
+	rc.RLock()
+	defer rc.RUnlock()
+	return rc.stats
+}
+
+// addPanic 增加节点panic次数
+func (rc *RuleChainCtx) addPanic() {
+	if stats := rc.getStats(); stats != nil {
+		atomic.AddUint64(&stats.panics, 1)
+	}
+}
+
+// Stats 获取规则链和子规则链的运行统计
+func (rc *RuleChainCtx) Stats() ChainStats {
+	rc.RLock()
+	defer rc.RUnlock()
+	var result ChainStats
+	if rc.stats != nil {
+		result.Panics = atomic.LoadUint64(&rc.stats.panics)
+		result.AsyncPending = atomic.LoadInt64(&rc.stats.asyncPending)
+		result.AsyncTimeouts = atomic.LoadUint64(&rc.stats.asyncTimeouts)
+		result.StreamsActive = atomic.LoadInt64(&rc.stats.streamsActive)
+		result.StreamChunks = atomic.LoadUint64(&rc.stats.streamChunks)
+	}
+	for _, node := range rc.nodes {
+		if subChain, ok := node.(*RuleChainCtx); ok {
+			sub := subChain.Stats()
+			result.Panics += sub.Panics
+			result.AsyncPending += sub.AsyncPending
+			result.AsyncTimeouts += sub.AsyncTimeouts
+			result.StreamsActive += sub.StreamsActive
+			result.StreamChunks += sub.StreamChunks
+		}
+	}
+	return result
+}
+
+// PanicCount 获取规则链和子规则链节点处理消息发生panic的次数
+func (rc *RuleChainCtx) PanicCount() uint64 {
+	return rc.Stats().Panics
+}
+
+// NodeHealth 节点健康状态
+type NodeHealth struct {
+	//ChainId 节点所在规则链ID
+	ChainId string `json:"chainId"`
+	//NodeId 节点ID
+	NodeId string `json:"nodeId"`
+	//Type 节点类型
+	Type string `json:"type"`
+	//Healthy 是否可用
+	Healthy bool `json:"healthy"`
+	//Error 不可用原因
+	Error string `json:"error,omitempty"`
+}
+
+// Health 检查规则链和子规则链中实现了`types.HealthChecker`的节点，按节点ID排序
+func (rc *RuleChainCtx) Health() []NodeHealth {
+	rc.RLock()
+	nodes := make([]types.NodeCtx, 0, len(rc.nodes))
+	for _, node := range rc.nodes {
+		nodes = append(nodes, node)
+	}
+	rc.RUnlock()
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].GetNodeId().Id < nodes[j].GetNodeId().Id
+	})
+	var result []NodeHealth
+	for _, node := range nodes {
+		if subChain, ok := node.(*RuleChainCtx); ok {
+			result = append(result, subChain.Health()...)
+			continue
+		}
+		nodeCtx, ok := node.(*RuleNodeCtx)
+		if !ok {
+			continue
+		}
+		checker, ok := nodeCtx.Node.(types.HealthChecker)
+		if !ok {
+			continue
+		}
+		item := NodeHealth{ChainId: rc.Id.Id, NodeId: nodeCtx.GetNodeId().Id, Type: nodeCtx.Type(), Healthy: true}
+		if err := checker.HealthCheck(); err != nil {
+			item.Healthy = false
+			item.Error = err.Error()
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// HealthCheck 检查规则链所有节点和子规则链的健康状态，返回第一个不可用节点的错误
+func (rc *RuleChainCtx) HealthCheck() error {
+	for _, item := range rc.Health() {
+		if !item.Healthy {
+			return fmt.Errorf("node %s: %s", item.NodeId, item.Error)
+		}
+	}
+	return nil
+}
+
+// DSL 导出规则链定义，配置了SecretsProvider则加密节点敏感字段
 func (rc *RuleChainCtx) DSL() []byte {
-	v, _ := rc.Config.Parser.EncodeRuleChain(rc.SelfDefinition)
+	def, err := encryptRuleChain(rc.Config, rc.SelfDefinition)
+	if err != nil {
+		if rc.Config.Logger != nil {
+			rc.Config.Logger.Printf("encrypt rule chain error: %s", err)
+		}
+		return nil
+	}
+	v, _ := rc.Config.Parser.EncodeRuleChain(def)
 	return v
 }
 
@@ -304,10 +471,19 @@ func (rc *RuleChainCtx) Copy(newCtx *RuleChainCtx) {
 	rc.initialized = newCtx.initialized
 	rc.componentsRegistry = newCtx.componentsRegistry
 	rc.SelfDefinition = newCtx.SelfDefinition
+	rc.info = newCtx.info
 	rc.nodeIds = newCtx.nodeIds
+	rc.firstNodeIndex = newCtx.firstNodeIndex
 	rc.nodes = newCtx.nodes
 	rc.nodeRoutes = newCtx.nodeRoutes
 	rc.rootRuleContext = newCtx.rootRuleContext
+	rc.ordering = newCtx.ordering
+	//新规则链的根上下文仍然指向newCtx，共享运行统计
+	if rc.stats == nil {
+		rc.stats = newCtx.stats
+	} else {
+		newCtx.stats = rc.stats
+	}
 	//清除缓存
 	rc.relationCache = make(map[RelationCache][]types.NodeCtx)
 }
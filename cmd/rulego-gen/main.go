@@ -0,0 +1,69 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// rulego-gen 把规则链DSL生成静态Go代码
+//
+//	go run github.com/2018yuli/rulego/cmd/rulego-gen -rule_file chain.json -out chain_gen.go -package main
+//
+// 也可以使用go:generate：
+//
+//	//go:generate go run github.com/2018yuli/rulego/cmd/rulego-gen -rule_file chain.json -out chain_gen.go
+package main
+
+import (
+	"flag"
+	"github.com/2018yuli/rulego/codegen"
+	"log"
+	"os"
+)
+
+var (
+	ruleFile string
+	outFile  string
+	pkg      string
+	typeName string
+)
+
+func init() {
+	flag.StringVar(&ruleFile, "rule_file", "", "Location of the rule_file.")
+	flag.StringVar(&outFile, "out", "", "Location of the generated go file, default stdout.")
+	flag.StringVar(&pkg, "package", codegen.DefaultPackage, "Package name of the generated go file.")
+	flag.StringVar(&typeName, "type", codegen.DefaultTypeName, "Type name of the generated rule chain.")
+}
+
+func main() {
+	flag.Parse()
+	if ruleFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dsl, err := os.ReadFile(ruleFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := codegen.GenerateFromDSL(dsl, codegen.Options{Package: pkg, TypeName: typeName})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if outFile == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = os.WriteFile(outFile, src, 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/2018yuli/rulego"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadChain 加载规则链DSL文件
+func loadChain(file string) (rulego.RuleChain, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return rulego.RuleChain{}, err
+	}
+	def, err := rulego.ParserRuleChain(b)
+	if err != nil {
+		return def, fmt.Errorf("%s: %w", file, err)
+	}
+	return def, nil
+}
+
+// nodeTypes 节点ID->节点类型
+func nodeTypes(def rulego.RuleChain) map[string]string {
+	m := make(map[string]string)
+	for _, node := range def.Metadata.Nodes {
+		m[node.Id] = node.Type
+	}
+	return m
+}
+
+// expandFiles 展开参数中的目录为目录下指定后缀的文件
+func expandFiles(args []string, exts ...string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && hasExt(path, exts) {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func hasExt(path string, exts []string) bool {
+	for _, ext := range exts {
+		if strings.EqualFold(filepath.Ext(path), ext) {
+			return true
+		}
+	}
+	return false
+}
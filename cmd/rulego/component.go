@@ -0,0 +1,75 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/2018yuli/rulego/componentkit"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+func componentCmd(args []string) int {
+	fs := flag.NewFlagSet("component", flag.ExitOnError)
+	nodeType := fs.String("type", "", "Component type, e.g. lala/upper.")
+	pkg := fs.String("package", "", "Package name, defaults to the last segment of the type.")
+	name := fs.String("name", "", "Node struct name, defaults to the last segment of the type plus Node.")
+	description := fs.String("description", "", "Component description.")
+	dir := fs.String("dir", "", "Output directory, defaults to ./<package>.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rulego component -type <type> [-package name] [-name Name] [-dir dir]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *nodeType == "" {
+		fs.Usage()
+		return 2
+	}
+	opts := componentkit.ScaffoldOptions{Type: *nodeType, Package: *pkg, Name: *name, Description: *description}
+	scaffolding, err := componentkit.Scaffold(opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *dir == "" {
+		*dir = scaffolding.Package
+	}
+	names := make([]string, 0, len(scaffolding.Files))
+	for fileName := range scaffolding.Files {
+		names = append(names, fileName)
+		if _, err := os.Stat(filepath.Join(*dir, fileName)); err == nil {
+			fmt.Fprintf(os.Stderr, "%s already exists\n", filepath.Join(*dir, fileName))
+			return 1
+		}
+	}
+	sort.Strings(names)
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, fileName := range names {
+		file := filepath.Join(*dir, fileName)
+		if err := os.WriteFile(file, scaffolding.Files[fileName], 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("created", file)
+	}
+	return 0
+}
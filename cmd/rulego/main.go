@@ -0,0 +1,79 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// rulego 规则引擎命令行工具
+//
+//	rulego run -dir ./rules -endpoints ./endpoints.json 运行目录下的规则链和接入端点
+//	rulego run -dir ./rules -admin :9091 -pprof        同时开启健康检查和性能分析管理接口
+//	rulego run -dir ./rules -admin :9091 -admin-api-key xx 管理接口开启API key认证，也可以使用-admin-jwt-secret开启JWT认证
+//	rulego validate ./rules                           检查规则链DSL文件
+//	rulego test ./testdata/fixture.json               使用消息用例测试规则链
+//	rulego trace -file chain.json -data '{"a":1}'     跟踪一条消息经过的节点
+//	rulego sign -key ./private.key -key-id pub01 chain.json 签名规则链DSL文件，-keygen生成密钥对
+//	rulego run -dir ./rules -trusted-keys ./keys.json  只运行信任的密钥签名的规则链
+//	rulego serve -config ./rulego.json                按配置文件运行规则链、接入端点和管理接口，参考ServerConfig
+//	rulego service install -config ./rulego.json      安装为systemd或者Windows服务，支持uninstall、start、stop、status
+//	rulego component -type lala/upper -dir ./upper    生成新组件包脚手架，包含组件实现和一致性测试
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command 子命令
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{name: "run", usage: "run a directory of rule chains and endpoints", run: runCmd},
+	{name: "serve", usage: "run the chains, endpoints and management API declared in a config file", run: serveCmd},
+	{name: "service", usage: "install, start, stop or uninstall rulego as a system service", run: serviceCmd},
+	{name: "validate", usage: "validate rule chain DSL files", run: validateCmd},
+	{name: "test", usage: "test rule chains against message fixtures", run: testCmd},
+	{name: "trace", usage: "trace a single message and print the node path", run: traceCmd},
+	{name: "sign", usage: "sign rule chain DSL files with an Ed25519 key", run: signCmd},
+	{name: "component", usage: "scaffold a new component package with conformance tests", run: componentCmd},
+}
+
+func main() {
+	os.Exit(execute(os.Args[1:]))
+}
+
+func execute(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+	usage()
+	return 2
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: rulego <command> [arguments]\n\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nUse \"rulego <command> -h\" for more information about a command.")
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/test/spec"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	assert.Equal(t, 0, len(validateFile("testdata/chain.json", true)))
+
+	problems := validateFile("testdata/invalid_chain.json", false)
+	assert.Equal(t, 3, len(problems))
+	assert.True(t, strings.Contains(problems[0], "componentType=notFound"))
+	assert.True(t, strings.Contains(problems[1], "duplicate node id=s1"))
+	assert.True(t, strings.Contains(problems[2], "toId=s3"))
+
+	assert.Equal(t, 1, len(validateFile("testdata/notFound.json", false)))
+	assert.Equal(t, 1, execute([]string{"validate", "testdata/chain.json", "testdata/invalid_chain.json"}))
+	assert.Equal(t, 2, execute([]string{"notFound"}))
+}
+
+func TestTestCmd(t *testing.T) {
+	assert.Equal(t, 0, execute([]string{"test", "-v", "testdata/fixture.json"}))
+	assert.Equal(t, 1, execute([]string{"test", "testdata/chain.json"}))
+}
+
+func TestTrace(t *testing.T) {
+	def, err := loadChain("testdata/chain.json")
+	assert.Nil(t, err)
+	trace, err := spec.Run(def, nil, spec.Msg{Data: []byte(`{"temperature":60}`)}.NewMsg(), time.Second*5)
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	printTrace(&buf, nodeTypes(def), trace)
+	out := buf.String()
+	assert.True(t, strings.Contains(out, "-> s1(jsFilter)"))
+	assert.True(t, strings.Contains(out, "relation=True"))
+	assert.True(t, strings.Contains(out, "-> s2(jsTransform)"))
+	assert.True(t, strings.Contains(out, "== end type=ALARM"))
+	assert.Equal(t, 0, execute([]string{"trace", "-file", "testdata/chain.json", "-data", `{"temperature":60}`}))
+}
+
+func TestNewEndpoints(t *testing.T) {
+	endpoints, err := newEndpoints("testdata/endpoints.json", &rulego.RuleGo{}, rulego.NewConfig())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(endpoints))
+	assert.Equal(t, "http", endpoints[0].Type())
+
+	_, err = newEndpoint(EndpointDef{Type: "notFound"}, &rulego.RuleGo{}, rulego.NewConfig())
+	assert.NotNil(t, err)
+}
+
+func TestSignCmd(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	dir := t.TempDir()
+	out := filepath.Join(dir, "chain.json")
+	assert.Equal(t, 0, execute([]string{"sign", "-key", base64.StdEncoding.EncodeToString(privateKey), "-key-id", "pub01", "-o", out, "testdata/chain.json"}))
+	assert.Equal(t, 2, execute([]string{"sign", "testdata/chain.json"}))
+
+	keysFile := filepath.Join(dir, "keys.json")
+	assert.Nil(t, os.WriteFile(keysFile, []byte(`{"pub01":"`+base64.StdEncoding.EncodeToString(publicKey)+`"}`), 0644))
+	opts, err := loadTrustedKeys(keysFile)
+	assert.Nil(t, err)
+	config := rulego.NewConfig(opts...)
+	signed, _ := os.ReadFile(out)
+	ruleGo := &rulego.RuleGo{}
+	defer ruleGo.Stop()
+	_, err = ruleGo.New("signed", signed, rulego.WithConfig(config))
+	assert.Nil(t, err)
+	unsigned, _ := os.ReadFile("testdata/chain.json")
+	_, err = ruleGo.New("unsigned", unsigned, rulego.WithConfig(config))
+	assert.NotNil(t, err)
+}
+
+func TestComponentCmd(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "upper")
+	assert.Equal(t, 0, execute([]string{"component", "-type", "lala/upper", "-dir", dir}))
+	src, err := os.ReadFile(filepath.Join(dir, "upper_node.go"))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(src), "package upper\n"))
+	_, err = os.Stat(filepath.Join(dir, "upper_node_test.go"))
+	assert.Nil(t, err)
+	//不覆盖已经存在的文件
+	assert.Equal(t, 1, execute([]string{"component", "-type", "lala/upper", "-dir", dir}))
+	assert.Equal(t, 2, execute([]string{"component"}))
+	assert.Equal(t, 1, execute([]string{"component", "-type", "a b"}))
+}
+
+func TestServe(t *testing.T) {
+	dir := t.TempDir()
+	chain, _ := os.ReadFile("testdata/chain.json")
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "rules"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "rules", "chain.json"), chain, 0644))
+	configFile := filepath.Join(dir, "rulego.yaml")
+	assert.Nil(t, os.WriteFile(configFile, []byte(`chains: ./rules
+endpoints:
+  - type: http
+    configuration:
+      server: 127.0.0.1:0
+    routers:
+      - from: /api/v1/msg/:msgType
+        to: chain:alarm
+        msgType: ${msgType}
+        params: [POST]
+pidFile: run/rulego.pid
+`), 0644))
+	//相对路径相对配置文件所在目录
+	config, err := loadServerConfig(configFile)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "rules"), config.Chains)
+	assert.Equal(t, filepath.Join(dir, "run", "rulego.pid"), config.PidFile)
+	assert.Equal(t, 1, len(config.Endpoints))
+	assert.Equal(t, "${msgType}", config.Endpoints[0].Routers[0].MsgType)
+
+	stop := make(chan struct{})
+	done := make(chan int, 1)
+	go func() {
+		done <- runServer(config, stop)
+	}()
+	var pid []byte
+	for i := 0; i < 100 && len(pid) == 0; i++ {
+		time.Sleep(time.Millisecond * 20)
+		pid, _ = os.ReadFile(config.PidFile)
+	}
+	assert.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(pid))
+	close(stop)
+	assert.Equal(t, 0, <-done)
+	_, err = os.Stat(config.PidFile)
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = loadServerConfig("testdata/endpoints.json")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, execute([]string{"serve", "-config", filepath.Join(dir, "notFound.json")}))
+}
+
+func TestServeAdminAuth(t *testing.T) {
+	dir := t.TempDir()
+	chain, _ := os.ReadFile("testdata/chain.json")
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "chain.json"), chain, 0644))
+	//没有配置认证，不能监听所有地址
+	_, err := newServer(ServerConfig{Chains: dir, Admin: AdminDef{Addr: ":9091"}})
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "not loopback"))
+	for addr, ok := range map[string]bool{
+		"127.0.0.1:9091": true, "localhost:9091": true, "[::1]:9091": true,
+		"0.0.0.0:9091": false, "192.168.1.10:9091": false, "[::]:9091": false, "9091": false,
+	} {
+		assert.Equal(t, ok, checkAdminAuth(AdminDef{Addr: addr}) == nil)
+	}
+	//配置了认证或者显式允许不认证
+	assert.Nil(t, checkAdminAuth(AdminDef{Addr: ":9091", APIKey: "key"}))
+	assert.Nil(t, checkAdminAuth(AdminDef{Addr: ":9091", JWTSecret: "secret"}))
+	assert.Nil(t, checkAdminAuth(AdminDef{Addr: ":9091", Insecure: true}))
+
+	s, err := newServer(ServerConfig{Chains: dir, Admin: AdminDef{Addr: "127.0.0.1:0"}})
+	assert.Nil(t, err)
+	assert.NotNil(t, s.admin)
+	s.stop()
+}
+
+func TestService(t *testing.T) {
+	unit := systemdUnit("rulego", "/usr/local/bin/rulego", []string{"serve", "-config", "/etc/rulego/rulego.json"})
+	assert.True(t, strings.Contains(unit, `ExecStart="/usr/local/bin/rulego" "serve" "-config" "/etc/rulego/rulego.json"`))
+	assert.True(t, strings.Contains(unit, "Restart=on-failure"))
+	assert.Equal(t, 2, execute([]string{"service"}))
+	assert.Equal(t, 2, execute([]string{"service", "-name", "rulego"}))
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/endpoint/mqtt"
+	"github.com/2018yuli/rulego/endpoint/pubsub"
+	"github.com/2018yuli/rulego/endpoint/pulsar"
+	"github.com/2018yuli/rulego/endpoint/rest"
+	"github.com/2018yuli/rulego/endpoint/sqs"
+	"github.com/2018yuli/rulego/endpoint/zmq"
+	"github.com/2018yuli/rulego/utils/json"
+	"github.com/2018yuli/rulego/utils/str"
+	"os"
+)
+
+// endpointComponents 支持的接入端点
+var endpointComponents = []endpoint.Endpoint{
+	&rest.Rest{}, &mqtt.Mqtt{}, &pubsub.PubSub{}, &pulsar.Pulsar{}, &sqs.Sqs{}, &zmq.Zmq{},
+}
+
+// EndpointsDef 接入端点配置文件
+//
+//	{
+//	  "endpoints": [
+//	    {
+//	      "type": "http",
+//	      "configuration": {"server": ":9090"},
+//	      "routers": [
+//	        {"from": "/api/v1/msg/:msgType", "to": "chain:default", "msgType": "${msgType}", "params": ["POST"]}
+//	      ]
+//	    }
+//	  ]
+//	}
+type EndpointsDef struct {
+	Endpoints []EndpointDef `json:"endpoints"`
+}
+
+// EndpointDef 接入端点配置
+type EndpointDef struct {
+	//Type 端点类型：http、mqtt、pubsub、pulsar、sqs、zmq
+	Type string `json:"type"`
+	//Configuration 端点配置
+	Configuration types.Configuration `json:"configuration"`
+	//Routers 路由列表
+	Routers []RouterDef `json:"routers"`
+}
+
+// RouterDef 路由配置
+type RouterDef struct {
+	//From 输入，例如http路径或者mqtt主题
+	From string `json:"from"`
+	//To 输出，例如：chain:default
+	To string `json:"to"`
+	//MsgType 消息类型，可以使用 ${metaKeyName} 替换元数据中的变量
+	MsgType string `json:"msgType"`
+	//Params 路由参数，例如http方法
+	Params []string `json:"params"`
+}
+
+func runCmd(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	dir := fs.String("dir", "./rules", "Directory of the rule chain files.")
+	endpointsFile := fs.String("endpoints", "", "Location of the endpoints file.")
+	adminAddr := fs.String("admin", "", "Address of the management listener serving /healthz and /readyz, e.g. :9091.")
+	enablePprof := fs.Bool("pprof", false, "Expose /debug/pprof/ under the management listener.")
+	adminAPIKey := fs.String("admin-api-key", os.Getenv("RULEGO_ADMIN_API_KEY"), "API key with the admin role for the management listener, defaults to $RULEGO_ADMIN_API_KEY.")
+	adminJWTSecret := fs.String("admin-jwt-secret", os.Getenv("RULEGO_ADMIN_JWT_SECRET"), "HS256 secret verifying management listener JWTs, defaults to $RULEGO_ADMIN_JWT_SECRET.")
+	trustedKeys := fs.String("trusted-keys", "", "JSON file of trusted signing keys {\"keyId\":\"base64 public key\"}, only chains signed by these keys are loaded.")
+	auditLog := fs.String("audit-log", "", "File recording rule chain configuration changes, also queryable via /audit on the management listener.")
+	pidFile := fs.String("pid-file", "", "File the process id is written to while running.")
+	_ = fs.Parse(args)
+
+	return runServer(ServerConfig{
+		Chains:        *dir,
+		EndpointsFile: *endpointsFile,
+		Admin: AdminDef{
+			Addr:      *adminAddr,
+			Pprof:     *enablePprof,
+			APIKey:    *adminAPIKey,
+			JWTSecret: *adminJWTSecret,
+		},
+		TrustedKeys: *trustedKeys,
+		AuditLog:    *auditLog,
+		PidFile:     *pidFile,
+	}, nil)
+}
+
+// newEndpoints 通过配置文件创建接入端点并添加路由
+func newEndpoints(file string, ruleGo *rulego.RuleGo, config types.Config) ([]endpoint.Endpoint, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var def EndpointsDef
+	if err := json.Unmarshal(b, &def); err != nil {
+		return nil, err
+	}
+	var endpoints []endpoint.Endpoint
+	for _, item := range def.Endpoints {
+		ep, err := newEndpoint(item, ruleGo, config)
+		if err != nil {
+			for _, created := range endpoints {
+				created.Destroy()
+			}
+			return nil, err
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+func newEndpoint(def EndpointDef, ruleGo *rulego.RuleGo, config types.Config) (ep endpoint.Endpoint, err error) {
+	for _, item := range endpointComponents {
+		if item.Type() == def.Type {
+			ep = item.New().(endpoint.Endpoint)
+		}
+	}
+	if ep == nil {
+		return nil, fmt.Errorf("endpoint not found.endpointType=%s", def.Type)
+	}
+	if def.Configuration == nil {
+		def.Configuration = make(types.Configuration)
+	}
+	if err = ep.Init(config, def.Configuration); err != nil {
+		return nil, err
+	}
+	//路由To配置错误会panic
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("endpoint %s: %v", def.Type, e)
+		}
+	}()
+	for _, item := range def.Routers {
+		from := endpoint.NewRouter(endpoint.WithRuleGo(ruleGo), endpoint.WithRuleConfig(config)).From(item.From)
+		if item.MsgType != "" {
+			msgType := item.MsgType
+			from.Transform(func(router *endpoint.Router, exchange *endpoint.Exchange) bool {
+				msg := exchange.In.GetMsg()
+				msg.Type = str.SprintfDict(msgType, msg.Metadata.Values())
+				return true
+			})
+		}
+		router := from.To(item.To).End()
+		params := make([]interface{}, 0, len(item.Params))
+		for _, param := range item.Params {
+			params = append(params, param)
+		}
+		if err = ep.AddRouterWithParams(router, params...); err != nil {
+			return nil, err
+		}
+	}
+	return ep, nil
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+)
+
+func serveCmd(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	configFile := fs.String("config", "rulego.json", "Location of the server config file, .json, .yaml or .yml.")
+	name := fs.String("name", defaultServiceName, "Service name reported to the Windows service control manager.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rulego serve [-config rulego.json] [-name rulego]")
+		fmt.Fprintln(fs.Output(), "Runs the rule chains, endpoints and management listener declared in the config file until SIGINT or SIGTERM.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	config, err := loadServerConfig(*configFile)
+	if err != nil {
+		log.Println("load server config error:", err)
+		return 1
+	}
+	run := func(stop <-chan struct{}) int {
+		return runServer(config, stop)
+	}
+	//由Windows服务控制管理器启动
+	if code, ok := runAsService(*name, run); ok {
+		return code
+	}
+	return run(nil)
+}
@@ -0,0 +1,281 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/endpoint/admin"
+	"github.com/2018yuli/rulego/utils/audit"
+	"github.com/2018yuli/rulego/utils/json"
+	"gopkg.in/yaml.v3"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ServerConfig 服务模式配置文件，不需要编写main函数即可部署规则链、接入端点和管理接口
+// .yaml和.yml后缀使用YAML格式解析，其他使用JSON格式解析，相对路径相对配置文件所在目录
+//
+//	{
+//	  "chains": "./rules",
+//	  "endpoints": [
+//	    {
+//	      "type": "http",
+//	      "configuration": {"server": ":9090"},
+//	      "routers": [{"from": "/api/v1/msg/:msgType", "to": "chain:default", "params": ["POST"]}]
+//	    }
+//	  ],
+//	  "admin": {"addr": ":9091", "apiKey": "xx"},
+//	  "pidFile": "/var/run/rulego.pid"
+//	}
+type ServerConfig struct {
+	//Chains 规则链文件所在目录
+	Chains string `json:"chains"`
+	//Endpoints 接入端点
+	Endpoints []EndpointDef `json:"endpoints"`
+	//EndpointsFile 接入端点配置文件，和Endpoints一起创建
+	EndpointsFile string `json:"endpointsFile"`
+	//Admin 管理接口
+	Admin AdminDef `json:"admin"`
+	//TrustedKeys 信任的签名密钥文件，只加载这些密钥签名的规则链
+	TrustedKeys string `json:"trustedKeys"`
+	//AuditLog 规则链配置变更审计文件
+	AuditLog string `json:"auditLog"`
+	//PidFile 运行期间写入进程ID的文件，退出时删除
+	PidFile string `json:"pidFile"`
+}
+
+// AdminDef 管理接口配置
+type AdminDef struct {
+	//Addr 监听地址，例如：:9091，为空不开启管理接口
+	Addr string `json:"addr"`
+	//Pprof 是否开启/debug/pprof/
+	Pprof bool `json:"pprof"`
+	//APIKey admin角色的API key
+	APIKey string `json:"apiKey"`
+	//JWTSecret 校验JWT的HS256密钥
+	JWTSecret string `json:"jwtSecret"`
+	//Insecure 允许在非回环地址不认证开启管理接口，能访问端口的任何人都可以修改配置和重放消息
+	//默认false：没有配置APIKey和JWTSecret时只能监听回环地址，例如：127.0.0.1:9091
+	Insecure bool `json:"insecure"`
+}
+
+// checkAdminAuth 管理接口没有配置认证时只允许监听回环地址
+func checkAdminAuth(def AdminDef) error {
+	if def.Addr == "" || def.APIKey != "" || def.JWTSecret != "" || def.Insecure {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(def.Addr)
+	if err != nil {
+		return fmt.Errorf("admin addr %s: %w", def.Addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("admin addr %s is not loopback, set apiKey or jwtSecret, or insecure: true to allow unauthenticated access", def.Addr)
+}
+
+// loadServerConfig 加载服务模式配置文件
+func loadServerConfig(file string) (ServerConfig, error) {
+	var config ServerConfig
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return config, err
+	}
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == ".yaml" || ext == ".yml" {
+		var v interface{}
+		if err = yaml.Unmarshal(b, &v); err != nil {
+			return config, err
+		}
+		if b, err = json.Marshal(v); err != nil {
+			return config, err
+		}
+	}
+	if err = json.Unmarshal(b, &config); err != nil {
+		return config, err
+	}
+	//服务的工作目录不确定，相对路径改为相对配置文件所在目录
+	dir := filepath.Dir(file)
+	for _, path := range []*string{&config.Chains, &config.EndpointsFile, &config.TrustedKeys, &config.AuditLog, &config.PidFile} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+	if config.Chains == "" {
+		return config, fmt.Errorf("%s: chains can not empty", file)
+	}
+	return config, nil
+}
+
+// server 运行中的规则链、接入端点和管理接口
+type server struct {
+	ruleGo    *rulego.RuleGo
+	endpoints []endpoint.Endpoint
+	admin     *admin.Server
+	errCh     chan error
+}
+
+// newServer 加载规则链并创建接入端点和管理接口，不启动监听
+func newServer(config ServerConfig) (*server, error) {
+	if err := checkAdminAuth(config.Admin); err != nil {
+		return nil, err
+	}
+	runtime := types.NewRuntimeConfig()
+	configOpts := []types.Option{types.WithDefaultPool(), types.WithRuntimeConfig(runtime)}
+	var auditStore audit.Store
+	if config.AuditLog != "" {
+		store, err := audit.NewFile(config.AuditLog)
+		if err != nil {
+			return nil, fmt.Errorf("open audit log error: %w", err)
+		}
+		auditStore = store
+		configOpts = append(configOpts, types.WithAuditSink(store))
+	}
+	if config.TrustedKeys != "" {
+		opts, err := loadTrustedKeys(config.TrustedKeys)
+		if err != nil {
+			return nil, fmt.Errorf("load trusted keys error: %w", err)
+		}
+		configOpts = append(configOpts, opts...)
+	}
+	ruleConfig := rulego.NewConfig(configOpts...)
+	s := &server{ruleGo: &rulego.RuleGo{}}
+	if err := s.ruleGo.Load(config.Chains, rulego.WithConfig(ruleConfig)); err != nil {
+		s.stop()
+		return nil, fmt.Errorf("load rule chains error: %w", err)
+	}
+	if config.EndpointsFile != "" {
+		endpoints, err := newEndpoints(config.EndpointsFile, s.ruleGo, ruleConfig)
+		if err != nil {
+			s.stop()
+			return nil, fmt.Errorf("load endpoints error: %w", err)
+		}
+		s.endpoints = endpoints
+	}
+	for _, item := range config.Endpoints {
+		ep, err := newEndpoint(item, s.ruleGo, ruleConfig)
+		if err != nil {
+			s.stop()
+			return nil, fmt.Errorf("load endpoints error: %w", err)
+		}
+		s.endpoints = append(s.endpoints, ep)
+	}
+	if config.Admin.Addr != "" {
+		opts := []admin.Option{admin.WithRuleGo(s.ruleGo), admin.WithEndpoints(s.endpoints...), admin.WithRuntimeConfig(runtime)}
+		if config.Admin.Pprof {
+			opts = append(opts, admin.WithPprof())
+		}
+		if auditStore != nil {
+			opts = append(opts, admin.WithAudit(auditStore))
+		}
+		if config.Admin.APIKey != "" {
+			opts = append(opts, admin.WithAuth(admin.NewAPIKeyAuthenticator(map[string]admin.Principal{
+				config.Admin.APIKey: {Name: "admin", Role: admin.RoleAdmin},
+			})))
+		}
+		if config.Admin.JWTSecret != "" {
+			opts = append(opts, admin.WithAuth(admin.NewJWTAuthenticator([]byte(config.Admin.JWTSecret), nil)))
+		}
+		s.admin = admin.New(config.Admin.Addr, opts...)
+	}
+	s.errCh = make(chan error, len(s.endpoints)+1)
+	return s, nil
+}
+
+// start 启动管理接口和接入端点，启动失败的错误发送到errCh
+func (s *server) start() {
+	if s.admin != nil {
+		go func() {
+			if err := s.admin.Start(); err != nil {
+				s.errCh <- fmt.Errorf("admin: %w", err)
+			}
+		}()
+	}
+	for _, ep := range s.endpoints {
+		item := ep
+		go func() {
+			if err := item.Start(); err != nil {
+				s.errCh <- fmt.Errorf("endpoint %s %s: %w", item.Type(), item.Id(), err)
+			}
+		}()
+	}
+}
+
+// stop 停止接入端点和管理接口，然后释放规则链
+func (s *server) stop() {
+	for _, ep := range s.endpoints {
+		ep.Destroy()
+	}
+	if s.admin != nil {
+		_ = s.admin.Stop()
+	}
+	s.ruleGo.Stop()
+}
+
+// runServer 运行直到收到SIGINT、SIGTERM信号、stop关闭或者接入端点启动失败，返回进程退出码
+func runServer(config ServerConfig, stop <-chan struct{}) int {
+	s, err := newServer(config)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer s.stop()
+	if config.PidFile != "" {
+		if err := writePidFile(config.PidFile); err != nil {
+			log.Println("write pid file error:", err)
+			return 1
+		}
+		defer os.Remove(config.PidFile)
+	}
+	s.start()
+	log.Printf("rulego started, %d endpoints", len(s.endpoints))
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	select {
+	case sig := <-sigCh:
+		log.Println("rulego stopped:", sig)
+		return 0
+	case <-stop:
+		log.Println("rulego stopped")
+		return 0
+	case err := <-s.errCh:
+		log.Println(err)
+		return 1
+	}
+}
+
+// writePidFile 写入当前进程ID
+func writePidFile(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
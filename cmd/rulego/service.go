@@ -0,0 +1,126 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultServiceName 默认服务名称
+const defaultServiceName = "rulego"
+
+// serviceManager 操作系统服务管理，Linux使用systemd，Windows使用服务控制管理器
+type serviceManager interface {
+	//Install 安装开机启动的服务，exe和args为服务的启动命令
+	Install(name, exe string, args []string) error
+	//Uninstall 停止并删除服务
+	Uninstall(name string) error
+	Start(name string) error
+	Stop(name string) error
+	//Status 服务运行状态，例如：active、running、stopped
+	Status(name string) (string, error)
+}
+
+func serviceCmd(args []string) int {
+	fs := flag.NewFlagSet("service", flag.ExitOnError)
+	name := fs.String("name", defaultServiceName, "Service name.")
+	configFile := fs.String("config", "rulego.json", "Server config file used by the installed service.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rulego service <install|uninstall|start|stop|status> [-name rulego] [-config rulego.json]")
+		fmt.Fprintln(fs.Output(), "Manages the systemd unit on Linux or the Windows service that runs \"rulego serve\".")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs.Usage()
+		return 2
+	}
+	action := args[0]
+	_ = fs.Parse(args[1:])
+
+	manager, err := newServiceManager()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	switch action {
+	case "install":
+		err = installService(manager, *name, *configFile)
+	case "uninstall":
+		err = manager.Uninstall(*name)
+	case "start":
+		err = manager.Start(*name)
+	case "stop":
+		err = manager.Stop(*name)
+	case "status":
+		var status string
+		if status, err = manager.Status(*name); err == nil {
+			fmt.Println(status)
+		}
+	default:
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "service %s %s: %s\n", action, *name, err)
+		return 1
+	}
+	return 0
+}
+
+// installService 检查配置文件后安装服务，服务使用当前可执行文件和配置文件的绝对路径运行serve命令
+func installService(manager serviceManager, name, configFile string) error {
+	configFile, err := filepath.Abs(configFile)
+	if err != nil {
+		return err
+	}
+	if _, err = loadServerConfig(configFile); err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return manager.Install(name, exe, []string{"serve", "-config", configFile, "-name", name})
+}
+
+// systemdUnit systemd服务单元文件内容，异常退出后自动重启，停止时发送SIGTERM
+func systemdUnit(name, exe string, args []string) string {
+	command := make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{exe}, args...) {
+		command = append(command, strconv.Quote(arg))
+	}
+	return fmt.Sprintf(`[Unit]
+Description=RuleGo rule engine (%s)
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart=%s
+Restart=on-failure
+RestartSec=5
+KillSignal=SIGTERM
+
+[Install]
+WantedBy=multi-user.target
+`, name, strings.Join(command, " "))
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// systemdUnitDir systemd服务单元文件目录
+var systemdUnitDir = "/etc/systemd/system"
+
+// systemdManager 通过systemctl管理服务
+type systemdManager struct {
+}
+
+func newServiceManager() (serviceManager, error) {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return nil, fmt.Errorf("systemd not found: %w", err)
+	}
+	return systemdManager{}, nil
+}
+
+func (m systemdManager) unitFile(name string) string {
+	return filepath.Join(systemdUnitDir, name+".service")
+}
+
+func (m systemdManager) Install(name, exe string, args []string) error {
+	if err := os.WriteFile(m.unitFile(name), []byte(systemdUnit(name, exe, args)), 0644); err != nil {
+		return err
+	}
+	if _, err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	_, err := systemctl("enable", name)
+	return err
+}
+
+func (m systemdManager) Uninstall(name string) error {
+	if _, err := systemctl("disable", "--now", name); err != nil {
+		return err
+	}
+	if err := os.Remove(m.unitFile(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	_, err := systemctl("daemon-reload")
+	return err
+}
+
+func (m systemdManager) Start(name string) error {
+	_, err := systemctl("start", name)
+	return err
+}
+
+func (m systemdManager) Stop(name string) error {
+	_, err := systemctl("stop", name)
+	return err
+}
+
+func (m systemdManager) Status(name string) (string, error) {
+	//服务没有运行时is-active返回非0退出码
+	out, err := systemctl("is-active", name)
+	if out != "" {
+		return out, nil
+	}
+	return "", err
+}
+
+// systemctl 执行systemctl命令，返回标准输出
+func systemctl(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return strings.TrimSpace(stdout.String()), fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// runAsService Linux由systemd直接运行serve命令，不需要额外处理
+func runAsService(name string, run func(stop <-chan struct{}) int) (int, bool) {
+	return 0, false
+}
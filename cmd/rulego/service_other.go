@@ -0,0 +1,32 @@
+//go:build !linux && !windows
+
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newServiceManager() (serviceManager, error) {
+	return nil, fmt.Errorf("service management not supported on %s", runtime.GOOS)
+}
+
+func runAsService(name string, run func(stop <-chan struct{}) int) (int, bool) {
+	return 0, false
+}
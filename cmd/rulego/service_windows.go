@@ -0,0 +1,170 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+	"time"
+)
+
+// windowsManager 通过服务控制管理器管理服务
+type windowsManager struct {
+}
+
+func newServiceManager() (serviceManager, error) {
+	return windowsManager{}, nil
+}
+
+// open 连接服务控制管理器并打开服务
+func (m windowsManager) open(name string) (*mgr.Mgr, *mgr.Service, error) {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := manager.OpenService(name)
+	if err != nil {
+		_ = manager.Disconnect()
+		return nil, nil, err
+	}
+	return manager, s, nil
+}
+
+func (m windowsManager) Install(name, exe string, args []string) error {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer manager.Disconnect()
+	s, err := manager.CreateService(name, exe, mgr.Config{
+		DisplayName: "RuleGo rule engine (" + name + ")",
+		Description: "Runs rule chains and endpoints declared in the rulego server config file.",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	//异常退出后5秒重启
+	return s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: time.Second * 5}}, 86400)
+}
+
+func (m windowsManager) Uninstall(name string) error {
+	manager, s, err := m.open(name)
+	if err != nil {
+		return err
+	}
+	defer manager.Disconnect()
+	defer s.Close()
+	if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+		_, _ = s.Control(svc.Stop)
+	}
+	return s.Delete()
+}
+
+func (m windowsManager) Start(name string) error {
+	manager, s, err := m.open(name)
+	if err != nil {
+		return err
+	}
+	defer manager.Disconnect()
+	defer s.Close()
+	return s.Start()
+}
+
+func (m windowsManager) Stop(name string) error {
+	manager, s, err := m.open(name)
+	if err != nil {
+		return err
+	}
+	defer manager.Disconnect()
+	defer s.Close()
+	_, err = s.Control(svc.Stop)
+	return err
+}
+
+func (m windowsManager) Status(name string) (string, error) {
+	manager, s, err := m.open(name)
+	if err != nil {
+		return "", err
+	}
+	defer manager.Disconnect()
+	defer s.Close()
+	status, err := s.Query()
+	if err != nil {
+		return "", err
+	}
+	switch status.State {
+	case svc.Running:
+		return "running", nil
+	case svc.Stopped:
+		return "stopped", nil
+	case svc.StartPending:
+		return "start pending", nil
+	case svc.StopPending:
+		return "stop pending", nil
+	default:
+		return fmt.Sprintf("state %d", status.State), nil
+	}
+}
+
+// windowsService 响应服务控制管理器的停止请求
+type windowsService struct {
+	run  func(stop <-chan struct{}) int
+	code int
+}
+
+func (s *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	stop := make(chan struct{})
+	done := make(chan int, 1)
+	go func() {
+		done <- s.run(stop)
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case s.code = <-done:
+			changes <- svc.Status{State: svc.StopPending}
+			return false, uint32(s.code)
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				close(stop)
+				s.code = <-done
+				return false, uint32(s.code)
+			}
+		}
+	}
+}
+
+// runAsService 由服务控制管理器启动时以服务方式运行，收到停止请求后关闭stop
+func runAsService(name string, run func(stop <-chan struct{}) int) (int, bool) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return 0, false
+	}
+	s := &windowsService{run: run}
+	if err = svc.Run(name, s); err != nil {
+		return 1, true
+	}
+	return s.code, true
+}
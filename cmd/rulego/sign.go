@@ -0,0 +1,111 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/sign"
+	"os"
+	"strings"
+)
+
+func signCmd(args []string) int {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	keygen := fs.Bool("keygen", false, "Generate a new Ed25519 key pair and print it base64 encoded.")
+	key := fs.String("key", os.Getenv("RULEGO_SIGN_KEY"), "Base64 Ed25519 private key or a file containing it, defaults to $RULEGO_SIGN_KEY.")
+	keyId := fs.String("key-id", "", "Id of the signing key, looked up in the trusted keys when loading.")
+	out := fs.String("o", "", "Output file, defaults to overwriting the input file.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rulego sign -key <key> -key-id <id> [-o out] <file>\n       rulego sign -keygen")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *keygen {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("public:  %s\n", base64.StdEncoding.EncodeToString(publicKey))
+		fmt.Printf("private: %s\n", base64.StdEncoding.EncodeToString(privateKey))
+		return 0
+	}
+	if fs.NArg() != 1 || *key == "" || *keyId == "" {
+		fs.Usage()
+		return 2
+	}
+	privateKey, err := sign.ParsePrivateKey(readKey(*key))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid key:", err)
+		return 1
+	}
+	file := fs.Arg(0)
+	dsl, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	signed, err := sign.Sign(dsl, *keyId, privateKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *out == "" {
+		*out = file
+	}
+	if err = os.WriteFile(*out, signed, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("signed %s with key %s\n", *out, *keyId)
+	return 0
+}
+
+// loadTrustedKeys 读取信任的签名公钥文件，格式：{"keyId":"base64公钥"}
+func loadTrustedKeys(file string) ([]types.Option, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var keys map[string]string
+	if err = json.Unmarshal(content, &keys); err != nil {
+		return nil, err
+	}
+	var opts []types.Option
+	for keyId, value := range keys {
+		publicKey, err := sign.ParsePublicKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", keyId, err)
+		}
+		opts = append(opts, types.WithTrustedKey(keyId, publicKey))
+	}
+	return opts, nil
+}
+
+// readKey key是文件则读取文件内容
+func readKey(key string) string {
+	if content, err := os.ReadFile(key); err == nil {
+		return strings.TrimSpace(string(content))
+	}
+	return key
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/2018yuli/rulego/test/spec"
+	"os"
+	"strings"
+)
+
+func testCmd(args []string) int {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	timeout := fs.Duration("timeout", spec.DefaultTimeout, "Max time to wait for each case.")
+	verbose := fs.Bool("v", false, "Print the node path of every case.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rulego test [-v] [-timeout 10s] <spec file or dir>...")
+		fmt.Fprintln(fs.Output(), "Spec files are .json, .yaml or .yml files, see package github.com/2018yuli/rulego/test/spec.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	files, err := expandFiles(fs.Args(), ".json", ".yaml", ".yml")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	passed, failed := 0, 0
+	for _, file := range files {
+		results, err := spec.RunFile(file, *timeout)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %s\n", file, err)
+			continue
+		}
+		for _, item := range results {
+			if item.Err == nil {
+				passed++
+				fmt.Printf("ok   %s/%s\n", file, item.Name)
+			} else {
+				failed++
+				fmt.Printf("FAIL %s/%s: %s\n", file, item.Name, item.Err)
+			}
+			if *verbose && item.Trace != nil {
+				fmt.Printf("     path: %s\n", strings.Join(item.Trace.Path(), " -> "))
+			}
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/spec"
+	"github.com/2018yuli/rulego/utils/json"
+	"io"
+	"os"
+)
+
+func traceCmd(args []string) int {
+	fs := flag.NewFlagSet("trace", flag.ExitOnError)
+	file := fs.String("file", "", "Location of the rule chain file.")
+	msgType := fs.String("type", "TEST_MSG_TYPE", "Message type.")
+	dataType := fs.String("data_type", string(types.JSON), "Message data type: JSON, TEXT or BINARY.")
+	data := fs.String("data", "{}", "Message data.")
+	metadata := fs.String("metadata", "{}", "Message metadata, json object.")
+	timeout := fs.Duration("timeout", spec.DefaultTimeout, "Max time to wait for the chain to finish.")
+	_ = fs.Parse(args)
+	if *file == "" {
+		fs.Usage()
+		return 2
+	}
+	def, err := loadChain(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	msg := spec.Msg{Type: *msgType, DataType: *dataType}
+	if err := json.Unmarshal([]byte(*metadata), &msg.Metadata); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid metadata:", err)
+		return 1
+	}
+	msg.Data, _ = json.Marshal(*data)
+	trace, err := spec.Run(def, nil, msg.NewMsg(), *timeout)
+	if trace != nil {
+		printTrace(os.Stdout, nodeTypes(def), trace)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// printTrace 按消息流入节点的顺序打印经过的节点和规则链结束结果
+// 流出事件是异步记录的，打印时跟在对应节点流入事件后面
+func printTrace(w io.Writer, nodeTypes map[string]string, trace *spec.Trace) {
+	trace.Lock()
+	defer trace.Unlock()
+	printed := make([]bool, len(trace.Events))
+	for i, item := range trace.Events {
+		if item.FlowType != types.In {
+			continue
+		}
+		node := fmt.Sprintf("%s(%s)", item.NodeId, nodeTypes[item.NodeId])
+		fmt.Fprintf(w, "-> %-24s type=%s data=%s\n", node, item.Msg.Type, item.Msg.Data)
+		for j := i + 1; j < len(trace.Events); j++ {
+			out := trace.Events[j]
+			if printed[j] || out.FlowType != types.Out || out.NodeId != item.NodeId {
+				continue
+			}
+			printed[j] = true
+			fmt.Fprintf(w, "<- %-24s relation=%s", node, out.RelationType)
+			if out.Err != nil {
+				fmt.Fprintf(w, " err=%s", out.Err)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	for _, item := range trace.Results {
+		metadata, _ := json.Marshal(item.Msg.Metadata.Values())
+		fmt.Fprintf(w, "== end type=%s data=%s metadata=%s", item.Msg.Type, item.Msg.Data, metadata)
+		if item.Err != nil {
+			fmt.Fprintf(w, " err=%s", item.Err)
+		}
+		fmt.Fprintln(w)
+	}
+}
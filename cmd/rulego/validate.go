@@ -0,0 +1,124 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/utils/json"
+	"os"
+)
+
+func validateCmd(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	initNodes := fs.Bool("init", false, "Also initialize every node, which may connect to external services.")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rulego validate [-init] <file or dir>...")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	files, err := expandFiles(fs.Args(), ".json")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	code := 0
+	for _, file := range files {
+		problems := validateFile(file, *initNodes)
+		if len(problems) == 0 {
+			fmt.Printf("ok   %s\n", file)
+			continue
+		}
+		code = 1
+		fmt.Printf("FAIL %s\n", file)
+		for _, problem := range problems {
+			fmt.Printf("     %s\n", problem)
+		}
+	}
+	return code
+}
+
+// validateFile 检查规则链DSL文件，返回发现的问题
+func validateFile(file string, initNodes bool) []string {
+	def, err := loadChain(file)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	expanded, err := rulego.ResolveChain(def)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	problems := validateChain(expanded)
+	if len(problems) == 0 && initNodes {
+		dsl, _ := json.Marshal(def)
+		ruleGo := &rulego.RuleGo{}
+		if ruleEngine, err := ruleGo.New("", dsl); err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			ruleEngine.Stop()
+		}
+	}
+	return problems
+}
+
+// validateChain 检查规则链结构：节点组件是否注册、节点ID是否重复、连接的节点是否存在
+func validateChain(def rulego.RuleChain) []string {
+	var problems []string
+	nodes := def.Metadata.Nodes
+	if len(nodes) == 0 {
+		problems = append(problems, "nodes can not empty")
+	} else if def.Metadata.FirstNodeIndex < 0 || def.Metadata.FirstNodeIndex >= len(nodes) {
+		problems = append(problems, fmt.Sprintf("firstNodeIndex=%d out of range", def.Metadata.FirstNodeIndex))
+	}
+	components := rulego.Registry.GetComponents()
+	ids := make(map[string]bool)
+	for index, node := range nodes {
+		id := node.Id
+		if id == "" {
+			id = fmt.Sprintf("node%d", index)
+		}
+		if ids[id] {
+			problems = append(problems, fmt.Sprintf("duplicate node id=%s", id))
+		}
+		ids[id] = true
+		if _, ok := components[node.Type]; !ok {
+			problems = append(problems, fmt.Sprintf("node id=%s: component not found.componentType=%s", id, node.Type))
+		}
+	}
+	for _, item := range def.Metadata.Connections {
+		if !ids[item.FromId] {
+			problems = append(problems, fmt.Sprintf("connection fromId=%s not found", item.FromId))
+		}
+		if !ids[item.ToId] {
+			problems = append(problems, fmt.Sprintf("connection toId=%s not found", item.ToId))
+		}
+		if item.Type == "" {
+			problems = append(problems, fmt.Sprintf("connection %s->%s type can not empty", item.FromId, item.ToId))
+		}
+	}
+	for _, item := range def.Metadata.RuleChainConnections {
+		if !ids[item.FromId] {
+			problems = append(problems, fmt.Sprintf("ruleChainConnection fromId=%s not found", item.FromId))
+		}
+	}
+	return problems
+}
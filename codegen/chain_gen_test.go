@@ -0,0 +1,86 @@
+// Code generated by rulego-gen. DO NOT EDIT.
+
+package codegen_test
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/codegen"
+	"github.com/2018yuli/rulego/components/filter"
+	"github.com/2018yuli/rulego/components/transform"
+)
+
+// Chain 规则链[测试规则链]静态生成代码
+type Chain struct {
+	config types.Config
+	//s1 过滤
+	node0 *filter.JsFilterNode
+	//s2 转换
+	node1 *transform.JsTransformNode
+	//s3 路由
+	node2 *filter.MsgTypeSwitchNode
+}
+
+// NewChain 创建并初始化规则链所有节点
+func NewChain(config types.Config) (*Chain, error) {
+	c := &Chain{config: config}
+	c.node0 = (&filter.JsFilterNode{}).New().(*filter.JsFilterNode)
+	if err := c.node0.Init(config, types.Configuration{"jsScript": "return msg.temperature > 50;"}); err != nil {
+		return nil, err
+	}
+	c.node1 = (&transform.JsTransformNode{}).New().(*transform.JsTransformNode)
+	if err := c.node1.Init(config, types.Configuration{"jsScript": "metadata['alarm']='true';\nreturn {'msg':msg,'metadata':metadata,'msgType':msgType};"}); err != nil {
+		return nil, err
+	}
+	c.node2 = (&filter.MsgTypeSwitchNode{}).New().(*filter.MsgTypeSwitchNode)
+	if err := c.node2.Init(config, types.Configuration{}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// OnMsg 把消息交给规则链处理，异步执行
+func (c *Chain) OnMsg(msg types.RuleMsg, opts ...types.RuleContextOption) {
+	codegen.NewContext(c.config, opts...).SetChainId("codegen01").Next("s1", c.node0, true, c.route0, msg)
+}
+
+// Destroy 销毁规则链所有节点
+func (c *Chain) Destroy() {
+	if c.node0 != nil {
+		c.node0.Destroy()
+	}
+	if c.node1 != nil {
+		c.node1.Destroy()
+	}
+	if c.node2 != nil {
+		c.node2.Destroy()
+	}
+}
+
+// route0 节点s1路由
+func (c *Chain) route0(ctx *codegen.Context, msg types.RuleMsg, relationType string) bool {
+	switch relationType {
+	case "True":
+		ctx.Next("s2", c.node1, false, c.route1, msg.Copy())
+	case "False":
+		ctx.Next("s3", c.node2, false, c.route2, msg.Copy())
+	default:
+		return false
+	}
+	return true
+}
+
+// route1 节点s2路由
+func (c *Chain) route1(ctx *codegen.Context, msg types.RuleMsg, relationType string) bool {
+	switch relationType {
+	case "Success":
+		ctx.Next("s3", c.node2, false, c.route2, msg.Copy())
+	default:
+		return false
+	}
+	return true
+}
+
+// route2 节点s3路由
+func (c *Chain) route2(ctx *codegen.Context, msg types.RuleMsg, relationType string) bool {
+	return false
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codegen
+
+import (
+	"context"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/state"
+	"time"
+)
+
+// Route 生成代码中每个节点的路由函数，把消息通过指定关系发送到下一个节点
+// 如果该关系没有下一个节点，返回false
+type Route func(ctx *Context, msg types.RuleMsg, relationType string) bool
+
+// Context 生成代码使用的规则引擎消息处理上下文
+// 节点之间的连接关系由生成的Route函数静态路由，运行时不查找节点和关系表
+type Context struct {
+	config types.Config
+	//规则链ID
+	chainId string
+	//当前节点ID
+	nodeId string
+	//当前节点
+	node types.Node
+	//当前节点是否开启调试模式
+	debugMode bool
+	//当前节点路由
+	route Route
+	//当前消息整条规则链处理结束回调函数
+	onEnd func(msg types.RuleMsg, err error)
+	//用于不同组件共享信号量和数据的上下文
+	context context.Context
+}
+
+// NewContext 创建根上下文，通过Next把消息发送到第一个节点
+func NewContext(config types.Config, opts ...types.RuleContextOption) *Context {
+	ctx := &Context{
+		config:  config,
+		context: context.TODO(),
+	}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+	return ctx
+}
+
+// SetChainId 设置规则链ID，节点状态的作用域为规则链+节点
+func (ctx *Context) SetChainId(chainId string) *Context {
+	ctx.chainId = chainId
+	return ctx
+}
+
+// Next 异步把消息交给指定节点处理
+func (ctx *Context) Next(nodeId string, node types.Node, debugMode bool, route Route, msg types.RuleMsg) {
+	nextCtx := &Context{
+		config:    ctx.config,
+		chainId:   ctx.chainId,
+		nodeId:    nodeId,
+		node:      node,
+		debugMode: debugMode,
+		route:     route,
+		onEnd:     ctx.onEnd,
+		context:   ctx.context,
+	}
+	ctx.SubmitTack(func() {
+		nextCtx.onMsg(msg)
+	})
+}
+
+func (ctx *Context) onMsg(msg types.RuleMsg) {
+	defer func() {
+		//捕捉异常
+		if e := recover(); e != nil && ctx.debugMode {
+			ctx.onDebug(types.In, msg, "", fmt.Errorf("%v", e))
+		}
+	}()
+	if ctx.debugMode {
+		ctx.onDebug(types.In, msg, "", nil)
+	}
+	if err := ctx.node.OnMsg(ctx, msg); err != nil {
+		ctx.config.Logger.Printf("tellNext error.node type:%s error: %s", ctx.node.Type(), err)
+	}
+}
+
+func (ctx *Context) TellSuccess(msg types.RuleMsg) {
+	ctx.tell(msg, nil, types.Success)
+}
+
+func (ctx *Context) TellFailure(msg types.RuleMsg, err error) {
+	ctx.tell(msg, err, types.Failure)
+}
+
+func (ctx *Context) TellNext(msg types.RuleMsg, relationTypes ...string) {
+	ctx.tell(msg, nil, relationTypes...)
+}
+
+func (ctx *Context) TellSelf(msg types.RuleMsg, delayMs int64) {
+	ctx.config.GetClock().AfterFunc(time.Millisecond*time.Duration(delayMs), func() {
+		ctx.tell(msg, nil, types.Success)
+	})
+}
+
+func (ctx *Context) NewMsg(msgType string, metaData types.Metadata, data string) types.RuleMsg {
+	return types.NewMsg(ctx.config.GetClock().Now().UnixMilli(), msgType, types.JSON, metaData, data)
+}
+
+func (ctx *Context) GetSelfId() string {
+	return ctx.nodeId
+}
+
+func (ctx *Context) Config() types.Config {
+	return ctx.config
+}
+
+func (ctx *Context) GetState(key string) (string, bool, error) {
+	return ctx.config.GetStateStore().Get(state.Key(ctx.chainId, ctx.nodeId, key))
+}
+
+func (ctx *Context) SetState(key string, value string) error {
+	return ctx.config.GetStateStore().Set(state.Key(ctx.chainId, ctx.nodeId, key), value)
+}
+
+func (ctx *Context) Async(msg types.RuleMsg, timeout time.Duration) types.AsyncCompletion {
+	return types.NewAsyncCompletion(ctx, msg, timeout, nil)
+}
+
+func (ctx *Context) Stream(msg types.RuleMsg, idleTimeout time.Duration) types.Stream {
+	return types.NewStream(ctx, msg, idleTimeout, nil, nil)
+}
+
+func (ctx *Context) SubmitTack(task func()) {
+	if ctx.config.Pool != nil {
+		if err := ctx.config.Pool.Submit(task); err != nil {
+			ctx.config.Logger.Printf("SubmitTack error:%s", err)
+		}
+	} else {
+		go task()
+	}
+}
+
+func (ctx *Context) SetEndFunc(onEndFunc func(msg types.RuleMsg, err error)) types.RuleContext {
+	ctx.onEnd = onEndFunc
+	return ctx
+}
+
+func (ctx *Context) GetEndFunc() func(msg types.RuleMsg, err error) {
+	return ctx.onEnd
+}
+
+func (ctx *Context) SetContext(c context.Context) types.RuleContext {
+	ctx.context = c
+	return ctx
+}
+
+func (ctx *Context) GetContext() context.Context {
+	return ctx.context
+}
+
+func (ctx *Context) tell(msg types.RuleMsg, err error, relationTypes ...string) {
+	msgCopy := msg.Copy()
+	for _, relationType := range relationTypes {
+		if ctx.debugMode {
+			ctx.onDebug(types.Out, msgCopy, relationType, err)
+		}
+		if ctx.route == nil || !ctx.route(ctx, msgCopy, relationType) {
+			ctx.doOnEnd(msgCopy, err)
+		}
+	}
+}
+
+func (ctx *Context) onDebug(flowType string, msg types.RuleMsg, relationType string, err error) {
+	if ctx.config.OnDebug != nil {
+		msgCopy := msg.Copy()
+		ctx.SubmitTack(func() {
+			ctx.config.OnDebug(flowType, ctx.nodeId, msgCopy, relationType, err)
+		})
+	}
+}
+
+// 规则链执行完成回调函数
+func (ctx *Context) doOnEnd(msg types.RuleMsg, err error) {
+	if ctx.config.OnEnd != nil {
+		ctx.SubmitTack(func() {
+			ctx.config.OnEnd(msg, err)
+		})
+	}
+	if ctx.onEnd != nil {
+		ctx.SubmitTack(func() {
+			ctx.onEnd(msg, err)
+		})
+	}
+}
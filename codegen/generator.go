@@ -0,0 +1,322 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package codegen 把规则链DSL生成静态Go代码
+// 生成的代码直接持有具体类型的节点实例，节点之间通过生成的路由函数静态连接，
+// 运行时不需要通过组件注册器、节点表和关系表查找节点，适合规则链稳定、性能要求高的嵌入式部署
+package codegen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"go/format"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	//DefaultPackage 默认生成代码包名
+	DefaultPackage = "main"
+	//DefaultTypeName 默认生成规则链结构体名称
+	DefaultTypeName = "Chain"
+
+	runtimePkgPath = "github.com/2018yuli/rulego/codegen"
+	typesPkgPath   = "github.com/2018yuli/rulego/api/types"
+)
+
+// Options 代码生成选项
+type Options struct {
+	//Package 生成代码包名，默认：main
+	Package string
+	//TypeName 生成规则链结构体名称，默认：Chain，构造函数为：New+TypeName
+	TypeName string
+	//Registry 组件注册器，用于查找节点组件类型，默认使用rulego.Registry
+	Registry types.ComponentRegistry
+}
+
+// GenerateFromDSL 把规则链DSL生成静态Go代码
+func GenerateFromDSL(dsl []byte, opts Options) ([]byte, error) {
+	def, err := rulego.ParserRuleChain(dsl)
+	if err != nil {
+		return nil, err
+	}
+	return Generate(def, opts)
+}
+
+// Generate 把规则链定义生成静态Go代码
+// 不支持子规则链连接
+func Generate(def rulego.RuleChain, opts Options) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = DefaultPackage
+	}
+	if opts.TypeName == "" {
+		opts.TypeName = DefaultTypeName
+	}
+	if opts.Registry == nil {
+		opts.Registry = rulego.Registry
+	}
+	def, err := rulego.ResolveChain(def)
+	if err != nil {
+		return nil, err
+	}
+	if len(def.Metadata.RuleChainConnections) != 0 {
+		return nil, errors.New("ruleChainConnections not supported")
+	}
+	nodes := def.Metadata.Nodes
+	if len(nodes) == 0 {
+		return nil, errors.New("nodes can not empty")
+	}
+	firstIndex := def.Metadata.FirstNodeIndex
+	if firstIndex < 0 || firstIndex >= len(nodes) {
+		return nil, fmt.Errorf("firstNodeIndex=%d out of range", firstIndex)
+	}
+
+	g := &generator{
+		opts:    opts,
+		imports: map[string]string{typesPkgPath: "types", runtimePkgPath: "codegen"},
+		aliases: map[string]bool{"types": true, "codegen": true},
+		indexes: make(map[string]int),
+	}
+	for index, node := range nodes {
+		id := node.Id
+		if id == "" {
+			id = fmt.Sprintf("node%d", index)
+		}
+		if _, ok := g.indexes[id]; ok {
+			return nil, fmt.Errorf("duplicate node id=%s", id)
+		}
+		g.indexes[id] = index
+		g.ids = append(g.ids, id)
+		typeName, err := g.nodeTypeName(node.Type)
+		if err != nil {
+			return nil, err
+		}
+		g.typeNames = append(g.typeNames, typeName)
+	}
+	g.routes = make([][]route, len(nodes))
+	for _, item := range def.Metadata.Connections {
+		from, ok := g.indexes[item.FromId]
+		if !ok {
+			return nil, fmt.Errorf("connection fromId=%s not found", item.FromId)
+		}
+		to, ok := g.indexes[item.ToId]
+		if !ok {
+			return nil, fmt.Errorf("connection toId=%s not found", item.ToId)
+		}
+		g.addRoute(from, item.Type, to)
+	}
+	return g.generate(def)
+}
+
+// route 节点指定关系的所有下一个节点
+type route struct {
+	relationType string
+	to           []int
+}
+
+type generator struct {
+	opts Options
+	//imports 包路径->别名
+	imports map[string]string
+	aliases map[string]bool
+	//indexes 节点ID->节点索引
+	indexes   map[string]int
+	ids       []string
+	typeNames []string
+	routes    [][]route
+	buf       bytes.Buffer
+}
+
+// nodeTypeName 获取节点组件的Go类型名称，并登记导入包
+func (g *generator) nodeTypeName(nodeType string) (string, error) {
+	node, err := g.opts.Registry.NewNode(nodeType)
+	if err != nil {
+		return "", err
+	}
+	t := reflect.TypeOf(node)
+	if t.Kind() != reflect.Ptr || t.Elem().Name() == "" {
+		return "", fmt.Errorf("node type=%s is not a pointer to named type", nodeType)
+	}
+	t = t.Elem()
+	pkgPath := t.PkgPath()
+	if pkgPath == "" || pkgPath == "main" {
+		return "", fmt.Errorf("node type=%s package %s can not be imported", nodeType, pkgPath)
+	}
+	alias, ok := g.imports[pkgPath]
+	if !ok {
+		base := strings.Map(func(r rune) rune {
+			if r == '-' || r == '.' {
+				return '_'
+			}
+			return r
+		}, path.Base(pkgPath))
+		alias = base
+		for i := 2; g.aliases[alias]; i++ {
+			alias = base + strconv.Itoa(i)
+		}
+		g.imports[pkgPath] = alias
+		g.aliases[alias] = true
+	}
+	return alias + "." + t.Name(), nil
+}
+
+func (g *generator) addRoute(from int, relationType string, to int) {
+	for i, item := range g.routes[from] {
+		if item.relationType == relationType {
+			g.routes[from][i].to = append(item.to, to)
+			return
+		}
+	}
+	g.routes[from] = append(g.routes[from], route{relationType: relationType, to: []int{to}})
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) generate(def rulego.RuleChain) ([]byte, error) {
+	nodes := def.Metadata.Nodes
+	typeName := g.opts.TypeName
+	first := def.Metadata.FirstNodeIndex
+
+	g.printf("// Code generated by rulego-gen. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", g.opts.Package)
+	g.printImports()
+
+	name := def.RuleChain.Name
+	if name == "" {
+		name = def.RuleChain.ID
+	}
+	g.printf("// %s 规则链[%s]静态生成代码\n", typeName, name)
+	g.printf("type %s struct {\n\tconfig types.Config\n", typeName)
+	for i, node := range nodes {
+		g.printf("\t//%s %s\n\tnode%d *%s\n", g.ids[i], node.Name, i, g.typeNames[i])
+	}
+	g.printf("}\n\n")
+
+	g.printf("// New%s 创建并初始化规则链所有节点\n", typeName)
+	g.printf("func New%s(config types.Config) (*%s, error) {\n", typeName, typeName)
+	g.printf("\tc := &%s{config: config}\n", typeName)
+	for i, node := range nodes {
+		configuration, err := literal(map[string]interface{}(node.Configuration))
+		if err != nil {
+			return nil, fmt.Errorf("node id=%s configuration: %w", g.ids[i], err)
+		}
+		if node.Configuration == nil {
+			configuration = "types.Configuration{}"
+		} else {
+			configuration = "types.Configuration" + strings.TrimPrefix(configuration, "map[string]interface{}")
+		}
+		g.printf("\tc.node%d = (&%s{}).New().(*%s)\n", i, g.typeNames[i], g.typeNames[i])
+		g.printf("\tif err := c.node%d.Init(config, %s); err != nil {\n\t\treturn nil, err\n\t}\n", i, configuration)
+	}
+	g.printf("\treturn c, nil\n}\n\n")
+
+	g.printf("// OnMsg 把消息交给规则链处理，异步执行\n")
+	g.printf("func (c *%s) OnMsg(msg types.RuleMsg, opts ...types.RuleContextOption) {\n", typeName)
+	g.printf("\tcodegen.NewContext(c.config, opts...).SetChainId(%s).Next(%s, c.node%d, %t, c.route%d, msg)\n}\n\n",
+		strconv.Quote(def.RuleChain.ID), strconv.Quote(g.ids[first]), first, nodes[first].DebugMode, first)
+
+	g.printf("// Destroy 销毁规则链所有节点\n")
+	g.printf("func (c *%s) Destroy() {\n", typeName)
+	for i := range nodes {
+		g.printf("\tif c.node%d != nil {\n\t\tc.node%d.Destroy()\n\t}\n", i, i)
+	}
+	g.printf("}\n")
+
+	for i := range nodes {
+		g.printf("\n// route%d 节点%s路由\n", i, g.ids[i])
+		g.printf("func (c *%s) route%d(ctx *codegen.Context, msg types.RuleMsg, relationType string) bool {\n", typeName, i)
+		if len(g.routes[i]) == 0 {
+			g.printf("\treturn false\n}\n")
+			continue
+		}
+		g.printf("\tswitch relationType {\n")
+		for _, item := range g.routes[i] {
+			g.printf("\tcase %s:\n", strconv.Quote(item.relationType))
+			for _, to := range item.to {
+				g.printf("\t\tctx.Next(%s, c.node%d, %t, c.route%d, msg.Copy())\n", strconv.Quote(g.ids[to]), to, nodes[to].DebugMode, to)
+			}
+		}
+		g.printf("\tdefault:\n\t\treturn false\n\t}\n\treturn true\n}\n")
+	}
+	return format.Source(g.buf.Bytes())
+}
+
+func (g *generator) printImports() {
+	pkgPaths := make([]string, 0, len(g.imports))
+	for pkgPath := range g.imports {
+		pkgPaths = append(pkgPaths, pkgPath)
+	}
+	sort.Strings(pkgPaths)
+	g.printf("import (\n")
+	for _, pkgPath := range pkgPaths {
+		if alias := g.imports[pkgPath]; alias != path.Base(pkgPath) {
+			g.printf("\t%s %s\n", alias, strconv.Quote(pkgPath))
+		} else {
+			g.printf("\t%s\n", strconv.Quote(pkgPath))
+		}
+	}
+	g.printf(")\n\n")
+}
+
+// literal 把json解析后的值转换成Go字面量
+// 数字统一生成float64，和解析DSL得到的值类型保持一致
+func literal(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case nil:
+		return "nil", nil
+	case string:
+		return strconv.Quote(value), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case float64:
+		return "float64(" + strconv.FormatFloat(value, 'g', -1, 64) + ")", nil
+	case []interface{}:
+		var items []string
+		for _, item := range value {
+			s, err := literal(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return "[]interface{}{" + strings.Join(items, ", ") + "}", nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var items []string
+		for _, k := range keys {
+			s, err := literal(value[k])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, strconv.Quote(k)+": "+s)
+		}
+		return "map[string]interface{}{" + strings.Join(items, ", ") + "}", nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
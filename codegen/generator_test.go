@@ -0,0 +1,108 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codegen_test
+
+import (
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/codegen"
+	"github.com/2018yuli/rulego/test/assert"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+//go:generate go run github.com/2018yuli/rulego/cmd/rulego-gen -rule_file testdata/chain.json -out chain_gen_test.go -package codegen_test
+
+// TestGenerate 生成代码和chain_gen_test.go一致
+func TestGenerate(t *testing.T) {
+	dsl, err := os.ReadFile("testdata/chain.json")
+	assert.Nil(t, err)
+	src, err := codegen.GenerateFromDSL(dsl, codegen.Options{Package: "codegen_test"})
+	assert.Nil(t, err)
+	expected, err := os.ReadFile("chain_gen_test.go")
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(src))
+}
+
+func TestGenerateError(t *testing.T) {
+	_, err := codegen.GenerateFromDSL([]byte("{"), codegen.Options{})
+	assert.NotNil(t, err)
+
+	_, err = codegen.Generate(rulego.RuleChain{}, codegen.Options{})
+	assert.NotNil(t, err)
+
+	def, err := rulego.NewChainBuilder().Node("notFound", nil).Build()
+	assert.Nil(t, err)
+	_, err = codegen.Generate(def, codegen.Options{})
+	assert.NotNil(t, err)
+
+	def, err = rulego.NewChainBuilder().Node("log", nil).On(types.Success).ToChain("sub01").Build()
+	assert.Nil(t, err)
+	_, err = codegen.Generate(def, codegen.Options{})
+	assert.NotNil(t, err)
+
+	def, err = rulego.NewChainBuilder().Node("log", types.Configuration{"value": struct{}{}}).Build()
+	assert.Nil(t, err)
+	_, err = codegen.Generate(def, codegen.Options{})
+	assert.NotNil(t, err)
+}
+
+// TestGeneratedChain 运行生成的规则链
+func TestGeneratedChain(t *testing.T) {
+	var debugCount int
+	var lock sync.Mutex
+	config := rulego.NewConfig()
+	config.OnDebug = func(flowType string, nodeId string, msg types.RuleMsg, relationType string, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		assert.Equal(t, "s1", nodeId)
+		debugCount++
+	}
+	chain, err := NewChain(config)
+	assert.Nil(t, err)
+	defer chain.Destroy()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	chain.OnMsg(types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, types.NewMetadata(), "{\"temperature\":60}"), types.WithEndFunc(func(msg types.RuleMsg, err error) {
+		assert.Nil(t, err)
+		assert.Equal(t, "true", msg.Metadata.GetValue("alarm"))
+		assert.Equal(t, "TEST_MSG_TYPE", msg.Type)
+		wg.Done()
+	}))
+	chain.OnMsg(types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, types.NewMetadata(), "{\"temperature\":20}"), types.WithEndFunc(func(msg types.RuleMsg, err error) {
+		assert.Nil(t, err)
+		assert.False(t, msg.Metadata.Has("alarm"))
+		wg.Done()
+	}))
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("wait timeout")
+	}
+	time.Sleep(time.Millisecond * 100)
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 4, debugCount)
+}
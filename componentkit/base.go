@@ -0,0 +1,135 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package componentkit 第三方组件开发工具包
+// 提供组件基础结构体（配置解析、${}模板替换、关系路由）、组件一致性测试套件和新组件脚手架生成器，
+// 降低社区开发和发布自定义组件的门槛
+//
+//	type UpperNode struct {
+//		componentkit.Base
+//		config UpperConfiguration
+//	}
+//
+//	func (x *UpperNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+//		return x.Decode(ruleConfig, configuration, &x.config)
+//	}
+package componentkit
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"reflect"
+)
+
+// ErrInvalidConfig 配置结构体参数不是结构体指针
+var ErrInvalidConfig = errors.New("componentkit: config must be a non-nil pointer to struct")
+
+// Validator 组件配置可以实现该接口，Decode解析配置后调用检查配置是否有效
+type Validator interface {
+	Validate() error
+}
+
+// Base 组件基础结构体，嵌入到组件结构体中使用
+// 提供配置解析和默认的Destroy实现，组件只需要实现New、Type、Init和OnMsg
+type Base struct {
+	//RuleConfig 规则引擎配置，Decode时保存
+	RuleConfig types.Config
+}
+
+// Decode 保存规则引擎配置，并把节点配置解析到config，config必须是结构体指针
+// 解析前config中已经设置的字段值作为默认值，如果config实现了Validator接口，解析后调用Validate检查配置
+func (b *Base) Decode(ruleConfig types.Config, configuration types.Configuration, config interface{}) error {
+	b.RuleConfig = ruleConfig
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrInvalidConfig
+	}
+	if err := maps.Map2Struct(configuration, config); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if validator, ok := config.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// Logf 使用规则引擎配置的日志记录器按级别输出日志，Decode之前调用不输出
+func (b *Base) Logf(level string, format string, v ...interface{}) {
+	if b.RuleConfig.Logger == nil {
+		return
+	}
+	types.Logf(b.RuleConfig.Logger, level, format, v...)
+}
+
+// Destroy 默认空实现，组件持有连接等资源时需要覆盖
+func (b *Base) Destroy() {
+}
+
+// Template ${}模板，使用消息元数据替换变量，例如：/api/${deviceId}
+// 不包含变量的模板直接返回原字符串，避免每条消息都做替换
+type Template struct {
+	raw    string
+	hasVar bool
+}
+
+// NewTemplate 创建模板
+func NewTemplate(pattern string) Template {
+	return Template{raw: pattern, hasVar: str.CheckHasVar(pattern)}
+}
+
+// String 返回模板原文
+func (t Template) String() string {
+	return t.raw
+}
+
+// HasVar 模板是否包含${}变量
+func (t Template) HasVar() bool {
+	return t.hasVar
+}
+
+// Execute 使用消息元数据替换模板变量
+func (t Template) Execute(msg types.RuleMsg) string {
+	if !t.hasVar {
+		return t.raw
+	}
+	return str.SprintfDict(t.raw, msg.Metadata.Values())
+}
+
+// Render 使用消息元数据替换pattern中的${}变量
+func Render(pattern string, msg types.RuleMsg) string {
+	return NewTemplate(pattern).Execute(msg)
+}
+
+// TellResult err为空把消息发送到Success链，否则发送到Failure链
+func TellResult(ctx types.RuleContext, msg types.RuleMsg, err error) {
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+}
+
+// TellBool ok为true把消息发送到True链，否则发送到False链
+func TellBool(ctx types.RuleContext, msg types.RuleMsg, ok bool) {
+	if ok {
+		ctx.TellNext(msg, types.True)
+	} else {
+		ctx.TellNext(msg, types.False)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package componentkit
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+)
+
+type testConfiguration struct {
+	Url     string
+	Retries int
+}
+
+func (c *testConfiguration) Validate() error {
+	if c.Url == "" {
+		return errors.New("url can not be empty")
+	}
+	return nil
+}
+
+func TestBaseDecode(t *testing.T) {
+	var base Base
+	config := testConfiguration{Retries: 3}
+	err := base.Decode(types.NewConfig(), types.Configuration{"url": "http://127.0.0.1/${id}"}, &config)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://127.0.0.1/${id}", config.Url)
+	assert.Equal(t, 3, config.Retries)
+	assert.NotNil(t, base.RuleConfig.Logger)
+
+	err = base.Decode(types.NewConfig(), types.Configuration{"url": "a", "retries": "5"}, &config)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, config.Retries)
+
+	err = base.Decode(types.NewConfig(), types.Configuration{}, &testConfiguration{})
+	assert.Equal(t, "url can not be empty", err.Error())
+
+	err = base.Decode(types.NewConfig(), types.Configuration{"retries": "x"}, &config)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, ErrInvalidConfig, base.Decode(types.NewConfig(), nil, config))
+	var nilConfig *testConfiguration
+	assert.Equal(t, ErrInvalidConfig, base.Decode(types.NewConfig(), nil, nilConfig))
+}
+
+func TestTemplate(t *testing.T) {
+	metadata := types.NewMetadata()
+	metadata.PutValue("id", "aa")
+	msg := types.NewMsg(0, "TEST", types.JSON, metadata, "{}")
+
+	tmpl := NewTemplate("/api/${id}")
+	assert.True(t, tmpl.HasVar())
+	assert.Equal(t, "/api/aa", tmpl.Execute(msg))
+	assert.Equal(t, "/api/${id}", tmpl.String())
+
+	tmpl = NewTemplate("/api")
+	assert.False(t, tmpl.HasVar())
+	assert.Equal(t, "/api", tmpl.Execute(msg))
+	assert.Equal(t, "aa-aa", Render("${id}-${id}", msg))
+}
+
+func TestTellHelpers(t *testing.T) {
+	var relations []string
+	ctx := test.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string) {
+		relations = append(relations, relationType)
+	})
+	msg := types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}")
+	TellResult(ctx, msg, nil)
+	TellResult(ctx, msg, errors.New("fail"))
+	TellBool(ctx, msg, true)
+	TellBool(ctx, msg, false)
+	assert.Equal(t, []string{types.Success, types.Failure, types.True, types.False}, relations)
+}
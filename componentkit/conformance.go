@@ -0,0 +1,266 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package componentkit
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// DefaultConformanceTimeout 默认等待OnMsg输出消息的超时时间
+const DefaultConformanceTimeout = 3 * time.Second
+
+// Suite 组件一致性测试用例
+type Suite struct {
+	//Configuration 有效的节点配置
+	Configuration types.Configuration
+	//InvalidConfigurations 无效的节点配置，Init必须返回错误
+	InvalidConfigurations []types.Configuration
+	//Msg 测试消息，默认：TEST类型的JSON消息
+	Msg *types.RuleMsg
+	//Relations OnMsg允许输出的关系，为空不检查
+	Relations []string
+	//Concurrency 并发调用OnMsg的次数，结合-race检查数据竞争，默认：10
+	Concurrency int
+	//Timeout 等待OnMsg输出消息的超时时间，默认：DefaultConformanceTimeout
+	Timeout time.Duration
+	//SkipOnMsg 不检查OnMsg，例如依赖外部服务的组件
+	SkipOnMsg bool
+	//Config 规则引擎配置，默认：rulego.NewConfig()
+	Config *types.Config
+}
+
+// Conformance 检查组件是否符合规则引擎对组件的约定，每项检查作为一个子测试：
+//
+//   - Type 组件类型不能为空且不能包含空白字符
+//   - New 返回同一类型的新实例
+//   - Register 可以注册到组件注册器并通过类型创建实例
+//   - Init 使用有效配置初始化成功，使用无效配置初始化返回错误，空配置不能panic
+//   - OnMsg 每条消息都输出到至少一个关系或者返回错误，支持并发调用
+//   - Destroy 可以重复调用
+//
+// 使用示例：
+//
+//	func TestUpperNodeConformance(t *testing.T) {
+//		componentkit.Conformance(t, &UpperNode{}, componentkit.Suite{
+//			Configuration: types.Configuration{"key": "name"},
+//			Relations:     []string{types.Success},
+//		})
+//	}
+func Conformance(t *testing.T, node types.Node, suite Suite) {
+	t.Helper()
+	if suite.Concurrency <= 0 {
+		suite.Concurrency = 10
+	}
+	if suite.Timeout <= 0 {
+		suite.Timeout = DefaultConformanceTimeout
+	}
+	config := rulego.NewConfig()
+	if suite.Config != nil {
+		config = *suite.Config
+	}
+	t.Run("Type", func(t *testing.T) {
+		nodeType := node.Type()
+		if nodeType == "" {
+			t.Fatal("Type() is empty")
+		}
+		if strings.ContainsAny(nodeType, " \t\r\n") {
+			t.Errorf("Type() %q contains whitespace", nodeType)
+		}
+	})
+	t.Run("New", func(t *testing.T) {
+		instance := node.New()
+		if instance == nil {
+			t.Fatal("New() returned nil")
+		}
+		if reflect.TypeOf(instance) != reflect.TypeOf(node) {
+			t.Errorf("New() returned %T, want %T", instance, node)
+		}
+		if instance.Type() != node.Type() {
+			t.Errorf("New().Type() is %q, want %q", instance.Type(), node.Type())
+		}
+		if reflect.TypeOf(node).Kind() == reflect.Ptr && instance == node {
+			t.Error("New() must return a new instance")
+		}
+	})
+	t.Run("Register", func(t *testing.T) {
+		registry := new(rulego.RuleComponentRegistry)
+		if err := registry.Register(node); err != nil {
+			t.Fatal(err)
+		}
+		instance, err := registry.NewNode(node.Type())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reflect.TypeOf(instance) != reflect.TypeOf(node) {
+			t.Errorf("registry created %T, want %T", instance, node)
+		}
+	})
+	t.Run("Init", func(t *testing.T) {
+		instance := node.New()
+		if err := safeCall(func() error { return instance.Init(config, suite.Configuration) }); err != nil {
+			t.Fatalf("Init() with valid configuration: %v", err)
+		}
+		instance.Destroy()
+		for i, configuration := range suite.InvalidConfigurations {
+			instance = node.New()
+			err := safeCall(func() error { return instance.Init(config, configuration) })
+			if err == nil {
+				t.Errorf("Init() with invalid configuration %d: want error", i)
+				instance.Destroy()
+			} else if errors.Is(err, errPanic) {
+				t.Errorf("Init() with invalid configuration %d: %v", i, err)
+			}
+		}
+		instance = node.New()
+		if err := safeCall(func() error { return instance.Init(config, types.Configuration{}) }); errors.Is(err, errPanic) {
+			t.Errorf("Init() with empty configuration: %v", err)
+		} else if err == nil {
+			instance.Destroy()
+		}
+	})
+	if !suite.SkipOnMsg {
+		t.Run("OnMsg", func(t *testing.T) {
+			instance := node.New()
+			if err := instance.Init(config, suite.Configuration); err != nil {
+				t.Fatalf("Init(): %v", err)
+			}
+			defer instance.Destroy()
+			checkOnMsg(t, config, instance, suite, 1)
+		})
+		t.Run("OnMsgConcurrent", func(t *testing.T) {
+			instance := node.New()
+			if err := instance.Init(config, suite.Configuration); err != nil {
+				t.Fatalf("Init(): %v", err)
+			}
+			defer instance.Destroy()
+			checkOnMsg(t, config, instance, suite, suite.Concurrency)
+		})
+	}
+	t.Run("Destroy", func(t *testing.T) {
+		instance := node.New()
+		if err := instance.Init(config, suite.Configuration); err != nil {
+			t.Fatalf("Init(): %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := safeCall(func() error { instance.Destroy(); return nil }); err != nil {
+				t.Errorf("Destroy() call %d: %v", i+1, err)
+			}
+		}
+	})
+}
+
+var errPanic = errors.New("panic")
+
+// safeCall 执行fn，把panic转换成错误
+func safeCall(fn func() error) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("%w: %v", errPanic, e)
+		}
+	}()
+	return fn()
+}
+
+// checkOnMsg 并发调用n次OnMsg，检查每条消息都在超时时间内输出到允许的关系或者返回错误
+// 回调可能在其他协程或者超时后执行，问题先记录下来，等待结束后统一报告
+func checkOnMsg(t *testing.T, config types.Config, node types.Node, suite Suite, n int) {
+	t.Helper()
+	var mu sync.Mutex
+	var problems []string
+	answered := make(map[string]bool)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	done := func(msgId string, problem string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if problem != "" {
+			problems = append(problems, problem)
+		}
+		if !answered[msgId] {
+			answered[msgId] = true
+			wg.Done()
+		}
+	}
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		var problem string
+		if len(suite.Relations) > 0 && !containsRelation(suite.Relations, relationType) {
+			problem = fmt.Sprintf("OnMsg() routed to unexpected relation %q, want one of %v", relationType, suite.Relations)
+		}
+		done(msg.Id, problem)
+	})
+	for i := 0; i < n; i++ {
+		msg := newSuiteMsg(suite, i)
+		go func() {
+			if err := safeCall(func() error { return node.OnMsg(ctx, msg) }); err != nil {
+				var problem string
+				if errors.Is(err, errPanic) {
+					problem = fmt.Sprintf("OnMsg(): %v", err)
+				}
+				done(msg.Id, problem)
+			}
+		}()
+	}
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	timeout := false
+	select {
+	case <-finished:
+	case <-time.After(suite.Timeout):
+		timeout = true
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, problem := range problems {
+		t.Error(problem)
+	}
+	if timeout {
+		t.Errorf("OnMsg() answered %d of %d messages within %s", len(answered), n, suite.Timeout)
+	}
+}
+
+// newSuiteMsg 创建测试消息，每条消息使用不同的ID
+func newSuiteMsg(suite Suite, i int) types.RuleMsg {
+	var msg types.RuleMsg
+	if suite.Msg != nil {
+		msg = suite.Msg.Copy()
+	} else {
+		msg = types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), `{"temperature":41}`)
+	}
+	msg.Id = fmt.Sprintf("%s-%d", msg.Id, i)
+	return msg
+}
+
+func containsRelation(relations []string, relationType string) bool {
+	for _, item := range relations {
+		if item == relationType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package componentkit
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"strings"
+	"testing"
+)
+
+type upperConfiguration struct {
+	Field string
+}
+
+func (c *upperConfiguration) Validate() error {
+	if c.Field == "" {
+		return errors.New("field can not be empty")
+	}
+	return nil
+}
+
+// upperNode 把元数据字段转换成大写，用于测试Base和Conformance
+type upperNode struct {
+	Base
+	config upperConfiguration
+}
+
+func (x *upperNode) Type() string {
+	return "test/upper"
+}
+
+func (x *upperNode) New() types.Node {
+	return &upperNode{}
+}
+
+func (x *upperNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	x.config = upperConfiguration{Field: "name"}
+	return x.Decode(ruleConfig, configuration, &x.config)
+}
+
+func (x *upperNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	value := msg.Metadata.GetValue(x.config.Field)
+	if value == nil {
+		ctx.TellNext(msg, types.False)
+		return nil
+	}
+	msg.Metadata.PutValue(x.config.Field, strings.ToUpper(value.(string)))
+	ctx.TellNext(msg, types.True)
+	return nil
+}
+
+func TestConformance(t *testing.T) {
+	metadata := types.NewMetadata()
+	metadata.PutValue("name", "lala")
+	msg := types.NewMsg(0, "TEST", types.JSON, metadata, "{}")
+	Conformance(t, &upperNode{}, Suite{
+		Configuration:         types.Configuration{"field": "name"},
+		InvalidConfigurations: []types.Configuration{{"field": ""}},
+		Msg:                   &msg,
+		Relations:             []string{types.True, types.False},
+	})
+}
+
+func TestConformanceSkipOnMsg(t *testing.T) {
+	Conformance(t, &upperNode{}, Suite{SkipOnMsg: true})
+}
+
+func TestSafeCall(t *testing.T) {
+	err := safeCall(func() error {
+		panic("boom")
+	})
+	assert.True(t, errors.Is(err, errPanic))
+	assert.Nil(t, safeCall(func() error { return nil }))
+}
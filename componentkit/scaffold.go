@@ -0,0 +1,277 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package componentkit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// ScaffoldOptions 新组件脚手架选项
+type ScaffoldOptions struct {
+	//Type 组件类型，例如：lala/upper，必填
+	Type string
+	//Package 包名，默认使用组件类型最后一段的小写字母和数字，例如：upper
+	Package string
+	//Name 组件结构体名称，默认使用组件类型最后一段首字母大写加Node，例如：UpperNode
+	Name string
+	//Description 组件描述
+	Description string
+}
+
+// Scaffolding 脚手架生成结果
+type Scaffolding struct {
+	//Package 包名
+	Package string
+	//Name 组件结构体名称
+	Name string
+	//Files 文件名和格式化后的源码
+	Files map[string][]byte
+}
+
+// Scaffold 生成新组件包的源码
+// 生成的组件嵌入Base，包含配置解析、配置检查、${}模板和组件描述，测试文件使用Conformance检查组件
+func Scaffold(opts ScaffoldOptions) (*Scaffolding, error) {
+	if strings.TrimSpace(opts.Type) == "" {
+		return nil, errors.New("component type can not be empty")
+	}
+	if strings.ContainsAny(opts.Type, " \t\r\n\"`\\") {
+		return nil, fmt.Errorf("invalid component type %q", opts.Type)
+	}
+	base := opts.Type[strings.LastIndexAny(opts.Type, "/.:")+1:]
+	if opts.Package == "" {
+		opts.Package = packageName(base)
+	}
+	if !token.IsIdentifier(opts.Package) || token.IsKeyword(opts.Package) {
+		return nil, fmt.Errorf("invalid package name %q", opts.Package)
+	}
+	if opts.Name == "" {
+		opts.Name = exportedName(base) + "Node"
+	}
+	if !token.IsIdentifier(opts.Name) || !token.IsExported(opts.Name) {
+		return nil, fmt.Errorf("invalid node name %q, must be an exported identifier", opts.Name)
+	}
+	opts.Description = strings.Join(strings.Fields(opts.Description), " ")
+	if opts.Description == "" {
+		opts.Description = "TODO 描述组件功能"
+	}
+	files := make(map[string][]byte)
+	for name, tmpl := range map[string]*template.Template{
+		fileName(opts.Name) + ".go":      scaffoldNodeTemplate,
+		fileName(opts.Name) + "_test.go": scaffoldTestTemplate,
+	} {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, opts); err != nil {
+			return nil, err
+		}
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("format %s: %w", name, err)
+		}
+		files[name] = src
+	}
+	return &Scaffolding{Package: opts.Package, Name: opts.Name, Files: files}, nil
+}
+
+// packageName 保留小写字母和数字，数字开头时加上前缀
+func packageName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "node" + name
+	}
+	return name
+}
+
+// exportedName 把-、_分隔的单词转换成首字母大写的驼峰命名
+func exportedName(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if r >= unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "X" + name
+	}
+	return name
+}
+
+// fileName 把驼峰命名转换成下划线分隔的小写文件名，例如：UpperNode -> upper_node
+func fileName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 && !unicode.IsUpper(rune(name[i-1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var scaffoldNodeTemplate = template.Must(template.New("node").Parse(`package {{.Package}}
+
+//规则链节点配置示例：
+//{
+//	"id": "s1",
+//	"type": "{{.Type}}",
+//	"name": "{{.Name}}",
+//	"configuration": {
+//		"key": "processedBy",
+//		"value": "${deviceId}"
+//	}
+//}
+import (
+	"errors"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/componentkit"
+)
+
+func init() {
+	_ = rulego.Registry.Register(&{{.Name}}{})
+}
+
+// {{.Name}}Configuration 节点配置
+type {{.Name}}Configuration struct {
+	//Key 写入的元数据键
+	Key string
+	//Value 写入的元数据值，支持${}占位符替换成消息元数据
+	Value string
+}
+
+// Validate 检查配置
+func (c *{{.Name}}Configuration) Validate() error {
+	if c.Key == "" {
+		return errors.New("key can not be empty")
+	}
+	return nil
+}
+
+// {{.Name}} {{.Description}}
+type {{.Name}} struct {
+	componentkit.Base
+	config {{.Name}}Configuration
+	value  componentkit.Template
+}
+
+// Type 组件类型
+func (x *{{.Name}}) Type() string {
+	return "{{.Type}}"
+}
+
+// Descriptor 组件描述
+func (x *{{.Name}}) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Category:    "{{.Package}}",
+		Description: {{printf "%q" .Description}},
+	}
+}
+
+// New 创建新实例
+func (x *{{.Name}}) New() types.Node {
+	return &{{.Name}}{}
+}
+
+// Init 初始化，配置中没有设置的字段使用默认值
+func (x *{{.Name}}) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	x.config = {{.Name}}Configuration{
+		Key: "processedBy",
+	}
+	if err := x.Decode(ruleConfig, configuration, &x.config); err != nil {
+		return err
+	}
+	x.value = componentkit.NewTemplate(x.config.Value)
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *{{.Name}}) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	msg.Metadata.PutValue(x.config.Key, x.value.Execute(msg))
+	ctx.TellSuccess(msg)
+	return nil
+}
+`))
+
+var scaffoldTestTemplate = template.Must(template.New("test").Parse(`package {{.Package}}
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/componentkit"
+	"github.com/2018yuli/rulego/test"
+	"testing"
+)
+
+func Test{{.Name}}Conformance(t *testing.T) {
+	componentkit.Conformance(t, &{{.Name}}{}, componentkit.Suite{
+		Configuration: types.Configuration{
+			"key":   "device",
+			"value": "${deviceId}",
+		},
+		InvalidConfigurations: []types.Configuration{
+			{"key": ""},
+		},
+		Relations: []string{types.Success},
+	})
+}
+
+func Test{{.Name}}OnMsg(t *testing.T) {
+	node := &{{.Name}}{}
+	err := node.Init(types.NewConfig(), types.Configuration{
+		"key":   "device",
+		"value": "${deviceId}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var relation, device string
+	ctx := test.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		device = msg.Metadata.GetValue("device").(string)
+	})
+	metadata := types.NewMetadata()
+	metadata.PutValue("deviceId", "aa")
+	if err := node.OnMsg(ctx, types.NewMsg(0, "TEST", types.JSON, metadata, "{}")); err != nil {
+		t.Fatal(err)
+	}
+	if relation != types.Success || device != "aa" {
+		t.Errorf("got relation=%s device=%s, want relation=%s device=aa", relation, device, types.Success)
+	}
+}
+`))
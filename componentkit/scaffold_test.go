@@ -0,0 +1,62 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package componentkit
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestScaffold(t *testing.T) {
+	scaffolding, err := Scaffold(ScaffoldOptions{Type: "lala/my-upper", Description: "转换\n\"大写\""})
+	assert.Nil(t, err)
+	assert.Equal(t, "myupper", scaffolding.Package)
+	assert.Equal(t, "MyUpperNode", scaffolding.Name)
+	assert.Equal(t, 2, len(scaffolding.Files))
+
+	src := string(scaffolding.Files["my_upper_node.go"])
+	assert.True(t, strings.HasPrefix(src, "package myupper\n"))
+	assert.True(t, strings.Contains(src, `return "lala/my-upper"`))
+	assert.True(t, strings.Contains(src, `// MyUpperNode 转换 "大写"`))
+	assert.True(t, strings.Contains(src, `Description: "转换 \"大写\""`))
+	assert.True(t, strings.Contains(string(scaffolding.Files["my_upper_node_test.go"]), "componentkit.Conformance(t, &MyUpperNode{}"))
+	for name, content := range scaffolding.Files {
+		_, err := parser.ParseFile(token.NewFileSet(), name, content, 0)
+		assert.Nil(t, err)
+	}
+
+	scaffolding, err = Scaffold(ScaffoldOptions{Type: "httpClient", Package: "client", Name: "HttpNode"})
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(scaffolding.Files["http_node.go"]), "package client\n"))
+
+	scaffolding, err = Scaffold(ScaffoldOptions{Type: "x/1st"})
+	assert.Nil(t, err)
+	assert.Equal(t, "node1st", scaffolding.Package)
+	assert.Equal(t, "X1stNode", scaffolding.Name)
+
+	_, err = Scaffold(ScaffoldOptions{})
+	assert.NotNil(t, err)
+	_, err = Scaffold(ScaffoldOptions{Type: "a b"})
+	assert.NotNil(t, err)
+	_, err = Scaffold(ScaffoldOptions{Type: "upper", Package: "func"})
+	assert.NotNil(t, err)
+	_, err = Scaffold(ScaffoldOptions{Type: "upper", Name: "upperNode"})
+	assert.NotNil(t, err)
+}
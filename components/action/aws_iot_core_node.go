@@ -0,0 +1,151 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/mqtt"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"time"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "awsIotCore",
+//	       "name": "上报遥测数据到AWS IoT Core",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "endpoint": "xx-ats.iot.us-east-1.amazonaws.com",
+//	         "clientId": "gw01",
+//	         "topic": "dt/gw01/${deviceId}",
+//	         "certFile": "./certs/gw01.pem.crt",
+//	         "certKeyFile": "./certs/gw01.private.key"
+//	       }
+//	     }
+func init() {
+	Registry.Add(&AwsIotCoreNode{})
+}
+
+// AwsIotCoreNodeConfiguration 节点配置
+type AwsIotCoreNodeConfiguration struct {
+	//Endpoint 设备数据端点，例如：xx-ats.iot.us-east-1.amazonaws.com
+	Endpoint string
+	//ClientId 客户端ID，一般使用thing name
+	ClientId string
+	//Topic 发布主题，可以使用 ${metaKeyName} 替换元数据中的变量
+	Topic string
+	//QOS 发布的QOS
+	QOS uint8
+	//CAFile 根证书，默认使用系统根证书
+	CAFile string
+	//CertFile X.509证书认证的设备证书
+	CertFile string
+	//CertKeyFile X.509证书认证的设备私钥
+	CertKeyFile string
+	//Region SigV4认证区域
+	Region string
+	//AccessKeyId SigV4认证访问密钥ID
+	AccessKeyId string
+	//SecretAccessKey SigV4认证访问密钥
+	SecretAccessKey string `sensitive:"true"`
+	//SessionToken SigV4认证临时会话token
+	SessionToken string `sensitive:"true"`
+	//MaxReconnectInterval 重连重试间隔
+	MaxReconnectInterval time.Duration
+}
+
+func (x *AwsIotCoreNodeConfiguration) ToAwsIotCoreConfig() mqtt.AwsIotCoreConfig {
+	return mqtt.AwsIotCoreConfig{
+		Endpoint:             x.Endpoint,
+		ClientId:             x.ClientId,
+		CAFile:               x.CAFile,
+		CertFile:             x.CertFile,
+		CertKeyFile:          x.CertKeyFile,
+		Region:               x.Region,
+		AccessKeyId:          x.AccessKeyId,
+		SecretAccessKey:      x.SecretAccessKey,
+		SessionToken:         x.SessionToken,
+		QOS:                  x.QOS,
+		MaxReconnectInterval: x.MaxReconnectInterval,
+	}
+}
+
+// AwsIotCoreNode 把msg.Data作为设备遥测数据发布到AWS IoT Core
+// 支持X.509证书认证和SigV4认证
+// 接收云到设备命令使用`endpoint/mqtt`包的NewAwsIotCore端点
+// 如果发布成功，发送消息到`Success`链, 否则发到`Failure`链
+type AwsIotCoreNode struct {
+	config AwsIotCoreNodeConfiguration
+	client *mqtt.Client
+}
+
+// Type 组件类型
+func (x *AwsIotCoreNode) Type() string {
+	return "awsIotCore"
+}
+
+// Descriptor 组件描述
+func (x *AwsIotCoreNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"aws", "iot", "mqtt", "cloud"},
+		Description: "把消息作为设备遥测数据发布到AWS IoT Core",
+	}
+}
+
+func (x *AwsIotCoreNode) New() types.Node {
+	return &AwsIotCoreNode{config: AwsIotCoreNodeConfiguration{QOS: 1}}
+}
+
+// Init 初始化
+func (x *AwsIotCoreNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err == nil {
+		if x.config.Topic == "" {
+			return errors.New("topic can not empty")
+		}
+		awsConfig := x.config.ToAwsIotCoreConfig()
+		var mqttConfig mqtt.Config
+		var opts []mqtt.ClientOption
+		if mqttConfig, opts, err = awsConfig.ToMqttConfig(); err == nil {
+			x.client, err = mqtt.NewClient(mqttConfig, opts...)
+		}
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *AwsIotCoreNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	topic := str.SprintfDict(x.config.Topic, msg.Metadata.Values())
+	err := x.client.Publish(topic, x.config.QOS, []byte(msg.Data))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (x *AwsIotCoreNode) Destroy() {
+	if x.client != nil {
+		_ = x.client.Close()
+	}
+}
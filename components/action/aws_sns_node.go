@@ -0,0 +1,190 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/aws"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"strconv"
+	"time"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "awsSns",
+//	       "name": "发布到SNS主题",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "region": "us-east-1",
+//	         "topicArn": "arn:aws:sns:us-east-1:123456789012:rulego",
+//	         "attributes": {"deviceId":"${deviceId}"}
+//	       }
+//	     }
+func init() {
+	Registry.Add(&AwsSnsNode{})
+}
+
+// AwsSnsNodeConfiguration 节点配置
+type AwsSnsNodeConfiguration struct {
+	//Region 区域，例如：us-east-1
+	Region string
+	//Endpoint 自定义接口地址，例如本地模拟器，默认使用区域地址
+	Endpoint string
+	//AccessKeyId 访问密钥ID，为空则从环境变量AWS_ACCESS_KEY_ID获取
+	AccessKeyId string
+	//SecretAccessKey 访问密钥，为空则从环境变量AWS_SECRET_ACCESS_KEY获取
+	SecretAccessKey string `sensitive:"true"`
+	//SessionToken 临时会话token
+	SessionToken string `sensitive:"true"`
+	//TopicArn 主题ARN，可以使用 ${metaKeyName} 替换元数据中的变量
+	TopicArn string
+	//Attributes 消息属性，value可以使用 ${metaKeyName} 替换元数据中的变量
+	Attributes map[string]string
+	//MessageGroupId FIFO主题消息组ID，可以使用 ${metaKeyName} 替换元数据中的变量
+	MessageGroupId string
+	//MessageDeduplicationId FIFO主题去重ID，可以使用 ${metaKeyName} 替换元数据中的变量
+	MessageDeduplicationId string
+	//BatchSize 批量发布数量，最大10，小于等于1不开启批量发布
+	BatchSize int
+	//BatchIntervalMs 批量发布最大等待时间，单位毫秒
+	BatchIntervalMs int
+	//TimeoutMs 请求超时，单位毫秒
+	TimeoutMs int
+}
+
+// AwsSnsNode 把msg.Data发布到AWS SNS主题
+// 开启批量发布后消息会缓存，直到达到BatchSize或者BatchIntervalMs再调用PublishBatch发布
+// 如果发布成功，发送消息到`Success`链，metaData.messageId记录消息ID, 否则发到`Failure`链
+type AwsSnsNode struct {
+	config  AwsSnsNodeConfiguration
+	client  *aws.SnsClient
+	batcher *msgBatcher
+}
+
+// Type 组件类型
+func (x *AwsSnsNode) Type() string {
+	return "awsSns"
+}
+
+// Descriptor 组件描述
+func (x *AwsSnsNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"aws", "messaging", "cloud"},
+		Description: "把消息发布到AWS SNS主题",
+	}
+}
+
+func (x *AwsSnsNode) New() types.Node {
+	return &AwsSnsNode{config: AwsSnsNodeConfiguration{
+		BatchIntervalMs: 1000,
+		TimeoutMs:       10000,
+	}}
+}
+
+// Init 初始化
+func (x *AwsSnsNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.TopicArn == "" {
+		return errors.New("topicArn can not empty")
+	}
+	if x.config.Region == "" && x.config.Endpoint == "" {
+		return errors.New("region can not empty")
+	}
+	credentials := aws.NewCredentialsProvider(x.config.AccessKeyId, x.config.SecretAccessKey, x.config.SessionToken)
+	x.client = aws.NewSnsClient(x.config.Region, x.config.Endpoint, credentials, time.Duration(x.config.TimeoutMs)*time.Millisecond)
+	if x.config.BatchSize > 1 {
+		if x.config.BatchSize > aws.MaxBatchSize {
+			x.config.BatchSize = aws.MaxBatchSize
+		}
+		x.batcher = newMsgBatcher(ruleConfig.GetClock(), x.config.BatchSize, time.Duration(x.config.BatchIntervalMs)*time.Millisecond, x.sendBatch)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *AwsSnsNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	if x.batcher != nil {
+		x.batcher.add(ctx, msg)
+		return nil
+	}
+	topicArn, snsMsg := x.toSnsMessage(msg)
+	messageId, err := x.client.Publish(ctx.GetContext(), topicArn, snsMsg)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		msg.Metadata.PutValue("messageId", messageId)
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁，发布缓存中的消息
+func (x *AwsSnsNode) Destroy() {
+	if x.batcher != nil {
+		x.batcher.flush()
+	}
+}
+
+func (x *AwsSnsNode) toSnsMessage(msg types.RuleMsg) (string, aws.Message) {
+	metaData := msg.Metadata.Values()
+	attributes := make(map[string]string, len(x.config.Attributes))
+	for k, v := range x.config.Attributes {
+		attributes[str.SprintfDict(k, metaData)] = str.SprintfDict(v, metaData)
+	}
+	return str.SprintfDict(x.config.TopicArn, metaData), aws.Message{
+		Body:            msg.Data,
+		Attributes:      attributes,
+		GroupId:         str.SprintfDict(x.config.MessageGroupId, metaData),
+		DeduplicationId: str.SprintfDict(x.config.MessageDeduplicationId, metaData),
+	}
+}
+
+// sendBatch 按照主题分组批量发布
+func (x *AwsSnsNode) sendBatch(items []*batchItem) {
+	var topicArns []string
+	groups := make(map[string][]*batchItem)
+	msgs := make(map[*batchItem]aws.Message, len(items))
+	for _, item := range items {
+		topicArn, snsMsg := x.toSnsMessage(item.msg)
+		if _, ok := groups[topicArn]; !ok {
+			topicArns = append(topicArns, topicArn)
+		}
+		groups[topicArn] = append(groups[topicArn], item)
+		msgs[item] = snsMsg
+	}
+	for _, topicArn := range topicArns {
+		for _, chunk := range chunkBatchItems(groups[topicArn], aws.MaxBatchSize) {
+			var entries []aws.Message
+			for i, item := range chunk {
+				snsMsg := msgs[item]
+				snsMsg.Id = strconv.Itoa(i)
+				entries = append(entries, snsMsg)
+			}
+			result, err := x.client.PublishBatch(context.Background(), topicArn, entries)
+			setBatchResult(chunk, result, err)
+		}
+	}
+}
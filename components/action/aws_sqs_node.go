@@ -0,0 +1,207 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/aws"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"strconv"
+	"time"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "awsSqs",
+//	       "name": "发送到SQS队列",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "region": "us-east-1",
+//	         "queueUrl": "https://sqs.us-east-1.amazonaws.com/123456789012/rulego",
+//	         "attributes": {"deviceId":"${deviceId}"},
+//	         "batchSize": 10
+//	       }
+//	     }
+func init() {
+	Registry.Add(&AwsSqsNode{})
+}
+
+// AwsSqsNodeConfiguration 节点配置
+type AwsSqsNodeConfiguration struct {
+	//Region 区域，例如：us-east-1
+	Region string
+	//Endpoint 自定义接口地址，例如本地模拟器，默认使用区域地址
+	Endpoint string
+	//AccessKeyId 访问密钥ID，为空则从环境变量AWS_ACCESS_KEY_ID获取
+	AccessKeyId string
+	//SecretAccessKey 访问密钥，为空则从环境变量AWS_SECRET_ACCESS_KEY获取
+	SecretAccessKey string `sensitive:"true"`
+	//SessionToken 临时会话token
+	SessionToken string `sensitive:"true"`
+	//QueueUrl 队列地址，可以使用 ${metaKeyName} 替换元数据中的变量
+	QueueUrl string
+	//Attributes 消息属性，value可以使用 ${metaKeyName} 替换元数据中的变量
+	Attributes map[string]string
+	//MessageGroupId FIFO队列消息组ID，可以使用 ${metaKeyName} 替换元数据中的变量
+	MessageGroupId string
+	//MessageDeduplicationId FIFO队列去重ID，可以使用 ${metaKeyName} 替换元数据中的变量
+	MessageDeduplicationId string
+	//BatchSize 批量发送数量，最大10，小于等于1不开启批量发送
+	BatchSize int
+	//BatchIntervalMs 批量发送最大等待时间，单位毫秒
+	BatchIntervalMs int
+	//TimeoutMs 请求超时，单位毫秒
+	TimeoutMs int
+}
+
+// AwsSqsNode 把msg.Data发送到AWS SQS队列
+// 开启批量发送后消息会缓存，直到达到BatchSize或者BatchIntervalMs再调用SendMessageBatch发送
+// 如果发送成功，发送消息到`Success`链，metaData.messageId记录消息ID, 否则发到`Failure`链
+type AwsSqsNode struct {
+	config  AwsSqsNodeConfiguration
+	client  *aws.SqsClient
+	batcher *msgBatcher
+}
+
+// Type 组件类型
+func (x *AwsSqsNode) Type() string {
+	return "awsSqs"
+}
+
+// Descriptor 组件描述
+func (x *AwsSqsNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"aws", "queue", "cloud"},
+		Description: "把消息发送到AWS SQS队列",
+	}
+}
+
+func (x *AwsSqsNode) New() types.Node {
+	return &AwsSqsNode{config: AwsSqsNodeConfiguration{
+		BatchIntervalMs: 1000,
+		TimeoutMs:       10000,
+	}}
+}
+
+// Init 初始化
+func (x *AwsSqsNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.QueueUrl == "" {
+		return errors.New("queueUrl can not empty")
+	}
+	if x.config.Region == "" && x.config.Endpoint == "" {
+		return errors.New("region can not empty")
+	}
+	credentials := aws.NewCredentialsProvider(x.config.AccessKeyId, x.config.SecretAccessKey, x.config.SessionToken)
+	x.client = aws.NewSqsClient(x.config.Region, x.config.Endpoint, credentials, time.Duration(x.config.TimeoutMs)*time.Millisecond)
+	if x.config.BatchSize > 1 {
+		if x.config.BatchSize > aws.MaxBatchSize {
+			x.config.BatchSize = aws.MaxBatchSize
+		}
+		x.batcher = newMsgBatcher(ruleConfig.GetClock(), x.config.BatchSize, time.Duration(x.config.BatchIntervalMs)*time.Millisecond, x.sendBatch)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *AwsSqsNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	if x.batcher != nil {
+		x.batcher.add(ctx, msg)
+		return nil
+	}
+	queueUrl, sqsMsg := x.toSqsMessage(msg)
+	messageId, err := x.client.SendMessage(ctx.GetContext(), queueUrl, sqsMsg)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		msg.Metadata.PutValue("messageId", messageId)
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁，发送缓存中的消息
+func (x *AwsSqsNode) Destroy() {
+	if x.batcher != nil {
+		x.batcher.flush()
+	}
+}
+
+func (x *AwsSqsNode) toSqsMessage(msg types.RuleMsg) (string, aws.Message) {
+	metaData := msg.Metadata.Values()
+	attributes := make(map[string]string, len(x.config.Attributes))
+	for k, v := range x.config.Attributes {
+		attributes[str.SprintfDict(k, metaData)] = str.SprintfDict(v, metaData)
+	}
+	return str.SprintfDict(x.config.QueueUrl, metaData), aws.Message{
+		Body:            msg.Data,
+		Attributes:      attributes,
+		GroupId:         str.SprintfDict(x.config.MessageGroupId, metaData),
+		DeduplicationId: str.SprintfDict(x.config.MessageDeduplicationId, metaData),
+	}
+}
+
+// sendBatch 按照队列分组批量发送
+func (x *AwsSqsNode) sendBatch(items []*batchItem) {
+	var queueUrls []string
+	groups := make(map[string][]*batchItem)
+	msgs := make(map[*batchItem]aws.Message, len(items))
+	for _, item := range items {
+		queueUrl, sqsMsg := x.toSqsMessage(item.msg)
+		if _, ok := groups[queueUrl]; !ok {
+			queueUrls = append(queueUrls, queueUrl)
+		}
+		groups[queueUrl] = append(groups[queueUrl], item)
+		msgs[item] = sqsMsg
+	}
+	for _, queueUrl := range queueUrls {
+		for _, chunk := range chunkBatchItems(groups[queueUrl], aws.MaxBatchSize) {
+			var entries []aws.Message
+			for i, item := range chunk {
+				sqsMsg := msgs[item]
+				sqsMsg.Id = strconv.Itoa(i)
+				entries = append(entries, sqsMsg)
+			}
+			result, err := x.client.SendMessageBatch(context.Background(), queueUrl, entries)
+			setBatchResult(chunk, result, err)
+		}
+	}
+}
+
+// setBatchResult 根据批量发送结果设置每条消息的发送结果，批次内ID为消息在批次中的下标
+func setBatchResult(chunk []*batchItem, result aws.BatchResult, err error) {
+	for i, item := range chunk {
+		id := strconv.Itoa(i)
+		if err != nil {
+			item.err = err
+		} else if itemErr, ok := result.Failed[id]; ok {
+			item.err = itemErr
+		} else if messageId, ok := result.Successful[id]; ok {
+			item.msg.Metadata.PutValue("messageId", messageId)
+		} else {
+			item.err = errors.New("no result for batch entry " + id)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAwsSqsNodeOnMsg(t *testing.T) {
+	var requestCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requestCount, 1)
+		_ = r.ParseForm()
+		assert.True(t, strings.HasSuffix(r.Form.Get("QueueUrl"), "/queue/aa"))
+		switch r.Form.Get("Action") {
+		case "SendMessage":
+			assert.Equal(t, "aa", r.Form.Get("MessageAttribute.1.Value.StringValue"))
+			_, _ = w.Write([]byte(`<SendMessageResponse><SendMessageResult><MessageId>m1</MessageId></SendMessageResult></SendMessageResponse>`))
+		case "SendMessageBatch":
+			_, _ = w.Write([]byte(`<SendMessageBatchResponse><SendMessageBatchResult>
+<SendMessageBatchResultEntry><Id>0</Id><MessageId>m1</MessageId></SendMessageBatchResultEntry>
+<BatchResultErrorEntry><Id>1</Id><Code>InvalidParameterValue</Code><Message>bad</Message></BatchResultErrorEntry>
+</SendMessageBatchResult></SendMessageBatchResponse>`))
+		}
+	}))
+	defer server.Close()
+
+	config := types.NewConfig()
+	configuration := types.Configuration{
+		"region":          "us-east-1",
+		"endpoint":        server.URL,
+		"accessKeyId":     "ak",
+		"secretAccessKey": "sk",
+		"queueUrl":        server.URL + "/queue/${deviceId}",
+		"attributes":      map[string]string{"deviceId": "${deviceId}"},
+	}
+	node := (&AwsSqsNode{}).New()
+	err := node.Init(config, configuration)
+	assert.Nil(t, err)
+
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "m1", msg.Metadata.GetValue("messageId"))
+	})
+	msg := ctx.NewMsg("TEST_MSG_TYPE_AA", metaData, "{\"temperature\":41}")
+	err = node.OnMsg(ctx, msg)
+	assert.Nil(t, err)
+
+	//批量发送
+	configuration["batchSize"] = 2
+	configuration["batchIntervalMs"] = 60000
+	atomic.StoreInt32(&requestCount, 0)
+	node = (&AwsSqsNode{}).New()
+	err = node.Init(config, configuration)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	var successCount, failureCount int32
+	ctx = test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		if relationType == types.Success {
+			atomic.AddInt32(&successCount, 1)
+		} else {
+			atomic.AddInt32(&failureCount, 1)
+		}
+		wg.Done()
+	})
+	_ = node.OnMsg(ctx, ctx.NewMsg("TEST_MSG_TYPE_AA", metaData.Copy(), "a"))
+	_ = node.OnMsg(ctx, ctx.NewMsg("TEST_MSG_TYPE_AA", metaData.Copy(), "b"))
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requestCount))
+	assert.Equal(t, int32(1), successCount)
+	assert.Equal(t, int32(1), failureCount)
+
+	//达到间隔时间发送
+	configuration["batchIntervalMs"] = 50
+	node = (&AwsSqsNode{}).New()
+	_ = node.Init(config, configuration)
+	wg.Add(1)
+	ctx = test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Success, relationType)
+		wg.Done()
+	})
+	start := time.Now()
+	_ = node.OnMsg(ctx, ctx.NewMsg("TEST_MSG_TYPE_AA", metaData, "a"))
+	wg.Wait()
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	//缺少队列地址
+	delete(configuration, "queueUrl")
+	err = (&AwsSqsNode{}).New().Init(config, configuration)
+	assert.NotNil(t, err)
+}
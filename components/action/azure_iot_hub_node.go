@@ -0,0 +1,150 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/mqtt"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"time"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "azureIotHub",
+//	       "name": "上报遥测数据到Azure IoT Hub",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "connectionString": "HostName=xx.azure-devices.net;DeviceId=gw01;SharedAccessKey=xx",
+//	         "properties": {"deviceId":"${deviceId}"}
+//	       }
+//	     }
+func init() {
+	Registry.Add(&AzureIotHubNode{})
+}
+
+// AzureIotHubNodeConfiguration 节点配置
+type AzureIotHubNodeConfiguration struct {
+	//ConnectionString 设备连接字符串，格式：HostName=xx.azure-devices.net;DeviceId=xx;SharedAccessKey=xx
+	ConnectionString string `sensitive:"true"`
+	//HostName IoT Hub 主机名
+	HostName string
+	//DeviceId 设备ID
+	DeviceId string
+	//SharedAccessKey 设备对称密钥
+	SharedAccessKey string `sensitive:"true"`
+	//TokenTtl SAS token 有效期，默认1小时
+	TokenTtl time.Duration
+	//CertFile X.509证书认证的设备证书
+	CertFile string
+	//CertKeyFile X.509证书认证的设备私钥
+	CertKeyFile string
+	//CAFile 根证书，默认使用系统根证书
+	CAFile string
+	//QOS 发布的QOS
+	QOS uint8
+	//Properties 消息属性，value可以使用 ${metaKeyName} 替换元数据中的变量
+	Properties map[string]string
+	//MaxReconnectInterval 重连重试间隔
+	MaxReconnectInterval time.Duration
+}
+
+func (x *AzureIotHubNodeConfiguration) ToAzureIotHubConfig() mqtt.AzureIotHubConfig {
+	return mqtt.AzureIotHubConfig{
+		ConnectionString:     x.ConnectionString,
+		HostName:             x.HostName,
+		DeviceId:             x.DeviceId,
+		SharedAccessKey:      x.SharedAccessKey,
+		TokenTtl:             x.TokenTtl,
+		CertFile:             x.CertFile,
+		CertKeyFile:          x.CertKeyFile,
+		CAFile:               x.CAFile,
+		QOS:                  x.QOS,
+		MaxReconnectInterval: x.MaxReconnectInterval,
+	}
+}
+
+// AzureIotHubNode 把msg.Data作为设备遥测数据发布到Azure IoT Hub
+// 接收云到设备命令使用`endpoint/mqtt`包的NewAzureIotHub端点
+// 如果发布成功，发送消息到`Success`链, 否则发到`Failure`链
+type AzureIotHubNode struct {
+	config    AzureIotHubNodeConfiguration
+	hubConfig mqtt.AzureIotHubConfig
+	client    *mqtt.Client
+}
+
+// Type 组件类型
+func (x *AzureIotHubNode) Type() string {
+	return "azureIotHub"
+}
+
+// Descriptor 组件描述
+func (x *AzureIotHubNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"azure", "iot", "mqtt", "cloud"},
+		Description: "把消息作为设备遥测数据发布到Azure IoT Hub",
+	}
+}
+
+func (x *AzureIotHubNode) New() types.Node {
+	return &AzureIotHubNode{config: AzureIotHubNodeConfiguration{QOS: 1}}
+}
+
+// Init 初始化
+func (x *AzureIotHubNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err == nil {
+		x.hubConfig = x.config.ToAzureIotHubConfig()
+		var mqttConfig mqtt.Config
+		var opts []mqtt.ClientOption
+		if mqttConfig, opts, err = x.hubConfig.ToMqttConfig(); err == nil {
+			x.client, err = mqtt.NewClient(mqttConfig, opts...)
+		}
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *AzureIotHubNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	metaData := msg.Metadata.Values()
+	properties := make(map[string]string, len(x.config.Properties)+2)
+	for k, v := range x.config.Properties {
+		properties[str.SprintfDict(k, metaData)] = str.SprintfDict(v, metaData)
+	}
+	if msg.DataType == types.JSON {
+		//系统属性：内容类型和编码，便于IoT Hub消息路由按消息体查询
+		properties["$.ct"] = "application/json"
+		properties["$.ce"] = "utf-8"
+	}
+	err := x.client.Publish(x.hubConfig.TelemetryTopic(properties), x.config.QOS, []byte(msg.Data))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (x *AzureIotHubNode) Destroy() {
+	if x.client != nil {
+		_ = x.client.Close()
+	}
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/maps"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	//BatchModeBatch 缓存消息，合并成一条数组payload的消息
+	BatchModeBatch = "batch"
+	//BatchModeUnbatch 把数组payload的消息拆分成多条消息
+	BatchModeUnbatch = "unbatch"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "batch",
+//	       "name": "批量合并",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "batchSize": 100,
+//	         "maxBytes": 65536,
+//	         "intervalMs": 1000
+//	       }
+//	     }
+func init() {
+	Registry.Add(&BatchNode{})
+}
+
+// BatchNodeConfiguration 节点配置
+type BatchNodeConfiguration struct {
+	//Mode 模式：batch、unbatch，默认：batch
+	Mode string
+	//BatchSize 缓存消息数量达到该值则发送，默认100
+	BatchSize int
+	//MaxBytes 缓存消息payload总字节数达到该值则发送，0不限制
+	MaxBytes int
+	//IntervalMs 距离第一条缓存消息超过该时间则发送，单位毫秒，默认1000
+	IntervalMs int
+}
+
+// BatchNode 批量合并/拆分消息，使任意节点都可以批量处理消息
+// batch模式：缓存消息，数量、字节数或者时间任意一个条件满足则合并成一条消息发送到`Success`链，
+// payload为JSON数组，JSON类型的消息作为JSON值，其他类型的消息作为字符串，元数据使用最后一条消息的元数据，并增加batchSize
+// unbatch模式：把JSON数组payload的每个元素拆分成一条消息发送到`Success`链，元数据增加batchIndex，payload不是JSON数组则发到`Failure`链
+type BatchNode struct {
+	config BatchNodeConfiguration
+	clock  clock.Clock
+	items  []types.RuleMsg
+	bytes  int
+	ctx    types.RuleContext
+	timer  clock.Timer
+	lock   sync.Mutex
+}
+
+// Type 组件类型
+func (x *BatchNode) Type() string {
+	return "batch"
+}
+
+// Descriptor 组件描述
+func (x *BatchNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"batch", "unbatch", "buffer"},
+		Description: "把多条消息合并成数组payload的消息，或者把数组payload拆分成多条消息",
+	}
+}
+
+func (x *BatchNode) New() types.Node {
+	return &BatchNode{config: BatchNodeConfiguration{Mode: BatchModeBatch, BatchSize: 100, IntervalMs: 1000}}
+}
+
+// Init 初始化
+func (x *BatchNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	switch x.config.Mode {
+	case BatchModeBatch:
+		if x.config.BatchSize <= 0 && x.config.MaxBytes <= 0 && x.config.IntervalMs <= 0 {
+			return errors.New("one of batchSize, maxBytes, intervalMs must be greater than 0")
+		}
+	case BatchModeUnbatch:
+	default:
+		return errors.New("unsupported mode:" + x.config.Mode)
+	}
+	x.clock = ruleConfig.GetClock()
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *BatchNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	if x.config.Mode == BatchModeUnbatch {
+		return x.unbatch(ctx, msg)
+	}
+	x.lock.Lock()
+	x.items = append(x.items, msg.Copy())
+	x.bytes += len(msg.Data)
+	x.ctx = ctx
+	if (x.config.BatchSize > 0 && len(x.items) >= x.config.BatchSize) ||
+		(x.config.MaxBytes > 0 && x.bytes >= x.config.MaxBytes) {
+		items := x.take()
+		x.lock.Unlock()
+		return x.send(ctx, items)
+	}
+	if x.timer == nil && x.config.IntervalMs > 0 {
+		x.timer = x.clock.AfterFunc(time.Duration(x.config.IntervalMs)*time.Millisecond, x.flush)
+	}
+	x.lock.Unlock()
+	return nil
+}
+
+// Destroy 销毁，发送缓存的消息
+func (x *BatchNode) Destroy() {
+	x.flush()
+}
+
+// flush 发送缓存的所有消息
+func (x *BatchNode) flush() {
+	x.lock.Lock()
+	ctx := x.ctx
+	items := x.take()
+	x.lock.Unlock()
+	_ = x.send(ctx, items)
+}
+
+// take 取出缓存的消息，调用方需要持有锁
+func (x *BatchNode) take() []types.RuleMsg {
+	if x.timer != nil {
+		x.timer.Stop()
+		x.timer = nil
+	}
+	items := x.items
+	x.items = nil
+	x.bytes = 0
+	return items
+}
+
+// send 合并消息并发送
+func (x *BatchNode) send(ctx types.RuleContext, items []types.RuleMsg) error {
+	if len(items) == 0 {
+		return nil
+	}
+	values := make([]interface{}, len(items))
+	for i, item := range items {
+		if item.DataType == types.JSON && json.Valid([]byte(item.Data)) {
+			values[i] = json.RawMessage(item.Data)
+		} else {
+			values[i] = item.Data
+		}
+	}
+	last := items[len(items)-1]
+	data, err := json.Marshal(values)
+	if err != nil {
+		ctx.TellFailure(last, err)
+		return err
+	}
+	metaData := last.Metadata.Copy()
+	metaData.PutValue("batchSize", strconv.Itoa(len(items)))
+	batch := ctx.NewMsg(items[0].Type, metaData, string(data))
+	batch.DataType = types.JSON
+	ctx.TellSuccess(batch)
+	return nil
+}
+
+// unbatch 拆分数组payload
+func (x *BatchNode) unbatch(ctx types.RuleContext, msg types.RuleMsg) error {
+	var values []json.RawMessage
+	if err := json.Unmarshal([]byte(msg.Data), &values); err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	for i, value := range values {
+		metaData := msg.Metadata.Copy()
+		metaData.PutValue("batchIndex", strconv.Itoa(i))
+		item := ctx.NewMsg(msg.Type, metaData, string(value))
+		item.DataType = types.JSON
+		var text string
+		if json.Unmarshal(value, &text) == nil {
+			item.Data = text
+			item.DataType = types.TEXT
+		}
+		ctx.TellSuccess(item)
+	}
+	return nil
+}
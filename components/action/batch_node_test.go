@@ -0,0 +1,109 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"testing"
+	"time"
+)
+
+func TestBatchNode(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	config := types.NewConfig(types.WithClock(vc))
+	node := (&BatchNode{}).New().(*BatchNode)
+	err := node.Init(config, types.Configuration{"batchSize": 3, "maxBytes": 20, "intervalMs": 1000})
+	assert.Nil(t, err)
+
+	var batches []types.RuleMsg
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Success, relationType)
+		batches = append(batches, msg)
+	})
+	send := func(data string, dataType types.DataType) {
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", data)
+		msg := ctx.NewMsg("TELEMETRY", metaData, data)
+		msg.DataType = dataType
+		_ = node.OnMsg(ctx, msg)
+	}
+
+	//数量触发
+	send(`{"a":1}`, types.JSON)
+	send(`aa`, types.TEXT)
+	assert.Equal(t, 0, len(batches))
+	send(`[1]`, types.JSON)
+	assert.Equal(t, 1, len(batches))
+	assert.Equal(t, `[{"a":1},"aa",[1]]`, batches[0].Data)
+	assert.Equal(t, types.JSON, batches[0].DataType)
+	assert.Equal(t, "TELEMETRY", batches[0].Type)
+	assert.Equal(t, "3", batches[0].Metadata.GetValue("batchSize"))
+	assert.Equal(t, "[1]", batches[0].Metadata.GetValue("deviceId"))
+
+	//时间触发
+	send(`1`, types.JSON)
+	vc.Advance(999 * time.Millisecond)
+	assert.Equal(t, 1, len(batches))
+	vc.Advance(time.Millisecond)
+	assert.Equal(t, 2, len(batches))
+	assert.Equal(t, `[1]`, batches[1].Data)
+	assert.Equal(t, 0, vc.Pending())
+
+	//字节数触发
+	send(`0123456789`, types.TEXT)
+	send(`0123456789`, types.TEXT)
+	assert.Equal(t, 3, len(batches))
+	assert.Equal(t, `["0123456789","0123456789"]`, batches[2].Data)
+
+	//销毁时发送缓存的消息
+	send(`x`, types.TEXT)
+	node.Destroy()
+	assert.Equal(t, 4, len(batches))
+	assert.Equal(t, `["x"]`, batches[3].Data)
+}
+
+func TestBatchNodeUnbatch(t *testing.T) {
+	config := types.NewConfig()
+	node := (&BatchNode{}).New().(*BatchNode)
+	err := node.Init(config, types.Configuration{"mode": BatchModeUnbatch})
+	assert.Nil(t, err)
+
+	var msgs []types.RuleMsg
+	var relation string
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		msgs = append(msgs, msg)
+	})
+	err = node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", types.NewMetadata(), `[{"a":1},"aa",2]`))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(msgs))
+	assert.Equal(t, `{"a":1}`, msgs[0].Data)
+	assert.Equal(t, types.JSON, msgs[0].DataType)
+	assert.Equal(t, `aa`, msgs[1].Data)
+	assert.Equal(t, types.TEXT, msgs[1].DataType)
+	assert.Equal(t, "2", msgs[2].Metadata.GetValue("batchIndex"))
+
+	err = node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", types.NewMetadata(), `{"a":1}`))
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relation)
+
+	err = (&BatchNode{}).New().Init(config, types.Configuration{"mode": "unknown"})
+	assert.NotNil(t, err)
+}
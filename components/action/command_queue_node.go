@@ -0,0 +1,223 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/kv"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"strconv"
+	"sync"
+)
+
+const (
+	//CommandQueueOperationEnqueue 把命令放入队列
+	CommandQueueOperationEnqueue = "enqueue"
+	//CommandQueueOperationFlush 取出队列所有命令
+	CommandQueueOperationFlush = "flush"
+	//CommandQueueRelation 队列中取出的命令消息的关系类型
+	CommandQueueRelation = "Command"
+	//commandQueueKeyPrefix 队列在key/value存储中的key前缀
+	commandQueueKeyPrefix = "commandQueue:"
+)
+
+// commandQueueLock 同一个进程的所有命令队列节点共享，保证入队和取出的原子性
+var commandQueueLock sync.Mutex
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "commandQueue",
+//	       "name": "缓存离线设备命令",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "operation": "enqueue",
+//	         "key": "${deviceId}",
+//	         "maxSize": 100,
+//	         "ttlMs": 86400000
+//	       }
+//	     }
+func init() {
+	Registry.Add(&CommandQueueNode{})
+}
+
+// CommandQueueNodeConfiguration 节点配置
+type CommandQueueNodeConfiguration struct {
+	//Operation 操作：enqueue、flush，默认：enqueue
+	Operation string
+	//Key 队列key，一般是设备ID，可以使用 ${metaKeyName} 替换元数据中的变量
+	Key string
+	//MaxSize 队列最大长度，队列满后入队失败，默认100
+	MaxSize int
+	//TtlMs 命令有效期，单位毫秒，过期的命令取出时丢弃，0表示永不过期
+	TtlMs int64
+	//Namespace 命名空间，可以使用 ${metaKeyName} 替换元数据中的变量，默认使用规则引擎配置的租户：Config.Tenant
+	Namespace string
+}
+
+// queuedCommand 队列中的命令
+type queuedCommand struct {
+	Ts       int64                  `json:"ts"`
+	Type     string                 `json:"type"`
+	DataType types.DataType         `json:"dataType"`
+	Data     string                 `json:"data"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// CommandQueueNode 按key缓存设备离线期间的下行命令，设备下一次上报或者上线时取出
+// 队列保存在跨规则链共享的key/value存储(`Config.KVStore`)，使用文件或者Redis存储时重启后不丢失
+// enqueue操作：把消息放入队列，元数据增加queueSize，发送到`Success`链，队列满发送到`Failure`链
+// flush操作：队列中每条未过期命令作为新消息发送到`Command`链，然后把当前消息发送到`Success`链
+type CommandQueueNode struct {
+	config CommandQueueNodeConfiguration
+	store  kv.Store
+	clock  clock.Clock
+	tenant string
+}
+
+// Type 组件类型
+func (x *CommandQueueNode) Type() string {
+	return "commandQueue"
+}
+
+// Descriptor 组件描述
+func (x *CommandQueueNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"queue", "command", "offline", "iot"},
+		Description: "按设备缓存离线期间的下行命令，上线后取出",
+	}
+}
+
+func (x *CommandQueueNode) New() types.Node {
+	return &CommandQueueNode{config: CommandQueueNodeConfiguration{
+		Operation: CommandQueueOperationEnqueue,
+		MaxSize:   100,
+	}}
+}
+
+// Init 初始化
+func (x *CommandQueueNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Key == "" {
+		return errors.New("key can not empty")
+	}
+	if x.config.Operation != CommandQueueOperationEnqueue && x.config.Operation != CommandQueueOperationFlush {
+		return errors.New("unsupported operation:" + x.config.Operation)
+	}
+	if x.config.MaxSize <= 0 {
+		x.config.MaxSize = 100
+	}
+	x.store = ruleConfig.GetKVStore()
+	x.clock = ruleConfig.GetClock()
+	x.tenant = ruleConfig.Tenant
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *CommandQueueNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	metaData := msg.Metadata.Values()
+	namespace := x.tenant
+	if x.config.Namespace != "" {
+		namespace = str.SprintfDict(x.config.Namespace, metaData)
+	}
+	key := commandQueueKeyPrefix + str.SprintfDict(x.config.Key, metaData)
+	var err error
+	if x.config.Operation == CommandQueueOperationFlush {
+		err = x.flush(ctx, namespace, key)
+	} else {
+		var size int
+		if size, err = x.enqueue(namespace, key, msg); err == nil {
+			msg.Metadata.PutValue("queueSize", strconv.Itoa(size))
+		}
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (x *CommandQueueNode) Destroy() {
+}
+
+// enqueue 命令入队，返回入队后的队列长度
+func (x *CommandQueueNode) enqueue(namespace, key string, msg types.RuleMsg) (int, error) {
+	commandQueueLock.Lock()
+	defer commandQueueLock.Unlock()
+	commands, err := x.load(namespace, key)
+	if err != nil {
+		return 0, err
+	}
+	if len(commands) >= x.config.MaxSize {
+		return len(commands), errors.New("command queue is full")
+	}
+	commands = append(commands, queuedCommand{
+		Ts:       x.clock.Now().UnixMilli(),
+		Type:     msg.Type,
+		DataType: msg.DataType,
+		Data:     msg.Data,
+		Metadata: msg.Metadata.Values(),
+	})
+	content, err := json.Marshal(commands)
+	if err != nil {
+		return 0, err
+	}
+	return len(commands), x.store.Put(namespace, key, string(content), 0)
+}
+
+// flush 取出队列所有命令，按入队顺序发送到`Command`链
+func (x *CommandQueueNode) flush(ctx types.RuleContext, namespace, key string) error {
+	commandQueueLock.Lock()
+	commands, err := x.load(namespace, key)
+	if err == nil && len(commands) > 0 {
+		err = x.store.Delete(namespace, key)
+	}
+	commandQueueLock.Unlock()
+	if err != nil {
+		return err
+	}
+	now := x.clock.Now().UnixMilli()
+	for _, item := range commands {
+		if x.config.TtlMs > 0 && now-item.Ts >= x.config.TtlMs {
+			continue
+		}
+		command := types.NewMsg(item.Ts, item.Type, item.DataType, types.BuildMetadata(item.Metadata), item.Data)
+		ctx.TellNext(command, CommandQueueRelation)
+	}
+	return nil
+}
+
+// load 读取队列，调用方需要持有锁
+func (x *CommandQueueNode) load(namespace, key string) ([]queuedCommand, error) {
+	value, ok, err := x.store.Get(namespace, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var commands []queuedCommand
+	err = json.Unmarshal([]byte(value), &commands)
+	return commands, err
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/kv"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCommandQueueNodeOnMsg(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	file := filepath.Join(t.TempDir(), "kv.json")
+	store, err := kv.NewFile(file, vc)
+	assert.Nil(t, err)
+	config := types.NewConfig(types.WithClock(vc), types.WithKVStore(store))
+
+	enqueueNode := (&CommandQueueNode{}).New()
+	err = enqueueNode.Init(config, types.Configuration{"key": "${deviceId}", "maxSize": 2, "ttlMs": 60000})
+	assert.Nil(t, err)
+
+	var relations []string
+	var msgs []types.RuleMsg
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relations = append(relations, relationType)
+		msgs = append(msgs, msg)
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	assert.Nil(t, enqueueNode.OnMsg(ctx, ctx.NewMsg("SET_INTERVAL", metaData, "{\"interval\":10}")))
+	vc.Advance(time.Second * 30)
+	assert.Nil(t, enqueueNode.OnMsg(ctx, ctx.NewMsg("REBOOT", metaData, "{}")))
+	assert.Equal(t, "2", msgs[1].Metadata.GetValue("queueSize"))
+	//队列满
+	assert.NotNil(t, enqueueNode.OnMsg(ctx, ctx.NewMsg("REBOOT", metaData, "{}")))
+	assert.Equal(t, []string{types.Success, types.Success, types.Failure}, relations)
+
+	//重启后从文件恢复，第一条命令已经过期
+	vc.Advance(time.Second * 30)
+	store, err = kv.NewFile(file, vc)
+	assert.Nil(t, err)
+	config.KVStore = store
+	flushNode := (&CommandQueueNode{}).New()
+	err = flushNode.Init(config, types.Configuration{"key": "${deviceId}", "operation": "flush", "ttlMs": 60000})
+	assert.Nil(t, err)
+	relations, msgs = nil, nil
+	assert.Nil(t, flushNode.OnMsg(ctx, ctx.NewMsg("POST_TELEMETRY", metaData, "{}")))
+	assert.Equal(t, []string{CommandQueueRelation, types.Success}, relations)
+	assert.Equal(t, "REBOOT", msgs[0].Type)
+	assert.Equal(t, "aa", msgs[0].Metadata.GetValue("deviceId"))
+	assert.Equal(t, "POST_TELEMETRY", msgs[1].Type)
+
+	//队列已经清空
+	relations = nil
+	assert.Nil(t, flushNode.OnMsg(ctx, ctx.NewMsg("POST_TELEMETRY", metaData, "{}")))
+	assert.Equal(t, []string{types.Success}, relations)
+}
+
+func TestCommandQueueNodeInitError(t *testing.T) {
+	node := (&CommandQueueNode{}).New()
+	assert.NotNil(t, node.Init(types.NewConfig(), types.Configuration{}))
+	assert.NotNil(t, node.Init(types.NewConfig(), types.Configuration{"key": "a", "operation": "peek"}))
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/kv"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"strconv"
+	"time"
+)
+
+const (
+	CounterOperationIncrement = "increment"
+	CounterOperationDecrement = "decrement"
+	CounterOperationReset     = "reset"
+)
+
+// 计数周期
+const (
+	CounterPeriodNone   = ""
+	CounterPeriodMinute = "minute"
+	CounterPeriodHour   = "hour"
+	CounterPeriodDay    = "day"
+	CounterPeriodWeek   = "week"
+	CounterPeriodMonth  = "month"
+)
+
+// CounterOverQuota 计数超过限额的关系类型
+const CounterOverQuota = "OverQuota"
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "counter",
+//	       "name": "设备每日消息配额",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "name": "msg_quota_${deviceId}",
+//	         "period": "day",
+//	         "limit": 10000
+//	       }
+//	     }
+func init() {
+	Registry.Add(&CounterNode{})
+}
+
+// CounterNodeConfiguration 节点配置
+type CounterNodeConfiguration struct {
+	//Name 计数器名称，可以使用 ${metaKeyName} 替换元数据中的变量，实现按设备等维度计数
+	Name string
+	//Operation 操作：increment、decrement、reset，默认：increment
+	Operation string
+	//Step 每次增加或者减少的数量，默认1
+	Step int64
+	//Period 计数周期：minute、hour、day、week、month，每个周期重新计数，为空则不重新计数
+	Period string
+	//Limit 限额，increment后计数超过限额发送到`OverQuota`链，0表示不限制
+	Limit int64
+	//Namespace 命名空间，可以使用 ${metaKeyName} 替换元数据中的变量，默认使用规则引擎配置的租户：Config.Tenant
+	Namespace string
+	//MetadataKey 当前计数放到元数据的key，默认：count
+	MetadataKey string
+}
+
+// CounterNode 维护命名计数器，计数保存在跨规则链共享的key/value存储(`Config.KVStore`)
+// 可以用于设备每日消息配额、计费等场景，周期按照规则引擎时钟的时区计算
+// 计数放到元数据，如果超过限额发送消息到`OverQuota`链，否则发到`Success`链，超过限额的消息同样会计数
+// 存储读写错误，发送消息到`Failure`链
+type CounterNode struct {
+	config CounterNodeConfiguration
+	store  kv.Store
+	clock  clock.Clock
+	tenant string
+}
+
+// Type 组件类型
+func (x *CounterNode) Type() string {
+	return "counter"
+}
+
+// Descriptor 组件描述
+func (x *CounterNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"counter", "quota", "billing", "limit"},
+		Description: "维护命名计数器，超过限额发送到OverQuota链",
+	}
+}
+
+func (x *CounterNode) New() types.Node {
+	return &CounterNode{config: CounterNodeConfiguration{
+		Operation:   CounterOperationIncrement,
+		Step:        1,
+		MetadataKey: "count",
+	}}
+}
+
+// Init 初始化
+func (x *CounterNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Name == "" {
+		return errors.New("name can not empty")
+	}
+	switch x.config.Operation {
+	case CounterOperationIncrement, CounterOperationDecrement, CounterOperationReset:
+	default:
+		return errors.New("unsupported operation:" + x.config.Operation)
+	}
+	if _, _, ok := counterPeriod(x.config.Period, time.Now()); !ok {
+		return errors.New("unsupported period:" + x.config.Period)
+	}
+	if x.config.Step <= 0 {
+		x.config.Step = 1
+	}
+	x.store = ruleConfig.GetKVStore()
+	x.clock = ruleConfig.GetClock()
+	x.tenant = ruleConfig.Tenant
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *CounterNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	metaData := msg.Metadata.Values()
+	namespace := x.tenant
+	if x.config.Namespace != "" {
+		namespace = str.SprintfDict(x.config.Namespace, metaData)
+	}
+	key := str.SprintfDict(x.config.Name, metaData)
+	var ttl time.Duration
+	if x.config.Period != CounterPeriodNone {
+		now := x.clock.Now()
+		suffix, end, _ := counterPeriod(x.config.Period, now)
+		key = key + ":" + suffix
+		ttl = end.Sub(now)
+	}
+	var count int64
+	var err error
+	switch x.config.Operation {
+	case CounterOperationReset:
+		err = x.store.Delete(namespace, key)
+	case CounterOperationDecrement:
+		count, err = x.store.Incr(namespace, key, -x.config.Step, ttl)
+	default:
+		count, err = x.store.Incr(namespace, key, x.config.Step, ttl)
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	msg.Metadata.PutValue(x.config.MetadataKey, strconv.FormatInt(count, 10))
+	if x.config.Operation == CounterOperationIncrement && x.config.Limit > 0 && count > x.config.Limit {
+		ctx.TellNext(msg, CounterOverQuota)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+	return nil
+}
+
+// Destroy 销毁
+func (x *CounterNode) Destroy() {
+}
+
+// counterPeriod 返回t所在周期的标识和周期结束时间，周一为每周的第一天
+func counterPeriod(period string, t time.Time) (string, time.Time, bool) {
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch period {
+	case CounterPeriodNone:
+		return "", time.Time{}, true
+	case CounterPeriodMinute:
+		start := time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc)
+		return start.Format("200601021504"), start.Add(time.Minute), true
+	case CounterPeriodHour:
+		start := time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+		return start.Format("2006010215"), start.Add(time.Hour), true
+	case CounterPeriodDay:
+		start := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		return start.Format("20060102"), start.AddDate(0, 0, 1), true
+	case CounterPeriodWeek:
+		start := time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+		return "w" + start.Format("20060102"), start.AddDate(0, 0, 7), true
+	case CounterPeriodMonth:
+		start := time.Date(y, m, 1, 0, 0, 0, 0, loc)
+		return start.Format("200601"), start.AddDate(0, 1, 0), true
+	default:
+		return "", time.Time{}, false
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/kv"
+	"testing"
+	"time"
+)
+
+func TestCounterNodeOnMsg(t *testing.T) {
+	vc := clock.NewVirtual(time.Date(2023, 7, 1, 23, 59, 0, 0, time.UTC))
+	config := types.NewConfig(types.WithClock(vc), types.WithKVStore(kv.NewMemory(vc)))
+	node := (&CounterNode{}).New()
+	err := node.Init(config, types.Configuration{
+		"name":   "quota_${deviceId}",
+		"period": "day",
+		"limit":  2,
+	})
+	assert.Nil(t, err)
+	resetNode := (&CounterNode{}).New()
+	err = resetNode.Init(config, types.Configuration{
+		"name":      "quota_${deviceId}",
+		"period":    "day",
+		"operation": "reset",
+	})
+	assert.Nil(t, err)
+
+	var relations []string
+	var counts []interface{}
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relations = append(relations, relationType)
+		counts = append(counts, msg.Metadata.GetValue("count"))
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", metaData, "")))
+	}
+	assert.Equal(t, []string{types.Success, types.Success, CounterOverQuota}, relations)
+	assert.Equal(t, []interface{}{"1", "2", "3"}, counts)
+	v, ok, _ := config.KVStore.Get("", "quota_aa:20230701")
+	assert.True(t, ok)
+	assert.Equal(t, "3", v)
+
+	//进入下一个周期，重新计数
+	vc.Advance(time.Minute)
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", metaData, "")))
+	assert.Equal(t, types.Success, relations[3])
+	assert.Equal(t, "1", counts[3])
+	_, ok, _ = config.KVStore.Get("", "quota_aa:20230701")
+	assert.False(t, ok)
+
+	assert.Nil(t, resetNode.OnMsg(ctx, ctx.NewMsg("TEST", metaData, "")))
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", metaData, "")))
+	assert.Equal(t, "0", counts[4])
+	assert.Equal(t, "1", counts[5])
+}
+
+func TestCounterPeriod(t *testing.T) {
+	//2023-07-05 星期三
+	now := time.Date(2023, 7, 5, 10, 30, 15, 0, time.UTC)
+	key, end, ok := counterPeriod(CounterPeriodWeek, now)
+	assert.True(t, ok)
+	assert.Equal(t, "w20230703", key)
+	assert.Equal(t, time.Date(2023, 7, 10, 0, 0, 0, 0, time.UTC), end)
+	key, end, _ = counterPeriod(CounterPeriodMonth, now)
+	assert.Equal(t, "202307", key)
+	assert.Equal(t, time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC), end)
+	key, end, _ = counterPeriod(CounterPeriodHour, now)
+	assert.Equal(t, "2023070510", key)
+	assert.Equal(t, time.Date(2023, 7, 5, 11, 0, 0, 0, time.UTC), end)
+	_, _, ok = counterPeriod("year", now)
+	assert.False(t, ok)
+}
+
+func TestCounterNodeInitError(t *testing.T) {
+	node := (&CounterNode{}).New()
+	assert.NotNil(t, node.Init(types.NewConfig(), types.Configuration{}))
+	assert.NotNil(t, node.Init(types.NewConfig(), types.Configuration{"name": "a", "period": "year"}))
+	assert.NotNil(t, node.Init(types.NewConfig(), types.Configuration{"name": "a", "operation": "incr"}))
+}
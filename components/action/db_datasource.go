@@ -0,0 +1,170 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"sync"
+	"time"
+)
+
+// dbDatasources 进程内共享的数据源，相同数据源的dbClient节点复用同一个连接池
+var dbDatasources = &dbDatasourceRegistry{items: make(map[string]*dbDatasource)}
+
+// dbDatasource 共享的数据库连接池
+type dbDatasource struct {
+	key    string
+	dbType string
+	dsn    string
+	db     *sql.DB
+	//poolSize 所有引用节点中最大的PoolSize，0表示不限制
+	poolSize int
+	//maxIdleConns 配置的最大空闲连接数，0表示按poolSize计算
+	maxIdleConns int
+	//connMaxLifetime 连接最长存活时间，0表示不限制
+	connMaxLifetime time.Duration
+	//connMaxIdleTime 连接最长空闲时间，0表示不限制
+	connMaxIdleTime time.Duration
+	//refs 引用的节点数量，为0时关闭连接池
+	refs int
+}
+
+// dbDatasourceRegistry 数据源注册表，使用引用计数管理连接池生命周期
+type dbDatasourceRegistry struct {
+	lock  sync.Mutex
+	items map[string]*dbDatasource
+}
+
+// datasourceKey 数据源标识，配置了DatasourceId则使用DatasourceId，否则使用DbType+Dsn
+func datasourceKey(config DbClientNodeConfiguration) string {
+	if config.DatasourceId != "" {
+		return "id:" + config.DatasourceId
+	}
+	return config.DbType + ":" + config.Dsn
+}
+
+// acquire 获取数据源，不存在则创建连接池，引用计数加1
+// 已存在的数据源PoolSize不一致时取最大值
+func (r *dbDatasourceRegistry) acquire(ruleConfig types.Config, config DbClientNodeConfiguration) (*dbDatasource, error) {
+	key := datasourceKey(config)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if ds, ok := r.items[key]; ok {
+		if ds.dbType != config.DbType || ds.dsn != config.Dsn {
+			return nil, fmt.Errorf("datasource %s already used with different dbType or dsn", config.DatasourceId)
+		}
+		if config.PoolSize != ds.poolSize && config.PoolSize > 0 {
+			if ds.poolSize > 0 && ruleConfig.Logger != nil {
+				ruleConfig.Logger.Printf("dbClient datasource %s poolSize conflict %d and %d, use %d",
+					key, ds.poolSize, config.PoolSize, maxPoolSize(ds.poolSize, config.PoolSize))
+			}
+			ds.setPoolSize(maxPoolSize(ds.poolSize, config.PoolSize))
+		}
+		ds.setConnLimits(config)
+		ds.refs++
+		return ds, nil
+	}
+	db, err := sql.Open(config.DbType, config.Dsn)
+	if err != nil {
+		if dialect := dbDialects[config.DbType]; dialect.driver != "" {
+			err = fmt.Errorf("%w, import driver: %s", err, dialect.driver)
+		}
+		return nil, err
+	}
+	ds := &dbDatasource{key: key, dbType: config.DbType, dsn: config.Dsn, db: db, refs: 1}
+	ds.setPoolSize(config.PoolSize)
+	ds.setConnLimits(config)
+	r.items[key] = ds
+	return ds, nil
+}
+
+// release 释放数据源，最后一个引用的节点释放时关闭连接池
+func (r *dbDatasourceRegistry) release(ds *dbDatasource) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ds.refs--
+	if ds.refs > 0 {
+		return
+	}
+	if r.items[ds.key] == ds {
+		delete(r.items, ds.key)
+	}
+	_ = ds.db.Close()
+}
+
+// dbPoolLimits 共享连接池当前生效的配置
+type dbPoolLimits struct {
+	poolSize        int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+	connMaxIdleTime time.Duration
+}
+
+// limits 数据源当前生效的连接池配置，相同数据源的节点相同
+func (r *dbDatasourceRegistry) limits(ds *dbDatasource) dbPoolLimits {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return dbPoolLimits{
+		poolSize:        ds.poolSize,
+		maxIdleConns:    ds.idleConns(),
+		connMaxLifetime: ds.connMaxLifetime,
+		connMaxIdleTime: ds.connMaxIdleTime,
+	}
+}
+
+// setPoolSize 设置连接池大小
+func (ds *dbDatasource) setPoolSize(poolSize int) {
+	ds.poolSize = poolSize
+	ds.db.SetMaxOpenConns(poolSize)
+	ds.db.SetMaxIdleConns(ds.idleConns())
+}
+
+// idleConns 最大空闲连接数，没有配置则按poolSize计算
+func (ds *dbDatasource) idleConns() int {
+	if ds.maxIdleConns > 0 {
+		return ds.maxIdleConns
+	} else if ds.dbType == DbTypeSqlite {
+		//保持连接避免sqlite :memory:数据库丢失
+		return ds.poolSize
+	}
+	return ds.poolSize / 2
+}
+
+// setConnLimits 设置空闲连接数和连接存活时间，没有配置则保持不变，相同数据源以最后配置的节点为准
+func (ds *dbDatasource) setConnLimits(config DbClientNodeConfiguration) {
+	if config.MaxIdleConns > 0 {
+		ds.maxIdleConns = config.MaxIdleConns
+		ds.db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if lifetime := config.connMaxLifetime(); lifetime > 0 {
+		ds.connMaxLifetime = lifetime
+		ds.db.SetConnMaxLifetime(lifetime)
+	}
+	if idleTime := config.connMaxIdleTime(); idleTime > 0 {
+		ds.connMaxIdleTime = idleTime
+		ds.db.SetConnMaxIdleTime(idleTime)
+	}
+}
+
+func maxPoolSize(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,234 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// dbPreparedEntry 预编译语句缓存项
+type dbPreparedEntry struct {
+	sql  string
+	stmt *sql.Stmt
+	//refs 正在使用该语句的执行数
+	refs int
+	//static 初始化时预编译的语句，不淘汰
+	static bool
+	//removed 已经从缓存删除，最后一个使用者释放时关闭
+	removed bool
+}
+
+// dbPreparedCache 预编译语句缓存，相同语句只预编译一次
+// 初始化时预编译的语句不淘汰，其他语句按替换后的语句LRU缓存，淘汰时关闭
+// sql.Stmt在连接池的其他连接或者重建的连接上执行时，database/sql自动重新预编译
+type dbPreparedCache struct {
+	db         *sql.DB
+	maxEntries int
+	//dynamic 非静态语句数量
+	dynamic int
+	entries map[string]*list.Element
+	lru     *list.List
+	lock    sync.Mutex
+}
+
+func newDbPreparedCache(db *sql.DB, maxEntries int) *dbPreparedCache {
+	return &dbPreparedCache{
+		db:         db,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// addStatic 添加不包含变量的语句，不淘汰，prepare为false或者预编译失败则在第一次执行时预编译
+func (c *dbPreparedCache) addStatic(ctx context.Context, sqlStr string, prepare bool) error {
+	var stmt *sql.Stmt
+	var err error
+	if prepare {
+		stmt, err = c.db.PrepareContext(ctx, sqlStr)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.entries[sqlStr]
+	if !ok {
+		element = c.lru.PushFront(&dbPreparedEntry{sql: sqlStr, static: true})
+		c.entries[sqlStr] = element
+	}
+	entry := element.Value.(*dbPreparedEntry)
+	if !entry.static {
+		entry.static = true
+		c.dynamic--
+	}
+	if stmt != nil {
+		if entry.stmt == nil {
+			entry.stmt = stmt
+		} else {
+			_ = stmt.Close()
+		}
+	}
+	return err
+}
+
+// get 获取预编译语句，不存在则预编译，使用完成后需要调用release
+func (c *dbPreparedCache) get(ctx context.Context, sqlStr string) (*dbPreparedEntry, error) {
+	c.lock.Lock()
+	if element, ok := c.entries[sqlStr]; ok {
+		entry := element.Value.(*dbPreparedEntry)
+		if entry.stmt != nil {
+			entry.refs++
+			c.lru.MoveToFront(element)
+			c.lock.Unlock()
+			return entry, nil
+		}
+	}
+	c.lock.Unlock()
+
+	stmt, err := c.db.PrepareContext(ctx, sqlStr)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[sqlStr]; ok {
+		entry := element.Value.(*dbPreparedEntry)
+		if entry.stmt == nil {
+			entry.stmt = stmt
+		} else {
+			//其他执行已经预编译
+			_ = stmt.Close()
+		}
+		entry.refs++
+		c.lru.MoveToFront(element)
+		return entry, nil
+	}
+	entry := &dbPreparedEntry{sql: sqlStr, stmt: stmt, refs: 1}
+	c.entries[sqlStr] = c.lru.PushFront(entry)
+	c.dynamic++
+	c.evict()
+	return entry, nil
+}
+
+// release 释放get获取的语句，discard则从缓存删除，下次执行重新预编译
+func (c *dbPreparedCache) release(entry *dbPreparedEntry, discard bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry.refs--
+	if discard && !entry.removed {
+		if element, ok := c.entries[entry.sql]; ok && element.Value == entry {
+			if entry.static {
+				//保留静态语句的位置，下次执行重新预编译
+				c.entries[entry.sql] = c.lru.InsertBefore(&dbPreparedEntry{sql: entry.sql, static: true}, element)
+			} else {
+				delete(c.entries, entry.sql)
+				c.dynamic--
+			}
+			c.lru.Remove(element)
+		}
+		entry.removed = true
+	}
+	if entry.removed && entry.refs == 0 && entry.stmt != nil {
+		_ = entry.stmt.Close()
+		entry.stmt = nil
+	}
+}
+
+// evict 淘汰超过maxEntries的最久没有使用的非静态语句，调用方需要持有锁
+func (c *dbPreparedCache) evict() {
+	for element := c.lru.Back(); element != nil && c.dynamic > c.maxEntries; {
+		prev := element.Prev()
+		entry := element.Value.(*dbPreparedEntry)
+		if !entry.static {
+			c.lru.Remove(element)
+			delete(c.entries, entry.sql)
+			c.dynamic--
+			entry.removed = true
+			if entry.refs == 0 {
+				_ = entry.stmt.Close()
+				entry.stmt = nil
+			}
+		}
+		element = prev
+	}
+}
+
+// len 缓存的语句数量
+func (c *dbPreparedCache) len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// close 关闭所有语句，正在使用的语句在释放时关闭
+func (c *dbPreparedCache) close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, element := range c.entries {
+		entry := element.Value.(*dbPreparedEntry)
+		entry.removed = true
+		if entry.refs == 0 && entry.stmt != nil {
+			_ = entry.stmt.Close()
+			entry.stmt = nil
+		}
+	}
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.dynamic = 0
+}
+
+// dbPreparedExecutor 使用预编译语句执行，tx不为空则在事务中执行
+type dbPreparedExecutor struct {
+	cache *dbPreparedCache
+	tx    *sql.Tx
+}
+
+func (e dbPreparedExecutor) stmt(ctx context.Context, entry *dbPreparedEntry) *sql.Stmt {
+	if e.tx != nil {
+		//事务中的语句在事务结束时自动关闭
+		return e.tx.StmtContext(ctx, entry.stmt)
+	}
+	return entry.stmt
+}
+
+func (e dbPreparedExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	entry, err := e.cache.get(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	result, err := e.stmt(ctx, entry).ExecContext(ctx, args...)
+	e.cache.release(entry, discardPrepared(ctx, err))
+	return result, err
+}
+
+func (e dbPreparedExecutor) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	entry, err := e.cache.get(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	//语句关闭时等待没有读取完的结果集关闭
+	rows, err := e.stmt(ctx, entry).QueryContext(ctx, args...)
+	e.cache.release(entry, discardPrepared(ctx, err))
+	return rows, err
+}
+
+// discardPrepared 执行失败时丢弃预编译语句，例如表结构变更后语句失效，超时和取消不丢弃
+func discardPrepared(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() == nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
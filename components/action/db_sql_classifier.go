@@ -0,0 +1,184 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sqlWord 语句中引号和注释之外的单词，depth为所在括号层级
+type sqlWord struct {
+	text  string
+	depth int
+}
+
+// sqlSemicolon sqlTokens返回的语句分隔符
+const sqlSemicolon = ";"
+
+// sqlWords 按顺序返回语句中引号和注释之外的单词，单词转换为大写
+// 支持--、/* */注释和开头的MySQL #注释
+func sqlWords(sqlStr string) []sqlWord {
+	var words []sqlWord
+	for _, word := range sqlTokens(sqlStr, false) {
+		if word.text != sqlSemicolon {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// sqlTokens 按顺序返回语句中引号和注释之外的单词和分号
+// mysql 按MySQL语法识别：单引号和双引号中的反斜杠转义下一个字符，任意位置的#开始注释
+// 否则按标准SQL识别，反斜杠不转义，只有开头的#是注释
+func sqlTokens(sqlStr string, mysql bool) []sqlWord {
+	var words []sqlWord
+	depth := 0
+	for i := 0; i < len(sqlStr); {
+		c := sqlStr[i]
+		switch {
+		case c == '-' && i+1 < len(sqlStr) && sqlStr[i+1] == '-', c == '#' && (mysql || len(words) == 0):
+			if end := strings.IndexByte(sqlStr[i:], '\n'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(sqlStr)
+			}
+		case c == '/' && i+1 < len(sqlStr) && sqlStr[i+1] == '*':
+			if end := strings.Index(sqlStr[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(sqlStr)
+			}
+		case c == '\'' || c == '"' || c == '`':
+			i = skipSqlQuote(sqlStr, i, mysql && c != '`')
+		case c == ';':
+			words = append(words, sqlWord{text: sqlSemicolon, depth: depth})
+			i++
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+		case isSqlWordChar(c):
+			start := i
+			for i < len(sqlStr) && isSqlWordChar(sqlStr[i]) {
+				i++
+			}
+			words = append(words, sqlWord{text: strings.ToUpper(sqlStr[start:i]), depth: depth})
+		default:
+			i++
+		}
+	}
+	return words
+}
+
+// skipSqlQuote 返回start处引号对应的结束引号之后的位置，没有结束引号返回语句长度
+func skipSqlQuote(sqlStr string, start int, backslashEscape bool) int {
+	quote := sqlStr[start]
+	for i := start + 1; i < len(sqlStr); i++ {
+		switch sqlStr[i] {
+		case '\\':
+			if backslashEscape {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return len(sqlStr)
+}
+
+func isSqlWordChar(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// sqlVerbs 语句开头的动词对应的操作类型
+var sqlVerbs = map[string]string{
+	SELECT: SELECT,
+	INSERT: INSERT,
+	UPDATE: UPDATE,
+	DELETE: DELETE,
+	CALL:   CALL,
+	DO:     DO,
+	//MySQL REPLACE INTO、CockroachDB UPSERT和MERGE按INSERT执行
+	"REPLACE":  INSERT,
+	"UPSERT":   INSERT,
+	"MERGE":    INSERT,
+	"TRUNCATE": DELETE,
+}
+
+// cteWriteVerbs WITH子句中可以出现的写操作
+var cteWriteVerbs = map[string]bool{INSERT: true, UPDATE: true, DELETE: true, "MERGE": true}
+
+// sqlClass 语句分类结果
+type sqlClass struct {
+	//verb 决定操作类型的动词，例如：SELECT、REPLACE、TRUNCATE
+	verb string
+	//opType 执行方式对应的操作类型
+	opType string
+	//cteWriteVerb WITH子句中的写操作动词，例如Postgres的WITH d AS (DELETE ... RETURNING *) SELECT * FROM d
+	cteWriteVerb string
+	//returning 插入语句是否包含RETURNING子句，例如：INSERT ... RETURNING id
+	returning bool
+}
+
+// classifySql 识别语句的操作类型，忽略开头的注释和括号
+// WITH语句使用公用表表达式之后的动词，同时记录WITH子句中的写操作
+func classifySql(sqlStr string) (sqlClass, error) {
+	var class sqlClass
+	words := sqlWords(sqlStr)
+	if len(words) == 0 {
+		return class, errors.New("sql can not empty")
+	}
+	if words[0].text != "WITH" {
+		if opType, ok := sqlVerbs[words[0].text]; ok {
+			return sqlClass{verb: words[0].text, opType: opType, returning: opType == INSERT && hasReturning(words[1:], words[0].depth)}, nil
+		}
+		return class, fmt.Errorf("unsupported sql statement: %s", sqlStr)
+	}
+	depth := words[0].depth
+	for i, word := range words[1:] {
+		if word.depth > depth {
+			//FOR UPDATE、FOR NO KEY UPDATE是加锁查询
+			if cteWriteVerbs[word.text] && class.cteWriteVerb == "" && (word.text != UPDATE || (words[i].text != "FOR" && words[i].text != "KEY")) {
+				class.cteWriteVerb = word.text
+			}
+			continue
+		}
+		if word.depth == depth && word.text != "WITH" {
+			if opType, ok := sqlVerbs[word.text]; ok && opType != CALL && opType != DO {
+				class.verb = word.text
+				class.opType = opType
+				class.returning = opType == INSERT && hasReturning(words[i+2:], depth)
+				return class, nil
+			}
+		}
+	}
+	return class, fmt.Errorf("unsupported sql statement: %s", sqlStr)
+}
+
+// hasReturning 动词之后同一括号层级是否有RETURNING子句
+func hasReturning(words []sqlWord, depth int) bool {
+	for _, word := range words {
+		if word.depth == depth && word.text == "RETURNING" {
+			return true
+		}
+	}
+	return false
+}
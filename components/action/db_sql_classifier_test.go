@@ -0,0 +1,66 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"testing"
+)
+
+func TestClassifySql(t *testing.T) {
+	tests := []struct {
+		sql   string
+		class sqlClass
+	}{
+		{"select * from users", sqlClass{verb: SELECT, opType: SELECT}},
+		{"  Insert into users values (?)", sqlClass{verb: INSERT, opType: INSERT}},
+		{"update users set name = ?", sqlClass{verb: UPDATE, opType: UPDATE}},
+		{"delete from users", sqlClass{verb: DELETE, opType: DELETE}},
+		{"call add_user(?)", sqlClass{verb: CALL, opType: CALL}},
+		{"DO $$ BEGIN PERFORM 1; END $$", sqlClass{verb: DO, opType: DO}},
+		{"-- 查询用户\nselect * from users", sqlClass{verb: SELECT, opType: SELECT}},
+		{"/* hint */ /* second\n line */delete from users", sqlClass{verb: DELETE, opType: DELETE}},
+		{"# mysql comment\nselect 1", sqlClass{verb: SELECT, opType: SELECT}},
+		{"(select id from a) union (select id from b)", sqlClass{verb: SELECT, opType: SELECT}},
+		{"replace into users (id, name) values (?, ?)", sqlClass{verb: "REPLACE", opType: INSERT}},
+		{"UPSERT INTO users (id, name) VALUES (?, ?)", sqlClass{verb: "UPSERT", opType: INSERT}},
+		{"merge into users using src on users.id = src.id when matched then update set name = src.name", sqlClass{verb: "MERGE", opType: INSERT}},
+		{"truncate table users", sqlClass{verb: "TRUNCATE", opType: DELETE}},
+		{"WITH recent AS (SELECT * FROM readings WHERE ts > ?) SELECT * FROM recent", sqlClass{verb: SELECT, opType: SELECT}},
+		{"with recursive t(n) as (select 1 union all select n+1 from t where n < 5), u as (select 2) select * from t", sqlClass{verb: SELECT, opType: SELECT}},
+		{"with src as (select ? as id) insert into users (id) select id from src", sqlClass{verb: INSERT, opType: INSERT}},
+		{"with src as (select id from users for update) update users set name = 'delete' where id in (select id from src)", sqlClass{verb: UPDATE, opType: UPDATE}},
+		{"with locked as (select id from jobs for no key update) select * from locked", sqlClass{verb: SELECT, opType: SELECT}},
+		{"with d as (delete from users where id = ? returning *) select * from d", sqlClass{verb: SELECT, opType: SELECT, cteWriteVerb: DELETE}},
+		{"-- with a comment\nwith x as (select ';delete' as v) select v from x", sqlClass{verb: SELECT, opType: SELECT}},
+		{"insert into users (name) values (?) returning id", sqlClass{verb: INSERT, opType: INSERT, returning: true}},
+		{"INSERT INTO users (name) VALUES ($1) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id, name", sqlClass{verb: INSERT, opType: INSERT, returning: true}},
+		{"insert into users (name) values ('returning')", sqlClass{verb: INSERT, opType: INSERT}},
+		{"insert into users (id) select id from (delete from old returning id) d", sqlClass{verb: INSERT, opType: INSERT}},
+		{"with src as (select ? as name) insert into users (name) select name from src returning id", sqlClass{verb: INSERT, opType: INSERT, returning: true}},
+	}
+	for _, item := range tests {
+		class, err := classifySql(item.sql)
+		if err != nil || class != item.class {
+			t.Errorf("classifySql(%q) = %+v, %v, want %+v", item.sql, class, err, item.class)
+		}
+	}
+	for _, sqlStr := range []string{"", "  -- only comment", "/* unclosed", "drop table users", "create table users (id int)", "with x as (select 1)", "show tables"} {
+		if _, err := classifySql(sqlStr); err == nil {
+			t.Errorf("classifySql(%q) should return error", sqlStr)
+		}
+	}
+}
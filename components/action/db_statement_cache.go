@@ -0,0 +1,80 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"container/list"
+	"sync"
+)
+
+// dbStatementCacheEntry 缓存项
+type dbStatementCacheEntry struct {
+	sql  string
+	stmt *dbStatement
+}
+
+// dbStatementCache DynamicSql模式解析后的语句缓存，LRU淘汰，相同语句不需要每条消息重新解析
+type dbStatementCache struct {
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+	lock       sync.Mutex
+}
+
+func newDbStatementCache(maxEntries int) *dbStatementCache {
+	return &dbStatementCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// get 获取缓存的语句
+func (c *dbStatementCache) get(sql string) (*dbStatement, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.entries[sql]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	return element.Value.(*dbStatementCacheEntry).stmt, true
+}
+
+// put 保存语句，超过maxEntries淘汰最久没有使用的语句
+func (c *dbStatementCache) put(sql string, stmt *dbStatement) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[sql]; ok {
+		element.Value.(*dbStatementCacheEntry).stmt = stmt
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[sql] = c.lru.PushFront(&dbStatementCacheEntry{sql: sql, stmt: stmt})
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*dbStatementCacheEntry).sql)
+	}
+}
+
+// len 缓存的语句数量
+func (c *dbStatementCache) len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/clock"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrTargetTimeout 目标处理超时
+var ErrTargetTimeout = errors.New("target timeout")
+
+// DispatchTarget fanOut、failover等节点的发送目标，Chain和Type二选一
+type DispatchTarget struct {
+	//Name 目标名称，用于结果元数据，默认使用目标序号
+	Name string
+	//Chain 目标规则链ID，通过`Config.ChainExecutor`执行，规则链第一个结束点的结果作为目标结果
+	Chain string
+	//Type 目标节点类型，例如：mqttClient(主题)、restApiCall(URL)，通过`Config.ComponentsRegistry`创建
+	Type string
+	//Configuration 目标节点配置
+	Configuration types.Configuration
+	//TimeoutMs 每次发送的超时时间，单位毫秒，默认5000
+	TimeoutMs int
+	//MaxRetries 失败后最大重试次数，默认0不重试
+	MaxRetries int
+	//RetryIntervalMs 重试间隔，单位毫秒，默认1000
+	RetryIntervalMs int
+}
+
+// dispatchTarget 初始化后的发送目标
+type dispatchTarget struct {
+	DispatchTarget
+	node     types.Node
+	executor func(chainId string, msg types.RuleMsg, onEnd func(msg types.RuleMsg, err error)) error
+	clock    clock.Clock
+}
+
+// newDispatchTarget 初始化第index个目标，节点类型的目标通过`Config.ComponentsRegistry`创建并初始化
+func newDispatchTarget(ruleConfig types.Config, item DispatchTarget, index int) (*dispatchTarget, error) {
+	target := &dispatchTarget{DispatchTarget: item, executor: ruleConfig.ChainExecutor, clock: ruleConfig.GetClock()}
+	if target.Name == "" {
+		target.Name = strconv.Itoa(index)
+	}
+	if target.TimeoutMs <= 0 {
+		target.TimeoutMs = 5000
+	}
+	if target.RetryIntervalMs <= 0 {
+		target.RetryIntervalMs = 1000
+	}
+	var err error
+	switch {
+	case target.Chain != "" && target.Type != "":
+		err = fmt.Errorf("target %s: chain and type can not both be set", target.Name)
+	case target.Chain != "":
+		if target.executor == nil {
+			err = errors.New("chain executor not configured")
+		}
+	case target.Type != "":
+		if ruleConfig.ComponentsRegistry == nil {
+			err = errors.New("components registry not configured")
+		} else if target.node, err = ruleConfig.ComponentsRegistry.NewNode(target.Type); err == nil {
+			if target.Configuration == nil {
+				target.Configuration = make(types.Configuration)
+			}
+			err = target.node.Init(ruleConfig, target.Configuration)
+		}
+	default:
+		err = fmt.Errorf("target %s: chain or type is required", target.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// destroy 销毁节点类型的目标
+func (t *dispatchTarget) destroy() {
+	if t.node != nil {
+		t.node.Destroy()
+	}
+}
+
+// send 发送消息副本，失败后按照重试次数重新发送，cancelled返回true则不再重试，done调用一次
+func (t *dispatchTarget) send(ctx types.RuleContext, msg types.RuleMsg, cancelled func() bool, done func(err error)) {
+	t.attempt(ctx, msg, 0, cancelled, done)
+}
+
+func (t *dispatchTarget) attempt(ctx types.RuleContext, msg types.RuleMsg, attempt int, cancelled func() bool, done func(err error)) {
+	var once sync.Once
+	finish := func(err error) {
+		once.Do(func() {
+			if err != nil && attempt < t.MaxRetries && !cancelled() {
+				t.clock.AfterFunc(time.Duration(t.RetryIntervalMs)*time.Millisecond, func() {
+					t.attempt(ctx, msg, attempt+1, cancelled, done)
+				})
+				return
+			}
+			done(err)
+		})
+	}
+	timer := t.clock.AfterFunc(time.Duration(t.TimeoutMs)*time.Millisecond, func() {
+		finish(ErrTargetTimeout)
+	})
+	msgCopy := msg.Copy()
+	if t.node != nil {
+		callNode(ctx, t.node, msgCopy, func(err error) {
+			timer.Stop()
+			finish(err)
+		})
+	} else if err := t.executor(t.Chain, msgCopy, func(_ types.RuleMsg, err error) {
+		timer.Stop()
+		finish(err)
+	}); err != nil {
+		timer.Stop()
+		finish(err)
+	}
+}
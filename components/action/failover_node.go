@@ -0,0 +1,131 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"strings"
+)
+
+const (
+	//failoverServedByKey 处理消息的目标名称元数据key
+	failoverServedByKey = "servedBy"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "failover",
+//	       "name": "多区域调用",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "targets": [
+//	           {"name": "cn-east", "type": "restApiCall", "configuration": {"restEndpointUrlPattern": "http://east.example.com/api"}, "timeoutMs": 2000},
+//	           {"name": "cn-north", "type": "restApiCall", "configuration": {"restEndpointUrlPattern": "http://north.example.com/api"}, "timeoutMs": 2000},
+//	           {"name": "fallback", "chain": "fallback_chain"}
+//	         ]
+//	       }
+//	     }
+func init() {
+	Registry.Add(&FailoverNode{})
+}
+
+// FailoverNodeConfiguration 节点配置
+type FailoverNodeConfiguration struct {
+	//Targets 目标列表，第一个为主目标，其余按顺序作为备用目标，目标配置参考`DispatchTarget`
+	Targets []DispatchTarget
+}
+
+// FailoverNode 故障转移节点，先把消息发送到主目标，失败或者超时(包括重试)后依次发送到备用目标
+// 有目标处理成功则发送到`Success`链，元数据servedBy为处理消息的目标名称，所有目标都失败则发送到`Failure`链
+// 每个目标收到消息的副本，目标对消息的修改不影响输出消息
+type FailoverNode struct {
+	config  FailoverNodeConfiguration
+	targets []*dispatchTarget
+}
+
+// Type 组件类型
+func (x *FailoverNode) Type() string {
+	return "failover"
+}
+
+// Descriptor 组件描述
+func (x *FailoverNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"failover", "fallback", "retry", "multiRegion"},
+		Description: "先发送到主目标，失败或者超时后依次发送到备用目标",
+	}
+}
+
+func (x *FailoverNode) New() types.Node {
+	return &FailoverNode{}
+}
+
+// Init 初始化
+func (x *FailoverNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if len(x.config.Targets) == 0 {
+		return errors.New("targets can not empty")
+	}
+	for i, item := range x.config.Targets {
+		target, err := newDispatchTarget(ruleConfig, item, i)
+		if err != nil {
+			x.Destroy()
+			return err
+		}
+		x.targets = append(x.targets, target)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *FailoverNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	x.try(ctx, msg, x.targets, nil)
+	return nil
+}
+
+// Destroy 销毁
+func (x *FailoverNode) Destroy() {
+	for _, target := range x.targets {
+		target.destroy()
+	}
+	x.targets = nil
+}
+
+// try 发送到第一个目标，失败后发送到剩余目标，errs为之前目标的错误
+func (x *FailoverNode) try(ctx types.RuleContext, msg types.RuleMsg, targets []*dispatchTarget, errs []string) {
+	target := targets[0]
+	target.send(ctx, msg, func() bool { return false }, func(err error) {
+		if err == nil {
+			msg.Metadata.PutValue(failoverServedByKey, target.Name)
+			ctx.TellSuccess(msg)
+			return
+		}
+		errs = append(errs, target.Name+": "+err.Error())
+		if len(targets) > 1 {
+			x.try(ctx, msg, targets[1:], errs)
+			return
+		}
+		ctx.TellFailure(msg, errors.New("all targets failed: "+strings.Join(errs, "; ")))
+	})
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFailoverNodeOnMsg(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	uplink := &testUplinkNode{}
+	var lock sync.Mutex
+	var calls []string
+	//east规则链不结束，west规则链返回失败
+	executor := func(chainId string, msg types.RuleMsg, onEnd func(msg types.RuleMsg, err error)) error {
+		lock.Lock()
+		calls = append(calls, chainId)
+		lock.Unlock()
+		if chainId == "west" {
+			onEnd(msg, errors.New("503 service unavailable"))
+		}
+		return nil
+	}
+	config := types.NewConfig(types.WithClock(vc), types.WithChainExecutor(executor),
+		types.WithComponentsRegistry(testUplinkRegistry{node: uplink}))
+	node := (&FailoverNode{}).New()
+	assert.Nil(t, node.Init(config, types.Configuration{"targets": []interface{}{
+		map[string]interface{}{"name": "east", "chain": "east", "timeoutMs": 2000},
+		map[string]interface{}{"name": "west", "chain": "west", "maxRetries": 1, "retryIntervalMs": 500},
+		map[string]interface{}{"name": "local", "type": "test/uplink"},
+	}}))
+	defer node.Destroy()
+
+	var relations []string
+	var msgs []types.RuleMsg
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		lock.Lock()
+		defer lock.Unlock()
+		relations = append(relations, relationType)
+		msgs = append(msgs, msg)
+	})
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "1")))
+	assert.Equal(t, 0, len(relations))
+	//主目标超时，切换到west，west重试一次后切换到local
+	vc.Advance(time.Second * 2)
+	assert.Equal(t, []string{"east", "west"}, calls)
+	assert.Equal(t, 0, len(relations))
+	vc.Advance(time.Millisecond * 500)
+	assert.Equal(t, []string{"east", "west", "west"}, calls)
+	assert.Equal(t, []string{types.Success}, relations)
+	assert.Equal(t, "local", msgs[0].Metadata.GetValue(failoverServedByKey))
+	assert.Equal(t, []string{"1"}, uplink.getSent())
+
+	//所有目标都失败
+	uplink.setOffline(true)
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "2")))
+	vc.Advance(time.Second * 2)
+	vc.Advance(time.Millisecond * 500)
+	assert.Equal(t, []string{types.Success, types.Failure}, relations)
+	assert.False(t, msgs[1].Metadata.Has(failoverServedByKey))
+}
+
+func TestFailoverNodeInitError(t *testing.T) {
+	config := types.NewConfig(types.WithComponentsRegistry(testUplinkRegistry{node: &testUplinkNode{}}))
+	assert.NotNil(t, (&FailoverNode{}).New().Init(config, types.Configuration{}))
+	assert.NotNil(t, (&FailoverNode{}).New().Init(config, types.Configuration{"targets": []interface{}{
+		map[string]interface{}{"type": "test/uplink"},
+		map[string]interface{}{"chain": "a"},
+	}}))
+}
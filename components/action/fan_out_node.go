@@ -0,0 +1,175 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"strings"
+	"sync"
+)
+
+const (
+	//FanOutPolicyAllSuccess 等待所有目标完成，全部成功发送到`Success`链，否则发送到`Failure`链
+	FanOutPolicyAllSuccess = "AllSuccess"
+	//FanOutPolicyAnyFailure 任意目标失败立即发送到`Failure`链，不再等待其他目标，全部成功发送到`Success`链
+	FanOutPolicyAnyFailure = "AnyFailure"
+	//fanOutFailedKey 失败的目标名称元数据key，多个使用逗号分隔
+	fanOutFailedKey = "fanOutFailed"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "fanOut",
+//	       "name": "同时上报两个平台",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "policy": "AllSuccess",
+//	         "targets": [
+//	           {"name": "archive", "chain": "archive_chain"},
+//	           {"name": "cloud", "type": "mqttClient", "configuration": {"server": "127.0.0.1:1883", "topic": "/device/msg"}, "maxRetries": 2},
+//	           {"name": "crm", "type": "restApiCall", "configuration": {"restEndpointUrlPattern": "http://127.0.0.1:8080/api/msg"}, "timeoutMs": 3000}
+//	         ]
+//	       }
+//	     }
+func init() {
+	Registry.Add(&FanOutNode{})
+}
+
+// FanOutNodeConfiguration 节点配置
+type FanOutNodeConfiguration struct {
+	//Targets 分发目标列表
+	Targets []DispatchTarget
+	//Policy 汇总路由策略：AllSuccess、AnyFailure，默认：AllSuccess
+	Policy string
+}
+
+// FanOutNode 把消息并行发送到多个目标(规则链、主题、URL)，每个目标单独配置超时和重试，根据Policy汇总结果路由
+// 成功发送到`Success`链，失败发送到`Failure`链，元数据fanOutFailed为失败的目标名称
+// 每个目标收到消息的副本，目标对消息的修改不影响输出消息
+type FanOutNode struct {
+	config  FanOutNodeConfiguration
+	targets []*dispatchTarget
+}
+
+// Type 组件类型
+func (x *FanOutNode) Type() string {
+	return "fanOut"
+}
+
+// Descriptor 组件描述
+func (x *FanOutNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"fanOut", "mirror", "parallel", "retry"},
+		Description: "把消息并行发送到多个目标，按策略汇总结果",
+	}
+}
+
+func (x *FanOutNode) New() types.Node {
+	return &FanOutNode{config: FanOutNodeConfiguration{Policy: FanOutPolicyAllSuccess}}
+}
+
+// Init 初始化
+func (x *FanOutNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if len(x.config.Targets) == 0 {
+		return errors.New("targets can not empty")
+	}
+	if x.config.Policy != FanOutPolicyAllSuccess && x.config.Policy != FanOutPolicyAnyFailure {
+		return errors.New("unsupported policy:" + x.config.Policy)
+	}
+	for i, item := range x.config.Targets {
+		target, err := newDispatchTarget(ruleConfig, item, i)
+		if err != nil {
+			x.Destroy()
+			return err
+		}
+		x.targets = append(x.targets, target)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *FanOutNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	call := &fanOutCall{node: x, ctx: ctx, msg: msg, errs: make([]error, len(x.targets)), pending: len(x.targets)}
+	for i, target := range x.targets {
+		index := i
+		target.send(ctx, msg, call.isFinished, func(err error) {
+			call.complete(index, err)
+		})
+	}
+	return nil
+}
+
+// Destroy 销毁
+func (x *FanOutNode) Destroy() {
+	for _, target := range x.targets {
+		target.destroy()
+	}
+	x.targets = nil
+}
+
+// fanOutCall 一条消息的分发状态
+type fanOutCall struct {
+	node *FanOutNode
+	ctx  types.RuleContext
+	msg  types.RuleMsg
+	//errs 每个目标的结果
+	errs     []error
+	pending  int
+	finished bool
+	lock     sync.Mutex
+}
+
+func (c *fanOutCall) isFinished() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.finished
+}
+
+// complete 记录第i个目标的结果，根据策略决定是否路由
+func (c *fanOutCall) complete(i int, err error) {
+	c.lock.Lock()
+	c.errs[i] = err
+	c.pending--
+	if c.finished || (c.pending > 0 && (err == nil || c.node.config.Policy != FanOutPolicyAnyFailure)) {
+		c.lock.Unlock()
+		return
+	}
+	c.finished = true
+	var names []string
+	var messages []string
+	for j, item := range c.errs {
+		if item != nil {
+			names = append(names, c.node.targets[j].Name)
+			messages = append(messages, c.node.targets[j].Name+": "+item.Error())
+		}
+	}
+	c.lock.Unlock()
+	if len(names) == 0 {
+		c.ctx.TellSuccess(c.msg)
+		return
+	}
+	c.msg.Metadata.PutValue(fanOutFailedKey, strings.Join(names, ","))
+	c.ctx.TellFailure(c.msg, errors.New("fan-out failed: "+strings.Join(messages, "; ")))
+}
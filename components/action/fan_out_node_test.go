@@ -0,0 +1,142 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFanOutNodeOnMsg(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	uplink := &testUplinkNode{}
+	var lock sync.Mutex
+	var chainMsgs []string
+	//archive规则链成功，slow规则链不结束，broken规则链失败
+	executor := func(chainId string, msg types.RuleMsg, onEnd func(msg types.RuleMsg, err error)) error {
+		lock.Lock()
+		chainMsgs = append(chainMsgs, chainId+":"+msg.Data)
+		lock.Unlock()
+		switch chainId {
+		case "archive":
+			onEnd(msg, nil)
+		case "broken":
+			onEnd(msg, errors.New("db down"))
+		case "missing":
+			return errors.New("rule chain not found")
+		}
+		return nil
+	}
+	config := types.NewConfig(types.WithClock(vc), types.WithChainExecutor(executor),
+		types.WithComponentsRegistry(testUplinkRegistry{node: uplink}))
+
+	var relations []string
+	var msgs []types.RuleMsg
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		lock.Lock()
+		defer lock.Unlock()
+		relations = append(relations, relationType)
+		msgs = append(msgs, msg)
+	})
+	last := func() (string, types.RuleMsg) {
+		lock.Lock()
+		defer lock.Unlock()
+		if len(relations) == 0 {
+			return "", types.RuleMsg{}
+		}
+		return relations[len(relations)-1], msgs[len(msgs)-1]
+	}
+
+	newNode := func(configuration types.Configuration) types.Node {
+		node := (&FanOutNode{}).New()
+		assert.Nil(t, node.Init(config, configuration))
+		return node
+	}
+
+	//全部成功
+	node := newNode(types.Configuration{"targets": []interface{}{
+		map[string]interface{}{"name": "archive", "chain": "archive"},
+		map[string]interface{}{"name": "cloud", "type": "test/uplink", "maxRetries": 1, "retryIntervalMs": 1000},
+	}})
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", types.NewMetadata(), "1")))
+	relation, _ := last()
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, []string{"1"}, uplink.getSent())
+
+	//失败后重试成功
+	uplink.setOffline(true)
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", types.NewMetadata(), "2")))
+	assert.Equal(t, 1, len(relations))
+	uplink.setOffline(false)
+	vc.Advance(time.Second)
+	relation, _ = last()
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, []string{"1", "2"}, uplink.getSent())
+	assert.Equal(t, []string{"archive:1", "archive:2"}, chainMsgs)
+	node.Destroy()
+
+	//AllSuccess等待所有目标，超时作为失败
+	node = newNode(types.Configuration{"targets": []interface{}{
+		map[string]interface{}{"name": "slow", "chain": "slow", "timeoutMs": 3000},
+		map[string]interface{}{"name": "broken", "chain": "broken"},
+	}})
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", types.NewMetadata(), "3")))
+	assert.Equal(t, 2, len(relations))
+	vc.Advance(time.Second * 3)
+	relation, msg := last()
+	assert.Equal(t, types.Failure, relation)
+	assert.Equal(t, "slow,broken", msg.Metadata.GetValue(fanOutFailedKey))
+	node.Destroy()
+
+	//AnyFailure任意目标失败立即路由
+	node = newNode(types.Configuration{"policy": FanOutPolicyAnyFailure, "targets": []interface{}{
+		map[string]interface{}{"name": "slow", "chain": "slow", "timeoutMs": 3000},
+		map[string]interface{}{"name": "missing", "chain": "missing"},
+	}})
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", types.NewMetadata(), "4")))
+	relation, msg = last()
+	assert.Equal(t, 4, len(relations))
+	assert.Equal(t, types.Failure, relation)
+	assert.Equal(t, "missing", msg.Metadata.GetValue(fanOutFailedKey))
+	//之后超时不再路由
+	vc.Advance(time.Second * 3)
+	assert.Equal(t, 4, len(relations))
+	assert.True(t, strings.HasPrefix(chainMsgs[len(chainMsgs)-1], "missing:"))
+	node.Destroy()
+}
+
+func TestFanOutNodeInitError(t *testing.T) {
+	config := types.NewConfig(types.WithComponentsRegistry(testUplinkRegistry{node: &testUplinkNode{}}))
+	tests := []types.Configuration{
+		{},
+		{"targets": []interface{}{map[string]interface{}{"chain": "a"}}},
+		{"targets": []interface{}{map[string]interface{}{"type": "mqttClient"}}},
+		{"targets": []interface{}{map[string]interface{}{"name": "a"}}},
+		{"targets": []interface{}{map[string]interface{}{"chain": "a", "type": "test/uplink"}}},
+		{"policy": "AnySuccess", "targets": []interface{}{map[string]interface{}{"type": "test/uplink"}}},
+	}
+	for _, item := range tests {
+		assert.NotNil(t, (&FanOutNode{}).New().Init(config, item))
+	}
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/gcp"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"net/http"
+	"time"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "gcpPubSub",
+//	       "name": "发布到Pub/Sub主题",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "projectId": "my-project",
+//	         "topic": "rulego",
+//	         "credentialsFile": "./service-account.json",
+//	         "attributes": {"deviceId":"${deviceId}"},
+//	         "batchSize": 100
+//	       }
+//	     }
+func init() {
+	Registry.Add(&GcpPubSubNode{})
+}
+
+// GcpPubSubNodeConfiguration 节点配置
+type GcpPubSubNodeConfiguration struct {
+	//ProjectId 项目ID
+	ProjectId string
+	//Topic 主题名称或者完整路径projects/{project}/topics/{topic}，可以使用 ${metaKeyName} 替换元数据中的变量
+	Topic string
+	//Endpoint 自定义接口地址，例如模拟器：http://127.0.0.1:8085/v1/
+	Endpoint string
+	//CredentialsFile 服务账号密钥文件，为空则使用环境变量GOOGLE_APPLICATION_CREDENTIALS或者元数据服务
+	CredentialsFile string
+	//AccessToken 固定访问token
+	AccessToken string `sensitive:"true"`
+	//Attributes 消息属性，value可以使用 ${metaKeyName} 替换元数据中的变量
+	Attributes map[string]string
+	//OrderingKey 顺序key，可以使用 ${metaKeyName} 替换元数据中的变量
+	OrderingKey string
+	//BatchSize 批量发布数量，最大1000，小于等于1不开启批量发布
+	BatchSize int
+	//BatchIntervalMs 批量发布最大等待时间，单位毫秒
+	BatchIntervalMs int
+	//TimeoutMs 请求超时，单位毫秒
+	TimeoutMs int
+}
+
+// GcpPubSubNode 把msg.Data发布到Google Cloud Pub/Sub主题
+// 开启批量发布后消息会缓存，直到达到BatchSize或者BatchIntervalMs再合并成一次请求发布
+// 如果发布成功，发送消息到`Success`链，metaData.messageId记录消息ID, 否则发到`Failure`链
+type GcpPubSubNode struct {
+	config  GcpPubSubNodeConfiguration
+	client  *gcp.PubSubClient
+	batcher *msgBatcher
+}
+
+// Type 组件类型
+func (x *GcpPubSubNode) Type() string {
+	return "gcpPubSub"
+}
+
+// Descriptor 组件描述
+func (x *GcpPubSubNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"gcp", "messaging", "cloud"},
+		Description: "把消息发布到Google Cloud Pub/Sub主题",
+	}
+}
+
+func (x *GcpPubSubNode) New() types.Node {
+	return &GcpPubSubNode{config: GcpPubSubNodeConfiguration{
+		BatchIntervalMs: 1000,
+		TimeoutMs:       10000,
+	}}
+}
+
+// Init 初始化
+func (x *GcpPubSubNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Topic == "" {
+		return errors.New("topic can not empty")
+	}
+	timeout := time.Duration(x.config.TimeoutMs) * time.Millisecond
+	token, err := gcp.NewTokenProvider(x.config.AccessToken, x.config.CredentialsFile, gcp.PubSubScope, &http.Client{Timeout: timeout})
+	if err != nil {
+		return err
+	}
+	x.client = gcp.NewPubSubClient(x.config.ProjectId, x.config.Endpoint, token, timeout)
+	if x.config.BatchSize > 1 {
+		if x.config.BatchSize > gcp.MaxBatchSize {
+			x.config.BatchSize = gcp.MaxBatchSize
+		}
+		x.batcher = newMsgBatcher(ruleConfig.GetClock(), x.config.BatchSize, time.Duration(x.config.BatchIntervalMs)*time.Millisecond, x.sendBatch)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *GcpPubSubNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	if x.batcher != nil {
+		x.batcher.add(ctx, msg)
+		return nil
+	}
+	topic, pubsubMsg := x.toPubSubMessage(msg)
+	messageIds, err := x.client.Publish(ctx.GetContext(), topic, []gcp.PubSubMessage{pubsubMsg})
+	if err == nil && len(messageIds) != 1 {
+		err = errors.New("no message id returned")
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		msg.Metadata.PutValue("messageId", messageIds[0])
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁，发布缓存中的消息
+func (x *GcpPubSubNode) Destroy() {
+	if x.batcher != nil {
+		x.batcher.flush()
+	}
+}
+
+func (x *GcpPubSubNode) toPubSubMessage(msg types.RuleMsg) (string, gcp.PubSubMessage) {
+	metaData := msg.Metadata.Values()
+	attributes := make(map[string]string, len(x.config.Attributes))
+	for k, v := range x.config.Attributes {
+		attributes[str.SprintfDict(k, metaData)] = str.SprintfDict(v, metaData)
+	}
+	return str.SprintfDict(x.config.Topic, metaData), gcp.PubSubMessage{
+		Data:        msg.Data,
+		Attributes:  attributes,
+		OrderingKey: str.SprintfDict(x.config.OrderingKey, metaData),
+	}
+}
+
+// sendBatch 按照主题分组批量发布，同一请求内的消息同时成功或者失败
+func (x *GcpPubSubNode) sendBatch(items []*batchItem) {
+	var topics []string
+	groups := make(map[string][]*batchItem)
+	msgs := make(map[*batchItem]gcp.PubSubMessage, len(items))
+	for _, item := range items {
+		topic, pubsubMsg := x.toPubSubMessage(item.msg)
+		if _, ok := groups[topic]; !ok {
+			topics = append(topics, topic)
+		}
+		groups[topic] = append(groups[topic], item)
+		msgs[item] = pubsubMsg
+	}
+	for _, topic := range topics {
+		chunk := groups[topic]
+		var entries []gcp.PubSubMessage
+		for _, item := range chunk {
+			entries = append(entries, msgs[item])
+		}
+		messageIds, err := x.client.Publish(context.Background(), topic, entries)
+		if err == nil && len(messageIds) != len(chunk) {
+			err = errors.New("message ids count mismatch")
+		}
+		for i, item := range chunk {
+			if err != nil {
+				item.err = err
+			} else {
+				item.msg.Metadata.PutValue("messageId", messageIds[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestGcpPubSubNodeOnMsg(t *testing.T) {
+	var lock sync.Mutex
+	var publishedCount []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/projects/my-project/topics/aa:publish", r.URL.Path)
+		assert.Equal(t, "Bearer token1", r.Header.Get("Authorization"))
+		b, _ := ioutil.ReadAll(r.Body)
+		var request struct {
+			Messages []struct {
+				Attributes map[string]string `json:"attributes"`
+			} `json:"messages"`
+		}
+		_ = json.Unmarshal(b, &request)
+		assert.Equal(t, "aa", request.Messages[0].Attributes["deviceId"])
+		lock.Lock()
+		publishedCount = append(publishedCount, len(request.Messages))
+		lock.Unlock()
+		if len(request.Messages) == 1 {
+			_, _ = w.Write([]byte(`{"messageIds":["1"]}`))
+		} else {
+			_, _ = w.Write([]byte(`{"messageIds":["1","2","3"]}`))
+		}
+	}))
+	defer server.Close()
+
+	config := types.NewConfig()
+	configuration := types.Configuration{
+		"projectId":   "my-project",
+		"endpoint":    server.URL + "/v1/",
+		"accessToken": "token1",
+		"topic":       "${deviceId}",
+		"attributes":  map[string]string{"deviceId": "${deviceId}"},
+	}
+	node := (&GcpPubSubNode{}).New()
+	err := node.Init(config, configuration)
+	assert.Nil(t, err)
+
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "1", msg.Metadata.GetValue("messageId"))
+	})
+	err = node.OnMsg(ctx, ctx.NewMsg("TEST_MSG_TYPE_AA", metaData, "{\"temperature\":41}"))
+	assert.Nil(t, err)
+
+	//批量发布，销毁时发布缓存的消息
+	configuration["batchSize"] = 10
+	configuration["batchIntervalMs"] = 60000
+	node = (&GcpPubSubNode{}).New()
+	err = node.Init(config, configuration)
+	assert.Nil(t, err)
+	var messageIds []string
+	ctx = test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Success, relationType)
+		messageIds = append(messageIds, msg.Metadata.GetValue("messageId").(string))
+	})
+	for i := 0; i < 3; i++ {
+		_ = node.OnMsg(ctx, ctx.NewMsg("TEST_MSG_TYPE_AA", metaData.Copy(), "a"))
+	}
+	node.Destroy()
+	assert.Equal(t, []string{"1", "2", "3"}, messageIds)
+	assert.Equal(t, []int{1, 3}, publishedCount)
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/watchdog"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"time"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "heartbeat",
+//	       "name": "设备心跳",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "watchdog": "device",
+//	         "key": "${deviceId}",
+//	         "timeoutMs": 600000
+//	       }
+//	     }
+func init() {
+	Registry.Add(&HeartbeatNode{})
+}
+
+// HeartbeatNodeConfiguration 节点配置
+type HeartbeatNodeConfiguration struct {
+	//Watchdog 心跳监控名称，通过`watchdog.Register`注册
+	Watchdog string
+	//Key 心跳key，可以使用 ${metaKeyName} 替换元数据中的变量
+	Key string
+	//TimeoutMs 超时时间，单位毫秒，0则使用心跳监控的默认超时时间
+	TimeoutMs int64
+}
+
+// HeartbeatNode 向心跳监控(`components/watchdog`)上报key的心跳，超时没有心跳则由心跳监控向指定规则链发送超时事件
+// 超时事件消息带有最后一次心跳消息的元数据
+// 如果心跳监控不存在，发送消息到`Failure`链, 否则发到`Success`链
+type HeartbeatNode struct {
+	config  HeartbeatNodeConfiguration
+	timeout time.Duration
+}
+
+// Type 组件类型
+func (x *HeartbeatNode) Type() string {
+	return "heartbeat"
+}
+
+// Descriptor 组件描述
+func (x *HeartbeatNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"heartbeat", "watchdog", "offline", "inactivity"},
+		Description: "向心跳监控上报心跳，超时后发送超时事件到指定规则链",
+	}
+}
+
+func (x *HeartbeatNode) New() types.Node {
+	return &HeartbeatNode{}
+}
+
+// Init 初始化
+func (x *HeartbeatNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Watchdog == "" || x.config.Key == "" {
+		return errors.New("watchdog and key can not empty")
+	}
+	x.timeout = time.Duration(x.config.TimeoutMs) * time.Millisecond
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *HeartbeatNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	w, ok := watchdog.Get(x.config.Watchdog)
+	if !ok {
+		err := errors.New("watchdog not found:" + x.config.Watchdog)
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	metaData := msg.Metadata.Values()
+	w.Beat(str.SprintfDict(x.config.Key, metaData), x.timeout, metaData)
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+// Destroy 销毁
+func (x *HeartbeatNode) Destroy() {
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/watchdog"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"testing"
+	"time"
+)
+
+type heartbeatTarget struct {
+	msgs []types.RuleMsg
+}
+
+func (t *heartbeatTarget) OnMsg(msg types.RuleMsg) {
+	t.msgs = append(t.msgs, msg)
+}
+
+func TestHeartbeatNodeOnMsg(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	target := &heartbeatTarget{}
+	w, err := watchdog.New(target, watchdog.Config{Timeout: time.Minute, Clock: vc})
+	assert.Nil(t, err)
+	defer w.Stop()
+	watchdog.Register("heartbeatTest", w)
+	defer watchdog.Unregister("heartbeatTest")
+
+	node := (&HeartbeatNode{}).New()
+	err = node.Init(types.NewConfig(), types.Configuration{
+		"watchdog":  "heartbeatTest",
+		"key":       "${deviceId}",
+		"timeoutMs": 120000,
+	})
+	assert.Nil(t, err)
+	var relation string
+	ctx := test.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metaData, "{}")))
+	assert.Equal(t, types.Success, relation)
+	_, ok := w.LastSeen("aa")
+	assert.True(t, ok)
+
+	vc.Advance(time.Minute)
+	assert.Equal(t, 0, len(target.msgs))
+	vc.Advance(time.Minute)
+	assert.Equal(t, 1, len(target.msgs))
+	assert.Equal(t, watchdog.DefaultMsgType, target.msgs[0].Type)
+	assert.Equal(t, "aa", target.msgs[0].Metadata.GetValue("deviceId"))
+
+	//心跳监控不存在
+	node2 := (&HeartbeatNode{}).New()
+	err = node2.Init(types.NewConfig(), types.Configuration{"watchdog": "notFound", "key": "${deviceId}"})
+	assert.Nil(t, err)
+	assert.NotNil(t, node2.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metaData, "{}")))
+	assert.Equal(t, types.Failure, relation)
+	assert.NotNil(t, (&HeartbeatNode{}).New().Init(types.NewConfig(), types.Configuration{}))
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"context"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"github.com/oschwald/maxminddb-golang"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "ipLookup",
+//	       "name": "IP地址富化",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "ip": "${srcIp}",
+//	         "reverseDns": true,
+//	         "cityDbFile": "./GeoLite2-City.mmdb",
+//	         "asnDbFile": "./GeoLite2-ASN.mmdb"
+//	       }
+//	     }
+func init() {
+	Registry.Add(&IpLookupNode{})
+}
+
+// IpLookupNodeConfiguration 节点配置
+type IpLookupNodeConfiguration struct {
+	//Ip 需要查询的IP地址，可以使用 ${metaKeyName} 替换元数据中的变量，默认：${ip}
+	Ip string
+	//ReverseDns 是否进行反向DNS查询
+	ReverseDns bool
+	//DnsServer 反向DNS查询使用的DNS服务器，例如：8.8.8.8:53，为空则使用系统配置
+	DnsServer string
+	//TimeoutMs 反向DNS查询超时，单位毫秒，默认2000
+	TimeoutMs int
+	//CityDbFile MaxMind City或者Country数据库文件，例如：GeoLite2-City.mmdb
+	CityDbFile string
+	//AsnDbFile MaxMind ASN数据库文件，例如：GeoLite2-ASN.mmdb
+	AsnDbFile string
+	//Language 国家和城市名称的语言，默认：en
+	Language string
+	//MetadataPrefix 查询结果放到元数据时key的前缀，默认：ip_
+	MetadataPrefix string
+}
+
+// geoIpRecord MaxMind City/Country数据库记录
+type geoIpRecord struct {
+	Country struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// asnRecord MaxMind ASN数据库记录
+type asnRecord struct {
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// IpLookupNode 对IP地址进行反向DNS和GeoIP(MaxMind数据库)查询，把结果放到元数据
+// 元数据key(默认前缀ip_)：ip_hostname、ip_country、ip_countryName、ip_city、ip_latitude、ip_longitude、ip_asn、ip_asOrg
+// 没有查询到的字段不会放到元数据，反向DNS查询失败不影响消息继续流转
+// 如果IP地址无效或者数据库查询错误，发送消息到`Failure`链, 否则发到`Success`链
+type IpLookupNode struct {
+	config   IpLookupNodeConfiguration
+	cityDb   *maxminddb.Reader
+	asnDb    *maxminddb.Reader
+	resolver *net.Resolver
+	timeout  time.Duration
+}
+
+// Type 组件类型
+func (x *IpLookupNode) Type() string {
+	return "ipLookup"
+}
+
+// Descriptor 组件描述
+func (x *IpLookupNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"ip", "dns", "geoip", "enrichment"},
+		Description: "对IP地址进行反向DNS和GeoIP查询",
+	}
+}
+
+func (x *IpLookupNode) New() types.Node {
+	return &IpLookupNode{config: IpLookupNodeConfiguration{
+		Ip:             "${ip}",
+		TimeoutMs:      2000,
+		Language:       "en",
+		MetadataPrefix: "ip_",
+	}}
+}
+
+// Init 初始化
+func (x *IpLookupNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if !x.config.ReverseDns && x.config.CityDbFile == "" && x.config.AsnDbFile == "" {
+		return errors.New("reverseDns, cityDbFile or asnDbFile must be configured")
+	}
+	if x.config.TimeoutMs <= 0 {
+		x.config.TimeoutMs = 2000
+	}
+	x.timeout = time.Duration(x.config.TimeoutMs) * time.Millisecond
+	if x.config.DnsServer != "" {
+		server := x.config.DnsServer
+		x.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	} else {
+		x.resolver = net.DefaultResolver
+	}
+	if x.config.CityDbFile != "" {
+		if x.cityDb, err = maxminddb.Open(x.config.CityDbFile); err != nil {
+			return err
+		}
+	}
+	if x.config.AsnDbFile != "" {
+		if x.asnDb, err = maxminddb.Open(x.config.AsnDbFile); err != nil {
+			x.Destroy()
+			return err
+		}
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *IpLookupNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	ipStr := strings.TrimSpace(str.SprintfDict(x.config.Ip, msg.Metadata.Values()))
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		err := errors.New("invalid ip:" + ipStr)
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	prefix := x.config.MetadataPrefix
+	if x.cityDb != nil {
+		var record geoIpRecord
+		if err := x.cityDb.Lookup(ip, &record); err != nil {
+			ctx.TellFailure(msg, err)
+			return err
+		}
+		x.putValue(msg, prefix+"country", record.Country.IsoCode)
+		x.putValue(msg, prefix+"countryName", x.name(record.Country.Names))
+		x.putValue(msg, prefix+"city", x.name(record.City.Names))
+		if record.Location.Latitude != 0 || record.Location.Longitude != 0 {
+			msg.Metadata.PutValue(prefix+"latitude", strconv.FormatFloat(record.Location.Latitude, 'f', -1, 64))
+			msg.Metadata.PutValue(prefix+"longitude", strconv.FormatFloat(record.Location.Longitude, 'f', -1, 64))
+		}
+	}
+	if x.asnDb != nil {
+		var record asnRecord
+		if err := x.asnDb.Lookup(ip, &record); err != nil {
+			ctx.TellFailure(msg, err)
+			return err
+		}
+		if record.AutonomousSystemNumber != 0 {
+			msg.Metadata.PutValue(prefix+"asn", strconv.FormatUint(uint64(record.AutonomousSystemNumber), 10))
+		}
+		x.putValue(msg, prefix+"asOrg", record.AutonomousSystemOrganization)
+	}
+	if x.config.ReverseDns {
+		x.putValue(msg, prefix+"hostname", x.lookupHostname(ipStr))
+	}
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+// Destroy 销毁
+func (x *IpLookupNode) Destroy() {
+	if x.cityDb != nil {
+		_ = x.cityDb.Close()
+		x.cityDb = nil
+	}
+	if x.asnDb != nil {
+		_ = x.asnDb.Close()
+		x.asnDb = nil
+	}
+}
+
+// lookupHostname 反向DNS查询，返回第一个主机名，查询失败返回空
+func (x *IpLookupNode) lookupHostname(ip string) string {
+	c, cancel := context.WithTimeout(context.Background(), x.timeout)
+	defer cancel()
+	names, err := x.resolver.LookupAddr(c, ip)
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
+
+// name 获取指定语言的名称，没有则使用英文名称
+func (x *IpLookupNode) name(names map[string]string) string {
+	if v, ok := names[x.config.Language]; ok {
+		return v
+	}
+	return names["en"]
+}
+
+func (x *IpLookupNode) putValue(msg types.RuleMsg, key, value string) {
+	if value != "" {
+		msg.Metadata.PutValue(key, value)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mmdbTestValue 编码MaxMind DB数据段的值，只支持测试用到的类型
+func mmdbTestValue(v interface{}) []byte {
+	var buf bytes.Buffer
+	switch value := v.(type) {
+	case string:
+		if len(value) < 29 {
+			buf.WriteByte(2<<5 | byte(len(value)))
+		} else {
+			buf.Write([]byte{2<<5 | 29, byte(len(value) - 29)})
+		}
+		buf.WriteString(value)
+	case uint32:
+		buf.WriteByte(6<<5 | 4)
+		_ = binary.Write(&buf, binary.BigEndian, value)
+	case uint16:
+		buf.WriteByte(5<<5 | 2)
+		_ = binary.Write(&buf, binary.BigEndian, value)
+	case float64:
+		buf.WriteByte(3<<5 | 8)
+		_ = binary.Write(&buf, binary.BigEndian, math.Float64bits(value))
+	case []string:
+		//扩展类型：array=11
+		buf.WriteByte(byte(len(value)))
+		buf.WriteByte(11 - 7)
+		for _, item := range value {
+			buf.Write(mmdbTestValue(item))
+		}
+	case map[string]interface{}:
+		buf.WriteByte(7<<5 | byte(len(value)))
+		for k, item := range value {
+			buf.Write(mmdbTestValue(k))
+			buf.Write(mmdbTestValue(item))
+		}
+	}
+	return buf.Bytes()
+}
+
+// writeTestMmdb 生成只包含一个IPv4 /24网段的MaxMind DB文件
+func writeTestMmdb(t *testing.T, file string, network string, data map[string]interface{}) {
+	_, ipNet, err := net.ParseCIDR(network)
+	assert.Nil(t, err)
+	ones, _ := ipNet.Mask.Size()
+	ip := ipNet.IP.To4()
+	nodeCount := uint32(ones)
+	var buf bytes.Buffer
+	//搜索树，record size 24，匹配的分支指向下一个节点，最后一个节点指向数据段，另一个分支为空
+	for i := 0; i < ones; i++ {
+		bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+		next := uint32(i + 1)
+		if i == ones-1 {
+			next = nodeCount + 16
+		}
+		records := [2]uint32{nodeCount, nodeCount}
+		records[bit] = next
+		for _, record := range records {
+			buf.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+		}
+	}
+	buf.Write(make([]byte, 16))
+	buf.Write(mmdbTestValue(data))
+	buf.WriteString("\xab\xcd\xefMaxMind.com")
+	buf.Write(mmdbTestValue(map[string]interface{}{
+		"node_count":                  nodeCount,
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(4),
+		"database_type":               "Test",
+		"languages":                   []string{"en"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint32(1),
+	}))
+	assert.Nil(t, os.WriteFile(file, buf.Bytes(), 0644))
+}
+
+// startTestDnsServer 只应答指定PTR查询的DNS服务
+func startTestDnsServer(t *testing.T, ptrName, hostname string) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	var rdata []byte
+	for _, label := range bytes.Split([]byte(hostname), []byte(".")) {
+		rdata = append(rdata, byte(len(label)))
+		rdata = append(rdata, label...)
+	}
+	rdata = append(rdata, 0)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := buf[:n]
+			//解析问题部分的域名
+			var name []byte
+			pos := 12
+			for pos < n && query[pos] != 0 {
+				l := int(query[pos])
+				name = append(name, query[pos+1:pos+1+l]...)
+				name = append(name, '.')
+				pos += l + 1
+			}
+			questionEnd := pos + 5
+			var resp bytes.Buffer
+			resp.Write(query[:2])
+			resp.Write([]byte{0x81, 0x80, 0, 1})
+			qtype := binary.BigEndian.Uint16(query[pos+1:])
+			if string(name) == ptrName && qtype == 12 {
+				resp.Write([]byte{0, 1, 0, 0, 0, 0})
+				resp.Write(query[12:questionEnd])
+				resp.Write([]byte{0xc0, 0x0c, 0, 12, 0, 1, 0, 0, 0, 60})
+				_ = binary.Write(&resp, binary.BigEndian, uint16(len(rdata)))
+				resp.Write(rdata)
+			} else {
+				resp.Write([]byte{0, 0, 0, 0, 0, 0})
+				resp.Write(query[12:questionEnd])
+			}
+			_, _ = conn.WriteTo(resp.Bytes(), addr)
+		}
+	}()
+	return conn
+}
+
+func TestIpLookupNodeOnMsg(t *testing.T) {
+	dir := t.TempDir()
+	cityDbFile := filepath.Join(dir, "city.mmdb")
+	asnDbFile := filepath.Join(dir, "asn.mmdb")
+	writeTestMmdb(t, cityDbFile, "81.2.69.0/24", map[string]interface{}{
+		"country": map[string]interface{}{
+			"iso_code": "GB",
+			"names":    map[string]interface{}{"en": "United Kingdom", "zh-CN": "英国"},
+		},
+		"city": map[string]interface{}{
+			"names": map[string]interface{}{"en": "London"},
+		},
+		"location": map[string]interface{}{
+			"latitude":  51.5142,
+			"longitude": -0.0931,
+		},
+	})
+	writeTestMmdb(t, asnDbFile, "81.2.69.0/24", map[string]interface{}{
+		"autonomous_system_number":       uint32(20712),
+		"autonomous_system_organization": "Andrews & Arnold Ltd",
+	})
+	dnsServer := startTestDnsServer(t, "142.69.2.81.in-addr.arpa.", "host.example.com.")
+	defer dnsServer.Close()
+
+	config := types.NewConfig()
+	node := (&IpLookupNode{}).New()
+	err := node.Init(config, types.Configuration{
+		"ip":         "${srcIp}",
+		"reverseDns": true,
+		"dnsServer":  dnsServer.LocalAddr().String(),
+		"cityDbFile": cityDbFile,
+		"asnDbFile":  asnDbFile,
+		"language":   "zh-CN",
+	})
+	assert.Nil(t, err)
+	defer node.Destroy()
+
+	var relation string
+	var result types.RuleMsg
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		result = msg
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("srcIp", "81.2.69.142")
+	err = node.OnMsg(ctx, ctx.NewMsg("LOGIN", metaData, "{}"))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, "GB", result.Metadata.GetValue("ip_country"))
+	assert.Equal(t, "英国", result.Metadata.GetValue("ip_countryName"))
+	//没有指定语言的名称，使用英文名称
+	assert.Equal(t, "London", result.Metadata.GetValue("ip_city"))
+	assert.Equal(t, "51.5142", result.Metadata.GetValue("ip_latitude"))
+	assert.Equal(t, "-0.0931", result.Metadata.GetValue("ip_longitude"))
+	assert.Equal(t, "20712", result.Metadata.GetValue("ip_asn"))
+	assert.Equal(t, "Andrews & Arnold Ltd", result.Metadata.GetValue("ip_asOrg"))
+	assert.Equal(t, "host.example.com", result.Metadata.GetValue("ip_hostname"))
+
+	//不在数据库中的IP
+	metaData = types.NewMetadata()
+	metaData.PutValue("srcIp", "81.2.70.1")
+	err = node.OnMsg(ctx, ctx.NewMsg("LOGIN", metaData, "{}"))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relation)
+	assert.False(t, result.Metadata.Has("ip_country"))
+	assert.False(t, result.Metadata.Has("ip_hostname"))
+
+	//无效IP
+	metaData = types.NewMetadata()
+	metaData.PutValue("srcIp", "aa")
+	err = node.OnMsg(ctx, ctx.NewMsg("LOGIN", metaData, "{}"))
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relation)
+}
+
+func TestIpLookupNodeInit(t *testing.T) {
+	config := types.NewConfig()
+	assert.NotNil(t, (&IpLookupNode{}).New().Init(config, types.Configuration{}))
+	assert.NotNil(t, (&IpLookupNode{}).New().Init(config, types.Configuration{"cityDbFile": "./not_exist.mmdb"}))
+}
@@ -28,7 +28,6 @@ package action
 //  }
 import (
 	"errors"
-	"fmt"
 	"github.com/2018yuli/rulego/api/types"
 	"github.com/2018yuli/rulego/components/js"
 	"github.com/2018yuli/rulego/utils/json"
@@ -47,6 +46,9 @@ type LogNodeConfiguration struct {
 	//return 'Incoming message:\n' + JSON.stringify(msg) + '\nIncoming metadata:\n' + JSON.stringify(metadata);
 	//完整脚本函数：
 	//"function ToString(msg, metadata, msgType) { ${JsScript} }"
+	//脚本使用了await则声明为async函数，可以使用fetch访问http接口，参考`types.Config.JsFetch`
+	//脚本可以通过state.get/put读写当前节点的状态，通过metrics.inc/observe记录指标，
+	//store.get/put为跨规则链共享的存储，不是节点状态，参考js包说明
 	//脚本返回值string
 	JsScript string
 }
@@ -71,6 +73,14 @@ func (x *LogNode) Type() string {
 	return "log"
 }
 
+// Descriptor 组件描述
+func (x *LogNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"log", "js"},
+		Description: "使用JS脚本把消息转换成字符串并记录日志",
+	}
+}
+
 func (x *LogNode) New() types.Node {
 	return &LogNode{}
 }
@@ -79,7 +89,7 @@ func (x *LogNode) New() types.Node {
 func (x *LogNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &x.config)
 	if err == nil {
-		jsScript := fmt.Sprintf("function ToString(msg, metadata, msgType) { %s }", x.config.JsScript)
+		jsScript := js.FuncScript("ToString", "msg, metadata, msgType", x.config.JsScript)
 		x.jsEngine = js.NewGojaJsEngine(ruleConfig, jsScript, nil)
 	}
 	x.logger = ruleConfig.Logger
@@ -95,7 +105,7 @@ func (x *LogNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
 			data = dataMap
 		}
 	}
-	out, err := x.jsEngine.Execute("ToString", data, msg.Metadata, msg.Type)
+	out, err := js.Execute(ctx, x.jsEngine, "ToString", data, msg.Metadata, msg.Type)
 	if err != nil {
 		ctx.TellFailure(msg, err)
 	} else {
@@ -110,6 +120,14 @@ func (x *LogNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
 	return err
 }
 
+// Metrics 实现types.MetricsProvider，返回脚本通过metrics对象记录的指标
+func (x *LogNode) Metrics() map[string]interface{} {
+	if x.jsEngine == nil {
+		return nil
+	}
+	return js.Metrics(x.jsEngine)
+}
+
 // Destroy 销毁
 func (x *LogNode) Destroy() {
 	x.jsEngine.Stop()
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/kv"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"time"
+)
+
+const (
+	KvStoreOperationGet    = "get"
+	KvStoreOperationPut    = "put"
+	KvStoreOperationDelete = "delete"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "kvStore",
+//	       "name": "设置告警标志",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "operation": "put",
+//	         "key": "alarm_${deviceId}",
+//	         "value": "true",
+//	         "ttlMs": 60000
+//	       }
+//	     }
+func init() {
+	Registry.Add(&KvStoreNode{})
+}
+
+// KvStoreNodeConfiguration 节点配置
+type KvStoreNodeConfiguration struct {
+	//Operation 操作：get、put、delete，默认：get
+	Operation string
+	//Key 可以使用 ${metaKeyName} 替换元数据中的变量
+	Key string
+	//Value put操作保存的值，可以使用 ${metaKeyName} 替换元数据中的变量，为空则保存msg.Data
+	Value string
+	//TtlMs put操作的过期时间，单位毫秒，0表示永不过期
+	TtlMs int64
+	//Namespace 命名空间，可以使用 ${metaKeyName} 替换元数据中的变量，默认使用规则引擎配置的租户：Config.Tenant
+	Namespace string
+	//MetadataKey get操作查询结果放到元数据的key，默认：value
+	MetadataKey string
+}
+
+// KvStoreNode 读写跨规则链共享的key/value存储(`Config.KVStore`)，用于不同规则链之间的协作
+// get操作：如果存在，把值放到元数据并发送消息到`True`链，否则发到`False`链
+// put和delete操作：发送消息到`Success`链
+// 存储读写错误，发送消息到`Failure`链
+type KvStoreNode struct {
+	config KvStoreNodeConfiguration
+	store  kv.Store
+	tenant string
+}
+
+// Type 组件类型
+func (x *KvStoreNode) Type() string {
+	return "kvStore"
+}
+
+// Descriptor 组件描述
+func (x *KvStoreNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"kv", "store", "cache", "state"},
+		Description: "读写跨规则链共享的key/value存储",
+	}
+}
+
+func (x *KvStoreNode) New() types.Node {
+	return &KvStoreNode{config: KvStoreNodeConfiguration{
+		Operation:   KvStoreOperationGet,
+		MetadataKey: "value",
+	}}
+}
+
+// Init 初始化
+func (x *KvStoreNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Key == "" {
+		return errors.New("key can not empty")
+	}
+	switch x.config.Operation {
+	case KvStoreOperationGet, KvStoreOperationPut, KvStoreOperationDelete:
+	default:
+		return errors.New("unsupported operation:" + x.config.Operation)
+	}
+	x.store = ruleConfig.GetKVStore()
+	x.tenant = ruleConfig.Tenant
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *KvStoreNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	metaData := msg.Metadata.Values()
+	namespace := x.tenant
+	if x.config.Namespace != "" {
+		namespace = str.SprintfDict(x.config.Namespace, metaData)
+	}
+	key := str.SprintfDict(x.config.Key, metaData)
+	var err error
+	switch x.config.Operation {
+	case KvStoreOperationPut:
+		value := msg.Data
+		if x.config.Value != "" {
+			value = str.SprintfDict(x.config.Value, metaData)
+		}
+		err = x.store.Put(namespace, key, value, time.Duration(x.config.TtlMs)*time.Millisecond)
+	case KvStoreOperationDelete:
+		err = x.store.Delete(namespace, key)
+	default:
+		var value string
+		var ok bool
+		if value, ok, err = x.store.Get(namespace, key); err == nil {
+			if ok {
+				msg.Metadata.PutValue(x.config.MetadataKey, value)
+				ctx.TellNext(msg, types.True)
+			} else {
+				ctx.TellNext(msg, types.False)
+			}
+			return nil
+		}
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (x *KvStoreNode) Destroy() {
+}
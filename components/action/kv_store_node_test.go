@@ -0,0 +1,81 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/kv"
+	"testing"
+	"time"
+)
+
+func TestKvStoreNodeOnMsg(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	config := types.NewConfig(types.WithKVStore(kv.NewMemory(vc)), types.WithTenant("tenant01"))
+
+	putNode := (&KvStoreNode{}).New()
+	err := putNode.Init(config, types.Configuration{
+		"operation": "put",
+		"key":       "alarm_${deviceId}",
+		"ttlMs":     60000,
+	})
+	assert.Nil(t, err)
+	getNode := (&KvStoreNode{}).New()
+	err = getNode.Init(config, types.Configuration{
+		"key":         "alarm_${deviceId}",
+		"metadataKey": "alarm",
+	})
+	assert.Nil(t, err)
+
+	var relation string
+	var result types.RuleMsg
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		result = msg
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+
+	assert.Nil(t, getNode.OnMsg(ctx, ctx.NewMsg("TEST", metaData, "")))
+	assert.Equal(t, types.False, relation)
+
+	assert.Nil(t, putNode.OnMsg(ctx, ctx.NewMsg("TEST", metaData, "high")))
+	assert.Equal(t, types.Success, relation)
+	v, ok, _ := config.KVStore.Get("tenant01", "alarm_aa")
+	assert.True(t, ok)
+	assert.Equal(t, "high", v)
+
+	assert.Nil(t, getNode.OnMsg(ctx, ctx.NewMsg("TEST", metaData, "")))
+	assert.Equal(t, types.True, relation)
+	assert.Equal(t, "high", result.Metadata.GetValue("alarm"))
+
+	//过期
+	vc.Advance(time.Minute)
+	assert.Nil(t, getNode.OnMsg(ctx, ctx.NewMsg("TEST", metaData, "")))
+	assert.Equal(t, types.False, relation)
+}
+
+func TestKvStoreNodeInitError(t *testing.T) {
+	node := (&KvStoreNode{}).New()
+	err := node.Init(types.NewConfig(), types.Configuration{})
+	assert.NotNil(t, err)
+	err = node.Init(types.NewConfig(), types.Configuration{"key": "a", "operation": "incr"})
+	assert.NotNil(t, err)
+}
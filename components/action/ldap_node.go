@@ -0,0 +1,304 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"github.com/go-ldap/ldap/v3"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//LdapModeSearch 查询模式，把查询到的条目属性放到元数据
+	LdapModeSearch = "search"
+	//LdapModeAuth 认证模式，校验用户名密码
+	LdapModeAuth = "auth"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "ldap",
+//	       "name": "查询用户信息",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "server": "ldap://127.0.0.1:389",
+//	         "bindDn": "cn=admin,dc=example,dc=com",
+//	         "bindPassword": "admin",
+//	         "baseDn": "ou=users,dc=example,dc=com",
+//	         "filter": "(uid=${username})",
+//	         "attributes": "cn,mail,memberOf"
+//	       }
+//	     }
+func init() {
+	Registry.Add(&LdapNode{})
+}
+
+// LdapNodeConfiguration 节点配置
+type LdapNodeConfiguration struct {
+	//Server LDAP服务地址，例如：ldap://127.0.0.1:389 或者 ldaps://127.0.0.1:636
+	Server string
+	//StartTLS 是否使用StartTLS升级连接
+	StartTLS bool
+	//InsecureSkipVerify 是否跳过服务端证书校验
+	InsecureSkipVerify bool
+	//BindDn 服务账号DN，为空则匿名查询
+	BindDn string
+	//BindPassword 服务账号密码
+	BindPassword string `sensitive:"true"`
+	//Mode 模式：search(查询条目属性) 或者 auth(校验用户名密码)，默认search
+	Mode string
+	//BaseDn 查询根DN
+	BaseDn string
+	//Filter 查询过滤器，可以使用 ${metaKeyName} 替换元数据中的变量，替换的值会进行转义
+	//例如：(uid=${username})
+	Filter string
+	//Attributes 返回的属性，多个使用逗号分隔，为空则返回全部属性
+	Attributes string
+	//Scope 查询范围：sub(子树)、one(一级)、base(根DN)，默认sub
+	Scope string
+	//SizeLimit 最多返回的条目数，0表示不限制
+	SizeLimit int
+	//MetadataPrefix 属性放到元数据时key的前缀，默认：ldap_
+	MetadataPrefix string
+	//Password auth模式下用户密码，可以使用 ${metaKeyName} 替换元数据中的变量
+	Password string
+	//TimeoutMs 连接和请求超时，单位毫秒，默认5000
+	TimeoutMs int
+}
+
+// LdapNode 查询LDAP目录或者校验用户凭证
+// search模式：通过filter查询目录，把第一个条目的属性放到元数据，多值属性使用逗号连接
+// 查询到条目发送消息到`Success`链，否则发送到`Failure`链
+// auth模式：通过filter查询用户DN，然后使用Password绑定该DN
+// 凭证有效发送消息到`True`链，无效发送到`False`链，其他错误发送到`Failure`链
+type LdapNode struct {
+	config     LdapNodeConfiguration
+	attributes []string
+	scope      int
+	timeout    time.Duration
+	conn       *ldap.Conn
+	lock       sync.Mutex
+}
+
+// Type 组件类型
+func (x *LdapNode) Type() string {
+	return "ldap"
+}
+
+// Descriptor 组件描述
+func (x *LdapNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"ldap", "auth", "enrichment"},
+		Description: "查询LDAP目录或者校验用户凭证",
+	}
+}
+
+func (x *LdapNode) New() types.Node {
+	return &LdapNode{config: LdapNodeConfiguration{
+		Mode:           LdapModeSearch,
+		Scope:          "sub",
+		MetadataPrefix: "ldap_",
+		TimeoutMs:      5000,
+	}}
+}
+
+// Init 初始化
+func (x *LdapNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Server == "" {
+		return errors.New("server can not empty")
+	}
+	if x.config.Filter == "" {
+		return errors.New("filter can not empty")
+	}
+	if x.config.Mode != LdapModeSearch && x.config.Mode != LdapModeAuth {
+		return errors.New("unsupported mode:" + x.config.Mode)
+	}
+	switch strings.ToLower(x.config.Scope) {
+	case "", "sub":
+		x.scope = ldap.ScopeWholeSubtree
+	case "one":
+		x.scope = ldap.ScopeSingleLevel
+	case "base":
+		x.scope = ldap.ScopeBaseObject
+	default:
+		return errors.New("unsupported scope:" + x.config.Scope)
+	}
+	for _, item := range strings.Split(x.config.Attributes, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			x.attributes = append(x.attributes, item)
+		}
+	}
+	if x.config.TimeoutMs <= 0 {
+		x.config.TimeoutMs = 5000
+	}
+	x.timeout = time.Duration(x.config.TimeoutMs) * time.Millisecond
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *LdapNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	filter := x.filter(msg.Metadata.Values())
+	entry, err := x.searchEntry(filter)
+	if x.config.Mode == LdapModeAuth {
+		return x.auth(ctx, msg, entry, err)
+	}
+	if err == nil && entry == nil {
+		err = errors.New("ldap entry not found:" + filter)
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	prefix := x.config.MetadataPrefix
+	msg.Metadata.PutValue(prefix+"dn", entry.DN)
+	for _, attr := range entry.Attributes {
+		msg.Metadata.PutValue(prefix+attr.Name, strings.Join(attr.Values, ","))
+	}
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+// Destroy 销毁
+func (x *LdapNode) Destroy() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.conn != nil {
+		x.conn.Close()
+		x.conn = nil
+	}
+}
+
+// auth 使用用户DN和密码绑定，校验凭证是否有效
+func (x *LdapNode) auth(ctx types.RuleContext, msg types.RuleMsg, entry *ldap.Entry, err error) error {
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	password := str.SprintfDict(x.config.Password, msg.Metadata.Values())
+	//空密码会被服务端当成匿名绑定而返回成功
+	if entry == nil || password == "" {
+		ctx.TellNext(msg, types.False)
+		return nil
+	}
+	conn, err := x.dial()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	defer conn.Close()
+	if err = conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			ctx.TellNext(msg, types.False)
+			return nil
+		}
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	msg.Metadata.PutValue(x.config.MetadataPrefix+"dn", entry.DN)
+	ctx.TellNext(msg, types.True)
+	return nil
+}
+
+// filter 替换过滤器中的变量，变量值会进行转义，防止注入
+func (x *LdapNode) filter(metaData map[string]interface{}) string {
+	escaped := make(map[string]interface{}, len(metaData))
+	for k, v := range metaData {
+		escaped[k] = ldap.EscapeFilter(str.ToString(v))
+	}
+	return str.SprintfDict(x.config.Filter, escaped)
+}
+
+// searchEntry 查询第一个匹配的条目，没有匹配则返回nil
+// 如果连接已经断开，重连后重试一次
+func (x *LdapNode) searchEntry(filter string) (*ldap.Entry, error) {
+	request := ldap.NewSearchRequest(x.config.BaseDn, x.scope, ldap.NeverDerefAliases,
+		x.config.SizeLimit, x.config.TimeoutMs/1000, false, filter, x.attributes, nil)
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	var result *ldap.SearchResult
+	var err error
+	for i := 0; i < 2; i++ {
+		if x.conn == nil || x.conn.IsClosing() {
+			if x.conn, err = x.dialAndBind(); err != nil {
+				x.conn = nil
+				return nil, err
+			}
+		}
+		result, err = x.conn.Search(request)
+		if err == nil || !ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+			break
+		}
+		x.conn.Close()
+		x.conn = nil
+	}
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, err
+	}
+	if result == nil || len(result.Entries) == 0 {
+		return nil, nil
+	}
+	return result.Entries[0], nil
+}
+
+// dialAndBind 建立连接并使用服务账号绑定
+func (x *LdapNode) dialAndBind() (*ldap.Conn, error) {
+	conn, err := x.dial()
+	if err != nil {
+		return nil, err
+	}
+	if x.config.BindDn != "" {
+		if err = conn.Bind(x.config.BindDn, x.config.BindPassword); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (x *LdapNode) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: x.config.InsecureSkipVerify}
+	conn, err := ldap.DialURL(x.config.Server,
+		ldap.DialWithDialer(&net.Dialer{Timeout: x.timeout}), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(x.timeout)
+	if x.config.StartTLS {
+		if u, parseErr := url.Parse(x.config.Server); parseErr == nil {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
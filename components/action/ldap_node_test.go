@@ -0,0 +1,241 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"net"
+	"testing"
+)
+
+// ldapTestServer 最简单的LDAP服务，只支持简单绑定和等值查询
+type ldapTestServer struct {
+	listener net.Listener
+	//dn->password
+	users map[string]string
+	//dn->attributes
+	entries map[string]map[string][]string
+}
+
+func newLdapTestServer(t *testing.T) *ldapTestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	s := &ldapTestServer{
+		listener: listener,
+		users: map[string]string{
+			"cn=admin,dc=example,dc=com":           "admin",
+			"uid=alice,ou=users,dc=example,dc=com": "secret",
+		},
+		entries: map[string]map[string][]string{
+			"uid=alice,ou=users,dc=example,dc=com": {
+				"uid":      {"alice"},
+				"cn":       {"Alice"},
+				"mail":     {"alice@example.com"},
+				"memberOf": {"admins", "users"},
+			},
+		},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *ldapTestServer) Url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *ldapTestServer) Close() {
+	_ = s.listener.Close()
+}
+
+func (s *ldapTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageId := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Data.String()
+			password := op.Children[2].Data.String()
+			code := ldap.LDAPResultSuccess
+			if expected, ok := s.users[dn]; !ok || expected != password {
+				code = ldap.LDAPResultInvalidCredentials
+			}
+			s.write(conn, messageId, ldapTestResult(ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			for dn, attributes := range s.entries {
+				if filter != "(uid="+attributes["uid"][0]+")" {
+					continue
+				}
+				entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+				entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, ""))
+				attrs := ber.NewSequence("")
+				for name, values := range attributes {
+					attr := ber.NewSequence("")
+					attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+					set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+					for _, v := range values {
+						set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+					}
+					attr.AppendChild(set)
+					attrs.AppendChild(attr)
+				}
+				entry.AppendChild(attrs)
+				s.write(conn, messageId, entry)
+			}
+			s.write(conn, messageId, ldapTestResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *ldapTestServer) write(conn net.Conn, messageId int64, op *ber.Packet) {
+	packet := ber.NewSequence("")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId, ""))
+	packet.AppendChild(op)
+	_, _ = conn.Write(packet.Bytes())
+}
+
+func ldapTestResult(tag ber.Tag, code int) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return result
+}
+
+func TestLdapNodeSearch(t *testing.T) {
+	server := newLdapTestServer(t)
+	defer server.Close()
+
+	config := types.NewConfig()
+	node := (&LdapNode{}).New()
+	err := node.Init(config, types.Configuration{
+		"server":       server.Url(),
+		"bindDn":       "cn=admin,dc=example,dc=com",
+		"bindPassword": "admin",
+		"baseDn":       "ou=users,dc=example,dc=com",
+		"filter":       "(uid=${username})",
+	})
+	assert.Nil(t, err)
+	defer node.Destroy()
+
+	var relation string
+	var result types.RuleMsg
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		result = msg
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("username", "alice")
+	err = node.OnMsg(ctx, ctx.NewMsg("LOGIN", metaData, "{}"))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, "uid=alice,ou=users,dc=example,dc=com", result.Metadata.GetValue("ldap_dn"))
+	assert.Equal(t, "alice@example.com", result.Metadata.GetValue("ldap_mail"))
+	assert.Equal(t, "admins,users", result.Metadata.GetValue("ldap_memberOf"))
+
+	//变量值会被转义，不能注入通配符
+	metaData = types.NewMetadata()
+	metaData.PutValue("username", "ali*")
+	err = node.OnMsg(ctx, ctx.NewMsg("LOGIN", metaData, "{}"))
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relation)
+
+	//服务账号密码错误
+	node = (&LdapNode{}).New()
+	err = node.Init(config, types.Configuration{
+		"server":       server.Url(),
+		"bindDn":       "cn=admin,dc=example,dc=com",
+		"bindPassword": "bad",
+		"filter":       "(uid=${username})",
+	})
+	assert.Nil(t, err)
+	err = node.OnMsg(ctx, ctx.NewMsg("LOGIN", metaData, "{}"))
+	assert.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials))
+	assert.Equal(t, types.Failure, relation)
+}
+
+func TestLdapNodeAuth(t *testing.T) {
+	server := newLdapTestServer(t)
+	defer server.Close()
+
+	config := types.NewConfig()
+	node := (&LdapNode{}).New()
+	err := node.Init(config, types.Configuration{
+		"server":       server.Url(),
+		"mode":         LdapModeAuth,
+		"bindDn":       "cn=admin,dc=example,dc=com",
+		"bindPassword": "admin",
+		"filter":       "(uid=${username})",
+		"password":     "${password}",
+	})
+	assert.Nil(t, err)
+	defer node.Destroy()
+
+	var relation string
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+	})
+	tests := []struct {
+		username string
+		password string
+		relation string
+	}{
+		{"alice", "secret", types.True},
+		{"alice", "wrong", types.False},
+		{"alice", "", types.False},
+		{"bob", "secret", types.False},
+	}
+	for _, item := range tests {
+		metaData := types.NewMetadata()
+		metaData.PutValue("username", item.username)
+		metaData.PutValue("password", item.password)
+		err = node.OnMsg(ctx, ctx.NewMsg("LOGIN", metaData, "{}"))
+		assert.Nil(t, err)
+		assert.Equal(t, item.relation, relation)
+	}
+}
+
+func TestLdapNodeInit(t *testing.T) {
+	config := types.NewConfig()
+	for _, configuration := range []types.Configuration{
+		{"filter": "(uid=a)"},
+		{"server": "ldap://127.0.0.1:389"},
+		{"server": "ldap://127.0.0.1:389", "filter": "(uid=a)", "mode": "xx"},
+		{"server": "ldap://127.0.0.1:389", "filter": "(uid=a)", "scope": "xx"},
+	} {
+		assert.NotNil(t, (&LdapNode{}).New().Init(config, configuration))
+	}
+}
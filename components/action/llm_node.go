@@ -0,0 +1,290 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/json"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//LlmModeChat 调用 /chat/completions 接口
+	LlmModeChat = "chat"
+	//LlmModeCompletion 调用 /completions 接口
+	LlmModeCompletion = "completion"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "llm",
+//	       "name": "工单分类",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "url": "https://api.openai.com/v1",
+//	         "apiKey": "sk-xx",
+//	         "model": "gpt-3.5-turbo",
+//	         "systemPrompt": "你是一个工单分类助手，只输出分类名称",
+//	         "prompt": "设备${deviceId}上报：${data}",
+//	         "maxTokens": 64
+//	       }
+//	     }
+func init() {
+	Registry.Add(&LlmNode{})
+}
+
+// LlmNodeConfiguration 节点配置
+type LlmNodeConfiguration struct {
+	//Url 兼容OpenAI接口的服务地址，默认：https://api.openai.com/v1
+	Url string
+	//ApiKey 接口密钥，使用Bearer认证
+	ApiKey string `sensitive:"true"`
+	//Model 模型名称
+	Model string
+	//Mode 接口类型：chat(对话)或者completion(文本补全)，默认chat
+	Mode string
+	//SystemPrompt chat模式下的系统提示词，可以使用变量
+	SystemPrompt string
+	//Prompt 提示词模板，可以使用 ${metaKeyName} 替换元数据中的变量
+	//${data} 替换成msg.Data，${msgType} 替换成msg.Type
+	Prompt string
+	//MaxTokens 最大生成token数，0表示使用服务默认值
+	MaxTokens int
+	//Temperature 采样温度，为空则使用服务默认值
+	Temperature *float64
+	//JsonResponse 是否把返回内容解析成JSON，解析成功则msg.DataType为JSON
+	JsonResponse bool
+	//Headers 额外的请求头
+	Headers map[string]string
+	//TimeoutMs 请求超时，单位毫秒，默认60000
+	TimeoutMs int
+	//MaxRetries 限流(429)、服务端错误(5xx)或者网络错误时最大重试次数，默认3
+	MaxRetries int
+	//RetryIntervalMs 首次重试间隔，之后每次翻倍，单位毫秒，默认1000
+	//如果响应包含Retry-After头，则使用该值
+	RetryIntervalMs int
+}
+
+// LlmNode 调用兼容OpenAI的completions/chat接口，使用消息渲染提示词，并把返回内容写入msg.Data
+// 元数据记录：finishReason、promptTokens、completionTokens
+// 调用成功发送消息到`Success`链, 否则发到`Failure`链，错误响应记录在元数据errorBody
+type LlmNode struct {
+	config     LlmNodeConfiguration
+	httpClient *http.Client
+}
+
+// Type 组件类型
+func (x *LlmNode) Type() string {
+	return "llm"
+}
+
+// Descriptor 组件描述
+func (x *LlmNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"ai", "llm", "http"},
+		Description: "调用兼容OpenAI的大模型接口",
+	}
+}
+
+func (x *LlmNode) New() types.Node {
+	return &LlmNode{config: LlmNodeConfiguration{
+		Url:             "https://api.openai.com/v1",
+		Mode:            LlmModeChat,
+		Prompt:          "${data}",
+		TimeoutMs:       60000,
+		MaxRetries:      3,
+		RetryIntervalMs: 1000,
+	}}
+}
+
+// Init 初始化
+func (x *LlmNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Model == "" {
+		return errors.New("model can not empty")
+	}
+	if x.config.Mode != LlmModeChat && x.config.Mode != LlmModeCompletion {
+		return errors.New("unsupported mode:" + x.config.Mode)
+	}
+	x.config.Url = strings.TrimSuffix(x.config.Url, "/")
+	x.httpClient = &http.Client{Timeout: time.Duration(x.config.TimeoutMs) * time.Millisecond}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *LlmNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	vars := msg.Metadata.Values()
+	vars["data"] = msg.Data
+	vars["msgType"] = msg.Type
+	body, err := json.Marshal(x.requestBody(vars))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	respBody, err := x.call(body)
+	if err != nil {
+		if respBody != nil {
+			msg.Metadata.PutValue(errorBody, string(respBody))
+		}
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	if err = x.parseResponse(&msg, respBody); err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+// Destroy 销毁
+func (x *LlmNode) Destroy() {
+}
+
+func (x *LlmNode) requestBody(vars map[string]interface{}) map[string]interface{} {
+	body := map[string]interface{}{"model": x.config.Model}
+	prompt := str.SprintfDict(x.config.Prompt, vars)
+	if x.config.Mode == LlmModeChat {
+		var messages []map[string]string
+		if x.config.SystemPrompt != "" {
+			messages = append(messages, map[string]string{"role": "system", "content": str.SprintfDict(x.config.SystemPrompt, vars)})
+		}
+		body["messages"] = append(messages, map[string]string{"role": "user", "content": prompt})
+	} else {
+		body["prompt"] = prompt
+	}
+	if x.config.MaxTokens > 0 {
+		body["max_tokens"] = x.config.MaxTokens
+	}
+	if x.config.Temperature != nil {
+		body["temperature"] = *x.config.Temperature
+	}
+	return body
+}
+
+// call 发送请求，限流、服务端错误和网络错误按照退避策略重试
+func (x *LlmNode) call(body []byte) ([]byte, error) {
+	path := "/chat/completions"
+	if x.config.Mode == LlmModeCompletion {
+		path = "/completions"
+	}
+	interval := time.Duration(x.config.RetryIntervalMs) * time.Millisecond
+	for retry := 0; ; retry++ {
+		respBody, retryAfter, err := x.doRequest(x.config.Url+path, body)
+		if err == nil || retryAfter < 0 || retry >= x.config.MaxRetries {
+			return respBody, err
+		}
+		if retryAfter == 0 {
+			retryAfter = interval
+			interval *= 2
+		}
+		time.Sleep(retryAfter)
+	}
+}
+
+// doRequest 发送一次请求，retryAfter<0表示不能重试，0表示使用默认退避间隔
+func (x *LlmNode) doRequest(url string, body []byte) (respBody []byte, retryAfter time.Duration, err error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if x.config.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+x.config.ApiKey)
+	}
+	for k, v := range x.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := x.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return respBody, 0, nil
+	}
+	err = fmt.Errorf("llm request error:%s", resp.Status)
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return respBody, -1, err
+	}
+	if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	return respBody, retryAfter, err
+}
+
+// parseResponse 提取第一个choice的内容写入msg.Data
+func (x *LlmNode) parseResponse(msg *types.RuleMsg, respBody []byte) error {
+	var resp struct {
+		Choices []struct {
+			Text    string `json:"text"`
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return err
+	}
+	if len(resp.Choices) == 0 {
+		return errors.New("llm response has no choices")
+	}
+	choice := resp.Choices[0]
+	content := choice.Message.Content
+	if x.config.Mode == LlmModeCompletion {
+		content = choice.Text
+	}
+	content = strings.TrimSpace(content)
+	msg.Metadata.PutValue("finishReason", choice.FinishReason)
+	msg.Metadata.PutValue("promptTokens", strconv.Itoa(resp.Usage.PromptTokens))
+	msg.Metadata.PutValue("completionTokens", strconv.Itoa(resp.Usage.CompletionTokens))
+	msg.DataType = types.TEXT
+	if x.config.JsonResponse {
+		//兼容模型使用markdown代码块包裹JSON
+		content = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(content, "```json"), "```"), "```"))
+		var v interface{}
+		if err := json.Unmarshal([]byte(content), &v); err != nil {
+			return fmt.Errorf("llm response is not json:%w", err)
+		}
+		msg.DataType = types.JSON
+	}
+	msg.Data = content
+	return nil
+}
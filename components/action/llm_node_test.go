@@ -0,0 +1,136 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLlmNodeChat(t *testing.T) {
+	var requestCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//第一次请求返回限流错误
+		if atomic.AddInt32(&requestCount, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer sk-aa", r.Header.Get("Authorization"))
+		var body struct {
+			Model     string              `json:"model"`
+			MaxTokens int                 `json:"max_tokens"`
+			Messages  []map[string]string `json:"messages"`
+		}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "gpt-test", body.Model)
+		assert.Equal(t, 16, body.MaxTokens)
+		assert.Equal(t, 2, len(body.Messages))
+		assert.Equal(t, "system", body.Messages[0]["role"])
+		assert.Equal(t, `设备aa上报：{"temperature":41}`, body.Messages[1]["content"])
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"` + "```json\\n{\\\"level\\\":\\\"high\\\"}\\n```" + `"},"finish_reason":"stop"}],"usage":{"prompt_tokens":20,"completion_tokens":5}}`))
+	}))
+	defer server.Close()
+
+	config := types.NewConfig()
+	node := (&LlmNode{}).New()
+	err := node.Init(config, types.Configuration{
+		"url":             server.URL + "/v1/",
+		"apiKey":          "sk-aa",
+		"model":           "gpt-test",
+		"systemPrompt":    "只输出JSON",
+		"prompt":          "设备${deviceId}上报：${data}",
+		"maxTokens":       16,
+		"jsonResponse":    true,
+		"retryIntervalMs": 10,
+	})
+	assert.Nil(t, err)
+
+	var relation string
+	var result types.RuleMsg
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		result = msg
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	err = node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metaData, `{"temperature":41}`))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requestCount))
+	assert.Equal(t, `{"level":"high"}`, result.Data)
+	assert.Equal(t, types.JSON, result.DataType)
+	assert.Equal(t, "stop", result.Metadata.GetValue("finishReason"))
+	assert.Equal(t, "20", result.Metadata.GetValue("promptTokens"))
+	assert.False(t, result.Metadata.Has("data"))
+}
+
+func TestLlmNodeCompletion(t *testing.T) {
+	var requestCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requestCount, 1)
+		var body map[string]interface{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["prompt"] == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"bad request"}}`))
+			return
+		}
+		assert.Equal(t, "/completions", r.URL.Path)
+		assert.Equal(t, 0.0, body["temperature"])
+		_, _ = w.Write([]byte(`{"choices":[{"text":" positive\n","finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	config := types.NewConfig()
+	node := (&LlmNode{}).New()
+	err := node.Init(config, types.Configuration{
+		"url":         server.URL,
+		"model":       "text-test",
+		"mode":        LlmModeCompletion,
+		"prompt":      "${data}",
+		"temperature": 0,
+	})
+	assert.Nil(t, err)
+	var relation string
+	var result types.RuleMsg
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		result = msg
+	})
+	err = node.OnMsg(ctx, ctx.NewMsg("TEXT", types.NewMetadata(), "good"))
+	assert.Nil(t, err)
+	assert.Equal(t, "positive", result.Data)
+	assert.Equal(t, types.TEXT, result.DataType)
+
+	//客户端错误不重试
+	atomic.StoreInt32(&requestCount, 0)
+	err = node.OnMsg(ctx, ctx.NewMsg("TEXT", types.NewMetadata(), "bad"))
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relation)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requestCount))
+	assert.Equal(t, `{"error":{"message":"bad request"}}`, result.Metadata.GetValue(errorBody))
+
+	assert.NotNil(t, (&LlmNode{}).New().Init(config, types.Configuration{}))
+	assert.NotNil(t, (&LlmNode{}).New().Init(config, types.Configuration{"model": "a", "mode": "xx"}))
+}
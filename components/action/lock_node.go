@@ -0,0 +1,152 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/lock"
+	"github.com/2018yuli/rulego/utils/lock/redis"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"sync"
+	"time"
+)
+
+// LockBusy 锁已经被其他消息持有的关系类型
+const LockBusy = "Busy"
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "lock",
+//	       "name": "设备操作互斥",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "key": "device_${deviceId}",
+//	         "ttlMs": 30000,
+//	         "server": "127.0.0.1:6379"
+//	       }
+//	     }
+func init() {
+	Registry.Add(&LockNode{})
+}
+
+// LockNodeConfiguration 节点配置
+type LockNodeConfiguration struct {
+	//Key 锁名称，可以使用 ${metaKeyName} 替换元数据中的变量
+	Key string
+	//TtlMs 锁的最长持有时间，单位毫秒，超时自动释放，默认30000
+	TtlMs int64
+	//Server Redis地址，例如：127.0.0.1:6379，为空则使用进程内的本地锁
+	Server string
+	//Password Redis密码
+	Password string `sensitive:"true"`
+	//Db Redis数据库
+	Db int
+	//KeyPrefix Redis key前缀，默认：rulego:lock:
+	KeyPrefix string
+}
+
+// LockNode 获取命名锁后再执行下游分支，下游分支处理结束后释放锁，用于串行化对共享资源的操作
+// 支持进程内的本地锁和基于Redis的分布式锁
+// 如果获取锁成功，发送消息到`Success`链，锁被其他消息持有则发到`Busy`链，获取锁错误发到`Failure`链
+// 如果下游分支有多个结束点，第一个结束点处理完成后释放锁；下游分支没有结束(例如消息被丢弃)，则超过ttlMs后自动释放
+type LockNode struct {
+	config LockNodeConfiguration
+	locker lock.Locker
+	ttl    time.Duration
+	logger types.Logger
+}
+
+// Type 组件类型
+func (x *LockNode) Type() string {
+	return "lock"
+}
+
+// Descriptor 组件描述
+func (x *LockNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"lock", "mutex", "redis", "concurrency"},
+		Description: "获取命名锁后执行下游分支，结束后释放锁",
+	}
+}
+
+func (x *LockNode) New() types.Node {
+	return &LockNode{config: LockNodeConfiguration{
+		TtlMs:     30000,
+		KeyPrefix: "rulego:lock:",
+	}}
+}
+
+// Init 初始化
+func (x *LockNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Key == "" {
+		return errors.New("key can not empty")
+	}
+	if x.config.TtlMs <= 0 {
+		x.config.TtlMs = 30000
+	}
+	x.ttl = time.Duration(x.config.TtlMs) * time.Millisecond
+	x.logger = ruleConfig.Logger
+	if x.config.Server == "" {
+		x.locker = lock.Local
+		return nil
+	}
+	x.locker, err = redis.New(x.config.Server, x.config.Password, x.config.Db, x.config.KeyPrefix)
+	return err
+}
+
+// OnMsg 处理消息
+func (x *LockNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	name := str.SprintfDict(x.config.Key, msg.Metadata.Values())
+	token, ok, err := x.locker.TryLock(name, x.ttl)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	if !ok {
+		ctx.TellNext(msg, LockBusy)
+		return nil
+	}
+	var once sync.Once
+	onEnd := ctx.GetEndFunc()
+	ctx.SetEndFunc(func(msg types.RuleMsg, err error) {
+		once.Do(func() {
+			if err := x.locker.Unlock(name, token); err != nil && x.logger != nil {
+				x.logger.Printf("unlock %s error:%v", name, err)
+			}
+		})
+		if onEnd != nil {
+			onEnd(msg, err)
+		}
+	})
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+// Destroy 销毁
+func (x *LockNode) Destroy() {
+	if locker, ok := x.locker.(*redis.Locker); ok {
+		_ = locker.Close()
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+)
+
+func TestLockNodeOnMsg(t *testing.T) {
+	node := (&LockNode{}).New()
+	config := types.NewConfig()
+	err := node.Init(config, types.Configuration{"key": "lockTest_${deviceId}"})
+	assert.Nil(t, err)
+	defer node.Destroy()
+
+	var relations []string
+	newCtx := func() types.RuleContext {
+		return test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+			relations = append(relations, relationType)
+		})
+	}
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	var ended int
+	ctx1 := newCtx().SetEndFunc(func(msg types.RuleMsg, err error) {
+		ended++
+	})
+	assert.Nil(t, node.OnMsg(ctx1, ctx1.NewMsg("TEST", metaData, "")))
+	ctx2 := newCtx()
+	assert.Nil(t, node.OnMsg(ctx2, ctx2.NewMsg("TEST", metaData, "")))
+	//不同的锁名称
+	metaData2 := types.NewMetadata()
+	metaData2.PutValue("deviceId", "bb")
+	assert.Nil(t, node.OnMsg(ctx2, ctx2.NewMsg("TEST", metaData2, "")))
+	assert.Equal(t, []string{types.Success, LockBusy, types.Success}, relations)
+
+	//下游分支结束后释放锁
+	ctx1.GetEndFunc()(types.RuleMsg{}, nil)
+	ctx1.GetEndFunc()(types.RuleMsg{}, nil)
+	assert.Equal(t, 2, ended)
+	ctx3 := newCtx()
+	assert.Nil(t, node.OnMsg(ctx3, ctx3.NewMsg("TEST", metaData, "")))
+	assert.Equal(t, types.Success, relations[3])
+}
+
+func TestLockNodeInitError(t *testing.T) {
+	node := (&LockNode{}).New()
+	err := node.Init(types.NewConfig(), types.Configuration{})
+	assert.NotNil(t, err)
+}
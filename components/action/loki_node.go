@@ -0,0 +1,200 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+// {
+//        "id": "s3",
+//        "type": "loki",
+//        "name": "推送日志到Loki",
+//        "debugMode": false,
+//        "configuration": {
+//          "server": "http://127.0.0.1:3100/loki/api/v1/push",
+//          "labels": {"job":"rulego","deviceId":"${deviceId}"},
+//          "metadataLabels": "productType,tenantId"
+//        }
+//      }
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/json"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	Registry.Add(&LokiNode{})
+}
+
+// LokiNodeConfiguration 节点配置
+type LokiNodeConfiguration struct {
+	//Server Loki push接口地址，例如：http://127.0.0.1:3100/loki/api/v1/push
+	Server string
+	//Labels 日志流标签，value可以使用 ${metaKeyName} 替换元数据中的变量
+	Labels map[string]string
+	//MetadataLabels 直接作为标签的元数据key，多个与逗号隔开
+	MetadataLabels string
+	//TenantId 多租户ID，设置到X-Scope-OrgID请求头，可以使用 ${metaKeyName} 替换元数据中的变量
+	TenantId string
+	//Username basic认证用户名
+	Username string
+	//Password basic认证密码
+	Password string `sensitive:"true"`
+	//Headers 请求头,可以使用 ${metaKeyName} 替换元数据中的变量
+	Headers map[string]string
+	//ReadTimeoutMs 超时，单位毫秒
+	ReadTimeoutMs int
+}
+
+// LokiNode 把消息作为日志条目推送到Grafana Loki
+// 日志内容为msg.Data，时间戳为msg.Ts，标签通过Labels和MetadataLabels配置从元数据中获取
+// 如果推送成功，发送消息到`Success`链, 否则发到`Failure`链，
+// metaData.status记录响应错误码和metaData.errorBody记录错误信息。
+type LokiNode struct {
+	//节点配置
+	config LokiNodeConfiguration
+	//metadataLabels 作为标签的元数据key列表
+	metadataLabels []string
+	//httpClient http客户端
+	httpClient *http.Client
+}
+
+// lokiPushRequest Loki push接口请求体
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+// lokiStream Loki日志流
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Type 组件类型
+func (x *LokiNode) Type() string {
+	return "loki"
+}
+
+// Descriptor 组件描述
+func (x *LokiNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"log", "grafana", "http"},
+		Description: "把消息推送到Grafana Loki",
+	}
+}
+
+func (x *LokiNode) New() types.Node {
+	return &LokiNode{config: LokiNodeConfiguration{
+		ReadTimeoutMs: 10000,
+	}}
+}
+
+// Init 初始化
+func (x *LokiNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err == nil {
+		if x.config.Server == "" {
+			return errors.New("server can not empty")
+		}
+		for _, item := range strings.Split(x.config.MetadataLabels, ",") {
+			if key := strings.TrimSpace(item); key != "" {
+				x.metadataLabels = append(x.metadataLabels, key)
+			}
+		}
+		x.httpClient = &http.Client{Timeout: time.Duration(x.config.ReadTimeoutMs) * time.Millisecond}
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *LokiNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	metaData := msg.Metadata.Values()
+	body, err := json.Marshal(lokiPushRequest{
+		Streams: []lokiStream{{
+			Stream: x.labels(msg.Metadata, metaData),
+			Values: [][2]string{{strconv.FormatInt(msg.Ts*int64(time.Millisecond), 10), msg.Data}},
+		}},
+	})
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, x.config.Server, bytes.NewReader(body))
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range x.config.Headers {
+		req.Header.Set(str.SprintfDict(key, metaData), str.SprintfDict(value, metaData))
+	}
+	if x.config.TenantId != "" {
+		req.Header.Set("X-Scope-OrgID", str.SprintfDict(x.config.TenantId, metaData))
+	}
+	if x.config.Username != "" {
+		req.SetBasicAuth(x.config.Username, x.config.Password)
+	}
+
+	response, err := x.httpClient.Do(req)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return nil
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	b, _ := ioutil.ReadAll(response.Body)
+	msg.Metadata.PutValue(status, response.Status)
+	msg.Metadata.PutValue(statusCode, strconv.Itoa(response.StatusCode))
+	//Loki push接口成功返回204
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		ctx.TellSuccess(msg)
+	} else {
+		msg.Metadata.PutValue(errorBody, string(b))
+		ctx.TellFailure(msg, fmt.Errorf("loki push error,status=%s", response.Status))
+	}
+	return nil
+}
+
+// Destroy 销毁
+func (x *LokiNode) Destroy() {
+}
+
+// labels 生成日志流标签
+func (x *LokiNode) labels(metadata types.Metadata, metaData map[string]interface{}) map[string]string {
+	labels := make(map[string]string)
+	for _, key := range x.metadataLabels {
+		if metadata.Has(key) {
+			labels[key] = str.ToString(metadata.GetValue(key))
+		}
+	}
+	for key, value := range x.config.Labels {
+		labels[key] = str.SprintfDict(value, metaData)
+	}
+	//Loki要求至少有一个标签
+	if len(labels) == 0 {
+		labels["source"] = "rulego"
+	}
+	return labels
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLokiNodeOnMsg(t *testing.T) {
+	var received lokiPushRequest
+	var tenantId string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(b, &received)
+		tenantId = r.Header.Get("X-Scope-OrgID")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	node := (&LokiNode{}).New()
+	var configuration = make(types.Configuration)
+	configuration["server"] = server.URL
+	configuration["labels"] = map[string]string{"job": "rulego", "deviceId": "${deviceId}"}
+	configuration["metadataLabels"] = "productType"
+	configuration["tenantId"] = "${tenant}"
+	config := types.NewConfig()
+	err := node.Init(config, configuration)
+	if err != nil {
+		t.Errorf("err=%s", err)
+	}
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "204", msg.Metadata.GetValue(statusCode))
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	metaData.PutValue("productType", "test")
+	metaData.PutValue("tenant", "t01")
+	msg := ctx.NewMsg("TEST_MSG_TYPE_AA", metaData, "{\"temperature\":41}")
+	err = node.OnMsg(ctx, msg)
+	if err != nil {
+		t.Errorf("err=%s", err)
+	}
+	assert.Equal(t, "t01", tenantId)
+	assert.Equal(t, 1, len(received.Streams))
+	assert.Equal(t, "rulego", received.Streams[0].Stream["job"])
+	assert.Equal(t, "aa", received.Streams[0].Stream["deviceId"])
+	assert.Equal(t, "test", received.Streams[0].Stream["productType"])
+	assert.Equal(t, "{\"temperature\":41}", received.Streams[0].Values[0][1])
+
+	//服务端错误
+	failServer := httptest.NewServer(http.NotFoundHandler())
+	defer failServer.Close()
+	configuration["server"] = failServer.URL
+	node = (&LokiNode{}).New()
+	_ = node.Init(config, configuration)
+	ctx = test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Failure, relationType)
+		assert.Equal(t, "404", msg.Metadata.GetValue(statusCode))
+	})
+	_ = node.OnMsg(ctx, msg)
+}
@@ -17,6 +17,7 @@
 package action
 
 import (
+	"errors"
 	"github.com/2018yuli/rulego/api/types"
 	"github.com/2018yuli/rulego/components/mqtt"
 	"github.com/2018yuli/rulego/utils/maps"
@@ -45,7 +46,7 @@ type MqttClientNodeConfiguration struct {
 	Topic                string
 	Server               string
 	Username             string
-	Password             string
+	Password             string `sensitive:"true"`
 	MaxReconnectInterval time.Duration
 	QOS                  uint8
 	CleanSession         bool
@@ -80,6 +81,14 @@ func (x *MqttClientNode) Type() string {
 	return "mqttClient"
 }
 
+// Descriptor 组件描述
+func (x *MqttClientNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"mqtt", "iot", "messaging"},
+		Description: "把消息发布到MQTT Broker",
+	}
+}
+
 func (x *MqttClientNode) New() types.Node {
 	return &MqttClientNode{}
 }
@@ -105,6 +114,27 @@ func (x *MqttClientNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
 	return err
 }
 
+// ErrMqttNotConnected mqtt客户端没有初始化
+var ErrMqttNotConnected = errors.New("mqtt client not connected")
+
+// SideEffect 实现types.SideEffectNode，发布消息
+func (x *MqttClientNode) SideEffect(msg types.RuleMsg) (types.DryRunAction, bool) {
+	return types.DryRunAction{
+		Operation: "publish",
+		Target:    str.SprintfDict(x.config.Topic, msg.Metadata.Values()),
+		Params:    map[string]interface{}{"qos": x.config.QOS},
+		Data:      msg.Data,
+	}, true
+}
+
+// HealthCheck 检查是否已经连接到mqtt broker，没有初始化或者连接失败返回ErrMqttNotConnected
+func (x *MqttClientNode) HealthCheck() error {
+	if x.mqttClient == nil {
+		return ErrMqttNotConnected
+	}
+	return x.mqttClient.HealthCheck()
+}
+
 // Destroy 销毁
 func (x *MqttClientNode) Destroy() {
 	if x.mqttClient != nil {
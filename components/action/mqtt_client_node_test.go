@@ -44,3 +44,9 @@ func TestMqttClientNodeOnMsg(t *testing.T) {
 	}
 
 }
+
+func TestMqttClientNodeHealthCheck(t *testing.T) {
+	//没有初始化
+	var node MqttClientNode
+	assert.Equal(t, ErrMqttNotConnected, node.HealthCheck())
+}
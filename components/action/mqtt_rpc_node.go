@@ -0,0 +1,292 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/mqtt"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gofrs/uuid/v5"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//MqttRpcDelivered 收到设备响应的关系类型
+	MqttRpcDelivered = "Delivered"
+	//MqttRpcTimeout 重试后仍然没有收到设备响应的关系类型
+	MqttRpcTimeout = "Timeout"
+	//mqttRpcRequestIdVar 请求ID变量
+	mqttRpcRequestIdVar = "${requestId}"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "mqttRpc",
+//	       "name": "下发设备命令",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "server": "127.0.0.1:1883",
+//	         "requestTopic": "devices/${deviceId}/rpc/request/${requestId}",
+//	         "responseTopic": "devices/+/rpc/response/${requestId}",
+//	         "timeoutMs": 10000,
+//	         "retries": 2
+//	       }
+//	     }
+func init() {
+	Registry.Add(&MqttRpcNode{})
+}
+
+// MqttRpcNodeConfiguration 节点配置
+type MqttRpcNodeConfiguration struct {
+	Server               string
+	Username             string
+	Password             string `sensitive:"true"`
+	MaxReconnectInterval time.Duration
+	QOS                  uint8
+	CleanSession         bool
+	ClientID             string
+	CAFile               string
+	CertFile             string
+	CertKeyFile          string
+	//RequestTopic 命令发布主题，可以使用 ${metaKeyName} 替换元数据中的变量，${requestId} 替换为请求ID
+	RequestTopic string
+	//ResponseTopic 设备响应订阅主题，必须包含一个 ${requestId} 层级，用于关联请求，例如：devices/+/rpc/response/${requestId}
+	ResponseTopic string
+	//TimeoutMs 每次发送等待响应的超时时间，单位毫秒，默认10000
+	TimeoutMs int64
+	//Retries 超时后重新发送的次数，默认0
+	Retries int
+}
+
+func (x *MqttRpcNodeConfiguration) ToMqttConfig() mqtt.Config {
+	return mqtt.Config{
+		Server:               x.Server,
+		Username:             x.Username,
+		Password:             x.Password,
+		QOS:                  x.QOS,
+		MaxReconnectInterval: x.MaxReconnectInterval,
+		CleanSession:         x.CleanSession,
+		ClientID:             x.ClientID,
+		CAFile:               x.CAFile,
+		CertFile:             x.CertFile,
+		CertKeyFile:          x.CertKeyFile,
+	}
+}
+
+// rpcTransport 发布命令和订阅响应的传输层
+type rpcTransport interface {
+	Publish(topic string, qos byte, data []byte) error
+	Subscribe(topic string, qos byte, handler func(topic string, payload []byte))
+	Close() error
+}
+
+// mqttRpcTransport mqtt客户端传输层
+type mqttRpcTransport struct {
+	client *mqtt.Client
+}
+
+func (t *mqttRpcTransport) Publish(topic string, qos byte, data []byte) error {
+	return t.client.Publish(topic, qos, data)
+}
+
+func (t *mqttRpcTransport) Subscribe(topic string, qos byte, handler func(topic string, payload []byte)) {
+	t.client.RegisterHandler(mqtt.Handler{Topic: topic, Qos: qos, Handle: func(c paho.Client, data paho.Message) {
+		handler(data.Topic(), data.Payload())
+	}})
+}
+
+func (t *mqttRpcTransport) Close() error {
+	return t.client.Close()
+}
+
+// mqttRpcRequest 等待响应的请求
+type mqttRpcRequest struct {
+	ctx      types.RuleContext
+	msg      types.RuleMsg
+	topic    string
+	attempts int
+	timer    clock.Timer
+}
+
+// MqttRpcNode 通过MQTT向设备下发命令(msg.Data)，并通过响应主题跟踪设备是否收到命令
+// 请求ID放到元数据requestId，设备在响应主题的${requestId}层级返回请求ID
+// 收到响应后，msg.Data替换为响应内容，元数据增加responseTopic，发送消息到`Delivered`链
+// 超时后按照Retries重新发送，仍然没有响应发送消息到`Timeout`链，发布错误发送到`Failure`链
+type MqttRpcNode struct {
+	config    MqttRpcNodeConfiguration
+	transport rpcTransport
+	clock     clock.Clock
+	timeout   time.Duration
+	//responseIdIndex 响应主题中请求ID所在层级
+	responseIdIndex int
+	pending         map[string]*mqttRpcRequest
+	lock            sync.Mutex
+}
+
+// Type 组件类型
+func (x *MqttRpcNode) Type() string {
+	return "mqttRpc"
+}
+
+// Descriptor 组件描述
+func (x *MqttRpcNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"mqtt", "iot", "rpc", "command"},
+		Description: "通过MQTT向设备下发命令并跟踪设备响应",
+	}
+}
+
+func (x *MqttRpcNode) New() types.Node {
+	return &MqttRpcNode{config: MqttRpcNodeConfiguration{TimeoutMs: 10000}}
+}
+
+// Init 初始化
+func (x *MqttRpcNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.RequestTopic == "" {
+		return errors.New("requestTopic can not empty")
+	}
+	x.responseIdIndex = -1
+	levels := strings.Split(x.config.ResponseTopic, "/")
+	for i, level := range levels {
+		if level == mqttRpcRequestIdVar {
+			x.responseIdIndex = i
+			levels[i] = "+"
+		}
+	}
+	if x.responseIdIndex < 0 {
+		return errors.New("responseTopic must contain " + mqttRpcRequestIdVar + " level")
+	}
+	if x.config.TimeoutMs <= 0 {
+		x.config.TimeoutMs = 10000
+	}
+	x.timeout = time.Duration(x.config.TimeoutMs) * time.Millisecond
+	x.clock = ruleConfig.GetClock()
+	x.pending = make(map[string]*mqttRpcRequest)
+	if x.transport == nil {
+		var client *mqtt.Client
+		if client, err = mqtt.NewClient(x.config.ToMqttConfig()); err != nil {
+			return err
+		}
+		x.transport = &mqttRpcTransport{client: client}
+	}
+	x.transport.Subscribe(strings.Join(levels, "/"), x.config.QOS, x.onResponse)
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *MqttRpcNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	id, _ := uuid.NewV4()
+	requestId := id.String()
+	msg.Metadata.PutValue("requestId", requestId)
+	topic := strings.ReplaceAll(x.config.RequestTopic, mqttRpcRequestIdVar, requestId)
+	topic = str.SprintfDict(topic, msg.Metadata.Values())
+	request := &mqttRpcRequest{ctx: ctx, msg: msg, topic: topic}
+	x.lock.Lock()
+	x.pending[requestId] = request
+	err := x.send(requestId, request)
+	if err != nil {
+		delete(x.pending, requestId)
+	}
+	x.lock.Unlock()
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (x *MqttRpcNode) Destroy() {
+	x.lock.Lock()
+	for requestId, request := range x.pending {
+		request.timer.Stop()
+		delete(x.pending, requestId)
+	}
+	x.lock.Unlock()
+	if x.transport != nil {
+		_ = x.transport.Close()
+	}
+}
+
+// send 发布命令并启动超时计时器，调用方需要持有锁
+func (x *MqttRpcNode) send(requestId string, request *mqttRpcRequest) error {
+	if err := x.transport.Publish(request.topic, x.config.QOS, []byte(request.msg.Data)); err != nil {
+		return err
+	}
+	request.attempts++
+	var timer clock.Timer
+	timer = x.clock.AfterFunc(x.timeout, func() {
+		x.lock.Lock()
+		if current, ok := x.pending[requestId]; !ok || current != request || request.timer != timer {
+			x.lock.Unlock()
+			return
+		}
+		if request.attempts <= x.config.Retries {
+			if err := x.send(requestId, request); err == nil {
+				x.lock.Unlock()
+				return
+			}
+		}
+		delete(x.pending, requestId)
+		x.lock.Unlock()
+		request.msg.Metadata.PutValue("attempts", strconv.Itoa(request.attempts))
+		request.ctx.TellNext(request.msg, MqttRpcTimeout)
+	})
+	request.timer = timer
+	return nil
+}
+
+// onResponse 处理设备响应
+func (x *MqttRpcNode) onResponse(topic string, payload []byte) {
+	levels := strings.Split(topic, "/")
+	if x.responseIdIndex >= len(levels) {
+		return
+	}
+	requestId := levels[x.responseIdIndex]
+	x.lock.Lock()
+	request, ok := x.pending[requestId]
+	if ok {
+		request.timer.Stop()
+		delete(x.pending, requestId)
+	}
+	x.lock.Unlock()
+	if !ok {
+		return
+	}
+	msg := request.msg
+	msg.DataType = types.DetectDataType(payload)
+	if msg.DataType == types.BINARY {
+		msg.Data = base64.StdEncoding.EncodeToString(payload)
+	} else {
+		msg.Data = string(payload)
+	}
+	msg.Metadata.PutValue("responseTopic", topic)
+	request.ctx.TellNext(msg, MqttRpcDelivered)
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRpcTransport 记录发布的主题，模拟设备响应
+type fakeRpcTransport struct {
+	published []string
+	subscribe string
+	handler   func(topic string, payload []byte)
+	err       error
+	lock      sync.Mutex
+}
+
+func (t *fakeRpcTransport) Publish(topic string, qos byte, data []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	t.published = append(t.published, topic)
+	return nil
+}
+
+func (t *fakeRpcTransport) Subscribe(topic string, qos byte, handler func(topic string, payload []byte)) {
+	t.subscribe = topic
+	t.handler = handler
+}
+
+func (t *fakeRpcTransport) Close() error {
+	return nil
+}
+
+func newTestMqttRpcNode(t *testing.T, vc *clock.Virtual, transport *fakeRpcTransport) *MqttRpcNode {
+	node := (&MqttRpcNode{}).New().(*MqttRpcNode)
+	node.transport = transport
+	err := node.Init(types.NewConfig(types.WithClock(vc)), types.Configuration{
+		"requestTopic":  "devices/${deviceId}/rpc/request/${requestId}",
+		"responseTopic": "devices/+/rpc/response/${requestId}",
+		"timeoutMs":     1000,
+		"retries":       1,
+	})
+	assert.Nil(t, err)
+	return node
+}
+
+func TestMqttRpcNodeDelivered(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	transport := &fakeRpcTransport{}
+	node := newTestMqttRpcNode(t, vc, transport)
+	defer node.Destroy()
+	assert.Equal(t, "devices/+/rpc/response/+", transport.subscribe)
+
+	var relation string
+	var result types.RuleMsg
+	ctx := test.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		result = msg
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("RPC", metaData, "{\"method\":\"reboot\"}")))
+	assert.Equal(t, 1, len(transport.published))
+	requestId := transport.published[0][len("devices/aa/rpc/request/"):]
+
+	//超时重发
+	vc.Advance(time.Second)
+	assert.Equal(t, 2, len(transport.published))
+	assert.Equal(t, transport.published[0], transport.published[1])
+	assert.Equal(t, "", relation)
+
+	//未知请求ID忽略
+	transport.handler("devices/aa/rpc/response/other", []byte("{}"))
+	transport.handler("devices/aa/rpc/response/"+requestId, []byte("{\"result\":\"ok\"}"))
+	assert.Equal(t, MqttRpcDelivered, relation)
+	assert.Equal(t, "{\"result\":\"ok\"}", result.Data)
+	assert.Equal(t, requestId, result.Metadata.GetValue("requestId"))
+	//响应后停止计时
+	vc.Advance(time.Second * 5)
+	assert.Equal(t, MqttRpcDelivered, relation)
+	assert.Equal(t, 0, vc.Pending())
+}
+
+func TestMqttRpcNodeTimeout(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	transport := &fakeRpcTransport{}
+	node := newTestMqttRpcNode(t, vc, transport)
+	defer node.Destroy()
+
+	var relations []string
+	var result types.RuleMsg
+	ctx := test.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string) {
+		relations = append(relations, relationType)
+		result = msg
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("RPC", metaData, "{}")))
+	vc.Advance(time.Second)
+	vc.Advance(time.Second)
+	assert.Equal(t, []string{MqttRpcTimeout}, relations)
+	assert.Equal(t, "2", result.Metadata.GetValue("attempts"))
+	assert.Equal(t, 2, len(transport.published))
+
+	transport.err = errors.New("not connected")
+	assert.NotNil(t, node.OnMsg(ctx, ctx.NewMsg("RPC", metaData, "{}")))
+	assert.Equal(t, types.Failure, relations[1])
+}
+
+func TestMqttRpcNodeInitError(t *testing.T) {
+	node := (&MqttRpcNode{}).New().(*MqttRpcNode)
+	node.transport = &fakeRpcTransport{}
+	assert.NotNil(t, node.Init(types.NewConfig(), types.Configuration{}))
+	err := node.Init(types.NewConfig(), types.Configuration{
+		"requestTopic":  "devices/aa/rpc",
+		"responseTopic": "devices/aa/rpc/response",
+	})
+	assert.NotNil(t, err)
+}
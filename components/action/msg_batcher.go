@@ -0,0 +1,107 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"time"
+)
+
+// batchItem 缓存的待发送消息，err为发送结果
+type batchItem struct {
+	ctx types.RuleContext
+	msg types.RuleMsg
+	err error
+}
+
+// msgBatcher 消息批量发送缓冲器
+// 缓存数量达到batchSize或者距离第一条消息超过interval则调用send批量发送
+// send 设置每条消息的发送结果，发送成功的消息发送到`Success`链, 否则发到`Failure`链
+type msgBatcher struct {
+	batchSize int
+	interval  time.Duration
+	send      func(items []*batchItem)
+	items     []*batchItem
+	clock     clock.Clock
+	timer     clock.Timer
+	sync.Mutex
+}
+
+func newMsgBatcher(clock clock.Clock, batchSize int, interval time.Duration, send func(items []*batchItem)) *msgBatcher {
+	return &msgBatcher{clock: clock, batchSize: batchSize, interval: interval, send: send}
+}
+
+// add 添加消息到缓冲区
+func (b *msgBatcher) add(ctx types.RuleContext, msg types.RuleMsg) {
+	b.Lock()
+	b.items = append(b.items, &batchItem{ctx: ctx, msg: msg})
+	if len(b.items) < b.batchSize {
+		if b.timer == nil {
+			b.timer = b.clock.AfterFunc(b.interval, b.flush)
+		}
+		b.Unlock()
+		return
+	}
+	items := b.take()
+	b.Unlock()
+	b.doSend(items)
+}
+
+// flush 发送缓冲区所有消息
+func (b *msgBatcher) flush() {
+	b.Lock()
+	items := b.take()
+	b.Unlock()
+	b.doSend(items)
+}
+
+// take 取出缓冲区消息，调用方需要持有锁
+func (b *msgBatcher) take() []*batchItem {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	items := b.items
+	b.items = nil
+	return items
+}
+
+func (b *msgBatcher) doSend(items []*batchItem) {
+	if len(items) == 0 {
+		return
+	}
+	b.send(items)
+	for _, item := range items {
+		if item.err != nil {
+			item.ctx.TellFailure(item.msg, item.err)
+		} else {
+			item.ctx.TellSuccess(item.msg)
+		}
+	}
+}
+
+// chunkBatchItems 按照size分组
+func chunkBatchItems(items []*batchItem, size int) [][]*batchItem {
+	var chunks [][]*batchItem
+	for size > 0 && len(items) > size {
+		chunks = append(chunks, items[:size])
+		items = items[size:]
+	}
+	return append(chunks, items)
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"testing"
+	"time"
+)
+
+func TestMsgBatcherInterval(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	config := types.NewConfig(types.WithClock(vc))
+	var sent []int
+	var relations []string
+	batcher := newMsgBatcher(config.GetClock(), 10, time.Second, func(items []*batchItem) {
+		sent = append(sent, len(items))
+	})
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relations = append(relations, relationType)
+	})
+	batcher.add(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "a"))
+	batcher.add(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "b"))
+
+	vc.Advance(time.Millisecond * 999)
+	assert.Equal(t, 0, len(sent))
+
+	vc.Advance(time.Millisecond)
+	assert.Equal(t, []int{2}, sent)
+	assert.Equal(t, []string{types.Success, types.Success}, relations)
+
+	//缓冲区为空，不再触发发送
+	vc.Advance(time.Second * 5)
+	assert.Equal(t, 1, len(sent))
+}
+
+func TestMsgBatcherSize(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	config := types.NewConfig(types.WithClock(vc))
+	var sent []int
+	batcher := newMsgBatcher(config.GetClock(), 2, time.Second, func(items []*batchItem) {
+		sent = append(sent, len(items))
+	})
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {})
+	msg := ctx.NewMsg("TEST", types.NewMetadata(), "a")
+	assert.Equal(t, int64(1700000000000), msg.Ts)
+	batcher.add(ctx, msg)
+	batcher.add(ctx, msg)
+	batcher.add(ctx, msg)
+	assert.Equal(t, []int{2}, sent)
+	assert.Equal(t, 1, vc.Pending())
+
+	vc.Advance(time.Second)
+	assert.Equal(t, []int{2, 1}, sent)
+	assert.Equal(t, 0, vc.Pending())
+}
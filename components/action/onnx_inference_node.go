@@ -0,0 +1,223 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/onnx"
+	"github.com/2018yuli/rulego/utils/json"
+	"github.com/2018yuli/rulego/utils/maps"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "onnxInference",
+//	       "name": "设备故障预测",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "modelFile": "./models/fault.onnx",
+//	         "features": ["$.temperature", "$.humidity", "$.vibration.rms"],
+//	         "outputs": {"label": "faultClass", "probabilities": "faultProb"}
+//	       }
+//	     }
+func init() {
+	Registry.Add(&OnnxInferenceNode{})
+}
+
+// OnnxInferenceNodeConfiguration 节点配置
+type OnnxInferenceNodeConfiguration struct {
+	//ModelFile 模型文件路径或者http(s) URL
+	ModelFile string
+	//Features 特征值在msg.Data中的路径，例如：$.temperature、$.values[0]
+	//按顺序组成形状为[1, len(features)]的输入张量
+	Features []string
+	//InputName 模型输入名称，默认使用模型第一个输入
+	InputName string
+	//Outputs 模型输出名称->写入msg.Data的字段名
+	//默认把模型第一个输出写入prediction字段
+	Outputs map[string]string
+	//TimeoutMs 从URL下载模型的超时，单位毫秒，默认30000
+	TimeoutMs int
+}
+
+// OnnxInferenceNode 使用ONNX模型对消息进行推理，把预测结果写回msg.Data
+// 从msg.Data(JSON)中按Features路径提取数值特征，只有一个元素的输出写入数值，否则写入数组
+// 模型使用纯Go推理引擎执行，支持的算子见 onnx.SupportedOps
+// 推理成功发送消息到`Success`链, 否则发到`Failure`链
+type OnnxInferenceNode struct {
+	config OnnxInferenceNodeConfiguration
+	model  *onnx.Model
+}
+
+// Type 组件类型
+func (x *OnnxInferenceNode) Type() string {
+	return "onnxInference"
+}
+
+// Descriptor 组件描述
+func (x *OnnxInferenceNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"ai", "onnx", "inference"},
+		Description: "使用ONNX模型对消息进行推理",
+	}
+}
+
+func (x *OnnxInferenceNode) New() types.Node {
+	return &OnnxInferenceNode{config: OnnxInferenceNodeConfiguration{TimeoutMs: 30000}}
+}
+
+// Init 初始化
+func (x *OnnxInferenceNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.ModelFile == "" {
+		return errors.New("modelFile can not empty")
+	}
+	if len(x.config.Features) == 0 {
+		return errors.New("features can not empty")
+	}
+	if x.model, err = x.loadModel(); err != nil {
+		return err
+	}
+	if x.config.InputName == "" {
+		if len(x.model.Inputs) == 0 {
+			return errors.New("model has no input")
+		}
+		x.config.InputName = x.model.Inputs[0]
+	}
+	if len(x.config.Outputs) == 0 {
+		if len(x.model.Outputs) == 0 {
+			return errors.New("model has no output")
+		}
+		x.config.Outputs = map[string]string{x.model.Outputs[0]: "prediction"}
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *OnnxInferenceNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	err := x.infer(&msg)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// RequiredDataType 需要JSON数据，TEXT消息由引擎自动转换
+func (x *OnnxInferenceNode) RequiredDataType() types.DataType {
+	return types.JSON
+}
+
+// Destroy 销毁
+func (x *OnnxInferenceNode) Destroy() {
+}
+
+func (x *OnnxInferenceNode) infer(msg *types.RuleMsg) error {
+	if msg.DataType != types.JSON {
+		return errors.New("data type must be JSON")
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+		return err
+	}
+	features := make([]float64, len(x.config.Features))
+	for i, path := range x.config.Features {
+		v, err := toFeature(maps.Get(data, path))
+		if err != nil {
+			return fmt.Errorf("feature %s error:%w", path, err)
+		}
+		features[i] = v
+	}
+	input, err := onnx.NewTensor([]int{1, len(features)}, features)
+	if err != nil {
+		return err
+	}
+	outputs, err := x.model.Run(map[string]*onnx.Tensor{x.config.InputName: input})
+	if err != nil {
+		return err
+	}
+	for name, field := range x.config.Outputs {
+		output, ok := outputs[name]
+		if !ok {
+			return errors.New("model output not found:" + name)
+		}
+		if output.Size() == 1 {
+			data[field] = output.Data[0]
+		} else {
+			data[field] = output.Data
+		}
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	msg.Data = string(b)
+	return nil
+}
+
+// loadModel 从文件或者URL加载模型
+func (x *OnnxInferenceNode) loadModel() (*onnx.Model, error) {
+	if !strings.HasPrefix(x.config.ModelFile, "http://") && !strings.HasPrefix(x.config.ModelFile, "https://") {
+		return onnx.Open(x.config.ModelFile)
+	}
+	client := &http.Client{Timeout: time.Duration(x.config.TimeoutMs) * time.Millisecond}
+	resp, err := client.Get(x.config.ModelFile)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("download model error:" + resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return onnx.Parse(b)
+}
+
+// toFeature 把JSON值转换成特征值，支持数值、布尔和数值字符串
+func toFeature(v interface{}) (float64, error) {
+	switch value := v.(type) {
+	case float64:
+		return value, nil
+	case bool:
+		if value {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseFloat(value, 64)
+	case nil:
+		return 0, errors.New("not found")
+	default:
+		return 0, fmt.Errorf("unsupported value %v", value)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/base64"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testOnnxModel 两层感知机分类模型：softmax(relu(x*W1+B1)*W2+B2)
+// 输入：x[1,2]，输出：prob[1,2] 和 label[1,1]
+const testOnnxModel = "CAg64AIKHAoBeAoCVzEKAkIxEgFoGgZHZW1tX2giBEdlbW0KFAoBaBIBchoGUmVsdV9yIgRSZWx1ChwKAXIKAlcyEgFtGghNYXRNdWxfbSIGTWF0TXVsCiAKAW0KAkIyEgZsb2dpdHMaCkFkZF9sb2dpdHMiA0FkZAoyCgZsb2dpdHMSBHByb2IaDFNvZnRtYXhfcHJvYiIHU29mdG1heCoLCgRheGlzGAGgAQIKMAoEcHJvYhIFbGFiZWwaDEFyZ01heF9sYWJlbCIGQXJnTWF4KgsKBGF4aXMYAaABAhIEdGVzdCocCAIIAhABIhAAAIA/AAAAQAAAQEAAAIBAQgJXMSoSCAIQAUICQjFKCAAAAD8AAIC/KhwIAggCEAEiEAAAgD8AAIC/AACAvwAAgD9CAlcyKhIIAhABIggAAAAAAAAAP0ICQjJaAwoBeFoECgJXMWIGCgRwcm9iYgcKBWxhYmVsQgQKABAN"
+
+func TestOnnxInferenceNodeOnMsg(t *testing.T) {
+	model, err := base64.StdEncoding.DecodeString(testOnnxModel)
+	assert.Nil(t, err)
+	modelFile := filepath.Join(t.TempDir(), "model.onnx")
+	assert.Nil(t, os.WriteFile(modelFile, model, 0644))
+
+	config := types.NewConfig()
+	node := (&OnnxInferenceNode{}).New()
+	err = node.Init(config, types.Configuration{
+		"modelFile": modelFile,
+		"features":  []string{"$.temperature", "$.sensor.values[0]"},
+		"outputs":   map[string]string{"label": "class", "prob": "probabilities"},
+	})
+	assert.Nil(t, err)
+
+	var relation string
+	var result types.RuleMsg
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		result = msg
+	})
+	metaData := types.NewMetadata()
+	err = node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metaData, `{"temperature":1,"sensor":{"values":["1"]}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relation)
+	var data map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(result.Data), &data))
+	assert.Equal(t, 1.0, data["class"])
+	assert.Equal(t, 2, len(data["probabilities"].([]interface{})))
+	assert.Equal(t, 1.0, data["temperature"])
+
+	err = node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metaData, `{"temperature":1,"sensor":{"values":[false]}}`))
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal([]byte(result.Data), &data))
+	assert.Equal(t, 0.0, data["class"])
+
+	//缺少特征
+	err = node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metaData, `{"temperature":1}`))
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relation)
+
+	//从URL加载模型，默认输出
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(model)
+	}))
+	defer server.Close()
+	node = (&OnnxInferenceNode{}).New()
+	err = node.Init(config, types.Configuration{
+		"modelFile": server.URL + "/model.onnx",
+		"features":  []string{"a", "b"},
+	})
+	assert.Nil(t, err)
+	err = node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metaData, `{"a":1,"b":1}`))
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal([]byte(result.Data), &data))
+	assert.Equal(t, 2, len(data["prediction"].([]interface{})))
+}
+
+func TestOnnxInferenceNodeInit(t *testing.T) {
+	config := types.NewConfig()
+	assert.NotNil(t, (&OnnxInferenceNode{}).New().Init(config, types.Configuration{"features": []string{"a"}}))
+	assert.NotNil(t, (&OnnxInferenceNode{}).New().Init(config, types.Configuration{"modelFile": "./model.onnx"}))
+	assert.NotNil(t, (&OnnxInferenceNode{}).New().Init(config, types.Configuration{"modelFile": "./not_exist.onnx", "features": []string{"a"}}))
+}
@@ -0,0 +1,491 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/mqtt"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// outbox表记录状态
+const (
+	outboxStatusPending = 0
+	outboxStatusSent    = 1
+	outboxStatusFailed  = 2
+)
+
+// outboxIdKey outbox记录ID元数据key
+const outboxIdKey = "outboxId"
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "outbox",
+//	       "name": "保存订单并可靠发布",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "dbType": "mysql",
+//	         "dsn": "root:root@tcp(127.0.0.1:3306)/test",
+//	         "sql": "insert into orders (id, status) values (?, ?)",
+//	         "params": ["${orderId}", "created"],
+//	         "topic": "orders/${orderId}",
+//	         "publisher": "mqtt",
+//	         "publisherConfig": {"server": "127.0.0.1:1883"}
+//	       }
+//	     }
+func init() {
+	Registry.Add(&OutboxNode{})
+}
+
+// OutboxPublisher outbox分发器发布消息的目标
+type OutboxPublisher interface {
+	//Publish 发布消息，key用于分区，例如kafka消息key
+	Publish(topic, key string, payload []byte) error
+	Close() error
+}
+
+// OutboxPublisherFactory 根据配置创建发布者
+type OutboxPublisherFactory func(config types.Configuration) (OutboxPublisher, error)
+
+var (
+	outboxPublishers = map[string]OutboxPublisherFactory{
+		"mqtt": newOutboxMqttPublisher,
+	}
+	outboxPublishersLock sync.RWMutex
+)
+
+// RegisterOutboxPublisher 注册outbox发布者，内置：mqtt
+// 可以注册其他消息中间件，例如kafka
+func RegisterOutboxPublisher(name string, factory OutboxPublisherFactory) {
+	outboxPublishersLock.Lock()
+	defer outboxPublishersLock.Unlock()
+	outboxPublishers[name] = factory
+}
+
+// outboxMqttPublisher mqtt发布者
+type outboxMqttPublisher struct {
+	client *mqtt.Client
+	qos    uint8
+}
+
+func newOutboxMqttPublisher(config types.Configuration) (OutboxPublisher, error) {
+	var mqttConfig MqttClientNodeConfiguration
+	if err := maps.Map2Struct(config, &mqttConfig); err != nil {
+		return nil, err
+	}
+	client, err := mqtt.NewClient(mqttConfig.ToMqttConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &outboxMqttPublisher{client: client, qos: mqttConfig.QOS}, nil
+}
+
+func (p *outboxMqttPublisher) Publish(topic, key string, payload []byte) error {
+	return p.client.Publish(topic, p.qos, payload)
+}
+
+func (p *outboxMqttPublisher) Close() error {
+	return p.client.Close()
+}
+
+// OutboxNodeConfiguration 节点配置
+type OutboxNodeConfiguration struct {
+	//DbType 数据库类型，mysql或postgres
+	DbType string
+	//Dsn 数据库连接配置，参考sql.Open参数
+	Dsn string `sensitive:"true"`
+	//PoolSize 连接池大小
+	PoolSize int
+	//Table outbox表名，默认：rulego_outbox
+	Table string
+	//CreateTable 是否自动创建outbox表
+	CreateTable bool
+	//Sql 业务操作语句，和outbox记录在同一个事务中执行，可以为空，可以使用${}占位符
+	Sql string
+	//Params 业务操作参数，可以使用${}占位符
+	Params []interface{}
+	//Topic 发布主题，可以使用 ${metaKeyName} 替换元数据中的变量
+	Topic string
+	//Key 消息key，可以使用 ${metaKeyName} 替换元数据中的变量
+	Key string
+	//Dispatch 是否启动后台分发器，多个节点写同一个outbox表时，可以只在一个节点启动，默认true
+	Dispatch bool
+	//Publisher 发布者类型，默认：mqtt
+	Publisher string
+	//PublisherConfig 发布者配置，mqtt参考mqttClient节点配置
+	PublisherConfig types.Configuration
+	//DispatchIntervalMs 分发器轮询间隔，单位毫秒，默认1000
+	DispatchIntervalMs int
+	//BatchSize 每次轮询最多发布的记录数，默认100
+	BatchSize int
+	//MaxRetries 最大重试次数，超过则标记为失败，不再发布，0不限制，默认10
+	MaxRetries int
+	//RetryIntervalMs 首次重试间隔，之后每次翻倍，单位毫秒，默认1000
+	RetryIntervalMs int
+	//MaxRetryIntervalMs 最大重试间隔，单位毫秒，默认60000
+	MaxRetryIntervalMs int
+}
+
+// OutboxNode 事务性outbox节点，把消息写入outbox表，后台分发器把已经提交的记录可靠地发布到消息中间件
+// 业务操作Sql和outbox记录在同一个事务中写入，发布失败按照指数退避重试，保证数据库写入和消息发布的最终一致
+// 发布是至少一次语义，消费者需要按照元数据outboxId去重
+// 如果写入成功，发送消息到`Success`链，metaData.outboxId记录ID, 否则发到`Failure`链
+type OutboxNode struct {
+	config     OutboxNodeConfiguration
+	store      outboxStore
+	dispatcher *outboxDispatcher
+	clock      clock.Clock
+	//参数是否有变量
+	paramsHasVar bool
+}
+
+// Type 组件类型
+func (x *OutboxNode) Type() string {
+	return "outbox"
+}
+
+// Descriptor 组件描述
+func (x *OutboxNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"database", "sql", "outbox", "mqtt", "messaging"},
+		Description: "把消息写入outbox表，并由后台分发器可靠发布到消息中间件",
+	}
+}
+
+func (x *OutboxNode) New() types.Node {
+	return &OutboxNode{config: OutboxNodeConfiguration{
+		DbType:             "mysql",
+		Table:              "rulego_outbox",
+		Dispatch:           true,
+		Publisher:          "mqtt",
+		DispatchIntervalMs: 1000,
+		BatchSize:          100,
+		MaxRetries:         10,
+		RetryIntervalMs:    1000,
+		MaxRetryIntervalMs: 60000,
+	}}
+}
+
+// Init 初始化
+func (x *OutboxNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Topic == "" {
+		return errors.New("topic can not empty")
+	}
+	for _, item := range x.config.Params {
+		if v, ok := item.(string); ok && str.CheckHasVar(v) {
+			x.paramsHasVar = true
+			break
+		}
+	}
+	x.config.Sql = str.ConvertDollarPlaceholder(x.config.Sql, x.config.DbType)
+	x.clock = ruleConfig.GetClock()
+	store, err := newSqlOutboxStore(x.config)
+	if err != nil {
+		return err
+	}
+	x.store = store
+	if x.config.Dispatch {
+		outboxPublishersLock.RLock()
+		factory, ok := outboxPublishers[x.config.Publisher]
+		outboxPublishersLock.RUnlock()
+		if !ok {
+			return errors.New("unsupported publisher:" + x.config.Publisher)
+		}
+		publisher, err := factory(x.config.PublisherConfig)
+		if err != nil {
+			return err
+		}
+		x.dispatcher = newOutboxDispatcher(x.config, x.store, publisher, ruleConfig.GetClock(), ruleConfig.Logger)
+		x.dispatcher.start()
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *OutboxNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	metaData := msg.Metadata.Values()
+	params := x.config.Params
+	if x.paramsHasVar {
+		params = make([]interface{}, 0, len(x.config.Params))
+		for _, item := range x.config.Params {
+			if v, ok := item.(string); ok {
+				params = append(params, str.SprintfDict(v, metaData))
+			} else {
+				params = append(params, item)
+			}
+		}
+	}
+	record := outboxRecord{
+		Topic:     str.SprintfDict(x.config.Topic, metaData),
+		Key:       str.SprintfDict(x.config.Key, metaData),
+		Payload:   msg.Data,
+		CreatedAt: x.clock.Now().UnixMilli(),
+	}
+	id, err := x.store.add(str.SprintfDict(x.config.Sql, metaData), params, record)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		msg.Metadata.PutValue(outboxIdKey, strconv.FormatInt(id, 10))
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (x *OutboxNode) Destroy() {
+	if x.dispatcher != nil {
+		x.dispatcher.stop()
+	}
+	if x.store != nil {
+		_ = x.store.close()
+	}
+}
+
+// outboxRecord outbox表记录
+type outboxRecord struct {
+	Id       int64
+	Topic    string
+	Key      string
+	Payload  string
+	Attempts int
+	//CreatedAt 创建时间，单位毫秒
+	CreatedAt int64
+}
+
+// outboxStore outbox表存储
+type outboxStore interface {
+	//add 在同一个事务中执行业务语句和写入outbox记录，返回记录ID
+	add(sqlStr string, params []interface{}, record outboxRecord) (int64, error)
+	//pending 查询到达发布时间的待发布记录，按照ID排序
+	pending(now int64, limit int) ([]outboxRecord, error)
+	//markSent 标记为已发布
+	markSent(id int64) error
+	//markRetry 记录发布失败，failed为true则不再重试
+	markRetry(id int64, attempts int, nextAttemptAt int64, failed bool, lastError string) error
+	close() error
+}
+
+// sqlOutboxStore 基于数据库表的outbox存储
+type sqlOutboxStore struct {
+	db     *sql.DB
+	dbType string
+	table  string
+}
+
+func newSqlOutboxStore(config OutboxNodeConfiguration) (*sqlOutboxStore, error) {
+	db, err := sql.Open(config.DbType, config.Dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.PoolSize)
+	db.SetMaxIdleConns(config.PoolSize / 2)
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	s := &sqlOutboxStore{db: db, dbType: config.DbType, table: config.Table}
+	if config.CreateTable {
+		if _, err = db.Exec(s.createTableSql()); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *sqlOutboxStore) createTableSql() string {
+	idColumn := "id BIGINT AUTO_INCREMENT PRIMARY KEY"
+	if s.dbType == "postgres" {
+		idColumn = "id BIGSERIAL PRIMARY KEY"
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s, topic VARCHAR(512) NOT NULL, msg_key VARCHAR(256), payload TEXT, "+
+		"status SMALLINT NOT NULL DEFAULT 0, attempts INT NOT NULL DEFAULT 0, next_attempt_at BIGINT NOT NULL DEFAULT 0, "+
+		"last_error VARCHAR(1024), created_at BIGINT NOT NULL)", s.table, idColumn)
+}
+
+func (s *sqlOutboxStore) sql(sqlStr string) string {
+	return str.ConvertDollarPlaceholder(sqlStr, s.dbType)
+}
+
+func (s *sqlOutboxStore) add(sqlStr string, params []interface{}, record outboxRecord) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if sqlStr != "" {
+		if _, err = tx.Exec(sqlStr, params...); err != nil {
+			return 0, err
+		}
+	}
+	insertSql := s.sql(fmt.Sprintf("INSERT INTO %s (topic, msg_key, payload, status, attempts, next_attempt_at, created_at) VALUES (?, ?, ?, ?, 0, 0, ?)", s.table))
+	args := []interface{}{record.Topic, record.Key, record.Payload, outboxStatusPending, record.CreatedAt}
+	var id int64
+	if s.dbType == "postgres" {
+		err = tx.QueryRow(insertSql+" RETURNING id", args...).Scan(&id)
+	} else {
+		var result sql.Result
+		if result, err = tx.Exec(insertSql, args...); err == nil {
+			id, err = result.LastInsertId()
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (s *sqlOutboxStore) pending(now int64, limit int) ([]outboxRecord, error) {
+	rows, err := s.db.Query(s.sql(fmt.Sprintf("SELECT id, topic, msg_key, payload, attempts FROM %s WHERE status = ? AND next_attempt_at <= ? ORDER BY id LIMIT %d", s.table, limit)),
+		outboxStatusPending, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []outboxRecord
+	for rows.Next() {
+		var record outboxRecord
+		var key, payload sql.NullString
+		if err = rows.Scan(&record.Id, &record.Topic, &key, &payload, &record.Attempts); err != nil {
+			return nil, err
+		}
+		record.Key = key.String
+		record.Payload = payload.String
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (s *sqlOutboxStore) markSent(id int64) error {
+	_, err := s.db.Exec(s.sql(fmt.Sprintf("UPDATE %s SET status = ? WHERE id = ?", s.table)), outboxStatusSent, id)
+	return err
+}
+
+func (s *sqlOutboxStore) markRetry(id int64, attempts int, nextAttemptAt int64, failed bool, lastError string) error {
+	status := outboxStatusPending
+	if failed {
+		status = outboxStatusFailed
+	}
+	if len(lastError) > 1024 {
+		lastError = lastError[:1024]
+	}
+	_, err := s.db.Exec(s.sql(fmt.Sprintf("UPDATE %s SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?", s.table)),
+		status, attempts, nextAttemptAt, lastError, id)
+	return err
+}
+
+func (s *sqlOutboxStore) close() error {
+	return s.db.Close()
+}
+
+// outboxDispatcher 后台分发器，轮询outbox表，发布待发布记录
+type outboxDispatcher struct {
+	config    OutboxNodeConfiguration
+	store     outboxStore
+	publisher OutboxPublisher
+	clock     clock.Clock
+	logger    types.Logger
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	//lock 保证同一时间只有一个轮询在执行
+	lock sync.Mutex
+}
+
+func newOutboxDispatcher(config OutboxNodeConfiguration, store outboxStore, publisher OutboxPublisher, clock clock.Clock, logger types.Logger) *outboxDispatcher {
+	return &outboxDispatcher{config: config, store: store, publisher: publisher, clock: clock, logger: logger, stopCh: make(chan struct{})}
+}
+
+func (d *outboxDispatcher) start() {
+	ticker := d.clock.NewTicker(time.Duration(d.config.DispatchIntervalMs) * time.Millisecond)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stopCh:
+				return
+			case <-ticker.C():
+				d.dispatch()
+			}
+		}
+	}()
+}
+
+func (d *outboxDispatcher) stop() {
+	close(d.stopCh)
+	d.wg.Wait()
+	_ = d.publisher.Close()
+}
+
+// dispatch 发布一批待发布记录，返回发布成功的数量
+func (d *outboxDispatcher) dispatch() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	now := d.clock.Now()
+	records, err := d.store.pending(now.UnixMilli(), d.config.BatchSize)
+	if err != nil {
+		d.logger.Printf("outbox query pending records err=%s", err)
+		return 0
+	}
+	sent := 0
+	for _, record := range records {
+		if err = d.publisher.Publish(record.Topic, record.Key, []byte(record.Payload)); err == nil {
+			if err = d.store.markSent(record.Id); err != nil {
+				d.logger.Printf("outbox mark sent id=%d err=%s", record.Id, err)
+			}
+			sent++
+			continue
+		}
+		attempts := record.Attempts + 1
+		failed := d.config.MaxRetries > 0 && attempts >= d.config.MaxRetries
+		nextAttemptAt := now.Add(d.backoff(attempts)).UnixMilli()
+		if markErr := d.store.markRetry(record.Id, attempts, nextAttemptAt, failed, err.Error()); markErr != nil {
+			d.logger.Printf("outbox mark retry id=%d err=%s", record.Id, markErr)
+		}
+	}
+	return sent
+}
+
+// backoff 第attempts次失败后的重试间隔
+func (d *outboxDispatcher) backoff(attempts int) time.Duration {
+	interval := time.Duration(d.config.RetryIntervalMs) * time.Millisecond
+	maxInterval := time.Duration(d.config.MaxRetryIntervalMs) * time.Millisecond
+	for i := 1; i < attempts && interval < maxInterval; i++ {
+		interval *= 2
+	}
+	if maxInterval > 0 && interval > maxInterval {
+		interval = maxInterval
+	}
+	return interval
+}
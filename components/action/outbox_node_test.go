@@ -0,0 +1,200 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryOutboxStore 测试使用的内存outbox存储
+type memoryOutboxStore struct {
+	records  []*memoryOutboxRecord
+	addErr   error
+	sqlCalls []string
+	sync.Mutex
+}
+
+type memoryOutboxRecord struct {
+	outboxRecord
+	status        int
+	nextAttemptAt int64
+	lastError     string
+}
+
+func (s *memoryOutboxStore) add(sqlStr string, params []interface{}, record outboxRecord) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.addErr != nil {
+		return 0, s.addErr
+	}
+	s.sqlCalls = append(s.sqlCalls, sqlStr)
+	record.Id = int64(len(s.records) + 1)
+	s.records = append(s.records, &memoryOutboxRecord{outboxRecord: record})
+	return record.Id, nil
+}
+
+func (s *memoryOutboxStore) pending(now int64, limit int) ([]outboxRecord, error) {
+	s.Lock()
+	defer s.Unlock()
+	var records []outboxRecord
+	for _, r := range s.records {
+		if r.status == outboxStatusPending && r.nextAttemptAt <= now && len(records) < limit {
+			records = append(records, r.outboxRecord)
+		}
+	}
+	return records, nil
+}
+
+func (s *memoryOutboxStore) markSent(id int64) error {
+	s.Lock()
+	defer s.Unlock()
+	s.records[id-1].status = outboxStatusSent
+	return nil
+}
+
+func (s *memoryOutboxStore) markRetry(id int64, attempts int, nextAttemptAt int64, failed bool, lastError string) error {
+	s.Lock()
+	defer s.Unlock()
+	r := s.records[id-1]
+	r.Attempts = attempts
+	r.nextAttemptAt = nextAttemptAt
+	r.lastError = lastError
+	if failed {
+		r.status = outboxStatusFailed
+	}
+	return nil
+}
+
+func (s *memoryOutboxStore) close() error {
+	return nil
+}
+
+// testOutboxPublisher 测试使用的发布者
+type testOutboxPublisher struct {
+	fail      map[string]bool
+	published []string
+	closed    bool
+}
+
+func (p *testOutboxPublisher) Publish(topic, key string, payload []byte) error {
+	if p.fail[topic] {
+		return errors.New("publish failed")
+	}
+	p.published = append(p.published, topic+"|"+key+"|"+string(payload))
+	return nil
+}
+
+func (p *testOutboxPublisher) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestOutboxNodeOnMsg(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	store := &memoryOutboxStore{}
+	node := (&OutboxNode{}).New().(*OutboxNode)
+	node.config.Topic = "orders/${orderId}"
+	node.config.Key = "${orderId}"
+	node.config.Sql = "insert into orders (id) values (${orderId})"
+	node.store = store
+	node.clock = vc
+
+	var relation, outboxId string
+	ctx := test.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		outboxId, _ = msg.Metadata.GetValue(outboxIdKey).(string)
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("orderId", "o1")
+	err := node.OnMsg(ctx, ctx.NewMsg("ORDER", metaData, `{"id":"o1"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, "1", outboxId)
+	assert.Equal(t, "orders/o1", store.records[0].Topic)
+	assert.Equal(t, "o1", store.records[0].Key)
+	assert.Equal(t, int64(1700000000000), store.records[0].CreatedAt)
+	assert.Equal(t, "insert into orders (id) values (o1)", store.sqlCalls[0])
+
+	store.addErr = errors.New("db error")
+	err = node.OnMsg(ctx, ctx.NewMsg("ORDER", metaData, `{"id":"o1"}`))
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relation)
+
+	err = (&OutboxNode{}).New().Init(types.NewConfig(), types.Configuration{})
+	assert.NotNil(t, err)
+}
+
+func TestOutboxDispatcher(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	store := &memoryOutboxStore{}
+	publisher := &testOutboxPublisher{fail: map[string]bool{"bad": true}}
+	config := (&OutboxNode{}).New().(*OutboxNode).config
+	config.MaxRetries = 3
+	config.BatchSize = 2
+	dispatcher := newOutboxDispatcher(config, store, publisher, vc, types.DefaultLogger())
+
+	_, _ = store.add("", nil, outboxRecord{Topic: "a", Key: "k1", Payload: "1"})
+	_, _ = store.add("", nil, outboxRecord{Topic: "bad", Payload: "2"})
+	_, _ = store.add("", nil, outboxRecord{Topic: "b", Payload: "3"})
+
+	//每次最多发布BatchSize条
+	assert.Equal(t, 1, dispatcher.dispatch())
+	assert.Equal(t, []string{"a|k1|1"}, publisher.published)
+	assert.Equal(t, outboxStatusSent, store.records[0].status)
+	assert.Equal(t, 1, store.records[1].Attempts)
+	assert.Equal(t, "publish failed", store.records[1].lastError)
+
+	//失败的记录等待重试间隔
+	assert.Equal(t, 1, dispatcher.dispatch())
+	assert.Equal(t, []string{"a|k1|1", "b||3"}, publisher.published)
+	assert.Equal(t, 1, store.records[1].Attempts)
+
+	//指数退避：1s、2s，第3次失败后标记失败
+	vc.Advance(time.Second)
+	dispatcher.dispatch()
+	assert.Equal(t, 2, store.records[1].Attempts)
+	vc.Advance(time.Second)
+	dispatcher.dispatch()
+	assert.Equal(t, 2, store.records[1].Attempts)
+	vc.Advance(time.Second)
+	dispatcher.dispatch()
+	assert.Equal(t, 3, store.records[1].Attempts)
+	assert.Equal(t, outboxStatusFailed, store.records[1].status)
+	vc.Advance(time.Hour)
+	assert.Equal(t, 0, dispatcher.dispatch())
+	assert.Equal(t, 3, store.records[1].Attempts)
+
+	dispatcher.start()
+	dispatcher.stop()
+	assert.True(t, publisher.closed)
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	d := &outboxDispatcher{config: OutboxNodeConfiguration{RetryIntervalMs: 1000, MaxRetryIntervalMs: 5000}}
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 4*time.Second, d.backoff(3))
+	assert.Equal(t, 5*time.Second, d.backoff(4))
+	assert.Equal(t, 5*time.Second, d.backoff(100))
+}
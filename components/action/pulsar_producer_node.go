@@ -0,0 +1,216 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/pulsar"
+	"github.com/2018yuli/rulego/utils/json"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "pulsarProducer",
+//	       "name": "发送到Pulsar",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "server": "ws://127.0.0.1:8080",
+//	         "topic": "persistent://public/default/device-${deviceId}",
+//	         "key": "${deviceId}",
+//	         "properties": {"productType":"${productType}"}
+//	       }
+//	     }
+func init() {
+	Registry.Add(&PulsarProducerNode{})
+}
+
+// PulsarProducerNodeConfiguration 节点配置
+type PulsarProducerNodeConfiguration struct {
+	//Server WebSocket服务地址，例如：ws://127.0.0.1:8080
+	Server string
+	//Token JWT认证token
+	Token string `sensitive:"true"`
+	//Topic 主题，支持完整主题名persistent://tenant/namespace/topic或者简写topic
+	//可以使用 ${metaKeyName} 替换元数据中的变量
+	Topic string
+	//Key 消息key，可以使用 ${metaKeyName} 替换元数据中的变量
+	Key string
+	//Properties 消息属性，value可以使用 ${metaKeyName} 替换元数据中的变量
+	Properties map[string]string
+	//SchemaType 主题schema类型，例如：JSON、AVRO、STRING，不为空则创建生产者前上传schema
+	//JSON类型会检查msg.Data是否是合法的JSON
+	SchemaType string
+	//Schema schema定义
+	Schema string
+	//AdminUrl admin REST接口地址，上传schema使用，默认把Server的ws协议替换成http
+	AdminUrl string
+	//ConnectTimeoutMs 连接和发送超时，单位毫秒
+	ConnectTimeoutMs int
+}
+
+// PulsarProducerNode 把msg.Data发送到Apache Pulsar主题
+// 通过Pulsar WebSocket API发送，每个主题使用一个生产者，发送失败会重新连接
+// 如果发送成功，发送消息到`Success`链，metaData.messageId记录消息ID, 否则发到`Failure`链
+type PulsarProducerNode struct {
+	config PulsarProducerNodeConfiguration
+	//pulsarConfig 连接配置
+	pulsarConfig pulsar.Config
+	//producers 主题对应的生产者
+	producers map[string]*pulsar.Producer
+	lock      sync.Mutex
+}
+
+// Type 组件类型
+func (x *PulsarProducerNode) Type() string {
+	return "pulsarProducer"
+}
+
+// Descriptor 组件描述
+func (x *PulsarProducerNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"pulsar", "messaging"},
+		Description: "把消息发送到Apache Pulsar主题",
+	}
+}
+
+func (x *PulsarProducerNode) New() types.Node {
+	return &PulsarProducerNode{config: PulsarProducerNodeConfiguration{
+		ConnectTimeoutMs: 5000,
+	}}
+}
+
+// Init 初始化
+func (x *PulsarProducerNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Server == "" {
+		return errors.New("server can not empty")
+	}
+	if x.config.Topic == "" {
+		return errors.New("topic can not empty")
+	}
+	if x.config.AdminUrl == "" {
+		x.config.AdminUrl = strings.Replace(x.config.Server, "ws", "http", 1)
+	}
+	x.pulsarConfig = pulsar.Config{
+		Server:         x.config.Server,
+		Token:          x.config.Token,
+		ConnectTimeout: time.Duration(x.config.ConnectTimeoutMs) * time.Millisecond,
+	}
+	x.producers = make(map[string]*pulsar.Producer)
+	//主题没有变量，则初始化时连接
+	if !strings.Contains(x.config.Topic, "${") {
+		_, err = x.getProducer(x.config.Topic)
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *PulsarProducerNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	messageId, err := x.send(msg)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		msg.Metadata.PutValue("messageId", messageId)
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (x *PulsarProducerNode) Destroy() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for topic, producer := range x.producers {
+		_ = producer.Close()
+		delete(x.producers, topic)
+	}
+}
+
+func (x *PulsarProducerNode) send(msg types.RuleMsg) (string, error) {
+	if strings.EqualFold(x.config.SchemaType, "JSON") {
+		var v interface{}
+		if err := json.Unmarshal([]byte(msg.Data), &v); err != nil {
+			return "", err
+		}
+	}
+	metaData := msg.Metadata.Values()
+	topic := str.SprintfDict(x.config.Topic, metaData)
+	properties := make(map[string]string, len(x.config.Properties))
+	for k, v := range x.config.Properties {
+		properties[str.SprintfDict(k, metaData)] = str.SprintfDict(v, metaData)
+	}
+	producerMsg := pulsar.ProducerMessage{
+		Payload:    msg.Data,
+		Key:        str.SprintfDict(x.config.Key, metaData),
+		Properties: properties,
+	}
+	producer, err := x.getProducer(topic)
+	if err != nil {
+		return "", err
+	}
+	messageId, err := producer.Send(producerMsg)
+	if err != nil {
+		//连接可能已经断开，重新连接后重试一次
+		x.removeProducer(topic, producer)
+		if producer, err = x.getProducer(topic); err != nil {
+			return "", err
+		}
+		messageId, err = producer.Send(producerMsg)
+	}
+	return messageId, err
+}
+
+// getProducer 获取主题的生产者，不存在则创建
+func (x *PulsarProducerNode) getProducer(topic string) (*pulsar.Producer, error) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if producer, ok := x.producers[topic]; ok {
+		return producer, nil
+	}
+	if x.config.SchemaType != "" {
+		schema := pulsar.Schema{Type: strings.ToUpper(x.config.SchemaType), Schema: x.config.Schema}
+		if err := pulsar.UploadSchema(x.config.AdminUrl, x.config.Token, topic, schema, x.pulsarConfig.ConnectTimeout); err != nil {
+			return nil, err
+		}
+	}
+	producer, err := pulsar.NewProducer(x.pulsarConfig, topic)
+	if err != nil {
+		return nil, err
+	}
+	x.producers[topic] = producer
+	return producer, nil
+}
+
+func (x *PulsarProducerNode) removeProducer(topic string, producer *pulsar.Producer) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.producers[topic] == producer {
+		delete(x.producers, topic)
+	}
+	_ = producer.Close()
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/base64"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPulsarProducerNodeOnMsg(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	var connCount int32
+	var schemaCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/admin/v2/schemas/") {
+			atomic.AddInt32(&schemaCount, 1)
+			return
+		}
+		assert.Equal(t, "/ws/v2/producer/persistent/public/default/device-aa", r.URL.Path)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		//第一个连接发送一条消息后断开，测试重连
+		count := atomic.AddInt32(&connCount, 1)
+		for {
+			var request struct {
+				Payload    string            `json:"payload"`
+				Key        string            `json:"key"`
+				Properties map[string]string `json:"properties"`
+			}
+			if conn.ReadJSON(&request) != nil {
+				return
+			}
+			payload, _ := base64.StdEncoding.DecodeString(request.Payload)
+			assert.Equal(t, "{\"temperature\":41}", string(payload))
+			assert.Equal(t, "aa", request.Key)
+			assert.Equal(t, "test", request.Properties["productType"])
+			_ = conn.WriteJSON(map[string]string{"result": "ok", "messageId": "m1"})
+			if count == 1 {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	config := types.NewConfig()
+	configuration := types.Configuration{
+		"server":     "ws" + strings.TrimPrefix(server.URL, "http"),
+		"topic":      "device-${deviceId}",
+		"key":        "${deviceId}",
+		"properties": map[string]string{"productType": "${productType}"},
+		"schemaType": "json",
+	}
+	node := (&PulsarProducerNode{}).New()
+	err := node.Init(config, configuration)
+	assert.Nil(t, err)
+	defer node.Destroy()
+
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	metaData.PutValue("productType", "test")
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, "m1", msg.Metadata.GetValue("messageId"))
+	})
+	msg := ctx.NewMsg("TEST_MSG_TYPE_AA", metaData, "{\"temperature\":41}")
+	assert.Nil(t, node.OnMsg(ctx, msg))
+	assert.Nil(t, node.OnMsg(ctx, msg))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&connCount))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&schemaCount))
+
+	//JSON schema 检查数据格式
+	ctx = test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Failure, relationType)
+	})
+	assert.NotNil(t, node.OnMsg(ctx, ctx.NewMsg("TEST_MSG_TYPE_AA", metaData, "aa")))
+
+	//缺少主题
+	delete(configuration, "topic")
+	assert.NotNil(t, (&PulsarProducerNode{}).New().Init(config, configuration))
+}
@@ -0,0 +1,247 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+// {
+//        "id": "s3",
+//        "type": "remoteComponent",
+//        "name": "评分",
+//        "configuration": {
+//          "server": "http://127.0.0.1:9090/components",
+//          "component": "score",
+//          "configuration": {"model": "v2"}
+//        }
+//      }
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func init() {
+	Registry.Add(&RemoteComponentNode{})
+}
+
+// RemoteComponentMsg 远程组件服务请求和响应中的消息
+type RemoteComponentMsg struct {
+	Id       string                 `json:"id,omitempty"`
+	Ts       int64                  `json:"ts,omitempty"`
+	Type     string                 `json:"type"`
+	DataType string                 `json:"dataType"`
+	Data     string                 `json:"data"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// RemoteComponentRequest 远程组件服务请求，以json格式POST到组件服务地址
+type RemoteComponentRequest struct {
+	//Component 远程组件名称
+	Component string `json:"component"`
+	//Configuration 远程组件配置
+	Configuration map[string]interface{} `json:"configuration,omitempty"`
+	//Msg 需要处理的消息
+	Msg RemoteComponentMsg `json:"msg"`
+}
+
+// RemoteComponentResponse 远程组件服务响应，HTTP状态码为200时有效
+type RemoteComponentResponse struct {
+	//RelationType 消息发送到的关系，为空则发送到`Success`链
+	RelationType string `json:"relationType,omitempty"`
+	//Msg 处理后的消息，为空则消息不变，metadata覆盖原消息的同名元数据
+	Msg *RemoteComponentMsg `json:"msg,omitempty"`
+	//Error 处理失败的错误信息，不为空则消息发送到`Failure`链
+	Error string `json:"error,omitempty"`
+}
+
+// RemoteComponentHandler 使用handle实现远程组件服务，返回错误时响应的Error为错误信息
+// 用于把耗资源或者需要授权的处理逻辑部署在规则引擎进程外，例如：
+//
+//	http.Handle("/components", action.RemoteComponentHandler(func(req action.RemoteComponentRequest) (action.RemoteComponentResponse, error) {
+//		req.Msg.Data = strings.ToUpper(req.Msg.Data)
+//		return action.RemoteComponentResponse{Msg: &req.Msg}, nil
+//	}))
+func RemoteComponentHandler(handle func(req RemoteComponentRequest) (RemoteComponentResponse, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req RemoteComponentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := handle(req)
+		if err != nil {
+			resp = RemoteComponentResponse{Error: err.Error()}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// RemoteComponentNodeConfiguration 节点配置
+type RemoteComponentNodeConfiguration struct {
+	//Server 远程组件服务地址，可以使用 ${metaKeyName} 替换元数据中的变量
+	Server string
+	//Component 远程组件名称
+	Component string
+	//Configuration 远程组件配置，每次请求原样发送
+	Configuration map[string]interface{}
+	//Headers 请求头，可以使用 ${metaKeyName} 替换元数据中的变量，例如授权信息
+	Headers map[string]string
+	//TimeoutMs 请求超时时间，单位毫秒，默认10000
+	TimeoutMs int
+	//MaxParallelRequestsCount 连接池大小，默认200
+	MaxParallelRequestsCount int
+}
+
+// RemoteComponentNode 把消息转发到远程组件服务处理，使用响应作为节点的处理结果，
+// 远程组件在规则链中和本地节点一样使用。响应的relationType决定消息发送到的关系，默认`Success`，
+// 请求失败、HTTP状态码不是200或者响应包含error则发送到`Failure`链，
+// HTTP状态码不是200时metaData.statusCode记录状态码，metaData.errorBody记录响应内容
+type RemoteComponentNode struct {
+	config     RemoteComponentNodeConfiguration
+	httpClient *http.Client
+}
+
+// Type 组件类型
+func (x *RemoteComponentNode) Type() string {
+	return "remoteComponent"
+}
+
+// Descriptor 组件描述
+func (x *RemoteComponentNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"http", "remote"},
+		Description: "把消息转发到远程组件服务处理",
+	}
+}
+
+func (x *RemoteComponentNode) New() types.Node {
+	return &RemoteComponentNode{config: RemoteComponentNodeConfiguration{
+		TimeoutMs:                10000,
+		MaxParallelRequestsCount: 200,
+	}}
+}
+
+// Init 初始化
+func (x *RemoteComponentNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Server == "" {
+		return errors.New("server can not be empty")
+	}
+	if x.config.Component == "" {
+		return errors.New("component can not be empty")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxConnsPerHost = x.config.MaxParallelRequestsCount
+	x.httpClient = &http.Client{Transport: transport, Timeout: time.Duration(x.config.TimeoutMs) * time.Millisecond}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *RemoteComponentNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	resp, err := x.call(&msg)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return nil
+	}
+	if resp.Msg != nil {
+		msg.Type = resp.Msg.Type
+		msg.DataType = types.DataType(resp.Msg.DataType)
+		msg.Data = resp.Msg.Data
+		for k, v := range resp.Msg.Metadata {
+			msg.Metadata.PutValue(k, v)
+		}
+	}
+	if resp.Error != "" {
+		ctx.TellFailure(msg, errors.New(resp.Error))
+		return nil
+	}
+	relationType := resp.RelationType
+	if relationType == "" {
+		relationType = types.Success
+	}
+	ctx.TellNext(msg, relationType)
+	return nil
+}
+
+// call 发送请求，HTTP状态码不是200时把状态码和响应内容保存到元数据并返回错误
+func (x *RemoteComponentNode) call(msg *types.RuleMsg) (RemoteComponentResponse, error) {
+	var resp RemoteComponentResponse
+	metadata := msg.Metadata.Values()
+	body, err := json.Marshal(RemoteComponentRequest{
+		Component:     x.config.Component,
+		Configuration: x.config.Configuration,
+		Msg: RemoteComponentMsg{
+			Id:       msg.Id,
+			Ts:       msg.Ts,
+			Type:     msg.Type,
+			DataType: string(msg.DataType),
+			Data:     msg.Data,
+			Metadata: metadata,
+		},
+	})
+	if err != nil {
+		return resp, err
+	}
+	req, err := http.NewRequest(http.MethodPost, str.SprintfDict(x.config.Server, metadata), bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range x.config.Headers {
+		req.Header.Set(str.SprintfDict(key, metadata), str.SprintfDict(value, metadata))
+	}
+	response, err := x.httpClient.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	defer response.Body.Close()
+	b, err := io.ReadAll(response.Body)
+	if err != nil {
+		return resp, err
+	}
+	if response.StatusCode != http.StatusOK {
+		msg.Metadata.PutValue(statusCode, strconv.Itoa(response.StatusCode))
+		msg.Metadata.PutValue(errorBody, string(b))
+		return resp, fmt.Errorf("remote component %s response status: %s", x.config.Component, response.Status)
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return resp, fmt.Errorf("remote component %s invalid response: %w", x.config.Component, err)
+	}
+	return resp, nil
+}
+
+// Destroy 销毁
+func (x *RemoteComponentNode) Destroy() {
+	if x.httpClient != nil {
+		x.httpClient.CloseIdleConnections()
+	}
+}
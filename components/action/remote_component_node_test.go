@@ -0,0 +1,92 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/str"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRemoteComponentNode(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/components", RemoteComponentHandler(func(req RemoteComponentRequest) (RemoteComponentResponse, error) {
+		switch req.Msg.Type {
+		case "FAIL":
+			return RemoteComponentResponse{}, errors.New("license expired")
+		case "ALARM":
+			return RemoteComponentResponse{RelationType: "True"}, nil
+		}
+		req.Msg.Data = strings.ToUpper(req.Msg.Data) + ":" + req.Configuration["model"].(string)
+		req.Msg.Metadata = map[string]interface{}{"component": req.Component}
+		return RemoteComponentResponse{Msg: &req.Msg}, nil
+	}))
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config := types.NewConfig()
+	newNode := func(path string) *RemoteComponentNode {
+		node := (&RemoteComponentNode{}).New().(*RemoteComponentNode)
+		assert.Nil(t, node.Init(config, types.Configuration{
+			"server":        server.URL + path,
+			"component":     "score",
+			"configuration": map[string]interface{}{"model": "v2"},
+		}))
+		return node
+	}
+	node := newNode("/${path}")
+	defer node.Destroy()
+	onMsg := func(node *RemoteComponentNode, msgType string) (types.RuleMsg, string) {
+		var result types.RuleMsg
+		var relation string
+		ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+			result, relation = msg, relationType
+		})
+		metadata := types.NewMetadata()
+		metadata.PutValue("path", "components")
+		metadata.PutValue("deviceId", "aa")
+		assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg(msgType, metadata, "test")))
+		return result, relation
+	}
+	msg, relationType := onMsg(node, "TEST")
+	assert.Equal(t, types.Success, relationType)
+	assert.Equal(t, "TEST:v2", msg.Data)
+	assert.Equal(t, "score", str.ToString(msg.Metadata.GetValue("component")))
+	assert.Equal(t, "aa", str.ToString(msg.Metadata.GetValue("deviceId")))
+
+	_, relationType = onMsg(node, "ALARM")
+	assert.Equal(t, "True", relationType)
+	_, relationType = onMsg(node, "FAIL")
+	assert.Equal(t, types.Failure, relationType)
+
+	down := newNode("/down")
+	defer down.Destroy()
+	msg, relationType = onMsg(down, "TEST")
+	assert.Equal(t, types.Failure, relationType)
+	assert.Equal(t, "503", str.ToString(msg.Metadata.GetValue(statusCode)))
+
+	assert.NotNil(t, (&RemoteComponentNode{}).New().Init(config, types.Configuration{"server": server.URL}))
+}
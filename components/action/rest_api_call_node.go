@@ -30,16 +30,23 @@ package action
 //      }
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/adaptive"
+	"github.com/2018yuli/rulego/utils/clock"
 	"github.com/2018yuli/rulego/utils/maps"
 	"github.com/2018yuli/rulego/utils/str"
 	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -47,6 +54,9 @@ func init() {
 	Registry.Add(&RestApiCallNode{})
 }
 
+// restLimiters 按主机共享的自适应并发限流器
+var restLimiters = adaptive.NewRegistry()
+
 // 存在到metadata key
 const (
 	//http响应状态
@@ -55,8 +65,23 @@ const (
 	statusCode = "statusCode"
 	//http响应错误信息
 	errorBody = "errorBody"
+	//响应缓存状态：hit、stale、miss
+	cacheStatus = "cacheStatus"
+	//请求重试次数
+	retryCount = "retryCount"
+	//请求错误类型，请求超时为timeout
+	requestError = "error"
 )
 
+// ErrRequestTimeout 请求超时，超时时metadata.statusCode为0，metadata.error为timeout
+var ErrRequestTimeout = errors.New("request timeout")
+
+// defaultRequestTimeoutKey 默认覆盖请求超时时间的元数据key
+const defaultRequestTimeoutKey = "requestTimeoutMs"
+
+// defaultRetryOn 默认重试的响应状态码
+var defaultRetryOn = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
 // RestApiCallNodeConfiguration rest配置
 type RestApiCallNodeConfiguration struct {
 	//RestEndpointUrlPattern HTTP URL地址目标,可以使用 ${metaKeyName} 替换元数据中的变量
@@ -67,6 +92,12 @@ type RestApiCallNodeConfiguration struct {
 	Headers map[string]string
 	//ReadTimeoutMs 超时，单位毫秒
 	ReadTimeoutMs int
+	//RequestTimeoutMs 每次请求的超时时间，单位毫秒，0使用ReadTimeoutMs
+	//超时则发送到`Failure`链，错误为ErrRequestTimeout，metadata.statusCode为0，metadata.error为timeout
+	RequestTimeoutMs int
+	//RequestTimeoutKey 覆盖请求超时时间的元数据key，单位毫秒，用于个别消息使用更短的超时时间，默认：requestTimeoutMs
+	//不超过ReadTimeoutMs
+	RequestTimeoutKey string
 	//MaxParallelRequestsCount 连接池大小，默认200
 	MaxParallelRequestsCount int
 	//EnableProxy 是否开启代理
@@ -80,9 +111,36 @@ type RestApiCallNodeConfiguration struct {
 	//ProxyUser 代理用户名
 	ProxyUser string
 	//ProxyPassword 代理密码
-	ProxyPassword string
+	ProxyPassword string `sensitive:"true"`
 	//ProxyScheme
 	ProxyScheme string
+	//CacheTtlMs GET请求响应缓存时间，单位毫秒，0不缓存
+	//缓存key为渲染后的URL和请求头，只缓存状态码200的响应
+	CacheTtlMs int
+	//CacheMaxEntries 最大缓存数量，超过淘汰最久未使用的，默认1000
+	CacheMaxEntries int
+	//CacheStaleMs 缓存过期后仍然返回旧值的时间窗口，同时在后台刷新，单位毫秒
+	CacheStaleMs int
+	//AdaptiveConcurrency 按请求延迟自动调整同一主机的并发请求数，为空不限制，相同主机的节点共享限流器
+	//请求出错或者响应状态码429、503时减少并发上限，达到上限的请求发送到`Failure`链，错误为adaptive.ErrLimitExceeded
+	AdaptiveConcurrency *adaptive.Config
+	//BinaryResponseBase64 非UTF-8的响应内容按base64编码，消息数据类型为BINARY
+	//默认false：原样保存到msg.Data，不修改消息数据类型，兼容读取原始响应内容的下游节点
+	BinaryResponseBase64 bool
+	//MaxRetries 请求失败最大重试次数，0不重试，重试次数记录到metadata.retryCount
+	//重试通过定时器调度，等待期间不占用协程池的协程，消息在最后一次请求完成后发送到下一个节点
+	//等待重试的消息通过`RuleContext.Async`异步完成，超过`Config.AsyncTimeout`则发送到`Failure`链并停止重试
+	MaxRetries int
+	//RetryIntervalMs 首次重试间隔，之后每次翻倍，并在±20%范围内随机抖动，单位毫秒，默认1000
+	RetryIntervalMs int
+	//MaxRetryIntervalMs 最大重试间隔，单位毫秒，默认30000
+	MaxRetryIntervalMs int
+	//RetryDeadlineMs 从第一次请求开始的重试总时长，超过则不再重试，单位毫秒，0不限制
+	RetryDeadlineMs int
+	//RetryOn 需要重试的响应状态码，网络错误总是重试，默认：429、502、503、504
+	RetryOn []int
+	//RetryNonIdempotent 是否重试POST、PATCH等非幂等请求，默认只重试GET、HEAD、OPTIONS、PUT、DELETE请求
+	RetryNonIdempotent bool
 }
 
 // RestApiCallNode 将通过REST API调用<code> GET | POST | PUT | DELETE </ code>到外部REST服务。
@@ -93,6 +151,42 @@ type RestApiCallNode struct {
 	config RestApiCallNodeConfiguration
 	//httpClient http客户端
 	httpClient *http.Client
+	//cache GET请求响应缓存
+	cache *restResponseCache
+	clock clock.Clock
+	//retries 等待重试的请求，节点销毁时停止，为nil表示已经销毁
+	retries map[*restRetry]struct{}
+	lock    sync.Mutex
+}
+
+// restRetry 等待重试的请求
+type restRetry struct {
+	completion types.AsyncCompletion
+	timer      clock.Timer
+}
+
+// restTeller 发送请求结果，没有重试时为RuleContext，重试后为异步完成句柄
+type restTeller interface {
+	TellSuccess(msg types.RuleMsg)
+	TellFailure(msg types.RuleMsg, err error)
+	TellNext(msg types.RuleMsg, relationTypes ...string)
+}
+
+// completionTeller 通过异步完成句柄发送请求结果
+type completionTeller struct {
+	completion types.AsyncCompletion
+}
+
+func (t completionTeller) TellSuccess(msg types.RuleMsg) {
+	t.completion.TellSuccess(msg)
+}
+
+func (t completionTeller) TellFailure(msg types.RuleMsg, err error) {
+	t.completion.TellFailure(msg, err)
+}
+
+func (t completionTeller) TellNext(msg types.RuleMsg, relationTypes ...string) {
+	t.completion.TellNext(msg, relationTypes...)
 }
 
 // Type 组件类型
@@ -100,6 +194,14 @@ func (x *RestApiCallNode) Type() string {
 	return "restApiCall"
 }
 
+// Descriptor 组件描述
+func (x *RestApiCallNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"http", "rest"},
+		Description: "调用外部REST服务",
+	}
+}
+
 func (x *RestApiCallNode) New() types.Node {
 	headers := map[string]string{"Content-Type": "application/json"}
 	config := RestApiCallNodeConfiguration{
@@ -107,6 +209,10 @@ func (x *RestApiCallNode) New() types.Node {
 		MaxParallelRequestsCount: 200,
 		ReadTimeoutMs:            20000,
 		Headers:                  headers,
+		CacheMaxEntries:          1000,
+		RetryIntervalMs:          1000,
+		MaxRetryIntervalMs:       30000,
+		RequestTimeoutKey:        defaultRequestTimeoutKey,
 	}
 	return &RestApiCallNode{config: config}
 }
@@ -117,6 +223,15 @@ func (x *RestApiCallNode) Init(ruleConfig types.Config, configuration types.Conf
 	if err == nil {
 		x.config.RequestMethod = strings.ToUpper(x.config.RequestMethod)
 		x.httpClient = NewHttpClient(x.config)
+		x.clock = ruleConfig.GetClock()
+		x.retries = make(map[*restRetry]struct{})
+		if x.config.RetryOn == nil {
+			x.config.RetryOn = defaultRetryOn
+		}
+		if x.config.CacheTtlMs > 0 && x.config.RequestMethod == http.MethodGet {
+			x.cache = newRestResponseCache(time.Duration(x.config.CacheTtlMs)*time.Millisecond,
+				time.Duration(x.config.CacheStaleMs)*time.Millisecond, x.config.CacheMaxEntries)
+		}
 	}
 	return err
 }
@@ -125,44 +240,324 @@ func (x *RestApiCallNode) Init(ruleConfig types.Config, configuration types.Conf
 func (x *RestApiCallNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
 	metaData := msg.Metadata.Values()
 	endpointUrl := str.SprintfDict(x.config.RestEndpointUrlPattern, metaData)
-	req, err := http.NewRequest(x.config.RequestMethod, endpointUrl, bytes.NewReader([]byte(msg.Data)))
-	if err != nil {
-		ctx.TellFailure(msg, err)
-	}
-	//设置header
+	headers := make(map[string]string, len(x.config.Headers))
 	for key, value := range x.config.Headers {
-		req.Header.Set(str.SprintfDict(key, metaData), str.SprintfDict(value, metaData))
+		headers[str.SprintfDict(key, metaData)] = str.SprintfDict(value, metaData)
 	}
-
-	response, err := x.httpClient.Do(req)
-	defer func() {
-		if response != nil && response.Body != nil {
-			_ = response.Body.Close()
+	timeout := x.requestTimeout(msg.Metadata)
+	var call func() (*restResponse, error)
+	if x.cache != nil {
+		var response *restResponse
+		if response, call = x.cachedCall(ctx, &msg, endpointUrl, headers, timeout); response != nil {
+			x.tell(ctx, msg, response, 0, nil)
+			return nil
+		}
+	} else {
+		data := msg.Data
+		call = func() (*restResponse, error) {
+			return x.call(endpointUrl, headers, data, timeout)
 		}
-	}()
+	}
+	x.callWithRetry(ctx, msg, call, func(teller restTeller, response *restResponse, retries int, err error) {
+		x.tell(teller, msg, response, retries, err)
+	})
+	return nil
+}
 
-	if err != nil {
+// tell 根据请求结果把消息发送到下一个节点
+func (x *RestApiCallNode) tell(ctx restTeller, msg types.RuleMsg, response *restResponse, retries int, err error) {
+	if x.config.MaxRetries > 0 {
+		msg.Metadata.PutValue(retryCount, strconv.Itoa(retries))
+	}
+	if errors.Is(err, ErrRequestTimeout) {
+		msg.Metadata.PutValue(statusCode, "0")
+		msg.Metadata.PutValue(requestError, "timeout")
 		ctx.TellFailure(msg, err)
-	} else if b, err := ioutil.ReadAll(response.Body); err != nil {
+	} else if err != nil {
 		ctx.TellFailure(msg, err)
 	} else {
-		msg.Metadata.PutValue(status, response.Status)
-		msg.Metadata.PutValue(statusCode, strconv.Itoa(response.StatusCode))
-		if response.StatusCode == 200 {
-			msg.Data = string(b)
+		msg.Metadata.PutValue(status, response.status)
+		msg.Metadata.PutValue(statusCode, strconv.Itoa(response.statusCode))
+		if response.statusCode == 200 {
+			dataType, data := response.data(x.config.BinaryResponseBase64)
+			if dataType != "" {
+				msg.DataType = dataType
+			}
+			msg.Data = data
 			ctx.TellSuccess(msg)
 		} else {
-			msg.Metadata.PutValue(errorBody, string(b))
+			msg.Metadata.PutValue(errorBody, string(response.body))
 			ctx.TellNext(msg, types.Failure)
 		}
+	}
+}
 
+// requestTimeout 请求超时时间，元数据中指定了超时时间则使用元数据的值，0表示使用http客户端的超时时间
+func (x *RestApiCallNode) requestTimeout(metadata types.Metadata) time.Duration {
+	timeout := time.Duration(x.config.RequestTimeoutMs) * time.Millisecond
+	if x.config.RequestTimeoutKey != "" {
+		if v := metadata.GetValue(x.config.RequestTimeoutKey); v != nil {
+			if ms, err := strconv.Atoi(str.ToString(v)); err == nil && ms > 0 {
+				timeout = time.Duration(ms) * time.Millisecond
+			}
+		}
 	}
+	return timeout
+}
 
-	return nil
+// SideEffect 实现types.SideEffectNode，GET、HEAD和OPTIONS请求没有副作用
+func (x *RestApiCallNode) SideEffect(msg types.RuleMsg) (types.DryRunAction, bool) {
+	switch x.config.RequestMethod {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return types.DryRunAction{}, false
+	}
+	metaData := msg.Metadata.Values()
+	headers := make(map[string]interface{}, len(x.config.Headers))
+	for key, value := range x.config.Headers {
+		headers[str.SprintfDict(key, metaData)] = str.SprintfDict(value, metaData)
+	}
+	return types.DryRunAction{
+		Operation: x.config.RequestMethod,
+		Target:    str.SprintfDict(x.config.RestEndpointUrlPattern, metaData),
+		Params:    headers,
+		Data:      msg.Data,
+	}, true
+}
+
+// cachedCall 优先使用缓存的响应，缓存过期但在stale时间窗口内，返回旧值并在后台刷新
+// 没有命中缓存返回执行请求的函数，相同key并发的请求只执行一次，成功的响应保存到缓存
+func (x *RestApiCallNode) cachedCall(ctx types.RuleContext, msg *types.RuleMsg, endpointUrl string, headers map[string]string, timeout time.Duration) (*restResponse, func() (*restResponse, error)) {
+	key := restCacheKey(endpointUrl, headers)
+	response, stale, refresh := x.cache.get(key, x.clock.Now())
+	if response != nil {
+		if refresh {
+			data := msg.Data
+			go func() {
+				if _, err := x.fetch(key, endpointUrl, headers, data, timeout); err != nil {
+					x.cache.refreshDone(key)
+					ctx.Config().Logger.Printf("restApiCall refresh cache url=%s err=%s", endpointUrl, err)
+				}
+			}()
+		}
+		if stale {
+			msg.Metadata.PutValue(cacheStatus, "stale")
+		} else {
+			msg.Metadata.PutValue(cacheStatus, "hit")
+		}
+		return response, nil
+	}
+	msg.Metadata.PutValue(cacheStatus, "miss")
+	data := msg.Data
+	return nil, func() (*restResponse, error) {
+		return x.cache.do(key, func() (*restResponse, error) {
+			return x.fetch(key, endpointUrl, headers, data, timeout)
+		})
+	}
+}
+
+// fetch 执行请求，如果响应成功则保存到缓存
+func (x *RestApiCallNode) fetch(key, endpointUrl string, headers map[string]string, data string, timeout time.Duration) (*restResponse, error) {
+	response, err := x.call(endpointUrl, headers, data, timeout)
+	if err == nil && response.statusCode == 200 {
+		x.cache.put(key, response, x.clock.Now())
+	} else if err == nil {
+		x.cache.refreshDone(key)
+	}
+	return response, err
 }
 
-// Destroy 销毁
+// callWithRetry 执行请求，网络错误或者响应状态码在RetryOn中则按指数退避重试，完成后调用done
+// 第一次请求在当前协程执行，重试通过时钟的定时器调度，等待期间不占用协程池的协程，
+// 第一次重试前通过ruleCtx.Async转为异步完成，超过最大重试次数、重试总时长或者ctx取消后返回最后一次请求的结果，
+// 异步完成超时或者节点销毁则停止重试
+func (x *RestApiCallNode) callWithRetry(ruleCtx types.RuleContext, msg types.RuleMsg, call func() (*restResponse, error), done func(teller restTeller, response *restResponse, retries int, err error)) {
+	response, err := call()
+	if x.config.MaxRetries <= 0 || !x.idempotent() {
+		done(ruleCtx, response, 0, err)
+		return
+	}
+	ctx := ruleCtx.GetContext()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var deadline time.Time
+	if x.config.RetryDeadlineMs > 0 {
+		deadline = x.clock.Now().Add(time.Duration(x.config.RetryDeadlineMs) * time.Millisecond)
+	}
+	var pending *restRetry
+	var teller restTeller = ruleCtx
+	var retry func(retries int, response *restResponse, err error)
+	retry = func(retries int, response *restResponse, err error) {
+		if retries >= x.config.MaxRetries || !x.retryable(response, err) || ctx.Err() != nil {
+			done(teller, response, retries, err)
+			return
+		}
+		wait := x.retryBackoff(retries + 1)
+		if !deadline.IsZero() && x.clock.Now().Add(wait).After(deadline) {
+			done(teller, response, retries, err)
+			return
+		}
+		if pending == nil {
+			pending = &restRetry{completion: ruleCtx.Async(msg, 0)}
+			teller = completionTeller{completion: pending.completion}
+		}
+		x.schedule(pending, wait, func() {
+			if pending.completion.Done() {
+				//异步完成已经超时
+				return
+			}
+			if ctx.Err() != nil {
+				done(teller, response, retries, err)
+				return
+			}
+			next, nextErr := call()
+			retry(retries+1, next, nextErr)
+		})
+	}
+	retry(0, response, err)
+}
+
+// schedule 等待wait后执行重试，节点已经销毁则放弃消息
+func (x *RestApiCallNode) schedule(pending *restRetry, wait time.Duration, f func()) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.retries == nil {
+		pending.completion.Cancel()
+		return
+	}
+	x.retries[pending] = struct{}{}
+	pending.timer = x.clock.AfterFunc(wait, func() {
+		x.lock.Lock()
+		_, ok := x.retries[pending]
+		delete(x.retries, pending)
+		x.lock.Unlock()
+		if ok {
+			f()
+		}
+	})
+}
+
+// idempotent 请求方法是否可以重试
+func (x *RestApiCallNode) idempotent() bool {
+	if x.config.RetryNonIdempotent {
+		return true
+	}
+	switch x.config.RequestMethod {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// retryable 网络错误或者响应状态码在RetryOn中则重试
+func (x *RestApiCallNode) retryable(response *restResponse, err error) bool {
+	if err != nil {
+		var urlErr *url.Error
+		return errors.As(err, &urlErr)
+	}
+	for _, code := range x.config.RetryOn {
+		if response.statusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryBackoff 第attempts次重试前的等待时间
+func (x *RestApiCallNode) retryBackoff(attempts int) time.Duration {
+	interval := time.Duration(x.config.RetryIntervalMs) * time.Millisecond
+	maxInterval := time.Duration(x.config.MaxRetryIntervalMs) * time.Millisecond
+	for i := 1; i < attempts && interval < maxInterval; i++ {
+		interval *= 2
+	}
+	if maxInterval > 0 && interval > maxInterval {
+		interval = maxInterval
+	}
+	return time.Duration(float64(interval) * (0.8 + 0.4*rand.Float64()))
+}
+
+// call 执行http请求，配置了AdaptiveConcurrency则先获取目标主机的并发许可
+func (x *RestApiCallNode) call(endpointUrl string, headers map[string]string, data string, timeout time.Duration) (*restResponse, error) {
+	if x.config.AdaptiveConcurrency == nil {
+		return x.request(endpointUrl, headers, data, timeout)
+	}
+	target, err := url.Parse(endpointUrl)
+	if err != nil || target.Host == "" {
+		return x.request(endpointUrl, headers, data, timeout)
+	}
+	done, err := restLimiters.Get(target.Host, *x.config.AdaptiveConcurrency, x.clock).Acquire(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	response, err := x.request(endpointUrl, headers, data, timeout)
+	done(err != nil || response.statusCode == http.StatusTooManyRequests || response.statusCode == http.StatusServiceUnavailable)
+	return response, err
+}
+
+// request 执行http请求，timeout大于0则请求超过该时间取消，超时返回ErrRequestTimeout
+func (x *RestApiCallNode) request(endpointUrl string, headers map[string]string, data string, timeout time.Duration) (*restResponse, error) {
+	reqCtx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(reqCtx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(reqCtx, x.config.RequestMethod, endpointUrl, bytes.NewReader([]byte(data)))
+	if err != nil {
+		return nil, err
+	}
+	//设置header
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	response, err := x.httpClient.Do(req)
+	if err != nil {
+		return nil, timeoutError(err)
+	}
+	defer response.Body.Close()
+	b, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, timeoutError(err)
+	}
+	return &restResponse{status: response.Status, statusCode: response.StatusCode, contentType: response.Header.Get("Content-Type"), body: b}, nil
+}
+
+// timeoutError 请求超时则返回包装了ErrRequestTimeout的错误，保留原始错误，网络错误仍然可以重试
+func timeoutError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &restTimeoutError{err: err}
+	}
+	return err
+}
+
+// restTimeoutError 请求超时错误
+type restTimeoutError struct {
+	err error
+}
+
+func (e *restTimeoutError) Error() string {
+	return ErrRequestTimeout.Error() + ": " + e.err.Error()
+}
+
+func (e *restTimeoutError) Is(target error) bool {
+	return target == ErrRequestTimeout
+}
+
+func (e *restTimeoutError) Unwrap() error {
+	return e.err
+}
+
+// Destroy 销毁，停止等待重试的请求并放弃对应的消息
 func (x *RestApiCallNode) Destroy() {
+	x.lock.Lock()
+	retries := x.retries
+	x.retries = nil
+	x.lock.Unlock()
+	for pending := range retries {
+		pending.timer.Stop()
+		pending.completion.Cancel()
+	}
 }
 
 func NewHttpClient(config RestApiCallNodeConfiguration) *http.Client {
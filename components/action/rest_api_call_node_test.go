@@ -17,10 +17,22 @@
 package action
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"github.com/2018yuli/rulego/api/types"
 	"github.com/2018yuli/rulego/test"
 	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/adaptive"
+	"github.com/2018yuli/rulego/utils/clock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestRestApiCallNodeOnMsg(t *testing.T) {
@@ -45,3 +57,445 @@ func TestRestApiCallNodeOnMsg(t *testing.T) {
 	}
 
 }
+
+func TestRestApiCallNodeCache(t *testing.T) {
+	var requests int32
+	var body atomic.Value
+	body.Store("v1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(body.Load().(string) + ":" + r.URL.Path + ":" + r.Header.Get("X-Device")))
+	}))
+	defer server.Close()
+
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	config := types.NewConfig(types.WithClock(vc))
+	node := (&RestApiCallNode{}).New().(*RestApiCallNode)
+	err := node.Init(config, types.Configuration{
+		"restEndpointUrlPattern": server.URL + "/${path}",
+		"requestMethod":          "GET",
+		"headers":                map[string]string{"X-Device": "${device}"},
+		"cacheTtlMs":             1000,
+		"cacheStaleMs":           1000,
+		"cacheMaxEntries":        2,
+	})
+	assert.Nil(t, err)
+
+	var data, status string
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Success, relationType)
+		data = msg.Data
+		status, _ = msg.Metadata.GetValue(cacheStatus).(string)
+	})
+	call := func(path, device string) {
+		metaData := types.NewMetadata()
+		metaData.PutValue("path", path)
+		metaData.PutValue("device", device)
+		_ = node.OnMsg(ctx, ctx.NewMsg("TEST", metaData, ""))
+	}
+
+	call("a", "d1")
+	assert.Equal(t, "v1:/a:d1", data)
+	assert.Equal(t, "miss", status)
+	call("a", "d1")
+	assert.Equal(t, "v1:/a:d1", data)
+	assert.Equal(t, "hit", status)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	//请求头不同，使用不同的缓存
+	call("a", "d2")
+	assert.Equal(t, "v1:/a:d2", data)
+	assert.Equal(t, "miss", status)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	//过期后返回旧值，并在后台刷新
+	body.Store("v2")
+	vc.Advance(1500 * time.Millisecond)
+	call("a", "d1")
+	assert.Equal(t, "v1:/a:d1", data)
+	assert.Equal(t, "stale", status)
+	for i := 0; i < 100 && refreshing(node.cache); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	call("a", "d1")
+	assert.Equal(t, "v2:/a:d1", data)
+	assert.Equal(t, "hit", status)
+
+	//超过stale时间窗口，重新请求
+	vc.Advance(3 * time.Second)
+	call("a", "d1")
+	assert.Equal(t, "v2:/a:d1", data)
+	assert.Equal(t, "miss", status)
+
+	//超过最大缓存数量，淘汰最久未使用的
+	call("b", "d1")
+	call("c", "d1")
+	assert.Equal(t, 2, node.cache.len())
+}
+
+func TestRestApiCallNodeAdaptiveConcurrency(t *testing.T) {
+	var code int32 = http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&code)))
+	}))
+	defer server.Close()
+
+	config := types.NewConfig()
+	node := (&RestApiCallNode{}).New().(*RestApiCallNode)
+	assert.Nil(t, node.Init(config, types.Configuration{
+		"restEndpointUrlPattern": server.URL,
+		"adaptiveConcurrency":    map[string]interface{}{"initialLimit": 10, "minLimit": 1, "maxLimit": 10},
+	}))
+	var relation string
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+	})
+	_ = node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), ""))
+	assert.Equal(t, types.Success, relation)
+	//第一次请求时创建目标主机的限流器
+	target, _ := url.Parse(server.URL)
+	limiter := restLimiters.Get(target.Host, adaptive.Config{}, nil)
+	assert.Equal(t, 10, limiter.Limit())
+	assert.Equal(t, 0, limiter.Inflight())
+
+	//下游过载减少并发上限
+	atomic.StoreInt32(&code, http.StatusServiceUnavailable)
+	_ = node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), ""))
+	assert.Equal(t, types.Failure, relation)
+	assert.Equal(t, 9, limiter.Limit())
+
+	//达到并发上限
+	var dones []func(bool)
+	for i := 0; i < 9; i++ {
+		done, err := limiter.Acquire(context.Background())
+		assert.Nil(t, err)
+		dones = append(dones, done)
+	}
+	_, err := node.call(server.URL, nil, "", 0)
+	assert.Equal(t, adaptive.ErrLimitExceeded, err)
+	for _, done := range dones {
+		done(false)
+	}
+}
+
+func TestRestApiCallNodeRetry(t *testing.T) {
+	var requests int32
+	var failures int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= atomic.LoadInt32(&failures) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	config := types.NewConfig()
+	newNode := func(configuration types.Configuration) *RestApiCallNode {
+		configuration["restEndpointUrlPattern"] = server.URL
+		node := (&RestApiCallNode{}).New().(*RestApiCallNode)
+		assert.Nil(t, node.Init(config, configuration))
+		return node
+	}
+	var relation, retries string
+	//重试在定时器中执行，等待消息发送到下一个节点
+	call := func(node *RestApiCallNode, failed int32) {
+		atomic.StoreInt32(&requests, 0)
+		atomic.StoreInt32(&failures, failed)
+		done := make(chan struct{})
+		ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+			relation = relationType
+			retries, _ = msg.Metadata.GetValue(retryCount).(string)
+			close(done)
+		})
+		_ = node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), ""))
+		<-done
+	}
+
+	//重试成功
+	node := newNode(types.Configuration{"requestMethod": "GET", "maxRetries": 3, "retryIntervalMs": 1})
+	call(node, 2)
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, "2", retries)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	//超过最大重试次数
+	call(node, 10)
+	assert.Equal(t, types.Failure, relation)
+	assert.Equal(t, "3", retries)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+
+	//状态码不在RetryOn中不重试
+	node = newNode(types.Configuration{"requestMethod": "GET", "maxRetries": 3, "retryIntervalMs": 1, "retryOn": []int{502}})
+	call(node, 1)
+	assert.Equal(t, types.Failure, relation)
+	assert.Equal(t, "0", retries)
+
+	//非幂等请求默认不重试
+	node = newNode(types.Configuration{"requestMethod": "POST", "maxRetries": 3, "retryIntervalMs": 1})
+	call(node, 1)
+	assert.Equal(t, types.Failure, relation)
+	assert.Equal(t, "0", retries)
+	node = newNode(types.Configuration{"requestMethod": "POST", "maxRetries": 3, "retryIntervalMs": 1, "retryNonIdempotent": true})
+	call(node, 1)
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, "1", retries)
+
+	//超过重试总时长
+	node = newNode(types.Configuration{"requestMethod": "GET", "maxRetries": 10, "retryIntervalMs": 20, "retryDeadlineMs": 50})
+	call(node, 10)
+	assert.Equal(t, types.Failure, relation)
+	assert.True(t, atomic.LoadInt32(&requests) < 4)
+
+	//网络错误重试
+	node = newNode(types.Configuration{"requestMethod": "GET", "maxRetries": 2, "retryIntervalMs": 1})
+	node.config.RestEndpointUrlPattern = "http://127.0.0.1:1"
+	call(node, 0)
+	assert.Equal(t, types.Failure, relation)
+	assert.Equal(t, "2", retries)
+
+	//指数退避
+	node.config.RetryIntervalMs = 100
+	node.config.MaxRetryIntervalMs = 300
+	for attempts, expected := range map[int]time.Duration{1: 100, 2: 200, 3: 300, 5: 300} {
+		backoff := node.retryBackoff(attempts)
+		assert.True(t, backoff >= expected*time.Millisecond*8/10 && backoff <= expected*time.Millisecond*12/10)
+	}
+}
+
+func TestRestApiCallNodeRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d, err := time.ParseDuration(r.URL.Query().Get("sleep")); err == nil {
+			time.Sleep(d)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	config := types.NewConfig()
+	node := (&RestApiCallNode{}).New().(*RestApiCallNode)
+	assert.Nil(t, node.Init(config, types.Configuration{
+		"restEndpointUrlPattern": server.URL + "?sleep=${sleep}",
+		"requestMethod":          "GET",
+		"requestTimeoutMs":       200,
+	}))
+	var relation string
+	var err error
+	var metadata types.Metadata
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		metadata = msg.Metadata
+	})
+	call := func(sleep string, timeoutMs string) {
+		metaData := types.NewMetadata()
+		metaData.PutValue("sleep", sleep)
+		if timeoutMs != "" {
+			metaData.PutValue(defaultRequestTimeoutKey, timeoutMs)
+		}
+		relation = ""
+		_ = node.OnMsg(ctx, ctx.NewMsg("TEST", metaData, ""))
+	}
+
+	call("10ms", "")
+	assert.Equal(t, types.Success, relation)
+
+	//超时
+	start := time.Now()
+	call("1s", "")
+	assert.Equal(t, types.Failure, relation)
+	assert.True(t, time.Since(start) < 800*time.Millisecond)
+	assert.Equal(t, "0", metadata.GetValue(statusCode))
+	assert.Equal(t, "timeout", metadata.GetValue(requestError))
+	_, err = node.request(server.URL+"?sleep=1s", nil, "", 50*time.Millisecond)
+	assert.True(t, errors.Is(err, ErrRequestTimeout))
+	assert.True(t, strings.Contains(err.Error(), "timeout"))
+
+	//元数据覆盖超时时间
+	start = time.Now()
+	call("150ms", "50")
+	assert.Equal(t, types.Failure, relation)
+	assert.Equal(t, "timeout", metadata.GetValue(requestError))
+	assert.True(t, time.Since(start) < 140*time.Millisecond)
+	call("150ms", "1000")
+	assert.Equal(t, types.Success, relation)
+}
+
+func TestRestApiCallNodeRetryNotBlocking(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	config := types.NewConfig(types.WithClock(vc))
+	node := (&RestApiCallNode{}).New().(*RestApiCallNode)
+	assert.Nil(t, node.Init(config, types.Configuration{
+		"restEndpointUrlPattern": server.URL,
+		"requestMethod":          "GET",
+		"maxRetries":             1,
+		"retryIntervalMs":        10000,
+	}))
+	relations := make(chan string, 1)
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relations <- relationType
+	})
+	//等待重试时OnMsg已经返回，不占用协程池的协程
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "")))
+	assert.Equal(t, 0, len(relations))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	vc.Advance(15 * time.Second)
+	select {
+	case relation := <-relations:
+		assert.Equal(t, types.Success, relation)
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry not executed")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestRestApiCallNodeRetryAsync(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	config := types.NewConfig(types.WithClock(vc), types.WithAsyncTimeout(5*time.Second))
+	newNode := func() *RestApiCallNode {
+		node := (&RestApiCallNode{}).New().(*RestApiCallNode)
+		assert.Nil(t, node.Init(config, types.Configuration{
+			"restEndpointUrlPattern": server.URL,
+			"requestMethod":          "GET",
+			"maxRetries":             3,
+			"retryIntervalMs":        10000,
+		}))
+		return node
+	}
+	relations := make(chan string, 2)
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relations <- relationType
+	})
+
+	//等待重试超过异步完成超时时间，发送到Failure链并停止重试
+	atomic.StoreInt32(&requests, 0)
+	node := newNode()
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "")))
+	//异步完成超时定时器和重试定时器
+	assert.Equal(t, 2, vc.Pending())
+	vc.Advance(6 * time.Second)
+	select {
+	case relation := <-relations:
+		assert.Equal(t, types.Failure, relation)
+	case <-time.After(5 * time.Second):
+		t.Fatal("async timeout not triggered")
+	}
+	vc.Advance(30 * time.Second)
+	assert.Equal(t, 0, vc.Pending())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, 0, len(relations))
+
+	//节点销毁后停止等待中的重试，不再发送消息
+	atomic.StoreInt32(&requests, 0)
+	node = newNode()
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "")))
+	assert.Equal(t, 2, vc.Pending())
+	node.Destroy()
+	assert.Equal(t, 0, vc.Pending())
+	vc.Advance(60 * time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, 0, len(relations))
+}
+
+func refreshing(cache *restResponseCache) bool {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	for _, entry := range cache.entries {
+		if entry.refreshing {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRestResponseCacheDo(t *testing.T) {
+	cache := newRestResponseCache(time.Second, 0, 10)
+	var calls int32
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := cache.do("key", func() (*restResponse, error) {
+				atomic.AddInt32(&calls, 1)
+				<-start
+				return &restResponse{statusCode: 200, body: []byte("ok")}, nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, "ok", string(response.body))
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(start)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRestResponseData(t *testing.T) {
+	dataType, data := (&restResponse{contentType: "application/json", body: []byte(`123`)}).data(false)
+	assert.Equal(t, types.JSON, dataType)
+	assert.Equal(t, "123", data)
+	dataType, _ = (&restResponse{contentType: "text/plain", body: []byte(`{"a":1}`)}).data(false)
+	assert.Equal(t, types.JSON, dataType)
+	dataType, _ = (&restResponse{contentType: "application/json", body: []byte(`ok`)}).data(false)
+	assert.Equal(t, types.TEXT, dataType)
+	dataType, data = (&restResponse{contentType: "application/octet-stream", body: []byte{0xff, 0xfe}}).data(true)
+	assert.Equal(t, types.BINARY, dataType)
+	assert.Equal(t, "//4=", data)
+	//默认原样返回
+	dataType, data = (&restResponse{contentType: "application/octet-stream", body: []byte{0xff, 0xfe}}).data(false)
+	assert.Equal(t, types.DataType(""), dataType)
+	assert.Equal(t, string([]byte{0xff, 0xfe}), data)
+}
+
+func TestRestApiCallNodeBinaryResponse(t *testing.T) {
+	body := []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	config := types.NewConfig()
+	for _, binaryBase64 := range []bool{false, true} {
+		node := (&RestApiCallNode{}).New().(*RestApiCallNode)
+		assert.Nil(t, node.Init(config, types.Configuration{
+			"restEndpointUrlPattern": server.URL,
+			"requestMethod":          "GET",
+			"binaryResponseBase64":   binaryBase64,
+		}))
+		var result types.RuleMsg
+		ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+			assert.Equal(t, types.Success, relationType)
+			result = msg
+		})
+		_ = node.OnMsg(ctx, types.NewMsg(0, "TEST", types.TEXT, types.NewMetadata(), ""))
+		if binaryBase64 {
+			assert.Equal(t, types.BINARY, result.DataType)
+			assert.Equal(t, base64.StdEncoding.EncodeToString(body), result.Data)
+		} else {
+			//原样保存响应内容，不修改数据类型
+			assert.Equal(t, types.TEXT, result.DataType)
+			assert.Equal(t, string(body), result.Data)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"container/list"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/2018yuli/rulego/api/types"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// restResponse http响应
+type restResponse struct {
+	status      string
+	statusCode  int
+	contentType string
+	body        []byte
+}
+
+// data 响应内容对应的消息数据类型和数据，JSON响应为JSON，UTF-8文本为TEXT
+// 非UTF-8内容：binaryBase64为true则按base64编码为BINARY，否则原样返回，数据类型为空表示不修改消息的数据类型
+func (r *restResponse) data(binaryBase64 bool) (types.DataType, string) {
+	dataType := types.DetectDataType(r.body)
+	if strings.Contains(r.contentType, "json") && json.Valid(r.body) {
+		dataType = types.JSON
+	}
+	if dataType == types.BINARY {
+		if !binaryBase64 {
+			return "", string(r.body)
+		}
+		return dataType, base64.StdEncoding.EncodeToString(r.body)
+	}
+	return dataType, string(r.body)
+}
+
+// restCacheEntry 缓存项
+type restCacheEntry struct {
+	key      string
+	response *restResponse
+	//expireAt 过期时间，过期后在stale时间窗口内仍然可以返回旧值，同时后台刷新
+	expireAt time.Time
+	//staleAt 超过该时间缓存不可用
+	staleAt    time.Time
+	refreshing bool
+	element    *list.Element
+}
+
+// restCall 正在进行的请求，相同key的并发请求共享结果
+type restCall struct {
+	wg       sync.WaitGroup
+	response *restResponse
+	err      error
+}
+
+// restResponseCache REST响应缓存，LRU淘汰，支持stale-while-revalidate，并合并相同key的并发请求
+type restResponseCache struct {
+	ttl        time.Duration
+	stale      time.Duration
+	maxEntries int
+	entries    map[string]*restCacheEntry
+	lru        *list.List
+	calls      map[string]*restCall
+	lock       sync.Mutex
+}
+
+func newRestResponseCache(ttl, stale time.Duration, maxEntries int) *restResponseCache {
+	return &restResponseCache{
+		ttl:        ttl,
+		stale:      stale,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*restCacheEntry),
+		lru:        list.New(),
+		calls:      make(map[string]*restCall),
+	}
+}
+
+// restCacheKey 缓存key：渲染后的URL和请求头
+func restCacheKey(url string, headers map[string]string) string {
+	var keys []string
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(url)
+	for _, k := range keys {
+		sb.WriteString("\n")
+		sb.WriteString(k)
+		sb.WriteString(":")
+		sb.WriteString(headers[k])
+	}
+	return sb.String()
+}
+
+// get 获取缓存，返回缓存的响应、是否已经过期，以及调用方是否需要负责后台刷新
+func (c *restResponseCache) get(key string, now time.Time) (response *restResponse, stale bool, refresh bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, false
+	}
+	if !now.Before(entry.staleAt) {
+		c.remove(entry)
+		return nil, false, false
+	}
+	c.lru.MoveToFront(entry.element)
+	if now.Before(entry.expireAt) {
+		return entry.response, false, false
+	}
+	if !entry.refreshing {
+		entry.refreshing = true
+		refresh = true
+	}
+	return entry.response, true, refresh
+}
+
+// put 保存缓存
+func (c *restResponseCache) put(key string, response *restResponse, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if entry, ok := c.entries[key]; ok {
+		c.remove(entry)
+	}
+	entry := &restCacheEntry{key: key, response: response, expireAt: now.Add(c.ttl), staleAt: now.Add(c.ttl + c.stale)}
+	entry.element = c.lru.PushFront(entry)
+	c.entries[key] = entry
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back().Value.(*restCacheEntry))
+	}
+}
+
+// refreshDone 后台刷新失败，允许下一次请求重新刷新
+func (c *restResponseCache) refreshDone(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if entry, ok := c.entries[key]; ok {
+		entry.refreshing = false
+	}
+}
+
+// do 执行请求，相同key的并发请求只执行一次
+func (c *restResponseCache) do(key string, fn func() (*restResponse, error)) (*restResponse, error) {
+	c.lock.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.lock.Unlock()
+		call.wg.Wait()
+		return call.response, call.err
+	}
+	call := &restCall{}
+	call.wg.Add(1)
+	c.calls[key] = call
+	c.lock.Unlock()
+
+	call.response, call.err = fn()
+	call.wg.Done()
+
+	c.lock.Lock()
+	delete(c.calls, key)
+	c.lock.Unlock()
+	return call.response, call.err
+}
+
+// len 缓存项数量
+func (c *restResponseCache) len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// remove 调用方需要持有锁
+func (c *restResponseCache) remove(entry *restCacheEntry) {
+	c.lru.Remove(entry.element)
+	delete(c.entries, entry.key)
+}
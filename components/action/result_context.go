@@ -0,0 +1,80 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"sync"
+	"time"
+)
+
+// ErrUplinkFailure 节点把消息发送到`Failure`链但没有提供错误信息
+var ErrUplinkFailure = errors.New("uplink failure")
+
+// resultContext 调用其他节点时使用的上下文，捕获节点的处理结果，done只调用一次
+// 节点通过`Async`异步完成时，超时作为失败处理
+type resultContext struct {
+	types.RuleContext
+	once sync.Once
+	done func(err error)
+}
+
+func (c *resultContext) finish(err error) {
+	c.once.Do(func() {
+		c.done(err)
+	})
+}
+
+func (c *resultContext) TellSuccess(msg types.RuleMsg) {
+	c.finish(nil)
+}
+
+func (c *resultContext) TellFailure(msg types.RuleMsg, err error) {
+	if err == nil {
+		err = ErrUplinkFailure
+	}
+	c.finish(err)
+}
+
+func (c *resultContext) TellNext(msg types.RuleMsg, relationTypes ...string) {
+	for _, relationType := range relationTypes {
+		if relationType == types.Failure {
+			c.finish(ErrUplinkFailure)
+			return
+		}
+	}
+	c.finish(nil)
+}
+
+func (c *resultContext) Async(msg types.RuleMsg, timeout time.Duration) types.AsyncCompletion {
+	return types.NewAsyncCompletion(c, msg, timeout, nil)
+}
+
+// callNode 调用节点处理消息，done在节点返回结果、OnMsg返回错误或者panic后调用一次
+func callNode(ctx types.RuleContext, node types.Node, msg types.RuleMsg, done func(err error)) {
+	nodeCtx := &resultContext{RuleContext: ctx, done: done}
+	defer func() {
+		if e := recover(); e != nil {
+			nodeCtx.finish(fmt.Errorf("node %s panic: %v", node.Type(), e))
+		}
+	}()
+	if err := node.OnMsg(nodeCtx, msg); err != nil {
+		nodeCtx.finish(err)
+	}
+}
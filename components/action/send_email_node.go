@@ -156,7 +156,7 @@ type SendEmailConfiguration struct {
 	//Username 用户名
 	Username string
 	//Password 密码
-	Password string
+	Password string `sensitive:"true"`
 	//EnableTls 是否是使用tls方式
 	EnableTls bool
 	//Email 邮件内容配置
@@ -177,6 +177,14 @@ func (x *SendEmailNode) Type() string {
 	return "sendEmail"
 }
 
+// Descriptor 组件描述
+func (x *SendEmailNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"email", "smtp", "notification"},
+		Description: "通过SMTP服务器发送邮件",
+	}
+}
+
 func (x *SendEmailNode) New() types.Node {
 	return &SendEmailNode{}
 }
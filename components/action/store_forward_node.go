@@ -0,0 +1,472 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	//StoreForwardRelationBuffered 上行失败，消息已经缓存的关系类型
+	StoreForwardRelationBuffered = "Buffered"
+	//storeForwardSizeKey 缓存消息数元数据key
+	storeForwardSizeKey = "bufferSize"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "storeForward",
+//	       "name": "断网缓存上报",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "uplink": "mqttClient",
+//	         "uplinkConfig": {"server": "127.0.0.1:1883", "topic": "/device/msg"},
+//	         "path": "./data/uplink.jsonl",
+//	         "maxSize": 10000,
+//	         "ttlMs": 86400000,
+//	         "retryIntervalMs": 5000
+//	       }
+//	     }
+func init() {
+	Registry.Add(&StoreForwardNode{})
+}
+
+// StoreForwardNodeConfiguration 节点配置
+type StoreForwardNodeConfiguration struct {
+	//Uplink 上行节点类型，例如：mqttClient、restApiCall，通过`Config.ComponentsRegistry`创建
+	Uplink string
+	//UplinkConfig 上行节点配置
+	UplinkConfig types.Configuration
+	//Path 缓存文件路径，每行一条json格式的消息
+	Path string
+	//MaxSize 最多缓存消息数，超过则丢弃最早的消息，默认10000
+	MaxSize int
+	//TtlMs 缓存消息有效期，单位毫秒，过期的消息不再回放，0表示永不过期，默认86400000
+	TtlMs int64
+	//RetryIntervalMs 回放失败后再次尝试的间隔，单位毫秒，默认5000
+	RetryIntervalMs int
+}
+
+// StoreForwardNode 边缘存储转发节点，通过上行节点(mqttClient、restApiCall等)发送消息
+// 上行节点返回失败时把消息按顺序缓存到磁盘，之后每隔RetryIntervalMs按照缓存顺序回放，连接恢复后依次发送
+// 存在缓存消息时，新消息直接追加到缓存，保证上行顺序
+// 上行成功发送到`Success`链，缓存后发送到`Buffered`链，元数据bufferSize为缓存消息数，缓存写入失败发送到`Failure`链
+// 回放使用最近一次处理消息的上下文，重启后收到第一条消息时开始回放缓存文件中的消息
+// 回放成功的消息不再发送到下一个节点，回放是至少一次语义，进程在回放过程中退出可能重复发送
+type StoreForwardNode struct {
+	config StoreForwardNodeConfiguration
+	uplink types.Node
+	buffer *storeForwardBuffer
+	clock  clock.Clock
+	//timeout 等待上行节点返回结果的超时时间
+	timeout time.Duration
+	//lastCtx 最近一次处理消息的上下文，回放时使用
+	lastCtx types.RuleContext
+	timer   clock.Timer
+	//replaying 是否正在回放
+	replaying bool
+	stopped   bool
+	lock      sync.Mutex
+}
+
+// Type 组件类型
+func (x *StoreForwardNode) Type() string {
+	return "storeForward"
+}
+
+// Descriptor 组件描述
+func (x *StoreForwardNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"buffer", "offline", "edge", "iot"},
+		Description: "上行失败时把消息缓存到磁盘，连接恢复后按顺序回放",
+	}
+}
+
+func (x *StoreForwardNode) New() types.Node {
+	return &StoreForwardNode{config: StoreForwardNodeConfiguration{
+		MaxSize:         10000,
+		TtlMs:           86400000,
+		RetryIntervalMs: 5000,
+	}}
+}
+
+// Init 初始化
+func (x *StoreForwardNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Uplink == "" {
+		return errors.New("uplink can not empty")
+	}
+	if x.config.Path == "" {
+		return errors.New("path can not empty")
+	}
+	if x.config.MaxSize <= 0 {
+		x.config.MaxSize = 10000
+	}
+	if x.config.RetryIntervalMs <= 0 {
+		x.config.RetryIntervalMs = 5000
+	}
+	if ruleConfig.ComponentsRegistry == nil {
+		return errors.New("components registry not configured")
+	}
+	if x.uplink, err = ruleConfig.ComponentsRegistry.NewNode(x.config.Uplink); err != nil {
+		return err
+	}
+	if x.config.UplinkConfig == nil {
+		x.config.UplinkConfig = make(types.Configuration)
+	}
+	if err = x.uplink.Init(ruleConfig, x.config.UplinkConfig); err != nil {
+		return err
+	}
+	x.clock = ruleConfig.GetClock()
+	x.timeout = ruleConfig.AsyncTimeout
+	if x.timeout <= 0 {
+		x.timeout = types.DefaultAsyncTimeout
+	}
+	if x.buffer, err = openStoreForwardBuffer(x.config.Path, x.config.MaxSize); err != nil {
+		x.uplink.Destroy()
+		return err
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *StoreForwardNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	x.lock.Lock()
+	x.lastCtx = ctx
+	x.lock.Unlock()
+	x.buffer.removeExpired(x.expireBefore())
+	if x.buffer.len() > 0 {
+		//有未回放的消息，追加到缓存保证顺序
+		return x.store(ctx, msg)
+	}
+	x.send(ctx, msg, func(err error) {
+		if err == nil {
+			ctx.TellSuccess(msg)
+		} else {
+			_ = x.store(ctx, msg)
+		}
+	})
+	return nil
+}
+
+// Destroy 销毁
+func (x *StoreForwardNode) Destroy() {
+	x.lock.Lock()
+	x.stopped = true
+	if x.timer != nil {
+		x.timer.Stop()
+	}
+	x.lock.Unlock()
+	if x.uplink != nil {
+		x.uplink.Destroy()
+	}
+	if x.buffer != nil {
+		_ = x.buffer.close()
+	}
+}
+
+// BufferSize 缓存消息数
+func (x *StoreForwardNode) BufferSize() int {
+	return x.buffer.len()
+}
+
+// send 通过上行节点发送消息，done在上行节点返回结果后调用一次
+func (x *StoreForwardNode) send(ctx types.RuleContext, msg types.RuleMsg, done func(err error)) {
+	callNode(ctx, x.uplink, msg, done)
+}
+
+// store 缓存消息并调度回放
+func (x *StoreForwardNode) store(ctx types.RuleContext, msg types.RuleMsg) error {
+	if err := x.buffer.push(newStoredMsg(msg, x.clock.Now())); err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	msg.Metadata.PutValue(storeForwardSizeKey, strconv.Itoa(x.buffer.len()))
+	ctx.TellNext(msg, StoreForwardRelationBuffered)
+	x.scheduleReplay()
+	return nil
+}
+
+// scheduleReplay 没有正在回放则在RetryIntervalMs后回放
+func (x *StoreForwardNode) scheduleReplay() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.replaying || x.stopped {
+		return
+	}
+	x.replaying = true
+	x.timer = x.clock.AfterFunc(time.Duration(x.config.RetryIntervalMs)*time.Millisecond, x.replay)
+}
+
+// replay 按顺序回放缓存消息，直到缓存为空或者发送失败
+func (x *StoreForwardNode) replay() {
+	x.lock.Lock()
+	ctx := x.lastCtx
+	x.lock.Unlock()
+	for {
+		x.buffer.removeExpired(x.expireBefore())
+		item, ok := x.buffer.peek()
+		if !ok || ctx == nil {
+			break
+		}
+		if err := x.sendAndWait(ctx, item.toMsg()); err != nil {
+			break
+		}
+		if err := x.buffer.pop(); err != nil {
+			break
+		}
+	}
+	x.lock.Lock()
+	x.replaying = false
+	x.lock.Unlock()
+	if x.buffer.len() > 0 {
+		x.scheduleReplay()
+	}
+}
+
+// sendAndWait 通过上行节点发送消息并等待结果，超时返回`types.ErrAsyncTimeout`
+func (x *StoreForwardNode) sendAndWait(ctx types.RuleContext, msg types.RuleMsg) error {
+	result := make(chan error, 1)
+	timer := x.clock.AfterFunc(x.timeout, func() {
+		select {
+		case result <- types.ErrAsyncTimeout:
+		default:
+		}
+	})
+	defer timer.Stop()
+	x.send(ctx, msg, func(err error) {
+		select {
+		case result <- err:
+		default:
+		}
+	})
+	return <-result
+}
+
+// expireBefore 缓存时间早于该时间的消息已经过期
+func (x *StoreForwardNode) expireBefore() int64 {
+	if x.config.TtlMs <= 0 {
+		return 0
+	}
+	return x.clock.Now().UnixMilli() - x.config.TtlMs
+}
+
+// storedMsg 缓存的消息
+type storedMsg struct {
+	Id       string                 `json:"id"`
+	Ts       int64                  `json:"ts"`
+	Type     string                 `json:"type"`
+	DataType types.DataType         `json:"dataType"`
+	Data     string                 `json:"data"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	//BufferedAt 缓存时间，单位毫秒
+	BufferedAt int64 `json:"bufferedAt"`
+}
+
+func newStoredMsg(msg types.RuleMsg, now time.Time) storedMsg {
+	return storedMsg{Id: msg.Id, Ts: msg.Ts, Type: msg.Type, DataType: msg.DataType, Data: msg.Data,
+		Metadata: msg.Metadata.Values(), BufferedAt: now.UnixMilli()}
+}
+
+func (m storedMsg) toMsg() types.RuleMsg {
+	msg := types.NewMsg(m.Ts, m.Type, m.DataType, types.BuildMetadata(m.Metadata), m.Data)
+	msg.Id = m.Id
+	return msg
+}
+
+// storeForwardBuffer 磁盘缓存，新消息追加到文件末尾
+// 从头部移除的消息先记录在stale，超过剩余消息数或者缓存为空时重写文件
+type storeForwardBuffer struct {
+	path    string
+	maxSize int
+	items   []storedMsg
+	//stale 文件头部已经移除的行数
+	stale int
+	file  *os.File
+	lock  sync.Mutex
+}
+
+// openStoreForwardBuffer 打开缓存文件，加载未回放的消息，忽略无法解析的行
+func openStoreForwardBuffer(path string, maxSize int) (*storeForwardBuffer, error) {
+	b := &storeForwardBuffer{path: path, maxSize: maxSize}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			var item storedMsg
+			if json.Unmarshal(scanner.Bytes(), &item) == nil {
+				b.items = append(b.items, item)
+			}
+		}
+		_ = f.Close()
+		if len(b.items) > maxSize {
+			b.items = b.items[len(b.items)-maxSize:]
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := b.rewrite(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *storeForwardBuffer) len() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.items)
+}
+
+// push 追加消息，缓存满则丢弃最早的消息
+func (b *storeForwardBuffer) push(item storedMsg) error {
+	line, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.file == nil {
+		return os.ErrClosed
+	}
+	if _, err = b.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	b.items = append(b.items, item)
+	if len(b.items) > b.maxSize {
+		b.removeHead(len(b.items) - b.maxSize)
+		return b.compact()
+	}
+	return nil
+}
+
+// peek 获取最早的消息
+func (b *storeForwardBuffer) peek() (storedMsg, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.items) == 0 {
+		return storedMsg{}, false
+	}
+	return b.items[0], true
+}
+
+// pop 移除最早的消息
+func (b *storeForwardBuffer) pop() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.items) == 0 {
+		return nil
+	}
+	b.removeHead(1)
+	return b.compact()
+}
+
+// removeExpired 移除缓存时间早于before的消息
+func (b *storeForwardBuffer) removeExpired(before int64) {
+	if before <= 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	n := 0
+	for n < len(b.items) && b.items[n].BufferedAt < before {
+		n++
+	}
+	if n > 0 {
+		b.removeHead(n)
+		_ = b.compact()
+	}
+}
+
+// removeHead 从头部移除n条消息，调用方需要持有锁
+func (b *storeForwardBuffer) removeHead(n int) {
+	b.items = append([]storedMsg(nil), b.items[n:]...)
+	b.stale += n
+}
+
+// compact 已移除的行数超过剩余消息数或者缓存为空时重写文件，调用方需要持有锁
+func (b *storeForwardBuffer) compact() error {
+	if b.file == nil || (len(b.items) > 0 && b.stale <= len(b.items)) {
+		return nil
+	}
+	return b.rewrite()
+}
+
+// rewrite 先写临时文件再重命名，然后以追加方式重新打开，调用方需要持有锁
+func (b *storeForwardBuffer) rewrite() error {
+	if b.file != nil {
+		_ = b.file.Close()
+		b.file = nil
+	}
+	tmp := b.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, item := range b.items {
+		line, err := json.Marshal(item)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		_, _ = w.Write(append(line, '\n'))
+	}
+	if err = w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, b.path); err != nil {
+		return err
+	}
+	b.stale = 0
+	b.file, err = os.OpenFile(b.path, os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+func (b *storeForwardBuffer) close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	b.file = nil
+	return err
+}
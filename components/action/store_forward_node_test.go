@@ -0,0 +1,161 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testUplinkNode 测试上行节点，offline时发送到`Failure`链
+type testUplinkNode struct {
+	offline bool
+	sent    []string
+	lock    sync.Mutex
+}
+
+func (x *testUplinkNode) Type() string {
+	return "test/uplink"
+}
+
+func (x *testUplinkNode) New() types.Node {
+	return x
+}
+
+func (x *testUplinkNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *testUplinkNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.offline {
+		ctx.TellFailure(msg, errors.New("network unreachable"))
+		return nil
+	}
+	x.sent = append(x.sent, msg.Data)
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *testUplinkNode) Destroy() {
+}
+
+func (x *testUplinkNode) setOffline(offline bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.offline = offline
+}
+
+func (x *testUplinkNode) getSent() []string {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return append([]string(nil), x.sent...)
+}
+
+// testUplinkRegistry 只能创建测试上行节点的组件注册器
+type testUplinkRegistry struct {
+	types.ComponentRegistry
+	node types.Node
+}
+
+func (r testUplinkRegistry) NewNode(nodeType string) (types.Node, error) {
+	if nodeType != r.node.Type() {
+		return nil, errors.New("component not found")
+	}
+	return r.node.New(), nil
+}
+
+func TestStoreForwardNodeOnMsg(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	uplink := &testUplinkNode{}
+	config := types.NewConfig(types.WithClock(vc), types.WithComponentsRegistry(testUplinkRegistry{node: uplink}))
+	path := filepath.Join(t.TempDir(), "buffer", "uplink.jsonl")
+	configuration := types.Configuration{"uplink": "test/uplink", "path": path, "maxSize": 3, "ttlMs": 60000, "retryIntervalMs": 1000}
+
+	node := (&StoreForwardNode{}).New()
+	assert.Nil(t, node.Init(config, configuration))
+	var relations []string
+	var lock sync.Mutex
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		lock.Lock()
+		defer lock.Unlock()
+		relations = append(relations, relationType)
+	})
+	send := func(data string) {
+		assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", types.NewMetadata(), data)))
+	}
+
+	send("1")
+	//断网后缓存
+	uplink.setOffline(true)
+	send("2")
+	uplink.setOffline(false)
+	//存在缓存消息，新消息追加到缓存保证顺序
+	send("3")
+	assert.Equal(t, []string{types.Success, StoreForwardRelationBuffered, StoreForwardRelationBuffered}, relations)
+	assert.Equal(t, []string{"1"}, uplink.getSent())
+	assert.Equal(t, 2, node.(*StoreForwardNode).BufferSize())
+
+	//连接恢复后按顺序回放
+	vc.Advance(time.Second)
+	assert.Equal(t, []string{"1", "2", "3"}, uplink.getSent())
+	assert.Equal(t, 0, node.(*StoreForwardNode).BufferSize())
+
+	//缓存满丢弃最早的消息，重启后从文件恢复，新消息追加后再次丢弃最早的消息
+	uplink.setOffline(true)
+	for _, data := range []string{"4", "5", "6", "7"} {
+		send(data)
+	}
+	vc.Advance(time.Second)
+	assert.Equal(t, 3, node.(*StoreForwardNode).BufferSize())
+	node.Destroy()
+
+	uplink.setOffline(false)
+	node = (&StoreForwardNode{}).New()
+	assert.Nil(t, node.Init(config, configuration))
+	defer node.Destroy()
+	assert.Equal(t, 3, node.(*StoreForwardNode).BufferSize())
+	send("8")
+	vc.Advance(time.Second)
+	assert.Equal(t, []string{"1", "2", "3", "6", "7", "8"}, uplink.getSent())
+
+	//过期的消息不再回放
+	uplink.setOffline(true)
+	send("9")
+	vc.Advance(time.Minute * 2)
+	uplink.setOffline(false)
+	send("10")
+	assert.Equal(t, 0, node.(*StoreForwardNode).BufferSize())
+	assert.Equal(t, []string{"1", "2", "3", "6", "7", "8", "10"}, uplink.getSent())
+}
+
+func TestStoreForwardNodeInitError(t *testing.T) {
+	config := types.NewConfig(types.WithComponentsRegistry(testUplinkRegistry{node: &testUplinkNode{}}))
+	path := filepath.Join(t.TempDir(), "a.jsonl")
+	assert.NotNil(t, (&StoreForwardNode{}).New().Init(config, types.Configuration{"path": path}))
+	assert.NotNil(t, (&StoreForwardNode{}).New().Init(config, types.Configuration{"uplink": "test/uplink"}))
+	assert.NotNil(t, (&StoreForwardNode{}).New().Init(config, types.Configuration{"uplink": "mqttClient", "path": path}))
+	assert.NotNil(t, (&StoreForwardNode{}).New().Init(types.NewConfig(), types.Configuration{"uplink": "test/uplink", "path": path}))
+}
@@ -0,0 +1,221 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+//规则链节点配置示例：
+// {
+//        "id": "s3",
+//        "type": "syslog",
+//        "name": "转发日志到syslog",
+//        "debugMode": false,
+//        "configuration": {
+//          "network": "udp",
+//          "server": "127.0.0.1:514",
+//          "facility": 16,
+//          "severity": "${severity}",
+//          "appName": "rulego"
+//        }
+//      }
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	Registry.Add(&SyslogNode{})
+}
+
+// syslog 消息格式
+const (
+	RFC5424 = "RFC5424"
+	RFC3164 = "RFC3164"
+)
+
+// 默认日志级别 informational
+const defaultSyslogSeverity = 6
+
+// SyslogNodeConfiguration 节点配置
+type SyslogNodeConfiguration struct {
+	//Network 网络类型 udp、tcp或者tls，默认udp
+	Network string
+	//Server syslog服务地址，例如：127.0.0.1:514
+	Server string
+	//Facility 设施值(0-23)，默认16(local0)
+	Facility int
+	//Severity 日志级别(0-7)，可以使用 ${metaKeyName} 替换元数据中的变量，默认6(informational)
+	Severity string
+	//Hostname 主机名，默认使用本机主机名
+	Hostname string
+	//AppName 应用名称，可以使用 ${metaKeyName} 替换元数据中的变量，默认rulego
+	AppName string
+	//Format 消息格式 RFC5424或者RFC3164，默认RFC5424
+	Format string
+	//InsecureSkipVerify tls方式是否跳过证书校验
+	InsecureSkipVerify bool
+	//ConnectTimeoutMs 连接超时，单位毫秒
+	ConnectTimeoutMs int
+}
+
+// SyslogNode 把消息作为日志条目转发到远程syslog服务器
+// 日志内容为msg.Data，tcp和tls方式使用octet-counting分帧
+// 如果发送成功，发送消息到`Success`链, 否则发到`Failure`链
+type SyslogNode struct {
+	//节点配置
+	config   SyslogNodeConfiguration
+	hostname string
+	//连接，发送失败会关闭并在下一条消息重新连接
+	conn net.Conn
+	sync.Mutex
+}
+
+// Type 组件类型
+func (x *SyslogNode) Type() string {
+	return "syslog"
+}
+
+// Descriptor 组件描述
+func (x *SyslogNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"log", "syslog"},
+		Description: "把消息转发到远程syslog服务器",
+	}
+}
+
+func (x *SyslogNode) New() types.Node {
+	return &SyslogNode{config: SyslogNodeConfiguration{
+		Network:          "udp",
+		Facility:         16,
+		AppName:          "rulego",
+		Format:           RFC5424,
+		ConnectTimeoutMs: 5000,
+	}}
+}
+
+// Init 初始化
+func (x *SyslogNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err == nil {
+		if x.config.Server == "" {
+			return errors.New("server can not empty")
+		}
+		x.config.Network = strings.ToLower(x.config.Network)
+		switch x.config.Network {
+		case "udp", "tcp", "tls":
+		default:
+			return fmt.Errorf("unsupported network: %s", x.config.Network)
+		}
+		if x.config.Facility < 0 || x.config.Facility > 23 {
+			return fmt.Errorf("facility must be between 0 and 23")
+		}
+		x.config.Format = strings.ToUpper(x.config.Format)
+		x.hostname = x.config.Hostname
+		if x.hostname == "" {
+			x.hostname, _ = os.Hostname()
+		}
+		if x.hostname == "" {
+			x.hostname = "-"
+		}
+	}
+	return err
+}
+
+// OnMsg 处理消息
+func (x *SyslogNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	metaData := msg.Metadata.Values()
+	severity := defaultSyslogSeverity
+	if x.config.Severity != "" {
+		if v, err := strconv.Atoi(str.SprintfDict(x.config.Severity, metaData)); err == nil && v >= 0 && v <= 7 {
+			severity = v
+		}
+	}
+	appName := str.SprintfDict(x.config.AppName, metaData)
+	line := x.format(x.config.Facility*8+severity, time.UnixMilli(msg.Ts), appName, msg.Id, msg.Data)
+	if err := x.write(line); err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+	return nil
+}
+
+// Destroy 销毁
+func (x *SyslogNode) Destroy() {
+	x.Lock()
+	defer x.Unlock()
+	if x.conn != nil {
+		_ = x.conn.Close()
+		x.conn = nil
+	}
+}
+
+// format 按照配置的格式生成syslog消息
+func (x *SyslogNode) format(priority int, ts time.Time, appName, msgId, content string) string {
+	if appName == "" {
+		appName = "-"
+	}
+	if x.config.Format == RFC3164 {
+		return fmt.Sprintf("<%d>%s %s %s: %s", priority, ts.Format(time.Stamp), x.hostname, appName, content)
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s - %s - %s", priority, ts.Format(time.RFC3339Nano), x.hostname, appName, msgId, content)
+}
+
+// write 发送消息，连接断开时重连一次
+func (x *SyslogNode) write(line string) error {
+	x.Lock()
+	defer x.Unlock()
+	var err error
+	for i := 0; i < 2; i++ {
+		if x.conn == nil {
+			if x.conn, err = x.dial(); err != nil {
+				return err
+			}
+		}
+		if x.config.Network == "udp" {
+			_, err = x.conn.Write([]byte(line))
+		} else {
+			_, err = fmt.Fprintf(x.conn, "%d %s", len(line), line)
+		}
+		if err == nil {
+			return nil
+		}
+		_ = x.conn.Close()
+		x.conn = nil
+	}
+	return err
+}
+
+func (x *SyslogNode) dial() (net.Conn, error) {
+	timeout := time.Duration(x.config.ConnectTimeoutMs) * time.Millisecond
+	if x.config.Network == "tls" {
+		host, _, _ := net.SplitHostPort(x.config.Server)
+		return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", x.config.Server, &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: x.config.InsecureSkipVerify,
+		})
+	}
+	return net.DialTimeout(x.config.Network, x.config.Server, timeout)
+}
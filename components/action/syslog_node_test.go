@@ -0,0 +1,78 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogNodeOnMsg(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	node := (&SyslogNode{}).New()
+	var configuration = make(types.Configuration)
+	configuration["server"] = conn.LocalAddr().String()
+	configuration["severity"] = "${severity}"
+	configuration["hostname"] = "edge01"
+	config := types.NewConfig()
+	err = node.Init(config, configuration)
+	if err != nil {
+		t.Errorf("err=%s", err)
+	}
+	defer node.Destroy()
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Success, relationType)
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("severity", "3")
+	msg := ctx.NewMsg("TEST_MSG_TYPE_AA", metaData, "{\"temperature\":41}")
+	err = node.OnMsg(ctx, msg)
+	if err != nil {
+		t.Errorf("err=%s", err)
+	}
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 2))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := string(buf[:n])
+	//local0(16)*8+3
+	assert.True(t, strings.HasPrefix(line, "<131>1 "))
+	assert.True(t, strings.Contains(line, " edge01 rulego "))
+	assert.True(t, strings.HasSuffix(line, "{\"temperature\":41}"))
+}
+
+func TestSyslogNodeInitError(t *testing.T) {
+	node := (&SyslogNode{}).New()
+	var configuration = make(types.Configuration)
+	configuration["server"] = "127.0.0.1:514"
+	configuration["network"] = "quic"
+	err := node.Init(types.NewConfig(), configuration)
+	assert.NotNil(t, err)
+}
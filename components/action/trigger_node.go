@@ -0,0 +1,190 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	//TriggerModeInactivity 每条消息重新计时，超过指定时间没有收到消息则触发，例如设备离线检测
+	TriggerModeInactivity = "inactivity"
+	//TriggerModeTimer 收到消息后开始计时，计时期间收到的消息不重新计时
+	TriggerModeTimer = "timer"
+)
+
+// TriggerRelation 触发事件消息的关系类型
+const TriggerRelation = "Trigger"
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "trigger",
+//	       "name": "设备离线检测",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "key": "${deviceId}",
+//	         "delayMs": 600000,
+//	         "msgType": "DEVICE_OFFLINE"
+//	       }
+//	     }
+func init() {
+	Registry.Add(&TriggerNode{})
+}
+
+// TriggerNodeConfiguration 节点配置
+type TriggerNodeConfiguration struct {
+	//Mode 触发模式：inactivity、timer，默认：inactivity
+	Mode string
+	//Key 计时器key，可以使用 ${metaKeyName} 替换元数据中的变量，每个key独立计时
+	Key string
+	//DelayMs 触发延迟，单位毫秒
+	DelayMs int64
+	//Repeat 触发后是否继续按照DelayMs周期触发，直到收到下一条消息
+	Repeat bool
+	//MsgType 触发事件的消息类型，默认：inactivity模式INACTIVITY_EVENT，timer模式TIMER_EVENT
+	MsgType string
+}
+
+// TriggerNode 规则链自触发节点，按key注册计时器，到期后向规则链注入事件消息
+// 事件消息复制最后一条消息的内容和元数据，并增加元数据：triggerKey、lastMsgTs
+// 收到的消息发送到`Success`链，事件消息发送到`Trigger`链
+type TriggerNode struct {
+	config TriggerNodeConfiguration
+	clock  clock.Clock
+	delay  time.Duration
+	timers map[string]*triggerTimer
+	lock   sync.Mutex
+}
+
+// triggerTimer key的计时器以及最后一条消息
+type triggerTimer struct {
+	timer clock.Timer
+	ctx   types.RuleContext
+	msg   types.RuleMsg
+}
+
+// Type 组件类型
+func (x *TriggerNode) Type() string {
+	return "trigger"
+}
+
+// Descriptor 组件描述
+func (x *TriggerNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"timer", "inactivity", "offline", "schedule"},
+		Description: "按key计时，到期后向规则链注入事件消息",
+	}
+}
+
+func (x *TriggerNode) New() types.Node {
+	return &TriggerNode{config: TriggerNodeConfiguration{Mode: TriggerModeInactivity}}
+}
+
+// Init 初始化
+func (x *TriggerNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.DelayMs <= 0 {
+		return errors.New("delayMs must be greater than 0")
+	}
+	switch x.config.Mode {
+	case TriggerModeInactivity:
+		if x.config.MsgType == "" {
+			x.config.MsgType = "INACTIVITY_EVENT"
+		}
+	case TriggerModeTimer:
+		if x.config.MsgType == "" {
+			x.config.MsgType = "TIMER_EVENT"
+		}
+	default:
+		return errors.New("unsupported mode:" + x.config.Mode)
+	}
+	x.delay = time.Duration(x.config.DelayMs) * time.Millisecond
+	x.clock = ruleConfig.GetClock()
+	x.timers = make(map[string]*triggerTimer)
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *TriggerNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	key := str.SprintfDict(x.config.Key, msg.Metadata.Values())
+	x.lock.Lock()
+	if item, ok := x.timers[key]; ok {
+		item.ctx = ctx
+		item.msg = msg.Copy()
+		if x.config.Mode == TriggerModeInactivity {
+			item.timer.Stop()
+			x.schedule(key, item)
+		}
+	} else {
+		item = &triggerTimer{ctx: ctx, msg: msg.Copy()}
+		x.timers[key] = item
+		x.schedule(key, item)
+	}
+	x.lock.Unlock()
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+// Destroy 销毁
+func (x *TriggerNode) Destroy() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for key, item := range x.timers {
+		item.timer.Stop()
+		delete(x.timers, key)
+	}
+}
+
+// schedule 启动计时器，调用方需要持有锁
+func (x *TriggerNode) schedule(key string, item *triggerTimer) {
+	var timer clock.Timer
+	timer = x.clock.AfterFunc(x.delay, func() {
+		x.lock.Lock()
+		//计时器已经被重置或者节点已经销毁
+		if current, ok := x.timers[key]; !ok || current != item || item.timer != timer {
+			x.lock.Unlock()
+			return
+		}
+		if x.config.Repeat {
+			x.schedule(key, item)
+		} else {
+			delete(x.timers, key)
+		}
+		ctx, last := item.ctx, item.msg
+		x.lock.Unlock()
+
+		metaData := last.Metadata.Copy()
+		metaData.PutValue("triggerKey", key)
+		metaData.PutValue("lastMsgTs", strconv.FormatInt(last.Ts, 10))
+		event := ctx.NewMsg(x.config.MsgType, metaData, last.Data)
+		event.DataType = last.DataType
+		ctx.TellNext(event, TriggerRelation)
+	})
+	item.timer = timer
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+type triggerRecorder struct {
+	events []types.RuleMsg
+	lock   sync.Mutex
+}
+
+func (r *triggerRecorder) callback(msg types.RuleMsg, relationType string) {
+	if relationType == TriggerRelation {
+		r.lock.Lock()
+		r.events = append(r.events, msg)
+		r.lock.Unlock()
+	}
+}
+
+func (r *triggerRecorder) count() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.events)
+}
+
+func TestTriggerNodeInactivity(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	config := types.NewConfig(types.WithClock(vc))
+	node := (&TriggerNode{}).New()
+	err := node.Init(config, types.Configuration{
+		"key":     "${deviceId}",
+		"delayMs": 60000,
+		"msgType": "DEVICE_OFFLINE",
+	})
+	assert.Nil(t, err)
+	defer node.Destroy()
+
+	var recorder triggerRecorder
+	ctx := test.NewRuleContext(config, recorder.callback)
+	send := func(deviceId string) {
+		metaData := types.NewMetadata()
+		metaData.PutValue("deviceId", deviceId)
+		assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TELEMETRY", metaData, "{\"temperature\":41}")))
+	}
+	send("aa")
+	send("bb")
+	vc.Advance(time.Second * 30)
+	//aa 重新计时
+	send("aa")
+	vc.Advance(time.Second * 30)
+	assert.Equal(t, 1, recorder.count())
+	event := recorder.events[0]
+	assert.Equal(t, "DEVICE_OFFLINE", event.Type)
+	assert.Equal(t, "bb", event.Metadata.GetValue("triggerKey"))
+	assert.Equal(t, "{\"temperature\":41}", event.Data)
+
+	vc.Advance(time.Second * 30)
+	assert.Equal(t, 2, recorder.count())
+	assert.Equal(t, "aa", recorder.events[1].Metadata.GetValue("triggerKey"))
+
+	//不重复触发
+	vc.Advance(time.Minute * 5)
+	assert.Equal(t, 2, recorder.count())
+	assert.Equal(t, 0, vc.Pending())
+}
+
+func TestTriggerNodeTimerRepeat(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	config := types.NewConfig(types.WithClock(vc))
+	node := (&TriggerNode{}).New()
+	err := node.Init(config, types.Configuration{
+		"mode":    "timer",
+		"delayMs": 1000,
+		"repeat":  true,
+	})
+	assert.Nil(t, err)
+
+	var recorder triggerRecorder
+	ctx := test.NewRuleContext(config, recorder.callback)
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "a")))
+	vc.Advance(time.Millisecond * 500)
+	//timer模式不重新计时
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "b")))
+	vc.Advance(time.Millisecond * 500)
+	assert.Equal(t, 1, recorder.count())
+	assert.Equal(t, "TIMER_EVENT", recorder.events[0].Type)
+	assert.Equal(t, "b", recorder.events[0].Data)
+	vc.Advance(time.Second * 2)
+	assert.Equal(t, 3, recorder.count())
+
+	node.Destroy()
+	vc.Advance(time.Second * 2)
+	assert.Equal(t, 3, recorder.count())
+}
+
+func TestTriggerNodeInitError(t *testing.T) {
+	node := (&TriggerNode{}).New()
+	assert.NotNil(t, node.Init(types.NewConfig(), types.Configuration{}))
+	assert.NotNil(t, node.Init(types.NewConfig(), types.Configuration{"delayMs": 1000, "mode": "cron"}))
+}
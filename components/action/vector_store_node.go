@@ -0,0 +1,230 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/vector"
+	"github.com/2018yuli/rulego/utils/json"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//VectorOpUpsert 插入或者更新向量
+	VectorOpUpsert = "upsert"
+	//VectorOpQuery 相似查询
+	VectorOpQuery = "query"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "vectorStore",
+//	       "name": "查询相似文档",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "provider": "qdrant",
+//	         "server": "http://127.0.0.1:6333",
+//	         "collection": "docs",
+//	         "operation": "query",
+//	         "vectorPath": "$.embedding",
+//	         "topK": 3
+//	       }
+//	     }
+func init() {
+	Registry.Add(&VectorStoreNode{})
+}
+
+// VectorStoreNodeConfiguration 节点配置
+type VectorStoreNodeConfiguration struct {
+	//Provider 向量数据库类型：qdrant、milvus、pgvector
+	Provider string
+	//Server 服务地址，pgvector为postgres连接配置
+	Server string
+	//ApiKey qdrant的api-key或者milvus的token
+	ApiKey string `sensitive:"true"`
+	//Collection 集合名称(pgvector为表名)，可以使用 ${metaKeyName} 替换元数据中的变量
+	Collection string
+	//Operation 操作：upsert 或者 query，默认query
+	Operation string
+	//VectorPath 向量在msg.Data中的路径，默认：$.embedding
+	VectorPath string
+	//IdPath upsert操作ID在msg.Data中的路径，默认：$.id，不存在则使用消息ID
+	IdPath string
+	//PayloadPath upsert操作附加数据在msg.Data中的路径，为空则使用msg.Data除向量外的所有字段
+	PayloadPath string
+	//TopK query操作返回的结果数，默认5
+	TopK int
+	//ScoreThreshold query操作最低相似度分数，0表示不过滤
+	ScoreThreshold float64
+	//TimeoutMs 请求超时，单位毫秒，默认10000
+	TimeoutMs int
+}
+
+// VectorStoreNode 把消息中的向量写入向量数据库或者进行相似查询
+// upsert：写入成功，消息原样发送到`Success`链
+// query：查询结果([{"id":"","score":0.9,"payload":{}}])写入msg.Data，元数据matchCount记录结果数，发送到`Success`链
+// 失败发送到`Failure`链
+type VectorStoreNode struct {
+	config VectorStoreNodeConfiguration
+	store  vector.Store
+}
+
+// Type 组件类型
+func (x *VectorStoreNode) Type() string {
+	return "vectorStore"
+}
+
+// Descriptor 组件描述
+func (x *VectorStoreNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"ai", "vector", "database"},
+		Description: "向量数据库写入和相似查询",
+	}
+}
+
+func (x *VectorStoreNode) New() types.Node {
+	return &VectorStoreNode{config: VectorStoreNodeConfiguration{
+		Operation:  VectorOpQuery,
+		VectorPath: "$.embedding",
+		IdPath:     "$.id",
+		TopK:       5,
+		TimeoutMs:  10000,
+	}}
+}
+
+// Init 初始化
+func (x *VectorStoreNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Collection == "" {
+		return errors.New("collection can not empty")
+	}
+	x.config.Operation = strings.ToLower(x.config.Operation)
+	if x.config.Operation != VectorOpUpsert && x.config.Operation != VectorOpQuery {
+		return errors.New("unsupported operation:" + x.config.Operation)
+	}
+	x.store, err = vector.NewStore(vector.Config{
+		Provider: x.config.Provider,
+		Server:   x.config.Server,
+		ApiKey:   x.config.ApiKey,
+		Timeout:  time.Duration(x.config.TimeoutMs) * time.Millisecond,
+	})
+	return err
+}
+
+// OnMsg 处理消息
+func (x *VectorStoreNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	err := x.handle(&msg)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (x *VectorStoreNode) Destroy() {
+	if x.store != nil {
+		_ = x.store.Close()
+	}
+}
+
+func (x *VectorStoreNode) handle(msg *types.RuleMsg) error {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+		return err
+	}
+	vec, err := toVector(maps.Get(data, x.config.VectorPath))
+	if err != nil {
+		return fmt.Errorf("vector %s error:%w", x.config.VectorPath, err)
+	}
+	collection := str.SprintfDict(x.config.Collection, msg.Metadata.Values())
+	if x.config.Operation == VectorOpUpsert {
+		return x.store.Upsert(collection, []vector.Point{{
+			Id:      x.pointId(data, msg.Id),
+			Vector:  vec,
+			Payload: x.payload(data),
+		}})
+	}
+	matches, err := x.store.Query(collection, vec, x.config.TopK, x.config.ScoreThreshold)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(matches)
+	if err != nil {
+		return err
+	}
+	msg.Data = string(b)
+	msg.DataType = types.JSON
+	msg.Metadata.PutValue("matchCount", strconv.Itoa(len(matches)))
+	return nil
+}
+
+func (x *VectorStoreNode) pointId(data map[string]interface{}, msgId string) string {
+	switch id := maps.Get(data, x.config.IdPath).(type) {
+	case nil:
+		return msgId
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	default:
+		return str.ToString(id)
+	}
+}
+
+// payload 获取附加数据，没有配置PayloadPath则使用除向量外的所有字段
+func (x *VectorStoreNode) payload(data map[string]interface{}) map[string]interface{} {
+	if x.config.PayloadPath != "" {
+		payload, _ := maps.Get(data, x.config.PayloadPath).(map[string]interface{})
+		return payload
+	}
+	payload := make(map[string]interface{}, len(data))
+	vectorKey := strings.TrimPrefix(x.config.VectorPath, "$.")
+	for k, v := range data {
+		if k != vectorKey {
+			payload[k] = v
+		}
+	}
+	return payload
+}
+
+// toVector 把JSON数组转换成向量
+func toVector(v interface{}) ([]float64, error) {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, errors.New("not a number array")
+	}
+	result := make([]float64, len(list))
+	for i, item := range list {
+		f, ok := item.(float64)
+		if !ok {
+			return nil, errors.New("not a number array")
+		}
+		result[i] = f
+	}
+	return result, nil
+}
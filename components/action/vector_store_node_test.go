@@ -0,0 +1,96 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"encoding/json"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVectorStoreNodeOnMsg(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		switch r.URL.Path {
+		case "/collections/docs_aa/points":
+			point := body["points"].([]interface{})[0].(map[string]interface{})
+			assert.Equal(t, 12.0, point["id"])
+			payload := point["payload"].(map[string]interface{})
+			assert.Equal(t, "hello", payload["text"])
+			assert.Nil(t, payload["embedding"])
+			_, _ = w.Write([]byte(`{"status":"ok"}`))
+		case "/collections/docs_aa/points/search":
+			assert.Equal(t, 3.0, body["limit"])
+			_, _ = w.Write([]byte(`{"result":[{"id":12,"score":0.98,"payload":{"text":"hello"}}]}`))
+		}
+	}))
+	defer server.Close()
+
+	config := types.NewConfig()
+	configuration := types.Configuration{
+		"provider":   "qdrant",
+		"server":     server.URL,
+		"collection": "docs_${tenant}",
+		"operation":  "upsert",
+	}
+	node := (&VectorStoreNode{}).New()
+	err := node.Init(config, configuration)
+	assert.Nil(t, err)
+	defer node.Destroy()
+
+	var relation string
+	var result types.RuleMsg
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		result = msg
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("tenant", "aa")
+	data := `{"id":12,"text":"hello","embedding":[0.1,0.2,0.3]}`
+	err = node.OnMsg(ctx, ctx.NewMsg("DOC", metaData, data))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, data, result.Data)
+
+	configuration["operation"] = "query"
+	configuration["topK"] = 3
+	node = (&VectorStoreNode{}).New()
+	err = node.Init(config, configuration)
+	assert.Nil(t, err)
+	err = node.OnMsg(ctx, ctx.NewMsg("QUERY", metaData, `{"embedding":[0.1,0.2,0.3]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, `[{"id":"12","score":0.98,"payload":{"text":"hello"}}]`, result.Data)
+	assert.Equal(t, "1", result.Metadata.GetValue("matchCount"))
+
+	//向量格式错误
+	err = node.OnMsg(ctx, ctx.NewMsg("QUERY", metaData, `{"embedding":"aa"}`))
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relation)
+}
+
+func TestVectorStoreNodeInit(t *testing.T) {
+	config := types.NewConfig()
+	assert.NotNil(t, (&VectorStoreNode{}).New().Init(config, types.Configuration{"provider": "qdrant", "server": "http://127.0.0.1:6333"}))
+	assert.NotNil(t, (&VectorStoreNode{}).New().Init(config, types.Configuration{"provider": "qdrant", "server": "http://127.0.0.1:6333", "collection": "a", "operation": "xx"}))
+	assert.NotNil(t, (&VectorStoreNode{}).New().Init(config, types.Configuration{"provider": "xx", "server": "http://127.0.0.1:6333", "collection": "a"}))
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/zmq"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"strings"
+	"time"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s3",
+//	       "type": "zmqClient",
+//	       "name": "发布到ZeroMQ",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "server": "tcp://*:5556",
+//	         "socketType": "PUB",
+//	         "bind": true,
+//	         "topic": "device/${deviceId}"
+//	       }
+//	     }
+func init() {
+	Registry.Add(&ZmqClientNode{})
+}
+
+// ZmqClientNodeConfiguration 节点配置
+type ZmqClientNodeConfiguration struct {
+	//Server 地址，多个与逗号隔开，例如：tcp://127.0.0.1:5555、ipc:///tmp/rulego.ipc
+	Server string
+	//SocketType socket类型：PUSH或者PUB
+	SocketType string
+	//Bind 是否绑定地址，否则连接地址
+	Bind bool
+	//Topic 主题，不为空则作为消息第一帧发送，PUB模式下用于订阅端过滤
+	//可以使用 ${metaKeyName} 替换元数据中的变量
+	Topic string
+	//SendTimeoutMs PUSH模式没有可用对端时的发送等待时间，单位毫秒
+	SendTimeoutMs int
+}
+
+// ZmqClientNode 把msg.Data通过ZeroMQ PUSH或者PUB socket发送
+// 配置了Topic则发送[topic,data]两帧消息，否则发送[data]单帧消息
+// 如果发送成功，发送消息到`Success`链, 否则发到`Failure`链
+// PUB模式下没有订阅者的消息会被丢弃，也认为发送成功
+type ZmqClientNode struct {
+	config ZmqClientNodeConfiguration
+	socket *zmq.Socket
+}
+
+// Type 组件类型
+func (x *ZmqClientNode) Type() string {
+	return "zmqClient"
+}
+
+// Descriptor 组件描述
+func (x *ZmqClientNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"zeromq", "messaging"},
+		Description: "通过ZeroMQ发送消息",
+	}
+}
+
+func (x *ZmqClientNode) New() types.Node {
+	return &ZmqClientNode{config: ZmqClientNodeConfiguration{
+		SocketType:    zmq.Push,
+		SendTimeoutMs: 5000,
+	}}
+}
+
+// Init 初始化
+func (x *ZmqClientNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	if x.config.Server == "" {
+		return errors.New("server can not empty")
+	}
+	socketType := strings.ToUpper(x.config.SocketType)
+	if socketType != zmq.Push && socketType != zmq.Pub {
+		return errors.New("socketType must be PUSH or PUB")
+	}
+	if x.socket, err = zmq.NewSocket(socketType); err != nil {
+		return err
+	}
+	x.socket.SendTimeout = time.Duration(x.config.SendTimeoutMs) * time.Millisecond
+	for _, server := range strings.Split(x.config.Server, ",") {
+		server = strings.TrimSpace(server)
+		if x.config.Bind {
+			err = x.socket.Bind(server)
+		} else {
+			err = x.socket.Connect(server)
+		}
+		if err != nil {
+			_ = x.socket.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *ZmqClientNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	var err error
+	if x.config.Topic != "" {
+		topic := str.SprintfDict(x.config.Topic, msg.Metadata.Values())
+		err = x.socket.Send([]byte(topic), []byte(msg.Data))
+	} else {
+		err = x.socket.Send([]byte(msg.Data))
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (x *ZmqClientNode) Destroy() {
+	if x.socket != nil {
+		_ = x.socket.Close()
+	}
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package action
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/zmq"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+)
+
+func TestZmqClientNodeOnMsg(t *testing.T) {
+	pull, _ := zmq.NewSocket(zmq.Pull)
+	defer pull.Close()
+	err := pull.Bind("tcp://127.0.0.1:0")
+	assert.Nil(t, err)
+
+	config := types.NewConfig()
+	node := (&ZmqClientNode{}).New()
+	err = node.Init(config, types.Configuration{
+		"server": "tcp://" + pull.Addr().String(),
+		"topic":  "device/${deviceId}",
+	})
+	assert.Nil(t, err)
+	defer node.Destroy()
+
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Success, relationType)
+	})
+	err = node.OnMsg(ctx, ctx.NewMsg("TEST_MSG_TYPE_AA", metaData, "{\"temperature\":41}"))
+	assert.Nil(t, err)
+
+	frames, err := pull.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, "device/aa", string(frames[0]))
+	assert.Equal(t, "{\"temperature\":41}", string(frames[1]))
+
+	//不支持的socket类型
+	err = (&ZmqClientNode{}).New().Init(config, types.Configuration{"server": "tcp://127.0.0.1:5555", "socketType": "SUB"})
+	assert.NotNil(t, err)
+}
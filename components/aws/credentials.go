@@ -0,0 +1,74 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"errors"
+	"os"
+)
+
+// Credentials AWS访问凭证
+type Credentials struct {
+	//AccessKeyId 访问密钥ID
+	AccessKeyId string
+	//SecretAccessKey 访问密钥
+	SecretAccessKey string
+	//SessionToken 临时会话token，可选
+	SessionToken string
+}
+
+// CredentialsProvider AWS访问凭证提供者
+// 每次请求前获取凭证，实现方可以在内部刷新临时凭证
+type CredentialsProvider interface {
+	Retrieve() (Credentials, error)
+}
+
+// StaticCredentialsProvider 固定凭证
+type StaticCredentialsProvider struct {
+	Credentials Credentials
+}
+
+func (p StaticCredentialsProvider) Retrieve() (Credentials, error) {
+	if p.Credentials.AccessKeyId == "" || p.Credentials.SecretAccessKey == "" {
+		return Credentials{}, errors.New("accessKeyId and secretAccessKey can not empty")
+	}
+	return p.Credentials, nil
+}
+
+// EnvCredentialsProvider 从环境变量AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY和AWS_SESSION_TOKEN获取凭证
+type EnvCredentialsProvider struct {
+}
+
+func (p EnvCredentialsProvider) Retrieve() (Credentials, error) {
+	return StaticCredentialsProvider{Credentials: Credentials{
+		AccessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}}.Retrieve()
+}
+
+// NewCredentialsProvider 如果配置了accessKeyId则使用固定凭证，否则使用环境变量凭证
+func NewCredentialsProvider(accessKeyId, secretAccessKey, sessionToken string) CredentialsProvider {
+	if accessKeyId != "" {
+		return StaticCredentialsProvider{Credentials: Credentials{
+			AccessKeyId:     accessKeyId,
+			SecretAccessKey: secretAccessKey,
+			SessionToken:    sessionToken,
+		}}
+	}
+	return EnvCredentialsProvider{}
+}
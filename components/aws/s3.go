@@ -0,0 +1,130 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/2018yuli/rulego/utils/blob"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Client S3对象存储客户端，使用path-style地址访问对象，实现了`blob.Store`
+type S3Client struct {
+	//Endpoint 接口地址，例如：https://s3.us-east-1.amazonaws.com
+	Endpoint string
+	//Region 区域
+	Region string
+	//Bucket 存储桶
+	Bucket string
+	//Prefix 对象key前缀，例如：rulego/blob/
+	Prefix string
+	//Credentials 凭证提供者
+	Credentials CredentialsProvider
+	HttpClient  *http.Client
+}
+
+// NewS3Client 创建S3客户端，endpoint为空则使用区域默认地址
+func NewS3Client(region, endpoint, bucket string, credentials CredentialsProvider, timeout time.Duration) *S3Client {
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	return &S3Client{Endpoint: strings.TrimSuffix(endpoint, "/"), Region: region, Bucket: bucket, Credentials: credentials,
+		HttpClient: &http.Client{Timeout: timeout}}
+}
+
+// Put 上传对象
+func (c *S3Client) Put(key string, data []byte) error {
+	_, err := c.do(context.Background(), http.MethodPut, key, data)
+	return err
+}
+
+// Get 下载对象，不存在返回`blob.ErrNotFound`
+func (c *S3Client) Get(key string) ([]byte, error) {
+	return c.do(context.Background(), http.MethodGet, key, nil)
+}
+
+// Delete 删除对象
+func (c *S3Client) Delete(key string) error {
+	_, err := c.do(context.Background(), http.MethodDelete, key, nil)
+	if err == blob.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// do 签名并执行对象请求，返回响应内容
+func (c *S3Client) do(ctx context.Context, method, key string, body []byte) ([]byte, error) {
+	credentials, err := c.Credentials.Retrieve()
+	if err != nil {
+		return nil, err
+	}
+	objectUrl := c.Endpoint + "/" + url.PathEscape(c.Bucket) + "/" + escapeKey(c.Prefix+key)
+	req, err := http.NewRequest(method, objectUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if method == http.MethodPut {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	SignV4(req, body, credentials, c.Region, "s3", time.Now())
+	response, err := c.HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	b, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return nil, blob.ErrNotFound
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		var errResponse struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		if xml.Unmarshal(b, &errResponse) == nil && errResponse.Code != "" {
+			return nil, &Error{Code: errResponse.Code, Message: errResponse.Message}
+		}
+		return nil, fmt.Errorf("%s: %s", response.Status, string(b))
+	}
+	return b, nil
+}
+
+// escapeKey 按段编码对象key，保留路径分隔符
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/blob"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestS3Client(t *testing.T) {
+	var lock sync.Mutex
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "SignedHeaders=") ||
+			!strings.Contains(r.Header.Get("Authorization"), "x-amz-content-sha256") {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>denied</Message></Error>`))
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			b, _ := ioutil.ReadAll(r.Body)
+			objects[r.URL.Path] = b
+		case http.MethodGet:
+			b, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(b)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	var store blob.Store = NewS3Client("us-east-1", server.URL, "bucket", NewCredentialsProvider("ak", "sk", ""), time.Second)
+	store.(*S3Client).Prefix = "rulego/"
+	assert.Nil(t, store.Put("k1", []byte("large payload")))
+	_, ok := objects["/bucket/rulego/k1"]
+	assert.True(t, ok)
+	data, err := store.Get("k1")
+	assert.Nil(t, err)
+	assert.Equal(t, "large payload", string(data))
+	assert.Nil(t, store.Delete("k1"))
+	_, err = store.Get("k1")
+	assert.Equal(t, blob.ErrNotFound, err)
+}
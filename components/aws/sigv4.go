@@ -0,0 +1,120 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const signAlgorithm = "AWS4-HMAC-SHA256"
+
+// SignV4 使用AWS Signature Version 4 对请求进行签名
+// 签名的请求头包括host、x-amz-date、content-type和x-amz-content-sha256(如果存在)，session token会设置到x-amz-security-token请求头
+func SignV4(req *http.Request, body []byte, credentials Credentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	//参与签名的请求头
+	headers := map[string]string{"host": req.URL.Host, "x-amz-date": amzDate}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+	if credentials.SessionToken != "" {
+		headers["x-amz-security-token"] = credentials.SessionToken
+	}
+	if contentSha256 := req.Header.Get("X-Amz-Content-Sha256"); contentSha256 != "" {
+		headers["x-amz-content-sha256"] = contentSha256
+	}
+	var headerNames []string
+	for k := range headers {
+		headerNames = append(headerNames, k)
+	}
+	sort.Strings(headerNames)
+	var canonicalHeaders strings.Builder
+	for _, k := range headerNames {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalUri := req.URL.EscapedPath()
+	if canonicalUri == "" {
+		canonicalUri = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalUri,
+		canonicalQueryString(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{signAlgorithm, amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	signingKey = hmacSha256(signingKey, region)
+	signingKey = hmacSha256(signingKey, service)
+	signingKey = hmacSha256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", signAlgorithm+" Credential="+credentials.AccessKeyId+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQueryString 按key排序并使用RFC3986方式编码查询参数
+func canonicalQueryString(values url.Values) string {
+	var keys []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		vs := values[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode RFC3986 编码，空格编码成%20
+func uriEncode(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
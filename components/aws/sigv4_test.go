@@ -0,0 +1,49 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 使用AWS官方文档示例验证签名
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	now, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+	SignV4(req, nil, Credentials{AccessKeyId: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}, "us-east-1", "iam", now)
+
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7", req.Header.Get("Authorization"))
+
+	//session token 参与签名
+	req, _ = http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	SignV4(req, nil, Credentials{AccessKeyId: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}, "us-east-1", "iam", now)
+	assert.Equal(t, "token", req.Header.Get("X-Amz-Security-Token"))
+	assert.True(t, strings.Contains(req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token"))
+}
+
+func TestCanonicalQueryString(t *testing.T) {
+	values := map[string][]string{"b": {"2", "1"}, "a": {"x y"}, "c": {"~-_."}}
+	assert.Equal(t, "a=x%20y&b=1&b=2&c=~-_.", canonicalQueryString(values))
+}
@@ -0,0 +1,348 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sqsApiVersion = "2012-11-05"
+	snsApiVersion = "2010-03-31"
+	//MaxBatchSize SQS和SNS批量接口每批最大消息数
+	MaxBatchSize = 10
+)
+
+// Message 发送或者接收的消息
+type Message struct {
+	//Id 批量发送时批次内的ID，接收时为消息ID
+	Id string
+	//Body 消息内容
+	Body string
+	//Attributes 消息属性，使用String类型
+	Attributes map[string]string
+	//ReceiptHandle 接收消息的句柄，用于删除消息
+	ReceiptHandle string
+	//GroupId FIFO队列/主题消息组ID
+	GroupId string
+	//DeduplicationId FIFO队列/主题去重ID
+	DeduplicationId string
+}
+
+// BatchResult 批量发送结果，Failed key为Message.Id
+type BatchResult struct {
+	Successful map[string]string
+	Failed     map[string]error
+}
+
+// Error AWS接口错误
+type Error struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Client AWS query协议接口客户端，签名后以表单方式POST请求
+type Client struct {
+	//Endpoint 接口地址，例如：https://sqs.us-east-1.amazonaws.com/
+	Endpoint string
+	//Region 区域
+	Region string
+	//Service 签名服务名，例如：sqs、sns
+	Service string
+	//Credentials 凭证提供者
+	Credentials CredentialsProvider
+	HttpClient  *http.Client
+}
+
+// Do 执行请求并把xml响应解析到out
+func (c *Client) Do(ctx context.Context, params url.Values, out interface{}) error {
+	credentials, err := c.Credentials.Retrieve()
+	if err != nil {
+		return err
+	}
+	body := []byte(params.Encode())
+	req, err := http.NewRequest(http.MethodPost, c.Endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	SignV4(req, body, credentials, c.Region, c.Service, time.Now())
+	response, err := c.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	b, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		var errResponse Error
+		if xml.Unmarshal(b, &errResponse) == nil && errResponse.Code != "" {
+			return &errResponse
+		}
+		return fmt.Errorf("%s: %s", response.Status, string(b))
+	}
+	if out != nil {
+		return xml.Unmarshal(b, out)
+	}
+	return nil
+}
+
+// SqsClient SQS客户端
+type SqsClient struct {
+	Client
+}
+
+// NewSqsClient 创建SQS客户端，endpoint为空则使用区域默认地址
+func NewSqsClient(region, endpoint string, credentials CredentialsProvider, timeout time.Duration) *SqsClient {
+	if endpoint == "" {
+		endpoint = "https://sqs." + region + ".amazonaws.com/"
+	}
+	return &SqsClient{Client{Endpoint: endpoint, Region: region, Service: "sqs", Credentials: credentials,
+		HttpClient: &http.Client{Timeout: timeout}}}
+}
+
+// SendMessage 发送一条消息，返回消息ID
+func (c *SqsClient) SendMessage(ctx context.Context, queueUrl string, msg Message) (string, error) {
+	params := url.Values{}
+	params.Set("Action", "SendMessage")
+	params.Set("Version", sqsApiVersion)
+	params.Set("QueueUrl", queueUrl)
+	setSqsMessage(params, "", msg)
+	var out struct {
+		MessageId string `xml:"SendMessageResult>MessageId"`
+	}
+	err := c.Do(ctx, params, &out)
+	return out.MessageId, err
+}
+
+// SendMessageBatch 批量发送消息，每批最多MaxBatchSize条
+func (c *SqsClient) SendMessageBatch(ctx context.Context, queueUrl string, msgs []Message) (BatchResult, error) {
+	params := url.Values{}
+	params.Set("Action", "SendMessageBatch")
+	params.Set("Version", sqsApiVersion)
+	params.Set("QueueUrl", queueUrl)
+	for i, msg := range msgs {
+		prefix := fmt.Sprintf("SendMessageBatchRequestEntry.%d.", i+1)
+		params.Set(prefix+"Id", msg.Id)
+		setSqsMessage(params, prefix, msg)
+	}
+	var out struct {
+		Successful []struct {
+			Id        string
+			MessageId string
+		} `xml:"SendMessageBatchResult>SendMessageBatchResultEntry"`
+		Failed []batchErrorEntry `xml:"SendMessageBatchResult>BatchResultErrorEntry"`
+	}
+	if err := c.Do(ctx, params, &out); err != nil {
+		return BatchResult{}, err
+	}
+	result := BatchResult{Successful: make(map[string]string), Failed: make(map[string]error)}
+	for _, item := range out.Successful {
+		result.Successful[item.Id] = item.MessageId
+	}
+	for _, item := range out.Failed {
+		result.Failed[item.Id] = &Error{Code: item.Code, Message: item.Message}
+	}
+	return result, nil
+}
+
+// ReceiveMessage 长轮询接收消息
+func (c *SqsClient) ReceiveMessage(ctx context.Context, queueUrl string, maxNumber int, waitTime, visibilityTimeout time.Duration) ([]Message, error) {
+	params := url.Values{}
+	params.Set("Action", "ReceiveMessage")
+	params.Set("Version", sqsApiVersion)
+	params.Set("QueueUrl", queueUrl)
+	params.Set("MaxNumberOfMessages", strconv.Itoa(maxNumber))
+	params.Set("WaitTimeSeconds", strconv.Itoa(int(waitTime/time.Second)))
+	if visibilityTimeout > 0 {
+		params.Set("VisibilityTimeout", strconv.Itoa(int(visibilityTimeout/time.Second)))
+	}
+	params.Set("MessageAttributeName.1", "All")
+	var out struct {
+		Messages []struct {
+			MessageId         string
+			ReceiptHandle     string
+			Body              string
+			MessageAttributes []struct {
+				Name        string
+				StringValue string `xml:"Value>StringValue"`
+			} `xml:"MessageAttribute"`
+		} `xml:"ReceiveMessageResult>Message"`
+	}
+	if err := c.Do(ctx, params, &out); err != nil {
+		return nil, err
+	}
+	var msgs []Message
+	for _, item := range out.Messages {
+		msg := Message{Id: item.MessageId, Body: item.Body, ReceiptHandle: item.ReceiptHandle, Attributes: make(map[string]string)}
+		for _, attr := range item.MessageAttributes {
+			msg.Attributes[attr.Name] = attr.StringValue
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// DeleteMessage 删除(确认)消息
+func (c *SqsClient) DeleteMessage(ctx context.Context, queueUrl string, receiptHandle string) error {
+	params := url.Values{}
+	params.Set("Action", "DeleteMessage")
+	params.Set("Version", sqsApiVersion)
+	params.Set("QueueUrl", queueUrl)
+	params.Set("ReceiptHandle", receiptHandle)
+	return c.Do(ctx, params, nil)
+}
+
+// ChangeMessageVisibility 修改消息可见性超时，0表示立即重新投递
+func (c *SqsClient) ChangeMessageVisibility(ctx context.Context, queueUrl string, receiptHandle string, visibilityTimeout time.Duration) error {
+	params := url.Values{}
+	params.Set("Action", "ChangeMessageVisibility")
+	params.Set("Version", sqsApiVersion)
+	params.Set("QueueUrl", queueUrl)
+	params.Set("ReceiptHandle", receiptHandle)
+	params.Set("VisibilityTimeout", strconv.Itoa(int(visibilityTimeout/time.Second)))
+	return c.Do(ctx, params, nil)
+}
+
+// SnsClient SNS客户端
+type SnsClient struct {
+	Client
+}
+
+// NewSnsClient 创建SNS客户端，endpoint为空则使用区域默认地址
+func NewSnsClient(region, endpoint string, credentials CredentialsProvider, timeout time.Duration) *SnsClient {
+	if endpoint == "" {
+		endpoint = "https://sns." + region + ".amazonaws.com/"
+	}
+	return &SnsClient{Client{Endpoint: endpoint, Region: region, Service: "sns", Credentials: credentials,
+		HttpClient: &http.Client{Timeout: timeout}}}
+}
+
+// Publish 发布一条消息到主题，返回消息ID
+func (c *SnsClient) Publish(ctx context.Context, topicArn string, msg Message) (string, error) {
+	params := url.Values{}
+	params.Set("Action", "Publish")
+	params.Set("Version", snsApiVersion)
+	params.Set("TopicArn", topicArn)
+	setSnsMessage(params, "", msg)
+	var out struct {
+		MessageId string `xml:"PublishResult>MessageId"`
+	}
+	err := c.Do(ctx, params, &out)
+	return out.MessageId, err
+}
+
+// PublishBatch 批量发布消息，每批最多MaxBatchSize条
+func (c *SnsClient) PublishBatch(ctx context.Context, topicArn string, msgs []Message) (BatchResult, error) {
+	params := url.Values{}
+	params.Set("Action", "PublishBatch")
+	params.Set("Version", snsApiVersion)
+	params.Set("TopicArn", topicArn)
+	for i, msg := range msgs {
+		prefix := fmt.Sprintf("PublishBatchRequestEntries.member.%d.", i+1)
+		params.Set(prefix+"Id", msg.Id)
+		setSnsMessage(params, prefix, msg)
+	}
+	var out struct {
+		Successful []struct {
+			Id        string
+			MessageId string
+		} `xml:"PublishBatchResult>Successful>member"`
+		Failed []batchErrorEntry `xml:"PublishBatchResult>Failed>member"`
+	}
+	if err := c.Do(ctx, params, &out); err != nil {
+		return BatchResult{}, err
+	}
+	result := BatchResult{Successful: make(map[string]string), Failed: make(map[string]error)}
+	for _, item := range out.Successful {
+		result.Successful[item.Id] = item.MessageId
+	}
+	for _, item := range out.Failed {
+		result.Failed[item.Id] = &Error{Code: item.Code, Message: item.Message}
+	}
+	return result, nil
+}
+
+type batchErrorEntry struct {
+	Id      string
+	Code    string
+	Message string
+}
+
+func setSqsMessage(params url.Values, prefix string, msg Message) {
+	params.Set(prefix+"MessageBody", msg.Body)
+	if msg.GroupId != "" {
+		params.Set(prefix+"MessageGroupId", msg.GroupId)
+	}
+	if msg.DeduplicationId != "" {
+		params.Set(prefix+"MessageDeduplicationId", msg.DeduplicationId)
+	}
+	for i, k := range sortedKeys(msg.Attributes) {
+		attrPrefix := fmt.Sprintf("%sMessageAttribute.%d.", prefix, i+1)
+		params.Set(attrPrefix+"Name", k)
+		params.Set(attrPrefix+"Value.DataType", "String")
+		params.Set(attrPrefix+"Value.StringValue", msg.Attributes[k])
+	}
+}
+
+func setSnsMessage(params url.Values, prefix string, msg Message) {
+	params.Set(prefix+"Message", msg.Body)
+	if msg.GroupId != "" {
+		params.Set(prefix+"MessageGroupId", msg.GroupId)
+	}
+	if msg.DeduplicationId != "" {
+		params.Set(prefix+"MessageDeduplicationId", msg.DeduplicationId)
+	}
+	for i, k := range sortedKeys(msg.Attributes) {
+		attrPrefix := fmt.Sprintf("%sMessageAttributes.entry.%d.", prefix, i+1)
+		params.Set(attrPrefix+"Name", k)
+		params.Set(attrPrefix+"Value.DataType", "String")
+		params.Set(attrPrefix+"Value.StringValue", msg.Attributes[k])
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k, v := range m {
+		//属性值不能为空
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
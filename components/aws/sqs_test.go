@@ -0,0 +1,88 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aws
+
+import (
+	"context"
+	"github.com/2018yuli/rulego/test/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSqsClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ak/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Form.Get("Action") {
+		case "SendMessage":
+			assert.Equal(t, "hello", r.Form.Get("MessageBody"))
+			assert.Equal(t, "deviceId", r.Form.Get("MessageAttribute.1.Name"))
+			assert.Equal(t, "aa", r.Form.Get("MessageAttribute.1.Value.StringValue"))
+			_, _ = w.Write([]byte(`<SendMessageResponse><SendMessageResult><MessageId>m1</MessageId></SendMessageResult></SendMessageResponse>`))
+		case "SendMessageBatch":
+			assert.Equal(t, "1", r.Form.Get("SendMessageBatchRequestEntry.2.Id"))
+			_, _ = w.Write([]byte(`<SendMessageBatchResponse><SendMessageBatchResult>
+<SendMessageBatchResultEntry><Id>0</Id><MessageId>m1</MessageId></SendMessageBatchResultEntry>
+<BatchResultErrorEntry><Id>1</Id><Code>InvalidParameterValue</Code><Message>bad</Message></BatchResultErrorEntry>
+</SendMessageBatchResult></SendMessageBatchResponse>`))
+		case "ReceiveMessage":
+			_, _ = w.Write([]byte(`<ReceiveMessageResponse><ReceiveMessageResult><Message>
+<MessageId>m1</MessageId><ReceiptHandle>h1</ReceiptHandle><Body>hello</Body>
+<MessageAttribute><Name>deviceId</Name><Value><DataType>String</DataType><StringValue>aa</StringValue></Value></MessageAttribute>
+</Message></ReceiveMessageResult></ReceiveMessageResponse>`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>InvalidAction</Code><Message>unknown action</Message></Error></ErrorResponse>`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewSqsClient("us-east-1", server.URL, NewCredentialsProvider("ak", "sk", ""), time.Second)
+	queueUrl := server.URL + "/123456789012/rulego"
+
+	messageId, err := client.SendMessage(context.Background(), queueUrl, Message{Body: "hello", Attributes: map[string]string{"deviceId": "aa", "empty": ""}})
+	assert.Nil(t, err)
+	assert.Equal(t, "m1", messageId)
+
+	result, err := client.SendMessageBatch(context.Background(), queueUrl, []Message{{Id: "0", Body: "a"}, {Id: "1", Body: "b"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "m1", result.Successful["0"])
+	assert.Equal(t, "InvalidParameterValue: bad", result.Failed["1"].Error())
+
+	msgs, err := client.ReceiveMessage(context.Background(), queueUrl, 10, time.Second, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(msgs))
+	assert.Equal(t, "hello", msgs[0].Body)
+	assert.Equal(t, "h1", msgs[0].ReceiptHandle)
+	assert.Equal(t, "aa", msgs[0].Attributes["deviceId"])
+
+	err = client.DeleteMessage(context.Background(), queueUrl, "h1")
+	assert.NotNil(t, err)
+	assert.Equal(t, "InvalidAction: unknown action", err.Error())
+
+	//没有凭证
+	client = NewSqsClient("us-east-1", server.URL, NewCredentialsProvider("", "", ""), time.Second)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	_, err = client.SendMessage(context.Background(), queueUrl, Message{Body: "hello"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,345 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//BlocklistMatch 命中黑名单的关系类型
+	BlocklistMatch = "Match"
+	//BlocklistNoMatch 没有命中黑名单的关系类型
+	BlocklistNoMatch = "NoMatch"
+)
+
+// 黑名单值类型
+const (
+	BlocklistTypeIp     = "ip"
+	BlocklistTypeDomain = "domain"
+	BlocklistTypeHash   = "hash"
+)
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s1",
+//	       "type": "blocklist",
+//	       "name": "恶意IP检查",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "value": "${srcIp}",
+//	         "valueType": "ip",
+//	         "sources": ["https://iplists.firehol.org/files/firehol_level1.netset"],
+//	         "refreshIntervalMs": 3600000,
+//	         "cacheDir": "./blocklist"
+//	       }
+//	     }
+func init() {
+	Registry.Add(&BlocklistNode{})
+}
+
+// BlocklistNodeConfiguration 节点配置
+type BlocklistNodeConfiguration struct {
+	//Value 需要检查的值，可以使用 ${metaKeyName} 替换元数据中的变量，默认：${ip}
+	Value string
+	//ValueType 值类型：ip、domain、hash，默认ip
+	//ip：支持单个IP和CIDR网段；domain：子域名也会命中；hash：忽略大小写
+	ValueType string
+	//Sources 黑名单来源，支持http(s) URL和本地文件路径
+	//每行一个条目，忽略空行和#、;开头的注释，兼容hosts文件格式
+	Sources []string
+	//Entries 直接配置的黑名单条目
+	Entries []string
+	//RefreshIntervalMs 刷新来源的间隔，单位毫秒，<=0则不刷新，默认1小时
+	RefreshIntervalMs int
+	//TimeoutMs 下载来源的超时，单位毫秒，默认30000
+	TimeoutMs int
+	//CacheDir 缓存下载内容的目录，下载失败时使用缓存，为空则不缓存
+	CacheDir string
+}
+
+// blocklist 黑名单条目集合
+type blocklist struct {
+	//values 条目->来源
+	values map[string]string
+	//networks 网段列表
+	networks []*net.IPNet
+	//networkSources 网段对应的来源
+	networkSources []string
+}
+
+// BlocklistNode 检查IP、域名或者文件hash是否在本地缓存的黑名单中
+// 黑名单从配置的URL或者文件加载，并定时刷新，刷新失败继续使用之前的黑名单
+// 命中发送消息到`Match`链，并在元数据设置blocklistEntry(命中的条目)和blocklistSource(来源)
+// 否则发送消息到`NoMatch`链
+type BlocklistNode struct {
+	config     BlocklistNodeConfiguration
+	ruleConfig types.Config
+	httpClient *http.Client
+	list       *blocklist
+	lock       sync.RWMutex
+	stop       chan struct{}
+}
+
+// Type 组件类型
+func (x *BlocklistNode) Type() string {
+	return "blocklist"
+}
+
+// Descriptor 组件描述
+func (x *BlocklistNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"security", "ip", "dns"},
+		Description: "检查IP、域名或者文件hash是否在黑名单中",
+	}
+}
+
+func (x *BlocklistNode) New() types.Node {
+	return &BlocklistNode{config: BlocklistNodeConfiguration{
+		Value:             "${ip}",
+		ValueType:         BlocklistTypeIp,
+		RefreshIntervalMs: 3600000,
+		TimeoutMs:         30000,
+	}}
+}
+
+// Init 初始化
+func (x *BlocklistNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err != nil {
+		return err
+	}
+	x.config.ValueType = strings.ToLower(x.config.ValueType)
+	switch x.config.ValueType {
+	case BlocklistTypeIp, BlocklistTypeDomain, BlocklistTypeHash:
+	default:
+		return errors.New("unsupported valueType:" + x.config.ValueType)
+	}
+	if len(x.config.Sources) == 0 && len(x.config.Entries) == 0 {
+		return errors.New("sources or entries must be configured")
+	}
+	if x.config.TimeoutMs <= 0 {
+		x.config.TimeoutMs = 30000
+	}
+	x.ruleConfig = ruleConfig
+	x.httpClient = &http.Client{Timeout: time.Duration(x.config.TimeoutMs) * time.Millisecond}
+	if err = x.Refresh(); err != nil {
+		return err
+	}
+	if x.config.RefreshIntervalMs > 0 && len(x.config.Sources) > 0 {
+		x.stop = make(chan struct{})
+		go x.refreshLoop(time.Duration(x.config.RefreshIntervalMs)*time.Millisecond, x.stop)
+	}
+	return nil
+}
+
+// OnMsg 处理消息
+func (x *BlocklistNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	value := strings.TrimSpace(str.SprintfDict(x.config.Value, msg.Metadata.Values()))
+	x.lock.RLock()
+	list := x.list
+	x.lock.RUnlock()
+	if entry, source, ok := x.match(list, value); ok {
+		msg.Metadata.PutValue("blocklistEntry", entry)
+		msg.Metadata.PutValue("blocklistSource", source)
+		ctx.TellNext(msg, BlocklistMatch)
+	} else {
+		ctx.TellNext(msg, BlocklistNoMatch)
+	}
+	return nil
+}
+
+// Destroy 销毁
+func (x *BlocklistNode) Destroy() {
+	if x.stop != nil {
+		close(x.stop)
+		x.stop = nil
+	}
+}
+
+// Refresh 重新加载所有来源，如果某个来源加载失败并且没有缓存，则返回错误，并继续使用之前的黑名单
+func (x *BlocklistNode) Refresh() error {
+	list := &blocklist{values: make(map[string]string)}
+	for _, entry := range x.config.Entries {
+		x.add(list, entry, "entries")
+	}
+	for _, source := range x.config.Sources {
+		content, err := x.load(source)
+		if err != nil {
+			return fmt.Errorf("load blocklist %s error:%w", source, err)
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(content)))
+		for scanner.Scan() {
+			if entry := parseBlocklistLine(scanner.Text()); entry != "" {
+				x.add(list, entry, source)
+			}
+		}
+	}
+	x.lock.Lock()
+	x.list = list
+	x.lock.Unlock()
+	return nil
+}
+
+func (x *BlocklistNode) refreshLoop(interval time.Duration, stop chan struct{}) {
+	ticker := x.ruleConfig.GetClock().NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+			if err := x.Refresh(); err != nil && x.ruleConfig.Logger != nil {
+				x.ruleConfig.Logger.Printf("refresh blocklist error:%v", err)
+			}
+		}
+	}
+}
+
+// load 加载来源内容，http(s)来源下载成功后写入缓存，下载失败则读取缓存
+func (x *BlocklistNode) load(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+	content, err := x.download(source)
+	cacheFile := x.cacheFile(source)
+	if err != nil {
+		if cacheFile != "" {
+			if cached, cacheErr := os.ReadFile(cacheFile); cacheErr == nil {
+				return cached, nil
+			}
+		}
+		return nil, err
+	}
+	if cacheFile != "" {
+		if mkErr := os.MkdirAll(x.config.CacheDir, 0755); mkErr == nil {
+			_ = os.WriteFile(cacheFile, content, 0644)
+		}
+	}
+	return content, nil
+}
+
+func (x *BlocklistNode) download(url string) ([]byte, error) {
+	resp, err := x.httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (x *BlocklistNode) cacheFile(source string) string {
+	if x.config.CacheDir == "" {
+		return ""
+	}
+	sum := sha1.Sum([]byte(source))
+	return filepath.Join(x.config.CacheDir, hex.EncodeToString(sum[:])+".txt")
+}
+
+func (x *BlocklistNode) add(list *blocklist, entry, source string) {
+	switch x.config.ValueType {
+	case BlocklistTypeIp:
+		if strings.Contains(entry, "/") {
+			if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+				list.networks = append(list.networks, ipNet)
+				list.networkSources = append(list.networkSources, source)
+			}
+			return
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			list.values[ip.String()] = source
+		}
+	default:
+		list.values[strings.ToLower(strings.TrimSuffix(entry, "."))] = source
+	}
+}
+
+// match 检查值是否命中黑名单，返回命中的条目和来源
+func (x *BlocklistNode) match(list *blocklist, value string) (string, string, bool) {
+	if list == nil || value == "" {
+		return "", "", false
+	}
+	switch x.config.ValueType {
+	case BlocklistTypeIp:
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return "", "", false
+		}
+		if source, ok := list.values[ip.String()]; ok {
+			return ip.String(), source, true
+		}
+		for i, ipNet := range list.networks {
+			if ipNet.Contains(ip) {
+				return ipNet.String(), list.networkSources[i], true
+			}
+		}
+	case BlocklistTypeDomain:
+		//依次检查域名和上级域名
+		domain := strings.ToLower(strings.TrimSuffix(value, "."))
+		for domain != "" {
+			if source, ok := list.values[domain]; ok {
+				return domain, source, true
+			}
+			index := strings.Index(domain, ".")
+			if index < 0 {
+				break
+			}
+			domain = domain[index+1:]
+		}
+	default:
+		hash := strings.ToLower(value)
+		if source, ok := list.values[hash]; ok {
+			return hash, source, true
+		}
+	}
+	return "", "", false
+}
+
+// parseBlocklistLine 解析一行黑名单，返回条目，空行和注释返回空
+// 兼容hosts文件格式，例如：0.0.0.0 example.com
+func parseBlocklistLine(line string) string {
+	if index := strings.IndexAny(line, "#;"); index >= 0 {
+		line = line[:index]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	if len(fields) > 1 && (fields[0] == "0.0.0.0" || fields[0] == "127.0.0.1") {
+		return fields[1]
+	}
+	return fields[0]
+}
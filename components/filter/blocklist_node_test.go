@@ -0,0 +1,130 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func blocklistCheck(t *testing.T, node types.Node, key, value, expected string) types.RuleMsg {
+	var relation string
+	var result types.RuleMsg
+	ctx := test.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string) {
+		relation = relationType
+		result = msg
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue(key, value)
+	err := node.OnMsg(ctx, ctx.NewMsg("TEST_MSG_TYPE_AA", metaData, "{}"))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, relation)
+	return result
+}
+
+func TestBlocklistNodeIp(t *testing.T) {
+	var available int32 = 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&available) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("# firehol\n1.2.3.4\n10.0.0.0/8 ; private\n\n"))
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	configuration := types.Configuration{
+		"value":             "${srcIp}",
+		"sources":           []string{server.URL},
+		"entries":           []string{"5.6.7.8"},
+		"refreshIntervalMs": 0,
+		"cacheDir":          cacheDir,
+	}
+	node := (&BlocklistNode{}).New()
+	err := node.Init(types.NewConfig(), configuration)
+	assert.Nil(t, err)
+	defer node.Destroy()
+
+	msg := blocklistCheck(t, node, "srcIp", "1.2.3.4", BlocklistMatch)
+	assert.Equal(t, "1.2.3.4", msg.Metadata.GetValue("blocklistEntry"))
+	assert.Equal(t, server.URL, msg.Metadata.GetValue("blocklistSource"))
+	msg = blocklistCheck(t, node, "srcIp", "10.1.2.3", BlocklistMatch)
+	assert.Equal(t, "10.0.0.0/8", msg.Metadata.GetValue("blocklistEntry"))
+	msg = blocklistCheck(t, node, "srcIp", "5.6.7.8", BlocklistMatch)
+	assert.Equal(t, "entries", msg.Metadata.GetValue("blocklistSource"))
+	blocklistCheck(t, node, "srcIp", "1.2.3.5", BlocklistNoMatch)
+	blocklistCheck(t, node, "srcIp", "aa", BlocklistNoMatch)
+
+	//下载失败，使用缓存
+	atomic.StoreInt32(&available, 0)
+	node2 := (&BlocklistNode{}).New()
+	err = node2.Init(types.NewConfig(), configuration)
+	assert.Nil(t, err)
+	blocklistCheck(t, node2, "srcIp", "1.2.3.4", BlocklistMatch)
+
+	//下载失败并且没有缓存
+	configuration["cacheDir"] = ""
+	err = (&BlocklistNode{}).New().Init(types.NewConfig(), configuration)
+	assert.NotNil(t, err)
+
+	//刷新失败，继续使用之前的黑名单
+	assert.Nil(t, os.RemoveAll(cacheDir))
+	err = node.(*BlocklistNode).Refresh()
+	assert.NotNil(t, err)
+	blocklistCheck(t, node, "srcIp", "1.2.3.4", BlocklistMatch)
+}
+
+func TestBlocklistNodeDomainAndHash(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hosts")
+	err := os.WriteFile(file, []byte("0.0.0.0 evil.com\n127.0.0.1 Bad.Example.org\n"), 0644)
+	assert.Nil(t, err)
+
+	node := (&BlocklistNode{}).New()
+	err = node.Init(types.NewConfig(), types.Configuration{
+		"value":     "${domain}",
+		"valueType": "domain",
+		"sources":   []string{file},
+	})
+	assert.Nil(t, err)
+	defer node.Destroy()
+	msg := blocklistCheck(t, node, "domain", "cdn.evil.com", BlocklistMatch)
+	assert.Equal(t, "evil.com", msg.Metadata.GetValue("blocklistEntry"))
+	blocklistCheck(t, node, "domain", "bad.example.org.", BlocklistMatch)
+	blocklistCheck(t, node, "domain", "example.org", BlocklistNoMatch)
+	blocklistCheck(t, node, "domain", "notevil.com", BlocklistNoMatch)
+
+	node = (&BlocklistNode{}).New()
+	err = node.Init(types.NewConfig(), types.Configuration{
+		"value":     "${sha256}",
+		"valueType": "hash",
+		"entries":   []string{"E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855"},
+	})
+	assert.Nil(t, err)
+	blocklistCheck(t, node, "sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", BlocklistMatch)
+	blocklistCheck(t, node, "sha256", "aa", BlocklistNoMatch)
+
+	assert.NotNil(t, (&BlocklistNode{}).New().Init(types.NewConfig(), types.Configuration{"valueType": "xx", "entries": []string{"a"}}))
+	assert.NotNil(t, (&BlocklistNode{}).New().Init(types.NewConfig(), types.Configuration{}))
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+//规则链节点配置示例：
+//{
+//        "id": "s3",
+//        "type": "experiment",
+//        "name": "新告警算法灰度",
+//        "configuration": {
+//          "experiment": "alarmV2",
+//          "key": "${deviceId}",
+//          "variants": [{"name": "control", "weight": 9}, {"name": "treatment", "weight": 1}]
+//        }
+//      }
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"strconv"
+)
+
+// 实验分组元数据key
+const (
+	//experimentKey 实验名称
+	experimentKey = "experiment"
+	//experimentVariantKey 分配的分组
+	experimentVariantKey = "variant"
+	//experimentAssignedAtKey 首次分配的时间，毫秒时间戳
+	experimentAssignedAtKey = "variantAssignedAt"
+	//experimentNewAssignmentKey 本条消息是否产生了新的分配，值为true/false，用于下游记录分配情况
+	experimentNewAssignmentKey = "newAssignment"
+)
+
+func init() {
+	Registry.Add(&ExperimentNode{})
+}
+
+// ExperimentVariant 实验分组
+type ExperimentVariant struct {
+	//Name 分组名称，同时作为关系类型
+	Name string
+	//Weight 流量比例权重，默认1，0表示不再分配新的key
+	Weight *int
+}
+
+// ExperimentNodeConfiguration 节点配置
+type ExperimentNodeConfiguration struct {
+	//Experiment 实验名称，不同实验对相同key的分配互相独立
+	Experiment string
+	//Key 分组依据，可以使用 ${metaKeyName} 替换元数据中的变量，例如：${deviceId}
+	Key string
+	//Variants 分组列表
+	Variants []ExperimentVariant
+}
+
+// experimentAssignment 保存在状态存储的分配记录
+type experimentAssignment struct {
+	Variant    string `json:"variant"`
+	AssignedAt int64  `json:"assignedAt"`
+}
+
+// ExperimentNode A/B实验节点，按key把消息分配到实验分组，关系类型为分组名称
+// 首次分配按权重哈希确定，并通过`Config.StateStore`保存，之后调整比例也不会改变已分配key的分组
+// 元数据experiment、variant、variantAssignedAt记录分配结果，newAssignment=true表示首次分配
+// key为空的消息发送到`Failure`链
+type ExperimentNode struct {
+	config  ExperimentNodeConfiguration
+	names   []string
+	weights []int
+	clock   clock.Clock
+}
+
+// Type 组件类型
+func (x *ExperimentNode) Type() string {
+	return "experiment"
+}
+
+// Descriptor 组件描述
+func (x *ExperimentNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"switch", "experiment", "abTest"},
+		Description: "A/B实验，按key粘性分配到实验分组并路由到对应分支",
+	}
+}
+
+func (x *ExperimentNode) New() types.Node {
+	return &ExperimentNode{}
+}
+
+// Init 初始化
+func (x *ExperimentNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.config); err != nil {
+		return err
+	}
+	if x.config.Experiment == "" {
+		return errors.New("experiment can not empty")
+	}
+	if x.config.Key == "" {
+		return errors.New("key can not empty")
+	}
+	total := 0
+	for _, variant := range x.config.Variants {
+		if variant.Name == "" {
+			return errors.New("variant name can not empty")
+		}
+		weight := 1
+		if variant.Weight != nil {
+			weight = *variant.Weight
+		}
+		if weight < 0 {
+			return fmt.Errorf("variant %s weight can not be negative", variant.Name)
+		}
+		x.names = append(x.names, variant.Name)
+		x.weights = append(x.weights, weight)
+		total += weight
+	}
+	if total == 0 {
+		return errors.New("variants can not empty")
+	}
+	x.clock = ruleConfig.GetClock()
+	return nil
+}
+
+// MetadataOnly 只使用元数据分组，不需要加载转存到BlobStore的消息内容
+func (x *ExperimentNode) MetadataOnly() bool {
+	return true
+}
+
+// OnMsg 处理消息
+func (x *ExperimentNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	key := str.SprintfDict(x.config.Key, msg.Metadata.Values())
+	if key == "" {
+		ctx.TellFailure(msg, errors.New("experiment key is empty"))
+		return nil
+	}
+	stateKey := x.config.Experiment + ":" + key
+	assignment, ok, err := x.load(ctx, stateKey)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return nil
+	}
+	if !ok {
+		assignment = experimentAssignment{
+			Variant:    rendezvousHash(stateKey, x.names, x.weights),
+			AssignedAt: x.clock.Now().UnixMilli(),
+		}
+		value, _ := json.Marshal(assignment)
+		if err = ctx.SetState(stateKey, string(value)); err != nil {
+			ctx.TellFailure(msg, err)
+			return nil
+		}
+	}
+	msg.Metadata.PutValue(experimentKey, x.config.Experiment)
+	msg.Metadata.PutValue(experimentVariantKey, assignment.Variant)
+	msg.Metadata.PutValue(experimentAssignedAtKey, strconv.FormatInt(assignment.AssignedAt, 10))
+	msg.Metadata.PutValue(experimentNewAssignmentKey, strconv.FormatBool(!ok))
+	ctx.TellNext(msg, assignment.Variant)
+	return nil
+}
+
+// Destroy 销毁
+func (x *ExperimentNode) Destroy() {
+}
+
+// load 加载已有的分配，分组已经从配置删除则视为未分配
+func (x *ExperimentNode) load(ctx types.RuleContext, stateKey string) (experimentAssignment, bool, error) {
+	var assignment experimentAssignment
+	value, ok, err := ctx.GetState(stateKey)
+	if err != nil || !ok {
+		return assignment, false, err
+	}
+	if err = json.Unmarshal([]byte(value), &assignment); err != nil {
+		return assignment, false, nil
+	}
+	for _, name := range x.names {
+		if name == assignment.Variant {
+			return assignment, true, nil
+		}
+	}
+	return assignment, false, nil
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import (
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/state"
+	"testing"
+	"time"
+)
+
+func TestExperimentNode(t *testing.T) {
+	store := state.NewMemory()
+	config := types.NewConfig(types.WithStateStore(store), types.WithClock(clock.NewVirtual(time.UnixMilli(1700000000000))))
+	newNode := func(variants ...interface{}) types.Node {
+		node := (&ExperimentNode{}).New()
+		assert.Nil(t, node.Init(config, types.Configuration{"experiment": "alarmV2", "key": "${deviceId}", "variants": variants}))
+		return node
+	}
+	var last types.RuleMsg
+	var relation string
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		last = msg
+		relation = relationType
+	})
+	send := func(node types.Node, deviceId string) string {
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceId", deviceId)
+		assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", metadata, "")))
+		return relation
+	}
+
+	node := newNode(map[string]interface{}{"name": "control", "weight": 3}, map[string]interface{}{"name": "treatment", "weight": 1})
+	assigned := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		deviceId := fmt.Sprintf("device%d", i)
+		variant := send(node, deviceId)
+		assert.Equal(t, variant, last.Metadata.GetValue(experimentVariantKey))
+		assert.Equal(t, "alarmV2", last.Metadata.GetValue(experimentKey))
+		assert.Equal(t, "true", last.Metadata.GetValue(experimentNewAssignmentKey))
+		assert.Equal(t, "1700000000000", last.Metadata.GetValue(experimentAssignedAtKey))
+		assigned[deviceId] = variant
+		counts[variant]++
+	}
+	assert.True(t, counts["control"] > 650 && counts["control"] < 850)
+
+	//再次发送保持原有分组
+	assert.Equal(t, assigned["device1"], send(node, "device1"))
+	assert.Equal(t, "false", last.Metadata.GetValue(experimentNewAssignmentKey))
+
+	//调整比例不影响已分配的key，重启后通过状态存储恢复
+	node = newNode(map[string]interface{}{"name": "control", "weight": 0}, map[string]interface{}{"name": "treatment", "weight": 1})
+	for i := 0; i < 100; i++ {
+		deviceId := fmt.Sprintf("device%d", i)
+		assert.Equal(t, assigned[deviceId], send(node, deviceId))
+	}
+	assert.Equal(t, "treatment", send(node, "newDevice"))
+
+	//分组删除后重新分配
+	node = newNode(map[string]interface{}{"name": "treatment"}, map[string]interface{}{"name": "v3"})
+	for i := 0; i < 100; i++ {
+		deviceId := fmt.Sprintf("device%d", i)
+		variant := send(node, deviceId)
+		if assigned[deviceId] == "treatment" {
+			assert.Equal(t, "treatment", variant)
+		} else {
+			assert.Equal(t, "true", last.Metadata.GetValue(experimentNewAssignmentKey))
+		}
+	}
+
+	//key为空
+	send(node, "")
+	assert.Equal(t, types.Failure, relation)
+}
+
+func TestExperimentNodeInit(t *testing.T) {
+	config := types.NewConfig()
+	for _, configuration := range []types.Configuration{
+		{"key": "${deviceId}", "variants": []interface{}{map[string]interface{}{"name": "a"}}},
+		{"experiment": "e", "variants": []interface{}{map[string]interface{}{"name": "a"}}},
+		{"experiment": "e", "key": "${deviceId}"},
+		{"experiment": "e", "key": "${deviceId}", "variants": []interface{}{map[string]interface{}{"name": "a", "weight": -1}}},
+		{"experiment": "e", "key": "${deviceId}", "variants": []interface{}{map[string]interface{}{"name": "a", "weight": 0}}},
+	} {
+		assert.NotNil(t, (&ExperimentNode{}).New().Init(config, configuration))
+	}
+}
@@ -50,6 +50,14 @@ func (x *FieldFilterNode) Type() string {
 	return "fieldFilter"
 }
 
+// Descriptor 组件描述
+func (x *FieldFilterNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"field"},
+		Description: "按msg字段或者元数据字段是否存在过滤消息",
+	}
+}
+
 func (x *FieldFilterNode) New() types.Node {
 	return &FieldFilterNode{}
 }
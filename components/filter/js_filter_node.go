@@ -27,7 +27,6 @@ package filter
 //        }
 //      }
 import (
-	"fmt"
 	"github.com/2018yuli/rulego/api/types"
 	"github.com/2018yuli/rulego/components/js"
 	"github.com/2018yuli/rulego/utils/json"
@@ -44,6 +43,9 @@ type JsFilterNodeConfiguration struct {
 	// 使用js脚本进行过滤
 	//完整脚本函数：
 	//function Filter(msg, metadata, msgType) { ${JsScript} }
+	//脚本使用了await则声明为async函数，可以使用fetch访问http接口，参考`types.Config.JsFetch`
+	//脚本可以通过state.get/put读写当前节点的状态，通过metrics.inc/observe记录指标，
+	//store.get/put为跨规则链共享的存储，不是节点状态，参考js包说明
 	//return bool
 	JsScript string
 }
@@ -64,6 +66,14 @@ func (x *JsFilterNode) Type() string {
 	return "jsFilter"
 }
 
+// Descriptor 组件描述
+func (x *JsFilterNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"js", "script"},
+		Description: "使用JS脚本过滤消息",
+	}
+}
+
 func (x *JsFilterNode) New() types.Node {
 	return &JsFilterNode{}
 }
@@ -72,7 +82,7 @@ func (x *JsFilterNode) New() types.Node {
 func (x *JsFilterNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &x.config)
 	if err == nil {
-		jsScript := fmt.Sprintf("function Filter(msg, metadata, msgType) { %s }", x.config.JsScript)
+		jsScript := js.FuncScript("Filter", "msg, metadata, msgType", x.config.JsScript)
 		x.jsEngine = js.NewGojaJsEngine(ruleConfig, jsScript, nil)
 	}
 	return err
@@ -88,7 +98,7 @@ func (x *JsFilterNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
 		}
 	}
 
-	out, err := x.jsEngine.Execute("Filter", data, msg.Metadata.Values(), msg.Type)
+	out, err := js.Execute(ctx, x.jsEngine, "Filter", data, msg.Metadata.Values(), msg.Type)
 	if err != nil {
 		ctx.TellFailure(msg, err)
 		return err
@@ -102,6 +112,14 @@ func (x *JsFilterNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
 	}
 }
 
+// Metrics 实现types.MetricsProvider，返回脚本通过metrics对象记录的指标
+func (x *JsFilterNode) Metrics() map[string]interface{} {
+	if x.jsEngine == nil {
+		return nil
+	}
+	return js.Metrics(x.jsEngine)
+}
+
 // Destroy 销毁
 func (x *JsFilterNode) Destroy() {
 	x.jsEngine.Stop()
@@ -20,6 +20,7 @@ import (
 	"github.com/2018yuli/rulego/api/types"
 	"github.com/2018yuli/rulego/test"
 	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/kv"
 	"testing"
 )
 
@@ -57,3 +58,55 @@ func TestJsFilterNodeOnMsg(t *testing.T) {
 		t.Errorf("err=%s", err)
 	}
 }
+
+func TestJsFilterNodeStore(t *testing.T) {
+	config := types.NewConfig(types.WithKVStore(kv.NewMemory(nil)), types.WithTenant("tenant01"))
+	_ = config.KVStore.Put("tenant01", "enabled_aa", "true", 0)
+	var node JsFilterNode
+	var configuration = make(types.Configuration)
+	configuration["jsScript"] = `
+		var count = store.get('count') || 0;
+		store.put('count', count * 1 + 1);
+		return store.get('enabled_' + metadata.deviceId) === 'true';
+  	`
+	err := node.Init(config, configuration)
+	assert.Nil(t, err)
+
+	var relations []string
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		relations = append(relations, relationType)
+	})
+	metaData := types.NewMetadata()
+	metaData.PutValue("deviceId", "aa")
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", metaData, "{}")))
+	metaData.PutValue("deviceId", "bb")
+	assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", metaData, "{}")))
+	assert.Equal(t, []string{types.True, types.False}, relations)
+
+	v, _, _ := config.KVStore.Get("tenant01", "count")
+	assert.Equal(t, "2", v)
+}
+
+func TestJsFilterNodeStateAndMetrics(t *testing.T) {
+	var node JsFilterNode
+	config := types.NewConfig()
+	assert.Nil(t, node.Init(config, types.Configuration{
+		"jsScript": "const n = Number(state.get('n') || 0) + 1; state.put('n', String(n)); metrics.inc('calls', 2); metrics.observe('temperature', msg.temperature); return n > 1;",
+	}))
+	assert.Equal(t, 0, len(node.Metrics()))
+	var results []string
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		results = append(results, relationType)
+	})
+	for _, temperature := range []string{"20", "40"} {
+		assert.Nil(t, node.OnMsg(ctx, types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), `{"temperature":`+temperature+`}`)))
+	}
+	assert.Equal(t, []string{types.False, types.True}, results)
+	metrics := node.Metrics()
+	assert.Equal(t, float64(4), metrics["calls"])
+	assert.Equal(t, map[string]interface{}{"count": int64(2), "sum": float64(60), "min": float64(20), "max": float64(40)}, metrics["temperature"])
+
+	//没有规则上下文不能访问节点状态
+	_, err := node.jsEngine.Execute("Filter", map[string]interface{}{}, map[string]string{}, "TEST")
+	assert.NotNil(t, err)
+}
@@ -28,7 +28,6 @@ package filter
 //      }
 import (
 	"errors"
-	"fmt"
 	"github.com/2018yuli/rulego/api/types"
 	"github.com/2018yuli/rulego/components/js"
 	"github.com/2018yuli/rulego/utils/json"
@@ -42,6 +41,11 @@ func init() {
 
 // JsSwitchNodeConfiguration 节点配置
 type JsSwitchNodeConfiguration struct {
+	//JsScript 配置函数体脚本内容，完整脚本函数：
+	//function Switch(msg, metadata, msgType) { ${JsScript} }
+	//脚本使用了await则声明为async函数，可以使用fetch访问http接口，参考`types.Config.JsFetch`
+	//脚本可以通过state.get/put读写当前节点的状态，通过metrics.inc/observe记录指标，
+	//store.get/put为跨规则链共享的存储，不是节点状态，参考js包说明
 	JsScript string
 }
 
@@ -59,6 +63,14 @@ type JsSwitchNode struct {
 func (x *JsSwitchNode) Type() string {
 	return "jsSwitch"
 }
+
+// Descriptor 组件描述
+func (x *JsSwitchNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"js", "script", "switch"},
+		Description: "使用JS脚本把消息路由到一个或多个链",
+	}
+}
 func (x *JsSwitchNode) New() types.Node {
 	return &JsSwitchNode{}
 }
@@ -67,7 +79,7 @@ func (x *JsSwitchNode) New() types.Node {
 func (x *JsSwitchNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &x.config)
 	if err == nil {
-		jsScript := fmt.Sprintf("function Switch(msg, metadata, msgType) { %s }", x.config.JsScript)
+		jsScript := js.FuncScript("Switch", "msg, metadata, msgType", x.config.JsScript)
 		x.jsEngine = js.NewGojaJsEngine(ruleConfig, jsScript, nil)
 	}
 	return err
@@ -84,7 +96,7 @@ func (x *JsSwitchNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
 		}
 	}
 
-	out, err := x.jsEngine.Execute("Switch", data, msg.Metadata.Values(), msg.Type)
+	out, err := js.Execute(ctx, x.jsEngine, "Switch", data, msg.Metadata.Values(), msg.Type)
 
 	if err != nil {
 		ctx.TellFailure(msg, err)
@@ -101,6 +113,14 @@ func (x *JsSwitchNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
 	return err
 }
 
+// Metrics 实现types.MetricsProvider，返回脚本通过metrics对象记录的指标
+func (x *JsSwitchNode) Metrics() map[string]interface{} {
+	if x.jsEngine == nil {
+		return nil
+	}
+	return js.Metrics(x.jsEngine)
+}
+
 // Destroy 销毁
 func (x *JsSwitchNode) Destroy() {
 	x.jsEngine.Stop()
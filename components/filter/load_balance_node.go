@@ -0,0 +1,232 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+//规则链节点配置示例：
+//{
+//        "id": "s2",
+//        "type": "loadBalance",
+//        "name": "分发到多个API后端",
+//        "configuration": {
+//          "strategy": "hash",
+//          "key": "${deviceId}",
+//          "branches": [{"name": "backendA", "weight": 2}, {"name": "backendB", "weight": 1}]
+//        }
+//      }
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// 负载均衡策略
+const (
+	//LoadBalanceRoundRobin 轮询，忽略权重
+	LoadBalanceRoundRobin = "roundRobin"
+	//LoadBalanceWeighted 平滑加权轮询
+	LoadBalanceWeighted = "weighted"
+	//LoadBalanceHash 按key加权哈希，相同key总是路由到相同分支，增删分支只影响该分支的key
+	LoadBalanceHash = "hash"
+)
+
+// loadBalanceBranchKey 选中分支的元数据key
+const loadBalanceBranchKey = "lbBranch"
+
+func init() {
+	Registry.Add(&LoadBalanceNode{})
+}
+
+// LoadBalanceBranch 分支
+type LoadBalanceBranch struct {
+	//Name 分支关系类型
+	Name string
+	//Weight 权重，默认1，0表示不分配消息
+	Weight *int
+}
+
+// LoadBalanceNodeConfiguration 节点配置
+type LoadBalanceNodeConfiguration struct {
+	//Strategy 策略：roundRobin、weighted、hash，默认：roundRobin
+	Strategy string
+	//Key hash策略的key，可以使用 ${metaKeyName} 替换元数据中的变量
+	Key string
+	//Branches 分支列表
+	Branches []LoadBalanceBranch
+}
+
+// LoadBalanceNode 负载均衡节点，按策略把消息发送到其中一个分支，关系类型为分支名称
+// 元数据lbBranch为选中的分支，用于把负载分散到多个API后端
+type LoadBalanceNode struct {
+	config LoadBalanceNodeConfiguration
+	names  []string
+	//weights 分支权重
+	weights []int
+	//current 平滑加权轮询的当前权重
+	current []int
+	//next 轮询下一个分支
+	next int
+	lock sync.Mutex
+}
+
+// Type 组件类型
+func (x *LoadBalanceNode) Type() string {
+	return "loadBalance"
+}
+
+// Descriptor 组件描述
+func (x *LoadBalanceNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"switch", "loadBalance", "weighted", "hash"},
+		Description: "按轮询、加权或者key哈希把消息分发到多个分支",
+	}
+}
+
+func (x *LoadBalanceNode) New() types.Node {
+	return &LoadBalanceNode{config: LoadBalanceNodeConfiguration{Strategy: LoadBalanceRoundRobin}}
+}
+
+// Init 初始化
+func (x *LoadBalanceNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if err := maps.Map2Struct(configuration, &x.config); err != nil {
+		return err
+	}
+	switch x.config.Strategy {
+	case LoadBalanceRoundRobin, LoadBalanceWeighted:
+	case LoadBalanceHash:
+		if x.config.Key == "" {
+			return errors.New("key can not empty")
+		}
+	default:
+		return fmt.Errorf("unsupported strategy: %s", x.config.Strategy)
+	}
+	total := 0
+	for _, branch := range x.config.Branches {
+		if branch.Name == "" {
+			return errors.New("branch name can not empty")
+		}
+		weight := 1
+		if branch.Weight != nil {
+			weight = *branch.Weight
+		}
+		if weight < 0 {
+			return fmt.Errorf("branch %s weight can not be negative", branch.Name)
+		}
+		if x.config.Strategy == LoadBalanceRoundRobin && weight == 0 {
+			continue
+		}
+		x.names = append(x.names, branch.Name)
+		x.weights = append(x.weights, weight)
+		total += weight
+	}
+	if total == 0 {
+		return errors.New("branches can not empty")
+	}
+	x.current = make([]int, len(x.names))
+	return nil
+}
+
+// MetadataOnly 只使用元数据选择分支，不需要加载转存到BlobStore的消息内容
+func (x *LoadBalanceNode) MetadataOnly() bool {
+	return true
+}
+
+// OnMsg 处理消息
+func (x *LoadBalanceNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	var branch string
+	switch x.config.Strategy {
+	case LoadBalanceHash:
+		branch = x.hash(str.SprintfDict(x.config.Key, msg.Metadata.Values()))
+	case LoadBalanceWeighted:
+		branch = x.weighted()
+	default:
+		branch = x.roundRobin()
+	}
+	msg.Metadata.PutValue(loadBalanceBranchKey, branch)
+	ctx.TellNext(msg, branch)
+	return nil
+}
+
+// Destroy 销毁
+func (x *LoadBalanceNode) Destroy() {
+}
+
+func (x *LoadBalanceNode) roundRobin() string {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	name := x.names[x.next]
+	x.next = (x.next + 1) % len(x.names)
+	return name
+}
+
+// weighted 平滑加权轮询，每个分支当前权重加上自身权重，选当前权重最大的分支，然后减去总权重
+func (x *LoadBalanceNode) weighted() string {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	total := 0
+	best := -1
+	for i, weight := range x.weights {
+		x.current[i] += weight
+		total += weight
+		if weight > 0 && (best < 0 || x.current[i] > x.current[best]) {
+			best = i
+		}
+	}
+	x.current[best] -= total
+	return x.names[best]
+}
+
+// hash 加权rendezvous哈希，选-weight/ln(h)最大的分支，h为key和分支名称哈希映射到(0,1)的值
+func (x *LoadBalanceNode) hash(key string) string {
+	return rendezvousHash(key, x.names, x.weights)
+}
+
+// rendezvousHash 加权rendezvous哈希，相同key总是选择相同的分支，权重为0的分支不会被选中
+func rendezvousHash(key string, names []string, weights []int) string {
+	best := -1
+	var bestScore float64
+	for i, name := range names {
+		if weights[i] == 0 {
+			continue
+		}
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(name))
+		u := (float64(mix64(h.Sum64())>>11) + 0.5) / (1 << 53)
+		score := -float64(weights[i]) / math.Log(u)
+		if best < 0 || score > bestScore {
+			best = i
+			bestScore = score
+		}
+	}
+	return names[best]
+}
+
+// mix64 splitmix64终结函数，fnv哈希的高位分布不均匀，混合后再映射到(0,1)
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
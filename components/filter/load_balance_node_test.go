@@ -0,0 +1,117 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filter
+
+import (
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"strings"
+	"testing"
+)
+
+func newLoadBalanceNode(t *testing.T, configuration types.Configuration) (types.Node, types.RuleContext, *[]string) {
+	node := (&LoadBalanceNode{}).New()
+	config := types.NewConfig()
+	assert.Nil(t, node.Init(config, configuration))
+	var relations []string
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, relationType, msg.Metadata.GetValue(loadBalanceBranchKey))
+		relations = append(relations, relationType)
+	})
+	return node, ctx, &relations
+}
+
+func TestLoadBalanceNodeRoundRobin(t *testing.T) {
+	node, ctx, relations := newLoadBalanceNode(t, types.Configuration{"branches": []interface{}{
+		map[string]interface{}{"name": "a"},
+		map[string]interface{}{"name": "b", "weight": 5},
+		map[string]interface{}{"name": "c", "weight": 0},
+	}})
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "")))
+	}
+	assert.Equal(t, []string{"a", "b", "a", "b"}, *relations)
+}
+
+func TestLoadBalanceNodeWeighted(t *testing.T) {
+	node, ctx, relations := newLoadBalanceNode(t, types.Configuration{"strategy": LoadBalanceWeighted, "branches": []interface{}{
+		map[string]interface{}{"name": "a", "weight": 5},
+		map[string]interface{}{"name": "b", "weight": 1},
+		map[string]interface{}{"name": "c", "weight": 1},
+	}})
+	for i := 0; i < 7; i++ {
+		assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", types.NewMetadata(), "")))
+	}
+	//平滑加权轮询不会连续选择同一个高权重分支
+	assert.Equal(t, "a a b a c a a", strings.Join(*relations, " "))
+}
+
+func TestLoadBalanceNodeHash(t *testing.T) {
+	branches := []interface{}{
+		map[string]interface{}{"name": "a", "weight": 1},
+		map[string]interface{}{"name": "b", "weight": 1},
+		map[string]interface{}{"name": "c", "weight": 2},
+	}
+	node, ctx, relations := newLoadBalanceNode(t, types.Configuration{"strategy": LoadBalanceHash, "key": "${deviceId}", "branches": branches})
+	send := func(node types.Node, deviceId string) {
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceId", deviceId)
+		assert.Nil(t, node.OnMsg(ctx, ctx.NewMsg("TEST", metadata, "")))
+	}
+	counts := make(map[string]int)
+	assigned := make(map[string]string)
+	for i := 0; i < 2000; i++ {
+		deviceId := fmt.Sprintf("device%d", i)
+		send(node, deviceId)
+		branch := (*relations)[len(*relations)-1]
+		counts[branch]++
+		assigned[deviceId] = branch
+	}
+	//按权重分布
+	assert.True(t, counts["c"] > counts["a"] && counts["c"] > counts["b"])
+	assert.True(t, counts["a"] > 300 && counts["b"] > 300)
+	//相同key路由到相同分支
+	send(node, "device7")
+	assert.Equal(t, assigned["device7"], (*relations)[len(*relations)-1])
+
+	//删除分支只影响该分支的key
+	node2, ctx2, relations2 := newLoadBalanceNode(t, types.Configuration{"strategy": LoadBalanceHash, "key": "${deviceId}", "branches": branches[:2]})
+	ctx = ctx2
+	for i := 0; i < 2000; i++ {
+		deviceId := fmt.Sprintf("device%d", i)
+		send(node2, deviceId)
+		if assigned[deviceId] != "c" {
+			assert.Equal(t, assigned[deviceId], (*relations2)[len(*relations2)-1])
+		}
+	}
+}
+
+func TestLoadBalanceNodeInitError(t *testing.T) {
+	tests := []types.Configuration{
+		{},
+		{"strategy": "random", "branches": []interface{}{map[string]interface{}{"name": "a"}}},
+		{"strategy": LoadBalanceHash, "branches": []interface{}{map[string]interface{}{"name": "a"}}},
+		{"branches": []interface{}{map[string]interface{}{"name": ""}}},
+		{"branches": []interface{}{map[string]interface{}{"name": "a", "weight": -1}}},
+		{"branches": []interface{}{map[string]interface{}{"name": "a", "weight": 0}}},
+	}
+	for _, item := range tests {
+		assert.NotNil(t, (&LoadBalanceNode{}).New().Init(types.NewConfig(), item))
+	}
+}
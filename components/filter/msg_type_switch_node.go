@@ -40,6 +40,14 @@ func (x *MsgTypeSwitchNode) Type() string {
 	return "msgTypeSwitch"
 }
 
+// Descriptor 组件描述
+func (x *MsgTypeSwitchNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"switch", "msgType"},
+		Description: "根据消息类型路由消息",
+	}
+}
+
 func (x *MsgTypeSwitchNode) New() types.Node {
 	return &MsgTypeSwitchNode{}
 }
@@ -49,6 +57,11 @@ func (x *MsgTypeSwitchNode) Init(ruleConfig types.Config, configuration types.Co
 	return nil
 }
 
+// MetadataOnly 只根据消息类型路由，不需要加载转存到BlobStore的消息内容
+func (x *MsgTypeSwitchNode) MetadataOnly() bool {
+	return true
+}
+
 // OnMsg 处理消息
 func (x *MsgTypeSwitchNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
 	ctx.TellNext(msg, msg.Type)
@@ -0,0 +1,225 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/utils/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//PubSubScope Pub/Sub 访问范围
+	PubSubScope = "https://www.googleapis.com/auth/pubsub"
+	//metadataTokenUrl GCE/GKE 元数据服务获取token地址
+	metadataTokenUrl = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	defaultTokenUri  = "https://oauth2.googleapis.com/token"
+)
+
+// TokenProvider OAuth2 access token 提供者
+type TokenProvider interface {
+	Token() (string, error)
+}
+
+// StaticTokenProvider 固定token，为空表示不需要认证，例如Pub/Sub模拟器
+type StaticTokenProvider struct {
+	AccessToken string
+}
+
+func (p StaticTokenProvider) Token() (string, error) {
+	return p.AccessToken, nil
+}
+
+// ServiceAccount 服务账号密钥文件内容
+type ServiceAccount struct {
+	Type         string `json:"type"`
+	ProjectId    string `json:"project_id"`
+	PrivateKeyId string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenUri     string `json:"token_uri"`
+}
+
+// cachedTokenProvider 缓存token，过期前1分钟刷新
+type cachedTokenProvider struct {
+	fetch     func() (string, time.Duration, error)
+	token     string
+	expiresAt time.Time
+	sync.Mutex
+}
+
+func (p *cachedTokenProvider) Token() (string, error) {
+	p.Lock()
+	defer p.Unlock()
+	if p.token != "" && time.Now().Before(p.expiresAt) {
+		return p.token, nil
+	}
+	token, expiresIn, err := p.fetch()
+	if err != nil {
+		return "", err
+	}
+	p.token = token
+	p.expiresAt = time.Now().Add(expiresIn - time.Minute)
+	return p.token, nil
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// NewServiceAccountTokenProvider 通过服务账号密钥(JSON)使用JWT方式获取token
+func NewServiceAccountTokenProvider(credentialsJson []byte, scope string, httpClient *http.Client) (TokenProvider, error) {
+	var account ServiceAccount
+	if err := json.Unmarshal(credentialsJson, &account); err != nil {
+		return nil, err
+	}
+	if account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, errors.New("invalid service account credentials")
+	}
+	key, err := parsePrivateKey(account.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if account.TokenUri == "" {
+		account.TokenUri = defaultTokenUri
+	}
+	return &cachedTokenProvider{fetch: func() (string, time.Duration, error) {
+		assertion, err := signJwt(account, key, scope, time.Now())
+		if err != nil {
+			return "", 0, err
+		}
+		form := url.Values{}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+		response, err := httpClient.PostForm(account.TokenUri, form)
+		if err != nil {
+			return "", 0, err
+		}
+		return readTokenResponse(response)
+	}}, nil
+}
+
+// NewMetadataTokenProvider 在GCE/GKE环境中通过元数据服务获取默认服务账号token
+func NewMetadataTokenProvider(httpClient *http.Client) TokenProvider {
+	return &cachedTokenProvider{fetch: func() (string, time.Duration, error) {
+		req, err := http.NewRequest(http.MethodGet, metadataTokenUrl, nil)
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		response, err := httpClient.Do(req)
+		if err != nil {
+			return "", 0, err
+		}
+		return readTokenResponse(response)
+	}}
+}
+
+// NewTokenProvider 根据配置选择token提供者：
+// 1.accessToken不为空使用固定token
+// 2.credentialsFile或者环境变量GOOGLE_APPLICATION_CREDENTIALS不为空使用服务账号
+// 3.否则使用元数据服务
+func NewTokenProvider(accessToken, credentialsFile, scope string, httpClient *http.Client) (TokenProvider, error) {
+	if accessToken != "" {
+		return StaticTokenProvider{AccessToken: accessToken}, nil
+	}
+	if credentialsFile == "" {
+		credentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if credentialsFile != "" {
+		b, err := ioutil.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		return NewServiceAccountTokenProvider(b, scope, httpClient)
+	}
+	return NewMetadataTokenProvider(httpClient), nil
+}
+
+func readTokenResponse(response *http.Response) (string, time.Duration, error) {
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	b, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", 0, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("fetch token error,status=%s,body=%s", response.Status, string(b))
+	}
+	var token tokenResponse
+	if err = json.Unmarshal(b, &token); err != nil {
+		return "", 0, err
+	}
+	return token.AccessToken, time.Duration(token.ExpiresIn) * time.Second, nil
+}
+
+func parsePrivateKey(privateKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return nil, errors.New("invalid private key")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+			return rsaKey, nil
+		}
+		return nil, errors.New("private key is not rsa key")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// signJwt 生成RS256签名的JWT
+func signJwt(account ServiceAccount, key *rsa.PrivateKey, scope string, now time.Time) (string, error) {
+	header := map[string]string{"alg": "RS256", "typ": "JWT", "kid": account.PrivateKeyId}
+	claims := map[string]interface{}{
+		"iss":   account.ClientEmail,
+		"scope": scope,
+		"aud":   account.TokenUri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	headerJson, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJson, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJson) + "." + base64.RawURLEncoding.EncodeToString(claimsJson)
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{signingInput, base64.RawURLEncoding.EncodeToString(signature)}, "."), nil
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/2018yuli/rulego/utils/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPubSubEndpoint = "https://pubsub.googleapis.com/v1/"
+	//MaxBatchSize 每次发布最大消息数
+	MaxBatchSize = 1000
+)
+
+// PubSubMessage Pub/Sub 消息
+type PubSubMessage struct {
+	//Data 消息内容
+	Data string
+	//Attributes 消息属性
+	Attributes map[string]string
+	//OrderingKey 顺序key
+	OrderingKey string
+	//MessageId 接收消息的ID
+	MessageId string
+	//AckId 接收消息的确认ID
+	AckId string
+	//PublishTime 接收消息的发布时间
+	PublishTime string
+}
+
+type pubsubMessageJson struct {
+	Data        string            `json:"data,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+	MessageId   string            `json:"messageId,omitempty"`
+	PublishTime string            `json:"publishTime,omitempty"`
+}
+
+// PubSubClient Google Cloud Pub/Sub REST 客户端
+type PubSubClient struct {
+	//Endpoint 接口地址，默认https://pubsub.googleapis.com/v1/
+	Endpoint string
+	//ProjectId 项目ID
+	ProjectId  string
+	Token      TokenProvider
+	HttpClient *http.Client
+}
+
+// NewPubSubClient 创建Pub/Sub客户端
+// 如果设置了环境变量PUBSUB_EMULATOR_HOST并且没有指定endpoint，则连接模拟器并不进行认证
+func NewPubSubClient(projectId, endpoint string, token TokenProvider, timeout time.Duration) *PubSubClient {
+	if endpoint == "" {
+		if emulatorHost := os.Getenv("PUBSUB_EMULATOR_HOST"); emulatorHost != "" {
+			endpoint = "http://" + emulatorHost + "/v1/"
+			token = StaticTokenProvider{}
+		} else {
+			endpoint = defaultPubSubEndpoint
+		}
+	}
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint = endpoint + "/"
+	}
+	return &PubSubClient{Endpoint: endpoint, ProjectId: projectId, Token: token, HttpClient: &http.Client{Timeout: timeout}}
+}
+
+// Publish 发布消息到主题，返回消息ID列表
+func (c *PubSubClient) Publish(ctx context.Context, topic string, msgs []PubSubMessage) ([]string, error) {
+	var request struct {
+		Messages []pubsubMessageJson `json:"messages"`
+	}
+	for _, msg := range msgs {
+		request.Messages = append(request.Messages, pubsubMessageJson{
+			Data:        base64.StdEncoding.EncodeToString([]byte(msg.Data)),
+			Attributes:  msg.Attributes,
+			OrderingKey: msg.OrderingKey,
+		})
+	}
+	var response struct {
+		MessageIds []string `json:"messageIds"`
+	}
+	err := c.do(ctx, c.topicPath(topic)+":publish", request, &response)
+	return response.MessageIds, err
+}
+
+// Pull 拉取订阅消息
+func (c *PubSubClient) Pull(ctx context.Context, subscription string, maxMessages int) ([]PubSubMessage, error) {
+	request := map[string]interface{}{"maxMessages": maxMessages}
+	var response struct {
+		ReceivedMessages []struct {
+			AckId   string            `json:"ackId"`
+			Message pubsubMessageJson `json:"message"`
+		} `json:"receivedMessages"`
+	}
+	if err := c.do(ctx, c.subscriptionPath(subscription)+":pull", request, &response); err != nil {
+		return nil, err
+	}
+	var msgs []PubSubMessage
+	for _, item := range response.ReceivedMessages {
+		data, _ := base64.StdEncoding.DecodeString(item.Message.Data)
+		msgs = append(msgs, PubSubMessage{
+			Data:        string(data),
+			Attributes:  item.Message.Attributes,
+			OrderingKey: item.Message.OrderingKey,
+			MessageId:   item.Message.MessageId,
+			PublishTime: item.Message.PublishTime,
+			AckId:       item.AckId,
+		})
+	}
+	return msgs, nil
+}
+
+// Acknowledge 确认消息
+func (c *PubSubClient) Acknowledge(ctx context.Context, subscription string, ackIds ...string) error {
+	return c.do(ctx, c.subscriptionPath(subscription)+":acknowledge", map[string]interface{}{"ackIds": ackIds}, nil)
+}
+
+// ModifyAckDeadline 修改确认截止时间，ackDeadlineSeconds=0表示立即重新投递
+func (c *PubSubClient) ModifyAckDeadline(ctx context.Context, subscription string, ackDeadlineSeconds int, ackIds ...string) error {
+	return c.do(ctx, c.subscriptionPath(subscription)+":modifyAckDeadline",
+		map[string]interface{}{"ackIds": ackIds, "ackDeadlineSeconds": ackDeadlineSeconds}, nil)
+}
+
+// topicPath 如果不是完整路径，则补全projects/{projectId}/topics/{topic}
+func (c *PubSubClient) topicPath(topic string) string {
+	if strings.HasPrefix(topic, "projects/") {
+		return topic
+	}
+	return "projects/" + c.ProjectId + "/topics/" + topic
+}
+
+func (c *PubSubClient) subscriptionPath(subscription string) string {
+	if strings.HasPrefix(subscription, "projects/") {
+		return subscription
+	}
+	return "projects/" + c.ProjectId + "/subscriptions/" + subscription
+}
+
+func (c *PubSubClient) do(ctx context.Context, path string, request interface{}, out interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != nil {
+		token, err := c.Token.Token()
+		if err != nil {
+			return err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	response, err := c.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	b, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("pubsub error,status=%s,body=%s", response.Status, string(b))
+	}
+	if out != nil && len(b) > 0 {
+		return json.Unmarshal(b, out)
+	}
+	return nil
+}
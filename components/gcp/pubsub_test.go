@@ -0,0 +1,114 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServiceAccountTokenProvider(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		_ = r.ParseForm()
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.Form.Get("grant_type"))
+		parts := strings.Split(r.Form.Get("assertion"), ".")
+		assert.Equal(t, 3, len(parts))
+		claimsJson, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]interface{}
+		_ = json.Unmarshal(claimsJson, &claims)
+		assert.Equal(t, "rulego@my-project.iam.gserviceaccount.com", claims["iss"])
+		assert.Equal(t, PubSubScope, claims["scope"])
+		_, _ = w.Write([]byte(`{"access_token":"token1","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	credentialsJson, _ := json.Marshal(ServiceAccount{
+		Type:        "service_account",
+		ProjectId:   "my-project",
+		PrivateKey:  string(privateKey),
+		ClientEmail: "rulego@my-project.iam.gserviceaccount.com",
+		TokenUri:    server.URL,
+	})
+	provider, err := NewServiceAccountTokenProvider(credentialsJson, PubSubScope, http.DefaultClient)
+	assert.Nil(t, err)
+	token, err := provider.Token()
+	assert.Nil(t, err)
+	assert.Equal(t, "token1", token)
+	//使用缓存
+	token, _ = provider.Token()
+	assert.Equal(t, "token1", token)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	_, err = NewServiceAccountTokenProvider([]byte(`{}`), PubSubScope, http.DefaultClient)
+	assert.NotNil(t, err)
+}
+
+func TestPubSubClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token1", r.Header.Get("Authorization"))
+		b, _ := ioutil.ReadAll(r.Body)
+		var request map[string]interface{}
+		_ = json.Unmarshal(b, &request)
+		switch r.URL.Path {
+		case "/v1/projects/my-project/topics/rulego:publish":
+			msgs := request["messages"].([]interface{})
+			msg := msgs[0].(map[string]interface{})
+			assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("hello")), msg["data"])
+			_, _ = w.Write([]byte(`{"messageIds":["1","2"]}`))
+		case "/v1/projects/my-project/subscriptions/sub1:pull":
+			_, _ = w.Write([]byte(`{"receivedMessages":[{"ackId":"a1","message":{"data":"aGVsbG8=","messageId":"1","attributes":{"deviceId":"aa"}}}]}`))
+		case "/v1/projects/other/subscriptions/sub1:acknowledge":
+			assert.Equal(t, "a1", request["ackIds"].([]interface{})[0])
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewPubSubClient("my-project", server.URL+"/v1", StaticTokenProvider{AccessToken: "token1"}, time.Second)
+	messageIds, err := client.Publish(context.Background(), "rulego", []PubSubMessage{{Data: "hello"}, {Data: "world"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(messageIds))
+
+	msgs, err := client.Pull(context.Background(), "sub1", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(msgs))
+	assert.Equal(t, "hello", msgs[0].Data)
+	assert.Equal(t, "a1", msgs[0].AckId)
+	assert.Equal(t, "aa", msgs[0].Attributes["deviceId"])
+
+	assert.Nil(t, client.Acknowledge(context.Background(), "projects/other/subscriptions/sub1", "a1"))
+	assert.NotNil(t, client.ModifyAckDeadline(context.Background(), "sub1", 0, "a1"))
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package js
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/dop251/goja"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// fetchVarName js脚本发送http请求的函数名
+const fetchVarName = "fetch"
+
+// ErrFetchDisabled 没有配置`Config.JsFetch`
+var ErrFetchDisabled = errors.New("fetch is disabled, see types.Config.JsFetch")
+
+// jsFetcher js脚本使用的fetch函数，同一个js引擎的虚拟机共享http客户端
+//
+//	const res = await fetch(url, {method: 'POST', headers: {...}, body: {...}, timeoutMs: 1000});
+//	res.status、res.statusText、res.ok、res.headers、res.text()、res.json()
+//
+// 请求在调用fetch时同步执行，返回已完成的Promise，请求超时不超过脚本执行的截止时间
+type jsFetcher struct {
+	config *types.JsFetchConfig
+	client *http.Client
+}
+
+// newJsFetcher 创建fetch函数，config为空则fetch返回ErrFetchDisabled
+func newJsFetcher(config *types.JsFetchConfig) *jsFetcher {
+	if config == nil {
+		return &jsFetcher{}
+	}
+	c := *config
+	if c.MaxResponseBytes <= 0 {
+		c.MaxResponseBytes = types.DefaultJsFetchMaxResponseBytes
+	}
+	f := &jsFetcher{config: &c}
+	f.client = &http.Client{
+		Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		//重定向地址也需要在允许访问的主机中
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return f.checkUrl(req.URL)
+		},
+	}
+	return f
+}
+
+// fetchOptions fetch请求参数
+type fetchOptions struct {
+	Method    string
+	Headers   map[string]interface{}
+	Body      interface{}
+	TimeoutMs int64
+}
+
+// fetchFunc 返回绑定到虚拟机的fetch函数
+func (f *jsFetcher) fetchFunc(vm *jsVm) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		promise, resolve, reject := vm.NewPromise()
+		response, err := f.fetch(vm, call)
+		if err != nil {
+			reject(vm.NewGoError(err))
+		} else {
+			resolve(response)
+		}
+		return vm.ToValue(promise)
+	}
+}
+
+// fetch 执行http请求
+func (f *jsFetcher) fetch(vm *jsVm, call goja.FunctionCall) (map[string]interface{}, error) {
+	if f.config == nil {
+		return nil, ErrFetchDisabled
+	}
+	target, err := url.Parse(call.Argument(0).String())
+	if err != nil {
+		return nil, err
+	}
+	if err = f.checkUrl(target); err != nil {
+		return nil, err
+	}
+	var options fetchOptions
+	if arg := call.Argument(1); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+		if err = maps.Map2Struct(arg.Export(), &options); err != nil {
+			return nil, err
+		}
+	}
+	body, err := fetchBody(options.Body)
+	if err != nil {
+		return nil, err
+	}
+	method := strings.ToUpper(options.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	deadline := vm.deadline
+	if options.TimeoutMs > 0 {
+		if d := time.Now().Add(time.Duration(options.TimeoutMs) * time.Millisecond); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	ctx := context.Background()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range options.Headers {
+		req.Header.Set(key, fmt.Sprint(value))
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.config.MaxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > f.config.MaxResponseBytes {
+		return nil, fmt.Errorf("response body exceeds %d bytes", f.config.MaxResponseBytes)
+	}
+	headers := make(map[string]interface{}, len(resp.Header))
+	for key := range resp.Header {
+		headers[strings.ToLower(key)] = resp.Header.Get(key)
+	}
+	return map[string]interface{}{
+		"status":     resp.StatusCode,
+		"statusText": http.StatusText(resp.StatusCode),
+		"ok":         resp.StatusCode >= 200 && resp.StatusCode < 300,
+		"url":        resp.Request.URL.String(),
+		"headers":    headers,
+		"text": func() string {
+			return string(data)
+		},
+		"json": func() interface{} {
+			var v interface{}
+			if err := json.Unmarshal(data, &v); err != nil {
+				panic(vm.NewGoError(err))
+			}
+			return v
+		},
+	}, nil
+}
+
+// checkUrl 只允许访问AllowedHosts中的http、https地址
+func (f *jsFetcher) checkUrl(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("fetch scheme %q is not allowed", target.Scheme)
+	}
+	if !f.config.HostAllowed(target.Host) {
+		return fmt.Errorf("fetch host %q is not allowed", target.Host)
+	}
+	return nil
+}
+
+// fetchBody 请求体，字符串原样发送，其他类型转换成JSON
+func fetchBody(body interface{}) (io.Reader, error) {
+	switch v := body.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.NewReader(v), nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(b), nil
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package js
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/dop251/goja"
+	"sync"
+)
+
+const (
+	//stateVarName js脚本访问当前节点状态的变量名
+	stateVarName = "state"
+	//metricsVarName js脚本记录指标的变量名
+	metricsVarName = "metrics"
+	//maxJsMetrics 每个js引擎最多记录的指标数量，超过则忽略新的指标
+	maxJsMetrics = 1000
+)
+
+// ErrNoRuleContext 没有规则上下文，脚本不能访问节点状态
+var ErrNoRuleContext = errors.New("state is only available when executed with rule context")
+
+// Execute 执行js脚本指定函数，engine实现了`types.ContextJsEngine`则脚本可以通过state对象访问当前节点的状态
+func Execute(ctx types.RuleContext, engine types.JsEngine, functionName string, argumentList ...interface{}) (interface{}, error) {
+	if contextEngine, ok := engine.(types.ContextJsEngine); ok && ctx != nil {
+		return contextEngine.ExecuteWithContext(ctx, functionName, argumentList...)
+	}
+	return engine.Execute(functionName, argumentList...)
+}
+
+// Metrics 返回js脚本通过metrics对象记录的指标，engine没有实现`types.MetricsProvider`返回nil
+func Metrics(engine types.JsEngine) map[string]interface{} {
+	if provider, ok := engine.(types.MetricsProvider); ok {
+		return provider.Metrics()
+	}
+	return nil
+}
+
+// newJsState 创建js脚本使用的state对象，访问当前节点的状态，作用域为规则链+节点，参考`RuleContext.GetState`
+//
+//	state.get(key) 获取值，不存在返回null
+//	state.put(key, value) 保存值
+func newJsState(vm *jsVm) map[string]interface{} {
+	ruleCtx := func() types.RuleContext {
+		if vm.ruleCtx == nil {
+			panic(vm.NewGoError(ErrNoRuleContext))
+		}
+		return vm.ruleCtx
+	}
+	return map[string]interface{}{
+		"get": func(key string) interface{} {
+			v, ok, err := ruleCtx().GetState(key)
+			if err != nil {
+				panic(vm.NewGoError(err))
+			}
+			if !ok {
+				return nil
+			}
+			return v
+		},
+		"put": func(key string, value string) {
+			if err := ruleCtx().SetState(key, value); err != nil {
+				panic(vm.NewGoError(err))
+			}
+		},
+	}
+}
+
+// jsMetrics js脚本通过metrics对象记录的指标，同一个js引擎的虚拟机共享
+//
+//	metrics.inc(name, delta) 计数器增加delta，delta可选，默认1
+//	metrics.observe(name, value) 记录观测值，统计次数、总和、最小值和最大值
+type jsMetrics struct {
+	lock         sync.Mutex
+	counters     map[string]float64
+	observations map[string]*jsObservation
+}
+
+// jsObservation 观测值统计
+type jsObservation struct {
+	count    int64
+	sum      float64
+	min, max float64
+}
+
+func newJsMetrics() *jsMetrics {
+	return &jsMetrics{counters: make(map[string]float64), observations: make(map[string]*jsObservation)}
+}
+
+// full 指标数量是否达到上限
+func (m *jsMetrics) full() bool {
+	return len(m.counters)+len(m.observations) >= maxJsMetrics
+}
+
+func (m *jsMetrics) inc(name string, delta float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.counters[name]; !ok && m.full() {
+		return
+	}
+	m.counters[name] += delta
+}
+
+func (m *jsMetrics) observe(name string, value float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	o, ok := m.observations[name]
+	if !ok {
+		if m.full() {
+			return
+		}
+		o = &jsObservation{min: value, max: value}
+		m.observations[name] = o
+	}
+	o.count++
+	o.sum += value
+	if value < o.min {
+		o.min = value
+	}
+	if value > o.max {
+		o.max = value
+	}
+}
+
+// snapshot 当前指标，计数器为数值，观测值为{count,sum,min,max}，没有指标返回nil
+func (m *jsMetrics) snapshot() map[string]interface{} {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(m.counters) == 0 && len(m.observations) == 0 {
+		return nil
+	}
+	result := make(map[string]interface{}, len(m.counters)+len(m.observations))
+	for name, value := range m.counters {
+		result[name] = value
+	}
+	for name, o := range m.observations {
+		result[name] = map[string]interface{}{"count": o.count, "sum": o.sum, "min": o.min, "max": o.max}
+	}
+	return result
+}
+
+// object 创建js脚本使用的metrics对象
+func (m *jsMetrics) object() map[string]interface{} {
+	return map[string]interface{}{
+		"inc": func(call goja.FunctionCall) goja.Value {
+			delta := 1.0
+			if arg := call.Argument(1); !goja.IsUndefined(arg) {
+				delta = arg.ToFloat()
+			}
+			m.inc(call.Argument(0).String(), delta)
+			return goja.Undefined()
+		},
+		"observe": func(call goja.FunctionCall) goja.Value {
+			m.observe(call.Argument(0).String(), call.Argument(1).ToFloat())
+			return goja.Undefined()
+		},
+	}
+}
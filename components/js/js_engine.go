@@ -14,6 +14,16 @@
  * limitations under the License.
  */
 
+// Package js 基于goja的js脚本引擎，jsFilter、jsSwitch、jsTransform和log等节点使用
+//
+// 脚本可以使用以下全局对象：
+//
+//	store   跨规则链共享的key/value存储，按`Config.Tenant`隔离：store.get(key)、store.put(key, value, ttlMs)、store.del(key)
+//	state   当前节点的状态，作用域为规则链+节点，参考`RuleContext.GetState`：state.get(key)、state.put(key, value)
+//	metrics 记录指标，通过节点的Metrics()上报：metrics.inc(name, delta)、metrics.observe(name, value)
+//	fetch   发送http请求，需要配置`Config.JsFetch`：const res = await fetch(url, options)
+//
+// 节点级状态使用state而不是store，因为store已经是跨规则链共享的存储，两者的作用域不同
 package js
 
 import (
@@ -21,10 +31,24 @@ import (
 	"fmt"
 	"github.com/2018yuli/rulego/api/types"
 	"github.com/dop251/goja"
+	"regexp"
 	"sync"
 	"time"
 )
 
+// awaitPattern 脚本中使用了await
+var awaitPattern = regexp.MustCompile(`\bawait\b`)
+
+// FuncScript 把脚本内容包装成函数：function name(params) { body }
+// 脚本使用了await则声明为async函数，例如：const res = await fetch(url); return res.json();
+// 执行async函数返回Promise的结果，参考`GojaJsEngine.Execute`
+func FuncScript(name, params, body string) string {
+	if awaitPattern.MatchString(body) {
+		return fmt.Sprintf("async function %s(%s) { %s }", name, params, body)
+	}
+	return fmt.Sprintf("function %s(%s) { %s }", name, params, body)
+}
+
 func closeStateChan(state chan int) {
 	// 超过时间也会执行到这里
 	//如果没有超过时间，那么取出的是0，否则取出的是2
@@ -39,17 +63,42 @@ type GojaJsEngine struct {
 	vmPool   sync.Pool
 	jsScript string
 	config   types.Config
+	//metrics 脚本通过metrics对象记录的指标
+	metrics *jsMetrics
+}
+
+// jsVm 对象池中的js虚拟机
+type jsVm struct {
+	*goja.Runtime
+	//deadline 当前脚本执行的截止时间，fetch请求不超过该时间
+	deadline time.Time
+	//ruleCtx 当前节点的规则上下文，state对象通过它访问节点状态
+	ruleCtx types.RuleContext
 }
 
 // NewGojaJsEngine 创建一个新的js引擎实例
 func NewGojaJsEngine(config types.Config, jsScript string, vars map[string]interface{}) *GojaJsEngine {
 	//defaultAntsPool, _ := ants.NewPool(config.MaxTaskPool)
+	fetcher := newJsFetcher(config.JsFetch)
+	metrics := newJsMetrics()
 	jsEngine := &GojaJsEngine{
 		vmPool: sync.Pool{
 			New: func() interface{} {
 				//atomic.AddInt64(&vmNum, 1)
 				//config.Logger.Printf("create new js vm%d", vmNum)
-				vm := goja.New()
+				vm := &jsVm{Runtime: goja.New(), deadline: time.Now().Add(config.GetJsMaxExecutionTime())}
+				if err := vm.Set(storeVarName, newJsStore(vm.Runtime, config)); err != nil {
+					panic(errors.New("set variable error,err:" + err.Error()))
+				}
+				if err := vm.Set(fetchVarName, fetcher.fetchFunc(vm)); err != nil {
+					panic(errors.New("set variable error,err:" + err.Error()))
+				}
+				if err := vm.Set(stateVarName, newJsState(vm)); err != nil {
+					panic(errors.New("set variable error,err:" + err.Error()))
+				}
+				if err := vm.Set(metricsVarName, metrics.object()); err != nil {
+					panic(errors.New("set variable error,err:" + err.Error()))
+				}
 				for k, v := range vars {
 					if err := vm.Set(k, v); err != nil {
 						config.Logger.Printf("set variable error,err:" + err.Error())
@@ -59,7 +108,7 @@ func NewGojaJsEngine(config types.Config, jsScript string, vars map[string]inter
 				}
 				state := make(chan int, 1)
 				state <- 0
-				time.AfterFunc(config.JsMaxExecutionTime, func() {
+				time.AfterFunc(config.GetJsMaxExecutionTime(), func() {
 					if <-state == 0 {
 						state <- 2
 						vm.Interrupt("execution timeout")
@@ -81,22 +130,40 @@ func NewGojaJsEngine(config types.Config, jsScript string, vars map[string]inter
 		},
 		jsScript: jsScript,
 		config:   config,
+		metrics:  metrics,
 	}
 
 	return jsEngine
 }
 
 func (g *GojaJsEngine) Execute(functionName string, argumentList ...interface{}) (out interface{}, err error) {
+	return g.execute(nil, functionName, argumentList...)
+}
+
+// ExecuteWithContext 实现`types.ContextJsEngine`，脚本可以通过state对象访问当前节点的状态
+func (g *GojaJsEngine) ExecuteWithContext(ctx types.RuleContext, functionName string, argumentList ...interface{}) (interface{}, error) {
+	return g.execute(ctx, functionName, argumentList...)
+}
+
+// Metrics 实现`types.MetricsProvider`，返回脚本通过metrics对象记录的指标，没有记录返回nil
+func (g *GojaJsEngine) Metrics() map[string]interface{} {
+	return g.metrics.snapshot()
+}
+
+func (g *GojaJsEngine) execute(ctx types.RuleContext, functionName string, argumentList ...interface{}) (out interface{}, err error) {
 	defer func() {
 		if caught := recover(); caught != nil {
 			err = fmt.Errorf("%s", caught)
 		}
 	}()
 
-	vm := g.vmPool.Get().(*goja.Runtime)
+	vm := g.vmPool.Get().(*jsVm)
+	vm.ruleCtx = ctx
+	maxExecutionTime := g.config.GetJsMaxExecutionTime()
+	vm.deadline = time.Now().Add(maxExecutionTime)
 	state := make(chan int, 1)
 	state <- 0
-	time.AfterFunc(g.config.JsMaxExecutionTime, func() {
+	time.AfterFunc(maxExecutionTime, func() {
 		if <-state == 0 {
 			state <- 2
 			vm.Interrupt("execution timeout")
@@ -115,13 +182,66 @@ func (g *GojaJsEngine) Execute(functionName string, argumentList ...interface{})
 
 	// 超过时间也会执行到这里，如果没有超过时间，那么取出的是0，否则取出的是2
 	closeStateChan(state)
+	if err == nil {
+		//放回对象池前取出结果，Promise的结果也需要从该虚拟机读取
+		out, err = promiseResult(res.Export())
+	}
 	//放回对象池
+	vm.ruleCtx = nil
 	g.vmPool.Put(vm)
-	if err != nil {
-		return nil, err
+	return out, err
+}
+
+// promiseResult async函数返回Promise，取出Promise的结果，Promise被拒绝则返回错误
+// 脚本执行完成后，Promise的后续任务已经执行，没有完成说明等待的操作不会完成
+func promiseResult(out interface{}) (interface{}, error) {
+	promise, ok := out.(*goja.Promise)
+	if !ok {
+		return out, nil
+	}
+	switch promise.State() {
+	case goja.PromiseStateFulfilled:
+		return promise.Result().Export(), nil
+	case goja.PromiseStateRejected:
+		return nil, fmt.Errorf("%s", promise.Result())
+	default:
+		return nil, errors.New("promise is not settled")
 	}
-	return res.Export(), err
 }
 
 func (g *GojaJsEngine) Stop() {
 }
+
+// storeVarName js脚本访问跨规则链共享存储的变量名
+const storeVarName = "store"
+
+// newJsStore 创建js脚本使用的store对象，数据按照`Config.Tenant`隔离
+//
+//	store.get(key) 获取值，不存在返回null
+//	store.put(key, value, ttlMs) 保存值，ttlMs可选，不设置永不过期
+//	store.del(key) 删除值
+func newJsStore(vm *goja.Runtime, config types.Config) map[string]interface{} {
+	store := config.GetKVStore()
+	return map[string]interface{}{
+		"get": func(key string) interface{} {
+			v, ok, err := store.Get(config.Tenant, key)
+			if err != nil {
+				panic(vm.NewGoError(err))
+			}
+			if !ok {
+				return nil
+			}
+			return v
+		},
+		"put": func(key string, value string, ttlMs int64) {
+			if err := store.Put(config.Tenant, key, value, time.Duration(ttlMs)*time.Millisecond); err != nil {
+				panic(vm.NewGoError(err))
+			}
+		},
+		"del": func(key string) {
+			if err := store.Delete(config.Tenant, key); err != nil {
+				panic(vm.NewGoError(err))
+			}
+		},
+	}
+}
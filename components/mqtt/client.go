@@ -19,6 +19,8 @@ package mqtt
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"github.com/2018yuli/rulego/utils/reconnect"
 	string2 "github.com/2018yuli/rulego/utils/str"
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"log"
@@ -55,19 +57,29 @@ type Config struct {
 	CAFile      string
 	CertFile    string
 	CertKeyFile string
+	//MaxConnectRetries 首次连接失败最大重试次数，0表示一直重试直到连接成功
+	MaxConnectRetries int
+	//OnStateChange 连接状态变化回调
+	OnStateChange func(state reconnect.State, err error) `mapstructure:"-"`
 }
 
+// ClientOption 修改paho客户端选项的函数，用于扩展认证、连接方式等
+type ClientOption func(opts *paho.ClientOptions)
+
 // Client mqtt客户端
 type Client struct {
 	sync.RWMutex
 	wg     sync.WaitGroup
 	client paho.Client
+	//连接状态管理
+	conn *reconnect.Manager
 	//订阅主题和处理器映射
 	msgHandlerMap map[string]Handler
 }
 
 // NewClient 创建一个MQTT客户端实例
-func NewClient(conf Config) (*Client, error) {
+// clientOpts 在默认配置基础上修改paho客户端选项
+func NewClient(conf Config, clientOpts ...ClientOption) (*Client, error) {
 	var err error
 
 	b := Client{
@@ -100,20 +112,36 @@ func NewClient(conf Config) (*Client, error) {
 	if tlsconfig != nil {
 		opts.SetTLSConfig(tlsconfig)
 	}
+	for _, clientOpt := range clientOpts {
+		clientOpt(opts)
+	}
 	log.Printf("connecting to mqtt broker,server=%s", conf.Server)
 	b.client = paho.NewClient(opts)
-	for {
+	//首次连接按照退避策略重试，连接成功后由paho客户端自动重连
+	b.conn = reconnect.New(reconnect.Config{
+		InitialInterval: 2 * time.Second,
+		MaxInterval:     conf.MaxReconnectInterval,
+		Jitter:          0.2,
+		MaxRetries:      conf.MaxConnectRetries,
+		OnStateChange:   conf.OnStateChange,
+	}, func() error {
 		if token := b.client.Connect(); token.Wait() && token.Error() != nil {
-			log.Printf("connecting to mqtt broker failed, will retry in 2s: %s", token.Error())
-			time.Sleep(2 * time.Second)
-		} else {
-			break
+			log.Printf("connecting to mqtt broker failed, will retry: %s", token.Error())
+			return token.Error()
 		}
+		return nil
+	})
+	if err := b.conn.Connect(); err != nil {
+		return nil, err
 	}
-
 	return &b, nil
 }
 
+// State 连接状态
+func (b *Client) State() reconnect.State {
+	return b.conn.State()
+}
+
 // RegisterHandler 注册订阅数据处理器
 func (b *Client) RegisterHandler(handler Handler) {
 	b.Lock()
@@ -148,6 +176,14 @@ func (b *Client) Close() error {
 	return nil
 }
 
+// HealthCheck 检查是否已经连接到mqtt broker
+func (b *Client) HealthCheck() error {
+	if b.conn.State() != reconnect.Connected || !b.client.IsConnectionOpen() {
+		return errors.New("mqtt broker not connected")
+	}
+	return nil
+}
+
 // Publish 发布数据
 func (b *Client) Publish(topic string, qos byte, data []byte) error {
 	if token := b.client.Publish(topic, qos, false, data); token.Wait() && token.Error() != nil {
@@ -160,6 +196,9 @@ func (b *Client) Publish(topic string, qos byte, data []byte) error {
 
 func (b *Client) onConnected(c paho.Client) {
 	log.Printf("connected to mqtt server")
+	if b.conn != nil {
+		b.conn.SetState(reconnect.Connected, nil)
+	}
 	b.subscribe()
 
 }
@@ -191,6 +230,7 @@ func is128Err(token *paho.SubscribeToken, topic string) bool {
 
 func (b *Client) onConnectionLost(c paho.Client, reason error) {
 	log.Printf("mqtt connection error: %s", reason)
+	b.conn.SetState(reconnect.Disconnected, reason)
 }
 
 func newTLSConfig(CAFile, certFile, certKeyFile string) (*tls.Config, error) {
@@ -0,0 +1,286 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	azureIotHubApiVersion = "2021-04-12"
+	//azureDefaultTokenTtl SAS token 默认有效期
+	azureDefaultTokenTtl = time.Hour
+	awsIotService        = "iotdevicegateway"
+	awsSignAlgorithm     = "AWS4-HMAC-SHA256"
+)
+
+// AzureIotHubConfig Azure IoT Hub 设备连接配置
+// 支持SAS密钥认证和X.509证书认证两种方式
+type AzureIotHubConfig struct {
+	//ConnectionString 设备连接字符串，格式：HostName=xx.azure-devices.net;DeviceId=xx;SharedAccessKey=xx
+	//如果配置，则覆盖HostName、DeviceId和SharedAccessKey
+	ConnectionString string
+	//HostName IoT Hub 主机名，例如：xx.azure-devices.net
+	HostName string
+	//DeviceId 设备ID
+	DeviceId string
+	//SharedAccessKey 设备对称密钥(base64)
+	SharedAccessKey string
+	//TokenTtl SAS token 有效期，每次连接重新生成，默认1小时
+	TokenTtl time.Duration
+	//CertFile X.509证书认证的设备证书
+	CertFile string
+	//CertKeyFile X.509证书认证的设备私钥
+	CertKeyFile string
+	//CAFile 根证书，默认使用系统根证书
+	CAFile string
+	//QOS 订阅和发布的QOS，IoT Hub 只支持0和1
+	QOS uint8
+	//MaxReconnectInterval 重连重试间隔
+	MaxReconnectInterval time.Duration
+}
+
+// Parse 解析连接字符串并检查配置
+func (c *AzureIotHubConfig) Parse() error {
+	if c.ConnectionString != "" {
+		for _, item := range strings.Split(c.ConnectionString, ";") {
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch strings.TrimSpace(kv[0]) {
+			case "HostName":
+				c.HostName = kv[1]
+			case "DeviceId":
+				c.DeviceId = kv[1]
+			case "SharedAccessKey":
+				c.SharedAccessKey = kv[1]
+			}
+		}
+	}
+	if c.HostName == "" || c.DeviceId == "" {
+		return errors.New("hostName and deviceId can not empty")
+	}
+	if c.SharedAccessKey == "" && (c.CertFile == "" || c.CertKeyFile == "") {
+		return errors.New("sharedAccessKey or certFile and certKeyFile must be configured")
+	}
+	return nil
+}
+
+// ToMqttConfig 转换成mqtt客户端配置和客户端选项
+func (c *AzureIotHubConfig) ToMqttConfig() (Config, []ClientOption, error) {
+	if err := c.Parse(); err != nil {
+		return Config{}, nil, err
+	}
+	conf := Config{
+		Server:               "ssl://" + c.HostName + ":8883",
+		Username:             fmt.Sprintf("%s/%s/?api-version=%s", c.HostName, c.DeviceId, azureIotHubApiVersion),
+		ClientID:             c.DeviceId,
+		QOS:                  c.QOS,
+		MaxReconnectInterval: c.MaxReconnectInterval,
+		CAFile:               c.CAFile,
+		CertFile:             c.CertFile,
+		CertKeyFile:          c.CertKeyFile,
+	}
+	var opts []ClientOption
+	if c.SharedAccessKey != "" {
+		ttl := c.TokenTtl
+		if ttl <= 0 {
+			ttl = azureDefaultTokenTtl
+		}
+		resourceUri := c.HostName + "/devices/" + c.DeviceId
+		key := c.SharedAccessKey
+		//每次连接或者重连都重新生成SAS token，防止过期
+		opts = append(opts, func(opts *paho.ClientOptions) {
+			opts.SetCredentialsProvider(func() (string, string) {
+				token, _ := AzureSasToken(resourceUri, key, time.Now().Add(ttl))
+				return conf.Username, token
+			})
+		})
+	}
+	return conf, opts, nil
+}
+
+// TelemetryTopic 设备到云消息的主题，properties 会编码成消息属性
+func (c *AzureIotHubConfig) TelemetryTopic(properties map[string]string) string {
+	topic := "devices/" + c.DeviceId + "/messages/events/"
+	if len(properties) == 0 {
+		return topic
+	}
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(properties[k]))
+	}
+	return topic + strings.Join(pairs, "&")
+}
+
+// CommandTopic 云到设备消息的订阅主题
+func (c *AzureIotHubConfig) CommandTopic() string {
+	return "devices/" + c.DeviceId + "/messages/devicebound/#"
+}
+
+// AzureSasToken 生成Azure IoT Hub SAS token
+// resourceUri 例如：xx.azure-devices.net/devices/{deviceId}
+// key base64编码的对称密钥
+func AzureSasToken(resourceUri, key string, expiry time.Time) (string, error) {
+	decodedKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "", err
+	}
+	sr := url.QueryEscape(resourceUri)
+	se := strconv.FormatInt(expiry.Unix(), 10)
+	mac := hmac.New(sha256.New, decodedKey)
+	mac.Write([]byte(sr + "\n" + se))
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return fmt.Sprintf("SharedAccessSignature sr=%s&sig=%s&se=%s", sr, url.QueryEscape(sig), se), nil
+}
+
+// AwsIotCoreConfig AWS IoT Core 设备连接配置
+// 支持X.509证书认证(mqtts 8883端口)和SigV4认证(wss 443端口)两种方式
+type AwsIotCoreConfig struct {
+	//Endpoint 设备数据端点，例如：xx-ats.iot.us-east-1.amazonaws.com
+	Endpoint string
+	//ClientId 客户端ID，一般使用thing name
+	ClientId string
+	//CAFile 根证书，默认使用系统根证书
+	CAFile string
+	//CertFile X.509证书认证的设备证书
+	CertFile string
+	//CertKeyFile X.509证书认证的设备私钥
+	CertKeyFile string
+	//Region SigV4认证区域，例如：us-east-1
+	Region string
+	//AccessKeyId SigV4认证访问密钥ID
+	AccessKeyId string
+	//SecretAccessKey SigV4认证访问密钥
+	SecretAccessKey string
+	//SessionToken SigV4认证临时会话token，可选
+	SessionToken string
+	//QOS 订阅和发布的QOS，IoT Core 只支持0和1
+	QOS uint8
+	//CleanSession 是否清除会话
+	CleanSession bool
+	//MaxReconnectInterval 重连重试间隔
+	MaxReconnectInterval time.Duration
+}
+
+// ToMqttConfig 转换成mqtt客户端配置和客户端选项
+func (c *AwsIotCoreConfig) ToMqttConfig() (Config, []ClientOption, error) {
+	if c.Endpoint == "" {
+		return Config{}, nil, errors.New("endpoint can not empty")
+	}
+	conf := Config{
+		ClientID:             c.ClientId,
+		QOS:                  c.QOS,
+		CleanSession:         c.CleanSession,
+		MaxReconnectInterval: c.MaxReconnectInterval,
+		CAFile:               c.CAFile,
+	}
+	if c.AccessKeyId != "" && c.SecretAccessKey != "" {
+		if c.Region == "" {
+			return Config{}, nil, errors.New("region can not empty")
+		}
+		conf.Server = "wss://" + c.Endpoint + ":443/mqtt"
+		sigConfig := *c
+		//每次连接或者重连都重新生成签名URL
+		opts := []ClientOption{func(opts *paho.ClientOptions) {
+			opts.SetCustomOpenConnectionFn(func(uri *url.URL, options paho.ClientOptions) (net.Conn, error) {
+				signedUrl, err := AwsIotPresignUrl(sigConfig.Endpoint, sigConfig.Region, sigConfig.AccessKeyId, sigConfig.SecretAccessKey, sigConfig.SessionToken, time.Now())
+				if err != nil {
+					return nil, err
+				}
+				tlsConfig := options.TLSConfig
+				if tlsConfig == nil {
+					tlsConfig = &tls.Config{ServerName: sigConfig.Endpoint}
+				}
+				return paho.NewWebsocket(signedUrl, tlsConfig, options.ConnectTimeout, options.HTTPHeaders, options.WebsocketOptions)
+			})
+		}}
+		return conf, opts, nil
+	}
+	if c.CertFile == "" || c.CertKeyFile == "" {
+		return Config{}, nil, errors.New("certFile and certKeyFile or accessKeyId and secretAccessKey must be configured")
+	}
+	conf.Server = "ssl://" + c.Endpoint + ":8883"
+	conf.CertFile = c.CertFile
+	conf.CertKeyFile = c.CertKeyFile
+	return conf, nil, nil
+}
+
+// AwsIotPresignUrl 生成AWS IoT Core websocket SigV4签名URL
+func AwsIotPresignUrl(host, region, accessKeyId, secretAccessKey, sessionToken string, now time.Time) (string, error) {
+	if host == "" || region == "" || accessKeyId == "" || secretAccessKey == "" {
+		return "", errors.New("host, region, accessKeyId and secretAccessKey can not empty")
+	}
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + region + "/" + awsIotService + "/aws4_request"
+
+	query := "X-Amz-Algorithm=" + awsSignAlgorithm +
+		"&X-Amz-Credential=" + url.QueryEscape(accessKeyId+"/"+scope) +
+		"&X-Amz-Date=" + amzDate +
+		"&X-Amz-SignedHeaders=host"
+	payloadHash := sha256.Sum256([]byte(""))
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		"/mqtt",
+		query,
+		"host:" + host + "\n",
+		"host",
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{awsSignAlgorithm, amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+secretAccessKey), date)
+	signingKey = hmacSha256(signingKey, region)
+	signingKey = hmacSha256(signingKey, awsIotService)
+	signingKey = hmacSha256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	signedUrl := "wss://" + host + "/mqtt?" + query + "&X-Amz-Signature=" + signature
+	//IoT Core 要求session token 不参与签名
+	if sessionToken != "" {
+		signedUrl += "&X-Amz-Security-Token=" + url.QueryEscape(sessionToken)
+	}
+	return signedUrl, nil
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,247 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package onnx
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// attribute 节点属性
+type attribute struct {
+	f      float64
+	i      int64
+	s      string
+	t      *Tensor
+	floats []float64
+	ints   []int64
+}
+
+// node 计算图节点
+type node struct {
+	name       string
+	opType     string
+	domain     string
+	inputs     []string
+	outputs    []string
+	attributes map[string]*attribute
+}
+
+func (n *node) intAttr(name string, defaultValue int64) int64 {
+	if a, ok := n.attributes[name]; ok {
+		return a.i
+	}
+	return defaultValue
+}
+
+func (n *node) floatAttr(name string, defaultValue float64) float64 {
+	if a, ok := n.attributes[name]; ok {
+		return a.f
+	}
+	return defaultValue
+}
+
+// Model ONNX模型
+// 纯Go实现的推理引擎，只支持常用的默认域(ai.onnx)算子，适用于线性模型、逻辑回归和多层感知机等小模型
+// 支持的算子见 SupportedOps
+type Model struct {
+	//Inputs 模型输入名称，不包括初始化参数
+	Inputs []string
+	//Outputs 模型输出名称
+	Outputs      []string
+	nodes        []*node
+	initializers map[string]*Tensor
+}
+
+// Open 从文件加载模型
+func Open(file string) (*Model, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse 解析ONNX模型(ModelProto)，并检查是否所有算子都支持
+func Parse(data []byte) (*Model, error) {
+	var graph []byte
+	err := forEach(data, func(f protoField) error {
+		if f.num == 7 && f.wireType == wireBytes {
+			graph = f.data
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if graph == nil {
+		return nil, fmt.Errorf("onnx: model has no graph")
+	}
+	m := &Model{initializers: make(map[string]*Tensor)}
+	var inputs []string
+	err = forEach(graph, func(f protoField) error {
+		switch f.num {
+		case 1:
+			n, err := parseNode(f.data)
+			if err != nil {
+				return err
+			}
+			m.nodes = append(m.nodes, n)
+		case 5:
+			name, t, err := parseTensor(f.data)
+			if err != nil {
+				return err
+			}
+			m.initializers[name] = t
+		case 11:
+			inputs = append(inputs, valueInfoName(f.data))
+		case 12:
+			m.Outputs = append(m.Outputs, valueInfoName(f.data))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	//旧版本模型的输入包括初始化参数
+	for _, name := range inputs {
+		if _, ok := m.initializers[name]; !ok {
+			m.Inputs = append(m.Inputs, name)
+		}
+	}
+	for _, n := range m.nodes {
+		if n.domain != "" && n.domain != "ai.onnx" {
+			return nil, fmt.Errorf("onnx: unsupported op domain %s", n.domain)
+		}
+		if _, ok := ops[n.opType]; !ok {
+			return nil, fmt.Errorf("onnx: unsupported op %s", n.opType)
+		}
+	}
+	return m, nil
+}
+
+// Run 执行推理，inputs key为输入名称，返回所有模型输出
+func (m *Model) Run(inputs map[string]*Tensor) (map[string]*Tensor, error) {
+	values := make(map[string]*Tensor, len(m.initializers)+len(inputs)+len(m.nodes))
+	for k, v := range m.initializers {
+		values[k] = v
+	}
+	for _, name := range m.Inputs {
+		v, ok := inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("onnx: missing input %s", name)
+		}
+		values[name] = v
+	}
+	//ONNX规范要求节点按照拓扑顺序排列
+	for _, n := range m.nodes {
+		args := make([]*Tensor, len(n.inputs))
+		for i, name := range n.inputs {
+			if name == "" {
+				continue
+			}
+			v, ok := values[name]
+			if !ok {
+				return nil, fmt.Errorf("onnx: node %s input %s not found", n.name, name)
+			}
+			args[i] = v
+		}
+		results, err := ops[n.opType](n, args)
+		if err != nil {
+			return nil, fmt.Errorf("onnx: node %s(%s) error:%w", n.name, n.opType, err)
+		}
+		for i, name := range n.outputs {
+			if i < len(results) && name != "" {
+				values[name] = results[i]
+			}
+		}
+	}
+	outputs := make(map[string]*Tensor, len(m.Outputs))
+	for _, name := range m.Outputs {
+		v, ok := values[name]
+		if !ok {
+			return nil, fmt.Errorf("onnx: output %s not found", name)
+		}
+		outputs[name] = v
+	}
+	return outputs, nil
+}
+
+func parseNode(buf []byte) (*node, error) {
+	n := &node{attributes: make(map[string]*attribute)}
+	err := forEach(buf, func(f protoField) error {
+		switch f.num {
+		case 1:
+			n.inputs = append(n.inputs, string(f.data))
+		case 2:
+			n.outputs = append(n.outputs, string(f.data))
+		case 3:
+			n.name = string(f.data)
+		case 4:
+			n.opType = string(f.data)
+		case 5:
+			name, a, err := parseAttribute(f.data)
+			if err != nil {
+				return err
+			}
+			n.attributes[name] = a
+		case 7:
+			n.domain = string(f.data)
+		}
+		return nil
+	})
+	return n, err
+}
+
+func parseAttribute(buf []byte) (string, *attribute, error) {
+	var name string
+	a := &attribute{}
+	err := forEach(buf, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			name = string(f.data)
+		case 2:
+			a.f = float64(math.Float32frombits(uint32(f.value)))
+		case 3:
+			a.i = int64(f.value)
+		case 4:
+			a.s = string(f.data)
+		case 5:
+			_, a.t, err = parseTensor(f.data)
+		case 7:
+			a.floats = floats(f, a.floats)
+		case 8:
+			a.ints, err = ints(f, a.ints)
+		}
+		return err
+	})
+	return name, a, err
+}
+
+// valueInfoName 获取 ValueInfoProto 的名称
+func valueInfoName(buf []byte) string {
+	var name string
+	_ = forEach(buf, func(f protoField) error {
+		if f.num == 1 {
+			name = string(f.data)
+		}
+		return nil
+	})
+	return name
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package onnx
+
+import (
+	"encoding/binary"
+	"github.com/2018yuli/rulego/test/assert"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pbWriter 测试用的protobuf编码器
+type pbWriter struct {
+	buf []byte
+}
+
+func (w *pbWriter) uvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	w.buf = append(w.buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func (w *pbWriter) key(num, wireType int) {
+	w.uvarint(uint64(num<<3 | wireType))
+}
+
+func (w *pbWriter) varint(num int, v int64) *pbWriter {
+	w.key(num, wireVarint)
+	w.uvarint(uint64(v))
+	return w
+}
+
+func (w *pbWriter) bytes(num int, data []byte) *pbWriter {
+	w.key(num, wireBytes)
+	w.uvarint(uint64(len(data)))
+	w.buf = append(w.buf, data...)
+	return w
+}
+
+func (w *pbWriter) str(num int, s string) *pbWriter {
+	return w.bytes(num, []byte(s))
+}
+
+func (w *pbWriter) msg(num int, m *pbWriter) *pbWriter {
+	return w.bytes(num, m.buf)
+}
+
+func appendFloat32(buf []byte, f float32) []byte {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], math.Float32bits(f))
+	return append(buf, tmp[:]...)
+}
+
+// testTensor 编码TensorProto，float_data使用packed编码
+func testTensor(name string, dims []int64, data []float32) *pbWriter {
+	w := &pbWriter{}
+	for _, d := range dims {
+		w.varint(1, d)
+	}
+	w.varint(2, dataTypeFloat)
+	var packed []byte
+	for _, v := range data {
+		packed = appendFloat32(packed, v)
+	}
+	return w.bytes(4, packed).str(8, name)
+}
+
+func testNode(opType string, inputs, outputs []string, attributes ...*pbWriter) *pbWriter {
+	w := &pbWriter{}
+	for _, v := range inputs {
+		w.str(1, v)
+	}
+	for _, v := range outputs {
+		w.str(2, v)
+	}
+	w.str(3, opType+"_"+outputs[0]).str(4, opType)
+	for _, a := range attributes {
+		w.msg(5, a)
+	}
+	return w
+}
+
+func testModel(nodes []*pbWriter, initializers []*pbWriter, inputs, outputs []string) []byte {
+	graph := &pbWriter{}
+	for _, n := range nodes {
+		graph.msg(1, n)
+	}
+	graph.str(2, "test")
+	for _, t := range initializers {
+		graph.msg(5, t)
+	}
+	for _, v := range inputs {
+		graph.msg(11, (&pbWriter{}).str(1, v))
+	}
+	for _, v := range outputs {
+		graph.msg(12, (&pbWriter{}).str(1, v))
+	}
+	model := (&pbWriter{}).varint(1, 8).msg(7, graph).msg(8, (&pbWriter{}).str(1, "").varint(2, 13))
+	return model.buf
+}
+
+// testMlpModel 两层感知机：softmax(relu(x*W1+B1)*W2+B2)，输出概率和类别
+func testMlpModel() []byte {
+	//偏置使用raw_data编码
+	b1 := &pbWriter{}
+	b1.varint(1, 2).varint(2, dataTypeFloat).str(8, "B1")
+	var raw []byte
+	for _, v := range []float32{0.5, -1} {
+		raw = appendFloat32(raw, v)
+	}
+	b1.bytes(9, raw)
+	return testModel(
+		[]*pbWriter{
+			testNode("Gemm", []string{"x", "W1", "B1"}, []string{"h"}),
+			testNode("Relu", []string{"h"}, []string{"r"}),
+			testNode("MatMul", []string{"r", "W2"}, []string{"m"}),
+			testNode("Add", []string{"m", "B2"}, []string{"logits"}),
+			testNode("Softmax", []string{"logits"}, []string{"prob"}, (&pbWriter{}).str(1, "axis").varint(3, 1).varint(20, 2)),
+			testNode("ArgMax", []string{"prob"}, []string{"label"}, (&pbWriter{}).str(1, "axis").varint(3, 1).varint(20, 2)),
+		},
+		[]*pbWriter{
+			testTensor("W1", []int64{2, 2}, []float32{1, 2, 3, 4}),
+			b1,
+			testTensor("W2", []int64{2, 2}, []float32{1, -1, -1, 1}),
+			testTensor("B2", []int64{2}, []float32{0, 0.5}),
+		},
+		//旧版本模型的输入包括初始化参数
+		[]string{"x", "W1"},
+		[]string{"prob", "label"},
+	)
+}
+
+func TestModelRun(t *testing.T) {
+	m, err := Parse(testMlpModel())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(m.Inputs))
+	assert.Equal(t, "x", m.Inputs[0])
+	assert.Equal(t, 2, len(m.Outputs))
+
+	x, err := NewTensor([]int{1, 2}, []float64{1, 1})
+	assert.Nil(t, err)
+	outputs, err := m.Run(map[string]*Tensor{"x": x})
+	assert.Nil(t, err)
+	//h=[1+3+0.5, 2+4-1]=[4.5,5]，logits=[4.5-5, -4.5+5+0.5]=[-0.5,1]
+	prob := outputs["prob"]
+	assert.Equal(t, []int{1, 2}, prob.Shape)
+	expected := 1 / (1 + math.Exp(1.5))
+	assert.True(t, math.Abs(prob.Data[0]-expected) < 1e-9)
+	assert.True(t, math.Abs(prob.Data[0]+prob.Data[1]-1) < 1e-9)
+	assert.Equal(t, []float64{1}, outputs["label"].Data)
+
+	//h=[1.5,1]，logits=[0.5,0]
+	x, _ = NewTensor([]int{1, 2}, []float64{1, 0})
+	outputs, err = m.Run(map[string]*Tensor{"x": x})
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{0}, outputs["label"].Data)
+
+	_, err = m.Run(map[string]*Tensor{})
+	assert.NotNil(t, err)
+	x, _ = NewTensor([]int{1, 3}, []float64{1, 1, 1})
+	_, err = m.Run(map[string]*Tensor{"x": x})
+	assert.NotNil(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "model.onnx")
+	err := os.WriteFile(file, testMlpModel(), 0644)
+	assert.Nil(t, err)
+	m, err := Open(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "x", m.Inputs[0])
+
+	_, err = Open(filepath.Join(t.TempDir(), "not_exist.onnx"))
+	assert.NotNil(t, err)
+}
+
+func TestParseUnsupported(t *testing.T) {
+	_, err := Parse(testModel([]*pbWriter{testNode("Conv", []string{"x", "w"}, []string{"y"})}, nil, []string{"x"}, []string{"y"}))
+	assert.NotNil(t, err)
+	n := testNode("LinearClassifier", []string{"x"}, []string{"y"}).str(7, "ai.onnx.ml")
+	_, err = Parse(testModel([]*pbWriter{n}, nil, []string{"x"}, []string{"y"}))
+	assert.NotNil(t, err)
+	_, err = Parse([]byte{0x0a})
+	assert.NotNil(t, err)
+	_, err = Parse(nil)
+	assert.NotNil(t, err)
+}
+
+func TestBroadcast(t *testing.T) {
+	a, _ := NewTensor([]int{2, 3}, []float64{1, 2, 3, 4, 5, 6})
+	b, _ := NewTensor([]int{3}, []float64{10, 20, 30})
+	c, err := broadcast(a, b, func(x, y float64) float64 { return x + y })
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 3}, c.Shape)
+	assert.Equal(t, []float64{11, 22, 33, 14, 25, 36}, c.Data)
+
+	b, _ = NewTensor([]int{2, 1}, []float64{1, 2})
+	c, err = broadcast(a, b, func(x, y float64) float64 { return x * y })
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{1, 2, 3, 8, 10, 12}, c.Data)
+
+	b, _ = NewTensor([]int{2}, []float64{1, 2})
+	_, err = broadcast(a, b, func(x, y float64) float64 { return x * y })
+	assert.NotNil(t, err)
+}
+
+func TestReshapeAndFlatten(t *testing.T) {
+	x, _ := NewTensor([]int{2, 3}, []float64{1, 2, 3, 4, 5, 6})
+	shape, _ := NewTensor([]int{2}, []float64{-1, 2})
+	result, err := opReshape(&node{}, []*Tensor{x, shape})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3, 2}, result[0].Shape)
+
+	x, _ = NewTensor([]int{1, 2, 2}, []float64{1, 2, 3, 4})
+	result, err = opFlatten(&node{attributes: map[string]*attribute{}}, []*Tensor{x})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 4}, result[0].Shape)
+}
@@ -0,0 +1,314 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package onnx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// opFunc 算子实现，args中可选输入不存在时为nil
+type opFunc func(n *node, args []*Tensor) ([]*Tensor, error)
+
+var ops = map[string]opFunc{
+	"Identity":  opIdentity,
+	"Constant":  opConstant,
+	"Add":       binaryOp(func(x, y float64) float64 { return x + y }),
+	"Sub":       binaryOp(func(x, y float64) float64 { return x - y }),
+	"Mul":       binaryOp(func(x, y float64) float64 { return x * y }),
+	"Div":       binaryOp(func(x, y float64) float64 { return x / y }),
+	"Relu":      unaryOp(func(n *node, x float64) float64 { return math.Max(x, 0) }),
+	"LeakyRelu": unaryOp(leakyRelu),
+	"Sigmoid":   unaryOp(func(n *node, x float64) float64 { return 1 / (1 + math.Exp(-x)) }),
+	"Tanh":      unaryOp(func(n *node, x float64) float64 { return math.Tanh(x) }),
+	"Exp":       unaryOp(func(n *node, x float64) float64 { return math.Exp(x) }),
+	"Neg":       unaryOp(func(n *node, x float64) float64 { return -x }),
+	"Abs":       unaryOp(func(n *node, x float64) float64 { return math.Abs(x) }),
+	"Gemm":      opGemm,
+	"MatMul":    opMatMul,
+	"Softmax":   opSoftmax,
+	"ArgMax":    opArgMax,
+	"Flatten":   opFlatten,
+	"Reshape":   opReshape,
+}
+
+// SupportedOps 返回支持的算子列表
+func SupportedOps() []string {
+	var names []string
+	for k := range ops {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func requireArgs(args []*Tensor, count int) error {
+	if len(args) < count {
+		return fmt.Errorf("expect %d inputs, got %d", count, len(args))
+	}
+	for i := 0; i < count; i++ {
+		if args[i] == nil {
+			return fmt.Errorf("input %d is required", i)
+		}
+	}
+	return nil
+}
+
+func opIdentity(n *node, args []*Tensor) ([]*Tensor, error) {
+	if err := requireArgs(args, 1); err != nil {
+		return nil, err
+	}
+	return []*Tensor{args[0]}, nil
+}
+
+func opConstant(n *node, args []*Tensor) ([]*Tensor, error) {
+	if a, ok := n.attributes["value"]; ok && a.t != nil {
+		return []*Tensor{a.t}, nil
+	}
+	if a, ok := n.attributes["value_float"]; ok {
+		return []*Tensor{{Data: []float64{a.f}}}, nil
+	}
+	if a, ok := n.attributes["value_floats"]; ok {
+		return []*Tensor{{Shape: []int{len(a.floats)}, Data: a.floats}}, nil
+	}
+	return nil, errors.New("unsupported constant value")
+}
+
+func binaryOp(fn func(x, y float64) float64) opFunc {
+	return func(n *node, args []*Tensor) ([]*Tensor, error) {
+		if err := requireArgs(args, 2); err != nil {
+			return nil, err
+		}
+		t, err := broadcast(args[0], args[1], fn)
+		return []*Tensor{t}, err
+	}
+}
+
+func unaryOp(fn func(n *node, x float64) float64) opFunc {
+	return func(n *node, args []*Tensor) ([]*Tensor, error) {
+		if err := requireArgs(args, 1); err != nil {
+			return nil, err
+		}
+		out := make([]float64, len(args[0].Data))
+		for i, x := range args[0].Data {
+			out[i] = fn(n, x)
+		}
+		return []*Tensor{{Shape: args[0].Shape, Data: out}}, nil
+	}
+}
+
+func leakyRelu(n *node, x float64) float64 {
+	if x < 0 {
+		return x * n.floatAttr("alpha", 0.01)
+	}
+	return x
+}
+
+// matrix 把张量看成二维矩阵，transpose 是否转置
+func matrix(t *Tensor, transpose bool) (rows, cols int, at func(i, j int) float64, err error) {
+	switch len(t.Shape) {
+	case 1:
+		rows, cols = 1, t.Shape[0]
+	case 2:
+		rows, cols = t.Shape[0], t.Shape[1]
+	default:
+		return 0, 0, nil, fmt.Errorf("expect 2D tensor, got shape %v", t.Shape)
+	}
+	stride := cols
+	if transpose {
+		rows, cols = cols, rows
+		return rows, cols, func(i, j int) float64 { return t.Data[j*stride+i] }, nil
+	}
+	return rows, cols, func(i, j int) float64 { return t.Data[i*stride+j] }, nil
+}
+
+func matMul(a, b *Tensor, transA, transB bool, alpha float64) (*Tensor, error) {
+	m, k, atA, err := matrix(a, transA)
+	if err != nil {
+		return nil, err
+	}
+	k2, cols, atB, err := matrix(b, transB)
+	if err != nil {
+		return nil, err
+	}
+	if k != k2 {
+		return nil, fmt.Errorf("can not multiply shape %v and %v", a.Shape, b.Shape)
+	}
+	out := make([]float64, m*cols)
+	for i := 0; i < m; i++ {
+		for j := 0; j < cols; j++ {
+			var sum float64
+			for x := 0; x < k; x++ {
+				sum += atA(i, x) * atB(x, j)
+			}
+			out[i*cols+j] = alpha * sum
+		}
+	}
+	return &Tensor{Shape: []int{m, cols}, Data: out}, nil
+}
+
+// opGemm Y = alpha * A' * B' + beta * C
+func opGemm(n *node, args []*Tensor) ([]*Tensor, error) {
+	if err := requireArgs(args, 2); err != nil {
+		return nil, err
+	}
+	y, err := matMul(args[0], args[1], n.intAttr("transA", 0) != 0, n.intAttr("transB", 0) != 0, n.floatAttr("alpha", 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 2 && args[2] != nil {
+		beta := n.floatAttr("beta", 1)
+		if y, err = broadcast(y, args[2], func(x, c float64) float64 { return x + beta*c }); err != nil {
+			return nil, err
+		}
+	}
+	return []*Tensor{y}, nil
+}
+
+func opMatMul(n *node, args []*Tensor) ([]*Tensor, error) {
+	if err := requireArgs(args, 2); err != nil {
+		return nil, err
+	}
+	y, err := matMul(args[0], args[1], false, false, 1)
+	return []*Tensor{y}, err
+}
+
+// axisRange 把axis转换成[外层元素数, 轴长度, 内层元素数]
+func axisRange(shape []int, axis int) (int, int, int, error) {
+	if axis < 0 {
+		axis += len(shape)
+	}
+	if axis < 0 || axis >= len(shape) {
+		return 0, 0, 0, fmt.Errorf("axis %d out of range for shape %v", axis, shape)
+	}
+	return shapeSize(shape[:axis]), shape[axis], shapeSize(shape[axis+1:]), nil
+}
+
+func opSoftmax(n *node, args []*Tensor) ([]*Tensor, error) {
+	if err := requireArgs(args, 1); err != nil {
+		return nil, err
+	}
+	x := args[0]
+	outer, length, inner, err := axisRange(x.Shape, int(n.intAttr("axis", -1)))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float64, len(x.Data))
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			base := o*length*inner + i
+			maxValue := math.Inf(-1)
+			for l := 0; l < length; l++ {
+				maxValue = math.Max(maxValue, x.Data[base+l*inner])
+			}
+			var sum float64
+			for l := 0; l < length; l++ {
+				v := math.Exp(x.Data[base+l*inner] - maxValue)
+				out[base+l*inner] = v
+				sum += v
+			}
+			for l := 0; l < length; l++ {
+				out[base+l*inner] /= sum
+			}
+		}
+	}
+	return []*Tensor{{Shape: x.Shape, Data: out}}, nil
+}
+
+func opArgMax(n *node, args []*Tensor) ([]*Tensor, error) {
+	if err := requireArgs(args, 1); err != nil {
+		return nil, err
+	}
+	x := args[0]
+	axis := int(n.intAttr("axis", 0))
+	if axis < 0 {
+		axis += len(x.Shape)
+	}
+	outer, length, inner, err := axisRange(x.Shape, axis)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float64, outer*inner)
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			base := o*length*inner + i
+			best := 0
+			for l := 1; l < length; l++ {
+				if x.Data[base+l*inner] > x.Data[base+best*inner] {
+					best = l
+				}
+			}
+			out[o*inner+i] = float64(best)
+		}
+	}
+	shape := append([]int{}, x.Shape...)
+	if n.intAttr("keepdims", 1) != 0 {
+		shape[axis] = 1
+	} else {
+		shape = append(shape[:axis], shape[axis+1:]...)
+	}
+	return []*Tensor{{Shape: shape, Data: out}}, nil
+}
+
+func opFlatten(n *node, args []*Tensor) ([]*Tensor, error) {
+	if err := requireArgs(args, 1); err != nil {
+		return nil, err
+	}
+	x := args[0]
+	axis := int(n.intAttr("axis", 1))
+	if axis < 0 {
+		axis += len(x.Shape)
+	}
+	if axis < 0 || axis > len(x.Shape) {
+		return nil, fmt.Errorf("axis %d out of range for shape %v", axis, x.Shape)
+	}
+	return []*Tensor{{Shape: []int{shapeSize(x.Shape[:axis]), shapeSize(x.Shape[axis:])}, Data: x.Data}}, nil
+}
+
+func opReshape(n *node, args []*Tensor) ([]*Tensor, error) {
+	if err := requireArgs(args, 2); err != nil {
+		return nil, err
+	}
+	x := args[0]
+	shape := make([]int, len(args[1].Data))
+	infer := -1
+	known := 1
+	for i, v := range args[1].Data {
+		switch d := int(v); {
+		case d == 0 && i < len(x.Shape):
+			shape[i] = x.Shape[i]
+		case d == -1 && infer < 0:
+			infer = i
+			continue
+		case d > 0:
+			shape[i] = d
+		default:
+			return nil, fmt.Errorf("invalid reshape dim %d", d)
+		}
+		known *= shape[i]
+	}
+	if infer >= 0 {
+		if known == 0 || len(x.Data)%known != 0 {
+			return nil, fmt.Errorf("can not reshape %v to %v", x.Shape, args[1].Data)
+		}
+		shape[infer] = len(x.Data) / known
+	}
+	t, err := NewTensor(shape, x.Data)
+	return []*Tensor{t}, err
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package onnx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// errTruncated protobuf数据不完整
+var errTruncated = errors.New("onnx: truncated protobuf data")
+
+// protobuf wire类型
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoField protobuf字段
+type protoField struct {
+	num      int
+	wireType int
+	//varint 和 fixed 类型的值
+	value uint64
+	//bytes 类型的值
+	data []byte
+}
+
+// protoReader 最简单的protobuf解码器，只支持ONNX模型用到的wire类型
+type protoReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *protoReader) done() bool {
+	return r.pos >= len(r.buf)
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if r.pos >= len(r.buf) {
+			return 0, errTruncated
+		}
+		b := r.buf[r.pos]
+		r.pos++
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("onnx: invalid varint")
+}
+
+func (r *protoReader) next() (protoField, error) {
+	key, err := r.varint()
+	if err != nil {
+		return protoField{}, err
+	}
+	f := protoField{num: int(key >> 3), wireType: int(key & 7)}
+	switch f.wireType {
+	case wireVarint:
+		f.value, err = r.varint()
+	case wireFixed64:
+		if r.pos+8 > len(r.buf) {
+			return f, errTruncated
+		}
+		f.value = binary.LittleEndian.Uint64(r.buf[r.pos:])
+		r.pos += 8
+	case wireFixed32:
+		if r.pos+4 > len(r.buf) {
+			return f, errTruncated
+		}
+		f.value = uint64(binary.LittleEndian.Uint32(r.buf[r.pos:]))
+		r.pos += 4
+	case wireBytes:
+		var l uint64
+		if l, err = r.varint(); err != nil {
+			return f, err
+		}
+		if uint64(len(r.buf)-r.pos) < l {
+			return f, errTruncated
+		}
+		f.data = r.buf[r.pos : r.pos+int(l)]
+		r.pos += int(l)
+	default:
+		err = fmt.Errorf("onnx: unsupported wire type %d", f.wireType)
+	}
+	return f, err
+}
+
+// forEach 遍历所有字段
+func forEach(buf []byte, fn func(f protoField) error) error {
+	r := &protoReader{buf: buf}
+	for !r.done() {
+		f, err := r.next()
+		if err != nil {
+			return err
+		}
+		if err = fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ints 解码repeated int64字段，兼容packed和非packed编码
+func ints(f protoField, values []int64) ([]int64, error) {
+	if f.wireType == wireVarint {
+		return append(values, int64(f.value)), nil
+	}
+	r := &protoReader{buf: f.data}
+	for !r.done() {
+		v, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, int64(v))
+	}
+	return values, nil
+}
+
+// floats 解码repeated float字段，兼容packed和非packed编码
+func floats(f protoField, values []float64) []float64 {
+	if f.wireType == wireFixed32 {
+		return append(values, float64(math.Float32frombits(uint32(f.value))))
+	}
+	for i := 0; i+4 <= len(f.data); i += 4 {
+		values = append(values, float64(math.Float32frombits(binary.LittleEndian.Uint32(f.data[i:]))))
+	}
+	return values
+}
+
+// doubles 解码repeated double字段，兼容packed和非packed编码
+func doubles(f protoField, values []float64) []float64 {
+	if f.wireType == wireFixed64 {
+		return append(values, math.Float64frombits(f.value))
+	}
+	for i := 0; i+8 <= len(f.data); i += 8 {
+		values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(f.data[i:])))
+	}
+	return values
+}
@@ -0,0 +1,230 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package onnx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// ONNX TensorProto 数据类型
+const (
+	dataTypeFloat  = 1
+	dataTypeUint8  = 2
+	dataTypeInt8   = 3
+	dataTypeUint16 = 4
+	dataTypeInt16  = 5
+	dataTypeInt32  = 6
+	dataTypeInt64  = 7
+	dataTypeBool   = 9
+	dataTypeDouble = 11
+	dataTypeUint32 = 12
+	dataTypeUint64 = 13
+)
+
+// Tensor 张量，为了简化计算，所有数值类型统一使用float64存储
+type Tensor struct {
+	//Shape 形状，标量为空
+	Shape []int
+	//Data 按行优先存储的数据
+	Data []float64
+}
+
+// NewTensor 创建张量，shape的元素个数必须和data长度一致
+func NewTensor(shape []int, data []float64) (*Tensor, error) {
+	if shapeSize(shape) != len(data) {
+		return nil, fmt.Errorf("onnx: shape %v does not match data length %d", shape, len(data))
+	}
+	return &Tensor{Shape: shape, Data: data}, nil
+}
+
+// Size 元素个数
+func (t *Tensor) Size() int {
+	return len(t.Data)
+}
+
+func shapeSize(shape []int) int {
+	size := 1
+	for _, d := range shape {
+		size *= d
+	}
+	return size
+}
+
+// strides 行优先存储的步长
+func strides(shape []int) []int {
+	s := make([]int, len(shape))
+	step := 1
+	for i := len(shape) - 1; i >= 0; i-- {
+		s[i] = step
+		step *= shape[i]
+	}
+	return s
+}
+
+// broadcast 按照numpy广播规则，对两个张量逐元素计算
+func broadcast(a, b *Tensor, fn func(x, y float64) float64) (*Tensor, error) {
+	rank := len(a.Shape)
+	if len(b.Shape) > rank {
+		rank = len(b.Shape)
+	}
+	shapeA := padShape(a.Shape, rank)
+	shapeB := padShape(b.Shape, rank)
+	shape := make([]int, rank)
+	for i := 0; i < rank; i++ {
+		switch {
+		case shapeA[i] == shapeB[i] || shapeB[i] == 1:
+			shape[i] = shapeA[i]
+		case shapeA[i] == 1:
+			shape[i] = shapeB[i]
+		default:
+			return nil, fmt.Errorf("onnx: can not broadcast shape %v and %v", a.Shape, b.Shape)
+		}
+	}
+	stridesA := strides(shapeA)
+	stridesB := strides(shapeB)
+	out := make([]float64, shapeSize(shape))
+	index := make([]int, rank)
+	for i := range out {
+		offsetA, offsetB := 0, 0
+		for d := 0; d < rank; d++ {
+			if shapeA[d] != 1 {
+				offsetA += index[d] * stridesA[d]
+			}
+			if shapeB[d] != 1 {
+				offsetB += index[d] * stridesB[d]
+			}
+		}
+		out[i] = fn(a.Data[offsetA], b.Data[offsetB])
+		for d := rank - 1; d >= 0; d-- {
+			index[d]++
+			if index[d] < shape[d] {
+				break
+			}
+			index[d] = 0
+		}
+	}
+	return &Tensor{Shape: shape, Data: out}, nil
+}
+
+func padShape(shape []int, rank int) []int {
+	padded := make([]int, rank)
+	for i := range padded {
+		padded[i] = 1
+	}
+	copy(padded[rank-len(shape):], shape)
+	return padded
+}
+
+// parseTensor 解码 TensorProto
+func parseTensor(buf []byte) (string, *Tensor, error) {
+	var name string
+	var dims []int64
+	var dataType int
+	var raw []byte
+	var data []float64
+	err := forEach(buf, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			dims, err = ints(f, dims)
+		case 2:
+			dataType = int(f.value)
+		case 4:
+			data = floats(f, data)
+		case 5, 7, 11:
+			var values []int64
+			if values, err = ints(f, nil); err == nil {
+				for _, v := range values {
+					if f.num == 5 {
+						data = append(data, float64(int32(v)))
+					} else if f.num == 11 {
+						data = append(data, float64(uint64(v)))
+					} else {
+						data = append(data, float64(v))
+					}
+				}
+			}
+		case 8:
+			name = string(f.data)
+		case 9:
+			raw = f.data
+		case 10:
+			data = doubles(f, data)
+		}
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if raw != nil {
+		if data, err = decodeRaw(raw, dataType); err != nil {
+			return "", nil, err
+		}
+	}
+	shape := make([]int, len(dims))
+	for i, d := range dims {
+		shape[i] = int(d)
+	}
+	t, err := NewTensor(shape, data)
+	return name, t, err
+}
+
+// decodeRaw 解码小端存储的raw_data
+func decodeRaw(raw []byte, dataType int) ([]float64, error) {
+	var size int
+	switch dataType {
+	case dataTypeUint8, dataTypeInt8, dataTypeBool:
+		size = 1
+	case dataTypeUint16, dataTypeInt16:
+		size = 2
+	case dataTypeFloat, dataTypeInt32, dataTypeUint32:
+		size = 4
+	case dataTypeInt64, dataTypeDouble, dataTypeUint64:
+		size = 8
+	default:
+		return nil, fmt.Errorf("onnx: unsupported tensor data type %d", dataType)
+	}
+	data := make([]float64, len(raw)/size)
+	for i := range data {
+		b := raw[i*size:]
+		switch dataType {
+		case dataTypeUint8, dataTypeBool:
+			data[i] = float64(b[0])
+		case dataTypeInt8:
+			data[i] = float64(int8(b[0]))
+		case dataTypeUint16:
+			data[i] = float64(binary.LittleEndian.Uint16(b))
+		case dataTypeInt16:
+			data[i] = float64(int16(binary.LittleEndian.Uint16(b)))
+		case dataTypeFloat:
+			data[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		case dataTypeInt32:
+			data[i] = float64(int32(binary.LittleEndian.Uint32(b)))
+		case dataTypeUint32:
+			data[i] = float64(binary.LittleEndian.Uint32(b))
+		case dataTypeInt64:
+			data[i] = float64(int64(binary.LittleEndian.Uint64(b)))
+		case dataTypeDouble:
+			data[i] = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		case dataTypeUint64:
+			data[i] = float64(binary.LittleEndian.Uint64(b))
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,297 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsar
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/utils/json"
+	"github.com/gorilla/websocket"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTenant    = "public"
+	defaultNamespace = "default"
+	resultOk         = "ok"
+)
+
+// Config Pulsar 连接配置，使用Pulsar WebSocket API
+type Config struct {
+	//Server WebSocket服务地址，例如：ws://127.0.0.1:8080
+	Server string
+	//Token JWT认证token
+	Token string
+	//ConnectTimeout 连接超时
+	ConnectTimeout time.Duration
+}
+
+func (c *Config) dial(path string, params url.Values) (*websocket.Conn, error) {
+	if c.Server == "" {
+		return nil, errors.New("server can not empty")
+	}
+	wsUrl := strings.TrimSuffix(c.Server, "/") + path
+	if len(params) > 0 {
+		wsUrl += "?" + params.Encode()
+	}
+	header := http.Header{}
+	if c.Token != "" {
+		header.Set("Authorization", "Bearer "+c.Token)
+	}
+	dialer := websocket.Dialer{HandshakeTimeout: c.ConnectTimeout, Proxy: http.ProxyFromEnvironment}
+	conn, response, err := dialer.Dial(wsUrl, header)
+	if err != nil && response != nil {
+		return nil, fmt.Errorf("%s,status=%s", err, response.Status)
+	}
+	return conn, err
+}
+
+// TopicPath 把主题转换成路径格式：{persistent|non-persistent}/{tenant}/{namespace}/{topic}
+// 支持完整主题名persistent://tenant/namespace/topic，或者简写topic(默认public/default命名空间)
+func TopicPath(topic string) (string, error) {
+	domain := "persistent"
+	if idx := strings.Index(topic, "://"); idx > 0 {
+		domain = topic[:idx]
+		topic = topic[idx+3:]
+	}
+	if domain != "persistent" && domain != "non-persistent" {
+		return "", fmt.Errorf("invalid topic domain %s", domain)
+	}
+	parts := strings.Split(topic, "/")
+	switch len(parts) {
+	case 1:
+		parts = []string{defaultTenant, defaultNamespace, parts[0]}
+	case 3:
+	default:
+		return "", fmt.Errorf("invalid topic %s", topic)
+	}
+	for _, item := range parts {
+		if item == "" {
+			return "", fmt.Errorf("invalid topic %s", topic)
+		}
+	}
+	return domain + "/" + strings.Join(parts, "/"), nil
+}
+
+// ProducerMessage 发送的消息
+type ProducerMessage struct {
+	//Payload 消息内容
+	Payload string
+	//Key 消息key，用于分区路由和Key_Shared订阅
+	Key string
+	//Properties 消息属性
+	Properties map[string]string
+}
+
+type producerRequest struct {
+	Payload    string            `json:"payload"`
+	Key        string            `json:"key,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Context    string            `json:"context,omitempty"`
+}
+
+type producerResponse struct {
+	Result    string `json:"result"`
+	MessageId string `json:"messageId"`
+	ErrorMsg  string `json:"errorMsg"`
+	Context   string `json:"context"`
+}
+
+// Producer 生产者，一个生产者对应一个主题
+type Producer struct {
+	conn *websocket.Conn
+	//sendTimeout 等待服务端确认超时
+	sendTimeout time.Duration
+	sync.Mutex
+}
+
+// NewProducer 创建生产者
+func NewProducer(conf Config, topic string) (*Producer, error) {
+	topicPath, err := TopicPath(topic)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := conf.dial("/ws/v2/producer/"+topicPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	sendTimeout := conf.ConnectTimeout
+	if sendTimeout <= 0 {
+		sendTimeout = 30 * time.Second
+	}
+	return &Producer{conn: conn, sendTimeout: sendTimeout}, nil
+}
+
+// Send 发送消息并等待服务端确认，返回消息ID
+func (p *Producer) Send(msg ProducerMessage) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+	request := producerRequest{
+		Payload:    base64.StdEncoding.EncodeToString([]byte(msg.Payload)),
+		Key:        msg.Key,
+		Properties: msg.Properties,
+	}
+	if err := p.conn.WriteJSON(request); err != nil {
+		return "", err
+	}
+	_ = p.conn.SetReadDeadline(time.Now().Add(p.sendTimeout))
+	var response producerResponse
+	if err := p.conn.ReadJSON(&response); err != nil {
+		return "", err
+	}
+	if response.Result != resultOk {
+		return "", fmt.Errorf("%s: %s", response.Result, response.ErrorMsg)
+	}
+	return response.MessageId, nil
+}
+
+// Close 关闭
+func (p *Producer) Close() error {
+	return p.conn.Close()
+}
+
+// ConsumerMessage 接收的消息
+type ConsumerMessage struct {
+	//MessageId 消息ID，用于确认
+	MessageId string `json:"messageId"`
+	//Payload 消息内容
+	Payload string `json:"payload"`
+	//Properties 消息属性
+	Properties map[string]string `json:"properties"`
+	//PublishTime 发布时间
+	PublishTime string `json:"publishTime"`
+	//Key 消息key
+	Key string `json:"key"`
+	//RedeliveryCount 重新投递次数
+	RedeliveryCount int `json:"redeliveryCount"`
+}
+
+// Consumer 消费者
+type Consumer struct {
+	conn *websocket.Conn
+	//写锁，确认消息可能在不同协程
+	writeLock sync.Mutex
+}
+
+// NewConsumer 创建消费者
+// subscriptionType 订阅类型：Exclusive、Shared、Failover、Key_Shared
+func NewConsumer(conf Config, topic, subscription, subscriptionType string) (*Consumer, error) {
+	topicPath, err := TopicPath(topic)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == "" {
+		return nil, errors.New("subscription can not empty")
+	}
+	params := url.Values{}
+	if subscriptionType != "" {
+		params.Set("subscriptionType", subscriptionType)
+	}
+	conn, err := conf.dial("/ws/v2/consumer/"+topicPath+"/"+url.PathEscape(subscription), params)
+	if err != nil {
+		return nil, err
+	}
+	return &Consumer{conn: conn}, nil
+}
+
+// Receive 阻塞接收一条消息，Payload已经base64解码
+func (c *Consumer) Receive() (ConsumerMessage, error) {
+	var msg ConsumerMessage
+	if err := c.conn.ReadJSON(&msg); err != nil {
+		return msg, err
+	}
+	payload, err := base64.StdEncoding.DecodeString(msg.Payload)
+	if err != nil {
+		return msg, err
+	}
+	msg.Payload = string(payload)
+	return msg, nil
+}
+
+// Ack 确认消息
+func (c *Consumer) Ack(messageId string) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.conn.WriteJSON(map[string]string{"messageId": messageId})
+}
+
+// Nack 否认消息，服务端稍后重新投递
+func (c *Consumer) Nack(messageId string) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.conn.WriteJSON(map[string]string{"type": "negativeAcknowledge", "messageId": messageId})
+}
+
+// Close 关闭
+func (c *Consumer) Close() error {
+	return c.conn.Close()
+}
+
+// Schema 主题schema
+type Schema struct {
+	//Type schema类型，例如：JSON、AVRO、STRING
+	Type string `json:"type"`
+	//Schema schema定义，JSON和AVRO类型为Avro schema定义
+	Schema string `json:"schema"`
+	//Properties schema属性
+	Properties map[string]string `json:"properties"`
+}
+
+// UploadSchema 通过admin REST接口上传主题schema
+// adminUrl 例如：http://127.0.0.1:8080
+func UploadSchema(adminUrl, token, topic string, schema Schema, timeout time.Duration) error {
+	topicPath, err := TopicPath(topic)
+	if err != nil {
+		return err
+	}
+	if schema.Properties == nil {
+		schema.Properties = map[string]string{}
+	}
+	body, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+	//schema接口路径不包含持久化类型
+	path := topicPath[strings.Index(topicPath, "/")+1:]
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(adminUrl, "/")+"/admin/v2/schemas/"+path+"/schema", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("upload schema error,status=%s,body=%s", response.Status, string(b))
+	}
+	return nil
+}
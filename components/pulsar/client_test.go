@@ -0,0 +1,113 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsar
+
+import (
+	"encoding/base64"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/json"
+	"github.com/gorilla/websocket"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTopicPath(t *testing.T) {
+	path, err := TopicPath("t1")
+	assert.Nil(t, err)
+	assert.Equal(t, "persistent/public/default/t1", path)
+	path, err = TopicPath("non-persistent://tenant1/ns1/t1")
+	assert.Nil(t, err)
+	assert.Equal(t, "non-persistent/tenant1/ns1/t1", path)
+	_, err = TopicPath("kafka://tenant1/ns1/t1")
+	assert.NotNil(t, err)
+	_, err = TopicPath("ns1/t1")
+	assert.NotNil(t, err)
+}
+
+func TestProducerAndConsumer(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	var schema Schema
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/admin/v2/schemas/") {
+			assert.Equal(t, "/admin/v2/schemas/public/default/t1/schema", r.URL.Path)
+			b, _ := ioutil.ReadAll(r.Body)
+			_ = json.Unmarshal(b, &schema)
+			return
+		}
+		assert.Equal(t, "Bearer token1", r.Header.Get("Authorization"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		switch {
+		case r.URL.Path == "/ws/v2/producer/persistent/public/default/t1":
+			for {
+				var request producerRequest
+				if conn.ReadJSON(&request) != nil {
+					return
+				}
+				payload, _ := base64.StdEncoding.DecodeString(request.Payload)
+				if string(payload) == "error" {
+					_ = conn.WriteJSON(producerResponse{Result: "send-error:3", ErrorMsg: "failed"})
+				} else {
+					_ = conn.WriteJSON(producerResponse{Result: "ok", MessageId: "CAAQAw==:" + request.Key})
+				}
+			}
+		case r.URL.Path == "/ws/v2/consumer/persistent/public/default/t1/sub1":
+			assert.Equal(t, "Key_Shared", r.URL.Query().Get("subscriptionType"))
+			_ = conn.WriteJSON(ConsumerMessage{MessageId: "m1", Payload: base64.StdEncoding.EncodeToString([]byte("hello")), Key: "k1"})
+			var ack map[string]string
+			_ = conn.ReadJSON(&ack)
+			assert.Equal(t, "m1", ack["messageId"])
+			_ = conn.ReadJSON(&ack)
+			assert.Equal(t, "negativeAcknowledge", ack["type"])
+		}
+	}))
+	defer server.Close()
+
+	conf := Config{Server: "ws" + strings.TrimPrefix(server.URL, "http"), Token: "token1", ConnectTimeout: time.Second}
+	producer, err := NewProducer(conf, "t1")
+	assert.Nil(t, err)
+	defer producer.Close()
+	messageId, err := producer.Send(ProducerMessage{Payload: "hello", Key: "k1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "CAAQAw==:k1", messageId)
+	_, err = producer.Send(ProducerMessage{Payload: "error"})
+	assert.Equal(t, "send-error:3: failed", err.Error())
+
+	consumer, err := NewConsumer(conf, "t1", "sub1", "Key_Shared")
+	assert.Nil(t, err)
+	defer consumer.Close()
+	msg, err := consumer.Receive()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", msg.Payload)
+	assert.Equal(t, "k1", msg.Key)
+	assert.Nil(t, consumer.Ack(msg.MessageId))
+	assert.Nil(t, consumer.Nack(msg.MessageId))
+
+	err = UploadSchema(server.URL, "", "t1", Schema{Type: "JSON", Schema: "{}"}, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, "JSON", schema.Type)
+
+	_, err = NewProducer(Config{Server: "ws://127.0.0.1:1", ConnectTimeout: time.Second}, "t1")
+	assert.NotNil(t, err)
+}
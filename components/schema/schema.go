@@ -0,0 +1,236 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package schema payload schema注册中心
+// 按主题(subject)注册多个版本的schema(JSON Schema或者Avro)，以及相邻版本之间的升级转换，
+// 可以检测payload符合哪个版本的schema，并逐个版本升级到最新版本
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/js"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Upgrader 把payload从某个版本升级到下一个版本，payload为json解码后的值
+type Upgrader func(payload interface{}) (interface{}, error)
+
+// Schema 注册的payload schema
+type Schema struct {
+	//Subject 主题，例如：thermostat.telemetry
+	Subject string
+	//Version 版本，从小到大递增
+	Version int
+	//Format 格式：jsonSchema、avro
+	Format string
+	//Definition schema定义
+	Definition string
+	validator  validator
+}
+
+// Validate 校验payload是否符合schema
+func (s *Schema) Validate(payload interface{}) error {
+	return s.validator.Validate(payload)
+}
+
+// subject 主题的所有版本
+type subject struct {
+	schemas  map[int]*Schema
+	upgrades map[int]Upgrader
+	versions []int
+}
+
+// Registry schema注册中心
+type Registry struct {
+	subjects map[string]*subject
+	sync.RWMutex
+}
+
+// DefaultRegistry 默认schema注册中心，`schemaUpgrade`节点默认使用
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry 创建schema注册中心
+func NewRegistry() *Registry {
+	return &Registry{subjects: make(map[string]*subject)}
+}
+
+// Register 注册schema，相同主题和版本的schema会被覆盖
+func (r *Registry) Register(subjectName string, version int, format, definition string) error {
+	v, err := newValidator(format, definition)
+	if err != nil {
+		return err
+	}
+	r.Lock()
+	defer r.Unlock()
+	item := r.getOrCreate(subjectName)
+	if _, ok := item.schemas[version]; !ok {
+		item.versions = append(item.versions, version)
+		sort.Ints(item.versions)
+	}
+	item.schemas[version] = &Schema{Subject: subjectName, Version: version, Format: format, Definition: definition, validator: v}
+	return nil
+}
+
+// RegisterUpgrade 注册从fromVersion升级到下一个版本的转换
+func (r *Registry) RegisterUpgrade(subjectName string, fromVersion int, upgrader Upgrader) {
+	r.Lock()
+	defer r.Unlock()
+	r.getOrCreate(subjectName).upgrades[fromVersion] = upgrader
+}
+
+// RegisterScriptUpgrade 注册js脚本实现的升级转换，脚本是函数体，例如：
+//
+//	payload.temperature = payload.temp; delete payload.temp; return payload;
+func (r *Registry) RegisterScriptUpgrade(subjectName string, fromVersion int, script string) {
+	jsEngine := js.NewGojaJsEngine(types.NewConfig(), "function Upgrade(payload) { "+script+"\n}", nil)
+	r.RegisterUpgrade(subjectName, fromVersion, func(payload interface{}) (interface{}, error) {
+		return jsEngine.Execute("Upgrade", payload)
+	})
+}
+
+// Get 获取指定版本的schema
+func (r *Registry) Get(subjectName string, version int) (*Schema, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	if item, ok := r.subjects[subjectName]; ok {
+		s, ok := item.schemas[version]
+		return s, ok
+	}
+	return nil, false
+}
+
+// Latest 获取最新版本的schema
+func (r *Registry) Latest(subjectName string) (*Schema, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	if item, ok := r.subjects[subjectName]; ok && len(item.versions) > 0 {
+		return item.schemas[item.versions[len(item.versions)-1]], true
+	}
+	return nil, false
+}
+
+// Versions 获取主题所有版本，从小到大排序
+func (r *Registry) Versions(subjectName string) []int {
+	r.RLock()
+	defer r.RUnlock()
+	if item, ok := r.subjects[subjectName]; ok {
+		return append([]int(nil), item.versions...)
+	}
+	return nil
+}
+
+// Detect 检测payload符合的schema版本，从最新版本开始匹配
+func (r *Registry) Detect(subjectName string, payload interface{}) (int, error) {
+	versions := r.Versions(subjectName)
+	if len(versions) == 0 {
+		return 0, errors.New("subject not found:" + subjectName)
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		if s, ok := r.Get(subjectName, versions[i]); ok && s.Validate(payload) == nil {
+			return versions[i], nil
+		}
+	}
+	return 0, fmt.Errorf("payload does not match any version of subject %s", subjectName)
+}
+
+// Upgrade 把payload从version逐个版本升级到最新版本，并使用最新版本的schema校验结果
+// 返回升级后的payload和最新版本
+func (r *Registry) Upgrade(subjectName string, version int, payload interface{}) (interface{}, int, error) {
+	versions := r.Versions(subjectName)
+	if len(versions) == 0 {
+		return nil, 0, errors.New("subject not found:" + subjectName)
+	}
+	latest := versions[len(versions)-1]
+	if version == latest {
+		return payload, latest, nil
+	}
+	r.RLock()
+	item := r.subjects[subjectName]
+	var upgraders []Upgrader
+	for _, v := range versions {
+		if v < version {
+			continue
+		}
+		if v == latest {
+			break
+		}
+		upgrader, ok := item.upgrades[v]
+		if !ok {
+			r.RUnlock()
+			return nil, 0, fmt.Errorf("upgrade of subject %s from version %d not found", subjectName, v)
+		}
+		upgraders = append(upgraders, upgrader)
+	}
+	latestSchema := item.schemas[latest]
+	r.RUnlock()
+	if len(upgraders) == 0 {
+		return nil, 0, fmt.Errorf("version %d of subject %s not found", version, subjectName)
+	}
+	var err error
+	for _, upgrader := range upgraders {
+		if payload, err = upgrader(payload); err != nil {
+			return nil, 0, err
+		}
+	}
+	//js脚本返回的值转换成json值再校验
+	if payload, err = normalize(payload); err != nil {
+		return nil, 0, err
+	}
+	if err = latestSchema.Validate(payload); err != nil {
+		return nil, 0, err
+	}
+	return payload, latest, nil
+}
+
+// getOrCreate 调用方需要持有锁
+func (r *Registry) getOrCreate(subjectName string) *subject {
+	item, ok := r.subjects[subjectName]
+	if !ok {
+		item = &subject{schemas: make(map[int]*Schema), upgrades: make(map[int]Upgrader)}
+		r.subjects[subjectName] = item
+	}
+	return item
+}
+
+// ParseVersion 解析版本号
+func ParseVersion(v interface{}) (int, error) {
+	switch value := v.(type) {
+	case int:
+		return value, nil
+	case float64:
+		return int(value), nil
+	case string:
+		return strconv.Atoi(value)
+	default:
+		return 0, fmt.Errorf("invalid version:%v", v)
+	}
+}
+
+// normalize 通过json编码和解码，把值转换成标准的json值
+func normalize(v interface{}) (interface{}, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	err = json.Unmarshal(content, &result)
+	return result, err
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+)
+
+const (
+	v1 = `{"type":"object","properties":{"temp":{"type":"number"}},"required":["temp"],"additionalProperties":false}`
+	v2 = `{"type":"object","properties":{"temperature":{"type":"number"}},"required":["temperature"],"additionalProperties":false}`
+	v3 = `{"type":"record","name":"Telemetry","fields":[{"name":"temperature","type":"double"},{"name":"unit","type":"string"}]}`
+)
+
+func decode(t *testing.T, data string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func newTestRegistry(t *testing.T) *Registry {
+	r := NewRegistry()
+	assert.Nil(t, r.Register("telemetry", 1, FormatJsonSchema, v1))
+	assert.Nil(t, r.Register("telemetry", 2, FormatJsonSchema, v2))
+	assert.Nil(t, r.Register("telemetry", 3, FormatAvro, v3))
+	r.RegisterUpgrade("telemetry", 1, func(payload interface{}) (interface{}, error) {
+		m := payload.(map[string]interface{})
+		m["temperature"] = m["temp"]
+		delete(m, "temp")
+		return m, nil
+	})
+	r.RegisterScriptUpgrade("telemetry", 2, "payload.unit='C'; return payload;")
+	return r
+}
+
+func TestRegistry(t *testing.T) {
+	r := newTestRegistry(t)
+	assert.Equal(t, []int{1, 2, 3}, r.Versions("telemetry"))
+	latest, ok := r.Latest("telemetry")
+	assert.True(t, ok)
+	assert.Equal(t, 3, latest.Version)
+	assert.Equal(t, FormatAvro, latest.Format)
+
+	assert.NotNil(t, r.Register("telemetry", 4, "xml", "<a/>"))
+	assert.NotNil(t, r.Register("telemetry", 4, FormatJsonSchema, "{"))
+	assert.NotNil(t, r.Register("telemetry", 4, FormatAvro, `{"type":"unknown"}`))
+}
+
+func TestDetectAndUpgrade(t *testing.T) {
+	r := newTestRegistry(t)
+
+	payload := decode(t, `{"temp":21.5}`)
+	version, err := r.Detect("telemetry", payload)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, version)
+	result, latest, err := r.Upgrade("telemetry", version, payload)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, latest)
+	assert.Equal(t, map[string]interface{}{"temperature": 21.5, "unit": "C"}, result)
+
+	version, err = r.Detect("telemetry", decode(t, `{"temperature":20}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, version)
+
+	version, err = r.Detect("telemetry", decode(t, `{"temperature":20,"unit":"F"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, version)
+	result, latest, err = r.Upgrade("telemetry", version, decode(t, `{"temperature":20,"unit":"F"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, latest)
+	assert.Equal(t, map[string]interface{}{"temperature": float64(20), "unit": "F"}, result)
+
+	_, err = r.Detect("telemetry", decode(t, `{"humidity":20}`))
+	assert.NotNil(t, err)
+	_, err = r.Detect("notFound", payload)
+	assert.NotNil(t, err)
+	_, _, err = r.Upgrade("telemetry", 5, payload)
+	assert.NotNil(t, err)
+}
+
+func TestUpgradeFail(t *testing.T) {
+	r := NewRegistry()
+	assert.Nil(t, r.Register("s", 1, FormatJsonSchema, v1))
+	assert.Nil(t, r.Register("s", 2, FormatJsonSchema, v2))
+	//缺少升级转换
+	_, _, err := r.Upgrade("s", 1, decode(t, `{"temp":1}`))
+	assert.NotNil(t, err)
+	//升级结果不符合最新版本
+	r.RegisterScriptUpgrade("s", 1, "return payload;")
+	_, _, err = r.Upgrade("s", 1, decode(t, `{"temp":1}`))
+	assert.NotNil(t, err)
+}
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("2")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, v)
+	v, err = ParseVersion(float64(3))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, v)
+	_, err = ParseVersion(true)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/linkedin/goavro/v2"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"strings"
+)
+
+const (
+	FormatJsonSchema = "jsonSchema"
+	FormatAvro       = "avro"
+)
+
+// validator 校验json解码后的payload
+type validator interface {
+	Validate(payload interface{}) error
+}
+
+func newValidator(format, definition string) (validator, error) {
+	switch format {
+	case FormatJsonSchema, "":
+		s, err := jsonschema.CompileString("schema.json", definition)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonSchemaValidator{schema: s}, nil
+	case FormatAvro:
+		codec, err := goavro.NewCodecForStandardJSON(definition)
+		if err != nil {
+			return nil, err
+		}
+		return &avroValidator{codec: codec}, nil
+	default:
+		return nil, errors.New("unsupported schema format:" + format)
+	}
+}
+
+// jsonSchemaValidator JSON Schema校验
+type jsonSchemaValidator struct {
+	schema *jsonschema.Schema
+}
+
+func (v *jsonSchemaValidator) Validate(payload interface{}) error {
+	return v.schema.Validate(payload)
+}
+
+// avroValidator Avro校验，payload使用标准json表示，union类型不需要使用类型名包装
+type avroValidator struct {
+	codec *goavro.Codec
+}
+
+func (v *avroValidator) Validate(payload interface{}) error {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, remaining, err := v.codec.NativeFromTextual(content)
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(remaining))) > 0 {
+		return errors.New("unexpected trailing data")
+	}
+	return nil
+}
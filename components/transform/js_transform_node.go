@@ -27,7 +27,6 @@ package transform
 //        }
 //      }
 import (
-	"fmt"
 	"github.com/2018yuli/rulego/api/types"
 	"github.com/2018yuli/rulego/components/js"
 	"github.com/2018yuli/rulego/utils/json"
@@ -45,6 +44,9 @@ type JsTransformNodeConfiguration struct {
 	//对msg、metadata、msgType 进行转换、增强
 	//完整脚本函数：
 	//function Transform(msg, metadata, msgType) { ${JsScript} }
+	//脚本使用了await则声明为async函数，可以使用fetch访问http接口，参考`types.Config.JsFetch`
+	//脚本可以通过state.get/put读写当前节点的状态，通过metrics.inc/observe记录指标，
+	//store.get/put为跨规则链共享的存储，不是节点状态，参考js包说明
 	//return {'msg':msg,'metadata':metadata,'msgType':msgType};
 	JsScript string
 }
@@ -55,6 +57,7 @@ type JsTransformNodeConfiguration struct {
 // msg:是消息的payload
 // msgType:是消息的 type
 // 法返回结构:return {'msg':msg,'metadata':metadata,'msgType':msgType};
+// 返回的msg为对象或者数组时消息数据类型为JSON，否则为TEXT，也可以通过'dataType'指定数据类型
 // 脚本执行成功，发送信息到`Success`链, 否则发到`Failure`链。
 type JsTransformNode struct {
 	config   JsTransformNodeConfiguration
@@ -66,6 +69,14 @@ func (x *JsTransformNode) Type() string {
 	return "jsTransform"
 }
 
+// Descriptor 组件描述
+func (x *JsTransformNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"js", "script"},
+		Description: "使用JS脚本转换消息、元数据和消息类型",
+	}
+}
+
 func (x *JsTransformNode) New() types.Node {
 	return &JsTransformNode{}
 }
@@ -74,7 +85,7 @@ func (x *JsTransformNode) New() types.Node {
 func (x *JsTransformNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &x.config)
 	if err == nil {
-		jsScript := fmt.Sprintf("function Transform(msg, metadata, msgType) { %s }", x.config.JsScript)
+		jsScript := js.FuncScript("Transform", "msg, metadata, msgType", x.config.JsScript)
 		x.jsEngine = js.NewGojaJsEngine(ruleConfig, jsScript, nil)
 	}
 	return err
@@ -90,7 +101,7 @@ func (x *JsTransformNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error
 		}
 	}
 
-	out, err := x.jsEngine.Execute("Transform", data, msg.Metadata.Values(), msg.Type)
+	out, err := js.Execute(ctx, x.jsEngine, "Transform", data, msg.Metadata.Values(), msg.Type)
 
 	if err != nil {
 		ctx.TellFailure(msg, err)
@@ -108,6 +119,11 @@ func (x *JsTransformNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error
 
 			if formatMsgData, ok := formatData[types.MsgKey]; ok {
 				msg.Data = string2.ToString(formatMsgData)
+				msg.DataType = dataTypeOf(formatMsgData, msg.DataType)
+			}
+			if formatDataType, ok := formatData[types.DataTypeKey]; ok {
+				//脚本指定数据类型
+				msg.DataType = types.DataType(string2.ToString(formatDataType))
 			}
 
 			//ctx.Config().Logger.Printf("jsTransform用时：%s", time.Since(start))
@@ -123,7 +139,31 @@ func (x *JsTransformNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error
 	return err
 }
 
+// Metrics 实现types.MetricsProvider，返回脚本通过metrics对象记录的指标
+func (x *JsTransformNode) Metrics() map[string]interface{} {
+	if x.jsEngine == nil {
+		return nil
+	}
+	return js.Metrics(x.jsEngine)
+}
+
 // Destroy 销毁
 func (x *JsTransformNode) Destroy() {
 	x.jsEngine.Stop()
 }
+
+// dataTypeOf 脚本返回的消息内容对应的数据类型，对象、数组和JSON对象或者数组文本为JSON，否则为TEXT
+func dataTypeOf(data interface{}, origin types.DataType) types.DataType {
+	switch v := data.(type) {
+	case map[string]interface{}, []interface{}:
+		return types.JSON
+	case string:
+		return types.DetectDataType([]byte(v))
+	default:
+		if origin == types.JSON {
+			//数字和布尔值也是合法的JSON
+			return types.JSON
+		}
+		return types.TEXT
+	}
+}
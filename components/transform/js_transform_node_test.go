@@ -17,10 +17,18 @@
 package transform
 
 import (
+	"fmt"
 	"github.com/2018yuli/rulego/api/types"
 	"github.com/2018yuli/rulego/test"
 	"github.com/2018yuli/rulego/test/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestJsTransformNodeOnMsg(t *testing.T) {
@@ -52,3 +60,117 @@ func TestJsTransformNodeOnMsg(t *testing.T) {
 	}
 
 }
+
+func TestJsTransformNodeDataType(t *testing.T) {
+	config := types.NewConfig()
+	tests := []struct {
+		script   string
+		dataType types.DataType
+		expected types.DataType
+	}{
+		{"return {'msg':{'a':1},'metadata':metadata,'msgType':msgType};", types.TEXT, types.JSON},
+		{"return {'msg':'hello','metadata':metadata,'msgType':msgType};", types.JSON, types.TEXT},
+		{"return {'msg':'[1,2]','metadata':metadata,'msgType':msgType};", types.TEXT, types.JSON},
+		{"return {'msg':42,'metadata':metadata,'msgType':msgType};", types.JSON, types.JSON},
+		{"return {'msg':'aGk=','metadata':metadata,'msgType':msgType,'dataType':'BINARY'};", types.TEXT, types.BINARY},
+	}
+	for _, item := range tests {
+		var node JsTransformNode
+		err := node.Init(config, types.Configuration{"jsScript": item.script})
+		assert.Nil(t, err)
+		var dataType types.DataType
+		ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+			dataType = msg.DataType
+		})
+		msg := types.NewMsg(0, "TEST", item.dataType, types.NewMetadata(), "{}")
+		assert.Nil(t, node.OnMsg(ctx, msg))
+		assert.Equal(t, item.expected, dataType)
+	}
+}
+
+func TestJsTransformNodeFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		case "/redirect":
+			http.Redirect(w, r, "http://example.com", http.StatusFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"method":"` + r.Method + `","device":"` + r.Header.Get("X-Device") + `","body":` + string(body) + `}`))
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+
+	run := func(config types.Config, script string) (types.RuleMsg, string) {
+		var node JsTransformNode
+		assert.Nil(t, node.Init(config, types.Configuration{"jsScript": script}))
+		var result types.RuleMsg
+		var relation string
+		ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+			result, relation = msg, relationType
+		})
+		metaData := types.NewMetadata()
+		metaData.PutValue("url", server.URL)
+		_ = node.OnMsg(ctx, types.NewMsg(0, "TEST", types.JSON, metaData, `{"temperature":41}`))
+		return result, relation
+	}
+
+	config := types.NewConfig(types.WithJsFetch(types.JsFetchConfig{AllowedHosts: []string{target.Hostname()}}))
+	msg, relation := run(config, `
+		const res = await fetch(metadata.url + '/lookup', {method: 'POST', headers: {'X-Device': 'd1'}, body: msg});
+		const data = await res.json();
+		metadata.status = res.status;
+		return {'msg': data, 'metadata': metadata, 'msgType': msgType};
+	`)
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, `{"body":{"temperature":41},"device":"d1","method":"POST"}`, msg.Data)
+	assert.Equal(t, "200", msg.Metadata.GetValue("status"))
+
+	//不允许访问的主机
+	_, relation = run(config, "const res = await fetch('http://example.com'); return {'msg': msg, 'metadata': metadata, 'msgType': msgType};")
+	assert.Equal(t, types.Failure, relation)
+	_, relation = run(config, "const res = await fetch(metadata.url + '/redirect'); return {'msg': msg, 'metadata': metadata, 'msgType': msgType};")
+	assert.Equal(t, types.Failure, relation)
+
+	//请求不超过脚本执行时间
+	config.JsMaxExecutionTime = 100 * time.Millisecond
+	start := time.Now()
+	_, relation = run(config, "const res = await fetch(metadata.url + '/slow'); return {'msg': msg, 'metadata': metadata, 'msgType': msgType};")
+	assert.Equal(t, types.Failure, relation)
+	assert.True(t, time.Since(start) < 400*time.Millisecond)
+
+	//没有配置JsFetch
+	msg, relation = run(types.NewConfig(), "try { await fetch(metadata.url); } catch (e) { msgType = String(e); } return {'msg': msg, 'metadata': metadata, 'msgType': msgType};")
+	assert.Equal(t, types.Success, relation)
+	assert.True(t, strings.Contains(msg.Type, "fetch is disabled"))
+
+	//没有使用await的脚本不受影响
+	msg, relation = run(config, "return {'msg': msg, 'metadata': metadata, 'msgType': msgType};")
+	assert.Equal(t, types.Success, relation)
+	assert.True(t, strings.Contains(msg.Data, "temperature"))
+}
+
+func TestJsTransformNodeAsyncConcurrent(t *testing.T) {
+	var node JsTransformNode
+	config := types.NewConfig()
+	assert.Nil(t, node.Init(config, types.Configuration{
+		"jsScript": "const v = await Promise.resolve(msg.index); return {'msg': {'index': v}, 'metadata': metadata, 'msgType': msgType};",
+	}))
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			var data string
+			ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+				data = msg.Data
+			})
+			_ = node.OnMsg(ctx, types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), fmt.Sprintf(`{"index":%d}`, index)))
+			assert.Equal(t, fmt.Sprintf(`{"index":%d}`, index), data)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+// 规则链节点配置示例：
+//
+//	{
+//	       "id": "s2",
+//	       "type": "schemaUpgrade",
+//	       "name": "升级payload到最新schema版本",
+//	       "debugMode": false,
+//	       "configuration": {
+//	         "subject": "${productKey}.telemetry",
+//	         "versionKey": "schemaVersion"
+//	       }
+//	     }
+import (
+	"encoding/json"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/schema"
+	"github.com/2018yuli/rulego/utils/maps"
+	"github.com/2018yuli/rulego/utils/str"
+	"strconv"
+)
+
+const (
+	//SchemaVersionKey 升级后的schema版本元数据key
+	SchemaVersionKey = "schemaVersion"
+	//SchemaFromVersionKey 检测到的原始schema版本元数据key
+	SchemaFromVersionKey = "schemaFromVersion"
+)
+
+func init() {
+	Registry.Add(&SchemaUpgradeNode{})
+}
+
+// SchemaUpgradeNodeConfiguration 节点配置
+type SchemaUpgradeNodeConfiguration struct {
+	//Subject schema主题，可以使用 ${metaKeyName} 替换元数据中的变量
+	Subject string
+	//VersionKey 如果元数据存在该key，则直接使用它的值作为payload版本，否则按schema检测版本
+	VersionKey string
+}
+
+// SchemaUpgradeNode 检测payload的schema版本，并使用schema注册中心注册的升级转换升级到最新版本
+// schema和升级转换通过`components/schema`包的DefaultRegistry注册
+// 升级成功，把最新版本写入元数据schemaVersion，原始版本写入元数据schemaFromVersion，发送消息到`Success`链
+// payload不符合任何版本或者升级失败，发送消息到`Failure`链
+type SchemaUpgradeNode struct {
+	config   SchemaUpgradeNodeConfiguration
+	registry *schema.Registry
+}
+
+// Type 组件类型
+func (x *SchemaUpgradeNode) Type() string {
+	return "schemaUpgrade"
+}
+
+// Descriptor 组件描述
+func (x *SchemaUpgradeNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{
+		Tags:        []string{"schema", "version", "upgrade"},
+		Description: "检测payload的schema版本，并升级到最新版本",
+	}
+}
+
+func (x *SchemaUpgradeNode) New() types.Node {
+	return &SchemaUpgradeNode{}
+}
+
+// Init 初始化
+func (x *SchemaUpgradeNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &x.config)
+	if err == nil && x.config.Subject == "" {
+		err = errors.New("subject can not empty")
+	}
+	x.registry = schema.DefaultRegistry
+	return err
+}
+
+// OnMsg 处理消息
+func (x *SchemaUpgradeNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	err := x.upgrade(&msg)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+	} else {
+		ctx.TellSuccess(msg)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (x *SchemaUpgradeNode) Destroy() {
+}
+
+func (x *SchemaUpgradeNode) upgrade(msg *types.RuleMsg) error {
+	subject := str.SprintfDict(x.config.Subject, msg.Metadata.Values())
+	var payload interface{}
+	if err := json.Unmarshal([]byte(msg.Data), &payload); err != nil {
+		return err
+	}
+	var version int
+	var err error
+	if x.config.VersionKey != "" && msg.Metadata.Has(x.config.VersionKey) {
+		version, err = schema.ParseVersion(msg.Metadata.GetValue(x.config.VersionKey))
+	} else {
+		version, err = x.registry.Detect(subject, payload)
+	}
+	if err != nil {
+		return err
+	}
+	result, latest, err := x.registry.Upgrade(subject, version, payload)
+	if err != nil {
+		return err
+	}
+	if latest != version {
+		content, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Data = string(content)
+	}
+	msg.DataType = types.JSON
+	msg.Metadata.PutValue(SchemaFromVersionKey, strconv.Itoa(version))
+	msg.Metadata.PutValue(SchemaVersionKey, strconv.Itoa(latest))
+	return nil
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transform
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/schema"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+)
+
+func TestSchemaUpgradeNode(t *testing.T) {
+	assert.Nil(t, schema.DefaultRegistry.Register("test.telemetry", 1, schema.FormatJsonSchema,
+		`{"type":"object","properties":{"temp":{"type":"number"}},"required":["temp"]}`))
+	assert.Nil(t, schema.DefaultRegistry.Register("test.telemetry", 2, schema.FormatJsonSchema,
+		`{"type":"object","properties":{"temperature":{"type":"number"}},"required":["temperature"],"not":{"required":["temp"]}}`))
+	schema.DefaultRegistry.RegisterScriptUpgrade("test.telemetry", 1, "payload.temperature=payload.temp; delete payload.temp; return payload;")
+
+	var node SchemaUpgradeNode
+	err := node.Init(types.NewConfig(), types.Configuration{"subject": "${productKey}.telemetry", "versionKey": "version"})
+	assert.Nil(t, err)
+
+	var data, relation, fromVersion, version string
+	ctx := test.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string) {
+		data = msg.Data
+		relation = relationType
+		fromVersion, _ = msg.Metadata.GetValue(SchemaFromVersionKey).(string)
+		version, _ = msg.Metadata.GetValue(SchemaVersionKey).(string)
+	})
+	metadata := types.NewMetadata()
+	metadata.PutValue("productKey", "test")
+
+	//检测版本并升级
+	_ = node.OnMsg(ctx, ctx.NewMsg("TEST", metadata, `{"temp":21}`))
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, `{"temperature":21}`, data)
+	assert.Equal(t, "1", fromVersion)
+	assert.Equal(t, "2", version)
+
+	//已经是最新版本
+	_ = node.OnMsg(ctx, ctx.NewMsg("TEST", metadata, `{"temperature":22}`))
+	assert.Equal(t, types.Success, relation)
+	assert.Equal(t, `{"temperature":22}`, data)
+	assert.Equal(t, "2", fromVersion)
+
+	//不符合任何版本
+	err = node.OnMsg(ctx, ctx.NewMsg("TEST", metadata, `{"humidity":50}`))
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relation)
+
+	//使用元数据指定版本
+	metadata.PutValue("version", "1")
+	err = node.OnMsg(ctx, ctx.NewMsg("TEST", metadata, `{"temperature":23}`))
+	assert.NotNil(t, err)
+	assert.Equal(t, types.Failure, relation)
+
+	err = node.New().Init(types.NewConfig(), types.Configuration{})
+	assert.NotNil(t, err)
+}
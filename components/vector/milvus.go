@@ -0,0 +1,115 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vector
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	//MilvusIdField milvus主键字段名
+	MilvusIdField = "id"
+	//MilvusVectorField milvus向量字段名
+	MilvusVectorField = "vector"
+)
+
+// MilvusStore Milvus RESTful(v2)接口客户端
+// 集合需要包含主键字段id和向量字段vector，payload的字段作为标量字段写入，需要开启动态字段或者在集合中定义
+type MilvusStore struct {
+	restClient
+}
+
+// NewMilvusStore 创建Milvus客户端
+func NewMilvusStore(config Config) *MilvusStore {
+	return &MilvusStore{restClient: newRestClient(config, func(req *http.Request, apiKey string) {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	})}
+}
+
+// milvusResponse milvus响应，code不为0表示错误
+type milvusResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func (s *MilvusStore) Upsert(collection string, points []Point) error {
+	var data []map[string]interface{}
+	for _, p := range points {
+		item := make(map[string]interface{}, len(p.Payload)+2)
+		for k, v := range p.Payload {
+			item[k] = v
+		}
+		if v, err := strconv.ParseInt(p.Id, 10, 64); err == nil {
+			item[MilvusIdField] = v
+		} else {
+			item[MilvusIdField] = p.Id
+		}
+		item[MilvusVectorField] = p.Vector
+		data = append(data, item)
+	}
+	var resp milvusResponse
+	err := s.do(http.MethodPost, "/v2/vectordb/entities/upsert",
+		map[string]interface{}{"collectionName": collection, "data": data}, &resp)
+	if err == nil && resp.Code != 0 {
+		err = errors.New(resp.Message)
+	}
+	return err
+}
+
+func (s *MilvusStore) Query(collection string, vector []float64, topK int, scoreThreshold float64) ([]Match, error) {
+	body := map[string]interface{}{
+		"collectionName": collection,
+		"data":           [][]float64{vector},
+		"annsField":      MilvusVectorField,
+		"limit":          topK,
+		"outputFields":   []string{"*"},
+	}
+	var resp milvusResponse
+	if err := s.do(http.MethodPost, "/v2/vectordb/entities/search", body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != 0 {
+		return nil, errors.New(resp.Message)
+	}
+	var data []map[string]interface{}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, err
+	}
+	matches := make([]Match, 0, len(data))
+	for _, item := range data {
+		score, _ := item["distance"].(float64)
+		if scoreThreshold != 0 && score < scoreThreshold {
+			continue
+		}
+		match := Match{Id: idString(item[MilvusIdField]), Score: score, Payload: make(map[string]interface{})}
+		for k, v := range item {
+			if k != MilvusIdField && k != MilvusVectorField && k != "distance" {
+				match.Payload[k] = v
+			}
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+func (s *MilvusStore) Close() error {
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vector
+
+import (
+	"database/sql"
+	"encoding/json"
+	_ "github.com/lib/pq"
+	"strconv"
+	"strings"
+)
+
+// PgVectorStore 基于postgres pgvector扩展的客户端
+// collection为表名，表结构：
+//
+//	CREATE TABLE items (id text PRIMARY KEY, embedding vector(3), payload jsonb)
+//
+// 使用余弦距离查询，分数为 1 - 距离
+type PgVectorStore struct {
+	db *sql.DB
+}
+
+// NewPgVectorStore 创建pgvector客户端
+func NewPgVectorStore(dsn string) (*PgVectorStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &PgVectorStore{db: db}, nil
+}
+
+func (s *PgVectorStore) Upsert(collection string, points []Point) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	query := "INSERT INTO " + quoteIdentifier(collection) + " (id, embedding, payload) VALUES ($1, $2, $3)" +
+		" ON CONFLICT (id) DO UPDATE SET embedding = EXCLUDED.embedding, payload = EXCLUDED.payload"
+	for _, p := range points {
+		payload, err := json.Marshal(p.Payload)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if _, err = tx.Exec(query, p.Id, vectorLiteral(p.Vector), string(payload)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PgVectorStore) Query(collection string, vector []float64, topK int, scoreThreshold float64) ([]Match, error) {
+	query := "SELECT id, payload, 1 - (embedding <=> $1) AS score FROM " + quoteIdentifier(collection) +
+		" ORDER BY embedding <=> $1 LIMIT $2"
+	rows, err := s.db.Query(query, vectorLiteral(vector), topK)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var matches []Match
+	for rows.Next() {
+		var match Match
+		var payload []byte
+		if err = rows.Scan(&match.Id, &payload, &match.Score); err != nil {
+			return nil, err
+		}
+		if scoreThreshold != 0 && match.Score < scoreThreshold {
+			continue
+		}
+		if len(payload) > 0 {
+			if err = json.Unmarshal(payload, &match.Payload); err != nil {
+				return nil, err
+			}
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+func (s *PgVectorStore) Close() error {
+	return s.db.Close()
+}
+
+// vectorLiteral 转换成pgvector的文本格式，例如：[1,2,3]
+func vectorLiteral(vector []float64) string {
+	items := make([]string, len(vector))
+	for i, v := range vector {
+		items[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return "[" + strings.Join(items, ",") + "]"
+}
+
+// quoteIdentifier 转义表名，支持schema.table格式
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
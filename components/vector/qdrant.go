@@ -0,0 +1,77 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vector
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// QdrantStore Qdrant REST接口客户端
+// 点ID只支持无符号整数和UUID，数值字符串会转换成整数
+type QdrantStore struct {
+	restClient
+}
+
+// NewQdrantStore 创建Qdrant客户端
+func NewQdrantStore(config Config) *QdrantStore {
+	return &QdrantStore{restClient: newRestClient(config, qdrantAuth)}
+}
+
+func (s *QdrantStore) Upsert(collection string, points []Point) error {
+	var items []map[string]interface{}
+	for _, p := range points {
+		var id interface{} = p.Id
+		if v, err := strconv.ParseUint(p.Id, 10, 64); err == nil {
+			id = v
+		}
+		items = append(items, map[string]interface{}{"id": id, "vector": p.Vector, "payload": p.Payload})
+	}
+	return s.do(http.MethodPut, "/collections/"+url.PathEscape(collection)+"/points?wait=true",
+		map[string]interface{}{"points": items}, nil)
+}
+
+func (s *QdrantStore) Query(collection string, vector []float64, topK int, scoreThreshold float64) ([]Match, error) {
+	body := map[string]interface{}{"vector": vector, "limit": topK, "with_payload": true}
+	if scoreThreshold != 0 {
+		body["score_threshold"] = scoreThreshold
+	}
+	var resp struct {
+		Result []struct {
+			Id      interface{}            `json:"id"`
+			Score   float64                `json:"score"`
+			Payload map[string]interface{} `json:"payload"`
+		} `json:"result"`
+	}
+	if err := s.do(http.MethodPost, "/collections/"+url.PathEscape(collection)+"/points/search", body, &resp); err != nil {
+		return nil, err
+	}
+	matches := make([]Match, 0, len(resp.Result))
+	for _, item := range resp.Result {
+		matches = append(matches, Match{Id: idString(item.Id), Score: item.Score, Payload: item.Payload})
+	}
+	return matches, nil
+}
+
+func (s *QdrantStore) Close() error {
+	return nil
+}
+
+func qdrantAuth(req *http.Request, apiKey string) {
+	req.Header.Set("api-key", apiKey)
+}
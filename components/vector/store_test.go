@@ -0,0 +1,122 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vector
+
+import (
+	"encoding/json"
+	"github.com/2018yuli/rulego/test/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQdrantStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key1", r.Header.Get("api-key"))
+		var body map[string]interface{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		switch r.URL.Path {
+		case "/collections/docs/points":
+			assert.Equal(t, http.MethodPut, r.Method)
+			points := body["points"].([]interface{})
+			assert.Equal(t, 2, len(points))
+			//数值ID转换成整数
+			assert.Equal(t, 1.0, points[0].(map[string]interface{})["id"])
+			assert.Equal(t, "550e8400-e29b-41d4-a716-446655440000", points[1].(map[string]interface{})["id"])
+			_, _ = w.Write([]byte(`{"result":{"status":"completed"},"status":"ok"}`))
+		case "/collections/docs/points/search":
+			assert.Equal(t, 2.0, body["limit"])
+			assert.Equal(t, 0.5, body["score_threshold"])
+			_, _ = w.Write([]byte(`{"result":[{"id":1,"score":0.9,"payload":{"title":"a"}}],"status":"ok"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"status":{"error":"Not found: Collection"}}`))
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewStore(Config{Provider: Qdrant, Server: server.URL + "/", ApiKey: "key1"})
+	assert.Nil(t, err)
+	defer store.Close()
+	err = store.Upsert("docs", []Point{
+		{Id: "1", Vector: []float64{0.1, 0.2}, Payload: map[string]interface{}{"title": "a"}},
+		{Id: "550e8400-e29b-41d4-a716-446655440000", Vector: []float64{0.3, 0.4}},
+	})
+	assert.Nil(t, err)
+	matches, err := store.Query("docs", []float64{0.1, 0.2}, 2, 0.5)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(matches))
+	assert.Equal(t, "1", matches[0].Id)
+	assert.Equal(t, 0.9, matches[0].Score)
+	assert.Equal(t, "a", matches[0].Payload["title"])
+
+	_, err = store.Query("none", []float64{0.1, 0.2}, 2, 0)
+	assert.NotNil(t, err)
+}
+
+func TestMilvusStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer root:Milvus", r.Header.Get("Authorization"))
+		var body map[string]interface{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["collectionName"] != "docs" {
+			_, _ = w.Write([]byte(`{"code":100,"message":"collection not found"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/vectordb/entities/upsert":
+			item := body["data"].([]interface{})[0].(map[string]interface{})
+			assert.Equal(t, 7.0, item["id"])
+			assert.Equal(t, "a", item["title"])
+			assert.Equal(t, 2, len(item["vector"].([]interface{})))
+			_, _ = w.Write([]byte(`{"code":0,"data":{"upsertCount":1}}`))
+		case "/v2/vectordb/entities/search":
+			assert.Equal(t, "vector", body["annsField"])
+			_, _ = w.Write([]byte(`{"code":0,"data":[{"id":7,"distance":0.8,"title":"a"},{"id":8,"distance":0.2,"title":"b"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewStore(Config{Provider: Milvus, Server: server.URL, ApiKey: "root:Milvus"})
+	assert.Nil(t, err)
+	err = store.Upsert("docs", []Point{{Id: "7", Vector: []float64{0.1, 0.2}, Payload: map[string]interface{}{"title": "a"}}})
+	assert.Nil(t, err)
+	matches, err := store.Query("docs", []float64{0.1, 0.2}, 5, 0.5)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(matches))
+	assert.Equal(t, "7", matches[0].Id)
+	assert.Equal(t, "a", matches[0].Payload["title"])
+
+	err = store.Upsert("none", []Point{{Id: "7", Vector: []float64{0.1}}})
+	assert.Equal(t, "collection not found", err.Error())
+}
+
+func TestNewStore(t *testing.T) {
+	_, err := NewStore(Config{Provider: "xx", Server: "http://127.0.0.1"})
+	assert.NotNil(t, err)
+	_, err = NewStore(Config{Provider: Qdrant})
+	assert.NotNil(t, err)
+	store, err := NewStore(Config{Provider: PgVector, Server: "postgres://127.0.0.1:5432/db"})
+	assert.Nil(t, err)
+	assert.Nil(t, store.Close())
+}
+
+func TestPgVectorHelper(t *testing.T) {
+	assert.Equal(t, "[1,0.5,-2]", vectorLiteral([]float64{1, 0.5, -2}))
+	assert.Equal(t, `"items"`, quoteIdentifier("items"))
+	assert.Equal(t, `"public"."it""ems"`, quoteIdentifier(`public.it"ems`))
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package watchdog
+
+import "sync"
+
+var watchdogs = make(map[string]*Watchdog)
+var lock sync.RWMutex
+
+// Register 注册心跳监控，`heartbeat`节点通过名称查找
+func Register(name string, w *Watchdog) {
+	lock.Lock()
+	defer lock.Unlock()
+	watchdogs[name] = w
+}
+
+// Unregister 删除心跳监控，不会停止心跳监控
+func Unregister(name string) {
+	lock.Lock()
+	defer lock.Unlock()
+	delete(watchdogs, name)
+}
+
+// Get 获取心跳监控
+func Get(name string) (*Watchdog, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+	w, ok := watchdogs[name]
+	return w, ok
+}
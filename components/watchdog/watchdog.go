@@ -0,0 +1,274 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package watchdog 心跳监控，按key记录最后活跃时间，超时没有心跳则向指定规则链发送超时事件消息
+// 规则链通过`heartbeat`节点上报心跳，可以用于设备离线检测
+package watchdog
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/clock"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultMsgType 超时事件默认的消息类型
+	DefaultMsgType = "HEARTBEAT_TIMEOUT"
+	//DefaultSaveInterval 默认持久化周期
+	DefaultSaveInterval = time.Second * 10
+)
+
+// Target 超时事件消息的接收者，一般是处理超时事件的规则引擎实例，例如：rulego.Get("deviceOffline")
+type Target interface {
+	OnMsg(msg types.RuleMsg)
+}
+
+// Config 心跳监控配置
+type Config struct {
+	//Timeout 默认超时时间，心跳可以指定每个key的超时时间
+	Timeout time.Duration
+	//MsgType 超时事件的消息类型，默认：HEARTBEAT_TIMEOUT
+	MsgType string
+	//File 持久化文件，重启后恢复所有key的计时，为空则不持久化
+	File string
+	//SaveInterval 持久化周期，默认10秒，停止时也会持久化
+	SaveInterval time.Duration
+	//Clock 时钟，默认使用系统时钟
+	Clock clock.Clock
+}
+
+// entry key的心跳记录
+type entry struct {
+	//LastSeen 最后心跳时间，unix毫秒
+	LastSeen int64 `json:"lastSeen"`
+	//Timeout 超时时间，单位毫秒
+	Timeout int64 `json:"timeout"`
+	//Metadata 最后心跳消息的元数据，超时事件消息会带上这些元数据
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	timer    clock.Timer
+}
+
+// Watchdog 心跳监控
+// 每个key超时只发送一次超时事件，收到下一次心跳后重新开始计时
+type Watchdog struct {
+	config  Config
+	target  Target
+	entries map[string]*entry
+	dirty   bool
+	ticker  clock.Ticker
+	stop    chan struct{}
+	lock    sync.Mutex
+}
+
+// New 创建心跳监控，如果配置了持久化文件，则加载文件并恢复计时，加载时已经超时的key立即发送超时事件
+func New(target Target, config Config) (*Watchdog, error) {
+	if target == nil {
+		return nil, errors.New("target can not nil")
+	}
+	if config.Timeout <= 0 {
+		return nil, errors.New("timeout must be greater than 0")
+	}
+	if config.MsgType == "" {
+		config.MsgType = DefaultMsgType
+	}
+	if config.SaveInterval <= 0 {
+		config.SaveInterval = DefaultSaveInterval
+	}
+	if config.Clock == nil {
+		config.Clock = clock.System
+	}
+	w := &Watchdog{config: config, target: target, entries: make(map[string]*entry)}
+	if config.File != "" {
+		if err := w.load(); err != nil {
+			return nil, err
+		}
+		w.stop = make(chan struct{})
+		w.ticker = config.Clock.NewTicker(config.SaveInterval)
+		go w.saveLoop(w.ticker, w.stop)
+	}
+	return w, nil
+}
+
+// Beat 记录key的心跳，timeout<=0使用默认超时时间，metadata 超时事件消息会带上这些元数据
+func (w *Watchdog) Beat(key string, timeout time.Duration, metadata map[string]interface{}) {
+	if timeout <= 0 {
+		timeout = w.config.Timeout
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	item, ok := w.entries[key]
+	if ok {
+		item.timer.Stop()
+	} else {
+		item = &entry{}
+		w.entries[key] = item
+	}
+	item.LastSeen = w.config.Clock.Now().UnixMilli()
+	item.Timeout = timeout.Milliseconds()
+	item.Metadata = metadata
+	w.schedule(key, item, timeout)
+	w.dirty = true
+}
+
+// LastSeen 获取key的最后心跳时间，已经超时或者不存在返回false
+func (w *Watchdog) LastSeen(key string) (time.Time, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if item, ok := w.entries[key]; ok {
+		return time.UnixMilli(item.LastSeen), true
+	}
+	return time.Time{}, false
+}
+
+// Remove 停止监控key，不发送超时事件
+func (w *Watchdog) Remove(key string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if item, ok := w.entries[key]; ok {
+		item.timer.Stop()
+		delete(w.entries, key)
+		w.dirty = true
+	}
+}
+
+// Save 持久化所有key的心跳记录
+func (w *Watchdog) Save() error {
+	if w.config.File == "" {
+		return nil
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.save()
+}
+
+// Stop 停止所有计时并持久化
+func (w *Watchdog) Stop() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+	for _, item := range w.entries {
+		item.timer.Stop()
+	}
+	if w.config.File == "" {
+		return nil
+	}
+	return w.save()
+}
+
+// schedule 启动计时器，调用方需要持有锁
+func (w *Watchdog) schedule(key string, item *entry, delay time.Duration) {
+	var timer clock.Timer
+	timer = w.config.Clock.AfterFunc(delay, func() {
+		w.lock.Lock()
+		//已经收到新的心跳或者已经移除
+		if current, ok := w.entries[key]; !ok || current != item || item.timer != timer {
+			w.lock.Unlock()
+			return
+		}
+		delete(w.entries, key)
+		w.dirty = true
+		w.lock.Unlock()
+		w.target.OnMsg(w.newMsg(key, item))
+	})
+	item.timer = timer
+}
+
+// newMsg 创建超时事件消息
+func (w *Watchdog) newMsg(key string, item *entry) types.RuleMsg {
+	metadata := types.BuildMetadata(item.Metadata)
+	metadata.PutValue("key", key)
+	metadata.PutValue("lastSeen", time.UnixMilli(item.LastSeen).Format(time.RFC3339))
+	data, _ := json.Marshal(map[string]interface{}{
+		"key":       key,
+		"lastSeen":  item.LastSeen,
+		"timeoutMs": item.Timeout,
+	})
+	return types.NewMsg(w.config.Clock.Now().UnixMilli(), w.config.MsgType, types.JSON, metadata, string(data))
+}
+
+// load 加载持久化文件，恢复计时
+func (w *Watchdog) load() error {
+	content, err := os.ReadFile(w.config.File)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(content) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(content, &w.entries); err != nil {
+		return err
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	now := w.config.Clock.Now()
+	for key, item := range w.entries {
+		delay := time.UnixMilli(item.LastSeen + item.Timeout).Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		w.schedule(key, item, delay)
+	}
+	return nil
+}
+
+// save 先写临时文件再重命名，调用方需要持有锁
+func (w *Watchdog) save() error {
+	content, err := json.Marshal(w.entries)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(w.config.File); dir != "" {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := w.config.File + ".tmp"
+	if err = os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, w.config.File); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+func (w *Watchdog) saveLoop(ticker clock.Ticker, stop chan struct{}) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+			w.lock.Lock()
+			if w.dirty {
+				_ = w.save()
+			}
+			w.lock.Unlock()
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package watchdog
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testTarget struct {
+	msgs []types.RuleMsg
+	lock sync.Mutex
+}
+
+func (t *testTarget) OnMsg(msg types.RuleMsg) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.msgs = append(t.msgs, msg)
+}
+
+func (t *testTarget) keys() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var keys []string
+	for _, msg := range t.msgs {
+		keys = append(keys, msg.Metadata.GetValue("key").(string))
+	}
+	return keys
+}
+
+func TestWatchdog(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	target := &testTarget{}
+	w, err := New(target, Config{Timeout: time.Minute, MsgType: "DEVICE_OFFLINE", Clock: vc})
+	assert.Nil(t, err)
+	defer w.Stop()
+
+	w.Beat("aa", 0, map[string]interface{}{"deviceName": "sensor01"})
+	w.Beat("bb", time.Minute*2, nil)
+	w.Beat("cc", 0, nil)
+	w.Remove("cc")
+	vc.Advance(time.Second * 30)
+	w.Beat("aa", 0, map[string]interface{}{"deviceName": "sensor01"})
+	vc.Advance(time.Second * 60)
+	assert.Equal(t, []string{"aa"}, target.keys())
+	assert.Equal(t, "DEVICE_OFFLINE", target.msgs[0].Type)
+	assert.Equal(t, "sensor01", target.msgs[0].Metadata.GetValue("deviceName"))
+	_, ok := w.LastSeen("aa")
+	assert.False(t, ok)
+	_, ok = w.LastSeen("bb")
+	assert.True(t, ok)
+
+	vc.Advance(time.Minute)
+	assert.Equal(t, []string{"aa", "bb"}, target.keys())
+	//超时事件只发送一次
+	vc.Advance(time.Minute * 10)
+	assert.Equal(t, 2, len(target.keys()))
+}
+
+func TestWatchdogPersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "watchdog.json")
+	vc := clock.NewVirtual(time.Now())
+	target := &testTarget{}
+	w, err := New(target, Config{Timeout: time.Minute, File: file, Clock: vc})
+	assert.Nil(t, err)
+	w.Beat("aa", 0, nil)
+	w.Beat("bb", time.Minute*10, map[string]interface{}{"deviceName": "sensor02"})
+	assert.Nil(t, w.Stop())
+
+	//重启
+	vc.Advance(time.Minute * 5)
+	target2 := &testTarget{}
+	w2, err := New(target2, Config{Timeout: time.Minute, File: file, Clock: vc})
+	assert.Nil(t, err)
+	defer w2.Stop()
+	vc.Advance(0)
+	//重启前已经超时
+	assert.Equal(t, []string{"aa"}, target2.keys())
+	vc.Advance(time.Minute * 5)
+	assert.Equal(t, []string{"aa", "bb"}, target2.keys())
+	assert.Equal(t, "sensor02", target2.msgs[1].Metadata.GetValue("deviceName"))
+}
+
+func TestWatchdogRegistry(t *testing.T) {
+	w, err := New(&testTarget{}, Config{Timeout: time.Minute})
+	assert.Nil(t, err)
+	defer w.Stop()
+	Register("test", w)
+	v, ok := Get("test")
+	assert.True(t, ok)
+	assert.Equal(t, w, v)
+	Unregister("test")
+	_, ok = Get("test")
+	assert.False(t, ok)
+
+	_, err = New(nil, Config{Timeout: time.Minute})
+	assert.NotNil(t, err)
+	_, err = New(&testTarget{}, Config{})
+	assert.NotNil(t, err)
+}
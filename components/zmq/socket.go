@@ -0,0 +1,393 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zmq
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// socket类型
+const (
+	Push = "PUSH"
+	Pull = "PULL"
+	Pub  = "PUB"
+	Sub  = "SUB"
+)
+
+// ErrClosed socket已经关闭
+var ErrClosed = errors.New("zmq socket closed")
+
+// Socket ZeroMQ socket，兼容libzmq的ZMTP 3.x协议
+// 支持PUSH/PULL和PUB/SUB模式，地址支持tcp://host:port和ipc://path
+// 一个socket可以同时绑定和连接多个地址，连接断开会自动重连
+type Socket struct {
+	socketType string
+	//SendTimeout 没有可用对端时，发送等待时间
+	SendTimeout time.Duration
+	//ReconnectInterval 重连间隔
+	ReconnectInterval time.Duration
+	//ConnectTimeout 连接超时
+	ConnectTimeout time.Duration
+	peers          []*peer
+	//next PUSH轮询下标
+	next int
+	//subscriptions SUB订阅的主题前缀
+	subscriptions map[string]bool
+	listeners     []net.Listener
+	recvCh        chan [][]byte
+	closed        chan struct{}
+	closeOnce     sync.Once
+	lock          sync.Mutex
+}
+
+// peer 对端连接
+type peer struct {
+	*conn
+	writeLock sync.Mutex
+	//subscriptions PUB端记录对端订阅的主题前缀
+	subscriptions map[string]bool
+	subLock       sync.RWMutex
+}
+
+func (p *peer) write(frames [][]byte) error {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+	return p.writeMessage(frames)
+}
+
+// matches 消息第一帧是否匹配对端订阅
+func (p *peer) matches(topic []byte) bool {
+	p.subLock.RLock()
+	defer p.subLock.RUnlock()
+	for prefix := range p.subscriptions {
+		if strings.HasPrefix(string(topic), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *peer) subscribe(prefix string, subscribe bool) {
+	p.subLock.Lock()
+	defer p.subLock.Unlock()
+	if subscribe {
+		p.subscriptions[prefix] = true
+	} else {
+		delete(p.subscriptions, prefix)
+	}
+}
+
+// NewSocket 创建socket，socketType：PUSH、PULL、PUB、SUB
+func NewSocket(socketType string) (*Socket, error) {
+	socketType = strings.ToUpper(socketType)
+	switch socketType {
+	case Push, Pull, Pub, Sub:
+	default:
+		return nil, fmt.Errorf("unsupported socket type %s", socketType)
+	}
+	return &Socket{
+		socketType:        socketType,
+		SendTimeout:       5 * time.Second,
+		ReconnectInterval: time.Second,
+		ConnectTimeout:    5 * time.Second,
+		subscriptions:     make(map[string]bool),
+		recvCh:            make(chan [][]byte, 1000),
+		closed:            make(chan struct{}),
+	}, nil
+}
+
+// Type socket类型
+func (s *Socket) Type() string {
+	return s.socketType
+}
+
+// Bind 绑定地址，例如：tcp://*:5555、ipc:///tmp/rulego.ipc
+func (s *Socket) Bind(endpoint string) error {
+	network, address, err := parseEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.lock.Unlock()
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c, true)
+		}
+	}()
+	return nil
+}
+
+// Addr 返回绑定地址，用于绑定随机端口
+func (s *Socket) Addr() net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.listeners) == 0 {
+		return nil
+	}
+	return s.listeners[0].Addr()
+}
+
+// Connect 连接地址，例如：tcp://127.0.0.1:5555，异步连接并且断开后自动重连
+func (s *Socket) Connect(endpoint string) error {
+	network, address, err := parseEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for !s.isClosed() {
+			if c, err := net.DialTimeout(network, address, s.ConnectTimeout); err == nil {
+				s.serve(c, false)
+			}
+			select {
+			case <-s.closed:
+				return
+			case <-time.After(s.ReconnectInterval):
+			}
+		}
+	}()
+	return nil
+}
+
+// Send 发送多帧消息
+// PUSH轮询发送给一个对端，没有对端则等待SendTimeout
+// PUB发送给所有订阅了第一帧前缀的对端，没有则丢弃
+func (s *Socket) Send(frames ...[]byte) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+	if len(frames) == 0 {
+		return errors.New("message can not empty")
+	}
+	switch s.socketType {
+	case Push:
+		deadline := time.Now().Add(s.SendTimeout)
+		for {
+			if p := s.nextPeer(); p != nil {
+				if err := p.write(frames); err == nil {
+					return nil
+				}
+				s.removePeer(p)
+				continue
+			}
+			if time.Now().After(deadline) {
+				return errors.New("no zmq peer available")
+			}
+			select {
+			case <-s.closed:
+				return ErrClosed
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	case Pub:
+		for _, p := range s.getPeers() {
+			if p.matches(frames[0]) {
+				if err := p.write(frames); err != nil {
+					s.removePeer(p)
+				}
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("socket type %s not support send", s.socketType)
+	}
+}
+
+// Recv 阻塞接收一条多帧消息，只支持PULL和SUB
+func (s *Socket) Recv() ([][]byte, error) {
+	if s.socketType != Pull && s.socketType != Sub {
+		return nil, fmt.Errorf("socket type %s not support recv", s.socketType)
+	}
+	select {
+	case frames := <-s.recvCh:
+		return frames, nil
+	case <-s.closed:
+		return nil, ErrClosed
+	}
+}
+
+// Subscribe SUB订阅主题前缀，空字符串表示订阅所有消息
+func (s *Socket) Subscribe(prefix string) {
+	s.lock.Lock()
+	s.subscriptions[prefix] = true
+	s.lock.Unlock()
+	for _, p := range s.getPeers() {
+		_ = p.write([][]byte{append([]byte{1}, prefix...)})
+	}
+}
+
+// Unsubscribe SUB取消订阅主题前缀
+func (s *Socket) Unsubscribe(prefix string) {
+	s.lock.Lock()
+	delete(s.subscriptions, prefix)
+	s.lock.Unlock()
+	for _, p := range s.getPeers() {
+		_ = p.write([][]byte{append([]byte{0}, prefix...)})
+	}
+}
+
+// Close 关闭所有监听和连接
+func (s *Socket) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		for _, listener := range s.listeners {
+			_ = listener.Close()
+		}
+		for _, p := range s.peers {
+			_ = p.Close()
+		}
+		s.peers = nil
+	})
+	return nil
+}
+
+func (s *Socket) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// serve 握手并处理连接，阻塞直到连接断开
+func (s *Socket) serve(c net.Conn, asServer bool) {
+	_ = c.SetDeadline(time.Now().Add(s.ConnectTimeout))
+	zc, err := handshake(c, s.socketType, asServer)
+	if err != nil {
+		_ = c.Close()
+		return
+	}
+	_ = c.SetDeadline(time.Time{})
+	p := &peer{conn: zc, subscriptions: make(map[string]bool)}
+	if s.socketType == Sub {
+		s.lock.Lock()
+		for prefix := range s.subscriptions {
+			_ = p.write([][]byte{append([]byte{1}, prefix...)})
+		}
+		s.lock.Unlock()
+	}
+	if !s.addPeer(p) {
+		_ = c.Close()
+		return
+	}
+	defer s.removePeer(p)
+	for {
+		frames, command, data, err := zc.readMessage()
+		if err != nil {
+			return
+		}
+		switch {
+		case command == "PING":
+			//ZMTP 3.1 心跳，前2字节为TTL，后面为上下文
+			if len(data) >= 2 {
+				p.writeLock.Lock()
+				_ = p.writeCommand("PONG", data[2:])
+				p.writeLock.Unlock()
+			}
+		case command == "SUBSCRIBE" || command == "CANCEL":
+			p.subscribe(string(data), command == "SUBSCRIBE")
+		case command != "":
+		case s.socketType == Pub:
+			//ZMTP 3.0 订阅消息，第一个字节1表示订阅，0表示取消订阅
+			if len(frames) == 1 && len(frames[0]) > 0 {
+				p.subscribe(string(frames[0][1:]), frames[0][0] == 1)
+			}
+		case s.socketType == Pull || s.socketType == Sub:
+			select {
+			case s.recvCh <- frames:
+			case <-s.closed:
+				return
+			}
+		}
+	}
+}
+
+func (s *Socket) addPeer(p *peer) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.isClosed() {
+		return false
+	}
+	s.peers = append(s.peers, p)
+	return true
+}
+
+func (s *Socket) removePeer(p *peer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, item := range s.peers {
+		if item == p {
+			s.peers = append(s.peers[:i], s.peers[i+1:]...)
+			break
+		}
+	}
+	_ = p.Close()
+}
+
+func (s *Socket) nextPeer() *peer {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.peers) == 0 {
+		return nil
+	}
+	s.next = (s.next + 1) % len(s.peers)
+	return s.peers[s.next]
+}
+
+func (s *Socket) getPeers() []*peer {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	peers := make([]*peer, len(s.peers))
+	copy(peers, s.peers)
+	return peers
+}
+
+// parseEndpoint 解析地址，tcp://*:5555 转换成 tcp和:5555，ipc://path 转换成 unix和path
+func parseEndpoint(endpoint string) (string, string, error) {
+	idx := strings.Index(endpoint, "://")
+	if idx < 0 {
+		return "", "", fmt.Errorf("invalid endpoint %s", endpoint)
+	}
+	scheme, address := endpoint[:idx], endpoint[idx+3:]
+	switch scheme {
+	case "tcp":
+		if strings.HasPrefix(address, "*:") {
+			address = address[1:]
+		}
+		return "tcp", address, nil
+	case "ipc":
+		return "unix", address, nil
+	default:
+		return "", "", fmt.Errorf("unsupported transport %s", scheme)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zmq
+
+import (
+	"bytes"
+	"github.com/2018yuli/rulego/test/assert"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushPull(t *testing.T) {
+	pull, _ := NewSocket(Pull)
+	defer pull.Close()
+	err := pull.Bind("tcp://127.0.0.1:0")
+	assert.Nil(t, err)
+
+	push, _ := NewSocket(Push)
+	defer push.Close()
+	err = push.Connect("tcp://" + pull.Addr().String())
+	assert.Nil(t, err)
+
+	//长帧
+	longData := bytes.Repeat([]byte("a"), 1000)
+	assert.Nil(t, push.Send([]byte("hello")))
+	assert.Nil(t, push.Send([]byte("topic"), longData))
+
+	frames, err := pull.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(frames[0]))
+	frames, err = pull.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(frames))
+	assert.Equal(t, "topic", string(frames[0]))
+	assert.Equal(t, longData, frames[1])
+
+	//PULL 不支持发送
+	assert.NotNil(t, pull.Send([]byte("hello")))
+}
+
+func TestPubSub(t *testing.T) {
+	pub, _ := NewSocket(Pub)
+	defer pub.Close()
+	err := pub.Bind("tcp://127.0.0.1:0")
+	assert.Nil(t, err)
+
+	sub, _ := NewSocket(Sub)
+	defer sub.Close()
+	sub.Subscribe("device/")
+	err = sub.Connect("tcp://" + pub.Addr().String())
+	assert.Nil(t, err)
+
+	//等待订阅生效
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		peers := pub.getPeers()
+		if len(peers) == 1 && peers[0].matches([]byte("device/")) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, pub.Send([]byte("other/aa"), []byte("ignored")))
+	assert.Nil(t, pub.Send([]byte("device/aa"), []byte("hello")))
+	frames, err := sub.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, "device/aa", string(frames[0]))
+	assert.Equal(t, "hello", string(frames[1]))
+
+	sub.Close()
+	_, err = sub.Recv()
+	assert.Equal(t, ErrClosed, err)
+}
+
+func TestHandshake(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	go func() {
+		if server, err := listener.Accept(); err == nil {
+			_, _ = handshake(server, Push, true)
+			_ = server.Close()
+		}
+	}()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	assert.Nil(t, err)
+	defer client.Close()
+	//类型不匹配
+	_, err = handshake(client, Pub, false)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "incompatible"))
+
+	network, address, err := parseEndpoint("tcp://*:5555")
+	assert.Nil(t, err)
+	assert.Equal(t, "tcp", network)
+	assert.Equal(t, ":5555", address)
+	network, address, _ = parseEndpoint("ipc:///tmp/rulego.ipc")
+	assert.Equal(t, "unix", network)
+	assert.Equal(t, "/tmp/rulego.ipc", address)
+	_, _, err = parseEndpoint("udp://127.0.0.1:5555")
+	assert.NotNil(t, err)
+
+	properties, err := decodeProperties(encodeProperties(map[string]string{"Socket-Type": Sub, "Identity": ""}))
+	assert.Nil(t, err)
+	assert.Equal(t, Sub, properties["Socket-Type"])
+}
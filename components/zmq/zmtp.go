@@ -0,0 +1,255 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zmq
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// ZMTP 3.0 协议实现，只支持NULL认证机制
+// 参考：https://rfc.zeromq.org/spec/23/
+
+const (
+	greetingSize = 64
+	flagMore     = 0x01
+	flagLong     = 0x02
+	flagCommand  = 0x04
+	//maxFrameSize 最大帧大小，防止异常数据耗尽内存
+	maxFrameSize = 256 * 1024 * 1024
+)
+
+var errInvalidGreeting = errors.New("invalid zmtp greeting")
+
+// conn ZMTP连接
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+	//peerType 对端socket类型
+	peerType string
+}
+
+// handshake 交换greeting和READY命令，检查对端socket类型
+func handshake(c net.Conn, socketType string, asServer bool) (*conn, error) {
+	greeting := make([]byte, greetingSize)
+	greeting[0] = 0xFF
+	greeting[9] = 0x7F
+	greeting[10] = 3
+	greeting[11] = 0
+	copy(greeting[12:32], "NULL")
+	if asServer {
+		greeting[32] = 1
+	}
+	if _, err := c.Write(greeting); err != nil {
+		return nil, err
+	}
+	zc := &conn{Conn: c, reader: bufio.NewReader(c)}
+	peerGreeting := make([]byte, greetingSize)
+	if _, err := io.ReadFull(zc.reader, peerGreeting); err != nil {
+		return nil, err
+	}
+	if peerGreeting[0] != 0xFF || peerGreeting[9] != 0x7F || peerGreeting[10] < 3 {
+		return nil, errInvalidGreeting
+	}
+	if mechanism := string(trimZero(peerGreeting[12:32])); mechanism != "NULL" {
+		return nil, fmt.Errorf("unsupported zmtp mechanism %s", mechanism)
+	}
+	if err := zc.writeCommand("READY", encodeProperties(map[string]string{"Socket-Type": socketType})); err != nil {
+		return nil, err
+	}
+	name, body, err := zc.readCommand()
+	if err != nil {
+		return nil, err
+	}
+	if name == "ERROR" {
+		return nil, fmt.Errorf("zmtp peer error: %s", string(body))
+	}
+	if name != "READY" {
+		return nil, fmt.Errorf("unexpected zmtp command %s", name)
+	}
+	properties, err := decodeProperties(body)
+	if err != nil {
+		return nil, err
+	}
+	zc.peerType = properties["Socket-Type"]
+	if !isCompatible(socketType, zc.peerType) {
+		_ = zc.writeCommand("ERROR", []byte("invalid socket type"))
+		return nil, fmt.Errorf("incompatible socket type %s and %s", socketType, zc.peerType)
+	}
+	return zc, nil
+}
+
+// isCompatible 检查socket类型是否匹配
+func isCompatible(socketType, peerType string) bool {
+	switch socketType {
+	case Push:
+		return peerType == Pull
+	case Pull:
+		return peerType == Push
+	case Pub:
+		return peerType == Sub || peerType == "XSUB"
+	case Sub:
+		return peerType == Pub || peerType == "XPUB"
+	}
+	return false
+}
+
+func (c *conn) writeFrame(flags byte, body []byte) error {
+	var header []byte
+	if len(body) > 255 {
+		header = make([]byte, 9)
+		header[0] = flags | flagLong
+		binary.BigEndian.PutUint64(header[1:], uint64(len(body)))
+	} else {
+		header = []byte{flags, byte(len(body))}
+	}
+	if _, err := c.Write(append(header, body...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeMessage 写多帧消息
+func (c *conn) writeMessage(frames [][]byte) error {
+	for i, frame := range frames {
+		var flags byte
+		if i < len(frames)-1 {
+			flags = flagMore
+		}
+		if err := c.writeFrame(flags, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) writeCommand(name string, data []byte) error {
+	body := append([]byte{byte(len(name))}, name...)
+	return c.writeFrame(flagCommand, append(body, data...))
+}
+
+// readFrame 读取一帧
+func (c *conn) readFrame() (flags byte, body []byte, err error) {
+	if flags, err = c.reader.ReadByte(); err != nil {
+		return
+	}
+	var size uint64
+	if flags&flagLong != 0 {
+		b := make([]byte, 8)
+		if _, err = io.ReadFull(c.reader, b); err != nil {
+			return
+		}
+		size = binary.BigEndian.Uint64(b)
+	} else {
+		var b byte
+		if b, err = c.reader.ReadByte(); err != nil {
+			return
+		}
+		size = uint64(b)
+	}
+	if size > maxFrameSize {
+		err = fmt.Errorf("zmtp frame too large: %d", size)
+		return
+	}
+	body = make([]byte, size)
+	_, err = io.ReadFull(c.reader, body)
+	return
+}
+
+func (c *conn) readCommand() (string, []byte, error) {
+	flags, body, err := c.readFrame()
+	if err != nil {
+		return "", nil, err
+	}
+	if flags&flagCommand == 0 {
+		return "", nil, errors.New("expected zmtp command")
+	}
+	return parseCommand(body)
+}
+
+func parseCommand(body []byte) (string, []byte, error) {
+	if len(body) < 1 || len(body) < int(body[0])+1 {
+		return "", nil, errors.New("invalid zmtp command")
+	}
+	size := int(body[0])
+	return string(body[1 : size+1]), body[size+1:], nil
+}
+
+// readMessage 读取一条多帧消息，command不为空表示收到命令
+func (c *conn) readMessage() (frames [][]byte, command string, data []byte, err error) {
+	for {
+		var flags byte
+		var body []byte
+		if flags, body, err = c.readFrame(); err != nil {
+			return
+		}
+		if flags&flagCommand != 0 {
+			command, data, err = parseCommand(body)
+			return
+		}
+		frames = append(frames, body)
+		if flags&flagMore == 0 {
+			return
+		}
+	}
+}
+
+func encodeProperties(properties map[string]string) []byte {
+	var b []byte
+	for k, v := range properties {
+		b = append(b, byte(len(k)))
+		b = append(b, k...)
+		size := make([]byte, 4)
+		binary.BigEndian.PutUint32(size, uint32(len(v)))
+		b = append(b, size...)
+		b = append(b, v...)
+	}
+	return b
+}
+
+func decodeProperties(b []byte) (map[string]string, error) {
+	properties := make(map[string]string)
+	for len(b) > 0 {
+		nameSize := int(b[0])
+		if len(b) < 1+nameSize+4 {
+			return nil, errors.New("invalid zmtp properties")
+		}
+		name := string(b[1 : 1+nameSize])
+		b = b[1+nameSize:]
+		valueSize := int(binary.BigEndian.Uint32(b[:4]))
+		b = b[4:]
+		if len(b) < valueSize {
+			return nil, errors.New("invalid zmtp properties")
+		}
+		properties[name] = string(b[:valueSize])
+		b = b[valueSize:]
+	}
+	return properties, nil
+}
+
+func trimZero(b []byte) []byte {
+	for i, v := range b {
+		if v == 0 {
+			return b[:i]
+		}
+	}
+	return b
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+)
+
+// dataTypeNode 包装实现了`types.DataTypeRequirer`的节点，调用OnMsg前把消息转换成节点需要的数据类型
+type dataTypeNode struct {
+	types.Node
+	requirer types.DataTypeRequirer
+}
+
+func newDataTypeNode(node types.Node) types.Node {
+	if requirer, ok := node.(types.DataTypeRequirer); ok {
+		return &dataTypeNode{Node: node, requirer: requirer}
+	}
+	return node
+}
+
+// OnMsg 转换消息数据类型，转换失败发送到`Failure`链
+func (x *dataTypeNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	converted, err := types.ConvertDataType(msg, x.requirer.RequiredDataType())
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	return x.Node.OnMsg(ctx, converted)
+}
+
+// HealthCheck 检查原节点健康状态
+func (x *dataTypeNode) HealthCheck() error {
+	if checker, ok := x.Node.(types.HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// Metrics 原节点运行指标
+func (x *dataTypeNode) Metrics() map[string]interface{} {
+	return nodeMetrics(x.Node)
+}
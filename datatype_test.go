@@ -0,0 +1,132 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// jsonTestNode 需要JSON数据的测试组件
+type jsonTestNode struct {
+}
+
+func (x *jsonTestNode) Type() string {
+	return "test/json"
+}
+
+func (x *jsonTestNode) New() types.Node {
+	return &jsonTestNode{}
+}
+
+func (x *jsonTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *jsonTestNode) RequiredDataType() types.DataType {
+	return types.JSON
+}
+
+func (x *jsonTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	if msg.DataType != types.JSON {
+		err := errors.New("not json")
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *jsonTestNode) Destroy() {
+}
+
+func TestConvertDataType(t *testing.T) {
+	tests := []struct {
+		from     types.DataType
+		data     string
+		to       types.DataType
+		expected string
+		err      bool
+	}{
+		{types.JSON, `{"a":1}`, types.JSON, `{"a":1}`, false},
+		{types.JSON, `{"a":1}`, types.TEXT, `{"a":1}`, false},
+		{types.TEXT, `{"a":1}`, types.JSON, `{"a":1}`, false},
+		{"", `[1]`, types.JSON, `[1]`, false},
+		{types.TEXT, `hello`, types.JSON, "", true},
+		{types.TEXT, `hi`, types.BINARY, "aGk=", false},
+		{types.JSON, `{}`, types.BINARY, "e30=", false},
+		{types.BINARY, "aGk=", types.TEXT, "hi", false},
+		{types.BINARY, "e30=", types.JSON, "{}", false},
+		{types.BINARY, "aGk=", types.JSON, "", true},
+		{types.BINARY, "//8=", types.TEXT, "", true},
+		{types.BINARY, "!!", types.TEXT, "", true},
+		{types.DataType("XML"), "<a/>", types.JSON, "", true},
+	}
+	for _, item := range tests {
+		msg := types.NewMsg(0, "TEST", item.from, types.NewMetadata(), item.data)
+		result, err := types.ConvertDataType(msg, item.to)
+		if item.err {
+			assert.True(t, errors.Is(err, types.ErrDataTypeConversion))
+			assert.Equal(t, item.data, result.Data)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, item.to, result.DataType)
+		assert.Equal(t, item.expected, result.Data)
+		assert.Equal(t, msg.Id, result.Id)
+	}
+	assert.Equal(t, types.JSON, types.DetectDataType([]byte(` {"a":1}`)))
+	assert.Equal(t, types.TEXT, types.DetectDataType([]byte(`123`)))
+	assert.Equal(t, types.BINARY, types.DetectDataType([]byte{0xff, 0xfe}))
+}
+
+func TestRequiredDataType(t *testing.T) {
+	_ = Registry.Register(&jsonTestNode{})
+	defer Registry.Unregister("test/json")
+	ruleEngine, err := NewChainBuilder().Id("dataType01").Node("test/json", nil).New()
+	assert.Nil(t, err)
+	defer Del("dataType01")
+
+	send := func(dataType types.DataType, data string) (types.RuleMsg, error) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		var endMsg types.RuleMsg
+		var endErr error
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", dataType, types.NewMetadata(), data), func(msg types.RuleMsg, err error) {
+			endMsg = msg
+			endErr = err
+			wg.Done()
+		})
+		waitTimeout(t, &wg, time.Second*3)
+		return endMsg, endErr
+	}
+	msg, err := send(types.TEXT, `{"temperature":30}`)
+	assert.Nil(t, err)
+	assert.Equal(t, types.JSON, msg.DataType)
+
+	msg, err = send(types.BINARY, "e30=")
+	assert.Nil(t, err)
+	assert.Equal(t, "{}", msg.Data)
+
+	msg, err = send(types.TEXT, "temperature=30")
+	assert.True(t, errors.Is(err, types.ErrDataTypeConversion))
+	assert.Equal(t, types.TEXT, msg.DataType)
+}
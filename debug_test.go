@@ -0,0 +1,120 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDebugDispatcherRateLimit(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	var lock sync.Mutex
+	var nodeIds []string
+	dispatcher := types.NewDebugDispatcher(func(flowType string, nodeId string, msg types.RuleMsg, relationType string, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		nodeIds = append(nodeIds, nodeId)
+	}, types.DebugConfig{RatePerNode: 2, Clock: vc})
+
+	for i := 0; i < 5; i++ {
+		dispatcher.OnDebug(types.In, "s1", types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), "", nil)
+	}
+	dispatcher.OnDebug(types.In, "s2", types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), "", nil)
+	//时间前进后恢复令牌
+	vc.Advance(time.Millisecond * 500)
+	dispatcher.OnDebug(types.In, "s1", types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), "", nil)
+	dispatcher.Close()
+
+	assert.Equal(t, []string{"s1", "s1", "s2", "s1"}, nodeIds)
+	assert.Equal(t, types.DebugStats{Delivered: 4, DroppedRateLimited: 3}, dispatcher.Stats())
+	//关闭后忽略
+	dispatcher.OnDebug(types.In, "s3", types.RuleMsg{}, "", nil)
+	assert.Equal(t, uint64(4), dispatcher.Stats().Delivered)
+}
+
+func TestDebugDispatcherDedup(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	var count int
+	dispatcher := types.NewDebugDispatcher(func(flowType string, nodeId string, msg types.RuleMsg, relationType string, err error) {
+		count++
+	}, types.DebugConfig{DedupWindow: time.Second, Clock: vc})
+	msg := types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}")
+	dispatcher.OnDebug(types.Out, "s1", msg, types.Success, nil)
+	dispatcher.OnDebug(types.Out, "s1", msg, types.Success, nil)
+	dispatcher.OnDebug(types.Out, "s1", msg, types.Failure, nil)
+	vc.Advance(time.Second)
+	dispatcher.OnDebug(types.Out, "s1", msg, types.Success, nil)
+	dispatcher.Close()
+	assert.Equal(t, 3, count)
+	assert.Equal(t, uint64(1), dispatcher.Stats().Deduplicated)
+}
+
+func TestDebugDispatcherQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	dispatcher := types.NewDebugDispatcher(func(flowType string, nodeId string, msg types.RuleMsg, relationType string, err error) {
+		<-release
+	}, types.DebugConfig{QueueSize: 1})
+	//回调阻塞时不阻塞规则链
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			dispatcher.OnDebug(types.In, "s1", types.RuleMsg{}, "", nil)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 3):
+		t.Fatal("OnDebug blocked")
+	}
+	close(release)
+	dispatcher.Close()
+	stats := dispatcher.Stats()
+	assert.Equal(t, uint64(10), stats.Delivered+stats.DroppedQueueFull)
+	assert.True(t, stats.DroppedQueueFull >= 8)
+}
+
+func TestDebugDispatcherWithEngine(t *testing.T) {
+	var lock sync.Mutex
+	var flows []string
+	dispatcher := types.NewDebugDispatcher(func(flowType string, nodeId string, msg types.RuleMsg, relationType string, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		flows = append(flows, flowType+":"+nodeId)
+	}, types.DebugConfig{})
+	config := NewConfig(types.WithOnDebug(dispatcher.OnDebug))
+	ruleEngine, err := NewChainBuilder().Id("debug01").
+		Node("jsFilter", types.Configuration{"jsScript": "return true;"}).Debug(true).
+		New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("debug01")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+		wg.Done()
+	})
+	waitTimeout(t, &wg, time.Second*3)
+	assert.True(t, waitFor(func() bool { return dispatcher.Stats().Delivered == 2 }))
+	dispatcher.Close()
+	assert.Equal(t, []string{types.In + ":s1", types.Out + ":s1"}, flows)
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/reconnect"
+	"sync"
+	"time"
+)
+
+// ErrNodeDegraded 节点初始化失败，处于降级状态
+var ErrNodeDegraded = errors.New("node degraded")
+
+// defaultReinitInterval 降级节点默认首次重新初始化间隔
+const defaultReinitInterval = time.Second * 5
+
+// degradedNode 降级节点，包装初始化失败的原节点，`types.Config.DegradedStart`=true时使用
+// 没有初始化成功之前，到达该节点的消息发送到`Failure`链，同时在后台按指数退避重新初始化
+type degradedNode struct {
+	types.Node
+	config types.Config
+	conn   *reconnect.Manager
+	timer  clock.Timer
+	//inited 原节点已经重新初始化成功
+	inited bool
+	//destroyed 已经销毁，初始化成功后需要立即销毁原节点
+	destroyed bool
+	lock      sync.Mutex
+}
+
+func newDegradedNode(config types.Config, node types.Node, configuration types.Configuration, initErr error) *degradedNode {
+	x := &degradedNode{Node: node, config: config}
+	interval := config.ReinitInterval
+	if interval <= 0 {
+		interval = defaultReinitInterval
+	}
+	x.conn = reconnect.New(reconnect.Config{
+		InitialInterval: interval,
+		Jitter:          0.2,
+		Clock:           config.GetClock(),
+		OnStateChange: func(state reconnect.State, err error) {
+			if config.Logger != nil {
+				types.Logf(config.Logger, types.LogLevelWarn, "degraded node state changed.node type:%s state:%s error: %v", node.Type(), state, err)
+			}
+		},
+	}, func() error {
+		return x.reinit(configuration)
+	})
+	x.conn.SetState(reconnect.Disconnected, initErr)
+	x.timer = config.GetClock().AfterFunc(interval, func() {
+		x.conn.Start()
+	})
+	return x
+}
+
+// Init 原节点在后台重新初始化
+func (x *degradedNode) Init(_ types.Config, _ types.Configuration) error {
+	return nil
+}
+
+// OnMsg 原节点没有初始化成功则发送到`Failure`链
+func (x *degradedNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	if err := x.HealthCheck(); err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	return x.Node.OnMsg(ctx, msg)
+}
+
+// HealthCheck 没有初始化成功返回初始化错误，否则检查原节点健康状态
+func (x *degradedNode) HealthCheck() error {
+	if err := x.conn.HealthCheck(); err != nil {
+		return fmt.Errorf("%w: %s", ErrNodeDegraded, err)
+	}
+	if checker, ok := x.Node.(types.HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// Metrics 重新初始化成功后返回原节点运行指标
+func (x *degradedNode) Metrics() map[string]interface{} {
+	x.lock.Lock()
+	inited := x.inited
+	x.lock.Unlock()
+	if !inited {
+		return nil
+	}
+	return nodeMetrics(x.Node)
+}
+
+// Destroy 停止重新初始化，原节点已经初始化成功则销毁
+func (x *degradedNode) Destroy() {
+	x.timer.Stop()
+	x.conn.Stop()
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.destroyed = true
+	if x.inited {
+		x.Node.Destroy()
+	}
+}
+
+func (x *degradedNode) reinit(configuration types.Configuration) error {
+	err := x.Node.Init(x.config, configuration)
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if err == nil {
+		if x.destroyed {
+			//重新初始化期间规则链已经销毁
+			x.Node.Destroy()
+		} else {
+			x.inited = true
+		}
+	}
+	return err
+}
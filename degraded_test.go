@@ -0,0 +1,103 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDegradedStart(t *testing.T) {
+	failing := &lazyTestNode{err: errors.New("connection refused")}
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	config := NewConfig(types.WithClock(vc), types.WithDegradedStart(time.Second))
+	node := newDegradedNode(config, failing, nil, failing.err)
+	assert.True(t, errors.Is(node.HealthCheck(), ErrNodeDegraded))
+	assert.True(t, strings.Contains(node.HealthCheck().Error(), "connection refused"))
+
+	var lock sync.Mutex
+	var relations []string
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string) {
+		lock.Lock()
+		defer lock.Unlock()
+		relations = append(relations, relationType)
+	})
+	assert.NotNil(t, node.OnMsg(ctx, types.RuleMsg{}))
+
+	//第一次重新初始化失败，按退避策略重试
+	assert.True(t, vc.WaitPending(1, time.Second))
+	vc.Advance(time.Second)
+	assert.True(t, waitFor(func() bool { return failing.initCount() == 1 }))
+	failing.lock.Lock()
+	failing.err = nil
+	failing.lock.Unlock()
+	assert.True(t, vc.WaitPending(1, time.Second))
+	vc.Advance(2 * time.Second)
+	assert.True(t, waitFor(func() bool { return node.HealthCheck() == nil }))
+
+	assert.Nil(t, node.OnMsg(ctx, types.RuleMsg{}))
+	assert.Equal(t, []string{types.Failure, types.Success}, relations)
+	node.Destroy()
+}
+
+func TestDegradedChain(t *testing.T) {
+	def, err := NewChainBuilder().Id("degraded01").
+		Node("jsTransform", types.Configuration{"jsScript": "return {'msg':msg,'metadata':metadata,'msgType':msgType};"}).
+		On(types.Success).
+		Node("dbClient", types.Configuration{"dbType": "unknownDriver", "sql": "select 1"}).
+		DSL()
+	assert.Nil(t, err)
+
+	//默认节点初始化失败则规则链加载失败
+	_, err = New("degraded01", def)
+	assert.NotNil(t, err)
+
+	ruleEngine, err := New("degraded01", def, WithConfig(NewConfig(types.WithDegradedStart(time.Minute))))
+	assert.Nil(t, err)
+	defer Del("degraded01")
+	health := ruleEngine.Health()
+	assert.Equal(t, 1, len(health))
+	assert.Equal(t, "s2", health[0].NodeId)
+	assert.False(t, health[0].Healthy)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+		assert.True(t, errors.Is(err, ErrNodeDegraded))
+		wg.Done()
+	})
+	waitTimeout(t, &wg, time.Second*3)
+}
+
+// waitFor 等待条件满足，超时返回false
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second * 3)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+)
+
+// dryRunNode dry-run模式节点，包装有外部副作用的节点，有副作用的消息不交给原节点处理
+type dryRunNode struct {
+	types.Node
+	sideEffect types.SideEffectNode
+	nodeId     string
+	config     types.Config
+}
+
+// newDryRunNode sideEffect为原节点，node可能已经被其他包装节点包装
+func newDryRunNode(config types.Config, node types.Node, sideEffect types.SideEffectNode, nodeId string) *dryRunNode {
+	return &dryRunNode{Node: node, sideEffect: sideEffect, nodeId: nodeId, config: config}
+}
+
+// OnMsg 记录将要执行的操作，模拟成功发送到`Success`链，没有副作用的消息交给原节点处理
+func (x *dryRunNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	action, ok := x.sideEffect.SideEffect(msg)
+	if !ok {
+		return x.Node.OnMsg(ctx, msg)
+	}
+	action.NodeId = x.nodeId
+	action.NodeType = x.Type()
+	action.Msg = msg.Copy()
+	if x.config.OnDryRun != nil {
+		x.config.OnDryRun(action)
+	}
+	msg.Metadata.PutValue(types.DryRunKey, "true")
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+// HealthCheck 检查原节点健康状态
+func (x *dryRunNode) HealthCheck() error {
+	if checker, ok := x.Node.(types.HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// Metrics 原节点运行指标
+func (x *dryRunNode) Metrics() map[string]interface{} {
+	return nodeMetrics(x.Node)
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDryRun(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(`{"temperature":60}`))
+	}))
+	defer server.Close()
+
+	var lock sync.Mutex
+	var actions []types.DryRunAction
+	config := NewConfig(types.WithDryRun(func(action types.DryRunAction) {
+		lock.Lock()
+		defer lock.Unlock()
+		actions = append(actions, action)
+	}))
+	ruleEngine, err := NewChainBuilder().Id("dryRun").
+		NodeWithId("query", "restApiCall", types.Configuration{"restEndpointUrlPattern": server.URL + "/query", "requestMethod": "GET"}).
+		On(types.Success).
+		NodeWithId("alarm", "restApiCall", types.Configuration{"restEndpointUrlPattern": server.URL + "/alarm/${deviceId}"}).
+		New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("dryRun")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	metadata := types.NewMetadata()
+	metadata.PutValue("deviceId", "aa")
+	ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, metadata, "{}"), func(msg types.RuleMsg, err error) {
+		assert.Nil(t, err)
+		assert.Equal(t, "true", msg.Metadata.GetValue(types.DryRunKey))
+		wg.Done()
+	})
+	waitTimeout(t, &wg, time.Second*3)
+
+	//GET请求正常执行，POST请求只记录
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, 1, len(actions))
+	assert.Equal(t, "alarm", actions[0].NodeId)
+	assert.Equal(t, "restApiCall", actions[0].NodeType)
+	assert.Equal(t, http.MethodPost, actions[0].Operation)
+	assert.Equal(t, server.URL+"/alarm/aa", actions[0].Target)
+	assert.Equal(t, `{"temperature":60}`, actions[0].Data)
+}
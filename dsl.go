@@ -51,6 +51,22 @@ type RuleChainBaseInfo struct {
 	Root bool `json:"root"`
 	//Configuration 规则链配置信息
 	Configuration types.Configuration `json:"configuration"`
+	//初始化方式，eager(默认):创建规则链时初始化；lazy:第一条消息到达时初始化；async:后台初始化，消息等待初始化完成
+	//用于数据库、消息中间件等初始化耗时的节点，避免外部服务不可用时阻塞规则链加载
+	InitMode string `json:"initMode,omitempty"`
+	//OrderingKey 顺序key，可以使用 ${metaKeyName} 替换元数据中的变量，例如：${deviceId}
+	//配置后相同key的消息在规则链中严格按顺序处理，上一条消息处理结束后才处理下一条，不同key的消息并行处理
+	//用于状态机、计数器等依赖消息顺序的场景。规则链有多个分支时，所有分支处理结束后才处理下一条
+	//节点异步处理需要通过`RuleContext.Async`或者`RuleContext.Stream`完成，否则节点OnMsg返回即认为处理结束
+	//为空则不保证顺序
+	OrderingKey string `json:"orderingKey,omitempty"`
+	//Extends 继承的基础规则链ID，基础规则链通过 BaseChains 注册
+	//加载时把当前规则链作为overlay合并到基础规则链，用于不同环境(dev/staging/prod)只覆盖差异的节点配置和连接，参考 ResolveExtends
+	Extends string `json:"extends,omitempty"`
+	//Description 规则链描述，解析和导出时原样保留，供可视化界面展示
+	Description string `json:"description,omitempty"`
+	//Requires 依赖的其他规则链ID，通过 Startup 启动时先启动依赖的规则链，停止时后停止，参考 NewStartup
+	Requires []string `json:"requires,omitempty"`
 }
 
 // RuleMetadata 规则链元数据定义，包含了规则链中节点和连接的信息
@@ -66,6 +82,13 @@ type RuleMetadata struct {
 	//子规则链链接
 	//每个对象代表规则链中一个节点和一个子规则链之间的连接
 	RuleChainConnections []RuleChainConnection `json:"ruleChainConnections"`
+	//节点组模板
+	//规则链内可复用的子图，通过type=template的节点实例化，参考 NodeTemplate
+	Templates []NodeTemplate `json:"templates,omitempty"`
+	//继承基础规则链时需要删除的节点ID，和节点相关的连接同时删除
+	RemoveNodes []string `json:"removeNodes,omitempty"`
+	//继承基础规则链时需要删除的连接
+	RemoveConnections []NodeConnection `json:"removeConnections,omitempty"`
 }
 
 // RuleNode 规则链节点信息定义
@@ -84,6 +107,11 @@ type RuleNode struct {
 	//例如，一个JS过滤器节点可能有一个`jsScript`字段，定义了过滤逻辑，
 	//而一个REST API调用节点可能有一个`restEndpointUrlPattern`字段，定义了要调用的URL。
 	Configuration types.Configuration `json:"configuration"`
+	//初始化方式，eager(默认):创建规则链时初始化；lazy:第一条消息到达时初始化；async:后台初始化，消息等待初始化完成
+	//用于数据库、消息中间件等初始化耗时的节点，避免外部服务不可用时阻塞规则链加载
+	InitMode string `json:"initMode,omitempty"`
+	//Description 节点描述，解析和导出时原样保留，供可视化界面展示
+	Description string `json:"description,omitempty"`
 }
 
 // ParserRuleNode 通过json解析节点结构体
@@ -93,11 +121,17 @@ func ParserRuleNode(rootRuleChain []byte) (RuleNode, error) {
 	return def, err
 }
 
-// NodeAdditionalInfo 用于可视化位置信息(预留字段)
+// NodeAdditionalInfo 用于可视化编辑器的节点位置和大小，没有位置的节点可以通过 AutoLayout 自动布局
 type NodeAdditionalInfo struct {
 	Description string `json:"description"`
-	LayoutX     int    `json:"layoutX"`
-	LayoutY     int    `json:"layoutY"`
+	//LayoutX 节点左上角横坐标
+	LayoutX int `json:"layoutX"`
+	//LayoutY 节点左上角纵坐标
+	LayoutY int `json:"layoutY"`
+	//Width 节点宽度，0表示使用编辑器默认大小
+	Width int `json:"width,omitempty"`
+	//Height 节点高度，0表示使用编辑器默认大小
+	Height int `json:"height,omitempty"`
 }
 
 // NodeConnection 规则链节点连接定义
@@ -110,6 +144,8 @@ type NodeConnection struct {
 	//连接的类型，决定了什么时候以及如何把消息从一个节点发送到另一个节点。它应该与源节点类型支持的连接类型之一匹配。
 	//例如，一个JS过滤器节点可能支持两种连接类型："True"和"False"，表示消息是否通过或者失败过滤条件。
 	Type string `json:"type"`
+	//Description 连接描述，解析和导出时原样保留，供可视化界面展示
+	Description string `json:"description,omitempty"`
 }
 
 // RuleChainConnection 子规则链连接定义
@@ -121,4 +157,6 @@ type RuleChainConnection struct {
 	ToId string `json:"toId"`
 	//连接的类型，决定了什么时候以及如何把消息从一个节点发送到另一个节点。它应该与源节点类型支持的连接类型之一匹配。
 	Type string `json:"type"`
+	//Description 连接描述，解析和导出时原样保留，供可视化界面展示
+	Description string `json:"description,omitempty"`
 }
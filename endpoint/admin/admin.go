@@ -0,0 +1,550 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package admin 管理接口，提供健康检查和运行时性能分析
+//
+//	/healthz       存活检查，进程正常则返回200
+//	/readyz        就绪检查，检查所有接入端点和规则链，全部可用返回200，否则返回503
+//	/health/nodes  按规则链返回实现了`types.HealthChecker`的节点健康状态
+//	/metrics       Prometheus文本格式的健康状态、节点panic次数、异步处理、流式输出和接入端点到规则链交接的积压指标
+//	/audit         查询规则链配置变更审计记录，需要通过WithAudit开启，
+//	               参数：chainId、actor、action、since、until(RFC3339时间)、limit
+//	/quarantine    查询规则链隔离区的毒消息，参数：chainId，DELETE删除消息，参数：chainId、msgId
+//	/quarantine/replay  POST重放隔离区的消息，参数：chainId、msgId
+//	/config        查询运行时配置，PUT修改日志级别、调试信息采样比例、协程池大小和js脚本执行超时时间，
+//	               只需要提交修改的字段，立即对新的执行生效，需要通过WithRuntimeConfig开启
+//	/debug/pprof/  运行时性能分析，需要通过WithPprof开启
+//
+// 通过WithAuth开启认证后，/healthz和/readyz仍然不需要认证，便于容器探针访问，
+// /health/nodes和/metrics需要viewer角色，/audit和/quarantine需要editor角色，只返回用户所属租户的数据，
+// /config和/debug/pprof/影响或者暴露整个进程，需要不属于任何租户的admin角色
+//
+// 通过GRPCServer或者StartGRPC提供gRPC管理接口，接口定义和生成的客户端见`api/proto/management/v1`，
+// 支持规则链查询、创建或者重新加载、删除，节点运行指标查询和节点调试信息订阅，
+// 查询需要viewer角色，修改规则链和订阅调试信息需要editor角色
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/utils/audit"
+	"github.com/2018yuli/rulego/utils/quarantine"
+	"google.golang.org/grpc"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOk   = "ok"
+	StatusFail = "fail"
+)
+
+// Check 健康检查项
+type Check struct {
+	Name  string
+	Check func() error
+}
+
+// CheckResult 健康检查项结果
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report 就绪检查结果
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Option 管理接口选项
+type Option func(s *Server)
+
+// WithRuleGo 检查规则引擎实例池中所有规则链，默认使用`rulego.DefaultRuleGo`
+func WithRuleGo(ruleGo *rulego.RuleGo) Option {
+	return func(s *Server) {
+		s.ruleGo = ruleGo
+	}
+}
+
+// WithEndpoints 检查接入端点，端点实现了`types.HealthChecker`才会检查
+func WithEndpoints(endpoints ...endpoint.Endpoint) Option {
+	return func(s *Server) {
+		s.endpoints = append(s.endpoints, endpoints...)
+	}
+}
+
+// WithCheck 增加自定义就绪检查项
+func WithCheck(name string, check func() error) Option {
+	return func(s *Server) {
+		s.checks = append(s.checks, Check{Name: name, Check: check})
+	}
+}
+
+// WithPprof 开启/debug/pprof/性能分析接口
+func WithPprof() Option {
+	return func(s *Server) {
+		s.pprof = true
+	}
+}
+
+// WithAudit 开启/audit审计记录查询接口
+func WithAudit(store audit.Store) Option {
+	return func(s *Server) {
+		s.audit = store
+	}
+}
+
+// WithRuntimeConfig 开启/config运行时配置查询和修改接口
+func WithRuntimeConfig(runtime *types.RuntimeConfig) Option {
+	return func(s *Server) {
+		s.runtime = runtime
+	}
+}
+
+// WithAuth 开启认证和基于角色的访问控制，依次使用认证器认证请求
+func WithAuth(authenticators ...Authenticator) Option {
+	return func(s *Server) {
+		s.authenticators = append(s.authenticators, authenticators...)
+	}
+}
+
+// Server 管理接口服务
+type Server struct {
+	//Addr 监听地址，例如：:9091
+	Addr      string
+	ruleGo    *rulego.RuleGo
+	endpoints []endpoint.Endpoint
+	checks    []Check
+	pprof     bool
+	audit     audit.Store
+	runtime   *types.RuntimeConfig
+	//认证器，为空则不认证
+	authenticators []Authenticator
+	//chainConfig 通过gRPC管理接口创建规则链使用的配置
+	chainConfig types.Config
+	//debug 分发调试信息给gRPC StreamDebug订阅者
+	debug      debugHub
+	mux        *http.ServeMux
+	server     *http.Server
+	grpcServer *grpc.Server
+	lock       sync.Mutex
+}
+
+// New 创建管理接口服务
+func New(addr string, opts ...Option) *Server {
+	s := &Server{Addr: addr, ruleGo: rulego.DefaultRuleGo, chainConfig: rulego.NewConfig(), mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.mux.HandleFunc("/healthz", s.healthz)
+	s.mux.HandleFunc("/readyz", s.readyz)
+	s.mux.HandleFunc("/health/nodes", s.authenticate(RoleViewer, s.nodes))
+	s.mux.HandleFunc("/metrics", s.authenticate(RoleViewer, s.metrics))
+	if s.audit != nil {
+		s.mux.HandleFunc("/audit", s.authenticate(RoleEditor, s.auditEvents))
+	}
+	s.mux.HandleFunc("/quarantine", s.authenticate(RoleEditor, s.quarantine))
+	s.mux.HandleFunc("/quarantine/replay", s.authenticate(RoleEditor, s.replay))
+	if s.runtime != nil {
+		s.mux.HandleFunc("/config", s.authenticate(RoleAdmin, unscoped(s.runtimeConfig)))
+	}
+	if s.pprof {
+		s.mux.HandleFunc("/debug/pprof/", s.authenticate(RoleAdmin, unscoped(pprof.Index)))
+		s.mux.HandleFunc("/debug/pprof/cmdline", s.authenticate(RoleAdmin, unscoped(pprof.Cmdline)))
+		s.mux.HandleFunc("/debug/pprof/profile", s.authenticate(RoleAdmin, unscoped(pprof.Profile)))
+		s.mux.HandleFunc("/debug/pprof/symbol", s.authenticate(RoleAdmin, unscoped(pprof.Symbol)))
+		s.mux.HandleFunc("/debug/pprof/trace", s.authenticate(RoleAdmin, unscoped(pprof.Trace)))
+	}
+	return s
+}
+
+// Handler 管理接口http处理器，可以挂载到已有的http服务
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Start 启动服务，阻塞直到服务停止
+func (s *Server) Start() error {
+	s.lock.Lock()
+	s.server = &http.Server{Addr: s.Addr, Handler: s.mux}
+	server := s.server
+	s.lock.Unlock()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Stop 停止服务，包括通过StartGRPC启动的gRPC服务
+func (s *Server) Stop() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.grpcServer != nil {
+		s.grpcServer.Stop()
+	}
+	if s.server != nil {
+		return s.server.Close()
+	}
+	return nil
+}
+
+// Ready 执行所有就绪检查
+func (s *Server) Ready() Report {
+	report := Report{Status: StatusOk}
+	add := func(name string, err error) {
+		result := CheckResult{Name: name, Status: StatusOk}
+		if err != nil {
+			result.Status = StatusFail
+			result.Error = err.Error()
+			report.Status = StatusFail
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	for _, ep := range s.endpoints {
+		if checker, ok := ep.(types.HealthChecker); ok {
+			add("endpoint:"+ep.Type()+":"+ep.Id(), checker.HealthCheck())
+		}
+	}
+	if s.ruleGo != nil {
+		var chains []string
+		engines := make(map[string]*rulego.RuleEngine)
+		s.ruleGo.Range(func(id string, ruleEngine *rulego.RuleEngine) bool {
+			chains = append(chains, id)
+			engines[id] = ruleEngine
+			return true
+		})
+		sort.Strings(chains)
+		for _, id := range chains {
+			add("chain:"+id, engines[id].HealthCheck())
+		}
+	}
+	for _, check := range s.checks {
+		add(check.Name, check.Check())
+	}
+	return report
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(StatusOk))
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	report := s.Ready()
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != StatusOk {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func (s *Server) nodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.health(r.Context()))
+}
+
+func (s *Server) metrics(w http.ResponseWriter, r *http.Request) {
+	var sb strings.Builder
+	sb.WriteString("# HELP rulego_check_healthy Readiness check status, 1 is healthy.\n")
+	sb.WriteString("# TYPE rulego_check_healthy gauge\n")
+	for _, item := range s.Ready().Checks {
+		fmt.Fprintf(&sb, "rulego_check_healthy{name=\"%s\"} %d\n", escapeLabel(item.Name), healthyValue(item.Status == StatusOk))
+	}
+	sb.WriteString("# HELP rulego_node_healthy Node health check status, 1 is healthy.\n")
+	sb.WriteString("# TYPE rulego_node_healthy gauge\n")
+	if health := s.health(r.Context()); len(health) > 0 {
+		var ids []string
+		for id := range health {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			for _, item := range health[id] {
+				fmt.Fprintf(&sb, "rulego_node_healthy{engine=\"%s\",chain=\"%s\",node=\"%s\",type=\"%s\"} %d\n",
+					escapeLabel(id), escapeLabel(item.ChainId), escapeLabel(item.NodeId), escapeLabel(item.Type), healthyValue(item.Healthy))
+			}
+		}
+	}
+	engines := s.engines(r.Context())
+	stats := make([]rulego.ChainStats, len(engines))
+	for i, ruleEngine := range engines {
+		stats[i] = ruleEngine.Stats()
+	}
+	for _, metric := range statsMetrics {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		for i, ruleEngine := range engines {
+			fmt.Fprintf(&sb, "%s{engine=\"%s\"} %d\n", metric.name, escapeLabel(ruleEngine.Id), metric.value(stats[i]))
+		}
+	}
+	var handoffEndpoints []endpoint.Endpoint
+	var handoffStats []endpoint.HandoffStats
+	for _, ep := range s.endpoints {
+		if provider, ok := ep.(endpoint.HandoffProvider); ok {
+			handoffEndpoints = append(handoffEndpoints, ep)
+			handoffStats = append(handoffStats, provider.Handoff().Stats())
+		}
+	}
+	if len(handoffEndpoints) > 0 {
+		for _, metric := range handoffMetrics {
+			fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+			for i, ep := range handoffEndpoints {
+				fmt.Fprintf(&sb, "%s{endpoint=\"%s\",id=\"%s\"} %s\n", metric.name, escapeLabel(ep.Type()), escapeLabel(ep.Id()),
+					strconv.FormatFloat(metric.value(handoffStats[i]), 'f', -1, 64))
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(sb.String()))
+}
+
+func (s *Server) auditEvents(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	query := audit.Query{
+		ChainId: values.Get("chainId"),
+		Actor:   values.Get("actor"),
+		Action:  values.Get("action"),
+	}
+	var err error
+	if v := values.Get("since"); v != "" {
+		if query.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := values.Get("until"); v != "" {
+		if query.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "invalid until: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := values.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if principal, ok := PrincipalFromContext(r.Context()); ok && principal.Tenant != "" {
+		query.Tenant = principal.Tenant
+	}
+	events, err := s.audit.Query(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []audit.Event{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(events)
+}
+
+func (s *Server) quarantine(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		chainId := r.URL.Query().Get("chainId")
+		entries := []quarantine.Entry{}
+		for _, ruleEngine := range s.engines(r.Context()) {
+			if ruleEngine.Config.Quarantine == nil || (chainId != "" && chainId != ruleEngine.Id) {
+				continue
+			}
+			items, err := ruleEngine.Quarantined()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			entries = append(entries, items...)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(entries)
+	case http.MethodDelete:
+		if ruleEngine, msgId, ok := s.quarantinedMsg(w, r); ok {
+			writeQuarantineResult(w, ruleEngine.Discard(msgId))
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) runtimeConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		//未提交的字段保持当前值
+		settings := s.runtime.Settings()
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.runtime.Update(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.runtime.Settings())
+}
+
+func (s *Server) replay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ruleEngine, msgId, ok := s.quarantinedMsg(w, r); ok {
+		writeQuarantineResult(w, ruleEngine.Replay(msgId))
+	}
+}
+
+// quarantinedMsg 获取请求参数指定的规则引擎实例和消息ID，用户无权访问该规则链则返回404
+func (s *Server) quarantinedMsg(w http.ResponseWriter, r *http.Request) (*rulego.RuleEngine, string, bool) {
+	values := r.URL.Query()
+	chainId, msgId := values.Get("chainId"), values.Get("msgId")
+	if chainId == "" || msgId == "" {
+		http.Error(w, "chainId and msgId are required", http.StatusBadRequest)
+		return nil, "", false
+	}
+	for _, ruleEngine := range s.engines(r.Context()) {
+		if ruleEngine.Id == chainId {
+			return ruleEngine, msgId, true
+		}
+	}
+	http.Error(w, "rule chain not found", http.StatusNotFound)
+	return nil, "", false
+}
+
+func writeQuarantineResult(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, quarantine.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, rulego.ErrQuarantineNotConfigured):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// engines 获取请求用户可以访问的规则引擎实例，按ID排序
+func (s *Server) engines(ctx context.Context) []*rulego.RuleEngine {
+	var result []*rulego.RuleEngine
+	if s.ruleGo == nil {
+		return result
+	}
+	principal, authenticated := PrincipalFromContext(ctx)
+	s.ruleGo.Range(func(id string, ruleEngine *rulego.RuleEngine) bool {
+		if !authenticated || principal.AllowTenant(ruleEngine.Config.Tenant) {
+			result = append(result, ruleEngine)
+		}
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+// health 获取请求用户可以访问的规则引擎实例节点健康状态
+func (s *Server) health(ctx context.Context) map[string][]rulego.NodeHealth {
+	health := make(map[string][]rulego.NodeHealth)
+	if s.ruleGo == nil {
+		return health
+	}
+	principal, authenticated := PrincipalFromContext(ctx)
+	s.ruleGo.Range(func(id string, ruleEngine *rulego.RuleEngine) bool {
+		if !authenticated || principal.AllowTenant(ruleEngine.Config.Tenant) {
+			health[id] = ruleEngine.Health()
+		}
+		return true
+	})
+	return health
+}
+
+// statsMetric 规则链运行统计指标
+type statsMetric struct {
+	name  string
+	help  string
+	kind  string
+	value func(stats rulego.ChainStats) int64
+}
+
+var statsMetrics = []statsMetric{
+	{"rulego_node_panics_total", "Number of panics recovered while nodes were processing messages.", "counter",
+		func(stats rulego.ChainStats) int64 { return int64(stats.Panics) }},
+	{"rulego_async_pending", "Number of messages waiting for async completion by nodes.", "gauge",
+		func(stats rulego.ChainStats) int64 { return stats.AsyncPending }},
+	{"rulego_async_timeouts_total", "Number of async completions and streams that timed out.", "counter",
+		func(stats rulego.ChainStats) int64 { return int64(stats.AsyncTimeouts) }},
+	{"rulego_streams_active", "Number of node streams emitting messages.", "gauge",
+		func(stats rulego.ChainStats) int64 { return stats.StreamsActive }},
+	{"rulego_stream_chunks_total", "Number of messages emitted by node streams.", "counter",
+		func(stats rulego.ChainStats) int64 { return int64(stats.StreamChunks) }},
+}
+
+// handoffMetric 接入端点到规则链交接的指标
+type handoffMetric struct {
+	name  string
+	help  string
+	kind  string
+	value func(stats endpoint.HandoffStats) float64
+}
+
+var handoffMetrics = []handoffMetric{
+	{"rulego_endpoint_handoff_pending", "Number of messages handed to rule chains and not yet completed.", "gauge",
+		func(stats endpoint.HandoffStats) float64 { return float64(stats.Pending) }},
+	{"rulego_endpoint_handoff_waiting", "Number of messages waiting for the chain backlog to drain.", "gauge",
+		func(stats endpoint.HandoffStats) float64 { return float64(stats.Waiting) }},
+	{"rulego_endpoint_paused", "Whether the endpoint paused consumption because of backpressure, 1 is paused.", "gauge",
+		func(stats endpoint.HandoffStats) float64 { return float64(healthyValue(stats.Paused)) }},
+	{"rulego_endpoint_handoffs_total", "Number of messages handed to rule chains.", "counter",
+		func(stats endpoint.HandoffStats) float64 { return float64(stats.Handoffs) }},
+	{"rulego_endpoint_pauses_total", "Number of times the endpoint paused consumption.", "counter",
+		func(stats endpoint.HandoffStats) float64 { return float64(stats.Pauses) }},
+	{"rulego_endpoint_handoff_wait_seconds_total", "Total time messages waited for the chain backlog to drain.", "counter",
+		func(stats endpoint.HandoffStats) float64 { return stats.WaitTime.Seconds() }},
+	{"rulego_endpoint_handoff_waits_total", "Number of messages that waited for the chain backlog to drain.", "counter",
+		func(stats endpoint.HandoffStats) float64 { return float64(stats.Waits) }},
+	{"rulego_endpoint_handoff_rejected_total", "Number of messages rejected after waiting longer than maxWaitMs.", "counter",
+		func(stats endpoint.HandoffStats) float64 { return float64(stats.Rejected) }},
+}
+
+var labelReplacer = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+// escapeLabel 转义Prometheus标签值
+func escapeLabel(v string) string {
+	return labelReplacer.Replace(v)
+}
+
+func healthyValue(healthy bool) int {
+	if healthy {
+		return 1
+	}
+	return 0
+}
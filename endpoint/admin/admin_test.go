@@ -0,0 +1,361 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/audit"
+	"github.com/2018yuli/rulego/utils/quarantine"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var healthErr error
+
+// healthTestNode 测试健康检查的节点
+type healthTestNode struct {
+}
+
+func (x *healthTestNode) Type() string {
+	return "test/health"
+}
+
+func (x *healthTestNode) New() types.Node {
+	return &healthTestNode{}
+}
+
+func (x *healthTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *healthTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *healthTestNode) Destroy() {
+}
+
+func (x *healthTestNode) HealthCheck() error {
+	return healthErr
+}
+
+// healthTestEndpoint 测试健康检查的接入端点
+type healthTestEndpoint struct {
+	endpoint.Endpoint
+	err error
+}
+
+func (e *healthTestEndpoint) Type() string {
+	return "test"
+}
+
+func (e *healthTestEndpoint) Id() string {
+	return "ep01"
+}
+
+func (e *healthTestEndpoint) HealthCheck() error {
+	return e.err
+}
+
+// handoffTestEndpoint 提供到规则链交接指标的接入端点
+type handoffTestEndpoint struct {
+	healthTestEndpoint
+	handoff *endpoint.Handoff
+}
+
+func (e *handoffTestEndpoint) Handoff() *endpoint.Handoff {
+	return e.handoff
+}
+
+func TestAdminHandoffMetrics(t *testing.T) {
+	ep := &handoffTestEndpoint{handoff: endpoint.NewHandoff(endpoint.BackpressureConfig{MaxPending: 1, MaxWaitMs: 1})}
+	assert.Nil(t, ep.handoff.Acquire(context.Background()))
+	assert.Equal(t, endpoint.ErrBackpressure, ep.handoff.Acquire(context.Background()))
+	server := New(":0", WithRuleGo(&rulego.RuleGo{}), WithEndpoints(ep))
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	resp, err := http.Get(httpServer.URL + "/metrics")
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.True(t, strings.Contains(string(body), `rulego_endpoint_handoff_pending{endpoint="test",id="ep01"} 1`))
+	assert.True(t, strings.Contains(string(body), `rulego_endpoint_paused{endpoint="test",id="ep01"} 1`))
+	assert.True(t, strings.Contains(string(body), `rulego_endpoint_handoff_rejected_total{endpoint="test",id="ep01"} 1`))
+	assert.True(t, strings.Contains(string(body), `rulego_endpoint_handoff_waits_total{endpoint="test",id="ep01"} 1`))
+}
+
+func TestAdminServer(t *testing.T) {
+	_ = rulego.Registry.Register(&healthTestNode{})
+	defer rulego.Registry.Unregister("test/health")
+	ruleGo := &rulego.RuleGo{}
+	defer ruleGo.Stop()
+	def, err := rulego.NewChainBuilder().Id("health01").Node("test/health", nil).DSL()
+	assert.Nil(t, err)
+	_, err = ruleGo.New("health01", def)
+	assert.Nil(t, err)
+
+	ep := &healthTestEndpoint{}
+	customErr := errors.New("disk full")
+	server := New(":0", WithRuleGo(ruleGo), WithEndpoints(ep), WithCheck("disk", func() error {
+		return customErr
+	}), WithPprof())
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	resp, err := http.Get(httpServer.URL + "/healthz")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	getReady := func() (int, Report) {
+		resp, err := http.Get(httpServer.URL + "/readyz")
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		var report Report
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&report))
+		return resp.StatusCode, report
+	}
+
+	code, report := getReady()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, []CheckResult{
+		{Name: "endpoint:test:ep01", Status: StatusOk},
+		{Name: "chain:health01", Status: StatusOk},
+		{Name: "disk", Status: StatusFail, Error: "disk full"},
+	}, report.Checks)
+
+	customErr = nil
+	healthErr = errors.New("db down")
+	ep.err = errors.New("broker disconnected")
+	code, report = getReady()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "broker disconnected", report.Checks[0].Error)
+	assert.Equal(t, "node s1: db down", report.Checks[1].Error)
+
+	healthErr = nil
+	ep.err = nil
+	code, report = getReady()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOk, report.Status)
+
+	resp, err = http.Get(httpServer.URL + "/health/nodes")
+	assert.Nil(t, err)
+	var nodes map[string][]rulego.NodeHealth
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&nodes))
+	_ = resp.Body.Close()
+	assert.Equal(t, map[string][]rulego.NodeHealth{
+		"health01": {{ChainId: "health01", NodeId: "s1", Type: "test/health", Healthy: true}},
+	}, nodes)
+
+	healthErr = errors.New("db down")
+	resp, err = http.Get(httpServer.URL + "/metrics")
+	assert.Nil(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.True(t, strings.Contains(string(body), `rulego_check_healthy{name="chain:health01"} 0`))
+	assert.True(t, strings.Contains(string(body), `rulego_check_healthy{name="endpoint:test:ep01"} 1`))
+	assert.True(t, strings.Contains(string(body), `rulego_node_healthy{engine="health01",chain="health01",node="s1",type="test/health"} 0`))
+	assert.True(t, strings.Contains(string(body), `rulego_node_panics_total{engine="health01"} 0`))
+	healthErr = nil
+
+	resp, err = http.Get(httpServer.URL + "/debug/pprof/goroutine?debug=1")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+}
+
+func TestAdminServerWithoutPprof(t *testing.T) {
+	server := New(":0", WithRuleGo(&rulego.RuleGo{}))
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+	resp, err := http.Get(httpServer.URL + "/debug/pprof/")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	_ = resp.Body.Close()
+	assert.Equal(t, StatusOk, server.Ready().Status)
+}
+
+func TestEscapeLabel(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\n`, escapeLabel("a\"b\\c\n"))
+}
+
+func TestAdminAudit(t *testing.T) {
+	store := audit.NewMemory(0)
+	_ = store.Record(audit.Event{Actor: "lala", Action: audit.ActionCreate, ChainId: "chain01", Tenant: "t1"})
+	_ = store.Record(audit.Event{Actor: "lulu", Action: audit.ActionUpdate, ChainId: "chain02", Tenant: "t2"})
+	server := New(":0", WithRuleGo(&rulego.RuleGo{}), WithAudit(store), WithAuth(NewAPIKeyAuthenticator(map[string]Principal{
+		"viewerKey": {Role: RoleViewer},
+		"editorKey": {Role: RoleEditor, Tenant: "t2"},
+		"adminKey":  {Role: RoleAdmin},
+	})))
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	get := func(path, key string) (int, []audit.Event) {
+		req, _ := http.NewRequest(http.MethodGet, httpServer.URL+path, nil)
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		var events []audit.Event
+		if resp.StatusCode == http.StatusOK {
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&events))
+		}
+		return resp.StatusCode, events
+	}
+	code, _ := get("/audit", "viewerKey")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, events := get("/audit", "adminKey")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, len(events))
+	_, events = get("/audit?actor=lala", "adminKey")
+	assert.Equal(t, "chain01", events[0].ChainId)
+	_, events = get("/audit", "editorKey")
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "chain02", events[0].ChainId)
+	code, _ = get("/audit?since=yesterday", "adminKey")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestAdminQuarantine(t *testing.T) {
+	_ = rulego.Registry.Register(&healthTestNode{})
+	defer rulego.Registry.Unregister("test/health")
+	ruleGo := &rulego.RuleGo{}
+	defer ruleGo.Stop()
+	manager := quarantine.NewManager(quarantine.Config{MaxFailures: 1})
+	def, err := rulego.NewChainBuilder().Id("quarantine01").Node("test/health", nil).DSL()
+	assert.Nil(t, err)
+	_, err = ruleGo.New("quarantine01", def, rulego.WithConfig(rulego.NewConfig(types.WithQuarantine(manager), types.WithTenant("t1"))))
+	assert.Nil(t, err)
+	_, _ = manager.Fail("quarantine01", quarantine.Message{Id: "m1", Type: "TEST", Data: "{"}, errors.New("invalid json"))
+	_, _ = manager.Fail("quarantine01", quarantine.Message{Id: "m2", Type: "TEST", Data: "{"}, errors.New("invalid json"))
+
+	server := New(":0", WithRuleGo(ruleGo), WithAuth(NewAPIKeyAuthenticator(map[string]Principal{
+		"viewerKey": {Role: RoleViewer},
+		"editorKey": {Role: RoleEditor, Tenant: "t2"},
+		"adminKey":  {Role: RoleAdmin},
+	})))
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	do := func(method, path, key string) (int, []quarantine.Entry) {
+		req, _ := http.NewRequest(method, httpServer.URL+path, nil)
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		var entries []quarantine.Entry
+		if resp.StatusCode == http.StatusOK {
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&entries))
+		}
+		return resp.StatusCode, entries
+	}
+	code, _ := do(http.MethodGet, "/quarantine", "viewerKey")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, entries := do(http.MethodGet, "/quarantine", "adminKey")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "invalid json", entries[0].Error)
+	//其他租户不可见
+	_, entries = do(http.MethodGet, "/quarantine", "editorKey")
+	assert.Equal(t, 0, len(entries))
+	code, _ = do(http.MethodPost, "/quarantine/replay?chainId=quarantine01&msgId=m1", "editorKey")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = do(http.MethodPost, "/quarantine/replay?chainId=quarantine01&msgId=m1", "adminKey")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = do(http.MethodPost, "/quarantine/replay?chainId=quarantine01&msgId=m1", "adminKey")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = do(http.MethodGet, "/quarantine/replay?chainId=quarantine01&msgId=m2", "adminKey")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, _ = do(http.MethodDelete, "/quarantine?chainId=quarantine01", "adminKey")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(http.MethodDelete, "/quarantine?chainId=quarantine01&msgId=m2", "adminKey")
+	assert.Equal(t, http.StatusNoContent, code)
+	_, entries = do(http.MethodGet, "/quarantine?chainId=quarantine01", "adminKey")
+	assert.Equal(t, 0, len(entries))
+}
+
+func TestAdminRuntimeConfig(t *testing.T) {
+	runtime := types.NewRuntimeConfig()
+	rulego.NewConfig(types.WithDefaultPool(), types.WithRuntimeConfig(runtime))
+	server := New(":0", WithRuleGo(&rulego.RuleGo{}), WithRuntimeConfig(runtime), WithAuth(NewAPIKeyAuthenticator(map[string]Principal{
+		"editorKey":  {Role: RoleEditor},
+		"adminKey":   {Role: RoleAdmin},
+		"t1AdminKey": {Role: RoleAdmin, Tenant: "t1"},
+	})))
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	do := func(method, key, body string) (int, types.RuntimeSettings) {
+		req, _ := http.NewRequest(method, httpServer.URL+"/config", strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		var settings types.RuntimeSettings
+		if resp.StatusCode == http.StatusOK {
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&settings))
+		}
+		return resp.StatusCode, settings
+	}
+	code, _ := do(http.MethodGet, "editorKey", "")
+	assert.Equal(t, http.StatusForbidden, code)
+	//租户的admin不能查询和修改整个进程的运行时配置
+	code, _ = do(http.MethodGet, "t1AdminKey", "")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = do(http.MethodPut, "t1AdminKey", `{"logLevel":"error"}`)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, settings := do(http.MethodGet, "adminKey", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, types.LogLevelInfo, settings.LogLevel)
+	assert.Equal(t, int64(2000), settings.JsMaxExecutionTime)
+
+	//只修改提交的字段
+	code, settings = do(http.MethodPut, "adminKey", `{"logLevel":"warn","poolSize":16}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, types.LogLevelWarn, settings.LogLevel)
+	assert.Equal(t, 16, settings.PoolSize)
+	assert.Equal(t, int64(2000), settings.JsMaxExecutionTime)
+	assert.Equal(t, settings, runtime.Settings())
+
+	code, _ = do(http.MethodPut, "adminKey", `{"debugSampleRate":1.5}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(http.MethodPut, "adminKey", `{`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do(http.MethodPost, "adminKey", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	assert.Equal(t, settings, runtime.Settings())
+
+	//没有开启
+	server = New(":0", WithRuleGo(&rulego.RuleGo{}))
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/config", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
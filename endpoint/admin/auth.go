@@ -0,0 +1,288 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/utils/clock"
+	"net/http"
+	"strings"
+)
+
+// Role 管理接口角色，高级别角色拥有低级别角色的所有权限
+type Role int
+
+const (
+	//RoleViewer 查看健康状态和指标
+	RoleViewer Role = iota + 1
+	//RoleEditor 在查看权限基础上可以修改规则链
+	RoleEditor
+	//RoleAdmin 所有权限，包括性能分析和调试接口
+	RoleAdmin
+)
+
+// ErrUnauthorized 没有提供认证信息或者认证失败
+var ErrUnauthorized = errors.New("unauthorized")
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleEditor:
+		return "editor"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return ""
+	}
+}
+
+// ParseRole 解析角色名称：viewer、editor、admin
+func ParseRole(name string) (Role, error) {
+	switch strings.ToLower(name) {
+	case "viewer":
+		return RoleViewer, nil
+	case "editor":
+		return RoleEditor, nil
+	case "admin":
+		return RoleAdmin, nil
+	default:
+		return 0, fmt.Errorf("unknown role: %s", name)
+	}
+}
+
+// Principal 认证用户
+type Principal struct {
+	//Name 用户名称或者API key名称
+	Name string
+	//Role 角色
+	Role Role
+	//Tenant 租户，只能访问该租户的规则链，为空表示可以访问所有租户
+	Tenant string
+}
+
+// Allow 是否拥有role权限
+func (p Principal) Allow(role Role) bool {
+	return p.Role >= role
+}
+
+// AllowTenant 是否可以访问tenant租户的规则链
+func (p Principal) AllowTenant(tenant string) bool {
+	return p.Tenant == "" || p.Tenant == tenant
+}
+
+// Authenticator 认证器
+type Authenticator interface {
+	//Authenticate 认证请求，请求没有携带该认证方式的凭证返回ok=false，凭证无效返回错误
+	Authenticate(r *http.Request) (principal Principal, ok bool, err error)
+}
+
+// APIKeyAuthenticator API key认证，通过请求头`X-API-Key: <key>`或者`Authorization: ApiKey <key>`传递
+type APIKeyAuthenticator struct {
+	keys map[string]Principal
+}
+
+// NewAPIKeyAuthenticator 创建API key认证器，keys为API key和用户的映射
+func NewAPIKeyAuthenticator(keys map[string]Principal) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: keys}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (Principal, bool, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key = authorization(r, "ApiKey")
+	}
+	if key == "" {
+		return Principal{}, false, nil
+	}
+	for k, principal := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return principal, true, nil
+		}
+	}
+	return Principal{}, true, ErrUnauthorized
+}
+
+// Claims JWT声明
+type Claims struct {
+	//Subject 用户名称
+	Subject string `json:"sub"`
+	//Role 角色名称：viewer、editor、admin
+	Role string `json:"role"`
+	//Tenant 租户，为空表示可以访问所有租户
+	Tenant string `json:"tenant,omitempty"`
+	//ExpiresAt 过期时间，unix秒，0表示不过期
+	ExpiresAt int64 `json:"exp,omitempty"`
+	//NotBefore 生效时间，unix秒
+	NotBefore int64 `json:"nbf,omitempty"`
+}
+
+// JWTAuthenticator HS256签名的JWT认证，通过请求头`Authorization: Bearer <token>`传递
+type JWTAuthenticator struct {
+	secret []byte
+	clock  clock.Clock
+}
+
+// NewJWTAuthenticator 创建JWT认证器，secret为HS256签名密钥，c为nil则使用系统时钟
+func NewJWTAuthenticator(secret []byte, c clock.Clock) *JWTAuthenticator {
+	if c == nil {
+		c = clock.System
+	}
+	return &JWTAuthenticator{secret: secret, clock: c}
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (Principal, bool, error) {
+	token := authorization(r, "Bearer")
+	if token == "" {
+		return Principal{}, false, nil
+	}
+	claims, err := ParseJWT(a.secret, token)
+	if err != nil {
+		return Principal{}, true, err
+	}
+	now := a.clock.Now().Unix()
+	if claims.ExpiresAt != 0 && now >= claims.ExpiresAt {
+		return Principal{}, true, fmt.Errorf("%w: token expired", ErrUnauthorized)
+	}
+	if claims.NotBefore != 0 && now < claims.NotBefore {
+		return Principal{}, true, fmt.Errorf("%w: token not valid yet", ErrUnauthorized)
+	}
+	role, err := ParseRole(claims.Role)
+	if err != nil {
+		return Principal{}, true, fmt.Errorf("%w: %s", ErrUnauthorized, err)
+	}
+	return Principal{Name: claims.Subject, Role: role, Tenant: claims.Tenant}, true, nil
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// NewJWT 使用HS256签名生成JWT
+func NewJWT(secret []byte, claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(secret, unsigned), nil
+}
+
+// ParseJWT 校验HS256签名并解析JWT声明，不检查过期时间
+func ParseJWT(secret []byte, token string) (Claims, error) {
+	var claims Claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("%w: malformed token", ErrUnauthorized)
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, fmt.Errorf("%w: malformed token", ErrUnauthorized)
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if err = json.Unmarshal(header, &h); err != nil || h.Alg != "HS256" {
+		return claims, fmt.Errorf("%w: unsupported algorithm", ErrUnauthorized)
+	}
+	expected := sign(secret, parts[0]+"."+parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return claims, fmt.Errorf("%w: invalid signature", ErrUnauthorized)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, fmt.Errorf("%w: malformed token", ErrUnauthorized)
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return claims, fmt.Errorf("%w: malformed token", ErrUnauthorized)
+	}
+	return claims, nil
+}
+
+func sign(secret []byte, unsigned string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// authorization 获取`Authorization: <scheme> <credentials>`请求头中的凭证
+func authorization(r *http.Request, scheme string) string {
+	value := r.Header.Get("Authorization")
+	if len(value) > len(scheme)+1 && strings.EqualFold(value[:len(scheme)], scheme) && value[len(scheme)] == ' ' {
+		return strings.TrimSpace(value[len(scheme)+1:])
+	}
+	return ""
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext 获取请求的认证用户，没有开启认证ok=false
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// principal 依次使用认证器认证请求，所有认证器都没有找到凭证返回ok=false
+func (s *Server) principal(r *http.Request) (Principal, bool, error) {
+	for _, authenticator := range s.authenticators {
+		if principal, ok, err := authenticator.Authenticate(r); ok {
+			return principal, true, err
+		}
+	}
+	return Principal{}, false, nil
+}
+
+// authenticate 依次使用认证器认证请求，需要role权限
+func (s *Server) authenticate(role Role, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(s.authenticators) == 0 {
+			handler(w, r)
+			return
+		}
+		principal, ok, err := s.principal(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="rulego"`)
+			http.Error(w, ErrUnauthorized.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !principal.Allow(role) {
+			http.Error(w, fmt.Sprintf("forbidden: %s role required", role), http.StatusForbidden)
+			return
+		}
+		handler(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	}
+}
+
+// unscoped 只允许不属于任何租户的用户访问，用于影响或者暴露整个进程的接口，例如/config和/debug/pprof/
+func unscoped(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if principal, ok := PrincipalFromContext(r.Context()); ok && principal.Tenant != "" {
+			http.Error(w, "forbidden: tenant-scoped principal can not access process-wide endpoint", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"encoding/json"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRole(t *testing.T) {
+	role, err := ParseRole("Editor")
+	assert.Nil(t, err)
+	assert.Equal(t, RoleEditor, role)
+	assert.Equal(t, "editor", role.String())
+	_, err = ParseRole("root")
+	assert.NotNil(t, err)
+	assert.True(t, Principal{Role: RoleAdmin}.Allow(RoleEditor))
+	assert.False(t, Principal{Role: RoleViewer}.Allow(RoleEditor))
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("secret01")
+	vc := clock.NewVirtual(time.Unix(1700000000, 0))
+	token, err := NewJWT(secret, Claims{Subject: "lala", Role: "viewer", Tenant: "t1", ExpiresAt: 1700000060})
+	assert.Nil(t, err)
+	claims, err := ParseJWT(secret, token)
+	assert.Nil(t, err)
+	assert.Equal(t, "lala", claims.Subject)
+
+	_, err = ParseJWT([]byte("secret02"), token)
+	assert.NotNil(t, err)
+	_, err = ParseJWT(secret, "aa.bb")
+	assert.NotNil(t, err)
+
+	authenticator := NewJWTAuthenticator(secret, vc)
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	_, ok, _ := authenticator.Authenticate(r)
+	assert.False(t, ok)
+	r.Header.Set("Authorization", "Bearer "+token)
+	principal, ok, err := authenticator.Authenticate(r)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, Principal{Name: "lala", Role: RoleViewer, Tenant: "t1"}, principal)
+
+	vc.Advance(time.Minute)
+	_, _, err = authenticator.Authenticate(r)
+	assert.NotNil(t, err)
+}
+
+func TestAdminServerAuth(t *testing.T) {
+	_ = rulego.Registry.Register(&healthTestNode{})
+	defer rulego.Registry.Unregister("test/health")
+	ruleGo := &rulego.RuleGo{}
+	defer ruleGo.Stop()
+	def, err := rulego.NewChainBuilder().Id("chain01").Node("test/health", nil).DSL()
+	assert.Nil(t, err)
+	_, err = ruleGo.New("t1Chain", def, rulego.WithConfig(rulego.NewConfig(types.WithTenant("t1"))))
+	assert.Nil(t, err)
+	_, err = ruleGo.New("t2Chain", def, rulego.WithConfig(rulego.NewConfig(types.WithTenant("t2"))))
+	assert.Nil(t, err)
+
+	secret := []byte("secret01")
+	server := New(":0", WithRuleGo(ruleGo), WithPprof(), WithAuth(
+		NewAPIKeyAuthenticator(map[string]Principal{
+			"viewerKey":  {Name: "viewer", Role: RoleViewer, Tenant: "t1"},
+			"adminKey":   {Name: "admin", Role: RoleAdmin},
+			"t1AdminKey": {Name: "t1Admin", Role: RoleAdmin, Tenant: "t1"},
+		}),
+		NewJWTAuthenticator(secret, nil),
+	))
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	get := func(path string, header, value string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, httpServer.URL+path, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		return resp
+	}
+	nodes := func(resp *http.Response) map[string][]rulego.NodeHealth {
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var result map[string][]rulego.NodeHealth
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result
+	}
+
+	//探针接口不需要认证
+	resp := get("/healthz", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	resp = get("/health/nodes", "", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	_ = resp.Body.Close()
+	resp = get("/health/nodes", "X-API-Key", "wrongKey")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	//按租户过滤
+	result := nodes(get("/health/nodes", "X-API-Key", "viewerKey"))
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, 1, len(result["t1Chain"]))
+	result = nodes(get("/health/nodes", "Authorization", "ApiKey adminKey"))
+	assert.Equal(t, 2, len(result))
+
+	token, _ := NewJWT(secret, Claims{Subject: "lala", Role: "editor", Tenant: "t2"})
+	result = nodes(get("/health/nodes", "Authorization", "Bearer "+token))
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, 1, len(result["t2Chain"]))
+
+	//性能分析需要admin角色
+	resp = get("/debug/pprof/goroutine?debug=1", "Authorization", "Bearer "+token)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_ = resp.Body.Close()
+	resp = get("/debug/pprof/goroutine?debug=1", "X-API-Key", "adminKey")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+	//租户的admin不能访问整个进程的性能分析数据
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/pprof/cmdline"} {
+		resp = get(path, "X-API-Key", "t1AdminKey")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+}
@@ -0,0 +1,382 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/2018yuli/rulego"
+	managementv1 "github.com/2018yuli/rulego/api/proto/management/v1"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/str"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// debugStreamBuffer 每个调试信息订阅者的缓冲大小，缓冲满时丢弃调试信息，不阻塞规则链执行
+const debugStreamBuffer = 256
+
+// grpcRoles gRPC管理接口需要的角色，其他方法需要admin角色
+var grpcRoles = map[string]Role{
+	managementv1.Management_ListChains_FullMethodName:  RoleViewer,
+	managementv1.Management_GetChain_FullMethodName:    RoleViewer,
+	managementv1.Management_GetMetrics_FullMethodName:  RoleViewer,
+	managementv1.Management_SaveChain_FullMethodName:   RoleEditor,
+	managementv1.Management_DeleteChain_FullMethodName: RoleEditor,
+	managementv1.Management_StreamDebug_FullMethodName: RoleEditor,
+}
+
+// WithChainConfig 通过gRPC管理接口创建规则链使用的配置，默认使用`rulego.NewConfig()`
+func WithChainConfig(config types.Config) Option {
+	return func(s *Server) {
+		s.chainConfig = config
+	}
+}
+
+// GRPCServer 创建gRPC服务并注册管理接口`managementv1.ManagementServer`，
+// 通过WithAuth开启认证后，客户端通过gRPC metadata传递和HTTP请求头相同的凭证，例如：authorization: Bearer <token>
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(s.unaryInterceptor), grpc.ChainStreamInterceptor(s.streamInterceptor))
+	server := grpc.NewServer(opts...)
+	managementv1.RegisterManagementServer(server, &managementService{server: s})
+	return server
+}
+
+// StartGRPC 在addr启动gRPC管理接口服务，阻塞直到服务停止
+func (s *Server) StartGRPC(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	s.grpcServer = s.GRPCServer()
+	server := s.grpcServer
+	s.lock.Unlock()
+	return server.Serve(listener)
+}
+
+// DebugFunc 返回chainId规则链的调试信息回调函数，把调试信息发送给StreamDebug订阅者，然后调用next
+// 通过gRPC管理接口创建的规则链自动使用，其他方式创建的规则链需要设置到`Config.OnDebug`，例如：
+//
+//	config.OnDebug = adminServer.DebugFunc("chain01", config.OnDebug)
+//	ruleGo.New("chain01", def, rulego.WithConfig(config))
+func (s *Server) DebugFunc(chainId string, next types.OnDebugFunc) types.OnDebugFunc {
+	return func(flowType string, nodeId string, msg types.RuleMsg, relationType string, err error) {
+		s.debug.publish(chainId, flowType, nodeId, msg, relationType, err)
+		if next != nil {
+			next(flowType, nodeId, msg, relationType, err)
+		}
+	}
+}
+
+// authorize 认证gRPC请求，返回携带认证用户的上下文
+func (s *Server) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	if len(s.authenticators) == 0 {
+		return ctx, nil
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, fullMethod, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+	principal, ok, err := s.principal(r)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, ErrUnauthorized.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	role, ok := grpcRoles[fullMethod]
+	if !ok {
+		role = RoleAdmin
+	}
+	if !principal.Allow(role) {
+		return nil, status.Errorf(codes.PermissionDenied, "forbidden: %s role required", role)
+	}
+	return context.WithValue(ctx, principalKey{}, principal), nil
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authorize(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &principalStream{ServerStream: stream, ctx: ctx})
+}
+
+// principalStream 携带认证用户上下文的gRPC流
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context {
+	return s.ctx
+}
+
+// managementService gRPC管理接口实现
+type managementService struct {
+	managementv1.UnimplementedManagementServer
+	server *Server
+	//chainLock 保证创建、重新加载和删除规则链串行执行
+	chainLock sync.Mutex
+}
+
+func (m *managementService) ListChains(ctx context.Context, req *managementv1.ListChainsRequest) (*managementv1.ListChainsResponse, error) {
+	resp := &managementv1.ListChainsResponse{}
+	for _, ruleEngine := range m.server.engines(ctx) {
+		resp.Chains = append(resp.Chains, &managementv1.Chain{Id: ruleEngine.Id, Dsl: ruleEngine.DSL()})
+	}
+	return resp, nil
+}
+
+func (m *managementService) GetChain(ctx context.Context, req *managementv1.GetChainRequest) (*managementv1.Chain, error) {
+	ruleEngine, err := m.server.engine(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &managementv1.Chain{Id: ruleEngine.Id, Dsl: ruleEngine.DSL()}, nil
+}
+
+func (m *managementService) SaveChain(ctx context.Context, req *managementv1.SaveChainRequest) (*managementv1.Chain, error) {
+	if len(req.Dsl) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "dsl is required")
+	}
+	id := req.Id
+	if id == "" {
+		chain, err := rulego.ParserRuleChain(req.Dsl)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if id = chain.RuleChain.ID; id == "" {
+			return nil, status.Error(codes.InvalidArgument, "id is required")
+		}
+	}
+	principal, authenticated := PrincipalFromContext(ctx)
+	actor := req.Actor
+	if authenticated {
+		actor = principal.Name
+	}
+	m.chainLock.Lock()
+	defer m.chainLock.Unlock()
+	if ruleEngine, ok := m.server.ruleGo.Get(id); ok {
+		if authenticated && !principal.AllowTenant(ruleEngine.Config.Tenant) {
+			return nil, status.Error(codes.AlreadyExists, "rule chain already exists")
+		}
+		if err := ruleEngine.ReloadSelf(req.Dsl, rulego.WithActor(actor)); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return &managementv1.Chain{Id: ruleEngine.Id, Dsl: ruleEngine.DSL()}, nil
+	}
+	config := m.server.chainConfig
+	if authenticated && principal.Tenant != "" {
+		config.Tenant = principal.Tenant
+	}
+	config.OnDebug = m.server.DebugFunc(id, config.OnDebug)
+	ruleEngine, err := m.server.ruleGo.New(id, req.Dsl, rulego.WithConfig(config), rulego.WithActor(actor))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &managementv1.Chain{Id: ruleEngine.Id, Dsl: ruleEngine.DSL()}, nil
+}
+
+func (m *managementService) DeleteChain(ctx context.Context, req *managementv1.DeleteChainRequest) (*managementv1.DeleteChainResponse, error) {
+	m.chainLock.Lock()
+	defer m.chainLock.Unlock()
+	if _, err := m.server.engine(ctx, req.Id); err != nil {
+		return nil, err
+	}
+	actor := req.Actor
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		actor = principal.Name
+	}
+	m.server.ruleGo.DelBy(req.Id, actor)
+	return &managementv1.DeleteChainResponse{}, nil
+}
+
+func (m *managementService) GetMetrics(ctx context.Context, req *managementv1.GetMetricsRequest) (*managementv1.GetMetricsResponse, error) {
+	var engines []*rulego.RuleEngine
+	if req.ChainId == "" {
+		engines = m.server.engines(ctx)
+	} else {
+		ruleEngine, err := m.server.engine(ctx, req.ChainId)
+		if err != nil {
+			return nil, err
+		}
+		engines = append(engines, ruleEngine)
+	}
+	resp := &managementv1.GetMetricsResponse{}
+	for _, ruleEngine := range engines {
+		for _, item := range ruleEngine.Metrics() {
+			b, err := json.Marshal(item.Metrics)
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			resp.Nodes = append(resp.Nodes, &managementv1.NodeMetrics{ChainId: item.ChainId, NodeId: item.NodeId, Type: item.Type, Metrics: string(b)})
+		}
+	}
+	return resp, nil
+}
+
+func (m *managementService) StreamDebug(req *managementv1.StreamDebugRequest, stream managementv1.Management_StreamDebugServer) error {
+	ctx := stream.Context()
+	if req.ChainId != "" {
+		if _, err := m.server.engine(ctx, req.ChainId); err != nil {
+			return err
+		}
+	}
+	subscriber := &debugSubscriber{chainId: req.ChainId, events: make(chan *managementv1.DebugEvent, debugStreamBuffer)}
+	if len(req.NodeIds) > 0 {
+		subscriber.nodeIds = make(map[string]bool, len(req.NodeIds))
+		for _, nodeId := range req.NodeIds {
+			subscriber.nodeIds[nodeId] = true
+		}
+	}
+	if principal, ok := PrincipalFromContext(ctx); ok && principal.Tenant != "" {
+		//规则链可能在订阅后创建或者删除，发送时检查租户
+		ruleGo := m.server.ruleGo
+		subscriber.allow = func(chainId string) bool {
+			ruleEngine, ok := ruleGo.Get(chainId)
+			return ok && principal.AllowTenant(ruleEngine.Config.Tenant)
+		}
+	}
+	m.server.debug.subscribe(subscriber)
+	defer m.server.debug.unsubscribe(subscriber)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-subscriber.events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// engine 获取请求用户可以访问的规则引擎实例，不存在或者无权访问返回NotFound
+func (s *Server) engine(ctx context.Context, id string) (*rulego.RuleEngine, error) {
+	if s.ruleGo != nil {
+		if ruleEngine, ok := s.ruleGo.Get(id); ok {
+			if principal, authenticated := PrincipalFromContext(ctx); !authenticated || principal.AllowTenant(ruleEngine.Config.Tenant) {
+				return ruleEngine, nil
+			}
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "%s: %s", rulego.ErrChainNotFound, id)
+}
+
+// debugSubscriber StreamDebug订阅者
+type debugSubscriber struct {
+	//chainId 为空则订阅所有规则链
+	chainId string
+	//nodeIds 为空则订阅所有节点
+	nodeIds map[string]bool
+	//allow 为nil则不检查租户
+	allow  func(chainId string) bool
+	events chan *managementv1.DebugEvent
+}
+
+func (d *debugSubscriber) match(chainId, nodeId string) bool {
+	if d.chainId != "" && d.chainId != chainId {
+		return false
+	}
+	if d.nodeIds != nil && !d.nodeIds[nodeId] {
+		return false
+	}
+	return d.allow == nil || d.allow(chainId)
+}
+
+// debugHub 把调试信息分发给StreamDebug订阅者
+type debugHub struct {
+	subscribers map[*debugSubscriber]struct{}
+	lock        sync.RWMutex
+}
+
+func (h *debugHub) subscribe(subscriber *debugSubscriber) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.subscribers == nil {
+		h.subscribers = make(map[*debugSubscriber]struct{})
+	}
+	h.subscribers[subscriber] = struct{}{}
+}
+
+func (h *debugHub) unsubscribe(subscriber *debugSubscriber) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.subscribers, subscriber)
+}
+
+// publish 发送调试信息，订阅者缓冲满则丢弃
+func (h *debugHub) publish(chainId string, flowType string, nodeId string, msg types.RuleMsg, relationType string, err error) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	var event *managementv1.DebugEvent
+	for subscriber := range h.subscribers {
+		if !subscriber.match(chainId, nodeId) {
+			continue
+		}
+		if event == nil {
+			event = newDebugEvent(chainId, flowType, nodeId, msg, relationType, err)
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+		}
+	}
+}
+
+func newDebugEvent(chainId string, flowType string, nodeId string, msg types.RuleMsg, relationType string, err error) *managementv1.DebugEvent {
+	event := &managementv1.DebugEvent{
+		ChainId:      chainId,
+		FlowType:     flowType,
+		NodeId:       nodeId,
+		RelationType: relationType,
+		MsgId:        msg.Id,
+		MsgType:      msg.Type,
+		Data:         msg.Data,
+		Metadata:     make(map[string]string),
+		Ts:           time.Now().UnixMilli(),
+	}
+	for k, v := range msg.Metadata.Values() {
+		event.Metadata[k] = str.ToString(v)
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	return event
+}
@@ -0,0 +1,258 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/2018yuli/rulego"
+	managementv1 "github.com/2018yuli/rulego/api/proto/management/v1"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+	"time"
+)
+
+// metricsTestNode 提供运行指标的节点
+type metricsTestNode struct {
+}
+
+func (x *metricsTestNode) Type() string {
+	return "test/metrics"
+}
+
+func (x *metricsTestNode) New() types.Node {
+	return &metricsTestNode{}
+}
+
+func (x *metricsTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *metricsTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *metricsTestNode) Destroy() {
+}
+
+func (x *metricsTestNode) Metrics() map[string]interface{} {
+	return map[string]interface{}{"openConnections": 2}
+}
+
+// startGRPC 在随机端口启动gRPC管理接口，返回生成的客户端
+func startGRPC(t *testing.T, server *Server) managementv1.ManagementClient {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	grpcServer := server.GRPCServer()
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		grpcServer.Stop()
+	})
+	return managementv1.NewManagementClient(conn)
+}
+
+// waitSubscribers 等待调试信息订阅生效
+func waitSubscribers(t *testing.T, server *Server, n int) {
+	for i := 0; i < 200; i++ {
+		server.debug.lock.RLock()
+		count := len(server.debug.subscribers)
+		server.debug.lock.RUnlock()
+		if count == n {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("expected %d debug subscribers", n)
+}
+
+func grpcCode(err error) codes.Code {
+	return status.Code(err)
+}
+
+func TestGRPCManagement(t *testing.T) {
+	_ = rulego.Registry.Register(&metricsTestNode{})
+	defer rulego.Registry.Unregister("test/metrics")
+	ruleGo := &rulego.RuleGo{}
+	defer ruleGo.Stop()
+	server := New(":0", WithRuleGo(ruleGo))
+	client := startGRPC(t, server)
+	ctx := context.Background()
+
+	def, err := rulego.NewChainBuilder().Id("grpc01").Node("test/metrics", nil).Debug(true).DSL()
+	assert.Nil(t, err)
+	chain, err := client.SaveChain(ctx, &managementv1.SaveChainRequest{Dsl: def, Actor: "lala"})
+	assert.Nil(t, err)
+	assert.Equal(t, "grpc01", chain.Id)
+	_, ok := ruleGo.Get("grpc01")
+	assert.True(t, ok)
+
+	_, err = client.SaveChain(ctx, &managementv1.SaveChainRequest{Id: "grpc02", Dsl: []byte("{")})
+	assert.Equal(t, codes.InvalidArgument, grpcCode(err))
+	_, ok = ruleGo.Get("grpc02")
+	assert.False(t, ok)
+
+	list, err := client.ListChains(ctx, &managementv1.ListChainsRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(list.Chains))
+	assert.Equal(t, "grpc01", list.Chains[0].Id)
+
+	chain, err = client.GetChain(ctx, &managementv1.GetChainRequest{Id: "grpc01"})
+	assert.Nil(t, err)
+	parsed, err := rulego.ParserRuleChain(chain.Dsl)
+	assert.Nil(t, err)
+	assert.Equal(t, "test/metrics", parsed.Metadata.Nodes[0].Type)
+	_, err = client.GetChain(ctx, &managementv1.GetChainRequest{Id: "notFound"})
+	assert.Equal(t, codes.NotFound, grpcCode(err))
+
+	metrics, err := client.GetMetrics(ctx, &managementv1.GetMetricsRequest{ChainId: "grpc01"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(metrics.Nodes))
+	assert.Equal(t, "test/metrics", metrics.Nodes[0].Type)
+	var values map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(metrics.Nodes[0].Metrics), &values))
+	assert.Equal(t, float64(2), values["openConnections"])
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.StreamDebug(streamCtx, &managementv1.StreamDebugRequest{ChainId: "grpc01"})
+	assert.Nil(t, err)
+	waitSubscribers(t, server, 1)
+	ruleEngine, _ := ruleGo.Get("grpc01")
+	metaData := types.NewMetadata()
+	metaData.PutValue("productType", "test")
+	ruleEngine.OnMsg(types.NewMsg(0, "TEST_MSG_TYPE", types.JSON, metaData, "{\"temperature\":41}"))
+	event, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, "grpc01", event.ChainId)
+	assert.Equal(t, types.In, event.FlowType)
+	assert.Equal(t, "s1", event.NodeId)
+	assert.Equal(t, "TEST_MSG_TYPE", event.MsgType)
+	assert.Equal(t, "{\"temperature\":41}", event.Data)
+	assert.Equal(t, "test", event.Metadata["productType"])
+	event, err = stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, types.Out, event.FlowType)
+	assert.Equal(t, types.Success, event.RelationType)
+	cancel()
+	waitSubscribers(t, server, 0)
+
+	//已经存在则重新加载
+	def, err = rulego.NewChainBuilder().Id("grpc01").Node("test/metrics", nil).Node("test/metrics", nil).DSL()
+	assert.Nil(t, err)
+	_, err = client.SaveChain(ctx, &managementv1.SaveChainRequest{Id: "grpc01", Dsl: def})
+	assert.Nil(t, err)
+	metrics, err = client.GetMetrics(ctx, &managementv1.GetMetricsRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(metrics.Nodes))
+
+	_, err = client.DeleteChain(ctx, &managementv1.DeleteChainRequest{Id: "grpc01"})
+	assert.Nil(t, err)
+	_, ok = ruleGo.Get("grpc01")
+	assert.False(t, ok)
+	_, err = client.DeleteChain(ctx, &managementv1.DeleteChainRequest{Id: "grpc01"})
+	assert.Equal(t, codes.NotFound, grpcCode(err))
+}
+
+func TestGRPCManagementAuth(t *testing.T) {
+	_ = rulego.Registry.Register(&metricsTestNode{})
+	defer rulego.Registry.Unregister("test/metrics")
+	ruleGo := &rulego.RuleGo{}
+	defer ruleGo.Stop()
+	other, err := rulego.NewChainBuilder().Id("other01").Node("test/metrics", nil).Debug(true).DSL()
+	assert.Nil(t, err)
+	_, err = ruleGo.New("other01", other, rulego.WithConfig(rulego.NewConfig(types.WithTenant("t2"))))
+	assert.Nil(t, err)
+
+	server := New(":0", WithRuleGo(ruleGo), WithAuth(NewAPIKeyAuthenticator(map[string]Principal{
+		"viewerKey": {Name: "viewer01", Role: RoleViewer, Tenant: "t1"},
+		"editorKey": {Name: "editor01", Role: RoleEditor, Tenant: "t1"},
+	})))
+	client := startGRPC(t, server)
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+
+	_, err = client.ListChains(context.Background(), &managementv1.ListChainsRequest{})
+	assert.Equal(t, codes.Unauthenticated, grpcCode(err))
+	_, err = client.ListChains(withKey("wrongKey"), &managementv1.ListChainsRequest{})
+	assert.Equal(t, codes.Unauthenticated, grpcCode(err))
+
+	def, err := rulego.NewChainBuilder().Id("t1chain").Node("test/metrics", nil).Debug(true).DSL()
+	assert.Nil(t, err)
+	_, err = client.SaveChain(withKey("viewerKey"), &managementv1.SaveChainRequest{Dsl: def})
+	assert.Equal(t, codes.PermissionDenied, grpcCode(err))
+	_, err = client.SaveChain(withKey("editorKey"), &managementv1.SaveChainRequest{Dsl: def})
+	assert.Nil(t, err)
+	ruleEngine, ok := ruleGo.Get("t1chain")
+	assert.True(t, ok)
+	assert.Equal(t, "t1", ruleEngine.Config.Tenant)
+
+	//只能访问所属租户的规则链
+	list, err := client.ListChains(withKey("viewerKey"), &managementv1.ListChainsRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(list.Chains))
+	assert.Equal(t, "t1chain", list.Chains[0].Id)
+	_, err = client.GetChain(withKey("viewerKey"), &managementv1.GetChainRequest{Id: "other01"})
+	assert.Equal(t, codes.NotFound, grpcCode(err))
+	_, err = client.SaveChain(withKey("editorKey"), &managementv1.SaveChainRequest{Id: "other01", Dsl: def})
+	assert.Equal(t, codes.AlreadyExists, grpcCode(err))
+	_, err = client.DeleteChain(withKey("editorKey"), &managementv1.DeleteChainRequest{Id: "other01"})
+	assert.Equal(t, codes.NotFound, grpcCode(err))
+	_, ok = ruleGo.Get("other01")
+	assert.True(t, ok)
+
+	stream, err := client.StreamDebug(withKey("viewerKey"), &managementv1.StreamDebugRequest{})
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, grpcCode(err))
+
+	//订阅所有规则链时只接收所属租户规则链的调试信息
+	ctx, cancel := context.WithCancel(withKey("editorKey"))
+	defer cancel()
+	stream, err = client.StreamDebug(ctx, &managementv1.StreamDebugRequest{})
+	assert.Nil(t, err)
+	waitSubscribers(t, server, 1)
+	otherEngine, _ := ruleGo.Get("other01")
+	config := otherEngine.Config
+	config.OnDebug = server.DebugFunc("other01", nil)
+	assert.Nil(t, otherEngine.ReloadSelf(other, rulego.WithConfig(config)))
+	done := make(chan struct{})
+	otherEngine.OnMsgWithEndFunc(types.NewMsg(0, "OTHER", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+		close(done)
+	})
+	<-done
+	ruleEngine.OnMsg(types.NewMsg(0, "T1", types.JSON, types.NewMetadata(), "{}"))
+	for i := 0; i < 2; i++ {
+		event, err := stream.Recv()
+		assert.Nil(t, err)
+		assert.Equal(t, "t1chain", event.ChainId)
+		assert.Equal(t, "T1", event.MsgType)
+	}
+}
@@ -64,6 +64,18 @@ type Message interface {
 	SetStatusCode(statusCode int)
 	//SetBody 响应 body
 	SetBody(body []byte)
+	//SetError 设置规则链处理错误
+	SetError(err error)
+	//GetError 获取规则链处理错误
+	GetError() error
+}
+
+// CompletedMessage 需要在规则链所有分支处理结束后确认的出数据，例如：消息队列的消息
+// 规则链有多个结束点时SetMsg和SetError会执行多次，Completed在所有分支处理结束后执行一次
+type CompletedMessage interface {
+	Message
+	//Completed 规则链所有分支处理结束
+	Completed()
 }
 
 // Exchange 包含in 和out message
@@ -343,6 +355,8 @@ type BaseEndpoint struct {
 	//endpoint 路由存储器
 	RouterStorage map[string]*Router
 	sync.RWMutex
+	//handoff 到规则链的交接，统计积压并控制背压
+	handoff *Handoff
 }
 
 func (e *BaseEndpoint) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
@@ -354,6 +368,43 @@ func (e *BaseEndpoint) AddInterceptors(interceptors ...Process) {
 	e.interceptors = append(e.interceptors, interceptors...)
 }
 
+// SetBackpressure 设置到规则链交接的背压配置，积压达到MaxPending时暂停消费，需要在Start之前调用
+func (e *BaseEndpoint) SetBackpressure(config BackpressureConfig) {
+	e.Lock()
+	defer e.Unlock()
+	e.handoff = NewHandoff(config)
+}
+
+// InitBackpressure 解析端点配置中的backpressure背压配置，在端点Init中调用，没有配置则不限制
+func (e *BaseEndpoint) InitBackpressure(configuration types.Configuration) error {
+	config, ok, err := parseBackpressure(configuration)
+	if ok {
+		e.SetBackpressure(config)
+	}
+	return err
+}
+
+// Handoff 获取到规则链的交接，用于获取队列深度、等待时间和拒绝次数等指标
+func (e *BaseEndpoint) Handoff() *Handoff {
+	e.RLock()
+	handoff := e.handoff
+	e.RUnlock()
+	if handoff != nil {
+		return handoff
+	}
+	e.Lock()
+	defer e.Unlock()
+	if e.handoff == nil {
+		e.handoff = NewHandoff(BackpressureConfig{})
+	}
+	return e.handoff
+}
+
+// Metrics 到规则链交接的运行指标
+func (e *BaseEndpoint) Metrics() map[string]interface{} {
+	return e.Handoff().Metrics()
+}
+
 func (e *BaseEndpoint) DoProcess(router *Router, exchange *Exchange) {
 	for _, item := range e.interceptors {
 		//执行全局拦截器
@@ -369,7 +420,7 @@ func (e *BaseEndpoint) DoProcess(router *Router, exchange *Exchange) {
 	}
 	//执行to端逻辑
 	if router.GetFrom() != nil && router.GetFrom().GetTo() != nil {
-		router.GetFrom().GetTo().Execute(context.TODO(), exchange)
+		router.GetFrom().GetTo().Execute(withHandoff(context.TODO(), e.Handoff()), exchange)
 	}
 }
 
@@ -452,15 +503,45 @@ func (ce *ChainExecutor) Execute(ctx context.Context, router *Router, exchange *
 
 		//查找规则链，并执行
 		if ruleEngine, ok := router.RuleGo.Get(toChainId); ok {
-			ruleEngine.OnMsgWithOptions(*inMsg, types.WithContext(ctx),
+			var release func()
+			if handoff := handoffFromContext(ctx); handoff != nil {
+				if err := handoff.Acquire(ctx); err != nil {
+					//积压超过阈值等待超时，拒绝消息
+					exchange.Out.SetError(err)
+					exchange.Out.SetMsg(inMsg)
+					for _, process := range toFlow.GetProcessList() {
+						if !process(router, exchange) {
+							break
+						}
+					}
+					if out, ok := exchange.Out.(CompletedMessage); ok {
+						out.Completed()
+					}
+					return
+				}
+				//规则链有多个结束点时第一个结束点释放
+				var once sync.Once
+				release = func() {
+					once.Do(handoff.Release)
+				}
+			}
+			opts := []types.RuleContextOption{types.WithContext(ctx),
 				types.WithEndFunc(func(msg types.RuleMsg, err error) {
+					if release != nil {
+						release()
+					}
+					exchange.Out.SetError(err)
 					exchange.Out.SetMsg(&msg)
 					for _, process := range toFlow.GetProcessList() {
 						if !process(router, exchange) {
 							break
 						}
 					}
-				}))
+				})}
+			if out, ok := exchange.Out.(CompletedMessage); ok {
+				opts = append(opts, types.WithOnAllNodeCompleted(out.Completed))
+			}
+			ruleEngine.OnMsgWithOptions(*inMsg, opts...)
 		}
 
 	}
@@ -504,14 +585,20 @@ func (ce *ComponentExecutor) Execute(ctx context.Context, router *Router, exchan
 
 		inMsg := exchange.In.GetMsg()
 		if toFlow := fromFlow.GetTo(); toFlow != nil && inMsg != nil {
+			//组件没有分支，第一次结束时确认消息
+			var once sync.Once
 			//初始化的空上下文
 			ruleCtx := rulego.NewRuleContext(ce.config, nil, nil, nil, ce.config.Pool, func(msg types.RuleMsg, err error) {
+				exchange.Out.SetError(err)
 				exchange.Out.SetMsg(&msg)
 				for _, process := range toFlow.GetProcessList() {
 					if !process(router, exchange) {
 						break
 					}
 				}
+				if out, ok := exchange.Out.(CompletedMessage); ok {
+					once.Do(out.Completed)
+				}
 			}, ctx)
 
 			//执行组件逻辑
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"sync"
+	"time"
+)
+
+// ErrBackpressure 规则链积压超过阈值，消息等待超过MaxWaitMs被拒绝
+var ErrBackpressure = errors.New("endpoint backpressure: chain backlog exceeded")
+
+// backpressureKey 端点配置中背压配置的键
+const backpressureKey = "backpressure"
+
+// BackpressureConfig 端点把消息交给规则链的背压配置
+//
+//	"backpressure": {"maxPending": 1000, "resumePending": 500, "maxWaitMs": 30000}
+type BackpressureConfig struct {
+	//MaxPending 已经交给规则链还没有处理完成的消息数达到该值时暂停消费，0表示不限制，只统计指标
+	MaxPending int64
+	//ResumePending 暂停后积压降低到该值恢复消费，默认：MaxPending的一半
+	ResumePending int64
+	//MaxWaitMs 暂停消费时消息最长等待时间，超时拒绝消息，错误为ErrBackpressure，0表示一直等待
+	MaxWaitMs int64
+}
+
+// HandoffStats 端点到规则链交接的运行指标
+type HandoffStats struct {
+	//Pending 已经交给规则链还没有处理完成的消息数
+	Pending int64 `json:"pending"`
+	//Waiting 因为暂停消费正在等待的消息数
+	Waiting int64 `json:"waiting"`
+	//Paused 是否暂停消费
+	Paused bool `json:"paused"`
+	//Handoffs 交给规则链的消息总数
+	Handoffs uint64 `json:"handoffs"`
+	//Pauses 暂停消费的次数
+	Pauses uint64 `json:"pauses"`
+	//Waits 等待过的消息总数
+	Waits uint64 `json:"waits"`
+	//WaitTime 消息等待的总时间
+	WaitTime time.Duration `json:"waitTime"`
+	//MaxWaitTime 消息等待的最长时间
+	MaxWaitTime time.Duration `json:"maxWaitTime"`
+	//Rejected 等待超时被拒绝的消息总数
+	Rejected uint64 `json:"rejected"`
+}
+
+// Handoff 端点到规则链的交接，统计积压并在积压超过阈值时暂停消费
+// 端点消费消息的协程在Acquire阻塞，例如MQTT、Pulsar按顺序处理消息的消费协程，阻塞期间不再读取新消息
+type Handoff struct {
+	mu     sync.Mutex
+	config BackpressureConfig
+	stats  HandoffStats
+	//resume 暂停期间等待的消息在恢复消费时被唤醒
+	resume chan struct{}
+}
+
+// NewHandoff 创建端点到规则链的交接
+func NewHandoff(config BackpressureConfig) *Handoff {
+	if config.MaxPending > 0 && (config.ResumePending <= 0 || config.ResumePending >= config.MaxPending) {
+		config.ResumePending = config.MaxPending / 2
+	}
+	return &Handoff{config: config}
+}
+
+// Acquire 把消息交给规则链前调用，暂停消费时阻塞直到积压降低到ResumePending
+// 等待超过MaxWaitMs返回ErrBackpressure，ctx结束返回ctx.Err()，返回错误时不需要调用Release
+func (h *Handoff) Acquire(ctx context.Context) error {
+	h.mu.Lock()
+	var start time.Time
+	var timeout <-chan time.Time
+	for h.stats.Paused || (h.config.MaxPending > 0 && h.stats.Pending >= h.config.MaxPending) {
+		if !h.stats.Paused {
+			h.stats.Paused = true
+			h.stats.Pauses++
+			h.resume = make(chan struct{})
+		}
+		if start.IsZero() {
+			start = time.Now()
+			if h.config.MaxWaitMs > 0 {
+				timer := time.NewTimer(time.Duration(h.config.MaxWaitMs) * time.Millisecond)
+				defer timer.Stop()
+				timeout = timer.C
+			}
+		}
+		resume := h.resume
+		h.stats.Waiting++
+		h.mu.Unlock()
+		var err error
+		select {
+		case <-resume:
+		case <-timeout:
+			err = ErrBackpressure
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		h.mu.Lock()
+		h.stats.Waiting--
+		if err != nil {
+			h.stats.Rejected++
+			h.recordWait(time.Since(start))
+			h.mu.Unlock()
+			return err
+		}
+	}
+	if !start.IsZero() {
+		h.recordWait(time.Since(start))
+	}
+	h.stats.Pending++
+	h.stats.Handoffs++
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *Handoff) recordWait(d time.Duration) {
+	h.stats.Waits++
+	h.stats.WaitTime += d
+	if d > h.stats.MaxWaitTime {
+		h.stats.MaxWaitTime = d
+	}
+}
+
+// Release 规则链处理完成后调用，积压降低到ResumePending时恢复消费
+func (h *Handoff) Release() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stats.Pending > 0 {
+		h.stats.Pending--
+	}
+	if h.stats.Paused && h.stats.Pending <= h.config.ResumePending {
+		h.stats.Paused = false
+		close(h.resume)
+	}
+}
+
+// Stats 获取运行指标
+func (h *Handoff) Stats() HandoffStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stats
+}
+
+// Config 获取背压配置
+func (h *Handoff) Config() BackpressureConfig {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.config
+}
+
+// Metrics 以`types.MetricsProvider`格式返回运行指标，时间单位毫秒
+func (h *Handoff) Metrics() map[string]interface{} {
+	stats := h.Stats()
+	return map[string]interface{}{
+		"pending":       stats.Pending,
+		"waiting":       stats.Waiting,
+		"paused":        stats.Paused,
+		"handoffs":      stats.Handoffs,
+		"pauses":        stats.Pauses,
+		"waits":         stats.Waits,
+		"waitTimeMs":    stats.WaitTime.Milliseconds(),
+		"maxWaitTimeMs": stats.MaxWaitTime.Milliseconds(),
+		"rejected":      stats.Rejected,
+	}
+}
+
+// HandoffProvider 端点提供到规则链交接的运行指标，BaseEndpoint已经实现
+type HandoffProvider interface {
+	Handoff() *Handoff
+}
+
+var _ types.MetricsProvider = (*Handoff)(nil)
+
+type handoffCtxKey struct{}
+
+// withHandoff 把交接传递给To端执行器
+func withHandoff(ctx context.Context, handoff *Handoff) context.Context {
+	return context.WithValue(ctx, handoffCtxKey{}, handoff)
+}
+
+// handoffFromContext 获取To端执行器的交接，没有则返回nil
+func handoffFromContext(ctx context.Context) *Handoff {
+	handoff, _ := ctx.Value(handoffCtxKey{}).(*Handoff)
+	return handoff
+}
+
+// parseBackpressure 解析端点配置中的背压配置，没有配置返回false
+func parseBackpressure(configuration types.Configuration) (BackpressureConfig, bool, error) {
+	var config BackpressureConfig
+	v, ok := configuration[backpressureKey]
+	if !ok || v == nil {
+		return config, false, nil
+	}
+	if err := maps.Map2Struct(v, &config); err != nil {
+		return config, false, err
+	}
+	if config.MaxPending < 0 || config.ResumePending < 0 || config.MaxWaitMs < 0 {
+		return config, false, errors.New("backpressure maxPending, resumePending and maxWaitMs can not be negative")
+	}
+	return config, true, nil
+}
@@ -0,0 +1,213 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package endpoint
+
+import (
+	"context"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHandoffBackpressure(t *testing.T) {
+	handoff := NewHandoff(BackpressureConfig{MaxPending: 4})
+	assert.Equal(t, int64(2), handoff.Config().ResumePending)
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, handoff.Acquire(context.Background()))
+	}
+	acquired := make(chan struct{})
+	go func() {
+		_ = handoff.Acquire(context.Background())
+		close(acquired)
+	}()
+	assert.True(t, waitUntil(func() bool { return handoff.Stats().Waiting == 1 }))
+	assert.True(t, handoff.Stats().Paused)
+
+	//积压降低到ResumePending之前保持暂停
+	handoff.Release()
+	select {
+	case <-acquired:
+		t.Fatal("resumed before backlog drained")
+	case <-time.After(20 * time.Millisecond):
+	}
+	handoff.Release()
+	<-acquired
+	stats := handoff.Stats()
+	assert.False(t, stats.Paused)
+	assert.Equal(t, int64(3), stats.Pending)
+	assert.Equal(t, uint64(5), stats.Handoffs)
+	assert.Equal(t, uint64(1), stats.Pauses)
+	assert.Equal(t, uint64(1), stats.Waits)
+	assert.True(t, stats.WaitTime >= 20*time.Millisecond)
+	assert.Equal(t, stats.WaitTime, stats.MaxWaitTime)
+	assert.Equal(t, uint64(0), stats.Rejected)
+}
+
+func TestHandoffReject(t *testing.T) {
+	handoff := NewHandoff(BackpressureConfig{MaxPending: 1, MaxWaitMs: 10})
+	assert.Nil(t, handoff.Acquire(context.Background()))
+	assert.Equal(t, ErrBackpressure, handoff.Acquire(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, handoff.Acquire(ctx))
+
+	metrics := handoff.Metrics()
+	assert.Equal(t, int64(1), metrics["pending"])
+	assert.Equal(t, uint64(2), metrics["rejected"])
+	assert.Equal(t, true, metrics["paused"])
+	handoff.Release()
+	assert.Nil(t, handoff.Acquire(context.Background()))
+
+	//不限制只统计指标
+	handoff = NewHandoff(BackpressureConfig{})
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, handoff.Acquire(context.Background()))
+	}
+	assert.Equal(t, int64(100), handoff.Stats().Pending)
+	assert.False(t, handoff.Stats().Paused)
+}
+
+func TestParseBackpressure(t *testing.T) {
+	_, ok, err := parseBackpressure(types.Configuration{"server": "127.0.0.1:1883"})
+	assert.False(t, ok)
+	assert.Nil(t, err)
+	config, ok, err := parseBackpressure(types.Configuration{backpressureKey: map[string]interface{}{"maxPending": 100, "resumePending": "20", "maxWaitMs": 500}})
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, BackpressureConfig{MaxPending: 100, ResumePending: 20, MaxWaitMs: 500}, config)
+	_, _, err = parseBackpressure(types.Configuration{backpressureKey: map[string]interface{}{"maxPending": -1}})
+	assert.NotNil(t, err)
+
+	var ep BaseEndpoint
+	assert.Nil(t, ep.InitBackpressure(types.Configuration{backpressureKey: map[string]interface{}{"maxPending": 8}}))
+	assert.Equal(t, int64(8), ep.Handoff().Config().MaxPending)
+}
+
+// handoffTestNode 阻塞到测试放行，模拟规则链积压
+type handoffTestNode struct {
+}
+
+var handoffRelease = make(chan struct{}, 16)
+
+func (x *handoffTestNode) Type() string {
+	return "test/handoff"
+}
+
+func (x *handoffTestNode) New() types.Node {
+	return &handoffTestNode{}
+}
+
+func (x *handoffTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *handoffTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	<-handoffRelease
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *handoffTestNode) Destroy() {
+}
+
+// testMessage 测试交换消息
+type testMessage struct {
+	sync.Mutex
+	msg *types.RuleMsg
+	err error
+}
+
+func (m *testMessage) Body() []byte                  { return nil }
+func (m *testMessage) Headers() textproto.MIMEHeader { return nil }
+func (m *testMessage) From() string                  { return "test" }
+func (m *testMessage) GetParam(key string) string    { return "" }
+func (m *testMessage) SetStatusCode(statusCode int)  {}
+func (m *testMessage) SetBody(body []byte)           {}
+func (m *testMessage) SetMsg(msg *types.RuleMsg)     { m.Lock(); m.msg = msg; m.Unlock() }
+func (m *testMessage) SetError(err error)            { m.Lock(); m.err = err; m.Unlock() }
+func (m *testMessage) GetError() error               { m.Lock(); defer m.Unlock(); return m.err }
+func (m *testMessage) GetMsg() *types.RuleMsg {
+	m.Lock()
+	defer m.Unlock()
+	if m.msg == nil {
+		msg := types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}")
+		m.msg = &msg
+	}
+	return m.msg
+}
+
+func TestEndpointBackpressure(t *testing.T) {
+	_ = rulego.Registry.Register(&handoffTestNode{})
+	defer rulego.Registry.Unregister("test/handoff")
+	ruleGo := &rulego.RuleGo{}
+	defer ruleGo.Stop()
+	def, err := rulego.NewChainBuilder().Id("handoff01").Node("test/handoff", nil).DSL()
+	assert.Nil(t, err)
+	_, err = ruleGo.New("handoff01", def)
+	assert.Nil(t, err)
+
+	var ep BaseEndpoint
+	ep.SetBackpressure(BackpressureConfig{MaxPending: 2, ResumePending: 1, MaxWaitMs: 50})
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var results []error
+	router := NewRouter(WithRuleGo(ruleGo)).From("test").To("chain:handoff01").Process(func(router *Router, exchange *Exchange) bool {
+		mu.Lock()
+		results = append(results, exchange.Out.GetError())
+		mu.Unlock()
+		wg.Done()
+		return true
+	}).End()
+	send := func() {
+		wg.Add(1)
+		ep.DoProcess(router, &Exchange{In: &testMessage{}, Out: &testMessage{}})
+	}
+	send()
+	send()
+	assert.Equal(t, int64(2), ep.Handoff().Stats().Pending)
+	//积压达到阈值，消费协程阻塞直到超时拒绝
+	send()
+	mu.Lock()
+	assert.Equal(t, []error{ErrBackpressure}, results)
+	mu.Unlock()
+
+	handoffRelease <- struct{}{}
+	handoffRelease <- struct{}{}
+	wg.Wait()
+	stats := ep.Handoff().Stats()
+	assert.Equal(t, int64(0), stats.Pending)
+	assert.Equal(t, uint64(2), stats.Handoffs)
+	assert.Equal(t, uint64(1), stats.Rejected)
+	assert.Equal(t, int64(0), ep.Metrics()["pending"])
+}
+
+// waitUntil 等待条件成立，超时返回false
+func waitUntil(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
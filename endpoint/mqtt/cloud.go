@@ -0,0 +1,43 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/mqtt"
+)
+
+// NewAzureIotHub 创建接收Azure IoT Hub云到设备消息的端点
+// 使用config.CommandTopic()作为路由from，例如：
+// ep.AddRouter(endpoint.NewRouter().From(config.CommandTopic()).To("chain:default").End())
+func NewAzureIotHub(ruleConfig types.Config, config mqtt.AzureIotHubConfig) (*Mqtt, error) {
+	mqttConfig, opts, err := config.ToMqttConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &Mqtt{RuleConfig: ruleConfig, Config: mqttConfig, ClientOptions: opts}, nil
+}
+
+// NewAwsIotCore 创建接收AWS IoT Core命令主题消息的端点
+// 例如：ep.AddRouter(endpoint.NewRouter().From("cmd/gw01/#").To("chain:default").End())
+func NewAwsIotCore(ruleConfig types.Config, config mqtt.AwsIotCoreConfig) (*Mqtt, error) {
+	mqttConfig, opts, err := config.ToMqttConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &Mqtt{RuleConfig: ruleConfig, Config: mqttConfig, ClientOptions: opts}, nil
+}
@@ -17,6 +17,7 @@
 package mqtt
 
 import (
+	"errors"
 	"github.com/2018yuli/rulego/api/types"
 	"github.com/2018yuli/rulego/components/mqtt"
 	"github.com/2018yuli/rulego/endpoint"
@@ -30,6 +31,7 @@ import (
 type RequestMessage struct {
 	request paho.Message
 	msg     *types.RuleMsg
+	err     error
 }
 
 func (r *RequestMessage) Body() []byte {
@@ -70,6 +72,14 @@ func (r *RequestMessage) SetStatusCode(statusCode int) {
 func (r *RequestMessage) SetBody(body []byte) {
 }
 
+func (r *RequestMessage) SetError(err error) {
+	r.err = err
+}
+
+func (r *RequestMessage) GetError() error {
+	return r.err
+}
+
 func (r *RequestMessage) Request() paho.Message {
 	return r.request
 }
@@ -81,6 +91,7 @@ type ResponseMessage struct {
 	body     []byte
 	msg      *types.RuleMsg
 	headers  textproto.MIMEHeader
+	err      error
 }
 
 func (r *ResponseMessage) Body() []byte {
@@ -127,6 +138,14 @@ func (r *ResponseMessage) SetBody(body []byte) {
 	}
 }
 
+func (r *ResponseMessage) SetError(err error) {
+	r.err = err
+}
+
+func (r *ResponseMessage) GetError() error {
+	return r.err
+}
+
 func (r *ResponseMessage) Response() paho.Client {
 	return r.response
 }
@@ -136,7 +155,9 @@ type Mqtt struct {
 	endpoint.BaseEndpoint
 	RuleConfig types.Config
 	Config     mqtt.Config
-	client     *mqtt.Client
+	//ClientOptions 扩展客户端选项，例如云平台认证方式
+	ClientOptions []mqtt.ClientOption
+	client        *mqtt.Client
 }
 
 // Type 组件类型
@@ -148,10 +169,14 @@ func (m *Mqtt) New() types.Node {
 	return &Mqtt{}
 }
 
-// Init 初始化
+// Init 初始化，backpressure配置参考endpoint.BackpressureConfig
 func (m *Mqtt) Init(ruleConfig types.Config, configuration types.Configuration) error {
 	err := maps.Map2Struct(configuration, &m.Config)
 	m.RuleConfig = ruleConfig
+	if err == nil {
+		//规则链积压超过阈值时暂停消费
+		err = m.InitBackpressure(configuration)
+	}
 	return err
 }
 
@@ -167,6 +192,14 @@ func (m *Mqtt) Close() error {
 	return nil
 }
 
+// HealthCheck 检查是否已经连接到mqtt broker
+func (m *Mqtt) HealthCheck() error {
+	if m.client == nil {
+		return errors.New("mqtt endpoint not started")
+	}
+	return m.client.HealthCheck()
+}
+
 func (m *Mqtt) Id() string {
 	return m.Config.Server
 }
@@ -201,7 +234,7 @@ func (m *Mqtt) AddRouter(routers ...*endpoint.Router) *Mqtt {
 
 func (m *Mqtt) Start() error {
 	if m.client == nil {
-		if client, err := mqtt.NewClient(m.Config); err != nil {
+		if client, err := mqtt.NewClient(m.Config, m.ClientOptions...); err != nil {
 			return err
 		} else {
 			m.client = client
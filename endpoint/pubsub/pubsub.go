@@ -0,0 +1,355 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pubsub
+
+import (
+	"context"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/gcp"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/utils/maps"
+	"net/http"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+// Config Pub/Sub 接收端点配置
+type Config struct {
+	//ProjectId 项目ID
+	ProjectId string
+	//Endpoint 自定义接口地址，例如模拟器：http://127.0.0.1:8085/v1/
+	Endpoint string
+	//CredentialsFile 服务账号密钥文件，为空则使用环境变量GOOGLE_APPLICATION_CREDENTIALS或者元数据服务
+	CredentialsFile string
+	//AccessToken 固定访问token
+	AccessToken string
+	//MaxMessages 每次拉取最大消息数
+	MaxMessages int
+	//PullIntervalMs 没有拉取到消息时下次拉取的间隔，单位毫秒
+	PullIntervalMs int
+}
+
+// RequestMessage Pub/Sub请求消息
+type RequestMessage struct {
+	subscription string
+	message      gcp.PubSubMessage
+	msg          *types.RuleMsg
+	err          error
+}
+
+func (r *RequestMessage) Body() []byte {
+	return []byte(r.message.Data)
+}
+
+// Headers 消息属性
+func (r *RequestMessage) Headers() textproto.MIMEHeader {
+	header := make(map[string][]string)
+	for k, v := range r.message.Attributes {
+		header[k] = []string{v}
+	}
+	return header
+}
+
+func (r *RequestMessage) From() string {
+	return r.subscription
+}
+
+// GetParam 获取消息属性
+func (r *RequestMessage) GetParam(key string) string {
+	return r.message.Attributes[key]
+}
+
+func (r *RequestMessage) SetMsg(msg *types.RuleMsg) {
+	r.msg = msg
+}
+
+func (r *RequestMessage) GetMsg() *types.RuleMsg {
+	if r.msg == nil {
+		ruleMsg := types.NewMsg(0, r.From(), types.JSON, types.NewMetadata(), r.message.Data)
+		for k, v := range r.message.Attributes {
+			ruleMsg.Metadata.PutValue(k, v)
+		}
+		ruleMsg.Metadata.PutValue("subscription", r.subscription)
+		ruleMsg.Metadata.PutValue("messageId", r.message.MessageId)
+		if r.message.OrderingKey != "" {
+			ruleMsg.Metadata.PutValue("orderingKey", r.message.OrderingKey)
+		}
+		r.msg = &ruleMsg
+	}
+	return r.msg
+}
+
+func (r *RequestMessage) SetStatusCode(statusCode int) {
+}
+
+func (r *RequestMessage) SetBody(body []byte) {
+}
+
+func (r *RequestMessage) SetError(err error) {
+	r.err = err
+}
+
+func (r *RequestMessage) GetError() error {
+	return r.err
+}
+
+func (r *RequestMessage) Message() gcp.PubSubMessage {
+	return r.message
+}
+
+// ResponseMessage Pub/Sub响应消息
+// 规则链处理结束后，如果没有错误则确认消息，否则立即重新投递
+type ResponseMessage struct {
+	subscription string
+	message      gcp.PubSubMessage
+	body         []byte
+	msg          *types.RuleMsg
+	headers      textproto.MIMEHeader
+	err          error
+	ack          func(err error)
+}
+
+func (r *ResponseMessage) Body() []byte {
+	return r.body
+}
+
+func (r *ResponseMessage) Headers() textproto.MIMEHeader {
+	if r.headers == nil {
+		r.headers = make(map[string][]string)
+	}
+	return r.headers
+}
+
+func (r *ResponseMessage) From() string {
+	return r.subscription
+}
+
+func (r *ResponseMessage) GetParam(key string) string {
+	return ""
+}
+
+// SetMsg 规则链处理结束回调，并确认消息
+func (r *ResponseMessage) SetMsg(msg *types.RuleMsg) {
+	r.msg = msg
+	r.ack(r.err)
+}
+
+func (r *ResponseMessage) GetMsg() *types.RuleMsg {
+	return r.msg
+}
+
+func (r *ResponseMessage) SetStatusCode(statusCode int) {
+}
+
+func (r *ResponseMessage) SetBody(body []byte) {
+	r.body = body
+}
+
+func (r *ResponseMessage) SetError(err error) {
+	r.err = err
+}
+
+func (r *ResponseMessage) GetError() error {
+	return r.err
+}
+
+// PubSub Google Cloud Pub/Sub 接收端点，From为订阅名称或者完整路径projects/{project}/subscriptions/{subscription}
+// 每个路由启动一个协程拉取消息，规则链处理成功则确认消息，处理失败则立即重新投递
+// 如果路由没有To端，则处理完成后直接确认消息
+type PubSub struct {
+	endpoint.BaseEndpoint
+	RuleConfig types.Config
+	Config     Config
+	client     *gcp.PubSubClient
+	//cancels 路由拉取协程取消函数
+	cancels map[string]context.CancelFunc
+}
+
+// Type 组件类型
+func (p *PubSub) Type() string {
+	return "pubsub"
+}
+
+func (p *PubSub) New() types.Node {
+	return &PubSub{Config: Config{MaxMessages: 10, PullIntervalMs: 1000}}
+}
+
+// Init 初始化，backpressure配置参考endpoint.BackpressureConfig
+func (p *PubSub) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &p.Config)
+	p.RuleConfig = ruleConfig
+	if err == nil {
+		//规则链积压超过阈值时暂停消费
+		err = p.InitBackpressure(configuration)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (p *PubSub) Destroy() {
+	_ = p.Close()
+}
+
+// Close 停止所有拉取协程
+func (p *PubSub) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	for from, cancel := range p.cancels {
+		cancel()
+		delete(p.cancels, from)
+	}
+	return nil
+}
+
+func (p *PubSub) Id() string {
+	return p.Config.ProjectId
+}
+
+func (p *PubSub) AddRouterWithParams(router *endpoint.Router, params ...interface{}) error {
+	p.AddRouter(router)
+	return nil
+}
+
+func (p *PubSub) RemoveRouterWithParams(from string, params ...interface{}) error {
+	p.Lock()
+	defer p.Unlock()
+	if p.RouterStorage != nil {
+		delete(p.RouterStorage, from)
+	}
+	if cancel, ok := p.cancels[from]; ok {
+		cancel()
+		delete(p.cancels, from)
+	}
+	return nil
+}
+
+// AddRouter 添加路由
+func (p *PubSub) AddRouter(routers ...*endpoint.Router) *PubSub {
+	p.Lock()
+	defer p.Unlock()
+	if p.RouterStorage == nil {
+		p.RouterStorage = make(map[string]*endpoint.Router)
+	}
+	for _, router := range routers {
+		p.RouterStorage[router.FromToString()] = router
+		//服务已经启动
+		if p.client != nil {
+			p.startPull(router)
+		}
+	}
+	return p
+}
+
+// Start 启动
+func (p *PubSub) Start() error {
+	p.Lock()
+	defer p.Unlock()
+	if p.client == nil {
+		timeout := time.Minute
+		token, err := gcp.NewTokenProvider(p.Config.AccessToken, p.Config.CredentialsFile, gcp.PubSubScope, &http.Client{Timeout: timeout})
+		if err != nil {
+			return err
+		}
+		p.client = gcp.NewPubSubClient(p.Config.ProjectId, p.Config.Endpoint, token, timeout)
+		for _, router := range p.RouterStorage {
+			p.startPull(router)
+		}
+	}
+	return nil
+}
+
+// startPull 启动路由拉取协程，调用方需要持有锁
+func (p *PubSub) startPull(router *endpoint.Router) {
+	from := router.FromToString()
+	if from == "" {
+		return
+	}
+	if p.cancels == nil {
+		p.cancels = make(map[string]context.CancelFunc)
+	}
+	if cancel, ok := p.cancels[from]; ok {
+		cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancels[from] = cancel
+	go p.pull(ctx, router, from)
+}
+
+func (p *PubSub) pull(ctx context.Context, router *endpoint.Router, subscription string) {
+	for ctx.Err() == nil {
+		msgs, err := p.client.Pull(ctx, subscription, p.Config.MaxMessages)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			p.Printf("pubsub pull message from %s err :%v", subscription, err)
+		}
+		for _, msg := range msgs {
+			p.handler(router, subscription, msg)
+		}
+		if len(msgs) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(p.Config.PullIntervalMs) * time.Millisecond):
+			}
+		}
+	}
+}
+
+func (p *PubSub) handler(router *endpoint.Router, subscription string, msg gcp.PubSubMessage) {
+	defer func() {
+		//捕捉异常
+		if e := recover(); e != nil {
+			p.Printf("pubsub handler err :%v", e)
+		}
+	}()
+	var once sync.Once
+	ack := func(err error) {
+		once.Do(func() {
+			if err == nil {
+				err = p.client.Acknowledge(context.Background(), subscription, msg.AckId)
+			} else {
+				err = p.client.ModifyAckDeadline(context.Background(), subscription, 0, msg.AckId)
+			}
+			if err != nil {
+				p.Printf("pubsub ack message %s err :%v", msg.MessageId, err)
+			}
+		})
+	}
+	exchange := &endpoint.Exchange{
+		In: &RequestMessage{
+			subscription: subscription,
+			message:      msg,
+		},
+		Out: &ResponseMessage{
+			subscription: subscription,
+			message:      msg,
+			ack:          ack,
+		}}
+	p.DoProcess(router, exchange)
+	if from := router.GetFrom(); from == nil || from.GetTo() == nil {
+		ack(nil)
+	}
+}
+
+func (p *PubSub) Printf(format string, v ...interface{}) {
+	if p.RuleConfig.Logger != nil {
+		p.RuleConfig.Logger.Printf(format, v...)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pubsub
+
+import (
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+var chainJson = `{
+  "ruleChain": {"name": "测试规则链"},
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "jsFilter",
+        "configuration": {
+          "jsScript": "if (msg.temperature === undefined) { throw 'no temperature'; } return msg.temperature>10;"
+        }
+      }
+    ]
+  }
+}`
+
+func TestPubSubEndpoint(t *testing.T) {
+	config := rulego.NewConfig(types.WithDefaultPool())
+	_, err := rulego.New("pubsubTest", []byte(chainJson), rulego.WithConfig(config))
+	assert.Nil(t, err)
+	defer rulego.Del("pubsubTest")
+
+	var lock sync.Mutex
+	var pulled bool
+	acked := make(chan string, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var request struct {
+			AckIds             []string `json:"ackIds"`
+			AckDeadlineSeconds int      `json:"ackDeadlineSeconds"`
+		}
+		_ = json.Unmarshal(b, &request)
+		switch r.URL.Path {
+		case "/v1/projects/my-project/subscriptions/sub1:pull":
+			lock.Lock()
+			defer lock.Unlock()
+			if pulled {
+				_, _ = w.Write([]byte(`{}`))
+				return
+			}
+			pulled = true
+			_, _ = w.Write([]byte(`{"receivedMessages":[
+{"ackId":"a1","message":{"data":"eyJ0ZW1wZXJhdHVyZSI6NDF9","messageId":"1"}},
+{"ackId":"a2","message":{"data":"eyJodW1pZGl0eSI6ODB9","messageId":"2"}}]}`))
+		case "/v1/projects/my-project/subscriptions/sub1:acknowledge":
+			acked <- "ack:" + request.AckIds[0]
+			_, _ = w.Write([]byte(`{}`))
+		case "/v1/projects/my-project/subscriptions/sub1:modifyAckDeadline":
+			assert.Equal(t, 0, request.AckDeadlineSeconds)
+			acked <- "nack:" + request.AckIds[0]
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	pubsubEndpoint := &PubSub{
+		RuleConfig: config,
+		Config:     Config{ProjectId: "my-project", Endpoint: server.URL + "/v1/", AccessToken: "token1", MaxMessages: 10, PullIntervalMs: 50},
+	}
+	router := endpoint.NewRouter(endpoint.WithRuleConfig(config)).From("sub1").To("chain:pubsubTest").End()
+	pubsubEndpoint.AddRouter(router)
+	err = pubsubEndpoint.Start()
+	assert.Nil(t, err)
+	defer pubsubEndpoint.Destroy()
+
+	results := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case result := <-acked:
+			results[result] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("wait ack timeout")
+		}
+	}
+	assert.True(t, results["ack:a1"])
+	assert.True(t, results["nack:a2"])
+
+	//没有To端，直接确认
+	lock.Lock()
+	pulled = false
+	lock.Unlock()
+	err = pubsubEndpoint.RemoveRouterWithParams("sub1")
+	assert.Nil(t, err)
+	pubsubEndpoint.AddRouter(endpoint.NewRouter().From("sub1").End())
+	select {
+	case result := <-acked:
+		assert.Equal(t, "ack:a1", result)
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait ack timeout")
+	}
+}
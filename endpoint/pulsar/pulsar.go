@@ -0,0 +1,372 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsar
+
+import (
+	"context"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/pulsar"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/utils/maps"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Config Pulsar 接收端点配置
+type Config struct {
+	//Server WebSocket服务地址，例如：ws://127.0.0.1:8080
+	Server string
+	//Token JWT认证token
+	Token string
+	//Subscription 订阅名称
+	Subscription string
+	//SubscriptionType 订阅类型：Exclusive、Shared、Failover、Key_Shared，默认Shared
+	SubscriptionType string
+	//ConnectTimeoutMs 连接超时，单位毫秒
+	ConnectTimeoutMs int
+	//ReconnectIntervalMs 连接断开后重连间隔，单位毫秒
+	ReconnectIntervalMs int
+}
+
+// RequestMessage Pulsar请求消息
+type RequestMessage struct {
+	topic   string
+	message pulsar.ConsumerMessage
+	msg     *types.RuleMsg
+	err     error
+}
+
+func (r *RequestMessage) Body() []byte {
+	return []byte(r.message.Payload)
+}
+
+// Headers 消息属性
+func (r *RequestMessage) Headers() textproto.MIMEHeader {
+	header := make(map[string][]string)
+	for k, v := range r.message.Properties {
+		header[k] = []string{v}
+	}
+	return header
+}
+
+func (r *RequestMessage) From() string {
+	return r.topic
+}
+
+// GetParam 获取消息属性
+func (r *RequestMessage) GetParam(key string) string {
+	return r.message.Properties[key]
+}
+
+func (r *RequestMessage) SetMsg(msg *types.RuleMsg) {
+	r.msg = msg
+}
+
+func (r *RequestMessage) GetMsg() *types.RuleMsg {
+	if r.msg == nil {
+		ruleMsg := types.NewMsg(0, r.From(), types.JSON, types.NewMetadata(), r.message.Payload)
+		for k, v := range r.message.Properties {
+			ruleMsg.Metadata.PutValue(k, v)
+		}
+		ruleMsg.Metadata.PutValue("topic", r.topic)
+		ruleMsg.Metadata.PutValue("messageId", r.message.MessageId)
+		ruleMsg.Metadata.PutValue("publishTime", r.message.PublishTime)
+		ruleMsg.Metadata.PutValue("redeliveryCount", strconv.Itoa(r.message.RedeliveryCount))
+		if r.message.Key != "" {
+			ruleMsg.Metadata.PutValue("key", r.message.Key)
+		}
+		r.msg = &ruleMsg
+	}
+	return r.msg
+}
+
+func (r *RequestMessage) SetStatusCode(statusCode int) {
+}
+
+func (r *RequestMessage) SetBody(body []byte) {
+}
+
+func (r *RequestMessage) SetError(err error) {
+	r.err = err
+}
+
+func (r *RequestMessage) GetError() error {
+	return r.err
+}
+
+func (r *RequestMessage) Message() pulsar.ConsumerMessage {
+	return r.message
+}
+
+// ResponseMessage Pulsar响应消息
+// 规则链处理结束后，如果没有错误则确认消息，否则否认消息，服务端稍后重新投递
+type ResponseMessage struct {
+	topic   string
+	message pulsar.ConsumerMessage
+	body    []byte
+	msg     *types.RuleMsg
+	headers textproto.MIMEHeader
+	err     error
+	ack     func(err error)
+}
+
+func (r *ResponseMessage) Body() []byte {
+	return r.body
+}
+
+func (r *ResponseMessage) Headers() textproto.MIMEHeader {
+	if r.headers == nil {
+		r.headers = make(map[string][]string)
+	}
+	return r.headers
+}
+
+func (r *ResponseMessage) From() string {
+	return r.topic
+}
+
+func (r *ResponseMessage) GetParam(key string) string {
+	return ""
+}
+
+// SetMsg 规则链处理结束回调，并确认消息
+func (r *ResponseMessage) SetMsg(msg *types.RuleMsg) {
+	r.msg = msg
+	r.ack(r.err)
+}
+
+func (r *ResponseMessage) GetMsg() *types.RuleMsg {
+	return r.msg
+}
+
+func (r *ResponseMessage) SetStatusCode(statusCode int) {
+}
+
+func (r *ResponseMessage) SetBody(body []byte) {
+	r.body = body
+}
+
+func (r *ResponseMessage) SetError(err error) {
+	r.err = err
+}
+
+func (r *ResponseMessage) GetError() error {
+	return r.err
+}
+
+// Pulsar Apache Pulsar 接收端点，From为主题
+// 每个路由使用一个消费者订阅主题，规则链处理成功则确认消息，处理失败则否认消息
+// 如果路由没有To端，则处理完成后直接确认消息
+type Pulsar struct {
+	endpoint.BaseEndpoint
+	RuleConfig types.Config
+	Config     Config
+	started    bool
+	//cancels 路由消费协程取消函数
+	cancels map[string]context.CancelFunc
+}
+
+// Type 组件类型
+func (p *Pulsar) Type() string {
+	return "pulsar"
+}
+
+func (p *Pulsar) New() types.Node {
+	return &Pulsar{Config: Config{SubscriptionType: "Shared", ConnectTimeoutMs: 5000, ReconnectIntervalMs: 3000}}
+}
+
+// Init 初始化，backpressure配置参考endpoint.BackpressureConfig
+func (p *Pulsar) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &p.Config)
+	p.RuleConfig = ruleConfig
+	if err == nil {
+		//规则链积压超过阈值时暂停消费
+		err = p.InitBackpressure(configuration)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (p *Pulsar) Destroy() {
+	_ = p.Close()
+}
+
+// Close 停止所有消费者
+func (p *Pulsar) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	for from, cancel := range p.cancels {
+		cancel()
+		delete(p.cancels, from)
+	}
+	p.started = false
+	return nil
+}
+
+func (p *Pulsar) Id() string {
+	return p.Config.Server
+}
+
+func (p *Pulsar) AddRouterWithParams(router *endpoint.Router, params ...interface{}) error {
+	p.AddRouter(router)
+	return nil
+}
+
+func (p *Pulsar) RemoveRouterWithParams(from string, params ...interface{}) error {
+	p.Lock()
+	defer p.Unlock()
+	if p.RouterStorage != nil {
+		delete(p.RouterStorage, from)
+	}
+	if cancel, ok := p.cancels[from]; ok {
+		cancel()
+		delete(p.cancels, from)
+	}
+	return nil
+}
+
+// AddRouter 添加路由
+func (p *Pulsar) AddRouter(routers ...*endpoint.Router) *Pulsar {
+	p.Lock()
+	defer p.Unlock()
+	if p.RouterStorage == nil {
+		p.RouterStorage = make(map[string]*endpoint.Router)
+	}
+	for _, router := range routers {
+		p.RouterStorage[router.FromToString()] = router
+		//服务已经启动
+		if p.started {
+			p.startConsume(router)
+		}
+	}
+	return p
+}
+
+// Start 启动
+func (p *Pulsar) Start() error {
+	p.Lock()
+	defer p.Unlock()
+	if !p.started {
+		p.started = true
+		for _, router := range p.RouterStorage {
+			p.startConsume(router)
+		}
+	}
+	return nil
+}
+
+// startConsume 启动路由消费协程，调用方需要持有锁
+func (p *Pulsar) startConsume(router *endpoint.Router) {
+	from := router.FromToString()
+	if from == "" {
+		return
+	}
+	if p.cancels == nil {
+		p.cancels = make(map[string]context.CancelFunc)
+	}
+	if cancel, ok := p.cancels[from]; ok {
+		cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancels[from] = cancel
+	go p.consume(ctx, router, from)
+}
+
+// consume 订阅主题并处理消息，连接断开则重连
+func (p *Pulsar) consume(ctx context.Context, router *endpoint.Router, topic string) {
+	conf := pulsar.Config{
+		Server:         p.Config.Server,
+		Token:          p.Config.Token,
+		ConnectTimeout: time.Duration(p.Config.ConnectTimeoutMs) * time.Millisecond,
+	}
+	for ctx.Err() == nil {
+		consumer, err := pulsar.NewConsumer(conf, topic, p.Config.Subscription, p.Config.SubscriptionType)
+		if err == nil {
+			//取消时关闭连接，中断阻塞的Receive
+			stop := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+					_ = consumer.Close()
+				case <-stop:
+				}
+			}()
+			for {
+				var msg pulsar.ConsumerMessage
+				if msg, err = consumer.Receive(); err != nil {
+					break
+				}
+				p.handler(router, topic, consumer, msg)
+			}
+			close(stop)
+			_ = consumer.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		p.Printf("pulsar consume topic %s err :%v", topic, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(p.Config.ReconnectIntervalMs) * time.Millisecond):
+		}
+	}
+}
+
+func (p *Pulsar) handler(router *endpoint.Router, topic string, consumer *pulsar.Consumer, msg pulsar.ConsumerMessage) {
+	defer func() {
+		//捕捉异常
+		if e := recover(); e != nil {
+			p.Printf("pulsar handler err :%v", e)
+		}
+	}()
+	var once sync.Once
+	ack := func(err error) {
+		once.Do(func() {
+			if err == nil {
+				err = consumer.Ack(msg.MessageId)
+			} else {
+				err = consumer.Nack(msg.MessageId)
+			}
+			if err != nil {
+				p.Printf("pulsar ack message %s err :%v", msg.MessageId, err)
+			}
+		})
+	}
+	exchange := &endpoint.Exchange{
+		In: &RequestMessage{
+			topic:   topic,
+			message: msg,
+		},
+		Out: &ResponseMessage{
+			topic:   topic,
+			message: msg,
+			ack:     ack,
+		}}
+	p.DoProcess(router, exchange)
+	if from := router.GetFrom(); from == nil || from.GetTo() == nil {
+		ack(nil)
+	}
+}
+
+func (p *Pulsar) Printf(format string, v ...interface{}) {
+	if p.RuleConfig.Logger != nil {
+		p.RuleConfig.Logger.Printf(format, v...)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsar
+
+import (
+	"encoding/base64"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var chainJson = `{
+  "ruleChain": {"name": "测试规则链"},
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "jsFilter",
+        "configuration": {
+          "jsScript": "if (msg.temperature === undefined) { throw 'no temperature'; } return msg.temperature>10;"
+        }
+      }
+    ]
+  }
+}`
+
+func TestPulsarEndpoint(t *testing.T) {
+	config := rulego.NewConfig(types.WithDefaultPool())
+	_, err := rulego.New("pulsarTest", []byte(chainJson), rulego.WithConfig(config))
+	assert.Nil(t, err)
+	defer rulego.Del("pulsarTest")
+
+	upgrader := websocket.Upgrader{}
+	acked := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ws/v2/consumer/persistent/public/default/t1/sub1", r.URL.Path)
+		assert.Equal(t, "Shared", r.URL.Query().Get("subscriptionType"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.WriteJSON(map[string]interface{}{"messageId": "m1", "payload": base64.StdEncoding.EncodeToString([]byte(`{"temperature":41}`)),
+			"properties": map[string]string{"deviceId": "aa"}})
+		_ = conn.WriteJSON(map[string]interface{}{"messageId": "m2", "payload": base64.StdEncoding.EncodeToString([]byte(`{"humidity":80}`))})
+		for {
+			var ack map[string]string
+			if conn.ReadJSON(&ack) != nil {
+				return
+			}
+			if ack["type"] == "negativeAcknowledge" {
+				acked <- "nack:" + ack["messageId"]
+			} else {
+				acked <- "ack:" + ack["messageId"]
+			}
+		}
+	}))
+	defer server.Close()
+
+	pulsarEndpoint := (&Pulsar{}).New().(*Pulsar)
+	err = pulsarEndpoint.Init(config, types.Configuration{
+		"server":       "ws" + strings.TrimPrefix(server.URL, "http"),
+		"subscription": "sub1",
+	})
+	assert.Nil(t, err)
+	router := endpoint.NewRouter(endpoint.WithRuleConfig(config)).From("t1").Transform(func(router *endpoint.Router, exchange *endpoint.Exchange) bool {
+		assert.Equal(t, "t1", exchange.In.GetMsg().Metadata.GetValue("topic"))
+		return true
+	}).To("chain:pulsarTest").End()
+	pulsarEndpoint.AddRouter(router)
+	assert.Nil(t, pulsarEndpoint.Start())
+	defer pulsarEndpoint.Destroy()
+
+	results := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case result := <-acked:
+			results[result] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("wait ack timeout")
+		}
+	}
+	assert.True(t, results["ack:m1"])
+	assert.True(t, results["nack:m2"])
+}
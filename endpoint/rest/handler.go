@@ -0,0 +1,212 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rest
+
+import (
+	"context"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/str"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//StatusCodeKey 规则链通过该元数据设置http响应状态码
+	StatusCodeKey = "statusCode"
+	//DefaultHeaderPrefix 请求头放到元数据时key的默认前缀
+	DefaultHeaderPrefix = "header_"
+	//DefaultChainTimeout 等待规则链处理结束的默认超时时间
+	DefaultChainTimeout = 30 * time.Second
+)
+
+type msgContextKey struct{}
+
+// MsgFromContext 获取中间件规则链处理结束后的消息
+func MsgFromContext(ctx context.Context) (types.RuleMsg, bool) {
+	msg, ok := ctx.Value(msgContextKey{}).(types.RuleMsg)
+	return msg, ok
+}
+
+// ChainHandlerOption ChainHandler选项
+type ChainHandlerOption func(h *ChainHandler)
+
+// WithTimeout 设置等待规则链处理结束的超时时间，超时响应504
+func WithTimeout(timeout time.Duration) ChainHandlerOption {
+	return func(h *ChainHandler) {
+		h.timeout = timeout
+	}
+}
+
+// WithHeaderPrefix 设置请求头放到元数据时key的前缀
+func WithHeaderPrefix(prefix string) ChainHandlerOption {
+	return func(h *ChainHandler) {
+		h.headerPrefix = prefix
+	}
+}
+
+// WithMsgType 设置消息类型，默认使用请求路径
+func WithMsgType(msgType string) ChainHandlerOption {
+	return func(h *ChainHandler) {
+		h.msgType = msgType
+	}
+}
+
+// ChainHandler 把规则链包装成标准 http.Handler
+// 请求转换成消息：body作为msg.Data，url参数和请求头(带前缀)放到元数据，
+// 元数据 httpMethod、httpPath、remoteAddr 记录请求信息
+// 规则链处理结束后，使用最终消息作为响应：msg.Data作为响应体，元数据statusCode作为状态码(默认200)
+// 规则链处理失败响应500，如果有多个分支结束，使用第一个结束的分支
+type ChainHandler struct {
+	ruleEngine   *rulego.RuleEngine
+	timeout      time.Duration
+	headerPrefix string
+	msgType      string
+}
+
+// NewChainHandler 创建规则链http.Handler
+func NewChainHandler(ruleEngine *rulego.RuleEngine, opts ...ChainHandlerOption) *ChainHandler {
+	h := &ChainHandler{
+		ruleEngine:   ruleEngine,
+		timeout:      DefaultChainTimeout,
+		headerPrefix: DefaultHeaderPrefix,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ServeHTTP 处理http请求
+func (h *ChainHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	msg, err, ok := h.process(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+		return
+	}
+	writeMsg(w, msg, err)
+}
+
+// ChainMiddleware 把规则链包装成http中间件，用于鉴权、富化和路由等场景
+// 规则链处理失败，或者最终消息元数据statusCode不是2xx，直接使用最终消息响应，不再调用next
+// 否则调用next，next可以通过 MsgFromContext 获取最终消息
+func ChainMiddleware(ruleEngine *rulego.RuleEngine, opts ...ChainHandlerOption) func(next http.Handler) http.Handler {
+	h := NewChainHandler(ruleEngine, opts...)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			msg, err, ok := h.process(r)
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+				return
+			}
+			if code := statusCode(msg); err != nil || code < 200 || code >= 300 {
+				writeMsg(w, msg, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), msgContextKey{}, msg)))
+		})
+	}
+}
+
+// process 把请求交给规则链处理，并等待处理结束，ok=false表示超时
+func (h *ChainHandler) process(r *http.Request) (msg types.RuleMsg, err error, ok bool) {
+	body, _ := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	//中间件模式下，后续handler需要读取body
+	r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+	metadata := types.NewMetadata()
+	for key, value := range r.URL.Query() {
+		if len(value) > 1 {
+			metadata.PutValue(key, str.ToString(value))
+		} else {
+			metadata.PutValue(key, value[0])
+		}
+	}
+	for key, value := range r.Header {
+		metadata.PutValue(h.headerPrefix+key, strings.Join(value, ","))
+	}
+	metadata.PutValue("httpMethod", r.Method)
+	metadata.PutValue("httpPath", r.URL.Path)
+	metadata.PutValue("remoteAddr", r.RemoteAddr)
+
+	dataType := types.TEXT
+	if strings.HasPrefix(r.Header.Get(ContentTypeKey), JsonContextType) {
+		dataType = types.JSON
+	}
+	msgType := h.msgType
+	if msgType == "" {
+		msgType = r.URL.Path
+	}
+	in := types.NewMsg(0, msgType, dataType, metadata, string(body))
+
+	done := make(chan struct{})
+	var once sync.Once
+	h.ruleEngine.OnMsgWithOptions(in, types.WithContext(r.Context()),
+		types.WithEndFunc(func(outMsg types.RuleMsg, outErr error) {
+			once.Do(func() {
+				msg, err = outMsg, outErr
+				close(done)
+			})
+		}))
+	timer := time.NewTimer(h.timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return msg, err, true
+	case <-r.Context().Done():
+		//防止回调写入返回值
+		once.Do(func() {})
+		return in, r.Context().Err(), true
+	case <-timer.C:
+		once.Do(func() {})
+		return types.RuleMsg{}, nil, false
+	}
+}
+
+// statusCode 获取消息元数据中的状态码，默认200
+func statusCode(msg types.RuleMsg) int {
+	if v := msg.Metadata.GetValue(StatusCodeKey); v != nil {
+		if code, err := strconv.Atoi(str.ToString(v)); err == nil {
+			return code
+		}
+	}
+	return http.StatusOK
+}
+
+// writeMsg 使用消息响应
+func writeMsg(w http.ResponseWriter, msg types.RuleMsg, err error) {
+	if err != nil {
+		code := http.StatusInternalServerError
+		if msg.Metadata.Has(StatusCodeKey) {
+			code = statusCode(msg)
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if msg.DataType == types.JSON {
+		w.Header().Set(ContentTypeKey, JsonContextType)
+	} else {
+		w.Header().Set(ContentTypeKey, "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(statusCode(msg))
+	_, _ = w.Write([]byte(msg.Data))
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rest
+
+import (
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var authChainJson = `{
+  "ruleChain": {"name": "鉴权规则链"},
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "jsFilter",
+        "configuration": {
+          "jsScript": "if (metadata.fail === 'true') { throw 'fail'; } return metadata.header_Authorization === 'Bearer ok';"
+        }
+      },
+      {
+        "id": "s2",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "metadata.userId='u1'; msg.user=metadata.userId; msg.method=metadata.httpMethod; return {'msg':msg,'metadata':metadata,'msgType':msgType};"
+        }
+      },
+      {
+        "id": "s3",
+        "type": "jsTransform",
+        "configuration": {
+          "jsScript": "metadata.statusCode='401'; return {'msg':{'error':'unauthorized'},'metadata':metadata,'msgType':msgType};"
+        }
+      }
+    ],
+    "connections": [
+      {"fromId": "s1", "toId": "s2", "type": "True"},
+      {"fromId": "s1", "toId": "s3", "type": "False"}
+    ]
+  }
+}`
+
+func newAuthRuleEngine(t *testing.T, id string) *rulego.RuleEngine {
+	config := rulego.NewConfig(types.WithDefaultPool())
+	ruleEngine, err := rulego.New(id, []byte(authChainJson), rulego.WithConfig(config))
+	assert.Nil(t, err)
+	return ruleEngine
+}
+
+func doRequest(t *testing.T, handler http.Handler, authorization, query string) (*http.Response, string) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders"+query, strings.NewReader(`{"orderId":"o1"}`))
+	req.Header.Set(ContentTypeKey, JsonContextType)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestChainHandler(t *testing.T) {
+	ruleEngine := newAuthRuleEngine(t, "chainHandlerTest")
+	defer rulego.Del("chainHandlerTest")
+	handler := NewChainHandler(ruleEngine)
+
+	resp, body := doRequest(t, handler, "Bearer ok", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, JsonContextType, resp.Header.Get(ContentTypeKey))
+	assert.True(t, strings.Contains(body, `"user":"u1"`))
+	assert.True(t, strings.Contains(body, `"method":"POST"`))
+	assert.True(t, strings.Contains(body, `"orderId":"o1"`))
+
+	resp, body = doRequest(t, handler, "", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `{"error":"unauthorized"}`, body)
+
+	//规则链处理失败
+	resp, _ = doRequest(t, handler, "", "?fail=true")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestChainMiddleware(t *testing.T) {
+	ruleEngine := newAuthRuleEngine(t, "chainMiddlewareTest")
+	defer rulego.Del("chainMiddlewareTest")
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg, ok := MsgFromContext(r.Context())
+		assert.True(t, ok)
+		body, _ := io.ReadAll(r.Body)
+		//后续handler仍然可以读取请求body
+		assert.Equal(t, `{"orderId":"o1"}`, string(body))
+		_, _ = w.Write([]byte("hello " + msg.Metadata.GetValue("userId").(string)))
+	})
+	handler := ChainMiddleware(ruleEngine, WithTimeout(5*time.Second))(next)
+
+	resp, body := doRequest(t, handler, "Bearer ok", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello u1", body)
+
+	resp, body = doRequest(t, handler, "Bearer bad", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `{"error":"unauthorized"}`, body)
+}
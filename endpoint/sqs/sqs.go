@@ -0,0 +1,374 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqs
+
+import (
+	"context"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/aws"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/utils/maps"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+// Config SQS 接收端点配置
+type Config struct {
+	//Region 区域，例如：us-east-1
+	Region string
+	//Endpoint 自定义接口地址，例如本地模拟器，默认使用区域地址
+	Endpoint string
+	//AccessKeyId 访问密钥ID，为空则从环境变量AWS_ACCESS_KEY_ID获取
+	AccessKeyId string
+	//SecretAccessKey 访问密钥，为空则从环境变量AWS_SECRET_ACCESS_KEY获取
+	SecretAccessKey string
+	//SessionToken 临时会话token
+	SessionToken string
+	//MaxNumberOfMessages 每次拉取最大消息数，最大10
+	MaxNumberOfMessages int
+	//WaitTimeSeconds 长轮询等待时间，单位秒，最大20
+	WaitTimeSeconds int
+	//VisibilityTimeout 消息可见性超时，单位秒，0使用队列配置
+	VisibilityTimeout int
+}
+
+// RequestMessage SQS请求消息
+type RequestMessage struct {
+	queueUrl string
+	message  aws.Message
+	msg      *types.RuleMsg
+	err      error
+}
+
+func (r *RequestMessage) Body() []byte {
+	return []byte(r.message.Body)
+}
+
+// Headers 消息属性
+func (r *RequestMessage) Headers() textproto.MIMEHeader {
+	header := make(map[string][]string)
+	for k, v := range r.message.Attributes {
+		header[k] = []string{v}
+	}
+	return header
+}
+
+func (r *RequestMessage) From() string {
+	return r.queueUrl
+}
+
+// GetParam 获取消息属性
+func (r *RequestMessage) GetParam(key string) string {
+	return r.message.Attributes[key]
+}
+
+func (r *RequestMessage) SetMsg(msg *types.RuleMsg) {
+	r.msg = msg
+}
+
+func (r *RequestMessage) GetMsg() *types.RuleMsg {
+	if r.msg == nil {
+		ruleMsg := types.NewMsg(0, r.From(), types.JSON, types.NewMetadata(), r.message.Body)
+		for k, v := range r.message.Attributes {
+			ruleMsg.Metadata.PutValue(k, v)
+		}
+		ruleMsg.Metadata.PutValue("queueUrl", r.queueUrl)
+		ruleMsg.Metadata.PutValue("messageId", r.message.Id)
+		r.msg = &ruleMsg
+	}
+	return r.msg
+}
+
+func (r *RequestMessage) SetStatusCode(statusCode int) {
+}
+
+func (r *RequestMessage) SetBody(body []byte) {
+}
+
+func (r *RequestMessage) SetError(err error) {
+	r.err = err
+}
+
+func (r *RequestMessage) GetError() error {
+	return r.err
+}
+
+func (r *RequestMessage) Message() aws.Message {
+	return r.message
+}
+
+// ResponseMessage SQS响应消息
+// 规则链所有分支处理结束后，如果没有错误则删除(确认)消息，任意分支出错则立即重新投递
+type ResponseMessage struct {
+	queueUrl string
+	message  aws.Message
+	body     []byte
+	msg      *types.RuleMsg
+	headers  textproto.MIMEHeader
+	err      error
+	ack      func(err error)
+	//多个分支同时结束时保护msg和err
+	lock sync.Mutex
+}
+
+func (r *ResponseMessage) Body() []byte {
+	return r.body
+}
+
+func (r *ResponseMessage) Headers() textproto.MIMEHeader {
+	if r.headers == nil {
+		r.headers = make(map[string][]string)
+	}
+	return r.headers
+}
+
+func (r *ResponseMessage) From() string {
+	return r.queueUrl
+}
+
+func (r *ResponseMessage) GetParam(key string) string {
+	return ""
+}
+
+// SetMsg 规则链分支处理结束回调
+func (r *ResponseMessage) SetMsg(msg *types.RuleMsg) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.msg = msg
+}
+
+func (r *ResponseMessage) GetMsg() *types.RuleMsg {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.msg
+}
+
+// Completed 规则链所有分支处理结束，确认消息
+func (r *ResponseMessage) Completed() {
+	r.ack(r.GetError())
+}
+
+func (r *ResponseMessage) SetStatusCode(statusCode int) {
+}
+
+func (r *ResponseMessage) SetBody(body []byte) {
+	r.body = body
+}
+
+// SetError 记录分支处理错误，成功的分支不覆盖其他分支的错误
+func (r *ResponseMessage) SetError(err error) {
+	if err == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.err = err
+}
+
+func (r *ResponseMessage) GetError() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+// Sqs AWS SQS 接收端点，From为队列地址
+// 每个路由启动一个长轮询协程拉取消息，规则链所有分支处理成功则删除消息，任意分支处理失败则立即重新投递
+// 如果路由没有To端，则处理完成后直接删除消息
+type Sqs struct {
+	endpoint.BaseEndpoint
+	RuleConfig types.Config
+	Config     Config
+	client     *aws.SqsClient
+	//cancels 路由拉取协程取消函数
+	cancels map[string]context.CancelFunc
+}
+
+// Type 组件类型
+func (s *Sqs) Type() string {
+	return "sqs"
+}
+
+func (s *Sqs) New() types.Node {
+	return &Sqs{Config: Config{MaxNumberOfMessages: 10, WaitTimeSeconds: 20}}
+}
+
+// Init 初始化，backpressure配置参考endpoint.BackpressureConfig
+func (s *Sqs) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &s.Config)
+	s.RuleConfig = ruleConfig
+	if err == nil {
+		//规则链积压超过阈值时暂停消费
+		err = s.InitBackpressure(configuration)
+	}
+	return err
+}
+
+// Destroy 销毁
+func (s *Sqs) Destroy() {
+	_ = s.Close()
+}
+
+// Close 停止所有拉取协程
+func (s *Sqs) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	for from, cancel := range s.cancels {
+		cancel()
+		delete(s.cancels, from)
+	}
+	return nil
+}
+
+func (s *Sqs) Id() string {
+	if s.Config.Endpoint != "" {
+		return s.Config.Endpoint
+	}
+	return s.Config.Region
+}
+
+func (s *Sqs) AddRouterWithParams(router *endpoint.Router, params ...interface{}) error {
+	s.AddRouter(router)
+	return nil
+}
+
+func (s *Sqs) RemoveRouterWithParams(from string, params ...interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.RouterStorage != nil {
+		delete(s.RouterStorage, from)
+	}
+	if cancel, ok := s.cancels[from]; ok {
+		cancel()
+		delete(s.cancels, from)
+	}
+	return nil
+}
+
+// AddRouter 添加路由
+func (s *Sqs) AddRouter(routers ...*endpoint.Router) *Sqs {
+	s.Lock()
+	defer s.Unlock()
+	if s.RouterStorage == nil {
+		s.RouterStorage = make(map[string]*endpoint.Router)
+	}
+	for _, router := range routers {
+		s.RouterStorage[router.FromToString()] = router
+		//服务已经启动
+		if s.client != nil {
+			s.startPoll(router)
+		}
+	}
+	return s
+}
+
+// Start 启动
+func (s *Sqs) Start() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.client == nil {
+		credentials := aws.NewCredentialsProvider(s.Config.AccessKeyId, s.Config.SecretAccessKey, s.Config.SessionToken)
+		//http超时需要大于长轮询等待时间
+		timeout := time.Duration(s.Config.WaitTimeSeconds)*time.Second + 10*time.Second
+		s.client = aws.NewSqsClient(s.Config.Region, s.Config.Endpoint, credentials, timeout)
+		for _, router := range s.RouterStorage {
+			s.startPoll(router)
+		}
+	}
+	return nil
+}
+
+// startPoll 启动路由拉取协程，调用方需要持有锁
+func (s *Sqs) startPoll(router *endpoint.Router) {
+	from := router.FromToString()
+	if from == "" {
+		return
+	}
+	if s.cancels == nil {
+		s.cancels = make(map[string]context.CancelFunc)
+	}
+	if cancel, ok := s.cancels[from]; ok {
+		cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancels[from] = cancel
+	go s.poll(ctx, router, from)
+}
+
+func (s *Sqs) poll(ctx context.Context, router *endpoint.Router, queueUrl string) {
+	for ctx.Err() == nil {
+		msgs, err := s.client.ReceiveMessage(ctx, queueUrl, s.Config.MaxNumberOfMessages,
+			time.Duration(s.Config.WaitTimeSeconds)*time.Second, time.Duration(s.Config.VisibilityTimeout)*time.Second)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.Printf("sqs receive message from %s err :%v", queueUrl, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for _, msg := range msgs {
+			s.handler(router, queueUrl, msg)
+		}
+	}
+}
+
+func (s *Sqs) handler(router *endpoint.Router, queueUrl string, msg aws.Message) {
+	defer func() {
+		//捕捉异常
+		if e := recover(); e != nil {
+			s.Printf("sqs handler err :%v", e)
+		}
+	}()
+	var once sync.Once
+	ack := func(err error) {
+		once.Do(func() {
+			if err == nil {
+				err = s.client.DeleteMessage(context.Background(), queueUrl, msg.ReceiptHandle)
+			} else {
+				err = s.client.ChangeMessageVisibility(context.Background(), queueUrl, msg.ReceiptHandle, 0)
+			}
+			if err != nil {
+				s.Printf("sqs ack message %s err :%v", msg.Id, err)
+			}
+		})
+	}
+	exchange := &endpoint.Exchange{
+		In: &RequestMessage{
+			queueUrl: queueUrl,
+			message:  msg,
+		},
+		Out: &ResponseMessage{
+			queueUrl: queueUrl,
+			message:  msg,
+			ack:      ack,
+		}}
+	s.DoProcess(router, exchange)
+	if from := router.GetFrom(); from == nil || from.GetTo() == nil {
+		ack(nil)
+	}
+}
+
+func (s *Sqs) Printf(format string, v ...interface{}) {
+	if s.RuleConfig.Logger != nil {
+		s.RuleConfig.Logger.Printf(format, v...)
+	}
+}
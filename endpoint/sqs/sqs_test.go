@@ -0,0 +1,179 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqs
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/test/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+var chainJson = `{
+  "ruleChain": {"name": "测试规则链"},
+  "metadata": {
+    "nodes": [
+      {
+        "id": "s1",
+        "type": "jsFilter",
+        "configuration": {
+          "jsScript": "if (msg.temperature === undefined) { throw 'no temperature'; } return msg.temperature>10;"
+        }
+      }
+    ]
+  }
+}`
+
+// newSqsTestServer 模拟SQS服务，第一次拉取返回messages，确认结果发送到acked
+func newSqsTestServer(t *testing.T, messages string) (*httptest.Server, chan string) {
+	var lock sync.Mutex
+	var received bool
+	acked := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.Form.Get("Action") {
+		case "ReceiveMessage":
+			lock.Lock()
+			defer lock.Unlock()
+			if received {
+				time.Sleep(50 * time.Millisecond)
+				_, _ = w.Write([]byte(`<ReceiveMessageResponse><ReceiveMessageResult></ReceiveMessageResult></ReceiveMessageResponse>`))
+				return
+			}
+			received = true
+			_, _ = w.Write([]byte(`<ReceiveMessageResponse><ReceiveMessageResult>` + messages + `</ReceiveMessageResult></ReceiveMessageResponse>`))
+		case "DeleteMessage":
+			acked <- "delete:" + r.Form.Get("ReceiptHandle")
+		case "ChangeMessageVisibility":
+			assert.Equal(t, "0", r.Form.Get("VisibilityTimeout"))
+			acked <- "nack:" + r.Form.Get("ReceiptHandle")
+		}
+	}))
+	return server, acked
+}
+
+func TestSqsEndpoint(t *testing.T) {
+	config := rulego.NewConfig(types.WithDefaultPool())
+	_, err := rulego.New("sqsTest", []byte(chainJson), rulego.WithConfig(config))
+	assert.Nil(t, err)
+	defer rulego.Del("sqsTest")
+
+	server, acked := newSqsTestServer(t, `
+<Message><MessageId>m1</MessageId><ReceiptHandle>h1</ReceiptHandle><Body>{"temperature":41}</Body></Message>
+<Message><MessageId>m2</MessageId><ReceiptHandle>h2</ReceiptHandle><Body>{"humidity":80}</Body></Message>
+`)
+	defer server.Close()
+
+	sqsEndpoint := &Sqs{
+		RuleConfig: config,
+		Config:     Config{Endpoint: server.URL, AccessKeyId: "ak", SecretAccessKey: "sk", MaxNumberOfMessages: 10, WaitTimeSeconds: 1},
+	}
+	queueUrl := server.URL + "/123456789012/rulego"
+	router := endpoint.NewRouter(endpoint.WithRuleConfig(config)).From(queueUrl).Transform(func(router *endpoint.Router, exchange *endpoint.Exchange) bool {
+		assert.Equal(t, queueUrl, exchange.In.GetMsg().Metadata.GetValue("queueUrl"))
+		return true
+	}).To("chain:sqsTest").End()
+	sqsEndpoint.AddRouter(router)
+	err = sqsEndpoint.Start()
+	assert.Nil(t, err)
+	defer sqsEndpoint.Destroy()
+
+	results := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case result := <-acked:
+			results[result] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("wait ack timeout")
+		}
+	}
+	assert.True(t, results["delete:h1"])
+	assert.True(t, results["nack:h2"])
+
+	err = sqsEndpoint.RemoveRouterWithParams(queueUrl)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(sqsEndpoint.cancels))
+}
+
+// slowFailureNode 延迟处理失败的分支
+type slowFailureNode struct{}
+
+func (x *slowFailureNode) Type() string {
+	return "test/slowFailure"
+}
+
+func (x *slowFailureNode) New() types.Node {
+	return x
+}
+
+func (x *slowFailureNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *slowFailureNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	completion := ctx.Async(msg, 0)
+	ctx.Config().GetClock().AfterFunc(100*time.Millisecond, func() {
+		completion.TellFailure(msg, errors.New("slow failure"))
+	})
+	return nil
+}
+
+func (x *slowFailureNode) Destroy() {
+}
+
+// 测试一个分支成功、另一个分支稍后失败时不删除消息
+func TestSqsEndpointBranches(t *testing.T) {
+	_ = rulego.Registry.Register(&slowFailureNode{})
+	defer rulego.Registry.Unregister("test/slowFailure")
+	config := rulego.NewConfig(types.WithDefaultPool())
+	_, err := rulego.NewChainBuilder().Id("sqsBranches").
+		Node("jsTransform", types.Configuration{"jsScript": "return {'msg':msg,'metadata':metadata,'msgType':msgType};"}).
+		On(types.Success).NodeWithId("success", "jsTransform", types.Configuration{"jsScript": "return {'msg':msg,'metadata':metadata,'msgType':msgType};"}).
+		From("s1").On(types.Success).NodeWithId("failure", "test/slowFailure", nil).New(rulego.WithConfig(config))
+	assert.Nil(t, err)
+	defer rulego.Del("sqsBranches")
+
+	server, acked := newSqsTestServer(t, `
+<Message><MessageId>m3</MessageId><ReceiptHandle>h3</ReceiptHandle><Body>{"temperature":41}</Body></Message>
+`)
+	defer server.Close()
+
+	sqsEndpoint := &Sqs{
+		RuleConfig: config,
+		Config:     Config{Endpoint: server.URL, AccessKeyId: "ak", SecretAccessKey: "sk", MaxNumberOfMessages: 10, WaitTimeSeconds: 1},
+	}
+	queueUrl := server.URL + "/123456789012/rulego"
+	sqsEndpoint.AddRouter(endpoint.NewRouter(endpoint.WithRuleConfig(config)).From(queueUrl).To("chain:sqsBranches").End())
+	assert.Nil(t, sqsEndpoint.Start())
+	defer sqsEndpoint.Destroy()
+
+	select {
+	case result := <-acked:
+		assert.Equal(t, "nack:h3", result)
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait ack timeout")
+	}
+	//只确认一次
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, len(acked))
+}
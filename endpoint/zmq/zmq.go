@@ -0,0 +1,336 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zmq
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/zmq"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/utils/maps"
+	"net/textproto"
+	"strings"
+)
+
+const (
+	//MatchAll 匹配所有主题的路由
+	MatchAll = "*"
+)
+
+// Config ZeroMQ 接收端点配置
+type Config struct {
+	//Server 地址，多个与逗号隔开，例如：tcp://*:5555、ipc:///tmp/rulego.ipc
+	Server string
+	//SocketType socket类型：PULL或者SUB
+	SocketType string
+	//Bind 是否绑定地址，否则连接地址
+	Bind bool
+}
+
+// RequestMessage ZeroMQ请求消息
+// 多帧消息第一帧为主题，最后一帧为消息内容；单帧消息主题为空
+type RequestMessage struct {
+	frames [][]byte
+	msg    *types.RuleMsg
+	err    error
+}
+
+func (r *RequestMessage) Body() []byte {
+	return r.frames[len(r.frames)-1]
+}
+
+func (r *RequestMessage) Headers() textproto.MIMEHeader {
+	header := make(map[string][]string)
+	header["topic"] = []string{r.From()}
+	return header
+}
+
+// From 消息主题
+func (r *RequestMessage) From() string {
+	if len(r.frames) > 1 {
+		return string(r.frames[0])
+	}
+	return ""
+}
+
+func (r *RequestMessage) GetParam(key string) string {
+	return ""
+}
+
+func (r *RequestMessage) SetMsg(msg *types.RuleMsg) {
+	r.msg = msg
+}
+
+func (r *RequestMessage) GetMsg() *types.RuleMsg {
+	if r.msg == nil {
+		ruleMsg := types.NewMsg(0, r.From(), types.JSON, types.NewMetadata(), string(r.Body()))
+		ruleMsg.Metadata.PutValue("topic", r.From())
+		r.msg = &ruleMsg
+	}
+	return r.msg
+}
+
+func (r *RequestMessage) SetStatusCode(statusCode int) {
+}
+
+func (r *RequestMessage) SetBody(body []byte) {
+}
+
+func (r *RequestMessage) SetError(err error) {
+	r.err = err
+}
+
+func (r *RequestMessage) GetError() error {
+	return r.err
+}
+
+// Frames 原始多帧消息
+func (r *RequestMessage) Frames() [][]byte {
+	return r.frames
+}
+
+// ResponseMessage ZeroMQ响应消息，PULL和SUB模式不支持响应
+type ResponseMessage struct {
+	topic   string
+	body    []byte
+	msg     *types.RuleMsg
+	headers textproto.MIMEHeader
+	err     error
+}
+
+func (r *ResponseMessage) Body() []byte {
+	return r.body
+}
+
+func (r *ResponseMessage) Headers() textproto.MIMEHeader {
+	if r.headers == nil {
+		r.headers = make(map[string][]string)
+	}
+	return r.headers
+}
+
+func (r *ResponseMessage) From() string {
+	return r.topic
+}
+
+func (r *ResponseMessage) GetParam(key string) string {
+	return ""
+}
+
+func (r *ResponseMessage) SetMsg(msg *types.RuleMsg) {
+	r.msg = msg
+}
+
+func (r *ResponseMessage) GetMsg() *types.RuleMsg {
+	return r.msg
+}
+
+func (r *ResponseMessage) SetStatusCode(statusCode int) {
+}
+
+func (r *ResponseMessage) SetBody(body []byte) {
+	r.body = body
+}
+
+func (r *ResponseMessage) SetError(err error) {
+	r.err = err
+}
+
+func (r *ResponseMessage) GetError() error {
+	return r.err
+}
+
+// Zmq ZeroMQ 接收端点，支持PULL和SUB模式
+// From为主题前缀，消息主题匹配前缀的路由会处理该消息，*匹配所有消息
+// SUB模式会按照路由From订阅主题
+type Zmq struct {
+	endpoint.BaseEndpoint
+	RuleConfig types.Config
+	Config     Config
+	socket     *zmq.Socket
+}
+
+// Type 组件类型
+func (z *Zmq) Type() string {
+	return "zmq"
+}
+
+func (z *Zmq) New() types.Node {
+	return &Zmq{Config: Config{SocketType: zmq.Pull}}
+}
+
+// Init 初始化
+func (z *Zmq) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	err := maps.Map2Struct(configuration, &z.Config)
+	z.RuleConfig = ruleConfig
+	return err
+}
+
+// Destroy 销毁
+func (z *Zmq) Destroy() {
+	_ = z.Close()
+}
+
+func (z *Zmq) Close() error {
+	if z.socket != nil {
+		return z.socket.Close()
+	}
+	return nil
+}
+
+func (z *Zmq) Id() string {
+	return z.Config.Server
+}
+
+func (z *Zmq) AddRouterWithParams(router *endpoint.Router, params ...interface{}) error {
+	z.AddRouter(router)
+	return nil
+}
+
+func (z *Zmq) RemoveRouterWithParams(from string, params ...interface{}) error {
+	z.Lock()
+	if z.RouterStorage != nil {
+		delete(z.RouterStorage, from)
+	}
+	z.Unlock()
+	if z.socket != nil && z.socket.Type() == zmq.Sub {
+		z.socket.Unsubscribe(subscription(from))
+	}
+	return nil
+}
+
+// AddRouter 添加路由
+func (z *Zmq) AddRouter(routers ...*endpoint.Router) *Zmq {
+	z.Lock()
+	if z.RouterStorage == nil {
+		z.RouterStorage = make(map[string]*endpoint.Router)
+	}
+	for _, router := range routers {
+		z.RouterStorage[router.FromToString()] = router
+	}
+	z.Unlock()
+	//服务已经启动
+	if z.socket != nil && z.socket.Type() == zmq.Sub {
+		for _, router := range routers {
+			z.socket.Subscribe(subscription(router.FromToString()))
+		}
+	}
+	return z
+}
+
+// Start 启动
+func (z *Zmq) Start() error {
+	if z.socket != nil {
+		return nil
+	}
+	socketType := strings.ToUpper(z.Config.SocketType)
+	if socketType != zmq.Pull && socketType != zmq.Sub {
+		return errors.New("socketType must be PULL or SUB")
+	}
+	socket, err := zmq.NewSocket(socketType)
+	if err != nil {
+		return err
+	}
+	if socketType == zmq.Sub {
+		z.RLock()
+		for from := range z.RouterStorage {
+			socket.Subscribe(subscription(from))
+		}
+		z.RUnlock()
+	}
+	for _, server := range strings.Split(z.Config.Server, ",") {
+		server = strings.TrimSpace(server)
+		if z.Config.Bind {
+			err = socket.Bind(server)
+		} else {
+			err = socket.Connect(server)
+		}
+		if err != nil {
+			_ = socket.Close()
+			return err
+		}
+	}
+	z.socket = socket
+	go z.receive(socket)
+	return nil
+}
+
+// Socket 底层socket，例如获取绑定的地址
+func (z *Zmq) Socket() *zmq.Socket {
+	return z.socket
+}
+
+func (z *Zmq) receive(socket *zmq.Socket) {
+	for {
+		frames, err := socket.Recv()
+		if err != nil {
+			return
+		}
+		if len(frames) == 0 {
+			continue
+		}
+		topic := ""
+		if len(frames) > 1 {
+			topic = string(frames[0])
+		}
+		for _, router := range z.matchRouters(topic) {
+			z.handler(router, frames)
+		}
+	}
+}
+
+// matchRouters 查找匹配主题前缀的路由
+func (z *Zmq) matchRouters(topic string) []*endpoint.Router {
+	z.RLock()
+	defer z.RUnlock()
+	var routers []*endpoint.Router
+	for from, router := range z.RouterStorage {
+		if from == MatchAll || strings.HasPrefix(topic, from) {
+			routers = append(routers, router)
+		}
+	}
+	return routers
+}
+
+func (z *Zmq) handler(router *endpoint.Router, frames [][]byte) {
+	defer func() {
+		//捕捉异常
+		if e := recover(); e != nil {
+			z.Printf("zmq handler err :%v", e)
+		}
+	}()
+	in := &RequestMessage{frames: frames}
+	exchange := &endpoint.Exchange{
+		In:  in,
+		Out: &ResponseMessage{topic: in.From()},
+	}
+	z.DoProcess(router, exchange)
+}
+
+func (z *Zmq) Printf(format string, v ...interface{}) {
+	if z.RuleConfig.Logger != nil {
+		z.RuleConfig.Logger.Printf(format, v...)
+	}
+}
+
+// subscription 路由From转换成订阅前缀
+func subscription(from string) string {
+	if from == MatchAll {
+		return ""
+	}
+	return from
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zmq
+
+import (
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/zmq"
+	"github.com/2018yuli/rulego/endpoint"
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+	"time"
+)
+
+func TestZmqEndpoint(t *testing.T) {
+	config := rulego.NewConfig(types.WithDefaultPool())
+	zmqEndpoint := (&Zmq{}).New().(*Zmq)
+	err := zmqEndpoint.Init(config, types.Configuration{
+		"server":     "tcp://127.0.0.1:0",
+		"socketType": "SUB",
+		"bind":       true,
+	})
+	assert.Nil(t, err)
+
+	received := make(chan *types.RuleMsg, 10)
+	router := endpoint.NewRouter().From("device/").Process(func(router *endpoint.Router, exchange *endpoint.Exchange) bool {
+		received <- exchange.In.GetMsg()
+		return true
+	}).End()
+	zmqEndpoint.AddRouter(router)
+	assert.Nil(t, zmqEndpoint.Start())
+	defer zmqEndpoint.Destroy()
+
+	pub, _ := zmq.NewSocket(zmq.Pub)
+	defer pub.Close()
+	assert.Nil(t, pub.Connect("tcp://"+zmqEndpoint.Socket().Addr().String()))
+
+	//订阅生效前的消息会被丢弃，循环发送直到收到
+	var msg *types.RuleMsg
+	deadline := time.Now().Add(5 * time.Second)
+	for msg == nil && time.Now().Before(deadline) {
+		_ = pub.Send([]byte("other/aa"), []byte("ignored"))
+		_ = pub.Send([]byte("device/aa"), []byte("{\"temperature\":41}"))
+		select {
+		case msg = <-received:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	assert.NotNil(t, msg)
+	assert.Equal(t, "device/aa", msg.Metadata.GetValue("topic"))
+	assert.Equal(t, "{\"temperature\":41}", msg.Data)
+}
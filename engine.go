@@ -17,10 +17,18 @@
 package rulego
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/audit"
+	"github.com/2018yuli/rulego/utils/profile"
+	"github.com/2018yuli/rulego/utils/quarantine"
+	"github.com/2018yuli/rulego/utils/quota"
+	"github.com/2018yuli/rulego/utils/state"
+	"runtime/debug"
+	"sync/atomic"
 	"time"
 )
 
@@ -42,6 +50,12 @@ type DefaultRuleContext struct {
 	onEnd func(msg types.RuleMsg, err error)
 	//用于不同组件共享信号量和数据的上下文
 	context context.Context
+	//profileStack 耗时分析的节点调用栈，没有配置Profiler则为空
+	profileStack []profile.Frame
+	//tracker 跟踪消息所有分支是否处理结束，规则链配置了顺序key或者设置了onAllNodeCompleted时使用，否则为空
+	tracker *msgTracker
+	//当前消息所有分支处理结束回调函数
+	onAllNodeCompleted func()
 }
 
 // NewRuleContext 创建一个默认规则引擎消息处理上下文实例
@@ -67,12 +81,14 @@ func (ctx *DefaultRuleContext) TellNext(msg types.RuleMsg, relationTypes ...stri
 	ctx.tell(msg, nil, relationTypes...)
 }
 func (ctx *DefaultRuleContext) TellSelf(msg types.RuleMsg, delayMs int64) {
-	time.AfterFunc(time.Millisecond*time.Duration(delayMs), func() {
+	ctx.tracker.acquire()
+	ctx.config.GetClock().AfterFunc(time.Millisecond*time.Duration(delayMs), func() {
+		defer ctx.tracker.release()
 		ctx.tell(msg, nil, types.Success)
 	})
 }
 func (ctx *DefaultRuleContext) NewMsg(msgType string, metaData types.Metadata, data string) types.RuleMsg {
-	return types.NewMsg(0, msgType, types.JSON, metaData, data)
+	return types.NewMsg(ctx.config.GetClock().Now().UnixMilli(), msgType, types.JSON, metaData, data)
 }
 func (ctx *DefaultRuleContext) GetSelfId() string {
 	return ctx.self.GetNodeId().Id
@@ -91,6 +107,10 @@ func (ctx *DefaultRuleContext) GetEndFunc() func(msg types.RuleMsg, err error) {
 	return ctx.onEnd
 }
 
+func (ctx *DefaultRuleContext) SetOnAllNodeCompleted(onAllNodeCompleted func()) {
+	ctx.onAllNodeCompleted = onAllNodeCompleted
+}
+
 func (ctx *DefaultRuleContext) SetContext(c context.Context) types.RuleContext {
 	ctx.context = c
 	return ctx
@@ -100,6 +120,69 @@ func (ctx *DefaultRuleContext) GetContext() context.Context {
 	return ctx.context
 }
 
+func (ctx *DefaultRuleContext) GetState(key string) (string, bool, error) {
+	return ctx.config.GetStateStore().Get(ctx.stateKey(key))
+}
+
+func (ctx *DefaultRuleContext) SetState(key string, value string) error {
+	return ctx.config.GetStateStore().Set(ctx.stateKey(key), value)
+}
+
+// stateKey 当前节点状态的key，作用域为规则链+节点
+func (ctx *DefaultRuleContext) stateKey(key string) string {
+	var chainId, nodeId string
+	if ctx.ruleChainCtx != nil {
+		chainId = ctx.ruleChainCtx.Id.Id
+	}
+	if ctx.self != nil {
+		nodeId = ctx.GetSelfId()
+	}
+	return state.Key(chainId, nodeId, key)
+}
+
+func (ctx *DefaultRuleContext) Async(msg types.RuleMsg, timeout time.Duration) types.AsyncCompletion {
+	var stats *chainStats
+	if ctx.ruleChainCtx != nil {
+		stats = ctx.ruleChainCtx.getStats()
+	}
+	ctx.tracker.acquire()
+	if stats != nil {
+		atomic.AddInt64(&stats.asyncPending, 1)
+	}
+	return types.NewAsyncCompletion(ctx, msg, timeout, func(timedOut bool) {
+		if stats != nil {
+			atomic.AddInt64(&stats.asyncPending, -1)
+			if timedOut {
+				atomic.AddUint64(&stats.asyncTimeouts, 1)
+			}
+		}
+		ctx.tracker.release()
+	})
+}
+
+func (ctx *DefaultRuleContext) Stream(msg types.RuleMsg, idleTimeout time.Duration) types.Stream {
+	var stats *chainStats
+	if ctx.ruleChainCtx != nil {
+		stats = ctx.ruleChainCtx.getStats()
+	}
+	ctx.tracker.acquire()
+	if stats == nil {
+		return types.NewStream(ctx, msg, idleTimeout, nil, func(timedOut bool) {
+			ctx.tracker.release()
+		})
+	}
+	atomic.AddInt64(&stats.streamsActive, 1)
+	return types.NewStream(ctx, msg, idleTimeout, func() {
+		atomic.AddUint64(&stats.streamChunks, 1)
+	}, func(timedOut bool) {
+		atomic.AddInt64(&stats.streamsActive, -1)
+		if timedOut {
+			atomic.AddUint64(&stats.asyncTimeouts, 1)
+		}
+		ctx.tracker.release()
+	})
+}
+
 func (ctx *DefaultRuleContext) SubmitTack(task func()) {
 	if ctx.pool != nil {
 		if err := ctx.pool.Submit(task); err != nil {
@@ -119,19 +202,23 @@ func (ctx *DefaultRuleContext) getNextNodes(relationType string) ([]types.NodeCt
 }
 
 func (ctx *DefaultRuleContext) onDebug(flowType string, nodeId string, msg types.RuleMsg, relationType string, err error) {
-	if ctx.config.OnDebug != nil {
+	if ctx.config.OnDebug != nil && ctx.config.Runtime.SampleDebug(msg.Id) {
 		ctx.config.OnDebug(flowType, nodeId, msg.Copy(), relationType, err)
 	}
 }
 
 func (ctx *DefaultRuleContext) tell(msg types.RuleMsg, err error, relationTypes ...string) {
+	msg = ctx.offload(msg)
 	msgCopy := msg.Copy()
 	if ctx.isFirst {
+		ctx.tracker.acquire()
 		ctx.SubmitTack(func() {
+			defer ctx.tracker.release()
 			ctx.tellNext(msgCopy, ctx.self)
 		})
 	} else {
 		for _, relationType := range relationTypes {
+			ctx.recordRelation(relationType)
 			if ctx.self != nil && ctx.self.IsDebugMode() {
 				//记录调试信息
 				ctx.SubmitTack(func() {
@@ -142,7 +229,9 @@ func (ctx *DefaultRuleContext) tell(msg types.RuleMsg, err error, relationTypes
 			if nodes, ok := ctx.getNextNodes(relationType); ok {
 				for _, item := range nodes {
 					tmp := item
+					ctx.tracker.acquire()
 					ctx.SubmitTack(func() {
+						defer ctx.tracker.release()
 						ctx.tellNext(msg.Copy(), tmp)
 					})
 				}
@@ -156,22 +245,66 @@ func (ctx *DefaultRuleContext) tell(msg types.RuleMsg, err error, relationTypes
 
 func (ctx *DefaultRuleContext) tellNext(msg types.RuleMsg, nextNode types.NodeCtx) {
 	nextCtx := NewRuleContext(ctx.config, ctx.ruleChainCtx, ctx.self, nextNode, ctx.pool, ctx.onEnd, ctx.GetContext())
+	nextCtx.tracker = ctx.tracker
 	defer func() {
 		//捕捉异常
 		if e := recover(); e != nil {
-			if nextCtx.self != nil && nextCtx.self.IsDebugMode() {
-				//记录异常信息
-				ctx.onDebug(types.In, nextCtx.GetSelfId(), msg, "", fmt.Errorf("%v", e))
-			}
+			nextCtx.onPanic(msg, e)
 		}
 	}()
 	if nextCtx.self != nil && nextCtx.self.IsDebugMode() {
 		//记录调试信息
 		ctx.onDebug(types.In, nextCtx.GetSelfId(), msg, "", nil)
 	}
+	var start time.Time
+	if ctx.config.Quota != nil || ctx.config.Profiler != nil {
+		start = ctx.config.GetClock().Now()
+	}
+	if ctx.config.Profiler != nil {
+		nextCtx.profileStack = ctx.pushProfileFrame(nextNode)
+	}
 	if err := nextNode.OnMsg(nextCtx, msg); err != nil {
 		ctx.config.Logger.Printf("tellNext error.node type:%s error: %s", nextCtx.self.Type(), err)
 	}
+	if ctx.config.Quota != nil && ctx.ruleChainCtx != nil {
+		//统计节点执行时间和外部调用次数
+		ctx.config.Quota.AddNodeTime(ctx.ruleChainCtx.Id.Id, nextNode.Type(), ctx.config.GetClock().Now().Sub(start))
+	}
+	if ctx.config.Profiler != nil {
+		ctx.config.Profiler.Record(nextCtx.profileStack, ctx.config.GetClock().Now().Sub(start))
+	}
+}
+
+// onPanic 处理当前节点panic，记录堆栈信息到元数据，然后把消息发送到`Failure`链或者`Config.OnPanic`
+func (ctx *DefaultRuleContext) onPanic(msg types.RuleMsg, value interface{}) {
+	if ctx.self == nil {
+		ctx.config.Logger.Printf("tellNext panic: %v", value)
+		return
+	}
+	err := &types.PanicError{
+		NodeId:   ctx.GetSelfId(),
+		NodeType: ctx.self.Type(),
+		Value:    value,
+		Stack:    string(debug.Stack()),
+	}
+	if ctx.ruleChainCtx != nil {
+		ctx.ruleChainCtx.addPanic()
+	}
+	ctx.config.Logger.Printf("%s\n%s", err, err.Stack)
+	msg = msg.Copy()
+	msg.Metadata.PutValue(types.PanicNodeKey, err.NodeId)
+	msg.Metadata.PutValue(types.PanicErrorKey, fmt.Sprintf("%v", value))
+	msg.Metadata.PutValue(types.PanicStackKey, err.Stack)
+	if ctx.self.IsDebugMode() {
+		//记录异常信息
+		ctx.onDebug(types.In, err.NodeId, msg, "", err)
+	}
+	if ctx.config.OnPanic != nil {
+		ctx.config.OnPanic(msg, err)
+		ctx.doOnEnd(msg, err)
+	} else {
+		ctx.TellFailure(msg, err)
+	}
 }
 
 // 规则链执行完成回调函数
@@ -179,14 +312,18 @@ func (ctx *DefaultRuleContext) doOnEnd(msg types.RuleMsg, err error) {
 	//全局回调
 	//通过`Config.OnEnd`设置
 	if ctx.config.OnEnd != nil {
+		ctx.tracker.acquire()
 		ctx.SubmitTack(func() {
+			defer ctx.tracker.release()
 			ctx.config.OnEnd(msg, err)
 		})
 	}
 	//单条消息的context回调
 	//通过OnMsgWithEndFunc(msg, endFunc)设置
 	if ctx.onEnd != nil {
+		ctx.tracker.acquire()
 		ctx.SubmitTack(func() {
+			defer ctx.tracker.release()
 			ctx.onEnd(msg, err)
 		})
 	}
@@ -203,6 +340,14 @@ type RuleEngine struct {
 	rootRuleChainCtx *RuleChainCtx
 	//子规则链
 	subRuleChains map[string][]byte
+	//actor 审计记录的操作人，通过WithActor设置
+	actor string
+	//shadow 影子流量配置，通过WithShadow设置
+	shadow *ShadowConfig
+	//gate 重新加载期间暂存输入的消息
+	gate reloadGate
+	//metricsStop 关闭后停止定期回调节点运行指标
+	metricsStop chan struct{}
 }
 
 // RuleEngineOption is a function type that modifies the RuleEngine.
@@ -225,7 +370,7 @@ func newRuleEngine(id string, def []byte, opts ...RuleEngineOption) (*RuleEngine
 			//使用规则链ID
 			ruleEngine.Id = ruleEngine.rootRuleChainCtx.Id.Id
 		}
-
+		ruleEngine.audit(audit.ActionCreate, "", nil, ruleEngine.auditDSL(), nil)
 	}
 
 	return ruleEngine, err
@@ -233,30 +378,84 @@ func newRuleEngine(id string, def []byte, opts ...RuleEngineOption) (*RuleEngine
 
 // ReloadSelf 重新加载规则链
 func (e *RuleEngine) ReloadSelf(def []byte, opts ...RuleEngineOption) error {
+	//多次重新加载串行执行
+	e.gate.serial.Lock()
+	defer e.gate.serial.Unlock()
 	// Apply the options to the RuleEngine.
 	for _, opt := range opts {
 		_ = opt(e)
 	}
-	//初始化
-	if ctx, err := e.Config.Parser.DecodeRuleChain(e.Config, def); err == nil {
-		if e.Initialized() {
-			e.Stop()
-		}
-		if e.rootRuleChainCtx != nil {
-			ctx.(*RuleChainCtx).Id = e.rootRuleChainCtx.Id
+	e.resolveLogger(def)
+	before := e.auditDSL()
+	err := e.reloadSelf(def)
+	if before != nil {
+		after := e.auditDSL()
+		action := audit.ActionUpdate
+		if err == nil && bytes.Equal(before, after) {
+			action = audit.ActionReload
 		}
-		e.rootRuleChainCtx = ctx.(*RuleChainCtx)
-		//初始化子规则链
-		for key, value := range e.subRuleChains {
-			err := e.ReloadChild(types.EmptyRuleNodeId, types.RuleNodeId{Id: key, Type: types.CHAIN}, value)
-			if err != nil {
-				return err
-			}
+		e.audit(action, "", before, after, err)
+	}
+	return err
+}
+
+// resolveLogger 配置了LoggerFactory则按规则链ID和租户创建该规则链的日志记录器，替换Config.Logger
+// 解析后清空LoggerFactory，重新加载时不重复创建，通过WithConfig更换配置后重新解析
+func (e *RuleEngine) resolveLogger(def []byte) {
+	factory := e.Config.LoggerFactory
+	if factory == nil {
+		return
+	}
+	e.Config.LoggerFactory = nil
+	chainId := e.Id
+	if chainId == "" {
+		if chain, err := ParserRuleChain(def); err == nil {
+			chainId = chain.RuleChain.ID
 		}
-		return nil
-	} else {
+	}
+	logger := factory(types.LogScope{ChainId: chainId, Tenant: e.Config.Tenant})
+	if logger == nil {
+		return
+	}
+	if e.Config.Runtime != nil {
+		//保留运行时日志级别
+		logger = e.Config.Runtime.Logger(logger)
+	}
+	e.Config.Logger = logger
+}
+
+// reloadSelf 重新加载规则链，不记录审计
+// 新规则链及其子规则链全部初始化完成后再切换，期间输入的消息暂存，切换后交给新规则链处理
+// 加载失败保留原规则链
+func (e *RuleEngine) reloadSelf(def []byte) error {
+	e.gate.begin()
+	ctx, err := e.Config.Parser.DecodeRuleChain(e.Config, def)
+	if err != nil {
+		e.gate.end(func() {})
 		return err
 	}
+	newCtx := ctx.(*RuleChainCtx)
+	if e.rootRuleChainCtx != nil {
+		newCtx.Id = e.rootRuleChainCtx.Id
+	}
+	//初始化子规则链
+	for key, value := range e.subRuleChains {
+		if err := newCtx.ReloadChild(types.RuleNodeId{Id: key, Type: types.CHAIN}, value); err != nil {
+			newCtx.Destroy()
+			e.gate.end(func() {})
+			return err
+		}
+	}
+	var oldCtx *RuleChainCtx
+	e.gate.end(func() {
+		oldCtx = e.rootRuleChainCtx
+		e.rootRuleChainCtx = newCtx
+	})
+	if oldCtx != nil {
+		oldCtx.Destroy()
+	}
+	e.startMetrics()
+	return nil
 }
 
 // ReloadChild 更新节点,包括根规则链下子节点、子规则链、子规则链下的子节点
@@ -271,7 +470,21 @@ func (e *RuleEngine) ReloadChild(chainId types.RuleNodeId, ruleNodeId types.Rule
 	} else if chainId.Id == "" && ruleNodeId.Id == "" {
 		//更新根规则链
 		return e.ReloadSelf(dsl)
-	} else if chainId.Id == "" && ruleNodeId.Id != "" {
+	}
+	before := e.auditNodeDSL(chainId, ruleNodeId)
+	err := e.reloadChild(chainId, ruleNodeId, dsl)
+	after := e.auditNodeDSL(chainId, ruleNodeId)
+	action := audit.ActionUpdate
+	if err == nil && bytes.Equal(before, after) {
+		action = audit.ActionReload
+	}
+	e.audit(action, ruleNodeId.Id, before, after, err)
+	return err
+}
+
+// reloadChild 更新子节点或者子规则链，不记录审计
+func (e *RuleEngine) reloadChild(chainId types.RuleNodeId, ruleNodeId types.RuleNodeId, dsl []byte) error {
+	if chainId.Id == "" && ruleNodeId.Id != "" {
 		//更新根规则链子节点
 		return e.rootRuleChainCtx.ReloadChild(ruleNodeId, dsl)
 	} else if chainId.Id != "" && ruleNodeId.Id != "" {
@@ -292,20 +505,22 @@ func (e *RuleEngine) DSL() []byte {
 }
 
 func (e *RuleEngine) NodeDSL(chainId types.RuleNodeId, childNodeId types.RuleNodeId) []byte {
+	if node, ok := e.nodeCtx(chainId, childNodeId); ok {
+		return node.DSL()
+	}
+	return nil
+}
+
+// nodeCtx 获取根规则链或者子规则链中的节点
+func (e *RuleEngine) nodeCtx(chainId types.RuleNodeId, childNodeId types.RuleNodeId) (types.NodeCtx, bool) {
 	if e.rootRuleChainCtx != nil {
 		if chainId.Id == "" {
-			if node, ok := e.rootRuleChainCtx.GetNodeById(childNodeId); ok {
-				return node.DSL()
-			}
-		} else {
-			if node, ok := e.rootRuleChainCtx.GetNodeById(chainId); ok {
-				if childNode, ok := node.GetNodeById(childNodeId); ok {
-					return childNode.DSL()
-				}
-			}
+			return e.rootRuleChainCtx.GetNodeById(childNodeId)
+		} else if node, ok := e.rootRuleChainCtx.GetNodeById(chainId); ok {
+			return node.GetNodeById(childNodeId)
 		}
 	}
-	return nil
+	return nil, false
 }
 
 func (e *RuleEngine) Initialized() bool {
@@ -318,6 +533,7 @@ func (e *RuleEngine) RootRuleChainCtx() *RuleChainCtx {
 }
 
 func (e *RuleEngine) Stop() {
+	e.stopMetrics()
 	if e.rootRuleChainCtx != nil {
 		e.rootRuleChainCtx.Destroy()
 		e.rootRuleChainCtx = nil
@@ -340,21 +556,138 @@ func (e *RuleEngine) OnMsgWithEndFunc(msg types.RuleMsg, endFunc func(msg types.
 // 可以携带context选项和结束回调选项
 // context 用于不同组件实例数据共享
 // endFunc 用于数据经过规则链执行完的回调，用于获取规则链处理结果数据。注意：如果规则链有多个结束点，回调函数则会执行多次
+// onAllNodeCompleted 通过types.WithOnAllNodeCompleted设置，消息所有分支处理结束后回调一次
 func (e *RuleEngine) OnMsgWithOptions(msg types.RuleMsg, opts ...types.RuleContextOption) {
-	if e.rootRuleChainCtx != nil {
-		rootCtx := e.rootRuleChainCtx.rootRuleContext.(*DefaultRuleContext)
+	var rootRuleChainCtx *RuleChainCtx
+	held, err := e.gate.hold(e.Config.ReloadBufferSize, func() {
+		e.OnMsgWithOptions(msg, opts...)
+	}, func() {
+		rootRuleChainCtx = e.rootRuleChainCtx
+	})
+	if held {
+		if err != nil {
+			//暂存的消息太多，拒绝消息
+			ctx := NewRuleContext(e.Config, nil, nil, nil, e.Config.Pool, nil, context.Background())
+			for _, opt := range opts {
+				opt(ctx)
+			}
+			ctx.tracker = newMsgTracker(ctx.onAllNodeCompleted)
+			ctx.doOnEnd(msg, err)
+			ctx.tracker.release()
+		}
+		return
+	}
+	if rootRuleChainCtx != nil {
+		rootCtx := rootRuleChainCtx.rootRuleContext.(*DefaultRuleContext)
 		rootCtxCopy := NewRuleContext(rootCtx.config, rootCtx.ruleChainCtx, rootCtx.from, rootCtx.self, rootCtx.pool, rootCtx.onEnd, rootCtx.GetContext())
 		rootCtxCopy.isFirst = rootCtx.isFirst
 		for _, opt := range opts {
 			opt(rootCtxCopy)
 		}
-		rootCtxCopy.TellNext(msg)
+		onAllNodeCompleted := rootCtxCopy.onAllNodeCompleted
+		rootCtxCopy.tracker = newMsgTracker(onAllNodeCompleted)
+		//大消息内容转存到BlobStore，排队和处理过程中只保留引用
+		if msg, err = limitSize(rootCtx.config, msg); err != nil {
+			rootCtxCopy.doOnEnd(msg, err)
+			rootCtxCopy.tracker.release()
+			return
+		}
+		if q := rootCtx.config.Quarantine; q != nil {
+			if q.Quarantined(e.Id, msg.Id) {
+				//毒消息，不再执行
+				rootCtxCopy.doOnEnd(msg, quarantine.ErrQuarantined)
+				rootCtxCopy.tracker.release()
+				return
+			}
+			rootCtxCopy.onEnd = e.quarantineEndFunc(q, msg, rootCtxCopy.onEnd)
+		}
+		if e.shadow != nil {
+			//按比例复制到候选规则链
+			rootCtxCopy.onEnd = e.shadow.mirror(rootCtx.config, msg, rootCtxCopy.onEnd)
+		}
+		if key, ok := rootCtx.ruleChainCtx.orderingKey(msg); ok {
+			//相同key的消息上一条消息所有分支处理结束后再处理
+			rootCtx.ruleChainCtx.ordering.Submit(key, func(done func()) {
+				rootCtxCopy.tracker = newMsgTracker(func() {
+					done()
+					if onAllNodeCompleted != nil {
+						onAllNodeCompleted()
+					}
+				})
+				e.tellFirst(rootCtxCopy, msg)
+				rootCtxCopy.tracker.release()
+			})
+		} else {
+			e.tellFirst(rootCtxCopy, msg)
+			rootCtxCopy.tracker.release()
+		}
 	} else {
 		//沒有定义根则链或者没初始化
 		e.Config.Logger.Printf("onMsg error.RuleEngine not initialized")
 	}
 }
 
+// tellFirst 检查配额后把消息交给规则链第一个节点处理
+func (e *RuleEngine) tellFirst(ctx *DefaultRuleContext, msg types.RuleMsg) {
+	if ctx.config.Quota != nil {
+		switch decision, delay := ctx.config.Quota.Acquire(e.Id); decision {
+		case quota.Reject:
+			//超过硬限制，拒绝消息
+			ctx.doOnEnd(msg, quota.ErrQuotaExceeded)
+			return
+		case quota.Throttle:
+			//超过软限制，延迟执行
+			ctx.tracker.acquire()
+			ctx.config.GetClock().AfterFunc(delay, func() {
+				defer ctx.tracker.release()
+				ctx.TellNext(msg)
+			})
+			return
+		}
+	}
+	ctx.TellNext(msg)
+}
+
+// HealthCheck 检查规则引擎是否已经初始化，以及规则链所有节点的健康状态
+func (e *RuleEngine) HealthCheck() error {
+	rootRuleChainCtx := e.rootRuleChainCtx
+	if rootRuleChainCtx == nil {
+		return errors.New("RuleEngine not initialized")
+	}
+	return rootRuleChainCtx.HealthCheck()
+}
+
+// Health 获取规则链中实现了`types.HealthChecker`的节点健康状态
+func (e *RuleEngine) Health() []NodeHealth {
+	rootRuleChainCtx := e.rootRuleChainCtx
+	if rootRuleChainCtx == nil {
+		return nil
+	}
+	return rootRuleChainCtx.Health()
+}
+
+// PanicCount 获取规则链和子规则链节点处理消息发生panic的次数
+func (e *RuleEngine) PanicCount() uint64 {
+	return e.Stats().Panics
+}
+
+// Stats 获取规则链和子规则链的运行统计，重新加载根规则链后重新计数
+func (e *RuleEngine) Stats() ChainStats {
+	rootRuleChainCtx := e.rootRuleChainCtx
+	if rootRuleChainCtx == nil {
+		return ChainStats{}
+	}
+	return rootRuleChainCtx.Stats()
+}
+
+// Usage 获取规则链当前统计周期的用量，没有配置`types.Config.Quota`则返回false
+func (e *RuleEngine) Usage() (quota.Usage, bool) {
+	if e.Config.Quota == nil {
+		return quota.Usage{}, false
+	}
+	return e.Config.Quota.Usage(e.Id), true
+}
+
 // NewConfig creates a new Config and applies the options.
 func NewConfig(opts ...types.Option) types.Config {
 	c := types.NewConfig(opts...)
@@ -364,6 +697,9 @@ func NewConfig(opts ...types.Option) types.Config {
 	if c.ComponentsRegistry == nil {
 		c.ComponentsRegistry = Registry
 	}
+	if c.ChainExecutor == nil {
+		c.ChainExecutor = DefaultRuleGo.Execute
+	}
 	return c
 }
 
@@ -375,6 +711,14 @@ func WithConfig(config types.Config) RuleEngineOption {
 	}
 }
 
+// WithActor 设置审计记录的操作人，之后的创建、更新和删除操作使用该操作人
+func WithActor(actor string) RuleEngineOption {
+	return func(re *RuleEngine) error {
+		re.actor = actor
+		return nil
+	}
+}
+
 // WithAddSubChain 添加子规则链选项
 func WithAddSubChain(subChainId string, subChain []byte) RuleEngineOption {
 	return func(re *RuleEngine) error {
@@ -385,3 +729,52 @@ func WithAddSubChain(subChainId string, subChain []byte) RuleEngineOption {
 		return nil
 	}
 }
+
+// audit 记录规则链配置变更，没有配置AuditSink则忽略
+func (e *RuleEngine) audit(action string, nodeId string, before, after []byte, err error) {
+	if e.Config.AuditSink == nil {
+		return
+	}
+	event := audit.Event{
+		Time:    e.Config.GetClock().Now(),
+		Actor:   e.actor,
+		Action:  action,
+		ChainId: e.Id,
+		NodeId:  nodeId,
+		Tenant:  e.Config.Tenant,
+		Diff:    audit.Diff(string(before), string(after)),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if recordErr := e.Config.AuditSink.Record(event); recordErr != nil && e.Config.Logger != nil {
+		e.Config.Logger.Printf("record audit event error: %s", recordErr)
+	}
+}
+
+// auditDSL 敏感字段替换成******后的规则链DSL，用于审计记录
+func (e *RuleEngine) auditDSL() []byte {
+	if e.rootRuleChainCtx == nil {
+		return nil
+	}
+	v, _ := e.Config.Parser.EncodeRuleChain(maskRuleChain(e.Config, e.rootRuleChainCtx.SelfDefinition))
+	return v
+}
+
+// auditNodeDSL 敏感字段替换成******后的节点或者子规则链DSL，用于审计记录
+func (e *RuleEngine) auditNodeDSL(chainId types.RuleNodeId, childNodeId types.RuleNodeId) []byte {
+	node, ok := e.nodeCtx(chainId, childNodeId)
+	if !ok {
+		return nil
+	}
+	var v []byte
+	switch item := node.(type) {
+	case *RuleNodeCtx:
+		v, _ = e.Config.Parser.EncodeRuleNode(maskRuleNode(item.Config, item.SelfDefinition))
+	case *RuleChainCtx:
+		v, _ = e.Config.Parser.EncodeRuleChain(maskRuleChain(item.Config, item.SelfDefinition))
+	default:
+		v = node.DSL()
+	}
+	return v
+}
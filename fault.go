@@ -0,0 +1,86 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"math/rand"
+	"time"
+)
+
+// faultNode 故障注入节点，包装原节点，按照`types.FaultConfig`注入错误、延迟或者丢弃消息
+type faultNode struct {
+	types.Node
+	fault  types.FaultConfig
+	config types.Config
+}
+
+func newFaultNode(config types.Config, node types.Node, fault types.FaultConfig) *faultNode {
+	if fault.Error == nil {
+		fault.Error = types.ErrFaultInjected
+	}
+	if fault.Rand == nil {
+		fault.Rand = rand.Float64
+	}
+	return &faultNode{Node: node, fault: fault, config: config}
+}
+
+// OnMsg 按概率丢弃消息或者发送到`Failure`链，否则延迟后交给原节点处理
+func (x *faultNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	if x.hit(x.fault.DropRate) {
+		return nil
+	}
+	if x.hit(x.fault.ErrorRate) {
+		ctx.TellFailure(msg, x.fault.Error)
+		return x.fault.Error
+	}
+	delay := x.latency()
+	if delay <= 0 {
+		return x.Node.OnMsg(ctx, msg)
+	}
+	x.config.GetClock().AfterFunc(delay, func() {
+		if err := x.Node.OnMsg(ctx, msg); err != nil && x.config.Logger != nil {
+			types.Logf(x.config.Logger, types.LogLevelError, "tellNext error.node type:%s error: %s", x.Type(), err)
+		}
+	})
+	return nil
+}
+
+// HealthCheck 检查原节点健康状态
+func (x *faultNode) HealthCheck() error {
+	if checker, ok := x.Node.(types.HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// Metrics 原节点运行指标
+func (x *faultNode) Metrics() map[string]interface{} {
+	return nodeMetrics(x.Node)
+}
+
+func (x *faultNode) hit(rate float64) bool {
+	return rate > 0 && x.fault.Rand() < rate
+}
+
+func (x *faultNode) latency() time.Duration {
+	delay := x.fault.Latency
+	if x.fault.LatencyJitter > 0 {
+		delay += time.Duration(x.fault.Rand() * float64(x.fault.LatencyJitter))
+	}
+	return delay
+}
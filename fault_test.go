@@ -0,0 +1,112 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFaultInjectError(t *testing.T) {
+	config := NewConfig(types.WithFault("jsFilter", types.FaultConfig{ErrorRate: 1}))
+	ruleEngine, err := NewChainBuilder().Id("faultError").
+		Node("jsFilter", types.Configuration{"jsScript": "return true;"}).
+		New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("faultError")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+		assert.Equal(t, types.ErrFaultInjected, err)
+		wg.Done()
+	})
+	waitTimeout(t, &wg, time.Second*3)
+}
+
+func TestFaultInjectLatency(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	var rate float64
+	node := newFaultNode(NewConfig(types.WithClock(vc)), &faultTestNode{}, types.FaultConfig{
+		Latency:       time.Second,
+		LatencyJitter: time.Second,
+		Rand:          func() float64 { return rate },
+	})
+	var relations []string
+	ctx := test.NewRuleContext(NewConfig(), func(msg types.RuleMsg, relationType string) {
+		relations = append(relations, relationType)
+	})
+	rate = 0.5
+	assert.Nil(t, node.OnMsg(ctx, types.RuleMsg{}))
+	vc.Advance(time.Millisecond * 1499)
+	assert.Equal(t, 0, len(relations))
+	vc.Advance(time.Millisecond)
+	assert.Equal(t, []string{types.Success}, relations)
+}
+
+func TestFaultInjectDrop(t *testing.T) {
+	var rate float64
+	node := newFaultNode(NewConfig(), &faultTestNode{}, types.FaultConfig{
+		DropRate:  0.3,
+		ErrorRate: 0.6,
+		Rand:      func() float64 { return rate },
+	})
+	var relations []string
+	ctx := test.NewRuleContext(NewConfig(), func(msg types.RuleMsg, relationType string) {
+		relations = append(relations, relationType)
+	})
+	rate = 0.1
+	assert.Nil(t, node.OnMsg(ctx, types.RuleMsg{}))
+	assert.Equal(t, 0, len(relations))
+
+	rate = 0.5
+	assert.Equal(t, types.ErrFaultInjected, node.OnMsg(ctx, types.RuleMsg{}))
+	assert.Equal(t, []string{types.Failure}, relations)
+
+	rate = 0.9
+	assert.Nil(t, node.OnMsg(ctx, types.RuleMsg{}))
+	assert.Equal(t, []string{types.Failure, types.Success}, relations)
+	assert.Equal(t, "faultTest", node.Type())
+}
+
+type faultTestNode struct {
+}
+
+func (x *faultTestNode) Type() string {
+	return "faultTest"
+}
+
+func (x *faultTestNode) New() types.Node {
+	return &faultTestNode{}
+}
+
+func (x *faultTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *faultTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *faultTestNode) Destroy() {
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+)
+
+// healthTestNode 测试健康检查的节点，configuration.error不为空则不可用
+type healthTestNode struct {
+	err error
+}
+
+func (x *healthTestNode) Type() string {
+	return "test/healthCheck"
+}
+
+func (x *healthTestNode) New() types.Node {
+	return &healthTestNode{}
+}
+
+func (x *healthTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if v, ok := configuration["error"].(string); ok && v != "" {
+		x.err = errors.New(v)
+	}
+	return nil
+}
+
+func (x *healthTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *healthTestNode) Destroy() {
+}
+
+func (x *healthTestNode) HealthCheck() error {
+	return x.err
+}
+
+func TestRuleEngineHealth(t *testing.T) {
+	_ = Registry.Register(&healthTestNode{})
+	defer Registry.Unregister("test/healthCheck")
+	ruleGo := &RuleGo{}
+	defer ruleGo.Stop()
+
+	def, err := NewChainBuilder().Id("health01").
+		Node("test/healthCheck", nil).
+		Node("jsFilter", types.Configuration{"jsScript": "return true;"}).
+		Node("test/healthCheck", types.Configuration{"error": "broker disconnected"}).
+		DSL()
+	assert.Nil(t, err)
+	ruleEngine, err := ruleGo.New("health01", def)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []NodeHealth{
+		{ChainId: "health01", NodeId: "s1", Type: "test/healthCheck", Healthy: true},
+		{ChainId: "health01", NodeId: "s3", Type: "test/healthCheck", Error: "broker disconnected"},
+	}, ruleEngine.Health())
+	assert.Equal(t, "node s3: broker disconnected", ruleEngine.HealthCheck().Error())
+	assert.Equal(t, 1, len(ruleGo.Health()))
+
+	def, err = NewChainBuilder().Id("health02").Node("test/healthCheck", nil).DSL()
+	assert.Nil(t, err)
+	ruleEngine, err = ruleGo.New("health02", def)
+	assert.Nil(t, err)
+	assert.Nil(t, ruleEngine.HealthCheck())
+	assert.Equal(t, 2, len(ruleGo.Health()))
+
+	ruleEngine.Stop()
+	assert.NotNil(t, ruleEngine.HealthCheck())
+	assert.Equal(t, 0, len(ruleEngine.Health()))
+}
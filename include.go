@@ -0,0 +1,173 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// refKey 引用外部文件的key，例如：{"jsScript": {"$ref": "scripts/filter.js"}}
+const refKey = "$ref"
+
+// maxIncludeDepth 最大引用层数，防止json文件互相引用
+const maxIncludeDepth = 8
+
+// ErrIncludeNotConfigured 节点配置使用了$ref，但是没有配置IncludeFS
+var ErrIncludeNotConfigured = errors.New("$ref requires Config.IncludeFS")
+
+// includeConfiguration 加载节点配置中$ref引用的外部文件，返回新的配置，没有引用则返回原配置
+// 只有$ref的对象替换成文件内容；整个配置是$ref对象时，其他key覆盖文件中的同名配置
+func includeConfiguration(config types.Config, configuration types.Configuration) (types.Configuration, error) {
+	if !hasRef(map[string]interface{}(configuration)) {
+		return configuration, nil
+	}
+	if len(config.TrustedKeys) > 0 {
+		return nil, ErrUnsignedReference
+	}
+	if config.IncludeFS == nil {
+		return nil, ErrIncludeNotConfigured
+	}
+	value, err := include(config.IncludeFS, map[string]interface{}(configuration), 0)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("configuration $ref must be a json object")
+	}
+	return result, nil
+}
+
+// hasRef 是否包含$ref
+func hasRef(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, ok := v[refKey]; ok {
+			return true
+		}
+		for _, item := range v {
+			if hasRef(item) {
+				return true
+			}
+		}
+	case types.Configuration:
+		return hasRef(map[string]interface{}(v))
+	case []interface{}:
+		for _, item := range v {
+			if hasRef(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// include 递归替换$ref
+func include(fsys fs.FS, value interface{}, depth int) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if ref, ok := v[refKey]; ok {
+			return includeRef(fsys, v, ref, depth)
+		}
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			replaced, err := include(fsys, item, depth)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = replaced
+		}
+		return result, nil
+	case types.Configuration:
+		return include(fsys, map[string]interface{}(v), depth)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			replaced, err := include(fsys, item, depth)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = replaced
+		}
+		return result, nil
+	default:
+		return v, nil
+	}
+}
+
+// includeRef 加载引用的文件，json文件中的$ref继续展开
+func includeRef(fsys fs.FS, v map[string]interface{}, ref interface{}, depth int) (interface{}, error) {
+	name, ok := ref.(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("invalid $ref: %v", ref)
+	}
+	if depth >= maxIncludeDepth {
+		return nil, fmt.Errorf("$ref %s exceeds %d levels", name, maxIncludeDepth)
+	}
+	//fs.FS只接受/分隔的相对路径，不允许..跳出根目录
+	name = path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "./"))
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("load $ref %s: %w", name, err)
+	}
+	var value interface{} = string(content)
+	if strings.EqualFold(path.Ext(name), ".json") {
+		if err = json.Unmarshal(content, &value); err != nil {
+			return nil, fmt.Errorf("parse $ref %s: %w", name, err)
+		}
+		if value, err = include(fsys, value, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	if len(v) == 1 {
+		return value, nil
+	}
+	//其他key覆盖文件中的同名配置
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("$ref %s with sibling keys must be a json object", name)
+	}
+	for key, item := range v {
+		if key == refKey {
+			continue
+		}
+		replaced, err := include(fsys, item, depth)
+		if err != nil {
+			return nil, err
+		}
+		object[key] = replaced
+	}
+	return object, nil
+}
+
+// withIncludeDir 没有配置IncludeFS则使用dir作为$ref引用文件的根目录
+func withIncludeDir(dir string) RuleEngineOption {
+	return func(re *RuleEngine) error {
+		if re.Config.IncludeFS == nil {
+			re.Config.IncludeFS = os.DirFS(dir)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestIncludeConfiguration(t *testing.T) {
+	fsys := fstest.MapFS{
+		"scripts/filter.js": {Data: []byte("return msg.temperature > 50;")},
+		"rest.json":         {Data: []byte(`{"requestMethod": "POST", "headers": {"$ref": "headers.json"}, "readTimeoutMs": 2000}`)},
+		"headers.json":      {Data: []byte(`{"Content-Type": "application/json"}`)},
+		"list.json":         {Data: []byte(`[1, 2]`)},
+		"loop.json":         {Data: []byte(`{"next": {"$ref": "loop.json"}}`)},
+	}
+	config := types.NewConfig(types.WithIncludeFS(fsys))
+
+	//没有引用返回原配置
+	configuration := types.Configuration{"jsScript": "return true;"}
+	result, err := includeConfiguration(types.NewConfig(), configuration)
+	assert.Nil(t, err)
+	assert.Equal(t, configuration, result)
+
+	result, err = includeConfiguration(config, types.Configuration{"jsScript": map[string]interface{}{"$ref": "./scripts/filter.js"}})
+	assert.Nil(t, err)
+	assert.Equal(t, types.Configuration{"jsScript": "return msg.temperature > 50;"}, result)
+
+	//整个配置引用json文件，其他key覆盖文件中的配置
+	result, err = includeConfiguration(config, types.Configuration{"$ref": "rest.json", "readTimeoutMs": 5000})
+	assert.Nil(t, err)
+	assert.Equal(t, types.Configuration{
+		"requestMethod": "POST",
+		"headers":       map[string]interface{}{"Content-Type": "application/json"},
+		"readTimeoutMs": 5000,
+	}, result)
+
+	result, err = includeConfiguration(config, types.Configuration{"items": []interface{}{map[string]interface{}{"$ref": "list.json"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, types.Configuration{"items": []interface{}{[]interface{}{float64(1), float64(2)}}}, result)
+
+	_, err = includeConfiguration(types.NewConfig(), types.Configuration{"jsScript": map[string]interface{}{"$ref": "scripts/filter.js"}})
+	assert.True(t, errors.Is(err, ErrIncludeNotConfigured))
+	_, err = includeConfiguration(config, types.Configuration{"jsScript": map[string]interface{}{"$ref": "missing.js"}})
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	//不能跳出根目录
+	_, err = includeConfiguration(config, types.Configuration{"jsScript": map[string]interface{}{"$ref": "../secret.js"}})
+	assert.NotNil(t, err)
+	_, err = includeConfiguration(config, types.Configuration{"$ref": "loop.json"})
+	assert.True(t, err != nil && strings.Contains(err.Error(), "exceeds"))
+	_, err = includeConfiguration(config, types.Configuration{"$ref": "list.json", "x": 1})
+	assert.NotNil(t, err)
+}
+
+func TestLoadWithInclude(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "scripts"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "scripts", "transform.js"), []byte("metadata['included']='true';return {'msg':msg,'metadata':metadata,'msgType':msgType};"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "chain.json"), []byte(`
+	{
+	  "ruleChain": {"id": "include01"},
+	  "metadata": {
+		"nodes": [
+		  {"id": "s1", "type": "jsTransform", "configuration": {"jsScript": {"$ref": "scripts/transform.js"}}}
+		]
+	  }
+	}`), 0644))
+	ruleGo := &RuleGo{}
+	assert.Nil(t, ruleGo.Load(dir))
+	defer ruleGo.Stop()
+	ruleEngine, ok := ruleGo.Get("include01")
+	assert.True(t, ok)
+	//导出的DSL保留引用
+	assert.True(t, strings.Contains(string(ruleEngine.DSL()), "scripts/transform.js"))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var result types.RuleMsg
+	ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+		result = msg
+		wg.Done()
+	})
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Equal(t, "true", result.Metadata.GetValue("included"))
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import "sort"
+
+// 自动布局默认参数
+const (
+	defaultLayoutNodeWidth    = 200
+	defaultLayoutNodeHeight   = 60
+	defaultLayoutLayerSpacing = 80
+	defaultLayoutNodeSpacing  = 40
+)
+
+// LayoutOptions 自动布局参数，0表示使用默认值
+type LayoutOptions struct {
+	//NodeWidth 节点宽度，默认200
+	NodeWidth int
+	//NodeHeight 节点高度，默认60
+	NodeHeight int
+	//LayerSpacing 相邻层之间的水平间距，默认80
+	LayerSpacing int
+	//NodeSpacing 同一层节点之间的垂直间距，默认40
+	NodeSpacing int
+	//Overwrite 是否覆盖已经有位置的节点，默认只布局没有位置(layoutX和layoutY都为0)的节点
+	Overwrite bool
+}
+
+// AutoLayout 使用分层布局从左到右排列规则链节点，把位置和大小写入节点的additionalInfo
+// 第一个节点在最左侧，节点所在的层为从入口到该节点的最长路径，环路中的回边不参与分层
+// 用于通过代码创建的规则链，使可视化编辑器可以直接展示
+func AutoLayout(def *RuleChain, options LayoutOptions) {
+	nodes := def.Metadata.Nodes
+	if len(nodes) == 0 {
+		return
+	}
+	if options.NodeWidth <= 0 {
+		options.NodeWidth = defaultLayoutNodeWidth
+	}
+	if options.NodeHeight <= 0 {
+		options.NodeHeight = defaultLayoutNodeHeight
+	}
+	if options.LayerSpacing <= 0 {
+		options.LayerSpacing = defaultLayoutLayerSpacing
+	}
+	if options.NodeSpacing <= 0 {
+		options.NodeSpacing = defaultLayoutNodeSpacing
+	}
+
+	indexes := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		indexes[node.Id] = i
+	}
+	outgoing := make([][]int, len(nodes))
+	for _, item := range def.Metadata.Connections {
+		from, ok := indexes[item.FromId]
+		to, ok2 := indexes[item.ToId]
+		if ok && ok2 && from != to && !containsInt(outgoing[from], to) {
+			outgoing[from] = append(outgoing[from], to)
+		}
+	}
+
+	//深度优先遍历得到拓扑序，指向栈中节点的连接是回边
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make([]int, len(nodes))
+	incoming := make([][]int, len(nodes))
+	order := make([]int, 0, len(nodes))
+	var visit func(int)
+	visit = func(v int) {
+		states[v] = visiting
+		for _, to := range outgoing[v] {
+			switch states[to] {
+			case unvisited:
+				incoming[to] = append(incoming[to], v)
+				visit(to)
+			case visited:
+				incoming[to] = append(incoming[to], v)
+			}
+		}
+		states[v] = visited
+		order = append(order, v)
+	}
+	first := def.Metadata.FirstNodeIndex
+	if first < 0 || first >= len(nodes) {
+		first = 0
+	}
+	visit(first)
+	for i := range nodes {
+		if states[i] == unvisited {
+			visit(i)
+		}
+	}
+
+	//最长路径分层
+	layers := make([]int, len(nodes))
+	layerCount := 0
+	for i := len(order) - 1; i >= 0; i-- {
+		v := order[i]
+		for _, from := range incoming[v] {
+			if layers[from]+1 > layers[v] {
+				layers[v] = layers[from] + 1
+			}
+		}
+		if layers[v]+1 > layerCount {
+			layerCount = layers[v] + 1
+		}
+	}
+	rows := make([][]int, layerCount)
+	for i := range nodes {
+		rows[layers[i]] = append(rows[layers[i]], i)
+	}
+
+	//按上一层前驱节点的平均位置排序，减少连线交叉
+	positions := make([]float64, len(nodes))
+	for _, row := range rows {
+		for i, v := range row {
+			if len(incoming[v]) == 0 {
+				positions[v] = float64(i)
+				continue
+			}
+			sum := 0.0
+			for _, from := range incoming[v] {
+				sum += positions[from]
+			}
+			positions[v] = sum / float64(len(incoming[v]))
+		}
+		sort.SliceStable(row, func(i, j int) bool {
+			return positions[row[i]] < positions[row[j]]
+		})
+		for i, v := range row {
+			positions[v] = float64(i)
+		}
+	}
+
+	for layer, row := range rows {
+		for i, v := range row {
+			info := &nodes[v].AdditionalInfo
+			if !options.Overwrite && (info.LayoutX != 0 || info.LayoutY != 0) {
+				continue
+			}
+			info.LayoutX = options.LayerSpacing + layer*(options.NodeWidth+options.LayerSpacing)
+			info.LayoutY = options.NodeSpacing + i*(options.NodeHeight+options.NodeSpacing)
+			if options.Overwrite || info.Width == 0 {
+				info.Width = options.NodeWidth
+			}
+			if options.Overwrite || info.Height == 0 {
+				info.Height = options.NodeHeight
+			}
+		}
+	}
+}
+
+func containsInt(items []int, item int) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
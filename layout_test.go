@@ -0,0 +1,74 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+)
+
+func TestAutoLayout(t *testing.T) {
+	//s1->s2->s3, s1->s3, s1->s4
+	def, err := newTestChainBuilder().From("s1").On(types.Failure).Node("log", nil).Build()
+	assert.Nil(t, err)
+	layout := func(id string) NodeAdditionalInfo {
+		for _, node := range def.Metadata.Nodes {
+			if node.Id == id {
+				return node.AdditionalInfo
+			}
+		}
+		return NodeAdditionalInfo{}
+	}
+	//第一层
+	assert.Equal(t, NodeAdditionalInfo{LayoutX: 80, LayoutY: 40, Width: 200, Height: 60}, layout("s1"))
+	//第二层
+	assert.Equal(t, 360, layout("s2").LayoutX)
+	assert.Equal(t, 360, layout("s4").LayoutX)
+	assert.Equal(t, 40, layout("s2").LayoutY)
+	assert.Equal(t, 140, layout("s4").LayoutY)
+	//最长路径在第三层
+	assert.Equal(t, 640, layout("s3").LayoutX)
+
+	//已有位置的节点保持不变
+	def.Metadata.Nodes[0].AdditionalInfo = NodeAdditionalInfo{Description: "入口", LayoutX: 10, LayoutY: 20}
+	def.Metadata.Nodes[1].AdditionalInfo = NodeAdditionalInfo{}
+	AutoLayout(&def, LayoutOptions{NodeWidth: 100, LayerSpacing: 20})
+	assert.Equal(t, NodeAdditionalInfo{Description: "入口", LayoutX: 10, LayoutY: 20}, layout("s1"))
+	assert.Equal(t, NodeAdditionalInfo{LayoutX: 140, LayoutY: 40, Width: 100, Height: 60}, layout("s2"))
+	AutoLayout(&def, LayoutOptions{Overwrite: true})
+	assert.Equal(t, NodeAdditionalInfo{Description: "入口", LayoutX: 80, LayoutY: 40, Width: 200, Height: 60}, layout("s1"))
+}
+
+func TestAutoLayoutCycle(t *testing.T) {
+	def := RuleChain{Metadata: RuleMetadata{
+		Nodes: []*RuleNode{{Id: "s1"}, {Id: "s2"}, {Id: "s3"}, {Id: "s4"}},
+		Connections: []NodeConnection{
+			{FromId: "s1", ToId: "s2", Type: types.Success},
+			{FromId: "s2", ToId: "s1", Type: types.Failure},
+			{FromId: "s2", ToId: "s2", Type: types.Failure},
+			{FromId: "s2", ToId: "s9", Type: types.Failure},
+		},
+	}}
+	AutoLayout(&def, LayoutOptions{})
+	assert.Equal(t, 80, def.Metadata.Nodes[0].AdditionalInfo.LayoutX)
+	assert.Equal(t, 360, def.Metadata.Nodes[1].AdditionalInfo.LayoutX)
+	//没有连接的节点在第一层
+	assert.Equal(t, 80, def.Metadata.Nodes[2].AdditionalInfo.LayoutX)
+	assert.Equal(t, 80, def.Metadata.Nodes[3].AdditionalInfo.LayoutX)
+	assert.Equal(t, 240, def.Metadata.Nodes[3].AdditionalInfo.LayoutY)
+}
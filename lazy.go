@@ -0,0 +1,173 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"sync"
+)
+
+const (
+	//InitModeEager 创建规则链时同步初始化节点，默认方式
+	InitModeEager = "eager"
+	//InitModeLazy 第一条消息到达时才初始化节点
+	InitModeLazy = "lazy"
+	//InitModeAsync 创建规则链时在后台初始化节点，消息需要等待初始化完成
+	InitModeAsync = "async"
+)
+
+// ErrNodeNotReady 节点还没有完成初始化
+var ErrNodeNotReady = errors.New("node not ready")
+
+// lazyNode 延迟初始化节点，包装原节点，用于连接数据库、消息中间件等初始化耗时的节点，
+// 避免外部服务不可用时阻塞规则链加载
+// lazy模式在第一条消息到达时初始化，初始化失败消息发送到`Failure`链，下一条消息重新初始化
+// async模式创建时在后台初始化，消息等待初始化完成后处理，初始化失败消息发送到`Failure`链
+type lazyNode struct {
+	types.Node
+	mode          string
+	config        types.Config
+	configuration types.Configuration
+	//ready 初始化结束后关闭
+	ready     chan struct{}
+	err       error
+	inited    bool
+	destroyed bool
+	lock      sync.Mutex
+	//initLock 保证同一时间只有一个初始化
+	initLock sync.Mutex
+}
+
+func newLazyNode(config types.Config, node types.Node, mode string, configuration types.Configuration) *lazyNode {
+	x := &lazyNode{Node: node, mode: mode, config: config, configuration: configuration}
+	if mode == InitModeAsync {
+		x.ready = make(chan struct{})
+		go x.init()
+	}
+	return x
+}
+
+// Init 原节点延迟到第一条消息或者后台初始化
+func (x *lazyNode) Init(_ types.Config, _ types.Configuration) error {
+	return nil
+}
+
+// OnMsg 等待原节点初始化完成后处理消息，初始化失败则发送到`Failure`链
+func (x *lazyNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	var err error
+	if x.mode == InitModeAsync {
+		<-x.ready
+		err = x.Err()
+	} else {
+		err = x.init()
+	}
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	return x.Node.OnMsg(ctx, msg)
+}
+
+// Ready 返回async模式初始化结束的通道，lazy模式返回nil
+func (x *lazyNode) Ready() <-chan struct{} {
+	return x.ready
+}
+
+// Err 初始化错误
+func (x *lazyNode) Err() error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.err
+}
+
+// HealthCheck 没有完成初始化返回错误，否则检查原节点健康状态
+func (x *lazyNode) HealthCheck() error {
+	x.lock.Lock()
+	inited, err := x.inited, x.err
+	x.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	if !inited {
+		if x.mode == InitModeLazy {
+			//等待第一条消息初始化
+			return nil
+		}
+		return ErrNodeNotReady
+	}
+	if checker, ok := x.Node.(types.HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// Metrics 初始化成功后返回原节点运行指标
+func (x *lazyNode) Metrics() map[string]interface{} {
+	x.lock.Lock()
+	inited := x.inited
+	x.lock.Unlock()
+	if !inited {
+		return nil
+	}
+	return nodeMetrics(x.Node)
+}
+
+// Destroy 只销毁已经初始化的原节点，正在初始化的节点在初始化完成后销毁
+func (x *lazyNode) Destroy() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.destroyed = true
+	if x.inited {
+		x.Node.Destroy()
+	}
+}
+
+// init 初始化原节点，已经初始化成功则直接返回
+func (x *lazyNode) init() error {
+	x.initLock.Lock()
+	defer x.initLock.Unlock()
+	x.lock.Lock()
+	inited, destroyed := x.inited, x.destroyed
+	x.lock.Unlock()
+	if inited {
+		return nil
+	}
+	var err error
+	if destroyed {
+		err = ErrNodeNotReady
+	} else {
+		err = x.Node.Init(x.config, x.configuration)
+	}
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.err = err
+	if err == nil {
+		x.inited = true
+		if x.destroyed {
+			//初始化期间规则链已经销毁
+			x.Node.Destroy()
+		}
+	} else if x.config.Logger != nil {
+		x.config.Logger.Printf("init node error.node type:%s error: %s", x.Type(), err)
+	}
+	if x.ready != nil {
+		close(x.ready)
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test"
+	"github.com/2018yuli/rulego/test/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lazyTestNode 测试延迟初始化的节点，release不为空则初始化等待release关闭
+type lazyTestNode struct {
+	lock    sync.Mutex
+	inits   int
+	err     error
+	release chan struct{}
+}
+
+func (x *lazyTestNode) Type() string {
+	return "test/lazy"
+}
+
+func (x *lazyTestNode) New() types.Node {
+	return &lazyTestNode{}
+}
+
+func (x *lazyTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	if x.release != nil {
+		<-x.release
+	}
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.inits++
+	return x.err
+}
+
+func (x *lazyTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *lazyTestNode) Destroy() {
+}
+
+func (x *lazyTestNode) initCount() int {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.inits
+}
+
+func TestLazyNode(t *testing.T) {
+	target := &lazyTestNode{err: errors.New("connection refused")}
+	node := newLazyNode(NewConfig(), target, InitModeLazy, nil)
+	assert.Nil(t, node.Init(NewConfig(), nil))
+	assert.Equal(t, 0, target.initCount())
+	assert.Nil(t, node.HealthCheck())
+
+	var relations []string
+	ctx := test.NewRuleContext(NewConfig(), func(msg types.RuleMsg, relationType string) {
+		relations = append(relations, relationType)
+	})
+	//初始化失败，下一条消息重新初始化
+	assert.NotNil(t, node.OnMsg(ctx, types.RuleMsg{}))
+	assert.Equal(t, "connection refused", node.HealthCheck().Error())
+	target.lock.Lock()
+	target.err = nil
+	target.lock.Unlock()
+	assert.Nil(t, node.OnMsg(ctx, types.RuleMsg{}))
+	assert.Nil(t, node.OnMsg(ctx, types.RuleMsg{}))
+	assert.Equal(t, 2, target.initCount())
+	assert.Equal(t, []string{types.Failure, types.Success, types.Success}, relations)
+	assert.Nil(t, node.HealthCheck())
+}
+
+func TestAsyncNode(t *testing.T) {
+	target := &lazyTestNode{release: make(chan struct{})}
+	node := newLazyNode(NewConfig(), target, InitModeAsync, nil)
+	assert.Equal(t, ErrNodeNotReady, node.HealthCheck())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctx := test.NewRuleContext(NewConfig(), func(msg types.RuleMsg, relationType string) {
+		assert.Equal(t, types.Success, relationType)
+		wg.Done()
+	})
+	go func() {
+		_ = node.OnMsg(ctx, types.RuleMsg{})
+	}()
+	close(target.release)
+	waitTimeout(t, &wg, time.Second*3)
+	<-node.Ready()
+	assert.Nil(t, node.HealthCheck())
+	assert.Equal(t, 1, target.initCount())
+}
+
+func TestInitMode(t *testing.T) {
+	_ = Registry.Register(&lazyTestNode{})
+	defer Registry.Unregister("test/lazy")
+
+	ruleEngine, err := NewChainBuilder().Id("initMode01").
+		Node("test/lazy", nil).InitMode(InitModeLazy).
+		New()
+	assert.Nil(t, err)
+	defer Del("initMode01")
+	nodeCtx, ok := ruleEngine.rootRuleChainCtx.nodes[types.RuleNodeId{Id: "s1", Type: types.NODE}]
+	assert.True(t, ok)
+	_, ok = nodeCtx.(*RuleNodeCtx).Node.(*lazyNode)
+	assert.True(t, ok)
+
+	_, err = NewChainBuilder().Id("initMode02").
+		Node("test/lazy", nil).InitMode("unknown").
+		New()
+	assert.Equal(t, "unsupported initMode: unknown", err.Error())
+}
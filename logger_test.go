@@ -0,0 +1,109 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var loggerTestChain = `
+	{
+	  "ruleChain": {
+		"id": "loggerChain",
+		"name": "测试日志路由"
+	  },
+	  "metadata": {
+		"nodes": [
+		  {
+			"id": "s1",
+			"type": "log",
+			"name": "记录日志",
+			"configuration": {
+			  "jsScript": "return 'hello ' + msg;"
+			}
+		  }
+		]
+	  }
+	}
+`
+
+func TestLoggerFactory(t *testing.T) {
+	var lock sync.Mutex
+	loggers := make(map[string]*runtimeTestLogger)
+	var scopes []types.LogScope
+	factory := func(scope types.LogScope) types.Logger {
+		lock.Lock()
+		defer lock.Unlock()
+		scopes = append(scopes, scope)
+		if scope.Tenant == "" {
+			//返回nil使用Config.Logger
+			return nil
+		}
+		logger := &runtimeTestLogger{}
+		loggers[scope.Tenant] = logger
+		return logger
+	}
+	defaultLogger := &runtimeTestLogger{}
+
+	run := func(id string, config types.Config) {
+		ruleEngine, err := New(id, []byte(loggerTestChain), WithConfig(config))
+		assert.Nil(t, err)
+		defer Del(id)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.TEXT, types.NewMetadata(), id), func(msg types.RuleMsg, err error) {
+			wg.Done()
+		})
+		wg.Wait()
+	}
+	run("loggerChainA", NewConfig(types.WithLogger(defaultLogger), types.WithLoggerFactory(factory), types.WithTenant("tenantA")))
+	run("loggerChainB", NewConfig(types.WithLogger(defaultLogger), types.WithLoggerFactory(factory), types.WithTenant("tenantB")))
+	run("loggerChainC", NewConfig(types.WithLogger(defaultLogger), types.WithLoggerFactory(factory)))
+
+	assert.Equal(t, []types.LogScope{
+		{ChainId: "loggerChainA", Tenant: "tenantA"},
+		{ChainId: "loggerChainB", Tenant: "tenantB"},
+		{ChainId: "loggerChainC"},
+	}, scopes)
+	assert.True(t, contains(loggers["tenantA"].lines, "hello loggerChainA"))
+	assert.False(t, contains(loggers["tenantA"].lines, "hello loggerChainB"))
+	assert.True(t, contains(loggers["tenantB"].lines, "hello loggerChainB"))
+	assert.True(t, contains(defaultLogger.lines, "hello loggerChainC"))
+	assert.False(t, contains(defaultLogger.lines, "hello loggerChainA"))
+
+	//没有指定ID使用规则链定义的ID，重新加载不重复创建
+	scopes = nil
+	ruleEngine, err := New("", []byte(loggerTestChain), WithConfig(NewConfig(types.WithLoggerFactory(factory), types.WithTenant("tenantA"))))
+	assert.Nil(t, err)
+	defer Del(ruleEngine.Id)
+	assert.Nil(t, ruleEngine.ReloadSelf([]byte(loggerTestChain)))
+	assert.Equal(t, []types.LogScope{{ChainId: "loggerChain", Tenant: "tenantA"}}, scopes)
+}
+
+// contains 日志中是否包含指定内容
+func contains(lines []string, s string) bool {
+	for _, line := range lines {
+		if strings.Contains(line, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"sort"
+	"time"
+)
+
+// defaultMetricsInterval 默认组件运行指标回调间隔
+const defaultMetricsInterval = time.Second * 10
+
+// NodeMetrics 节点运行指标
+type NodeMetrics struct {
+	//ChainId 节点所在规则链ID
+	ChainId string `json:"chainId"`
+	//NodeId 节点ID
+	NodeId string `json:"nodeId"`
+	//Type 节点类型
+	Type string `json:"type"`
+	//Metrics 节点通过`types.MetricsProvider`提供的运行指标
+	Metrics map[string]interface{} `json:"metrics"`
+}
+
+// nodeMetrics 节点实现了`types.MetricsProvider`则返回运行指标，否则返回nil
+func nodeMetrics(node types.Node) map[string]interface{} {
+	if provider, ok := node.(types.MetricsProvider); ok {
+		return provider.Metrics()
+	}
+	return nil
+}
+
+// Metrics 获取规则链和子规则链中实现了`types.MetricsProvider`的节点的运行指标，按节点ID排序
+func (rc *RuleChainCtx) Metrics() []NodeMetrics {
+	rc.RLock()
+	nodes := make([]types.NodeCtx, 0, len(rc.nodes))
+	for _, node := range rc.nodes {
+		nodes = append(nodes, node)
+	}
+	rc.RUnlock()
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].GetNodeId().Id < nodes[j].GetNodeId().Id
+	})
+	var result []NodeMetrics
+	for _, node := range nodes {
+		if subChain, ok := node.(*RuleChainCtx); ok {
+			result = append(result, subChain.Metrics()...)
+			continue
+		}
+		nodeCtx, ok := node.(*RuleNodeCtx)
+		if !ok {
+			continue
+		}
+		if metrics := nodeMetrics(nodeCtx.Node); metrics != nil {
+			result = append(result, NodeMetrics{ChainId: rc.Id.Id, NodeId: nodeCtx.GetNodeId().Id, Type: nodeCtx.Type(), Metrics: metrics})
+		}
+	}
+	return result
+}
+
+// Metrics 获取规则链所有节点的运行指标，没有初始化返回nil
+func (e *RuleEngine) Metrics() []NodeMetrics {
+	//定期回调在后台执行，和重新加载并发
+	e.gate.lock.RLock()
+	rootRuleChainCtx := e.rootRuleChainCtx
+	e.gate.lock.RUnlock()
+	if rootRuleChainCtx == nil {
+		return nil
+	}
+	return rootRuleChainCtx.Metrics()
+}
+
+// startMetrics 配置了`Config.OnComponentMetrics`则按MetricsInterval定期回调节点运行指标，重复调用只启动一次
+func (e *RuleEngine) startMetrics() {
+	onMetrics := e.Config.OnComponentMetrics
+	if onMetrics == nil || e.metricsStop != nil {
+		return
+	}
+	interval := e.Config.MetricsInterval
+	if interval <= 0 {
+		interval = defaultMetricsInterval
+	}
+	stop := make(chan struct{})
+	e.metricsStop = stop
+	ticker := e.Config.GetClock().NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C():
+				for _, item := range e.Metrics() {
+					onMetrics(item.ChainId, item.NodeId, item.Metrics)
+				}
+			}
+		}
+	}()
+}
+
+// stopMetrics 停止定期回调节点运行指标
+func (e *RuleEngine) stopMetrics() {
+	if e.metricsStop != nil {
+		close(e.metricsStop)
+		e.metricsStop = nil
+	}
+}
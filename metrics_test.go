@@ -0,0 +1,137 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/state"
+	"testing"
+	"time"
+)
+
+// metricsTestNode 测试提供运行指标的节点
+type metricsTestNode struct {
+	healthTestNode
+}
+
+func (x *metricsTestNode) Type() string {
+	return "test/metrics"
+}
+
+func (x *metricsTestNode) New() types.Node {
+	return &metricsTestNode{}
+}
+
+func (x *metricsTestNode) Metrics() map[string]interface{} {
+	return map[string]interface{}{"inUse": 1}
+}
+
+func TestRuleEngineMetrics(t *testing.T) {
+	_ = Registry.Register(&metricsTestNode{})
+	defer Registry.Unregister("test/metrics")
+
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	reports := make(chan NodeMetrics, 10)
+	config := NewConfig(types.WithClock(vc), types.WithOnComponentMetrics(time.Second*5, func(chainId, nodeId string, metrics map[string]interface{}) {
+		reports <- NodeMetrics{ChainId: chainId, NodeId: nodeId, Metrics: metrics}
+	}))
+	def, err := NewChainBuilder().Id("metrics01").
+		NodeWithId("db", "test/metrics", nil).
+		Node("jsFilter", types.Configuration{"jsScript": "return true;"}).
+		//延迟初始化的节点初始化前没有指标
+		NodeWithId("lazy", "test/metrics", nil).InitMode(InitModeLazy).
+		DSL()
+	assert.Nil(t, err)
+	ruleEngine, err := newRuleEngine("metrics01", def, WithConfig(config))
+	assert.Nil(t, err)
+
+	metrics := ruleEngine.Metrics()
+	assert.Equal(t, 1, len(metrics))
+	assert.Equal(t, "metrics01", metrics[0].ChainId)
+	assert.Equal(t, "db", metrics[0].NodeId)
+	assert.Equal(t, "test/metrics", metrics[0].Type)
+
+	//按间隔回调
+	assert.True(t, vc.WaitPending(1, time.Second))
+	vc.Advance(time.Second * 5)
+	select {
+	case report := <-reports:
+		assert.Equal(t, "db", report.NodeId)
+		assert.Equal(t, 1, report.Metrics["inUse"])
+	case <-time.After(time.Second * 3):
+		t.Fatal("wait metrics timeout")
+	}
+
+	//重新加载不会重复启动，停止后不再回调
+	assert.Nil(t, ruleEngine.ReloadSelf(def))
+	assert.Equal(t, 1, vc.Pending())
+	ruleEngine.Stop()
+	assert.Equal(t, 0, len(ruleEngine.Metrics()))
+	assert.True(t, waitNoPending(vc, time.Second))
+}
+
+// waitNoPending 等待虚拟时钟没有未触发的定时器
+func waitNoPending(vc *clock.Virtual, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for vc.Pending() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+func TestRuleEngineJsMetrics(t *testing.T) {
+	script := "const n = Number(state.get('n') || 0) + 1; state.put('n', String(n)); metrics.inc('calls'); metrics.observe('n', n); return true;"
+	def := `{
+	  "ruleChain": {"id": "jsMetricsChain"},
+	  "metadata": {
+		"nodes": [
+		  {"id": "s1", "type": "jsFilter", "configuration": {"jsScript": "` + script + `"}},
+		  {"id": "s2", "type": "jsFilter", "configuration": {"jsScript": "` + script + `"}}
+		],
+		"connections": [{"fromId": "s1", "toId": "s2", "type": "True"}]
+	  }
+	}`
+	config := NewConfig()
+	ruleEngine, err := New("jsMetricsChain", []byte(def), WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("jsMetricsChain")
+	for i := 0; i < 3; i++ {
+		done := make(chan struct{})
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.TEXT, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+			close(done)
+		})
+		<-done
+	}
+	metrics := ruleEngine.Metrics()
+	assert.Equal(t, 2, len(metrics))
+	for _, item := range metrics {
+		assert.Equal(t, float64(3), item.Metrics["calls"])
+		assert.Equal(t, map[string]interface{}{"count": int64(3), "sum": float64(6), "min": float64(1), "max": float64(3)}, item.Metrics["n"])
+	}
+	//节点状态按节点隔离
+	for _, nodeId := range []string{"s1", "s2"} {
+		v, ok, err := config.GetStateStore().Get(state.Key("jsMetricsChain", nodeId, "n"))
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "3", v)
+	}
+}
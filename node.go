@@ -18,6 +18,7 @@ package rulego
 
 import (
 	"errors"
+	"fmt"
 	"github.com/2018yuli/rulego/api/types"
 )
 
@@ -44,15 +45,49 @@ func InitRuleNodeCtx(config types.Config, selfDefinition *RuleNode) (*RuleNodeCt
 		if selfDefinition.Configuration == nil {
 			selfDefinition.Configuration = make(types.Configuration)
 		}
-		if err = node.Init(config, selfDefinition.Configuration); err != nil {
+		metadataOnly := types.IsMetadataOnly(node)
+		sideEffect, hasSideEffect := node.(types.SideEffectNode)
+		//转换成节点需要的消息数据类型
+		node = newDataTypeNode(node)
+		if config.DryRun && hasSideEffect {
+			//dry-run模式不执行有副作用的操作
+			node = newDryRunNode(config, node, sideEffect, selfDefinition.Id)
+		}
+		if config.BlobStore != nil && !metadataOnly {
+			//加载被转存到BlobStore的消息内容
+			node = newBlobNode(config.BlobStore, node)
+		}
+		if fault, ok := config.Faults[selfDefinition.Type]; ok {
+			node = newFaultNode(config, node, fault)
+		}
+		//加载$ref引用的外部文件，节点定义保留引用
+		var configuration types.Configuration
+		if configuration, err = includeConfiguration(config, selfDefinition.Configuration); err != nil {
+			return &RuleNodeCtx{}, fmt.Errorf("node id=%s: %w", selfDefinition.Id, err)
+		}
+		//解密敏感字段，节点定义保留加密值
+		if configuration, err = decryptConfiguration(config, configuration); err != nil {
+			return &RuleNodeCtx{}, err
+		}
+		switch selfDefinition.InitMode {
+		case "", InitModeEager:
+			if err = node.Init(config, configuration); err != nil && config.DegradedStart {
+				node = newDegradedNode(config, node, configuration, err)
+				err = nil
+			}
+		case InitModeLazy, InitModeAsync:
+			node = newLazyNode(config, node, selfDefinition.InitMode, configuration)
+		default:
+			err = fmt.Errorf("unsupported initMode: %s", selfDefinition.InitMode)
+		}
+		if err != nil {
 			return &RuleNodeCtx{}, err
-		} else {
-			return &RuleNodeCtx{
-				Node:           node,
-				SelfDefinition: selfDefinition,
-				Config:         config,
-			}, nil
 		}
+		return &RuleNodeCtx{
+			Node:           node,
+			SelfDefinition: selfDefinition,
+			Config:         config,
+		}, nil
 	}
 
 }
@@ -85,8 +120,24 @@ func (rn *RuleNodeCtx) GetNodeById(_ types.RuleNodeId) (types.NodeCtx, bool) {
 	return nil, false
 }
 
+// HealthCheck 如果组件实现了`types.HealthChecker`则检查组件健康状态
+func (rn *RuleNodeCtx) HealthCheck() error {
+	if checker, ok := rn.Node.(types.HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// DSL 导出节点定义，配置了SecretsProvider则加密敏感字段
 func (rn *RuleNodeCtx) DSL() []byte {
-	v, _ := rn.Config.Parser.EncodeRuleNode(rn.SelfDefinition)
+	def, err := encryptRuleNode(rn.Config, rn.SelfDefinition)
+	if err != nil {
+		if rn.Config.Logger != nil {
+			rn.Config.Logger.Printf("encrypt rule node error: %s", err)
+		}
+		return nil
+	}
+	v, _ := rn.Config.Parser.EncodeRuleNode(def)
 	return v
 }
 
@@ -99,4 +150,5 @@ func (rn *RuleNodeCtx) Copy(newCtx *RuleNodeCtx) {
 	rn.SelfDefinition.Type = newCtx.SelfDefinition.Type
 	rn.SelfDefinition.DebugMode = newCtx.SelfDefinition.DebugMode
 	rn.SelfDefinition.Configuration = newCtx.SelfDefinition.Configuration
+	rn.SelfDefinition.InitMode = newCtx.SelfDefinition.InitMode
 }
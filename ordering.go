@@ -0,0 +1,136 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/str"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultOrderingTimeout 保证顺序时，消息处理没有结束的最长等待时间
+const defaultOrderingTimeout = time.Second * 30
+
+// keyedExecutor 按key串行执行任务，相同key的任务按提交顺序执行，不同key的任务并行执行
+type keyedExecutor struct {
+	clock   clock.Clock
+	timeout time.Duration
+	queues  map[string][]func(done func())
+	lock    sync.Mutex
+}
+
+func newKeyedExecutor(config types.Config) *keyedExecutor {
+	timeout := config.OrderingTimeout
+	if timeout <= 0 {
+		timeout = defaultOrderingTimeout
+	}
+	return &keyedExecutor{clock: config.GetClock(), timeout: timeout, queues: make(map[string][]func(done func()))}
+}
+
+// Submit 提交任务，任务处理完成需要调用done，超过timeout没有调用done则继续执行该key的下一个任务
+func (e *keyedExecutor) Submit(key string, task func(done func())) {
+	e.lock.Lock()
+	queue, running := e.queues[key]
+	e.queues[key] = append(queue, task)
+	e.lock.Unlock()
+	if !running {
+		e.run(key, task)
+	}
+}
+
+// Len 等待执行和正在执行的key数量
+func (e *keyedExecutor) Len() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return len(e.queues)
+}
+
+func (e *keyedExecutor) run(key string, task func(done func())) {
+	var once sync.Once
+	var lock sync.Mutex
+	var timer clock.Timer
+	done := func() {
+		once.Do(func() {
+			lock.Lock()
+			if timer != nil {
+				timer.Stop()
+			}
+			lock.Unlock()
+			if next, ok := e.next(key); ok {
+				e.run(key, next)
+			}
+		})
+	}
+	lock.Lock()
+	timer = e.clock.AfterFunc(e.timeout, done)
+	lock.Unlock()
+	task(done)
+}
+
+// next 移除已经完成的任务，返回该key的下一个任务
+func (e *keyedExecutor) next(key string) (func(done func()), bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	queue := e.queues[key][1:]
+	if len(queue) == 0 {
+		delete(e.queues, key)
+		return nil, false
+	}
+	e.queues[key] = queue
+	return queue[0], true
+}
+
+// msgTracker 跟踪一条消息在规则链中的所有分支，全部处理结束后调用onDone一次
+// 待执行和执行中的节点、`RuleContext.Async`异步完成、`RuleContext.Stream`流式输出、TellSelf延迟发送和结束回调各占用一次计数
+type msgTracker struct {
+	pending int64
+	onDone  func()
+}
+
+// newMsgTracker 创建消息跟踪器，初始占用一次计数，提交消息后需要调用release
+// onDone为空则不需要跟踪，返回nil
+func newMsgTracker(onDone func()) *msgTracker {
+	if onDone == nil {
+		return nil
+	}
+	return &msgTracker{pending: 1, onDone: onDone}
+}
+
+// acquire 占用一次计数，t为nil则忽略
+func (t *msgTracker) acquire() {
+	if t != nil {
+		atomic.AddInt64(&t.pending, 1)
+	}
+}
+
+// release 释放一次计数，全部释放后调用onDone，t为nil则忽略
+func (t *msgTracker) release() {
+	if t != nil && atomic.AddInt64(&t.pending, -1) == 0 {
+		t.onDone()
+	}
+}
+
+// orderingKey 获取消息的顺序key，没有配置顺序key则返回false
+func (rc *RuleChainCtx) orderingKey(msg types.RuleMsg) (string, bool) {
+	if rc.ordering == nil {
+		return "", false
+	}
+	return str.SprintfDict(rc.info.OrderingKey, msg.Metadata.Values()), true
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// orderTestNode 记录消息处理顺序的测试组件，msg.Data为slow时延迟处理
+type orderTestNode struct {
+	lock  sync.Mutex
+	order []string
+}
+
+func (x *orderTestNode) Type() string {
+	return "test/order"
+}
+
+func (x *orderTestNode) New() types.Node {
+	return x
+}
+
+func (x *orderTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *orderTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	completion := ctx.Async(msg, 0)
+	go func() {
+		if msg.Data == "slow" {
+			time.Sleep(time.Millisecond * 100)
+		}
+		x.record(msg.Metadata.GetValue("deviceId").(string) + ":" + msg.Data)
+		completion.TellSuccess(msg)
+	}()
+	return nil
+}
+
+func (x *orderTestNode) Destroy() {
+}
+
+func (x *orderTestNode) record(item string) {
+	x.lock.Lock()
+	x.order = append(x.order, item)
+	x.lock.Unlock()
+}
+
+// slowBranchTestNode 延迟处理的分支，记录到orderTestNode
+type slowBranchTestNode struct {
+	order *orderTestNode
+}
+
+func (x *slowBranchTestNode) Type() string {
+	return "test/slowBranch"
+}
+
+func (x *slowBranchTestNode) New() types.Node {
+	return x
+}
+
+func (x *slowBranchTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *slowBranchTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	completion := ctx.Async(msg, 0)
+	ctx.Config().GetClock().AfterFunc(time.Millisecond*100, func() {
+		x.order.record("slow:" + msg.Data)
+		completion.TellSuccess(msg)
+	})
+	return nil
+}
+
+func (x *slowBranchTestNode) Destroy() {
+}
+
+func TestOrderingKey(t *testing.T) {
+	node := &orderTestNode{}
+	_ = Registry.Register(node)
+	defer Registry.Unregister("test/order")
+
+	ruleEngine, err := NewChainBuilder().Id("ordering01").OrderingKey("${deviceId}").Node("test/order", nil).New()
+	assert.Nil(t, err)
+	defer Del("ordering01")
+
+	var wg sync.WaitGroup
+	send := func(deviceId, data string) {
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceId", deviceId)
+		wg.Add(1)
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.TEXT, metadata, data), func(msg types.RuleMsg, err error) {
+			wg.Done()
+		})
+	}
+	send("d1", "slow")
+	send("d1", "fast")
+	send("d2", "fast")
+	waitTimeout(t, &wg, time.Second*3)
+
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	//相同key按顺序处理，不同key并行处理
+	assert.Equal(t, []string{"d2:fast", "d1:slow", "d1:fast"}, node.order)
+	assert.Equal(t, 0, ruleEngine.RootRuleChainCtx().ordering.Len())
+}
+
+func TestOrderingKeyBranches(t *testing.T) {
+	node := &orderTestNode{}
+	_ = Registry.Register(node)
+	defer Registry.Unregister("test/order")
+	_ = Registry.Register(&slowBranchTestNode{order: node})
+	defer Registry.Unregister("test/slowBranch")
+
+	//s1分成两个分支，slow分支处理结束前fast分支已经结束
+	ruleEngine, err := NewChainBuilder().Id("ordering02").OrderingKey("${deviceId}").
+		Node("test/order", nil).On(types.Success).NodeWithId("fast", "test/order", nil).
+		From("s1").On(types.Success).NodeWithId("slow", "test/slowBranch", nil).New()
+	assert.Nil(t, err)
+	defer Del("ordering02")
+
+	var wg sync.WaitGroup
+	send := func(data string) {
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceId", "d1")
+		//每个分支结束调用一次
+		wg.Add(2)
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.TEXT, metadata, data), func(msg types.RuleMsg, err error) {
+			wg.Done()
+		})
+	}
+	send("m1")
+	send("m2")
+	waitTimeout(t, &wg, time.Second*3)
+
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	//上一条消息所有分支结束后才处理下一条
+	assert.Equal(t, []string{"d1:m1", "d1:m1", "slow:m1", "d1:m2", "d1:m2", "slow:m2"}, node.order)
+	assert.Equal(t, 0, ruleEngine.RootRuleChainCtx().ordering.Len())
+}
+
+func TestOnAllNodeCompleted(t *testing.T) {
+	node := &orderTestNode{}
+	_ = Registry.Register(node)
+	defer Registry.Unregister("test/order")
+	_ = Registry.Register(&slowBranchTestNode{order: node})
+	defer Registry.Unregister("test/slowBranch")
+
+	for _, orderingKey := range []string{"", "${deviceId}"} {
+		ruleEngine, err := NewChainBuilder().Id("completed01").OrderingKey(orderingKey).
+			Node("test/order", nil).On(types.Success).NodeWithId("fast", "test/order", nil).
+			From("s1").On(types.Success).NodeWithId("slow", "test/slowBranch", nil).New()
+		assert.Nil(t, err)
+
+		var ends int32
+		completed := make(chan int32, 2)
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceId", "d1")
+		ruleEngine.OnMsgWithOptions(types.NewMsg(0, "TEST", types.TEXT, metadata, "m1"),
+			types.WithEndFunc(func(msg types.RuleMsg, err error) {
+				atomic.AddInt32(&ends, 1)
+			}),
+			types.WithOnAllNodeCompleted(func() {
+				completed <- atomic.LoadInt32(&ends)
+			}))
+		select {
+		case n := <-completed:
+			//所有分支的结束回调执行之后回调
+			assert.Equal(t, int32(2), n)
+		case <-time.After(time.Second * 3):
+			t.Fatal("all node completed not called")
+		}
+		time.Sleep(time.Millisecond * 50)
+		assert.Equal(t, 0, len(completed))
+		Del("completed01")
+	}
+}
+
+func TestKeyedExecutorTimeout(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	executor := newKeyedExecutor(NewConfig(types.WithClock(vc), types.WithOrderingTimeout(time.Second)))
+	var order []int
+	executor.Submit("k1", func(done func()) {
+		//没有调用done
+		order = append(order, 1)
+	})
+	executor.Submit("k1", func(done func()) {
+		order = append(order, 2)
+		done()
+		done()
+	})
+	assert.Equal(t, []int{1}, order)
+	vc.Advance(time.Second)
+	assert.Equal(t, []int{1, 2}, order)
+	assert.Equal(t, 0, executor.Len())
+	assert.Equal(t, 0, vc.Pending())
+}
@@ -0,0 +1,309 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// maxExtendsDepth 规则链最大继承层数，防止循环继承
+const maxExtendsDepth = 8
+
+// BaseChains 基础规则链库，规则链通过ruleChain.extends继承其中的规则链
+var BaseChains = new(BaseChainRegistry)
+
+// BaseChainRegistry 基础规则链库
+type BaseChainRegistry struct {
+	chains map[string]RuleChain
+	sync.RWMutex
+}
+
+// Register 注册基础规则链，已经存在则覆盖
+func (r *BaseChainRegistry) Register(def RuleChain) error {
+	if def.RuleChain.ID == "" {
+		return errors.New("base rule chain id can not empty")
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.chains == nil {
+		r.chains = make(map[string]RuleChain)
+	}
+	r.chains[def.RuleChain.ID] = def
+	return nil
+}
+
+// RegisterDSL 解析并注册基础规则链
+func (r *BaseChainRegistry) RegisterDSL(dsl []byte) error {
+	def, err := ParserRuleChain(dsl)
+	if err != nil {
+		return err
+	}
+	return r.Register(def)
+}
+
+// Unregister 删除基础规则链
+func (r *BaseChainRegistry) Unregister(id string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.chains, id)
+}
+
+// Get 获取基础规则链
+func (r *BaseChainRegistry) Get(id string) (RuleChain, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	def, ok := r.chains[id]
+	return def, ok
+}
+
+// ResolveChain 合并继承的基础规则链并展开模板，返回用于创建规则链的定义，不修改原定义
+func ResolveChain(def RuleChain) (RuleChain, error) {
+	resolved, err := ResolveExtends(def)
+	if err != nil {
+		return def, err
+	}
+	return ExpandTemplates(resolved)
+}
+
+// ResolveExtends 合并ruleChain.extends继承的基础规则链，返回合并后的定义，不修改原定义
+// 没有继承则直接返回原定义。合并规则：
+//   - ruleChain：非空字段覆盖基础规则链，configuration和additionalInfo按key覆盖
+//   - nodes：相同id的节点非空字段覆盖基础节点，configuration按key覆盖，值为null则删除该key；不同id的节点追加
+//   - connections、ruleChainConnections：追加，已经存在的连接忽略
+//   - removeNodes：删除基础规则链的节点以及和该节点相关的连接
+//   - removeConnections：删除基础规则链的连接
+//   - templates：相同id覆盖
+//
+// 第一个节点保持基础规则链的第一个节点
+func ResolveExtends(def RuleChain) (RuleChain, error) {
+	return resolveExtends(def, 0)
+}
+
+func resolveExtends(def RuleChain, depth int) (RuleChain, error) {
+	if def.RuleChain.Extends == "" {
+		return def, nil
+	}
+	if depth >= maxExtendsDepth {
+		return def, fmt.Errorf("rule chain extends exceeds %d levels", maxExtendsDepth)
+	}
+	base, ok := BaseChains.Get(def.RuleChain.Extends)
+	if !ok {
+		return def, fmt.Errorf("base rule chain not found.extends=%s", def.RuleChain.Extends)
+	}
+	base, err := resolveExtends(base, depth+1)
+	if err != nil {
+		return def, err
+	}
+	return mergeRuleChain(base, def)
+}
+
+// connectionKey 连接的唯一标识，不包含描述等展示字段
+type connectionKey struct {
+	fromId       string
+	toId         string
+	relationType string
+}
+
+// mergeRuleChain 把overlay合并到base，返回新的定义
+func mergeRuleChain(base, overlay RuleChain) (RuleChain, error) {
+	result := base
+	info := &result.RuleChain
+	info.Extends = ""
+	if overlay.RuleChain.ID != "" {
+		info.ID = overlay.RuleChain.ID
+	}
+	if overlay.RuleChain.Name != "" {
+		info.Name = overlay.RuleChain.Name
+	}
+	if overlay.RuleChain.DebugMode {
+		info.DebugMode = true
+	}
+	if overlay.RuleChain.Root {
+		info.Root = true
+	}
+	if overlay.RuleChain.InitMode != "" {
+		info.InitMode = overlay.RuleChain.InitMode
+	}
+	if overlay.RuleChain.OrderingKey != "" {
+		info.OrderingKey = overlay.RuleChain.OrderingKey
+	}
+	if overlay.RuleChain.Description != "" {
+		info.Description = overlay.RuleChain.Description
+	}
+	if len(overlay.RuleChain.Requires) > 0 {
+		info.Requires = overlay.RuleChain.Requires
+	}
+	info.Configuration = mergeConfiguration(base.RuleChain.Configuration, overlay.RuleChain.Configuration)
+	if len(overlay.RuleChain.AdditionalInfo) > 0 {
+		info.AdditionalInfo = make(map[string]string, len(base.RuleChain.AdditionalInfo)+len(overlay.RuleChain.AdditionalInfo))
+		for k, v := range base.RuleChain.AdditionalInfo {
+			info.AdditionalInfo[k] = v
+		}
+		for k, v := range overlay.RuleChain.AdditionalInfo {
+			info.AdditionalInfo[k] = v
+		}
+	}
+
+	removed := make(map[string]bool, len(overlay.Metadata.RemoveNodes))
+	for _, id := range overlay.Metadata.RemoveNodes {
+		removed[id] = true
+	}
+	var firstId string
+	if index := base.Metadata.FirstNodeIndex; index >= 0 && index < len(base.Metadata.Nodes) {
+		firstId = base.Metadata.Nodes[index].Id
+	}
+	metadata := RuleMetadata{}
+	nodes := make(map[string]*RuleNode)
+	for _, node := range base.Metadata.Nodes {
+		if removed[node.Id] {
+			delete(removed, node.Id)
+			continue
+		}
+		item := *node
+		metadata.Nodes = append(metadata.Nodes, &item)
+		nodes[item.Id] = &item
+	}
+	for id := range removed {
+		//删除的节点必须在基础规则链中存在
+		return result, fmt.Errorf("remove node id=%s not found in base rule chain", id)
+	}
+	for _, node := range overlay.Metadata.Nodes {
+		if node.Id == "" {
+			return result, errors.New("overlay node id can not empty")
+		}
+		item, ok := nodes[node.Id]
+		if !ok {
+			added := *node
+			metadata.Nodes = append(metadata.Nodes, &added)
+			nodes[added.Id] = &added
+			continue
+		}
+		if node.Type != "" {
+			item.Type = node.Type
+		}
+		if node.Name != "" {
+			item.Name = node.Name
+		}
+		if node.DebugMode {
+			item.DebugMode = true
+		}
+		if node.InitMode != "" {
+			item.InitMode = node.InitMode
+		}
+		if node.Description != "" {
+			item.Description = node.Description
+		}
+		if node.AdditionalInfo != (NodeAdditionalInfo{}) {
+			item.AdditionalInfo = node.AdditionalInfo
+		}
+		item.Configuration = mergeConfiguration(item.Configuration, node.Configuration)
+	}
+	if _, ok := nodes[firstId]; !ok {
+		return result, fmt.Errorf("first node id=%s of base rule chain removed", firstId)
+	}
+	for index, node := range metadata.Nodes {
+		if node.Id == firstId {
+			metadata.FirstNodeIndex = index
+		}
+	}
+
+	removedConnections := make(map[connectionKey]bool, len(overlay.Metadata.RemoveConnections))
+	for _, item := range overlay.Metadata.RemoveConnections {
+		removedConnections[connectionKey{item.FromId, item.ToId, item.Type}] = true
+	}
+	//基础规则链中和已删除节点相关的连接忽略，overlay的连接必须指向存在的节点
+	//连接以fromId、toId、type区分，重复定义的连接只更新描述
+	exists := make(map[connectionKey]int)
+	for _, item := range base.Metadata.Connections {
+		key := connectionKey{item.FromId, item.ToId, item.Type}
+		if _, ok := exists[key]; ok || removedConnections[key] || nodes[item.FromId] == nil || nodes[item.ToId] == nil {
+			continue
+		}
+		exists[key] = len(metadata.Connections)
+		metadata.Connections = append(metadata.Connections, item)
+	}
+	for _, item := range overlay.Metadata.Connections {
+		if nodes[item.FromId] == nil || nodes[item.ToId] == nil {
+			return result, fmt.Errorf("overlay connection %s->%s node not found", item.FromId, item.ToId)
+		}
+		key := connectionKey{item.FromId, item.ToId, item.Type}
+		if index, ok := exists[key]; !ok {
+			exists[key] = len(metadata.Connections)
+			metadata.Connections = append(metadata.Connections, item)
+		} else if item.Description != "" {
+			metadata.Connections[index].Description = item.Description
+		}
+	}
+	chainExists := make(map[connectionKey]int)
+	for _, item := range base.Metadata.RuleChainConnections {
+		key := connectionKey{item.FromId, item.ToId, item.Type}
+		if _, ok := chainExists[key]; ok || nodes[item.FromId] == nil {
+			continue
+		}
+		chainExists[key] = len(metadata.RuleChainConnections)
+		metadata.RuleChainConnections = append(metadata.RuleChainConnections, item)
+	}
+	for _, item := range overlay.Metadata.RuleChainConnections {
+		if nodes[item.FromId] == nil {
+			return result, fmt.Errorf("overlay rule chain connection fromId=%s not found", item.FromId)
+		}
+		key := connectionKey{item.FromId, item.ToId, item.Type}
+		if index, ok := chainExists[key]; !ok {
+			chainExists[key] = len(metadata.RuleChainConnections)
+			metadata.RuleChainConnections = append(metadata.RuleChainConnections, item)
+		} else if item.Description != "" {
+			metadata.RuleChainConnections[index].Description = item.Description
+		}
+	}
+
+	metadata.Templates = append(metadata.Templates, base.Metadata.Templates...)
+	for _, template := range overlay.Metadata.Templates {
+		replaced := false
+		for i, item := range metadata.Templates {
+			if item.Id == template.Id {
+				metadata.Templates[i] = template
+				replaced = true
+			}
+		}
+		if !replaced {
+			metadata.Templates = append(metadata.Templates, template)
+		}
+	}
+	result.Metadata = metadata
+	return result, nil
+}
+
+// mergeConfiguration 按key合并配置，overlay的值为nil则删除该key
+func mergeConfiguration(base, overlay map[string]interface{}) map[string]interface{} {
+	if len(overlay) == 0 {
+		return base
+	}
+	result := make(map[string]interface{}, len(base)+len(overlay))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range overlay {
+		if v == nil {
+			delete(result, k)
+		} else {
+			result[k] = v
+		}
+	}
+	return result
+}
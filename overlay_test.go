@@ -0,0 +1,175 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var baseRuleChain = `
+	{
+	  "ruleChain": {
+		"id": "alarmBase",
+		"name": "告警",
+		"configuration": {"env": "dev", "owner": "iot"}
+	  },
+	  "metadata": {
+		"nodes": [
+		  {"id": "s1", "type": "jsFilter", "configuration": {"jsScript": "return msg.temperature > 50;"}},
+		  {"id": "s2", "type": "jsTransform", "configuration": {"jsScript": "metadata['env']='dev';return {'msg':msg,'metadata':metadata,'msgType':msgType};", "debug": true}},
+		  {"id": "s3", "type": "log", "configuration": {"jsScript": "return 'alarm';"}}
+		],
+		"connections": [
+		  {"fromId": "s1", "toId": "s2", "type": "True"},
+		  {"fromId": "s2", "toId": "s3", "type": "Success"}
+		]
+	  }
+	}
+`
+
+var prodRuleChain = `
+	{
+	  "ruleChain": {
+		"id": "alarmProd",
+		"extends": "alarmBase",
+		"configuration": {"env": "prod", "owner": null}
+	  },
+	  "metadata": {
+		"nodes": [
+		  {"id": "s2", "configuration": {"jsScript": "metadata['env']='prod';return {'msg':msg,'metadata':metadata,'msgType':msgType};", "debug": null}},
+		  {"id": "s4", "type": "jsTransform", "configuration": {"jsScript": "metadata['low']='true';return {'msg':msg,'metadata':metadata,'msgType':msgType};"}}
+		],
+		"connections": [
+		  {"fromId": "s1", "toId": "s4", "type": "False"}
+		],
+		"removeNodes": ["s3"]
+	  }
+	}
+`
+
+func TestResolveExtends(t *testing.T) {
+	assert.Nil(t, BaseChains.RegisterDSL([]byte(baseRuleChain)))
+	defer BaseChains.Unregister("alarmBase")
+	overlay, err := ParserRuleChain([]byte(prodRuleChain))
+	assert.Nil(t, err)
+
+	def, err := ResolveExtends(overlay)
+	assert.Nil(t, err)
+	assert.Equal(t, "alarmProd", def.RuleChain.ID)
+	assert.Equal(t, "告警", def.RuleChain.Name)
+	assert.Equal(t, "", def.RuleChain.Extends)
+	assert.Equal(t, map[string]interface{}{"env": "prod"}, map[string]interface{}(def.RuleChain.Configuration))
+	var ids []string
+	for _, node := range def.Metadata.Nodes {
+		ids = append(ids, node.Id)
+	}
+	assert.Equal(t, []string{"s1", "s2", "s4"}, ids)
+	assert.Equal(t, "jsTransform", def.Metadata.Nodes[1].Type)
+	assert.True(t, strings.Contains(def.Metadata.Nodes[1].Configuration["jsScript"].(string), "prod"))
+	assert.False(t, def.Metadata.Nodes[1].Configuration["debug"] != nil)
+	assert.Equal(t, []NodeConnection{
+		{FromId: "s1", ToId: "s2", Type: types.True},
+		{FromId: "s1", ToId: "s4", Type: types.False},
+	}, def.Metadata.Connections)
+	//基础规则链不变
+	base, _ := BaseChains.Get("alarmBase")
+	assert.Equal(t, 3, len(base.Metadata.Nodes))
+	assert.Equal(t, true, base.Metadata.Nodes[1].Configuration["debug"])
+
+	//多层继承
+	assert.Nil(t, BaseChains.Register(overlay))
+	defer BaseChains.Unregister("alarmProd")
+	def, err = ResolveExtends(RuleChain{
+		RuleChain: RuleChainBaseInfo{ID: "alarmProdEu", Extends: "alarmProd"},
+		Metadata:  RuleMetadata{RemoveConnections: []NodeConnection{{FromId: "s1", ToId: "s4", Type: types.False}}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(def.Metadata.Nodes))
+	assert.Equal(t, []NodeConnection{{FromId: "s1", ToId: "s2", Type: types.True}}, def.Metadata.Connections)
+
+	//连接按fromId、toId、type匹配，描述不影响删除，重复连接只更新描述
+	def, err = ResolveExtends(RuleChain{
+		RuleChain: RuleChainBaseInfo{ID: "alarmProdUs", Extends: "alarmProd", Description: "美国区"},
+		Metadata: RuleMetadata{
+			Nodes:             []*RuleNode{{Id: "s2", Description: "转换"}},
+			Connections:       []NodeConnection{{FromId: "s1", ToId: "s2", Type: types.True, Description: "告警"}},
+			RemoveConnections: []NodeConnection{{FromId: "s1", ToId: "s4", Type: types.False, Description: "忽略"}},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "美国区", def.RuleChain.Description)
+	assert.Equal(t, "转换", def.Metadata.Nodes[1].Description)
+	assert.Equal(t, "jsTransform", def.Metadata.Nodes[1].Type)
+	assert.Equal(t, []NodeConnection{{FromId: "s1", ToId: "s2", Type: types.True, Description: "告警"}}, def.Metadata.Connections)
+}
+
+func TestResolveExtendsError(t *testing.T) {
+	assert.Nil(t, BaseChains.RegisterDSL([]byte(baseRuleChain)))
+	defer BaseChains.Unregister("alarmBase")
+	assert.Nil(t, BaseChains.Register(RuleChain{RuleChain: RuleChainBaseInfo{ID: "loop", Extends: "loop"}}))
+	defer BaseChains.Unregister("loop")
+	assert.NotNil(t, BaseChains.Register(RuleChain{}))
+
+	for _, item := range []struct {
+		def RuleChain
+		err string
+	}{
+		{RuleChain{RuleChain: RuleChainBaseInfo{Extends: "unknown"}}, "base rule chain not found"},
+		{RuleChain{RuleChain: RuleChainBaseInfo{Extends: "loop"}}, "extends exceeds"},
+		{RuleChain{RuleChain: RuleChainBaseInfo{Extends: "alarmBase"}, Metadata: RuleMetadata{RemoveNodes: []string{"s9"}}}, "remove node id=s9"},
+		{RuleChain{RuleChain: RuleChainBaseInfo{Extends: "alarmBase"}, Metadata: RuleMetadata{RemoveNodes: []string{"s1"}}}, "first node id=s1"},
+		{RuleChain{RuleChain: RuleChainBaseInfo{Extends: "alarmBase"}, Metadata: RuleMetadata{Connections: []NodeConnection{{FromId: "s3", ToId: "s9", Type: types.Success}}}}, "node not found"},
+	} {
+		_, err := ResolveExtends(item.def)
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), item.err))
+	}
+}
+
+func TestExtendsRuleChain(t *testing.T) {
+	assert.Nil(t, BaseChains.RegisterDSL([]byte(baseRuleChain)))
+	defer BaseChains.Unregister("alarmBase")
+	ruleEngine, err := New("", []byte(prodRuleChain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Del("alarmProd")
+	assert.Equal(t, "alarmProd", ruleEngine.Id)
+	//导出的DSL保留overlay定义
+	assert.True(t, strings.Contains(string(ruleEngine.DSL()), `"extends": "alarmBase"`))
+
+	send := func(data string) types.RuleMsg {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		var result types.RuleMsg
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), data), func(msg types.RuleMsg, err error) {
+			result = msg
+			wg.Done()
+		})
+		waitTimeout(t, &wg, time.Second*3)
+		return result
+	}
+	msg := send(`{"temperature":60}`)
+	assert.Equal(t, "prod", msg.Metadata.GetValue("env"))
+	msg = send(`{"temperature":20}`)
+	assert.Equal(t, "true", msg.Metadata.GetValue("low"))
+}
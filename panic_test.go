@@ -0,0 +1,119 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// panicTestNode 处理消息时panic的测试组件
+type panicTestNode struct {
+}
+
+func (x *panicTestNode) Type() string {
+	return "test/panic"
+}
+
+func (x *panicTestNode) New() types.Node {
+	return &panicTestNode{}
+}
+
+func (x *panicTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *panicTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	panic("malformed payload")
+}
+
+func (x *panicTestNode) Destroy() {
+}
+
+func TestNodePanicToFailure(t *testing.T) {
+	_ = Registry.Register(&panicTestNode{})
+	defer Registry.Unregister("test/panic")
+
+	ruleEngine, err := NewChainBuilder().Id("panic01").
+		Node("test/panic", nil).On(types.Failure).
+		Node("jsTransform", types.Configuration{"jsScript": "return {'msg':msg,'metadata':metadata,'msgType':'PANIC'};"}).
+		New()
+	assert.Nil(t, err)
+	defer Del("panic01")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var endMsg types.RuleMsg
+	var endErr error
+	ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+		endMsg = msg
+		endErr = err
+		wg.Done()
+	})
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Nil(t, endErr)
+	assert.Equal(t, "PANIC", endMsg.Type)
+	assert.Equal(t, "s1", endMsg.Metadata.GetValue(types.PanicNodeKey))
+	assert.Equal(t, "malformed payload", endMsg.Metadata.GetValue(types.PanicErrorKey))
+	assert.True(t, strings.Contains(endMsg.Metadata.GetValue(types.PanicStackKey).(string), "panicTestNode"))
+	assert.Equal(t, uint64(1), ruleEngine.PanicCount())
+}
+
+func TestNodePanicWithOnPanic(t *testing.T) {
+	_ = Registry.Register(&panicTestNode{})
+	defer Registry.Unregister("test/panic")
+
+	var poisoned []*types.PanicError
+	var lock sync.Mutex
+	config := NewConfig(types.WithOnPanic(func(msg types.RuleMsg, err *types.PanicError) {
+		lock.Lock()
+		defer lock.Unlock()
+		poisoned = append(poisoned, err)
+	}))
+	ruleEngine, err := NewChainBuilder().Id("panic02").
+		Node("test/panic", nil).On(types.Failure).
+		Node("jsTransform", types.Configuration{"jsScript": "return {'msg':msg,'metadata':metadata,'msgType':'PANIC'};"}).
+		New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("panic02")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	var endErr error
+	for i := 0; i < 2; i++ {
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+			lock.Lock()
+			endErr = err
+			lock.Unlock()
+			wg.Done()
+		})
+	}
+	waitTimeout(t, &wg, time.Second*3)
+	lock.Lock()
+	defer lock.Unlock()
+	var panicErr *types.PanicError
+	assert.True(t, errors.As(endErr, &panicErr))
+	assert.Equal(t, "test/panic", panicErr.NodeType)
+	assert.Equal(t, 2, len(poisoned))
+	assert.Equal(t, "node s1(test/panic) panic: malformed payload", poisoned[0].Error())
+	assert.Equal(t, uint64(2), ruleEngine.PanicCount())
+}
@@ -17,15 +17,21 @@
 package rulego
 
 import (
+	"errors"
 	"github.com/2018yuli/rulego/api/types"
 	string2 "github.com/2018yuli/rulego/utils/json"
+	"github.com/2018yuli/rulego/utils/sign"
 )
 
 // JsonParser Json
 type JsonParser struct {
 }
 
+// DecodeRuleChain 解析规则链，配置了信任的签名公钥则先校验签名
 func (p *JsonParser) DecodeRuleChain(config types.Config, dsl []byte) (types.Node, error) {
+	if err := verifySignature(config, dsl); err != nil {
+		return nil, err
+	}
 	if rootRuleChainDef, err := ParserRuleChain(dsl); err == nil {
 		//初始化
 		return InitRuleChainCtx(config, &rootRuleChainDef)
@@ -33,7 +39,12 @@ func (p *JsonParser) DecodeRuleChain(config types.Config, dsl []byte) (types.Nod
 		return nil, err
 	}
 }
+
+// DecodeRuleNode 解析节点，配置了信任的签名公钥则先校验签名
 func (p *JsonParser) DecodeRuleNode(config types.Config, dsl []byte) (types.Node, error) {
+	if err := verifySignature(config, dsl); err != nil {
+		return nil, err
+	}
 	if node, err := ParserRuleNode(dsl); err == nil {
 		return InitRuleNodeCtx(config, &node)
 	} else {
@@ -48,3 +59,15 @@ func (p *JsonParser) EncodeRuleNode(def interface{}) ([]byte, error) {
 	//缩进符为两个空格
 	return string2.MarshalIndent(def, "", "  ")
 }
+
+// ErrUnsignedReference 配置了信任的签名公钥时，规则链使用了$ref引用外部文件或者extends继承基础规则链
+// 签名只覆盖DSL本身，引用的内容没有被签名，因此拒绝加载
+var ErrUnsignedReference = errors.New("$ref and extends are not covered by the signature")
+
+// verifySignature 配置了信任的签名公钥则校验DSL签名
+func verifySignature(config types.Config, dsl []byte) error {
+	if len(config.TrustedKeys) == 0 {
+		return nil
+	}
+	return sign.Verify(dsl, config.TrustedKeys)
+}
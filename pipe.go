@@ -0,0 +1,114 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"sync"
+)
+
+// defaultPipeMaxInFlight 管道每个阶段默认最多同时处理的消息数
+const defaultPipeMaxInFlight = 1024
+
+// ErrChainNotFound 规则引擎实例池中不存在该规则链
+var ErrChainNotFound = errors.New("rule chain not found")
+
+// Pipeline 规则链管道，把一个规则链的输出交给下一个规则链处理，用于把多个小的可复用规则链组合成大的应用
+// 每个阶段最多同时处理MaxInFlight条消息，下一阶段满了则阻塞上一阶段的结束回调，直到阻塞OnMsg，实现背压
+// 规则链有多个结束点时，每个结束点的输出都交给下一阶段处理；任意阶段处理失败则结束，不再交给下一阶段
+type Pipeline struct {
+	ruleGo   *RuleGo
+	chainIds []string
+	stages   []chan struct{}
+}
+
+// Pipe 使用默认规则引擎实例池创建规则链管道，例如：rulego.Pipe("ingest", "enrich", "store")
+func Pipe(chainIds ...string) (*Pipeline, error) {
+	return DefaultRuleGo.Pipe(chainIds...)
+}
+
+// Pipe 创建规则链管道，规则链需要已经加载到规则引擎实例池
+// 每条消息处理时按ID获取规则引擎实例，因此规则链更新后管道使用新的规则链
+func (g *RuleGo) Pipe(chainIds ...string) (*Pipeline, error) {
+	if len(chainIds) == 0 {
+		return nil, errors.New("pipe requires at least one chain")
+	}
+	for _, chainId := range chainIds {
+		if _, ok := g.Get(chainId); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrChainNotFound, chainId)
+		}
+	}
+	p := &Pipeline{ruleGo: g, chainIds: chainIds}
+	return p.MaxInFlight(defaultPipeMaxInFlight), nil
+}
+
+// MaxInFlight 设置每个阶段最多同时处理的消息数，默认1024，需要在处理消息前设置
+func (p *Pipeline) MaxInFlight(n int) *Pipeline {
+	if n <= 0 {
+		n = defaultPipeMaxInFlight
+	}
+	p.stages = make([]chan struct{}, len(p.chainIds))
+	for i := range p.stages {
+		p.stages[i] = make(chan struct{}, n)
+	}
+	return p
+}
+
+// ChainIds 管道的规则链ID列表
+func (p *Pipeline) ChainIds() []string {
+	return p.chainIds
+}
+
+// OnMsg 把消息交给管道处理，第一阶段满了则阻塞
+func (p *Pipeline) OnMsg(msg types.RuleMsg) {
+	p.OnMsgWithEndFunc(msg, nil)
+}
+
+// OnMsgWithEndFunc 把消息交给管道处理，第一阶段满了则阻塞
+// endFunc 最后一个规则链处理结束或者任意阶段处理失败的回调。注意：如果规则链有多个结束点，回调函数则会执行多次
+func (p *Pipeline) OnMsgWithEndFunc(msg types.RuleMsg, endFunc func(msg types.RuleMsg, err error)) {
+	p.tell(0, msg, endFunc)
+}
+
+// tell 把消息交给指定阶段的规则链处理
+func (p *Pipeline) tell(stage int, msg types.RuleMsg, endFunc func(msg types.RuleMsg, err error)) {
+	ruleEngine, ok := p.ruleGo.Get(p.chainIds[stage])
+	if !ok || !ruleEngine.Initialized() {
+		if endFunc != nil {
+			endFunc(msg, fmt.Errorf("%w: %s", ErrChainNotFound, p.chainIds[stage]))
+		}
+		return
+	}
+	//背压
+	p.stages[stage] <- struct{}{}
+	var once sync.Once
+	ruleEngine.OnMsgWithEndFunc(msg, func(out types.RuleMsg, err error) {
+		//第一个结束点释放
+		once.Do(func() {
+			<-p.stages[stage]
+		})
+		if err != nil || stage == len(p.chainIds)-1 {
+			if endFunc != nil {
+				endFunc(out, err)
+			}
+			return
+		}
+		p.tell(stage+1, out, endFunc)
+	})
+}
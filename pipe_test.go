@@ -0,0 +1,180 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateTestNode 等待release后才处理完成的测试组件
+type gateTestNode struct {
+	release chan struct{}
+}
+
+func (x *gateTestNode) Type() string {
+	return "test/gate"
+}
+
+func (x *gateTestNode) New() types.Node {
+	return x
+}
+
+func (x *gateTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *gateTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	<-x.release
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *gateTestNode) Destroy() {
+}
+
+func TestPipe(t *testing.T) {
+	ruleGo := &RuleGo{}
+	defer ruleGo.Stop()
+	for _, item := range []struct{ id, script string }{
+		{"ingest", "msg.stages=['ingest'];return {'msg':msg,'metadata':metadata,'msgType':msgType};"},
+		{"enrich", "msg.stages=msg.stages.concat(['enrich']);metadata.enriched='true';return {'msg':msg,'metadata':metadata,'msgType':msgType};"},
+		{"store", "msg.stages=msg.stages.concat(['store']);return {'msg':msg,'metadata':metadata,'msgType':'STORED'};"},
+	} {
+		def, err := NewChainBuilder().Id(item.id).Node("jsTransform", types.Configuration{"jsScript": item.script}).DSL()
+		assert.Nil(t, err)
+		_, err = ruleGo.New(item.id, def)
+		assert.Nil(t, err)
+	}
+	_, err := ruleGo.Pipe("ingest", "unknown")
+	assert.True(t, errors.Is(err, ErrChainNotFound))
+
+	pipeline, err := ruleGo.Pipe("ingest", "enrich", "store")
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var endMsg types.RuleMsg
+	var endErr error
+	pipeline.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+		endMsg = msg
+		endErr = err
+		wg.Done()
+	})
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Nil(t, endErr)
+	assert.Equal(t, "STORED", endMsg.Type)
+	assert.Equal(t, `{"stages":["ingest","enrich","store"]}`, endMsg.Data)
+	assert.Equal(t, "true", endMsg.Metadata.GetValue("enriched"))
+
+	//规则链被删除
+	ruleGo.Del("store")
+	wg.Add(1)
+	pipeline.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+		endErr = err
+		wg.Done()
+	})
+	waitTimeout(t, &wg, time.Second*3)
+	assert.True(t, errors.Is(endErr, ErrChainNotFound))
+}
+
+func TestPipeBackpressure(t *testing.T) {
+	node := &gateTestNode{release: make(chan struct{})}
+	_ = Registry.Register(node)
+	defer Registry.Unregister("test/gate")
+	ruleGo := &RuleGo{}
+	defer ruleGo.Stop()
+	def, err := NewChainBuilder().Id("gate").Node("test/gate", nil).DSL()
+	assert.Nil(t, err)
+	_, err = ruleGo.New("gate", def)
+	assert.Nil(t, err)
+
+	pipeline, err := ruleGo.Pipe("gate")
+	assert.Nil(t, err)
+	pipeline.MaxInFlight(1)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	pipeline.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+		wg.Done()
+	})
+	sent := make(chan struct{})
+	go func() {
+		pipeline.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+			wg.Done()
+		})
+		close(sent)
+	}()
+	//第一条消息没有处理完成，第二条消息阻塞
+	select {
+	case <-sent:
+		t.Fatal("second message not blocked")
+	case <-time.After(time.Millisecond * 100):
+	}
+	node.release <- struct{}{}
+	select {
+	case <-sent:
+	case <-time.After(time.Second * 3):
+		t.Fatal("second message still blocked")
+	}
+	node.release <- struct{}{}
+	waitTimeout(t, &wg, time.Second*3)
+}
+
+func TestExecuteChain(t *testing.T) {
+	var lock sync.Mutex
+	var received []string
+	config := NewConfig()
+	for _, id := range []string{"mirrorA", "mirrorB"} {
+		chainId := id
+		def, err := NewChainBuilder().Id(chainId).Node("jsTransform", types.Configuration{
+			"jsScript": "return {'msg':msg,'metadata':metadata,'msgType':msgType};"}).DSL()
+		assert.Nil(t, err)
+		_, err = New(chainId, def, WithConfig(NewConfig(types.WithOnEnd(func(msg types.RuleMsg, err error) {
+			lock.Lock()
+			received = append(received, chainId)
+			lock.Unlock()
+		}))))
+		assert.Nil(t, err)
+		defer Del(chainId)
+	}
+	err := config.ChainExecutor("unknown", types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), nil)
+	assert.True(t, errors.Is(err, ErrChainNotFound))
+
+	ruleEngine, err := NewChainBuilder().Id("mirror01").Node("fanOut", types.Configuration{"targets": []interface{}{
+		map[string]interface{}{"chain": "mirrorA"},
+		map[string]interface{}{"chain": "mirrorB"},
+	}}).New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("mirror01")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var endErr error
+	ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), func(msg types.RuleMsg, err error) {
+		endErr = err
+		wg.Done()
+	})
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Nil(t, endErr)
+	assert.True(t, waitFor(func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(received) == 2
+	}))
+}
@@ -104,6 +104,20 @@ func (wp *WorkerPool) Stop() {
 func (wp *WorkerPool) Release() {
 	wp.Stop()
 }
+// Tune 运行时修改最大协程数，超出的空闲协程在空闲超时后回收
+func (wp *WorkerPool) Tune(size int) {
+	wp.lock.Lock()
+	wp.MaxWorkersCount = size
+	wp.lock.Unlock()
+}
+
+// Cap 最大协程数
+func (wp *WorkerPool) Cap() int {
+	wp.lock.Lock()
+	defer wp.lock.Unlock()
+	return wp.MaxWorkersCount
+}
+
 func (wp *WorkerPool) getMaxIdleWorkerDuration() time.Duration {
 	if wp.MaxIdleWorkerDuration <= 0 {
 		return 10 * time.Second
@@ -0,0 +1,33 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/profile"
+)
+
+// pushProfileFrame 返回当前调用栈加上下一个节点的新调用栈，不修改当前调用栈，同一个节点的多个子节点共享当前调用栈
+func (ctx *DefaultRuleContext) pushProfileFrame(node types.NodeCtx) []profile.Frame {
+	var chainId string
+	if ctx.ruleChainCtx != nil {
+		chainId = ctx.ruleChainCtx.Id.Id
+	}
+	stack := make([]profile.Frame, len(ctx.profileStack), len(ctx.profileStack)+1)
+	copy(stack, ctx.profileStack)
+	return append(stack, profile.Frame{ChainId: chainId, NodeId: node.GetNodeId().Id, NodeType: node.Type()})
+}
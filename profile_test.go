@@ -0,0 +1,71 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/profile"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProfiler(t *testing.T) {
+	profiler := profile.New(0)
+	config := NewConfig(types.WithProfiler(profiler))
+	ruleEngine, err := NewChainBuilder().Id("profileChain").
+		Node("jsFilter", types.Configuration{"jsScript": "return msg.temperature > 50;"}).On(types.True).
+		Node("jsTransform", types.Configuration{"jsScript": "return {'msg':msg,'metadata':metadata,'msgType':msgType};"}).
+		New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("profileChain")
+
+	for _, data := range []string{`{"temperature":60}`, `{"temperature":20}`, `{"temperature":70}`} {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), data), func(msg types.RuleMsg, err error) {
+			wg.Done()
+		})
+		waitTimeout(t, &wg, time.Second*3)
+	}
+	//结束回调在最后一个节点的OnMsg中执行，等待最后一个节点记录执行时间
+	var report profile.Report
+	for i := 0; i < 100; i++ {
+		if report = profiler.Report(); len(report.Nodes) == 2 && report.Nodes[0].Count+report.Nodes[1].Count == 5 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	assert.Equal(t, 2, len(report.Stacks))
+	counts := make(map[string]int64)
+	for _, item := range report.Stacks {
+		counts[item.Stack] = item.Count
+	}
+	assert.Equal(t, map[string]int64{"profileChain:s1": 3, "profileChain:s1;profileChain:s2": 2}, counts)
+
+	nodes := make(map[string]profile.NodeProfile)
+	for _, item := range report.Nodes {
+		nodes[item.NodeId] = item
+	}
+	assert.Equal(t, "jsFilter", nodes["s1"].NodeType)
+	assert.Equal(t, int64(3), nodes["s1"].Count)
+	assert.Equal(t, int64(2), nodes["s2"].Count)
+	//s1的总时间包括s2的执行时间
+	assert.Equal(t, nodes["s1"].Exclusive+nodes["s2"].Exclusive, nodes["s1"].Inclusive)
+	assert.Equal(t, report.Total, nodes["s1"].Inclusive)
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/quarantine"
+)
+
+// ErrQuarantineNotConfigured 没有配置`types.Config.Quarantine`
+var ErrQuarantineNotConfigured = errors.New("quarantine not configured")
+
+// quarantineEndFunc 包装结束回调，规则链处理失败则记录一次失败，失败次数达到限制则隔离该消息
+func (e *RuleEngine) quarantineEndFunc(q *quarantine.Manager, msg types.RuleMsg, onEnd func(msg types.RuleMsg, err error)) func(msg types.RuleMsg, err error) {
+	//保存原始消息，用于重放
+	origin := toQuarantineMessage(msg)
+	return func(endMsg types.RuleMsg, err error) {
+		if err == nil {
+			q.Succeed(e.Id, origin.Id)
+		} else if ok, putErr := q.Fail(e.Id, origin, err); putErr != nil {
+			e.Config.Logger.Printf("quarantine message %s error: %s", origin.Id, putErr)
+		} else if ok {
+			e.Config.Logger.Printf("message %s quarantined, chain: %s error: %s", origin.Id, e.Id, err)
+		}
+		if onEnd != nil {
+			onEnd(endMsg, err)
+		}
+	}
+}
+
+// Quarantined 获取规则链隔离区的消息
+func (e *RuleEngine) Quarantined() ([]quarantine.Entry, error) {
+	if e.Config.Quarantine == nil {
+		return nil, ErrQuarantineNotConfigured
+	}
+	return e.Config.Quarantine.Store().List(e.Id)
+}
+
+// Replay 从隔离区移除消息并重新交给规则链处理，重放的消息重新开始计算失败次数
+func (e *RuleEngine) Replay(msgId string, opts ...types.RuleContextOption) error {
+	if e.Config.Quarantine == nil {
+		return ErrQuarantineNotConfigured
+	}
+	entry, err := e.Config.Quarantine.Release(e.Id, msgId)
+	if err != nil {
+		return err
+	}
+	e.OnMsgWithOptions(fromQuarantineMessage(entry.Msg), opts...)
+	return nil
+}
+
+// Discard 从隔离区删除消息，不再处理
+func (e *RuleEngine) Discard(msgId string) error {
+	if e.Config.Quarantine == nil {
+		return ErrQuarantineNotConfigured
+	}
+	_, err := e.Config.Quarantine.Release(e.Id, msgId)
+	return err
+}
+
+func toQuarantineMessage(msg types.RuleMsg) quarantine.Message {
+	return quarantine.Message{
+		Ts:       msg.Ts,
+		Id:       msg.Id,
+		DataType: string(msg.DataType),
+		Type:     msg.Type,
+		Data:     msg.Data,
+		Metadata: msg.Metadata.Values(),
+	}
+}
+
+func fromQuarantineMessage(msg quarantine.Message) types.RuleMsg {
+	return types.RuleMsg{
+		Ts:       msg.Ts,
+		Id:       msg.Id,
+		DataType: types.DataType(msg.DataType),
+		Type:     msg.Type,
+		Data:     msg.Data,
+		Metadata: types.BuildMetadata(msg.Metadata),
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/quarantine"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuarantine(t *testing.T) {
+	_ = Registry.Register(&panicTestNode{})
+	defer Registry.Unregister("test/panic")
+
+	manager := quarantine.NewManager(quarantine.Config{MaxFailures: 2})
+	config := NewConfig(types.WithQuarantine(manager))
+	ruleEngine, err := NewChainBuilder().Id("quarantine01").Node("test/panic", nil).New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("quarantine01")
+
+	metadata := types.NewMetadata()
+	metadata.PutValue("deviceId", "d1")
+	msg := types.NewMsg(0, "TEST", types.JSON, metadata, "{")
+	send := func(msg types.RuleMsg) error {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		var endErr error
+		ruleEngine.OnMsgWithEndFunc(msg, func(msg types.RuleMsg, err error) {
+			endErr = err
+			wg.Done()
+		})
+		waitTimeout(t, &wg, time.Second*3)
+		return endErr
+	}
+
+	//重新投递相同消息，失败次数达到限制后隔离
+	assert.NotNil(t, send(msg))
+	assert.NotNil(t, send(msg))
+	assert.Equal(t, uint64(2), ruleEngine.PanicCount())
+	assert.Equal(t, quarantine.ErrQuarantined, send(msg))
+	assert.Equal(t, uint64(2), ruleEngine.PanicCount())
+
+	entries, err := ruleEngine.Quarantined()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, msg.Id, entries[0].Msg.Id)
+	assert.Equal(t, 2, entries[0].Failures)
+	assert.Equal(t, "node s1(test/panic) panic: malformed payload", entries[0].Error)
+	assert.Equal(t, "d1", entries[0].Msg.Metadata["deviceId"])
+
+	//重放的消息重新执行
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var replayed types.RuleMsg
+	assert.Nil(t, ruleEngine.Replay(msg.Id, types.WithEndFunc(func(msg types.RuleMsg, err error) {
+		replayed = msg
+		wg.Done()
+	})))
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Equal(t, msg.Id, replayed.Id)
+	assert.Equal(t, "{", replayed.Data)
+	assert.Equal(t, uint64(3), ruleEngine.PanicCount())
+	assert.Equal(t, quarantine.ErrNotFound, ruleEngine.Replay(msg.Id))
+
+	//其他消息不受影响
+	assert.False(t, manager.Quarantined("quarantine01", types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}").Id))
+	assert.Equal(t, quarantine.ErrNotFound, ruleEngine.Discard("unknown"))
+}
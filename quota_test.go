@@ -0,0 +1,77 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/2018yuli/rulego/utils/quota"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuotaRejectAndThrottle(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	manager := quota.NewManager(vc)
+	manager.SetQuota("quotaChain", quota.Config{
+		Soft:          quota.Limit{Executions: 2},
+		Hard:          quota.Limit{Executions: 2},
+		ThrottleDelay: time.Second,
+	})
+	config := NewConfig(types.WithClock(vc), types.WithQuota(manager))
+	ruleEngine, err := NewChainBuilder().Id("quotaChain").
+		Node("jsFilter", types.Configuration{"jsScript": "return true;"}).
+		New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("quotaChain")
+
+	var wg sync.WaitGroup
+	var errs []error
+	var lock sync.Mutex
+	endFunc := func(msg types.RuleMsg, err error) {
+		lock.Lock()
+		errs = append(errs, err)
+		lock.Unlock()
+		wg.Done()
+	}
+	msg := types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}")
+
+	wg.Add(1)
+	ruleEngine.OnMsgWithEndFunc(msg, endFunc)
+	waitTimeout(t, &wg, time.Second*3)
+
+	//超过软限制，延迟执行
+	wg.Add(1)
+	ruleEngine.OnMsgWithEndFunc(msg, endFunc)
+	assert.Equal(t, 1, vc.Pending())
+	vc.Advance(time.Second)
+	waitTimeout(t, &wg, time.Second*3)
+
+	//超过硬限制，拒绝执行
+	wg.Add(1)
+	ruleEngine.OnMsgWithEndFunc(msg, endFunc)
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Equal(t, []error{nil, nil, quota.ErrQuotaExceeded}, errs)
+
+	usage, ok := ruleEngine.Usage()
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), usage.Executions)
+	assert.Equal(t, int64(1), usage.Throttled)
+	assert.Equal(t, int64(1), usage.Rejected)
+}
@@ -23,13 +23,21 @@ import (
 	"github.com/2018yuli/rulego/components/action"
 	"github.com/2018yuli/rulego/components/filter"
 	"github.com/2018yuli/rulego/components/transform"
+	"github.com/2018yuli/rulego/utils/secrets"
+	"path"
 	"plugin"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 )
 
 // PluginsSymbol 插件检查点 Symbol
 const PluginsSymbol = "Plugins"
 
+// PluginCategory 没有提供分类的插件组件默认分类
+const PluginCategory = "plugin"
+
 // Registry 规则引擎组件默认注册器
 var Registry = new(RuleComponentRegistry)
 
@@ -52,6 +60,8 @@ type RuleComponentRegistry struct {
 	components map[string]types.Node
 	//插件列表
 	plugins map[string][]types.Node
+	//组件描述信息
+	descriptors map[string]types.ComponentDescriptor
 	sync.RWMutex
 }
 
@@ -66,7 +76,10 @@ func (r *RuleComponentRegistry) Register(node types.Node) error {
 		return errors.New("the component already exists. nodeType=" + node.Type())
 	}
 	r.components[node.Type()] = node
-
+	if r.descriptors == nil {
+		r.descriptors = make(map[string]types.ComponentDescriptor)
+	}
+	r.descriptors[node.Type()] = newDescriptor(node)
 	return nil
 }
 
@@ -90,6 +103,12 @@ func (r *RuleComponentRegistry) RegisterPlugin(name string, file string) error {
 
 	r.Lock()
 	defer r.Unlock()
+	for _, node := range components {
+		if descriptor := r.descriptors[node.Type()]; descriptor.Category == "" {
+			descriptor.Category = PluginCategory
+			r.descriptors[node.Type()] = descriptor
+		}
+	}
 	if r.plugins == nil {
 		r.plugins = make(map[string][]types.Node)
 	}
@@ -106,6 +125,7 @@ func (r *RuleComponentRegistry) Unregister(componentType string) error {
 		for _, node := range nodes {
 			// Delete the plugin from the map
 			delete(r.components, node.Type())
+			delete(r.descriptors, node.Type())
 		}
 		delete(r.plugins, componentType)
 		removed = true
@@ -115,6 +135,7 @@ func (r *RuleComponentRegistry) Unregister(componentType string) error {
 	if _, ok := r.components[componentType]; ok {
 		// Delete the plugin from the map
 		delete(r.components, componentType)
+		delete(r.descriptors, componentType)
 		removed = true
 	}
 
@@ -147,6 +168,119 @@ func (r *RuleComponentRegistry) GetComponents() map[string]types.Node {
 	return components
 }
 
+// Describe 设置或者覆盖已注册组件的描述信息
+// 分类、敏感字段为空则保留原值
+func (r *RuleComponentRegistry) Describe(descriptor types.ComponentDescriptor) error {
+	r.Lock()
+	defer r.Unlock()
+	old, ok := r.descriptors[descriptor.Type]
+	if !ok {
+		return fmt.Errorf("component not found.componentType=%s", descriptor.Type)
+	}
+	if descriptor.Category == "" {
+		descriptor.Category = old.Category
+	}
+	if descriptor.SensitiveFields == nil {
+		descriptor.SensitiveFields = old.SensitiveFields
+	}
+	r.descriptors[descriptor.Type] = descriptor
+	return nil
+}
+
+// GetDescriptor 获取组件描述信息
+func (r *RuleComponentRegistry) GetDescriptor(nodeType string) (types.ComponentDescriptor, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	descriptor, ok := r.descriptors[nodeType]
+	return descriptor, ok
+}
+
+// Query 按分类、标签或者关键字查询组件，结果按分类和类型排序
+func (r *RuleComponentRegistry) Query(query types.ComponentQuery) []types.ComponentDescriptor {
+	r.RLock()
+	defer r.RUnlock()
+	keyword := strings.ToLower(query.Keyword)
+	var result []types.ComponentDescriptor
+	for _, descriptor := range r.descriptors {
+		if query.Category != "" && descriptor.Category != query.Category {
+			continue
+		}
+		if query.Tag != "" && !containsString(descriptor.Tags, query.Tag) {
+			continue
+		}
+		if keyword != "" && !matchKeyword(descriptor, keyword) {
+			continue
+		}
+		result = append(result, descriptor)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Category != result[j].Category {
+			return result[i].Category < result[j].Category
+		}
+		return result[i].Type < result[j].Type
+	})
+	return result
+}
+
+// Categories 获取所有组件分类
+func (r *RuleComponentRegistry) Categories() []string {
+	r.RLock()
+	defer r.RUnlock()
+	var categories []string
+	for _, descriptor := range r.descriptors {
+		if !containsString(categories, descriptor.Category) {
+			categories = append(categories, descriptor.Category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// newDescriptor 获取组件描述信息，如果组件没提供分类，则使用组件所在包名
+func newDescriptor(node types.Node) types.ComponentDescriptor {
+	var descriptor types.ComponentDescriptor
+	if describer, ok := node.(types.ComponentDescriber); ok {
+		descriptor = describer.Descriptor()
+	}
+	descriptor.Type = node.Type()
+	if descriptor.SensitiveFields == nil {
+		descriptor.SensitiveFields = secrets.SensitiveFields(node)
+	}
+	if descriptor.Category == "" {
+		t := reflect.TypeOf(node)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if pkgPath := t.PkgPath(); pkgPath != "" && pkgPath != "main" {
+			descriptor.Category = path.Base(pkgPath)
+		}
+	}
+	return descriptor
+}
+
+func matchKeyword(descriptor types.ComponentDescriptor, keyword string) bool {
+	if strings.Contains(strings.ToLower(descriptor.Type), keyword) ||
+		strings.Contains(strings.ToLower(descriptor.Category), keyword) ||
+		strings.Contains(strings.ToLower(descriptor.Description), keyword) {
+		return true
+	}
+	for _, tag := range descriptor.Tags {
+		if strings.Contains(strings.ToLower(tag), keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(items []string, item string) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
 // PluginComponentRegistry go plugin组件初始化器
 type PluginComponentRegistry struct {
 	name     string
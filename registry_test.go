@@ -0,0 +1,103 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/components/action"
+	"github.com/2018yuli/rulego/components/filter"
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+)
+
+// describedNode 提供描述信息的测试组件
+type describedNode struct {
+	action.LogNode
+}
+
+func (x *describedNode) Type() string {
+	return "describedNode"
+}
+
+func (x *describedNode) New() types.Node {
+	return &describedNode{}
+}
+
+func (x *describedNode) Descriptor() types.ComponentDescriptor {
+	return types.ComponentDescriptor{Category: "custom", Tags: []string{"test"}, Description: "Test component"}
+}
+
+func TestRegistryDescriptor(t *testing.T) {
+	registry := new(RuleComponentRegistry)
+	assert.Nil(t, registry.Register(&filter.JsFilterNode{}))
+	assert.Nil(t, registry.Register(&filter.MsgTypeSwitchNode{}))
+	assert.Nil(t, registry.Register(&action.RestApiCallNode{}))
+	assert.Nil(t, registry.Register(&describedNode{}))
+
+	descriptor, ok := registry.GetDescriptor("jsFilter")
+	assert.True(t, ok)
+	assert.Equal(t, "jsFilter", descriptor.Type)
+	assert.Equal(t, "filter", descriptor.Category)
+	assert.True(t, len(descriptor.Description) > 0)
+
+	descriptor, ok = registry.GetDescriptor("describedNode")
+	assert.True(t, ok)
+	assert.Equal(t, "custom", descriptor.Category)
+
+	assert.Equal(t, []string{"action", "custom", "filter"}, registry.Categories())
+
+	result := registry.Query(types.ComponentQuery{Category: "filter"})
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "jsFilter", result[0].Type)
+	assert.Equal(t, "msgTypeSwitch", result[1].Type)
+
+	result = registry.Query(types.ComponentQuery{Tag: "http"})
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "restApiCall", result[0].Type)
+
+	result = registry.Query(types.ComponentQuery{Keyword: "TEST COMP"})
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "describedNode", result[0].Type)
+
+	result = registry.Query(types.ComponentQuery{Category: "filter", Keyword: "switch"})
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "msgTypeSwitch", result[0].Type)
+
+	assert.Equal(t, 4, len(registry.Query(types.ComponentQuery{})))
+
+	//覆盖描述信息，保留分类
+	assert.Nil(t, registry.Describe(types.ComponentDescriptor{Type: "restApiCall", Tags: []string{"webhook"}}))
+	descriptor, _ = registry.GetDescriptor("restApiCall")
+	assert.Equal(t, "action", descriptor.Category)
+	assert.Equal(t, []string{"webhook"}, descriptor.Tags)
+	assert.NotNil(t, registry.Describe(types.ComponentDescriptor{Type: "notFound"}))
+
+	assert.Nil(t, registry.Unregister("describedNode"))
+	_, ok = registry.GetDescriptor("describedNode")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"action", "filter"}, registry.Categories())
+}
+
+// TestDefaultRegistryDescriptor 默认注册器的组件都有描述信息
+func TestDefaultRegistryDescriptor(t *testing.T) {
+	for nodeType := range Registry.GetComponents() {
+		descriptor, ok := Registry.GetDescriptor(nodeType)
+		assert.True(t, ok)
+		assert.True(t, descriptor.Category != "")
+		assert.True(t, descriptor.Description != "")
+	}
+}
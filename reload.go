@@ -0,0 +1,96 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultReloadBufferSize 规则链重新加载期间默认最多暂存的消息数
+const defaultReloadBufferSize = 1024
+
+// ErrReloadBufferFull 规则链重新加载期间暂存的消息数超过`Config.ReloadBufferSize`
+var ErrReloadBufferFull = errors.New("reload buffer full")
+
+// reloadGate 规则链蓝绿切换
+// 重新加载期间暂存输入的消息，新规则链及其子规则链全部初始化完成并切换后，再按顺序交给新规则链处理，
+// 保证消息不会被初始化一半的规则链处理
+type reloadGate struct {
+	//serial 串行执行重新加载，避免后一次重新加载提前结束暂存
+	serial sync.Mutex
+	//lock 保护pending和规则链切换，消息处理路径只加读锁
+	lock sync.RWMutex
+	//reloading 1:正在重新加载，原子读写
+	reloading int32
+	pending   []func()
+}
+
+// begin 开始重新加载，之后输入的消息暂存
+func (g *reloadGate) begin() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	atomic.StoreInt32(&g.reloading, 1)
+}
+
+// isReloading 是否正在重新加载
+func (g *reloadGate) isReloading() bool {
+	return atomic.LoadInt32(&g.reloading) == 1
+}
+
+// end 结束重新加载，swap在锁内执行，用于切换规则链，然后按顺序释放暂存的消息
+func (g *reloadGate) end(swap func()) {
+	g.lock.Lock()
+	swap()
+	atomic.StoreInt32(&g.reloading, 0)
+	pending := g.pending
+	g.pending = nil
+	g.lock.Unlock()
+	for _, f := range pending {
+		f()
+	}
+}
+
+// hold 正在重新加载则暂存消息并返回true，否则在锁内执行read读取当前规则链并返回false
+// 暂存的消息超过size返回ErrReloadBufferFull
+func (g *reloadGate) hold(size int, f func(), read func()) (bool, error) {
+	if !g.isReloading() {
+		g.lock.RLock()
+		//切换在写锁内执行，读锁内再次确认
+		if !g.isReloading() {
+			read()
+			g.lock.RUnlock()
+			return false, nil
+		}
+		g.lock.RUnlock()
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if !g.isReloading() {
+		read()
+		return false, nil
+	}
+	if size <= 0 {
+		size = defaultReloadBufferSize
+	}
+	if len(g.pending) >= size {
+		return true, ErrReloadBufferFull
+	}
+	g.pending = append(g.pending, f)
+	return true, nil
+}
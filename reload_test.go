@@ -0,0 +1,209 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/str"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reloadTestRelease 不为空时，配置了block的reloadTestNode初始化等待release关闭
+var reloadTestRelease chan struct{}
+
+// reloadTestNode 测试蓝绿切换的节点，把version写到元数据
+type reloadTestNode struct {
+	Config struct {
+		Version string
+		Block   bool
+		Fail    bool
+	}
+}
+
+func (x *reloadTestNode) Type() string {
+	return "test/reload"
+}
+
+func (x *reloadTestNode) New() types.Node {
+	return &reloadTestNode{}
+}
+
+func (x *reloadTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	x.Config.Version, _ = configuration["version"].(string)
+	x.Config.Block, _ = configuration["block"].(bool)
+	x.Config.Fail, _ = configuration["fail"].(bool)
+	if x.Config.Block && reloadTestRelease != nil {
+		<-reloadTestRelease
+	}
+	if x.Config.Fail {
+		return errors.New("init failed")
+	}
+	return nil
+}
+
+func (x *reloadTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	msg.Metadata.PutValue("version", x.Config.Version)
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *reloadTestNode) Destroy() {
+}
+
+func reloadTestDSL(t *testing.T, configuration types.Configuration) []byte {
+	def, err := NewChainBuilder().Id("reload01").Node("test/reload", configuration).DSL()
+	assert.Nil(t, err)
+	return def
+}
+
+// waitReloading 等待规则引擎开始重新加载
+func waitReloading(t *testing.T, ruleEngine *RuleEngine) {
+	deadline := time.Now().Add(time.Second * 3)
+	for time.Now().Before(deadline) {
+		if ruleEngine.gate.isReloading() {
+			return
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	t.Fatal("wait reloading timeout")
+}
+
+func TestReloadGate(t *testing.T) {
+	_ = Registry.Register(&reloadTestNode{})
+	defer Registry.Unregister("test/reload")
+	reloadTestRelease = make(chan struct{})
+	defer func() {
+		reloadTestRelease = nil
+	}()
+
+	config := NewConfig(types.WithReloadBufferSize(2))
+	ruleEngine, err := newRuleEngine("reload01", reloadTestDSL(t, types.Configuration{"version": "v1"}), WithConfig(config))
+	assert.Nil(t, err)
+	defer ruleEngine.Stop()
+
+	var lock sync.Mutex
+	var versions []string
+	var errs []error
+	var wg sync.WaitGroup
+	onEnd := func(msg types.RuleMsg, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			versions = append(versions, str.ToString(msg.Metadata.GetValue("version")))
+		}
+		wg.Done()
+	}
+
+	reloaded := make(chan error, 1)
+	go func() {
+		reloaded <- ruleEngine.ReloadSelf(reloadTestDSL(t, types.Configuration{"version": "v2", "block": true}))
+	}()
+	waitReloading(t, ruleEngine)
+
+	//新规则链初始化完成前暂存，超过暂存数量拒绝
+	wg.Add(3)
+	for i := 0; i < 3; i++ {
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), onEnd)
+	}
+	time.Sleep(time.Millisecond * 50)
+	lock.Lock()
+	assert.Equal(t, 0, len(versions))
+	assert.Equal(t, 1, len(errs))
+	assert.True(t, errors.Is(errs[0], ErrReloadBufferFull))
+	lock.Unlock()
+
+	close(reloadTestRelease)
+	assert.Nil(t, <-reloaded)
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Equal(t, []string{"v2", "v2"}, versions)
+
+	//加载失败，暂存的消息交给原规则链处理
+	reloadTestRelease = make(chan struct{})
+	versions = nil
+	go func() {
+		reloaded <- ruleEngine.ReloadSelf(reloadTestDSL(t, types.Configuration{"version": "v3", "block": true, "fail": true}))
+	}()
+	waitReloading(t, ruleEngine)
+	wg.Add(1)
+	ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), onEnd)
+	close(reloadTestRelease)
+	assert.NotNil(t, <-reloaded)
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Equal(t, []string{"v2"}, versions)
+}
+
+func TestReloadGateSerial(t *testing.T) {
+	_ = Registry.Register(&reloadTestNode{})
+	defer Registry.Unregister("test/reload")
+	reloadTestRelease = make(chan struct{})
+	defer func() {
+		reloadTestRelease = nil
+	}()
+
+	ruleEngine, err := newRuleEngine("reload02", reloadTestDSL(t, types.Configuration{"version": "v1"}))
+	assert.Nil(t, err)
+	defer ruleEngine.Stop()
+
+	var lock sync.Mutex
+	var versions []string
+	var wg sync.WaitGroup
+	onEnd := func(msg types.RuleMsg, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		versions = append(versions, str.ToString(msg.Metadata.GetValue("version")))
+		wg.Done()
+	}
+
+	first := make(chan error, 1)
+	go func() {
+		first <- ruleEngine.ReloadSelf(reloadTestDSL(t, types.Configuration{"version": "v2", "block": true}))
+	}()
+	waitReloading(t, ruleEngine)
+
+	//第一次重新加载未完成，第二次重新加载等待
+	second := make(chan error, 1)
+	go func() {
+		second <- ruleEngine.ReloadSelf(reloadTestDSL(t, types.Configuration{"version": "v3"}))
+	}()
+	wg.Add(1)
+	ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), onEnd)
+	select {
+	case <-second:
+		t.Fatal("reload not serialized")
+	case <-time.After(time.Millisecond * 50):
+	}
+	lock.Lock()
+	assert.Equal(t, 0, len(versions))
+	lock.Unlock()
+
+	close(reloadTestRelease)
+	assert.Nil(t, <-first)
+	assert.Nil(t, <-second)
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Equal(t, []string{"v2"}, versions)
+
+	wg.Add(1)
+	ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"), onEnd)
+	waitTimeout(t, &wg, time.Second*3)
+	assert.Equal(t, []string{"v2", "v3"}, versions)
+}
@@ -17,7 +17,11 @@
 package rulego
 
 import (
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/audit"
 	"github.com/2018yuli/rulego/utils/fs"
+	"path/filepath"
 	"strings"
 	"sync"
 )
@@ -31,6 +35,7 @@ type RuleGo struct {
 
 // Load 加载指定文件夹及其子文件夹所有规则链配置（与.json结尾文件），到规则引擎实例池
 // 规则链ID，使用规则链文件配置的ruleChain.id
+// 没有配置IncludeFS时，节点配置中$ref引用的文件相对规则链文件所在目录
 func (g *RuleGo) Load(folderPath string, opts ...RuleEngineOption) error {
 	if !strings.HasSuffix(folderPath, "*.json") && !strings.HasSuffix(folderPath, "*.JSON") {
 		if strings.HasSuffix(folderPath, "/") || strings.HasSuffix(folderPath, "\\") {
@@ -48,7 +53,8 @@ func (g *RuleGo) Load(folderPath string, opts ...RuleEngineOption) error {
 	for _, path := range paths {
 		b := fs.LoadFile(path)
 		if b != nil {
-			if _, err = g.New("", b, opts...); err != nil {
+			//$ref引用的文件默认相对规则链文件所在目录
+			if _, err = g.New("", b, append(opts, withIncludeDir(filepath.Dir(path)))...); err != nil {
 				return err
 			}
 		}
@@ -86,14 +92,49 @@ func (g *RuleGo) Get(id string) (*RuleEngine, bool) {
 
 }
 
+// Execute 把消息交给指定ID的规则链处理，onEnd在规则链每个结束点调用，规则链不存在返回`ErrChainNotFound`
+func (g *RuleGo) Execute(chainId string, msg types.RuleMsg, onEnd func(msg types.RuleMsg, err error)) error {
+	ruleEngine, ok := g.Get(chainId)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrChainNotFound, chainId)
+	}
+	ruleEngine.OnMsgWithEndFunc(msg, onEnd)
+	return nil
+}
+
 // Del 删除指定ID规则引擎实例
 func (g *RuleGo) Del(id string) {
+	g.DelBy(id, "")
+}
+
+// DelBy 删除指定ID规则引擎实例，actor为审计记录的操作人
+func (g *RuleGo) DelBy(id string, actor string) {
 	v, ok := g.ruleEngines.Load(id)
 	if ok {
-		v.(*RuleEngine).Stop()
+		ruleEngine := v.(*RuleEngine)
+		before := ruleEngine.auditDSL()
+		ruleEngine.Stop()
 		g.ruleEngines.Delete(id)
+		ruleEngine.actor = actor
+		ruleEngine.audit(audit.ActionDelete, "", before, nil, nil)
 	}
+}
 
+// Range 遍历所有规则引擎实例，f返回false则停止遍历
+func (g *RuleGo) Range(f func(id string, ruleEngine *RuleEngine) bool) {
+	g.ruleEngines.Range(func(key, value any) bool {
+		return f(key.(string), value.(*RuleEngine))
+	})
+}
+
+// Health 获取所有规则引擎实例的节点健康状态，key为规则引擎实例ID
+func (g *RuleGo) Health() map[string][]NodeHealth {
+	result := make(map[string][]NodeHealth)
+	g.Range(func(id string, ruleEngine *RuleEngine) bool {
+		result[id] = ruleEngine.Health()
+		return true
+	})
+	return result
 }
 
 // Stop 释放所有规则引擎实例
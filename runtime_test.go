@@ -0,0 +1,154 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/pool"
+	"github.com/2018yuli/rulego/test/assert"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// runtimeTestLogger 记录输出的日志
+type runtimeTestLogger struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (l *runtimeTestLogger) Printf(format string, v ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestRuntimeConfig(t *testing.T) {
+	runtime := types.NewRuntimeConfig()
+	logger := &runtimeTestLogger{}
+	config := NewConfig(types.WithDefaultPool(), types.WithLogger(logger), types.WithRuntimeConfig(runtime))
+	settings := runtime.Settings()
+	assert.Equal(t, types.LogLevelInfo, settings.LogLevel)
+	assert.Equal(t, float64(1), settings.DebugSampleRate)
+	assert.Equal(t, math.MaxInt32, settings.PoolSize)
+	assert.Equal(t, int64(2000), settings.JsMaxExecutionTime)
+
+	config.Logger.Printf("info")
+	types.Logf(config.Logger, types.LogLevelDebug, "debug")
+	settings.LogLevel = types.LogLevelError
+	settings.PoolSize = 8
+	settings.JsMaxExecutionTime = 100
+	assert.Nil(t, runtime.Update(settings))
+	config.Logger.Printf("info")
+	types.Logf(config.Logger, types.LogLevelError, "error")
+	assert.Equal(t, []string{"info", "error"}, logger.lines)
+	assert.Equal(t, 8, config.Pool.(*pool.WorkerPool).Cap())
+	assert.Equal(t, time.Millisecond*100, config.GetJsMaxExecutionTime())
+
+	//校验失败不修改
+	for _, invalid := range []types.RuntimeSettings{
+		{LogLevel: "trace", DebugSampleRate: 1, PoolSize: 8, JsMaxExecutionTime: 100},
+		{LogLevel: types.LogLevelInfo, DebugSampleRate: 2, PoolSize: 8, JsMaxExecutionTime: 100},
+		{LogLevel: types.LogLevelInfo, DebugSampleRate: 1, PoolSize: -1, JsMaxExecutionTime: 100},
+		{LogLevel: types.LogLevelInfo, DebugSampleRate: 1, PoolSize: 8, JsMaxExecutionTime: 0},
+	} {
+		assert.NotNil(t, runtime.Update(invalid))
+	}
+	assert.Equal(t, settings, runtime.Settings())
+
+	//协程池不支持修改大小
+	runtime = types.NewRuntimeConfig()
+	NewConfig(types.WithRuntimeConfig(runtime))
+	settings = runtime.Settings()
+	settings.PoolSize = 8
+	assert.Equal(t, types.ErrPoolNotTunable, runtime.Update(settings))
+
+	//没有配置Runtime
+	config = NewConfig()
+	assert.Equal(t, time.Millisecond*2000, config.GetJsMaxExecutionTime())
+	assert.True(t, config.Runtime.SampleDebug("msg"))
+	assert.True(t, config.Runtime.LogEnabled(types.LogLevelDebug))
+}
+
+func TestRuntimeConfigWithEngine(t *testing.T) {
+	runtime := types.NewRuntimeConfig()
+	var debugCount int64
+	config := NewConfig(types.WithRuntimeConfig(runtime), types.WithOnDebug(func(flowType string, nodeId string, msg types.RuleMsg, relationType string, err error) {
+		atomic.AddInt64(&debugCount, 1)
+	}))
+	ruleEngine, err := NewChainBuilder().Id("runtime01").
+		Node("jsFilter", types.Configuration{"jsScript": "if (msg.loop) { while (true) {} } return true;"}).Debug(true).
+		New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("runtime01")
+
+	execute := func(data string) error {
+		var wg sync.WaitGroup
+		var result error
+		wg.Add(1)
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), data), func(msg types.RuleMsg, err error) {
+			result = err
+			wg.Done()
+		})
+		waitTimeout(t, &wg, time.Second*3)
+		return result
+	}
+
+	//不采样调试信息
+	settings := runtime.Settings()
+	settings.DebugSampleRate = 0
+	assert.Nil(t, runtime.Update(settings))
+	assert.Nil(t, execute("{}"))
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&debugCount))
+
+	settings.DebugSampleRate = 1
+	assert.Nil(t, runtime.Update(settings))
+	assert.Nil(t, execute("{}"))
+	assert.True(t, waitFor(func() bool { return atomic.LoadInt64(&debugCount) == 2 }))
+
+	//修改js脚本执行超时时间，不需要重新加载规则链
+	settings.JsMaxExecutionTime = 50
+	assert.Nil(t, runtime.Update(settings))
+	start := time.Now()
+	err = execute(`{"loop":true}`)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "execution timeout"))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestRuntimeConfigSampleDebug(t *testing.T) {
+	runtime := types.NewRuntimeConfig()
+	settings := runtime.Settings()
+	settings.JsMaxExecutionTime = 100
+	settings.DebugSampleRate = 0.25
+	assert.Nil(t, runtime.Update(settings))
+	sampled := 0
+	for i := 0; i < 4000; i++ {
+		msgId := fmt.Sprintf("msg%d", i)
+		if runtime.SampleDebug(msgId) {
+			sampled++
+			//同一条消息的采样结果相同
+			assert.True(t, runtime.SampleDebug(msgId))
+		}
+	}
+	assert.True(t, sampled > 800 && sampled < 1200)
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/secrets"
+	"strings"
+)
+
+// defaultSecretKeyName 默认加密密钥名称
+const defaultSecretKeyName = "RULEGO_CONFIG_KEY"
+
+// ErrSecretsProviderNotConfigured 配置中有加密字段，但是没有配置SecretsProvider
+var ErrSecretsProviderNotConfigured = errors.New("secrets provider not configured")
+
+// secretKey 从SecretsProvider获取加密密钥
+func secretKey(config types.Config) (string, error) {
+	if config.SecretsProvider == nil {
+		return "", ErrSecretsProviderNotConfigured
+	}
+	name := config.SecretKeyName
+	if name == "" {
+		name = defaultSecretKeyName
+	}
+	return config.SecretsProvider.GetSecret(name)
+}
+
+// decryptConfiguration 解密节点配置中的加密字段，没有加密字段则返回原配置
+func decryptConfiguration(config types.Config, configuration types.Configuration) (types.Configuration, error) {
+	var key string
+	var result types.Configuration
+	for k, v := range configuration {
+		value, ok := v.(string)
+		if !ok || !secrets.IsEncrypted(value) {
+			continue
+		}
+		if result == nil {
+			var err error
+			if key, err = secretKey(config); err != nil {
+				return nil, err
+			}
+			result = copyConfiguration(configuration)
+		}
+		plaintext, err := secrets.Decrypt(key, value)
+		if err != nil {
+			return nil, err
+		}
+		result[k] = plaintext
+	}
+	if result == nil {
+		return configuration, nil
+	}
+	return result, nil
+}
+
+// maskedValue 审计记录中敏感字段的替换值
+const maskedValue = "******"
+
+// encryptRuleNode 返回敏感字段加密后的节点定义副本，没有配置SecretsProvider则返回原定义
+func encryptRuleNode(config types.Config, def *RuleNode) (*RuleNode, error) {
+	if config.SecretsProvider == nil {
+		return def, nil
+	}
+	var key string
+	return replaceSensitive(config, def, func(value string) (string, error) {
+		if secrets.IsEncrypted(value) {
+			return value, nil
+		}
+		if key == "" {
+			var err error
+			if key, err = secretKey(config); err != nil {
+				return "", err
+			}
+		}
+		return secrets.Encrypt(key, value)
+	})
+}
+
+// encryptRuleChain 返回所有节点敏感字段加密后的规则链定义副本，没有配置SecretsProvider则返回原定义
+func encryptRuleChain(config types.Config, def *RuleChain) (*RuleChain, error) {
+	if config.SecretsProvider == nil {
+		return def, nil
+	}
+	return replaceRuleChainSensitive(def, func(node *RuleNode) (*RuleNode, error) {
+		return encryptRuleNode(config, node)
+	})
+}
+
+// maskRuleNode 返回敏感字段替换成******的节点定义副本，用于审计记录
+func maskRuleNode(config types.Config, def *RuleNode) *RuleNode {
+	result, _ := replaceSensitive(config, def, func(value string) (string, error) {
+		return maskedValue, nil
+	})
+	return result
+}
+
+// maskRuleChain 返回所有节点敏感字段替换成******的规则链定义副本，用于审计记录
+func maskRuleChain(config types.Config, def *RuleChain) *RuleChain {
+	result, _ := replaceRuleChainSensitive(def, func(node *RuleNode) (*RuleNode, error) {
+		return maskRuleNode(config, node), nil
+	})
+	return result
+}
+
+// replaceSensitive 使用replace替换节点非空的敏感字段，返回副本，没有敏感字段则返回原定义
+func replaceSensitive(config types.Config, def *RuleNode, replace func(value string) (string, error)) (*RuleNode, error) {
+	if def == nil || config.ComponentsRegistry == nil {
+		return def, nil
+	}
+	descriptor, ok := config.ComponentsRegistry.GetDescriptor(def.Type)
+	if !ok || len(descriptor.SensitiveFields) == 0 {
+		return def, nil
+	}
+	var result *RuleNode
+	for k, v := range def.Configuration {
+		value, ok := v.(string)
+		if !ok || value == "" || !containsFold(descriptor.SensitiveFields, k) {
+			continue
+		}
+		replaced, err := replace(value)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			node := *def
+			node.Configuration = copyConfiguration(def.Configuration)
+			result = &node
+		}
+		result.Configuration[k] = replaced
+	}
+	if result == nil {
+		return def, nil
+	}
+	return result, nil
+}
+
+// replaceRuleChainSensitive 使用replace替换所有节点，返回规则链定义副本
+func replaceRuleChainSensitive(def *RuleChain, replace func(node *RuleNode) (*RuleNode, error)) (*RuleChain, error) {
+	if def == nil {
+		return def, nil
+	}
+	result := *def
+	result.Metadata.Nodes = make([]*RuleNode, len(def.Metadata.Nodes))
+	for i, node := range def.Metadata.Nodes {
+		replaced, err := replace(node)
+		if err != nil {
+			return nil, err
+		}
+		result.Metadata.Nodes[i] = replaced
+	}
+	return &result, nil
+}
+
+func copyConfiguration(configuration types.Configuration) types.Configuration {
+	result := make(types.Configuration, len(configuration))
+	for k, v := range configuration {
+		result[k] = v
+	}
+	return result
+}
+
+func containsFold(items []string, s string) bool {
+	for _, item := range items {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/secrets"
+	"strings"
+	"testing"
+)
+
+func TestSensitiveDescriptor(t *testing.T) {
+	descriptor, ok := Registry.GetDescriptor("dbClient")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"dsn"}, descriptor.SensitiveFields)
+	descriptor, ok = Registry.GetDescriptor("mqttClient")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"password"}, descriptor.SensitiveFields)
+}
+
+func TestEncryptSensitiveConfiguration(t *testing.T) {
+	provider := secrets.MapProvider{"RULEGO_CONFIG_KEY": "key01"}
+	config := NewConfig(types.WithSecretsProvider(provider, ""))
+	def, err := NewChainBuilder().Id("secret01").
+		Node("restApiCall", types.Configuration{
+			"restEndpointUrlPattern": "http://127.0.0.1:9099/api",
+			"proxyPassword":          "pass01",
+		}).
+		DSL()
+	assert.Nil(t, err)
+
+	ruleEngine, err := New("secret01", def, WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("secret01")
+	exported := ruleEngine.DSL()
+	assert.False(t, strings.Contains(string(exported), "pass01"))
+	assert.True(t, strings.Contains(string(exported), secrets.EncryptedPrefix))
+	assert.True(t, strings.Contains(string(exported), "http://127.0.0.1:9099/api"))
+	//原定义不修改
+	assert.Equal(t, "pass01", ruleEngine.rootRuleChainCtx.SelfDefinition.Metadata.Nodes[0].Configuration["proxyPassword"])
+
+	//加载加密的规则链
+	ruleEngine2, err := New("secret02", exported, WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("secret02")
+	nodeCtx, _ := ruleEngine2.rootRuleChainCtx.nodes[types.RuleNodeId{Id: "s1", Type: types.NODE}]
+	assert.True(t, strings.Contains(string(nodeCtx.DSL()), secrets.EncryptedPrefix))
+
+	//没有密钥不能加载
+	_, err = New("secret03", exported)
+	assert.Equal(t, ErrSecretsProviderNotConfigured, err)
+	_, err = New("secret03", exported, WithConfig(NewConfig(types.WithSecretsProvider(secrets.MapProvider{"RULEGO_CONFIG_KEY": "key02"}, ""))))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"math/rand"
+	"reflect"
+	"sync"
+)
+
+// ShadowKey 复制到候选规则链的消息在元数据中设置shadow=true
+const ShadowKey = "shadow"
+
+// 结果不一致的字段
+const (
+	ShadowDiffType     = "msgType"
+	ShadowDiffData     = "data"
+	ShadowDiffMetadata = "metadata"
+	ShadowDiffError    = "error"
+)
+
+// ShadowConfig 影子流量配置，把一定比例的消息复制到候选规则链，用于在生产流量下验证规则链变更
+// 候选规则链的结果不会影响主规则链，如果候选规则链有写数据库等副作用，可以使用`types.WithDryRun`创建
+type ShadowConfig struct {
+	//ChainId 候选规则链ID，通过`Config.ChainExecutor`执行
+	ChainId string
+	//Percent 复制的消息比例，范围0~100
+	Percent float64
+	//OnResult 比较主规则链和候选规则链的结果，两个规则链都以第一个结束点的结果为准
+	//为空则丢弃候选规则链的结果
+	OnResult func(result ShadowResult)
+	//Rand 随机数函数，返回[0,1)，默认使用`math/rand.Float64`
+	Rand func() float64
+}
+
+// ShadowResult 主规则链和候选规则链处理同一条消息的结果
+type ShadowResult struct {
+	//Msg 输入的消息
+	Msg types.RuleMsg
+	//Primary 主规则链的结果
+	Primary    types.RuleMsg
+	PrimaryErr error
+	//Shadow 候选规则链的结果
+	Shadow    types.RuleMsg
+	ShadowErr error
+	//Diff 结果不一致的字段，参考ShadowDiffType、ShadowDiffData、ShadowDiffMetadata和ShadowDiffError，一致则为空
+	Diff []string
+}
+
+// WithShadow 设置影子流量，按比例把消息复制到候选规则链，ChainId为空则不复制
+func WithShadow(config ShadowConfig) RuleEngineOption {
+	return func(re *RuleEngine) error {
+		if config.Rand == nil {
+			config.Rand = rand.Float64
+		}
+		re.shadow = &config
+		return nil
+	}
+}
+
+// mirror 按比例把消息复制到候选规则链，返回包装后的主规则链结束回调
+func (c *ShadowConfig) mirror(config types.Config, msg types.RuleMsg, onEnd func(msg types.RuleMsg, err error)) func(msg types.RuleMsg, err error) {
+	if c.ChainId == "" || c.Percent <= 0 || c.Rand()*100 >= c.Percent || config.ChainExecutor == nil {
+		return onEnd
+	}
+	result := &shadowCollector{result: ShadowResult{Msg: msg.Copy()}, onResult: c.OnResult}
+	shadowMsg := msg.Copy()
+	shadowMsg.Metadata.PutValue(ShadowKey, "true")
+	if err := config.ChainExecutor(c.ChainId, shadowMsg, result.onShadowEnd); err != nil {
+		result.onShadowEnd(types.RuleMsg{}, err)
+	}
+	return func(msg types.RuleMsg, err error) {
+		result.onPrimaryEnd(msg, err)
+		if onEnd != nil {
+			onEnd(msg, err)
+		}
+	}
+}
+
+// shadowCollector 收集主规则链和候选规则链第一个结束点的结果，都结束后比较
+type shadowCollector struct {
+	lock        sync.Mutex
+	result      ShadowResult
+	primaryDone bool
+	shadowDone  bool
+	onResult    func(result ShadowResult)
+}
+
+func (x *shadowCollector) onPrimaryEnd(msg types.RuleMsg, err error) {
+	x.lock.Lock()
+	if x.primaryDone {
+		x.lock.Unlock()
+		return
+	}
+	x.primaryDone = true
+	x.result.Primary, x.result.PrimaryErr = msg.Copy(), err
+	x.done()
+}
+
+func (x *shadowCollector) onShadowEnd(msg types.RuleMsg, err error) {
+	x.lock.Lock()
+	if x.shadowDone {
+		x.lock.Unlock()
+		return
+	}
+	x.shadowDone = true
+	x.result.Shadow, x.result.ShadowErr = msg.Copy(), err
+	x.done()
+}
+
+// done 两个规则链都结束后比较结果，调用时持有锁
+func (x *shadowCollector) done() {
+	if !x.primaryDone || !x.shadowDone || x.onResult == nil {
+		x.lock.Unlock()
+		return
+	}
+	result := x.result
+	x.lock.Unlock()
+	result.Diff = diffShadowResult(result)
+	x.onResult(result)
+}
+
+// diffShadowResult 比较结果，忽略候选规则链消息的ShadowKey元数据
+func diffShadowResult(result ShadowResult) []string {
+	var diff []string
+	if result.Primary.Type != result.Shadow.Type {
+		diff = append(diff, ShadowDiffType)
+	}
+	if result.Primary.Data != result.Shadow.Data {
+		diff = append(diff, ShadowDiffData)
+	}
+	shadowMetadata := result.Shadow.Metadata.Values()
+	if !result.Primary.Metadata.Has(ShadowKey) {
+		delete(shadowMetadata, ShadowKey)
+	}
+	if !reflect.DeepEqual(result.Primary.Metadata.Values(), shadowMetadata) {
+		diff = append(diff, ShadowDiffMetadata)
+	}
+	if (result.PrimaryErr == nil) != (result.ShadowErr == nil) ||
+		(result.PrimaryErr != nil && result.PrimaryErr.Error() != result.ShadowErr.Error()) {
+		diff = append(diff, ShadowDiffError)
+	}
+	return diff
+}
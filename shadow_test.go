@@ -0,0 +1,107 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newShadowTestChain(id string, script string, opts ...RuleEngineOption) (*RuleEngine, error) {
+	return NewChainBuilder().Id(id).
+		Node("jsTransform", types.Configuration{"jsScript": script}).
+		New(opts...)
+}
+
+func TestShadow(t *testing.T) {
+	_, err := newShadowTestChain("shadowCandidate", "metadata['level']=msg.temperature > 50 ? 'high' : 'low';return {'msg':msg,'metadata':metadata,'msgType':msgType};")
+	assert.Nil(t, err)
+	defer Del("shadowCandidate")
+
+	var wg sync.WaitGroup
+	var results []ShadowResult
+	var lock sync.Mutex
+	percent := 100.0
+	ruleEngine, err := newShadowTestChain("shadowPrimary", "metadata['level']=msg.temperature > 60 ? 'high' : 'low';return {'msg':msg,'metadata':metadata,'msgType':msgType};",
+		WithShadow(ShadowConfig{
+			ChainId: "shadowCandidate",
+			Percent: 50,
+			Rand:    func() float64 { return percent / 100 },
+			OnResult: func(result ShadowResult) {
+				lock.Lock()
+				defer lock.Unlock()
+				results = append(results, result)
+				wg.Done()
+			},
+		}))
+	assert.Nil(t, err)
+	defer Del("shadowPrimary")
+
+	onMsg := func(data string) {
+		var done sync.WaitGroup
+		done.Add(1)
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), data), func(msg types.RuleMsg, err error) {
+			//主规则链的结果不受影响
+			assert.False(t, msg.Metadata.Has(ShadowKey))
+			done.Done()
+		})
+		waitTimeout(t, &done, time.Second*3)
+	}
+	//随机数不小于比例不复制
+	onMsg(`{"temperature":55}`)
+	percent = 10
+	wg.Add(2)
+	onMsg(`{"temperature":55}`)
+	onMsg(`{"temperature":70}`)
+	waitTimeout(t, &wg, time.Second*3)
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 2, len(results))
+	for _, result := range results {
+		assert.Equal(t, "true", result.Shadow.Metadata.GetValue(ShadowKey))
+		if result.Msg.Data == `{"temperature":55}` {
+			assert.Equal(t, []string{ShadowDiffMetadata}, result.Diff)
+			assert.Equal(t, "low", result.Primary.Metadata.GetValue("level"))
+			assert.Equal(t, "high", result.Shadow.Metadata.GetValue("level"))
+		} else {
+			assert.Equal(t, 0, len(result.Diff))
+		}
+	}
+}
+
+func TestShadowChainNotFound(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ruleEngine, err := newShadowTestChain("shadowNotFound", "return {'msg':msg,'metadata':metadata,'msgType':msgType};",
+		WithShadow(ShadowConfig{
+			ChainId: "notFound",
+			Percent: 100,
+			OnResult: func(result ShadowResult) {
+				assert.NotNil(t, result.ShadowErr)
+				assert.Equal(t, []string{ShadowDiffError}, result.Diff[len(result.Diff)-1:])
+				wg.Done()
+			},
+		}))
+	assert.Nil(t, err)
+	defer Del("shadowNotFound")
+	ruleEngine.OnMsg(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), "{}"))
+	waitTimeout(t, &wg, time.Second*3)
+}
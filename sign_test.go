@@ -0,0 +1,79 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/sign"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSignedRuleChain(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	config := NewConfig(types.WithTrustedKey("pub01", publicKey))
+	def, err := NewChainBuilder().Id("signed01").
+		Node("jsFilter", types.Configuration{"jsScript": "return true;"}).
+		DSL()
+	assert.Nil(t, err)
+
+	_, err = New("signed01", def, WithConfig(config))
+	assert.Equal(t, sign.ErrUnsigned, err)
+
+	signed, err := sign.Sign(def, "pub01", privateKey)
+	assert.Nil(t, err)
+	ruleEngine, err := New("signed01", signed, WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("signed01")
+
+	//重新加载也需要签名
+	assert.Equal(t, sign.ErrUnsigned, ruleEngine.ReloadSelf(def))
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	forged, _ := sign.Sign(def, "pub01", otherKey)
+	assert.True(t, errors.Is(ruleEngine.ReloadSelf(forged), sign.ErrInvalidSignature))
+	assert.Nil(t, ruleEngine.ReloadSelf(signed))
+}
+
+// 签名不覆盖$ref引用的文件和extends继承的基础规则链，配置了信任的签名公钥时拒绝
+func TestSignedRuleChainReference(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	config := NewConfig(types.WithTrustedKey("pub01", publicKey), types.WithIncludeFS(fstest.MapFS{
+		"filter.js": &fstest.MapFile{Data: []byte("return true;")},
+	}))
+
+	def, err := NewChainBuilder().Id("signedRef01").
+		Node("jsFilter", types.Configuration{"jsScript": map[string]interface{}{"$ref": "filter.js"}}).
+		DSL()
+	assert.Nil(t, err)
+	signed, err := sign.Sign(def, "pub01", privateKey)
+	assert.Nil(t, err)
+	_, err = New("signedRef01", signed, WithConfig(config))
+	assert.True(t, errors.Is(err, ErrUnsignedReference))
+
+	assert.Nil(t, BaseChains.RegisterDSL([]byte(baseRuleChain)))
+	defer BaseChains.Unregister("alarmBase")
+	signed, err = sign.Sign([]byte(prodRuleChain), "pub01", privateKey)
+	assert.Nil(t, err)
+	_, err = New("alarmProd", signed, WithConfig(config))
+	assert.True(t, errors.Is(err, ErrUnsignedReference))
+}
@@ -0,0 +1,272 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/utils/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStartGrace 默认服务启动宽限时间
+const DefaultStartGrace = 200 * time.Millisecond
+
+// startupCheckInterval 等待规则链就绪时检查健康状态的间隔
+const startupCheckInterval = 50 * time.Millisecond
+
+// Service 启动编排管理的服务，例如：endpoint.Endpoint
+type Service interface {
+	//Start 启动服务，可以阻塞直到服务停止，例如：http.Server.ListenAndServe
+	Start() error
+	//Destroy 停止服务
+	Destroy()
+}
+
+// startupUnit 启动编排的规则链或者服务
+type startupUnit struct {
+	id       string
+	requires []string
+	//subChains 子规则链连接的目标规则链，没有添加到启动编排时忽略
+	subChains []string
+	//def 规则链定义，服务为空
+	def  []byte
+	opts []RuleEngineOption
+	//service 服务，规则链为空
+	service Service
+}
+
+// Startup 规则链和服务的启动编排
+// 按声明的依赖顺序启动：规则链在依赖的规则链之后启动，服务在依赖的规则链就绪之后才启动(例如endpoint开始监听)，
+// 停止时按相反顺序停止。依赖包括规则链定义的ruleChain.requires、子规则链连接和添加时声明的依赖
+type Startup struct {
+	ruleGo *RuleGo
+	opts   []RuleEngineOption
+	//readyTimeout 等待规则链健康检查通过的时间，0表示规则链创建成功即就绪
+	readyTimeout time.Duration
+	//startGrace 服务启动宽限时间，Start在宽限时间内返回错误则启动失败，仍在运行则认为已经启动
+	startGrace time.Duration
+	units      []*startupUnit
+	index      map[string]*startupUnit
+	lock       sync.Mutex
+	//started 已经启动的单元，按启动顺序排列
+	started []*startupUnit
+}
+
+// NewStartup 创建启动编排，opts为创建规则链的选项，ruleGo为空使用DefaultRuleGo
+func NewStartup(ruleGo *RuleGo, opts ...RuleEngineOption) *Startup {
+	if ruleGo == nil {
+		ruleGo = DefaultRuleGo
+	}
+	return &Startup{ruleGo: ruleGo, opts: opts, startGrace: DefaultStartGrace, index: make(map[string]*startupUnit)}
+}
+
+// ReadyTimeout 设置等待规则链健康检查通过的时间，用于initMode=async或者降级启动的规则链，超时则启动失败
+func (s *Startup) ReadyTimeout(timeout time.Duration) *Startup {
+	s.readyTimeout = timeout
+	return s
+}
+
+// StartGrace 设置服务启动宽限时间，默认DefaultStartGrace
+func (s *Startup) StartGrace(grace time.Duration) *Startup {
+	s.startGrace = grace
+	return s
+}
+
+// AddChain 添加规则链，requires为除规则链定义中声明的依赖之外的其他依赖
+// 子规则链连接的目标规则链如果也添加到启动编排，同样作为依赖
+func (s *Startup) AddChain(def []byte, requires ...string) error {
+	var chain RuleChain
+	if err := json.Unmarshal(def, &chain); err != nil {
+		return err
+	}
+	unit := &startupUnit{id: chain.RuleChain.ID, def: def}
+	unit.requires = append(unit.requires, chain.RuleChain.Requires...)
+	unit.requires = append(unit.requires, requires...)
+	for _, item := range chain.Metadata.RuleChainConnections {
+		unit.subChains = append(unit.subChains, item.ToId)
+	}
+	return s.add(unit)
+}
+
+// AddService 添加服务，requires为依赖的规则链或者服务ID
+func (s *Startup) AddService(id string, service Service, requires ...string) error {
+	if service == nil {
+		return errors.New("service can not nil")
+	}
+	return s.add(&startupUnit{id: id, service: service, requires: requires})
+}
+
+func (s *Startup) add(unit *startupUnit) error {
+	if unit.id == "" {
+		return errors.New("startup id can not empty")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.index[unit.id]; ok {
+		return fmt.Errorf("duplicate startup id=%s", unit.id)
+	}
+	s.units = append(s.units, unit)
+	s.index[unit.id] = unit
+	return nil
+}
+
+// Order 返回启动顺序，没有依赖关系的单元按添加顺序排列
+// 依赖没有添加到启动编排并且规则链池中也不存在，或者存在循环依赖则返回错误
+func (s *Startup) Order() ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	units, err := s.order()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(units))
+	for i, unit := range units {
+		ids[i] = unit.id
+	}
+	return ids, nil
+}
+
+// order 拓扑排序，调用方需要持有锁
+func (s *Startup) order() ([]*startupUnit, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := make(map[string]int, len(s.units))
+	result := make([]*startupUnit, 0, len(s.units))
+	var visit func(unit *startupUnit, path []string) error
+	visit = func(unit *startupUnit, path []string) error {
+		switch states[unit.id] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("circular startup dependency: %s", strings.Join(append(path, unit.id), " -> "))
+		}
+		states[unit.id] = visiting
+		for _, require := range unit.requires {
+			dep, ok := s.index[require]
+			if !ok {
+				if _, exists := s.ruleGo.Get(require); !exists {
+					return fmt.Errorf("startup dependency not found: %s requires %s", unit.id, require)
+				}
+				continue
+			}
+			if err := visit(dep, append(path, unit.id)); err != nil {
+				return err
+			}
+		}
+		for _, chainId := range unit.subChains {
+			//规则链可以连接到自身
+			if dep, ok := s.index[chainId]; ok && dep != unit {
+				if err := visit(dep, append(path, unit.id)); err != nil {
+					return err
+				}
+			}
+		}
+		states[unit.id] = visited
+		result = append(result, unit)
+		return nil
+	}
+	for _, unit := range s.units {
+		if err := visit(unit, nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Start 按依赖顺序启动所有规则链和服务，任意单元启动失败则按相反顺序停止已经启动的单元
+func (s *Startup) Start() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	units, err := s.order()
+	if err != nil {
+		return err
+	}
+	for _, unit := range units {
+		if err = s.start(unit); err != nil {
+			s.stop()
+			return fmt.Errorf("start %s error: %w", unit.id, err)
+		}
+		s.started = append(s.started, unit)
+	}
+	return nil
+}
+
+// Stop 按启动的相反顺序停止所有规则链和服务
+func (s *Startup) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stop()
+}
+
+func (s *Startup) start(unit *startupUnit) error {
+	if unit.service != nil {
+		return s.startService(unit.service)
+	}
+	ruleEngine, err := s.ruleGo.New("", unit.def, s.opts...)
+	if err != nil {
+		return err
+	}
+	if s.readyTimeout <= 0 {
+		return nil
+	}
+	//等待规则链健康检查通过
+	deadline := time.Now().Add(s.readyTimeout)
+	for {
+		if err = ruleEngine.HealthCheck(); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			s.ruleGo.Del(unit.id)
+			return fmt.Errorf("chain not ready after %s: %w", s.readyTimeout, err)
+		}
+		time.Sleep(startupCheckInterval)
+	}
+}
+
+// startService 在独立的协程启动服务，宽限时间内返回错误则启动失败
+func (s *Startup) startService(service Service) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- service.Start()
+	}()
+	timer := time.NewTimer(s.startGrace)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		return nil
+	}
+}
+
+// stop 调用方需要持有锁
+func (s *Startup) stop() {
+	for i := len(s.started) - 1; i >= 0; i-- {
+		unit := s.started[i]
+		if unit.service != nil {
+			unit.service.Destroy()
+		} else {
+			s.ruleGo.Del(unit.id)
+		}
+	}
+	s.started = nil
+}
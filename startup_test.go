@@ -0,0 +1,145 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testService 记录启动和停止顺序的服务
+type testService struct {
+	id      string
+	events  *[]string
+	lock    *sync.Mutex
+	startFn func() error
+}
+
+func (s *testService) Start() error {
+	s.lock.Lock()
+	*s.events = append(*s.events, "start:"+s.id)
+	s.lock.Unlock()
+	if s.startFn != nil {
+		return s.startFn()
+	}
+	return nil
+}
+
+func (s *testService) Destroy() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	*s.events = append(*s.events, "stop:"+s.id)
+}
+
+func startupChain(t *testing.T, id string, requires []string, subChain string) []byte {
+	builder := NewChainBuilder().Id(id).Requires(requires...).
+		Node("jsFilter", types.Configuration{"jsScript": "return true;"})
+	if subChain != "" {
+		builder.On(types.True).ToChain(subChain)
+	}
+	def, err := builder.DSL()
+	assert.Nil(t, err)
+	return def
+}
+
+func TestStartup(t *testing.T) {
+	ruleGo := &RuleGo{}
+	var events []string
+	var lock sync.Mutex
+	startup := NewStartup(ruleGo)
+	//服务启动时依赖的规则链已经就绪
+	service := &testService{id: "rest", events: &events, lock: &lock, startFn: func() error {
+		_, ok := ruleGo.Get("chainB")
+		assert.True(t, ok)
+		return nil
+	}}
+	assert.Nil(t, startup.AddService("rest", service, "chainB"))
+	assert.Nil(t, startup.AddChain(startupChain(t, "chainC", nil, "chainA")))
+	assert.Nil(t, startup.AddChain(startupChain(t, "chainB", []string{"chainA"}, "")))
+	assert.Nil(t, startup.AddChain(startupChain(t, "chainA", nil, "chainA")))
+	assert.NotNil(t, startup.AddChain(startupChain(t, "chainA", nil, "")))
+
+	order, err := startup.Order()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"chainA", "chainB", "rest", "chainC"}, order)
+
+	assert.Nil(t, startup.Start())
+	for _, id := range order[:2] {
+		_, ok := ruleGo.Get(id)
+		assert.True(t, ok)
+	}
+	startup.Stop()
+	assert.Equal(t, []string{"start:rest", "stop:rest"}, events)
+	_, ok := ruleGo.Get("chainA")
+	assert.False(t, ok)
+}
+
+func TestStartupDependencyError(t *testing.T) {
+	ruleGo := &RuleGo{}
+	startup := NewStartup(ruleGo)
+	assert.Nil(t, startup.AddChain(startupChain(t, "chainA", []string{"chainB"}, "")))
+	assert.Nil(t, startup.AddChain(startupChain(t, "chainB", []string{"chainA"}, "")))
+	_, err := startup.Order()
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "chainA -> chainB -> chainA"))
+	assert.NotNil(t, startup.Start())
+
+	//依赖不存在
+	startup = NewStartup(ruleGo)
+	assert.Nil(t, startup.AddService("rest", &testService{}, "chainX"))
+	_, err = startup.Order()
+	assert.NotNil(t, err)
+	//依赖已经在规则链池中
+	_, err = ruleGo.New("", startupChain(t, "chainX", nil, ""))
+	assert.Nil(t, err)
+	defer ruleGo.Del("chainX")
+	_, err = startup.Order()
+	assert.Nil(t, err)
+}
+
+func TestStartupRollback(t *testing.T) {
+	ruleGo := &RuleGo{}
+	var events []string
+	var lock sync.Mutex
+	startup := NewStartup(ruleGo).StartGrace(time.Millisecond * 50)
+	stopped := make(chan struct{})
+	//阻塞运行的服务宽限时间后认为已经启动
+	blocking := &testService{id: "mqtt", events: &events, lock: &lock, startFn: func() error {
+		<-stopped
+		return nil
+	}}
+	failed := &testService{id: "rest", events: &events, lock: &lock, startFn: func() error {
+		return errors.New("address already in use")
+	}}
+	assert.Nil(t, startup.AddChain(startupChain(t, "chainA", nil, "")))
+	assert.Nil(t, startup.AddService("mqtt", blocking, "chainA"))
+	assert.Nil(t, startup.AddService("rest", failed, "mqtt"))
+	err := startup.Start()
+	close(stopped)
+	assert.NotNil(t, err)
+	assert.Equal(t, "start rest error: address already in use", err.Error())
+	lock.Lock()
+	assert.Equal(t, []string{"start:mqtt", "start:rest", "stop:mqtt"}, events)
+	lock.Unlock()
+	_, ok := ruleGo.Get("chainA")
+	assert.False(t, ok)
+}
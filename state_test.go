@@ -0,0 +1,104 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/state"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNodeState(t *testing.T) {
+	registry := new(RuleComponentRegistry)
+	_ = registry.Register(&stateCounterNode{})
+	store := state.NewMemory()
+	config := NewConfig(types.WithComponentsRegistry(registry), types.WithStateStore(store))
+	ruleEngine, err := NewChainBuilder().Id("stateChain").
+		NodeWithId("c1", "stateCounter", nil).On(types.Success).
+		NodeWithId("c2", "stateCounter", nil).
+		New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("stateChain")
+
+	send := func(deviceId string) types.RuleMsg {
+		var result types.RuleMsg
+		var wg sync.WaitGroup
+		wg.Add(1)
+		metadata := types.NewMetadata()
+		metadata.PutValue("deviceId", deviceId)
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, metadata, "{}"), func(msg types.RuleMsg, err error) {
+			result = msg
+			wg.Done()
+		})
+		waitTimeout(t, &wg, time.Second*3)
+		return result
+	}
+	msg := send("aa")
+	assert.Equal(t, "1", msg.Metadata.GetValue("count"))
+	msg = send("aa")
+	assert.Equal(t, "2", msg.Metadata.GetValue("count"))
+	msg = send("bb")
+	assert.Equal(t, "1", msg.Metadata.GetValue("count"))
+
+	//每个节点的状态相互独立
+	v, ok, _ := store.Get(state.Key("stateChain", "c1", "aa"))
+	assert.True(t, ok)
+	assert.Equal(t, "2", v)
+	v, _, _ = store.Get(state.Key("stateChain", "c2", "aa"))
+	assert.Equal(t, "2", v)
+}
+
+// stateCounterNode 按设备ID统计消息数量，结果写入元数据count
+type stateCounterNode struct {
+}
+
+func (x *stateCounterNode) Type() string {
+	return "stateCounter"
+}
+
+func (x *stateCounterNode) New() types.Node {
+	return &stateCounterNode{}
+}
+
+func (x *stateCounterNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *stateCounterNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	key, _ := msg.Metadata.GetValue("deviceId").(string)
+	value, _, err := ctx.GetState(key)
+	if err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	count, _ := strconv.Atoi(value)
+	count++
+	if err = ctx.SetState(key, strconv.Itoa(count)); err != nil {
+		ctx.TellFailure(msg, err)
+		return err
+	}
+	msg.Metadata.PutValue("count", strconv.Itoa(count))
+	ctx.TellSuccess(msg)
+	return nil
+}
+
+func (x *stateCounterNode) Destroy() {
+}
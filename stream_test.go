@@ -0,0 +1,143 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+// streamTestNode 流式输出的测试组件，msg.Data为rows时输出3行，为fail时输出1行后失败，为idle时输出1行后不再输出
+type streamTestNode struct {
+}
+
+func (x *streamTestNode) Type() string {
+	return "test/stream"
+}
+
+func (x *streamTestNode) New() types.Node {
+	return &streamTestNode{}
+}
+
+func (x *streamTestNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return nil
+}
+
+func (x *streamTestNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	stream := ctx.Stream(msg, 0)
+	go func() {
+		switch msg.Data {
+		case "rows":
+			for _, row := range []string{"r1", "r2", "r3"} {
+				stream.Emit(ctx.NewMsg("ROW", types.NewMetadata(), row))
+			}
+			stream.Close(nil)
+		case "fail":
+			stream.Emit(ctx.NewMsg("ROW", types.NewMetadata(), "r1"))
+			stream.Close(errors.New("connection reset"))
+		default:
+			stream.Emit(ctx.NewMsg("ROW", types.NewMetadata(), "r1"))
+		}
+	}()
+	return nil
+}
+
+func (x *streamTestNode) Destroy() {
+}
+
+func TestStream(t *testing.T) {
+	_ = Registry.Register(&streamTestNode{})
+	defer Registry.Unregister("test/stream")
+
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	config := NewConfig(types.WithClock(vc), types.WithAsyncTimeout(time.Second*10))
+	ruleEngine, err := NewChainBuilder().Id("stream01").
+		Node("test/stream", nil).On(types.Success).
+		Node("jsTransform", types.Configuration{"jsScript": "msg.row=true;return {'msg':msg,'metadata':metadata,'msgType':msgType};"}).
+		New(WithConfig(config))
+	assert.Nil(t, err)
+	defer Del("stream01")
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var ends []types.RuleMsg
+	var errs []error
+	send := func(data string, count int) types.RuleMsg {
+		lock.Lock()
+		ends, errs = nil, nil
+		lock.Unlock()
+		wg.Add(count)
+		msg := types.NewMsg(0, "QUERY", types.TEXT, types.NewMetadata(), data)
+		ruleEngine.OnMsgWithEndFunc(msg, func(msg types.RuleMsg, err error) {
+			lock.Lock()
+			ends = append(ends, msg)
+			errs = append(errs, err)
+			lock.Unlock()
+			wg.Done()
+		})
+		return msg
+	}
+	lastEnd := func() (types.RuleMsg, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		for i, item := range ends {
+			if item.Metadata.GetValue(types.StreamEndKey) == "true" {
+				return item, errs[i]
+			}
+		}
+		t.Fatal("stream end not found")
+		return types.RuleMsg{}, nil
+	}
+
+	//3行输出和1个结束信号
+	msg := send("rows", 4)
+	waitTimeout(t, &wg, time.Second*3)
+	end, endErr := lastEnd()
+	assert.Nil(t, endErr)
+	assert.Equal(t, msg.Id, end.Id)
+	assert.Equal(t, "3", end.Metadata.GetValue(types.StreamSeqKey))
+	rows := 0
+	for _, item := range ends {
+		if item.Type == "ROW" {
+			rows++
+			assert.Equal(t, msg.Id, item.Metadata.GetValue(types.StreamIdKey))
+		}
+	}
+	assert.Equal(t, 3, rows)
+	assert.Equal(t, ChainStats{StreamChunks: 3}, ruleEngine.Stats())
+
+	//失败结束
+	send("fail", 2)
+	waitTimeout(t, &wg, time.Second*3)
+	_, endErr = lastEnd()
+	assert.Equal(t, "connection reset", endErr.Error())
+
+	//空闲超时结束
+	send("idle", 2)
+	assert.True(t, vc.WaitPending(1, time.Second*3))
+	assert.True(t, waitFor(func() bool { return ruleEngine.Stats().StreamChunks == 5 }))
+	vc.Advance(time.Second * 10)
+	waitTimeout(t, &wg, time.Second*3)
+	_, endErr = lastEnd()
+	assert.Equal(t, types.ErrAsyncTimeout, endErr)
+	assert.Equal(t, ChainStats{StreamChunks: 5, AsyncTimeouts: 1}, ruleEngine.Stats())
+}
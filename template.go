@@ -0,0 +1,364 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+	"regexp"
+	"sync"
+)
+
+// TemplateNodeType 模板实例节点类型，配置：{"template": "模板ID", "params": {"参数名": "参数值"}}
+// 加载规则链时展开成模板定义的节点组，模板内节点ID为：实例节点ID/模板内节点ID
+const TemplateNodeType = "template"
+
+// maxTemplateDepth 模板最大嵌套层数，防止模板互相引用
+const maxTemplateDepth = 8
+
+// templateParamRegex 模板参数引用，例如：{{table}}
+var templateParamRegex = regexp.MustCompile(`\{\{\s*(\w+)\s*}}`)
+
+// Templates 全局模板库，规则链的metadata.templates定义的同名模板优先
+var Templates = new(TemplateRegistry)
+
+// NodeTemplate 节点组模板，定义可复用的带参数子图，例如：校验->补全->存储
+// 通过type=template的节点实例化，同一个模板可以在多个规则链实例化多次
+type NodeTemplate struct {
+	//Id 模板ID
+	Id string `json:"id"`
+	//Params 参数及默认值，节点配置中使用{{参数名}}引用，默认值为null表示实例必须提供该参数
+	//配置值只有参数引用时保留参数值的类型，否则按字符串替换
+	Params map[string]interface{} `json:"params,omitempty"`
+	//Entry 入口节点ID，连接到实例节点的消息发送到该节点，默认第一个节点
+	Entry string `json:"entry,omitempty"`
+	//Nodes 模板内的节点
+	Nodes []*RuleNode `json:"nodes"`
+	//Connections 模板内节点之间的连接
+	Connections []NodeConnection `json:"connections"`
+	//Outputs 模板出口，fromId和type为模板内节点和关系类型，toId为实例节点对外的关系类型
+	//例如：{"fromId": "store", "type": "Success", "toId": "Success"}
+	Outputs []NodeConnection `json:"outputs,omitempty"`
+}
+
+// templateInstance 模板实例节点配置
+type templateInstance struct {
+	Template string
+	Params   map[string]interface{}
+}
+
+// TemplateRegistry 模板库
+type TemplateRegistry struct {
+	templates map[string]NodeTemplate
+	sync.RWMutex
+}
+
+// Register 注册模板，已经存在则覆盖
+func (r *TemplateRegistry) Register(template NodeTemplate) error {
+	if template.Id == "" {
+		return errors.New("template id can not empty")
+	}
+	r.Lock()
+	defer r.Unlock()
+	if r.templates == nil {
+		r.templates = make(map[string]NodeTemplate)
+	}
+	r.templates[template.Id] = template
+	return nil
+}
+
+// Unregister 删除模板
+func (r *TemplateRegistry) Unregister(id string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.templates, id)
+}
+
+// Get 获取模板
+func (r *TemplateRegistry) Get(id string) (NodeTemplate, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	template, ok := r.templates[id]
+	return template, ok
+}
+
+// ExpandTemplates 展开规则链中的模板实例节点，返回只包含普通节点的规则链定义，不修改原定义
+// 没有模板实例节点则直接返回原定义
+func ExpandTemplates(def RuleChain) (RuleChain, error) {
+	hasInstance := false
+	for _, node := range def.Metadata.Nodes {
+		if node.Type == TemplateNodeType {
+			hasInstance = true
+			break
+		}
+	}
+	if !hasInstance {
+		return def, nil
+	}
+	local := make(map[string]NodeTemplate, len(def.Metadata.Templates))
+	for _, template := range def.Metadata.Templates {
+		local[template.Id] = template
+	}
+	e := &templateExpander{
+		local:       local,
+		nodes:       make([]*RuleNode, len(def.Metadata.Nodes)),
+		connections: append([]NodeConnection(nil), def.Metadata.Connections...),
+		chains:      append([]RuleChainConnection(nil), def.Metadata.RuleChainConnections...),
+		depth:       make(map[string]int),
+	}
+	for index, node := range def.Metadata.Nodes {
+		item := *node
+		if item.Id == "" {
+			item.Id = fmt.Sprintf(defaultNodeIdPrefix+"%d", index)
+		}
+		e.nodes[index] = &item
+		if index == def.Metadata.FirstNodeIndex {
+			e.first = item.Id
+		}
+	}
+	for i := 0; i < len(e.nodes); {
+		if e.nodes[i].Type != TemplateNodeType {
+			i++
+			continue
+		}
+		//展开后的节点替换实例节点，嵌套的实例节点在后续循环中展开
+		if err := e.expand(i); err != nil {
+			return def, err
+		}
+	}
+	result := def
+	result.Metadata = RuleMetadata{
+		Nodes:                e.nodes,
+		Connections:          e.connections,
+		RuleChainConnections: e.chains,
+	}
+	for index, node := range e.nodes {
+		if node.Id == e.first {
+			result.Metadata.FirstNodeIndex = index
+		}
+	}
+	return result, nil
+}
+
+// templateExpander 模板展开过程
+type templateExpander struct {
+	local       map[string]NodeTemplate
+	nodes       []*RuleNode
+	connections []NodeConnection
+	chains      []RuleChainConnection
+	//first 第一个节点ID
+	first string
+	//depth 节点所在的模板嵌套层数
+	depth map[string]int
+}
+
+// expand 展开第index个实例节点
+func (e *templateExpander) expand(index int) error {
+	instance := e.nodes[index]
+	var config templateInstance
+	if err := maps.Map2Struct(instance.Configuration, &config); err != nil {
+		return err
+	}
+	template, ok := e.local[config.Template]
+	if !ok {
+		if template, ok = Templates.Get(config.Template); !ok {
+			return fmt.Errorf("node id=%s: template not found.template=%s", instance.Id, config.Template)
+		}
+	}
+	depth := e.depth[instance.Id] + 1
+	if depth > maxTemplateDepth {
+		return fmt.Errorf("node id=%s: template nesting exceeds %d levels", instance.Id, maxTemplateDepth)
+	}
+	if len(template.Nodes) == 0 {
+		return fmt.Errorf("template %s nodes can not empty", template.Id)
+	}
+	params, err := templateParams(template, config.Params)
+	if err != nil {
+		return fmt.Errorf("node id=%s: %w", instance.Id, err)
+	}
+	prefix := instance.Id + "/"
+	entry := template.Entry
+	if entry == "" {
+		entry = template.Nodes[0].Id
+	}
+
+	body := make([]*RuleNode, 0, len(template.Nodes))
+	ids := make(map[string]bool, len(template.Nodes))
+	for _, node := range template.Nodes {
+		if node.Id == "" {
+			return fmt.Errorf("template %s node id can not empty", template.Id)
+		}
+		item := *node
+		item.Id = prefix + node.Id
+		item.DebugMode = node.DebugMode || instance.DebugMode
+		configuration, err := replaceTemplateParams(map[string]interface{}(node.Configuration), params)
+		if err != nil {
+			return fmt.Errorf("template %s node id=%s: %w", template.Id, node.Id, err)
+		}
+		item.Configuration, _ = configuration.(map[string]interface{})
+		body = append(body, &item)
+		ids[node.Id] = true
+		e.depth[item.Id] = depth
+	}
+	if !ids[entry] {
+		return fmt.Errorf("template %s entry node id=%s not found", template.Id, entry)
+	}
+
+	connections := make([]NodeConnection, 0, len(e.connections)+len(template.Connections))
+	for _, item := range e.connections {
+		switch {
+		case item.FromId == instance.Id:
+			outputs, err := templateOutputs(template, item.Type)
+			if err != nil {
+				return fmt.Errorf("node id=%s: %w", instance.Id, err)
+			}
+			for _, output := range outputs {
+				connections = append(connections, NodeConnection{FromId: prefix + output.FromId, ToId: item.ToId, Type: output.Type, Description: item.Description})
+			}
+		case item.ToId == instance.Id:
+			item.ToId = prefix + entry
+			connections = append(connections, item)
+		default:
+			connections = append(connections, item)
+		}
+	}
+	for _, item := range template.Connections {
+		if !ids[item.FromId] || !ids[item.ToId] {
+			return fmt.Errorf("template %s connection %s->%s node not found", template.Id, item.FromId, item.ToId)
+		}
+		connections = append(connections, NodeConnection{FromId: prefix + item.FromId, ToId: prefix + item.ToId, Type: item.Type, Description: item.Description})
+	}
+	//出口连接可能指向自身，例如：实例节点Success连接到实例节点
+	for i, item := range connections {
+		if item.ToId == instance.Id {
+			connections[i].ToId = prefix + entry
+		}
+	}
+	e.connections = connections
+
+	chains := make([]RuleChainConnection, 0, len(e.chains))
+	for _, item := range e.chains {
+		if item.FromId != instance.Id {
+			chains = append(chains, item)
+			continue
+		}
+		outputs, err := templateOutputs(template, item.Type)
+		if err != nil {
+			return fmt.Errorf("node id=%s: %w", instance.Id, err)
+		}
+		for _, output := range outputs {
+			chains = append(chains, RuleChainConnection{FromId: prefix + output.FromId, ToId: item.ToId, Type: output.Type, Description: item.Description})
+		}
+	}
+	e.chains = chains
+
+	if e.first == instance.Id {
+		e.first = prefix + entry
+	}
+	nodes := make([]*RuleNode, 0, len(e.nodes)+len(body)-1)
+	nodes = append(nodes, e.nodes[:index]...)
+	nodes = append(nodes, body...)
+	e.nodes = append(nodes, e.nodes[index+1:]...)
+	return nil
+}
+
+// templateOutputs 获取实例节点对外关系类型对应的模板出口
+func templateOutputs(template NodeTemplate, relationType string) ([]NodeConnection, error) {
+	var outputs []NodeConnection
+	for _, output := range template.Outputs {
+		if output.ToId == relationType {
+			outputs = append(outputs, output)
+		}
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("template %s output %s not found", template.Id, relationType)
+	}
+	return outputs, nil
+}
+
+// templateParams 合并模板参数默认值和实例参数
+func templateParams(template NodeTemplate, values map[string]interface{}) (map[string]interface{}, error) {
+	params := make(map[string]interface{}, len(template.Params))
+	for k, v := range template.Params {
+		params[k] = v
+	}
+	for k, v := range values {
+		if _, ok := template.Params[k]; !ok {
+			return nil, fmt.Errorf("template %s param %s not defined", template.Id, k)
+		}
+		params[k] = v
+	}
+	for k, v := range params {
+		if v == nil {
+			return nil, fmt.Errorf("template %s param %s is required", template.Id, k)
+		}
+	}
+	return params, nil
+}
+
+// replaceTemplateParams 替换配置中的{{参数名}}，返回新的配置，不修改模板定义
+func replaceTemplateParams(value interface{}, params map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return v, nil
+		}
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			replaced, err := replaceTemplateParams(item, params)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = replaced
+		}
+		return result, nil
+	case types.Configuration:
+		return replaceTemplateParams(map[string]interface{}(v), params)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			replaced, err := replaceTemplateParams(item, params)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = replaced
+		}
+		return result, nil
+	case string:
+		matches := templateParamRegex.FindAllStringSubmatchIndex(v, -1)
+		if len(matches) == 0 {
+			return v, nil
+		}
+		for _, match := range matches {
+			if _, ok := params[v[match[2]:match[3]]]; !ok {
+				return nil, fmt.Errorf("param %s not defined", v[match[2]:match[3]])
+			}
+		}
+		//只有参数引用，保留参数值类型
+		if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(v) {
+			return params[v[matches[0][2]:matches[0][3]]], nil
+		}
+		return templateParamRegex.ReplaceAllStringFunc(v, func(s string) string {
+			name := templateParamRegex.FindStringSubmatch(s)[1]
+			return fmt.Sprint(params[name])
+		}), nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rulego
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// validateEnrichTemplate 校验温度并补全元数据的模板
+var validateEnrichTemplate = NodeTemplate{
+	Id:     "validateEnrich",
+	Params: map[string]interface{}{"threshold": nil, "key": "checked"},
+	Nodes: []*RuleNode{
+		{Id: "validate", Type: "jsFilter", Configuration: types.Configuration{"jsScript": "return msg.temperature > {{threshold}};"}},
+		{Id: "enrich", Type: "jsTransform", Configuration: types.Configuration{"jsScript": "metadata['{{key}}']='true';return {'msg':msg,'metadata':metadata,'msgType':msgType};"}},
+	},
+	Connections: []NodeConnection{{FromId: "validate", ToId: "enrich", Type: types.True}},
+	Outputs: []NodeConnection{
+		{FromId: "enrich", Type: types.Success, ToId: types.Success},
+		{FromId: "validate", Type: types.False, ToId: "Invalid"},
+	},
+}
+
+func TestExpandTemplates(t *testing.T) {
+	def, err := NewChainBuilder().Id("template01").Template(validateEnrichTemplate).
+		Node("jsTransform", types.Configuration{"jsScript": "return {'msg':msg,'metadata':metadata,'msgType':msgType};"}).
+		On(types.Success).
+		NodeWithId("check", TemplateNodeType, types.Configuration{"template": "validateEnrich", "params": map[string]interface{}{"threshold": 50}}).
+		On("Invalid").
+		Node("log", types.Configuration{"jsScript": "return 'invalid';"}).
+		Build()
+	assert.Nil(t, err)
+	def.Metadata.FirstNodeIndex = 1
+
+	expanded, err := ExpandTemplates(def)
+	assert.Nil(t, err)
+	var ids []string
+	for _, node := range expanded.Metadata.Nodes {
+		ids = append(ids, node.Id)
+	}
+	assert.Equal(t, []string{"s1", "check/validate", "check/enrich", "s3"}, ids)
+	assert.Equal(t, 1, expanded.Metadata.FirstNodeIndex)
+	assert.Equal(t, "return msg.temperature > 50;", expanded.Metadata.Nodes[1].Configuration["jsScript"])
+	assert.True(t, strings.Contains(expanded.Metadata.Nodes[2].Configuration["jsScript"].(string), "metadata['checked']"))
+	assert.Equal(t, []NodeConnection{
+		{FromId: "s1", ToId: "check/validate", Type: types.Success},
+		{FromId: "check/validate", ToId: "s3", Type: types.False},
+		{FromId: "check/validate", ToId: "check/enrich", Type: types.True},
+	}, expanded.Metadata.Connections)
+	//原定义和模板不变
+	assert.Equal(t, TemplateNodeType, def.Metadata.Nodes[1].Type)
+	assert.Equal(t, "return msg.temperature > {{threshold}};", validateEnrichTemplate.Nodes[0].Configuration["jsScript"])
+
+	//只有参数引用的配置保留参数值类型
+	value, err := replaceTemplateParams(map[string]interface{}{"n": "{{n}}", "s": "v{{n}}", "list": []interface{}{"{{ n }}"}}, map[string]interface{}{"n": 3})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"n": 3, "s": "v3", "list": []interface{}{3}}, value)
+}
+
+func TestExpandTemplatesError(t *testing.T) {
+	nested := NodeTemplate{Id: "nested", Nodes: []*RuleNode{
+		{Id: "inner", Type: TemplateNodeType, Configuration: types.Configuration{"template": "nested"}},
+	}}
+	for _, item := range []struct {
+		configuration types.Configuration
+		relation      string
+		err           string
+	}{
+		{types.Configuration{"template": "unknown"}, "", "template not found"},
+		{types.Configuration{"template": "validateEnrich"}, "", "param threshold is required"},
+		{types.Configuration{"template": "validateEnrich", "params": map[string]interface{}{"threshold": 1, "other": 1}}, "", "param other not defined"},
+		{types.Configuration{"template": "validateEnrich", "params": map[string]interface{}{"threshold": 1}}, types.Failure, "output Failure not found"},
+		{types.Configuration{"template": "nested"}, "", "nesting exceeds"},
+	} {
+		b := NewChainBuilder().Template(validateEnrichTemplate).Template(nested).
+			NodeWithId("t1", TemplateNodeType, item.configuration)
+		if item.relation != "" {
+			b.On(item.relation).Node("log", types.Configuration{"jsScript": "return 'x';"})
+		}
+		def, err := b.Build()
+		assert.Nil(t, err)
+		_, err = ExpandTemplates(def)
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), item.err))
+	}
+}
+
+func TestTemplateRuleChain(t *testing.T) {
+	assert.Nil(t, Templates.Register(validateEnrichTemplate))
+	defer Templates.Unregister(validateEnrichTemplate.Id)
+
+	//同一个模板实例化两次
+	ruleEngine, err := NewChainBuilder().Id("template02").
+		NodeWithId("high", TemplateNodeType, types.Configuration{"template": "validateEnrich", "params": map[string]interface{}{"threshold": 50, "key": "high"}}).
+		On(types.Success).
+		NodeWithId("veryHigh", TemplateNodeType, types.Configuration{"template": "validateEnrich", "params": map[string]interface{}{"threshold": 80, "key": "veryHigh"}}).
+		From("high").On("Invalid").
+		NodeWithId("low", "jsTransform", types.Configuration{"jsScript": "metadata['low']='true';return {'msg':msg,'metadata':metadata,'msgType':msgType};"}).
+		New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Del("template02")
+	//导出的DSL保留模板实例节点
+	assert.True(t, strings.Contains(string(ruleEngine.DSL()), TemplateNodeType))
+
+	send := func(data string) types.RuleMsg {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		var result types.RuleMsg
+		ruleEngine.OnMsgWithEndFunc(types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), data), func(msg types.RuleMsg, err error) {
+			result = msg
+			wg.Done()
+		})
+		waitTimeout(t, &wg, time.Second*3)
+		return result
+	}
+	msg := send(`{"temperature":90}`)
+	assert.Equal(t, "true", msg.Metadata.GetValue("high"))
+	assert.Equal(t, "true", msg.Metadata.GetValue("veryHigh"))
+	msg = send(`{"temperature":30}`)
+	assert.Equal(t, "true", msg.Metadata.GetValue("low"))
+	assert.False(t, msg.Metadata.Has("high"))
+}
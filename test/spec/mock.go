@@ -0,0 +1,104 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spec
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/utils/maps"
+)
+
+// MockNodeType 模拟节点组件类型
+const MockNodeType = "specMock"
+
+// MockNodeConfiguration 模拟节点配置
+type MockNodeConfiguration struct {
+	//Relation 发送到下一个节点的关系，默认：Success
+	Relation string
+	//MsgType 替换消息类型
+	MsgType string
+	//Data 替换消息内容
+	Data string
+	//ReplaceData 是否替换消息内容
+	ReplaceData bool
+	//Metadata 合并到元数据
+	Metadata map[string]string
+	//Error 如果配置，则以该错误发送到`Failure`链
+	Error string
+}
+
+// MockNode 使用配置的响应代替被模拟的节点
+type MockNode struct {
+	config MockNodeConfiguration
+}
+
+// Type 组件类型
+func (x *MockNode) Type() string {
+	return MockNodeType
+}
+
+func (x *MockNode) New() types.Node {
+	return &MockNode{config: MockNodeConfiguration{Relation: types.Success}}
+}
+
+// Init 初始化
+func (x *MockNode) Init(ruleConfig types.Config, configuration types.Configuration) error {
+	return maps.Map2Struct(configuration, &x.config)
+}
+
+// OnMsg 处理消息
+func (x *MockNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) error {
+	if x.config.MsgType != "" {
+		msg.Type = x.config.MsgType
+	}
+	if x.config.ReplaceData {
+		msg.Data = x.config.Data
+	}
+	for k, v := range x.config.Metadata {
+		msg.Metadata.PutValue(k, v)
+	}
+	if x.config.Error != "" {
+		ctx.TellFailure(msg, errors.New(x.config.Error))
+	} else {
+		ctx.TellNext(msg, x.config.Relation)
+	}
+	return nil
+}
+
+// Destroy 销毁
+func (x *MockNode) Destroy() {
+}
+
+// configuration 转换成模拟节点配置
+func (m Mock) configuration() types.Configuration {
+	relation := m.Relation
+	if relation == "" {
+		relation = types.Success
+	}
+	metadata := make(map[string]interface{}, len(m.Metadata))
+	for k, v := range m.Metadata {
+		metadata[k] = v
+	}
+	return types.Configuration{
+		"relation":    relation,
+		"msgType":     m.MsgType,
+		"data":        rawToString(m.Data),
+		"replaceData": len(m.Data) > 0,
+		"metadata":    metadata,
+		"error":       m.Error,
+	}
+}
@@ -0,0 +1,316 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/2018yuli/rulego"
+	"github.com/2018yuli/rulego/api/types"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultTimeout 默认每个用例最大执行时间
+	DefaultTimeout = 10 * time.Second
+	//DefaultIdle 规则链结束后，等待其他分支结束的时间
+	DefaultIdle = 200 * time.Millisecond
+)
+
+// Event 节点调试事件
+type Event struct {
+	FlowType     string
+	NodeId       string
+	RelationType string
+	Msg          types.RuleMsg
+	Err          error
+}
+
+// Result 规则链结束结果
+type Result struct {
+	Msg types.RuleMsg
+	Err error
+}
+
+// Trace 记录一条消息在规则链的处理过程
+type Trace struct {
+	sync.Mutex
+	Events  []Event
+	Results []Result
+	//routes 节点ID->有下一个节点的关系
+	routes map[string]map[string]bool
+	notify chan struct{}
+}
+
+func newTrace(def rulego.RuleChain) *Trace {
+	t := &Trace{routes: make(map[string]map[string]bool), notify: make(chan struct{}, 1)}
+	for _, item := range def.Metadata.Connections {
+		t.addRoute(item.FromId, item.Type)
+	}
+	for _, item := range def.Metadata.RuleChainConnections {
+		t.addRoute(item.FromId, item.Type)
+	}
+	return t
+}
+
+func (t *Trace) addRoute(nodeId, relationType string) {
+	if t.routes[nodeId] == nil {
+		t.routes[nodeId] = make(map[string]bool)
+	}
+	t.routes[nodeId][relationType] = true
+}
+
+func (t *Trace) onDebug(flowType string, nodeId string, msg types.RuleMsg, relationType string, err error) {
+	t.Lock()
+	t.Events = append(t.Events, Event{FlowType: flowType, NodeId: nodeId, RelationType: relationType, Msg: msg, Err: err})
+	t.Unlock()
+	t.touch()
+}
+
+func (t *Trace) onEnd(msg types.RuleMsg, err error) {
+	t.Lock()
+	t.Results = append(t.Results, Result{Msg: msg, Err: err})
+	t.Unlock()
+	t.touch()
+}
+
+func (t *Trace) touch() {
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+// wait 等待消息处理完成：至少结束一次，并且idle时间内没有新的事件
+// 超时返回false
+func (t *Trace) wait(timeout, idle time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case <-t.notify:
+		case <-time.After(idle):
+			t.Lock()
+			done := len(t.Results) > 0
+			t.Unlock()
+			if done {
+				return true
+			}
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// Path 消息依次流入的节点ID
+func (t *Trace) Path() []string {
+	t.Lock()
+	defer t.Unlock()
+	var nodeIds []string
+	for _, item := range t.Events {
+		if item.FlowType == types.In {
+			nodeIds = append(nodeIds, item.NodeId)
+		}
+	}
+	return nodeIds
+}
+
+// EndRelations 规则链结束时，最后一个节点发出的关系
+func (t *Trace) EndRelations() []string {
+	t.Lock()
+	defer t.Unlock()
+	var relations []string
+	for _, item := range t.Events {
+		if item.FlowType == types.Out && !t.routes[item.NodeId][item.RelationType] {
+			relations = append(relations, item.RelationType)
+		}
+	}
+	return relations
+}
+
+// Check 检查处理结果是否满足期望
+func (t *Trace) Check(then Then) error {
+	if len(then.Path) > 0 {
+		if path := t.Path(); !reflect.DeepEqual(then.Path, path) {
+			return fmt.Errorf("path: expected %v, actual %v", then.Path, path)
+		}
+	}
+	if then.Relation != "" {
+		relations := t.EndRelations()
+		found := false
+		for _, item := range relations {
+			found = found || item == then.Relation
+		}
+		if !found {
+			return fmt.Errorf("relation: expected %s, actual %v", then.Relation, relations)
+		}
+	}
+	t.Lock()
+	defer t.Unlock()
+	var lastErr error
+	for _, item := range t.Results {
+		if lastErr = checkResult(then, item); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func checkResult(then Then, actual Result) error {
+	if then.Error == nil && actual.Err != nil {
+		return fmt.Errorf("unexpected error: %s", actual.Err)
+	}
+	if then.Error != nil {
+		if actual.Err == nil {
+			return fmt.Errorf("expected error containing %q", *then.Error)
+		}
+		if !strings.Contains(actual.Err.Error(), *then.Error) {
+			return fmt.Errorf("error: expected containing %q, actual %q", *then.Error, actual.Err)
+		}
+	}
+	if then.MsgType != "" && then.MsgType != actual.Msg.Type {
+		return fmt.Errorf("msgType: expected %s, actual %s", then.MsgType, actual.Msg.Type)
+	}
+	for k, v := range then.Metadata {
+		if value := actual.Msg.Metadata.GetValue(k); value == nil || fmt.Sprint(value) != v {
+			return fmt.Errorf("metadata %s: expected %s, actual %v", k, v, value)
+		}
+	}
+	if len(then.Data) > 0 && !dataEqual(then.Data, actual.Msg.Data) {
+		return fmt.Errorf("data: expected %s, actual %s", then.Data, actual.Msg.Data)
+	}
+	return nil
+}
+
+// dataEqual 期望值是json字符串则完全匹配，否则按json语义匹配
+func dataEqual(expect json.RawMessage, data string) bool {
+	var s string
+	if err := json.Unmarshal(expect, &s); err == nil {
+		return s == data
+	}
+	var expectValue, actualValue interface{}
+	if err := json.Unmarshal(expect, &expectValue); err != nil {
+		return false
+	}
+	if err := json.Unmarshal([]byte(data), &actualValue); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(expectValue, actualValue)
+}
+
+// Run 把消息交给规则链处理，并等待处理完成
+// 规则链所有节点开启调试模式，mocks中的节点替换成模拟节点
+func Run(def rulego.RuleChain, mocks map[string]Mock, msg types.RuleMsg, timeout time.Duration) (*Trace, error) {
+	def = mockChain(def, mocks)
+	for _, item := range def.Metadata.Nodes {
+		item.DebugMode = true
+	}
+	dsl, err := json.Marshal(def)
+	if err != nil {
+		return nil, err
+	}
+	t := newTrace(def)
+	config := rulego.NewConfig()
+	config.ComponentsRegistry = newRegistry()
+	config.OnDebug = t.onDebug
+	ruleGo := &rulego.RuleGo{}
+	ruleEngine, err := ruleGo.New("", dsl, rulego.WithConfig(config))
+	if err != nil {
+		return nil, err
+	}
+	defer ruleEngine.Stop()
+	ruleEngine.OnMsgWithEndFunc(msg, t.onEnd)
+	if !t.wait(timeout, DefaultIdle) {
+		return t, fmt.Errorf("timeout after %s", timeout)
+	}
+	return t, nil
+}
+
+// mockChain 复制规则链定义，并把被模拟的节点替换成模拟节点
+func mockChain(def rulego.RuleChain, mocks map[string]Mock) rulego.RuleChain {
+	nodes := make([]*rulego.RuleNode, 0, len(def.Metadata.Nodes))
+	for _, item := range def.Metadata.Nodes {
+		node := *item
+		if mock, ok := mocks[node.Id]; ok {
+			node.Type = MockNodeType
+			node.Configuration = mock.configuration()
+		}
+		nodes = append(nodes, &node)
+	}
+	def.Metadata.Nodes = nodes
+	return def
+}
+
+// newRegistry 创建包含默认组件和模拟组件的注册器
+func newRegistry() types.ComponentRegistry {
+	registry := new(rulego.RuleComponentRegistry)
+	for _, node := range rulego.Registry.GetComponents() {
+		_ = registry.Register(node)
+	}
+	_ = registry.Register(&MockNode{})
+	return registry
+}
+
+// CaseResult 用例执行结果
+type CaseResult struct {
+	//Name 用例名称
+	Name string
+	//Trace 处理过程
+	Trace *Trace
+	//Err 用例失败原因，nil表示通过
+	Err error
+}
+
+// Run 执行所有用例
+func (s *Spec) Run(timeout time.Duration) ([]CaseResult, error) {
+	b, err := os.ReadFile(s.ChainFile())
+	if err != nil {
+		return nil, err
+	}
+	def, err := rulego.ParserRuleChain(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.ChainFile(), err)
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	var results []CaseResult
+	for index, item := range s.Cases {
+		name := item.Name
+		if name == "" {
+			name = fmt.Sprintf("case%d", index)
+		}
+		t, err := Run(def, s.mocks(item), item.When.Msg.NewMsg(), timeout)
+		if err == nil {
+			err = t.Check(item.Then)
+		}
+		results = append(results, CaseResult{Name: name, Trace: t, Err: err})
+	}
+	return results, nil
+}
+
+// RunFile 加载用例文件并执行所有用例
+func RunFile(file string, timeout time.Duration) ([]CaseResult, error) {
+	spec, err := Load(file)
+	if err != nil {
+		return nil, err
+	}
+	return spec.Run(timeout)
+}
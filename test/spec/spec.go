@@ -0,0 +1,212 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package spec 规则链测试用例DSL
+// 使用JSON或者YAML描述输入消息(when)、模拟的节点响应(given)和期望的结束消息和关系(then)，
+// 通过`rulego test`命令或者 RunT 执行，不需要编写Go代码即可测试规则链
+//
+//	chain: chain.json
+//	given:
+//	  mocks:
+//	    s3: {relation: Success, data: '{"status":"ok"}'}
+//	cases:
+//	  - name: high temperature
+//	    when:
+//	      msg: {type: TELEMETRY, metadata: {deviceId: d1}, data: {temperature: 60}}
+//	    then:
+//	      relation: Success
+//	      msgType: ALARM
+//	      metadata: {alarm: "true"}
+//	      path: [s1, s2, s3]
+package spec
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/2018yuli/rulego/api/types"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Spec 规则链测试用例文件
+type Spec struct {
+	//Chain 规则链文件，相对路径基于用例文件所在目录
+	Chain string `json:"chain"`
+	//Given 所有用例共享的前置条件，用例可以覆盖
+	Given Given `json:"given"`
+	//Cases 用例列表
+	Cases []Case `json:"cases"`
+	//dir 用例文件所在目录
+	dir string
+}
+
+// Case 测试用例
+type Case struct {
+	//Name 用例名称
+	Name string `json:"name"`
+	//Given 前置条件
+	Given Given `json:"given"`
+	//When 输入消息
+	When When `json:"when"`
+	//Then 期望结果
+	Then Then `json:"then"`
+}
+
+// Given 前置条件
+type Given struct {
+	//Mocks 节点ID->模拟响应，模拟的节点不会初始化和执行原组件
+	Mocks map[string]Mock `json:"mocks"`
+}
+
+// Mock 模拟节点响应
+type Mock struct {
+	//Relation 发送到下一个节点的关系，默认：Success
+	Relation string `json:"relation"`
+	//MsgType 替换消息类型
+	MsgType string `json:"msgType"`
+	//Data 替换消息内容，字符串原样替换，其他json值序列化后替换
+	Data json.RawMessage `json:"data"`
+	//Metadata 合并到元数据
+	Metadata map[string]string `json:"metadata"`
+	//Error 如果配置，则以该错误发送到`Failure`链
+	Error string `json:"error"`
+}
+
+// When 输入
+type When struct {
+	//Msg 输入消息
+	Msg Msg `json:"msg"`
+}
+
+// Msg 消息
+type Msg struct {
+	//Type 消息类型
+	Type string `json:"type"`
+	//DataType 数据类型，默认：JSON
+	DataType string `json:"dataType"`
+	//Metadata 元数据
+	Metadata map[string]interface{} `json:"metadata"`
+	//Data 字符串原样作为消息内容，其他json值序列化后作为消息内容
+	Data json.RawMessage `json:"data"`
+}
+
+// Then 期望结果，规则链任意一个结束结果满足所有配置的条件则通过
+type Then struct {
+	//Relation 规则链结束时最后一个节点发出的关系，例如：Success、Failure、False
+	Relation string `json:"relation"`
+	//MsgType 消息类型
+	MsgType string `json:"msgType"`
+	//Data 字符串完全匹配，其他json值按json语义匹配
+	Data json.RawMessage `json:"data"`
+	//Metadata 包含这些元数据
+	Metadata map[string]string `json:"metadata"`
+	//Error 不配置表示没有错误，否则错误信息包含该字符串
+	Error *string `json:"error"`
+	//Path 消息依次流入的节点ID
+	Path []string `json:"path"`
+}
+
+// Load 加载用例文件，.yaml和.yml后缀使用YAML格式解析，其他使用JSON格式解析
+func Load(file string) (*Spec, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var spec *Spec
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == ".yaml" || ext == ".yml" {
+		spec, err = ParseYaml(b)
+	} else {
+		spec, err = Parse(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	spec.dir = filepath.Dir(file)
+	return spec, nil
+}
+
+// Parse 解析JSON格式用例
+func Parse(b []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(b, &spec); err != nil {
+		return nil, err
+	}
+	if spec.Chain == "" {
+		return nil, errors.New("chain can not empty")
+	}
+	return &spec, nil
+}
+
+// ParseYaml 解析YAML格式用例
+func ParseYaml(b []byte) (*Spec, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// ChainFile 规则链文件路径
+func (s *Spec) ChainFile() string {
+	if filepath.IsAbs(s.Chain) || s.dir == "" {
+		return s.Chain
+	}
+	return filepath.Join(s.dir, s.Chain)
+}
+
+// mocks 合并公共模拟和用例模拟
+func (s *Spec) mocks(c Case) map[string]Mock {
+	mocks := make(map[string]Mock, len(s.Given.Mocks)+len(c.Given.Mocks))
+	for k, v := range s.Given.Mocks {
+		mocks[k] = v
+	}
+	for k, v := range c.Given.Mocks {
+		mocks[k] = v
+	}
+	return mocks
+}
+
+// NewMsg 把用例消息转换成规则引擎消息
+func (m Msg) NewMsg() types.RuleMsg {
+	metadata := types.NewMetadata()
+	for k, v := range m.Metadata {
+		metadata.PutValue(k, v)
+	}
+	dataType := types.DataType(strings.ToUpper(m.DataType))
+	if dataType == "" {
+		dataType = types.JSON
+	}
+	return types.NewMsg(0, m.Type, dataType, metadata, rawToString(m.Data))
+}
+
+// rawToString json字符串返回字符串的值，其他json值返回原文
+func rawToString(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
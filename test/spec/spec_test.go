@@ -0,0 +1,66 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spec
+
+import (
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+	"time"
+)
+
+func TestRunT(t *testing.T) {
+	RunT(t, "testdata/alarm_spec.yaml")
+	RunT(t, "testdata/alarm_spec.json")
+}
+
+func TestLoad(t *testing.T) {
+	spec, err := Load("testdata/alarm_spec.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "testdata/chain.json", spec.ChainFile())
+	assert.Equal(t, 3, len(spec.Cases))
+	assert.Equal(t, "connection refused", spec.mocks(spec.Cases[2])["s3"].Error)
+	assert.Equal(t, "", spec.mocks(spec.Cases[0])["s3"].Error)
+
+	msg := spec.Cases[0].When.Msg.NewMsg()
+	assert.Equal(t, "TELEMETRY", msg.Type)
+	assert.Equal(t, types.JSON, msg.DataType)
+	assert.Equal(t, "d1", msg.Metadata.GetValue("deviceId"))
+	assert.Equal(t, "{\"temperature\":60}", msg.Data)
+
+	_, err = Parse([]byte(`{"cases":[]}`))
+	assert.NotNil(t, err)
+	_, err = Load("testdata/notFound.yaml")
+	assert.NotNil(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	spec, err := Load("testdata/alarm_spec.yaml")
+	assert.Nil(t, err)
+	results, err := spec.Run(time.Second * 5)
+	assert.Nil(t, err)
+	trace := results[0].Trace
+	assert.Nil(t, trace.Check(Then{MsgType: "ALARM"}))
+	assert.Equal(t, []string{"Success"}, trace.EndRelations())
+	assert.NotNil(t, trace.Check(Then{Relation: "Failure"}))
+	assert.NotNil(t, trace.Check(Then{MsgType: "TELEMETRY"}))
+	assert.NotNil(t, trace.Check(Then{Path: []string{"s1"}}))
+	assert.NotNil(t, trace.Check(Then{Metadata: map[string]string{"alarm": "false"}}))
+	assert.NotNil(t, trace.Check(Then{Data: []byte(`{"status":"error"}`)}))
+	errMsg := ""
+	assert.NotNil(t, trace.Check(Then{Error: &errMsg}))
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package spec
+
+import (
+	"testing"
+)
+
+// RunT 在Go测试中执行用例文件，每个用例作为一个子测试
+//
+//	func TestAlarmChain(t *testing.T) {
+//		spec.RunT(t, "testdata/alarm_spec.yaml")
+//	}
+func RunT(t *testing.T, file string) {
+	t.Helper()
+	results, err := RunFile(file, DefaultTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range results {
+		result := item
+		t.Run(result.Name, func(t *testing.T) {
+			if result.Err != nil {
+				t.Error(result.Err)
+			}
+		})
+	}
+}
@@ -30,6 +30,7 @@ type NodeTestRuleContext struct {
 	config   types.Config
 	context  context.Context
 	callback func(msg types.RuleMsg, relationType string)
+	onEnd    func(msg types.RuleMsg, err error)
 }
 
 func NewRuleContext(config types.Config, callback func(msg types.RuleMsg, relationType string)) types.RuleContext {
@@ -52,12 +53,12 @@ func (ctx *NodeTestRuleContext) TellNext(msg types.RuleMsg, relationTypes ...str
 
 }
 func (ctx *NodeTestRuleContext) TellSelf(msg types.RuleMsg, delayMs int64) {
-	time.AfterFunc(time.Millisecond*time.Duration(delayMs), func() {
+	ctx.config.GetClock().AfterFunc(time.Millisecond*time.Duration(delayMs), func() {
 		ctx.callback(msg, types.Success)
 	})
 }
 func (ctx *NodeTestRuleContext) NewMsg(msgType string, metaData types.Metadata, data string) types.RuleMsg {
-	return types.NewMsg(0, msgType, types.JSON, metaData, data)
+	return types.NewMsg(ctx.config.GetClock().Now().UnixMilli(), msgType, types.JSON, metaData, data)
 }
 func (ctx *NodeTestRuleContext) GetSelfId() string {
 	return ""
@@ -71,12 +72,29 @@ func (ctx *NodeTestRuleContext) SubmitTack(task func()) {
 	go task()
 }
 
+func (ctx *NodeTestRuleContext) GetState(key string) (string, bool, error) {
+	return ctx.config.GetStateStore().Get(key)
+}
+
+func (ctx *NodeTestRuleContext) SetState(key string, value string) error {
+	return ctx.config.GetStateStore().Set(key, value)
+}
+
+func (ctx *NodeTestRuleContext) Async(msg types.RuleMsg, timeout time.Duration) types.AsyncCompletion {
+	return types.NewAsyncCompletion(ctx, msg, timeout, nil)
+}
+
+func (ctx *NodeTestRuleContext) Stream(msg types.RuleMsg, idleTimeout time.Duration) types.Stream {
+	return types.NewStream(ctx, msg, idleTimeout, nil, nil)
+}
+
 func (ctx *NodeTestRuleContext) SetEndFunc(onEndFunc func(msg types.RuleMsg, err error)) types.RuleContext {
+	ctx.onEnd = onEndFunc
 	return ctx
 }
 
 func (ctx *NodeTestRuleContext) GetEndFunc() func(msg types.RuleMsg, err error) {
-	return nil
+	return ctx.onEnd
 }
 
 func (ctx *NodeTestRuleContext) SetContext(c context.Context) types.RuleContext {
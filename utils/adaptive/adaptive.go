@@ -0,0 +1,251 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package adaptive 根据观测到的延迟自动调整并发数的限流器
+// 参考gradient算法：短期延迟明显高于长期延迟时说明下游开始排队，减少并发上限；延迟稳定并且并发已经用满时逐步增加上限，
+// 请求失败或者超时按比例减少上限，不需要人工配置下游服务能承受的并发数
+package adaptive
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"github.com/2018yuli/rulego/utils/clock"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrLimitExceeded 正在执行的请求数达到并发上限，并且等待MaxWaitMs后仍然没有空闲
+var ErrLimitExceeded = errors.New("concurrency limit exceeded")
+
+// dropBackoff 请求失败或者超时时上限的缩减比例
+const dropBackoff = 0.9
+
+// Config 自适应限流配置
+type Config struct {
+	//InitialLimit 初始并发上限，默认20
+	InitialLimit int `json:"initialLimit"`
+	//MinLimit 最小并发上限，默认1
+	MinLimit int `json:"minLimit"`
+	//MaxLimit 最大并发上限，默认200
+	MaxLimit int `json:"maxLimit"`
+	//Tolerance 短期延迟超过长期延迟多少倍开始减少上限，默认1.5
+	Tolerance float64 `json:"tolerance"`
+	//Smoothing 每次调整上限的平滑系数，范围(0,1]，默认0.2
+	Smoothing float64 `json:"smoothing"`
+	//LongWindow 长期延迟平均的样本数，默认100
+	LongWindow int `json:"longWindow"`
+	//MaxWaitMs 达到并发上限时最多等待多少毫秒，0表示不等待直接返回ErrLimitExceeded
+	MaxWaitMs int `json:"maxWaitMs"`
+}
+
+// withDefaults 填充默认值
+func (c Config) withDefaults() Config {
+	if c.MinLimit <= 0 {
+		c.MinLimit = 1
+	}
+	if c.MaxLimit <= 0 {
+		c.MaxLimit = 200
+	}
+	if c.MaxLimit < c.MinLimit {
+		c.MaxLimit = c.MinLimit
+	}
+	if c.InitialLimit <= 0 {
+		c.InitialLimit = 20
+	}
+	if c.InitialLimit < c.MinLimit {
+		c.InitialLimit = c.MinLimit
+	}
+	if c.InitialLimit > c.MaxLimit {
+		c.InitialLimit = c.MaxLimit
+	}
+	if c.Tolerance <= 0 {
+		c.Tolerance = 1.5
+	}
+	if c.Smoothing <= 0 || c.Smoothing > 1 {
+		c.Smoothing = 0.2
+	}
+	if c.LongWindow <= 0 {
+		c.LongWindow = 100
+	}
+	return c
+}
+
+// waiter 等待并发空闲的请求
+type waiter struct {
+	ch      chan struct{}
+	granted bool
+	element *list.Element
+}
+
+// Limiter 自适应并发限流器，并发安全
+type Limiter struct {
+	config Config
+	clock  clock.Clock
+	lock   sync.Mutex
+	limit  float64
+	//inflight 正在执行的请求数
+	inflight int
+	//shortRtt 最近一次请求的延迟，longRtt 长期平均延迟，单位纳秒
+	shortRtt float64
+	longRtt  float64
+	waiters  *list.List
+}
+
+// New 创建自适应限流器，clk为空使用系统时钟
+func New(config Config, clk clock.Clock) *Limiter {
+	config = config.withDefaults()
+	if clk == nil {
+		clk = clock.System
+	}
+	return &Limiter{config: config, clock: clk, limit: float64(config.InitialLimit), waiters: list.New()}
+}
+
+// Limit 当前并发上限
+func (l *Limiter) Limit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return int(l.limit)
+}
+
+// Inflight 正在执行的请求数
+func (l *Limiter) Inflight() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.inflight
+}
+
+// Acquire 获取执行许可，请求完成后必须调用返回的done，dropped表示请求失败或者超时
+// 达到并发上限时最多等待MaxWaitMs，ctx取消或者等待超时返回错误
+func (l *Limiter) Acquire(ctx context.Context) (done func(dropped bool), err error) {
+	l.lock.Lock()
+	if l.inflight < int(l.limit) && l.waiters.Len() == 0 {
+		l.inflight++
+		l.lock.Unlock()
+		return l.done(l.clock.Now()), nil
+	}
+	if l.config.MaxWaitMs <= 0 {
+		l.lock.Unlock()
+		return nil, ErrLimitExceeded
+	}
+	w := &waiter{ch: make(chan struct{})}
+	w.element = l.waiters.PushBack(w)
+	l.lock.Unlock()
+
+	timeout := make(chan struct{})
+	timer := l.clock.AfterFunc(time.Duration(l.config.MaxWaitMs)*time.Millisecond, func() {
+		close(timeout)
+	})
+	defer timer.Stop()
+	select {
+	case <-w.ch:
+		return l.done(l.clock.Now()), nil
+	case <-timeout:
+		err = ErrLimitExceeded
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if w.granted {
+		//超时的同时获得了许可
+		return l.done(l.clock.Now()), nil
+	}
+	l.waiters.Remove(w.element)
+	return nil, err
+}
+
+// done 创建请求完成回调，只有第一次调用有效
+func (l *Limiter) done(start time.Time) func(dropped bool) {
+	var once sync.Once
+	return func(dropped bool) {
+		once.Do(func() {
+			l.release(l.clock.Now().Sub(start), dropped)
+		})
+	}
+}
+
+// release 根据请求延迟调整并发上限，然后唤醒等待的请求
+func (l *Limiter) release(rtt time.Duration, dropped bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	inflight := l.inflight
+	l.inflight--
+	if dropped {
+		l.setLimit(l.limit * dropBackoff)
+	} else {
+		l.sample(float64(rtt), inflight)
+	}
+	for l.waiters.Len() > 0 && l.inflight < int(l.limit) {
+		w := l.waiters.Remove(l.waiters.Front()).(*waiter)
+		w.granted = true
+		l.inflight++
+		close(w.ch)
+	}
+}
+
+// sample 记录请求延迟并计算新的并发上限，调用方需要持有锁
+func (l *Limiter) sample(rtt float64, inflight int) {
+	if rtt <= 0 {
+		rtt = 1
+	}
+	l.shortRtt = rtt
+	if l.longRtt == 0 {
+		l.longRtt = rtt
+	} else {
+		l.longRtt += (rtt - l.longRtt) / float64(l.config.LongWindow)
+	}
+	//长期延迟远高于当前延迟，说明之前的高延迟已经恢复，加快长期延迟下降
+	if l.longRtt/l.shortRtt > 2 {
+		l.longRtt *= 0.95
+	}
+	//并发没有用到一半，延迟不能反映下游的承受能力，不调整上限
+	if float64(inflight) < l.limit/2 {
+		return
+	}
+	gradient := math.Max(0.5, math.Min(1, l.config.Tolerance*l.longRtt/l.shortRtt))
+	newLimit := l.limit*gradient + math.Sqrt(l.limit)
+	l.setLimit(l.limit*(1-l.config.Smoothing) + newLimit*l.config.Smoothing)
+}
+
+// setLimit 设置并发上限，限制在MinLimit和MaxLimit之间
+func (l *Limiter) setLimit(limit float64) {
+	l.limit = math.Max(float64(l.config.MinLimit), math.Min(float64(l.config.MaxLimit), limit))
+}
+
+// Registry 按目标共享的限流器，例如相同主机的REST请求共享一个限流器
+type Registry struct {
+	lock     sync.Mutex
+	limiters map[string]*Limiter
+}
+
+// NewRegistry 创建限流器注册表
+func NewRegistry() *Registry {
+	return &Registry{limiters: make(map[string]*Limiter)}
+}
+
+// Get 获取目标的限流器，不存在则使用config创建，已经存在则忽略config
+func (r *Registry) Get(target string, config Config, clk clock.Clock) *Limiter {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	limiter, ok := r.limiters[target]
+	if !ok {
+		limiter = New(config, clk)
+		r.limiters[target] = limiter
+	}
+	return limiter
+}
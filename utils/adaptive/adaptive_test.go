@@ -0,0 +1,146 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package adaptive
+
+import (
+	"context"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+// round 获取当前上限数量的许可，经过rtt后全部完成，返回完成前的上限
+func round(t *testing.T, l *Limiter, vc *clock.Virtual, rtt time.Duration) int {
+	limit := l.Limit()
+	var dones []func(bool)
+	for i := 0; i < limit; i++ {
+		done, err := l.Acquire(context.Background())
+		assert.Nil(t, err)
+		dones = append(dones, done)
+	}
+	vc.Advance(rtt)
+	for _, done := range dones {
+		done(false)
+	}
+	return limit
+}
+
+func TestLimiter(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	l := New(Config{InitialLimit: 4, MaxLimit: 50}, vc)
+	var dones []func(bool)
+	for i := 0; i < 4; i++ {
+		done, err := l.Acquire(context.Background())
+		assert.Nil(t, err)
+		dones = append(dones, done)
+	}
+	//达到上限不等待
+	_, err := l.Acquire(context.Background())
+	assert.Equal(t, ErrLimitExceeded, err)
+	assert.Equal(t, 4, l.Inflight())
+	for _, done := range dones {
+		done(false)
+		//重复调用无效
+		done(false)
+	}
+	assert.Equal(t, 0, l.Inflight())
+
+	//延迟稳定并且并发用满时增加上限
+	for i := 0; i < 20; i++ {
+		round(t, l, vc, time.Millisecond*10)
+	}
+	grown := l.Limit()
+	assert.True(t, grown > 4)
+	//延迟明显增加时减少上限
+	for i := 0; i < 5; i++ {
+		round(t, l, vc, time.Millisecond*100)
+	}
+	assert.True(t, l.Limit() < grown)
+
+	//请求失败按比例减少上限
+	before := l.Limit()
+	done, err := l.Acquire(context.Background())
+	assert.Nil(t, err)
+	done(true)
+	assert.True(t, l.Limit() < before)
+}
+
+func TestLimiterBounds(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	l := New(Config{InitialLimit: 3, MinLimit: 2, MaxLimit: 5}, vc)
+	for i := 0; i < 50; i++ {
+		round(t, l, vc, time.Millisecond)
+	}
+	assert.Equal(t, 5, l.Limit())
+	for i := 0; i < 50; i++ {
+		done, _ := l.Acquire(context.Background())
+		done(true)
+	}
+	assert.Equal(t, 2, l.Limit())
+}
+
+func TestLimiterWait(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	l := New(Config{InitialLimit: 1, MaxLimit: 1, MaxWaitMs: 1000}, vc)
+	done, err := l.Acquire(context.Background())
+	assert.Nil(t, err)
+
+	//完成的请求把许可交给等待的请求
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		next, err := l.Acquire(context.Background())
+		assert.Nil(t, err)
+		next(false)
+	}()
+	assert.True(t, vc.WaitPending(1, time.Second))
+	done(false)
+	wg.Wait()
+	assert.Equal(t, 0, l.Inflight())
+
+	//等待超时
+	done, err = l.Acquire(context.Background())
+	assert.Nil(t, err)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := l.Acquire(context.Background())
+		assert.Equal(t, ErrLimitExceeded, err)
+	}()
+	assert.True(t, vc.WaitPending(1, time.Second))
+	vc.Advance(time.Second)
+	wg.Wait()
+
+	//上下文取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = l.Acquire(ctx)
+	assert.Equal(t, context.Canceled, err)
+	done(false)
+	assert.Equal(t, 0, l.Inflight())
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	l := r.Get("127.0.0.1:8080", Config{InitialLimit: 5}, nil)
+	assert.Equal(t, 5, l.Limit())
+	assert.True(t, l == r.Get("127.0.0.1:8080", Config{InitialLimit: 10}, nil))
+	assert.Equal(t, 10, r.Get("127.0.0.1:9090", Config{InitialLimit: 10}, nil).Limit())
+}
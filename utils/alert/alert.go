@@ -0,0 +1,206 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package alert 基于节点关系指标的自动告警
+// 按节点统计时间窗口内输出的关系，指定关系的比例超过阈值时产生告警，恢复到阈值以下时产生恢复通知，
+// 例如：节点s1在5分钟内Failure关系的比例超过10%
+package alert
+
+import (
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"time"
+)
+
+// 告警状态
+const (
+	//Firing 比例超过阈值
+	Firing = "firing"
+	//Resolved 比例恢复到阈值以下
+	Resolved = "resolved"
+)
+
+// windowBuckets 时间窗口划分的桶数量
+const windowBuckets = 30
+
+// Rule 告警规则
+type Rule struct {
+	//Id 告警规则ID
+	Id string `json:"id"`
+	//ChainId 规则链ID，为空匹配所有规则链
+	ChainId string `json:"chainId,omitempty"`
+	//NodeId 节点ID，为空匹配所有节点，每个节点分别统计
+	NodeId string `json:"nodeId,omitempty"`
+	//RelationType 统计的关系类型，默认：Failure
+	RelationType string `json:"relationType,omitempty"`
+	//Threshold 关系比例阈值，百分比，范围0~100，超过该值产生告警
+	Threshold float64 `json:"threshold"`
+	//Window 统计时间窗口，默认5分钟
+	Window time.Duration `json:"window,omitempty"`
+	//MinCount 时间窗口内节点最少输出多少次才检查比例，避免少量消息产生告警，默认1
+	MinCount int64 `json:"minCount,omitempty"`
+}
+
+// Alert 告警或者恢复通知
+type Alert struct {
+	//RuleId 告警规则ID
+	RuleId string `json:"ruleId"`
+	//State 告警状态：firing、resolved
+	State   string `json:"state"`
+	ChainId string `json:"chainId"`
+	NodeId  string `json:"nodeId"`
+	//RelationType 统计的关系类型
+	RelationType string `json:"relationType"`
+	//Rate 时间窗口内关系的比例，百分比
+	Rate float64 `json:"rate"`
+	//Count 时间窗口内该关系的次数
+	Count int64 `json:"count"`
+	//Total 时间窗口内节点输出的总次数
+	Total     int64         `json:"total"`
+	Threshold float64       `json:"threshold"`
+	Window    time.Duration `json:"window"`
+	Time      time.Time     `json:"time"`
+}
+
+// Config 告警配置
+type Config struct {
+	//ChainId 接收告警消息的规则链ID，该规则链的节点不参与统计
+	ChainId string
+	//Rules 告警规则
+	Rules []Rule
+	//Clock 时钟，默认使用系统时钟
+	Clock clock.Clock
+}
+
+// series 节点在时间窗口内的统计，按桶滚动
+type series struct {
+	counts []int64
+	totals []int64
+	//slots 每个桶对应的时间片序号
+	slots  []int64
+	firing bool
+}
+
+// Manager 告警管理器
+type Manager struct {
+	config Config
+	series map[string]*series
+	lock   sync.Mutex
+}
+
+// NewManager 创建告警管理器
+func NewManager(config Config) *Manager {
+	if config.Clock == nil {
+		config.Clock = clock.System
+	}
+	rules := make([]Rule, len(config.Rules))
+	for i, rule := range config.Rules {
+		if rule.RelationType == "" {
+			rule.RelationType = "Failure"
+		}
+		if rule.Window <= 0 {
+			rule.Window = time.Minute * 5
+		}
+		if rule.MinCount <= 0 {
+			rule.MinCount = 1
+		}
+		rules[i] = rule
+	}
+	config.Rules = rules
+	return &Manager{config: config, series: make(map[string]*series)}
+}
+
+// ChainId 接收告警消息的规则链ID
+func (m *Manager) ChainId() string {
+	return m.config.ChainId
+}
+
+// Record 记录节点输出的一次关系，返回状态发生变化的告警
+func (m *Manager) Record(chainId, nodeId, relationType string) []Alert {
+	if chainId == m.config.ChainId {
+		return nil
+	}
+	now := m.config.Clock.Now()
+	var alerts []Alert
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for i := range m.config.Rules {
+		rule := &m.config.Rules[i]
+		if (rule.ChainId != "" && rule.ChainId != chainId) || (rule.NodeId != "" && rule.NodeId != nodeId) {
+			continue
+		}
+		k := rule.Id + "|" + chainId + "|" + nodeId
+		item, ok := m.series[k]
+		if !ok {
+			item = &series{counts: make([]int64, windowBuckets), totals: make([]int64, windowBuckets), slots: make([]int64, windowBuckets)}
+			m.series[k] = item
+		}
+		count, total := item.add(rule, now, relationType == rule.RelationType)
+		if total < rule.MinCount {
+			continue
+		}
+		rate := float64(count) * 100 / float64(total)
+		if firing := rate > rule.Threshold; firing != item.firing {
+			item.firing = firing
+			alert := Alert{
+				RuleId:       rule.Id,
+				State:        Resolved,
+				ChainId:      chainId,
+				NodeId:       nodeId,
+				RelationType: rule.RelationType,
+				Rate:         rate,
+				Count:        count,
+				Total:        total,
+				Threshold:    rule.Threshold,
+				Window:       rule.Window,
+				Time:         now,
+			}
+			if firing {
+				alert.State = Firing
+			}
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts
+}
+
+// add 增加一次计数，返回时间窗口内的关系次数和总次数
+func (s *series) add(rule *Rule, now time.Time, hit bool) (int64, int64) {
+	size := rule.Window / windowBuckets
+	if size <= 0 {
+		size = 1
+	}
+	slot := now.UnixNano() / int64(size)
+	index := int(slot % windowBuckets)
+	if s.slots[index] != slot {
+		//桶已经过期，重新计数
+		s.slots[index] = slot
+		s.counts[index] = 0
+		s.totals[index] = 0
+	}
+	s.totals[index]++
+	if hit {
+		s.counts[index]++
+	}
+	var count, total int64
+	for i := range s.slots {
+		if slot-s.slots[i] < windowBuckets {
+			count += s.counts[i]
+			total += s.totals[i]
+		}
+	}
+	return count, total
+}
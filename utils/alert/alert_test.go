@@ -0,0 +1,66 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package alert
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	m := NewManager(Config{
+		ChainId: "alarm",
+		Rules:   []Rule{{Id: "failureRate", NodeId: "s1", Threshold: 50, Window: time.Minute, MinCount: 4}},
+		Clock:   vc,
+	})
+	assert.Equal(t, "alarm", m.ChainId())
+	//没有达到最少次数不检查
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 0, len(m.Record("chain01", "s1", "Failure")))
+	}
+	alerts := m.Record("chain01", "s1", "Failure")
+	assert.Equal(t, 1, len(alerts))
+	assert.Equal(t, Alert{
+		RuleId: "failureRate", State: Firing, ChainId: "chain01", NodeId: "s1", RelationType: "Failure",
+		Rate: 100, Count: 4, Total: 4, Threshold: 50, Window: time.Minute, Time: vc.Now(),
+	}, alerts[0])
+	//告警状态不变不重复告警
+	assert.Equal(t, 0, len(m.Record("chain01", "s1", "Success")))
+	//其他节点、其他规则链分别统计，告警规则链不统计
+	assert.Equal(t, 0, len(m.Record("chain01", "s2", "Failure")))
+	assert.Equal(t, 0, len(m.Record("alarm", "s1", "Failure")))
+
+	//比例恢复到阈值以下
+	assert.Equal(t, 0, len(m.Record("chain01", "s1", "Success")))
+	assert.Equal(t, 0, len(m.Record("chain01", "s1", "Success")))
+	alerts = m.Record("chain01", "s1", "Success")
+	assert.Equal(t, 1, len(alerts))
+	assert.Equal(t, Resolved, alerts[0].State)
+	assert.Equal(t, int64(8), alerts[0].Total)
+
+	//超过时间窗口重新统计
+	vc.Advance(time.Minute)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 0, len(m.Record("chain01", "s1", "Failure")))
+	}
+	alerts = m.Record("chain01", "s1", "Failure")
+	assert.Equal(t, 1, len(alerts))
+	assert.Equal(t, int64(4), alerts[0].Total)
+}
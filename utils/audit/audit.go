@@ -0,0 +1,258 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package audit 规则链配置变更审计
+// 规则链创建、更新、删除和重新加载时记录操作人、时间和DSL差异，
+// 通过`types.Config.AuditSink`配置记录方式，管理接口/audit可以查询审计记录
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//ActionCreate 创建规则链
+	ActionCreate = "create"
+	//ActionUpdate 更新规则链或者节点，DSL有变化
+	ActionUpdate = "update"
+	//ActionReload 重新加载规则链，DSL没有变化
+	ActionReload = "reload"
+	//ActionDelete 删除规则链
+	ActionDelete = "delete"
+)
+
+// Event 审计记录
+type Event struct {
+	//Time 操作时间
+	Time time.Time `json:"time"`
+	//Actor 操作人
+	Actor string `json:"actor"`
+	//Action 操作：create、update、reload、delete
+	Action string `json:"action"`
+	//ChainId 规则链ID
+	ChainId string `json:"chainId"`
+	//NodeId 更新的子节点或者子规则链ID，为空表示整个规则链
+	NodeId string `json:"nodeId,omitempty"`
+	//Tenant 租户
+	Tenant string `json:"tenant,omitempty"`
+	//Diff 变更前后DSL的差异
+	Diff string `json:"diff,omitempty"`
+	//Error 操作失败的错误
+	Error string `json:"error,omitempty"`
+}
+
+// Sink 审计记录输出
+type Sink interface {
+	//Record 记录审计事件
+	Record(event Event) error
+}
+
+// Query 查询条件，空条件不过滤
+type Query struct {
+	ChainId string
+	Actor   string
+	Action  string
+	Tenant  string
+	//Since 开始时间(包含)
+	Since time.Time
+	//Until 结束时间(不包含)
+	Until time.Time
+	//Limit 最多返回最近的条数，<=0不限制
+	Limit int
+}
+
+// Match 事件是否满足查询条件
+func (q Query) Match(event Event) bool {
+	return (q.ChainId == "" || q.ChainId == event.ChainId) &&
+		(q.Actor == "" || q.Actor == event.Actor) &&
+		(q.Action == "" || q.Action == event.Action) &&
+		(q.Tenant == "" || q.Tenant == event.Tenant) &&
+		(q.Since.IsZero() || !event.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || event.Time.Before(q.Until))
+}
+
+// Store 可以查询的审计记录输出
+type Store interface {
+	Sink
+	//Query 按时间顺序返回满足条件的审计记录
+	Query(query Query) ([]Event, error)
+}
+
+// Memory 内存审计存储，超过容量丢弃最早的记录
+type Memory struct {
+	capacity int
+	events   []Event
+	lock     sync.RWMutex
+}
+
+// NewMemory 创建内存审计存储，capacity<=0默认保留10000条
+func NewMemory(capacity int) *Memory {
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	return &Memory{capacity: capacity}
+}
+
+func (m *Memory) Record(event Event) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.events = append(m.events, event)
+	if len(m.events) > m.capacity {
+		m.events = append([]Event(nil), m.events[len(m.events)-m.capacity:]...)
+	}
+	return nil
+}
+
+func (m *Memory) Query(query Query) ([]Event, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return filter(m.events, query), nil
+}
+
+// File 文件审计存储，每条记录一行json追加到文件，适合合规场景长期保存
+type File struct {
+	path string
+	lock sync.Mutex
+}
+
+// NewFile 创建文件审计存储
+func NewFile(path string) (*File, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &File{path: path}, nil
+}
+
+func (f *File) Record(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *File) Query(query Query) ([]Event, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	file, err := os.Open(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event Event
+		if err = json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, err
+		}
+		if query.Match(event) {
+			events = append(events, event)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return limit(events, query.Limit), nil
+}
+
+func filter(events []Event, query Query) []Event {
+	var result []Event
+	for _, event := range events {
+		if query.Match(event) {
+			result = append(result, event)
+		}
+	}
+	return limit(result, query.Limit)
+}
+
+// limit 保留最近的n条
+func limit(events []Event, n int) []Event {
+	if n > 0 && len(events) > n {
+		return events[len(events)-n:]
+	}
+	return events
+}
+
+// Diff 按行比较变更前后的文本，返回删除行以"-"开头、新增行以"+"开头的差异，没有差异返回空字符串
+func Diff(before, after string) string {
+	if before == after {
+		return ""
+	}
+	a := splitLines(before)
+	b := splitLines(after)
+	//最长公共子序列
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			sb.WriteString("+" + b[j] + "\n")
+			j++
+		default:
+			sb.WriteString("-" + a[i] + "\n")
+			i++
+		}
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	assert.Equal(t, "", Diff("a\nb\n", "a\nb\n"))
+	assert.Equal(t, "-b\n+c\n", Diff("a\nb\n", "a\nc\n"))
+	assert.Equal(t, "+a\n+b\n", Diff("", "a\nb"))
+	assert.Equal(t, "-b\n", Diff("a\nb\nc", "a\nc"))
+}
+
+func testStore(t *testing.T, store Store) {
+	start := time.UnixMilli(1700000000000)
+	events := []Event{
+		{Time: start, Actor: "lala", Action: ActionCreate, ChainId: "chain01", Tenant: "t1"},
+		{Time: start.Add(time.Minute), Actor: "lulu", Action: ActionUpdate, ChainId: "chain01", Tenant: "t1", Diff: "-a\n+b\n"},
+		{Time: start.Add(2 * time.Minute), Actor: "lala", Action: ActionDelete, ChainId: "chain02", Tenant: "t2"},
+	}
+	for _, event := range events {
+		assert.Nil(t, store.Record(event))
+	}
+	result, err := store.Query(Query{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(result))
+	assert.Equal(t, "-a\n+b\n", result[1].Diff)
+
+	result, _ = store.Query(Query{Actor: "lala"})
+	assert.Equal(t, 2, len(result))
+	result, _ = store.Query(Query{ChainId: "chain01", Action: ActionUpdate})
+	assert.Equal(t, 1, len(result))
+	result, _ = store.Query(Query{Tenant: "t2"})
+	assert.Equal(t, "chain02", result[0].ChainId)
+	result, _ = store.Query(Query{Since: start.Add(time.Minute), Until: start.Add(2 * time.Minute)})
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, "lulu", result[0].Actor)
+	result, _ = store.Query(Query{Limit: 1})
+	assert.Equal(t, ActionDelete, result[0].Action)
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory(0))
+
+	store := NewMemory(2)
+	for i := 0; i < 3; i++ {
+		_ = store.Record(Event{ChainId: string(rune('a' + i))})
+	}
+	result, _ := store.Query(Query{})
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "b", result[0].ChainId)
+}
+
+func TestFile(t *testing.T) {
+	store, err := NewFile(filepath.Join(t.TempDir(), "audit", "audit.log"))
+	assert.Nil(t, err)
+	result, err := store.Query(Query{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(result))
+	testStore(t, store)
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package blob 大消息内容存储
+// 超过大小限制的消息内容转存到Store，消息只保留引用，需要内容的节点处理前再加载
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound 内容不存在
+var ErrNotFound = errors.New("blob not found")
+
+// Store 内容存储，key由`Key`根据内容生成，相同内容使用相同的key
+// 多条消息可能引用同一个key，Store不负责清理，需要通过存储本身的过期策略清理，例如S3生命周期规则
+type Store interface {
+	//Put 保存内容，key存在则覆盖
+	Put(key string, data []byte) error
+	//Get 获取内容，不存在返回`ErrNotFound`
+	Get(key string) ([]byte, error)
+	//Delete 删除内容
+	Delete(key string) error
+}
+
+// Key 根据内容生成key，使用sha256十六进制编码
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Memory 内存存储，用于测试或者单机场景
+type Memory struct {
+	items map[string][]byte
+	lock  sync.RWMutex
+}
+
+// NewMemory 创建内存存储
+func NewMemory() *Memory {
+	return &Memory{items: make(map[string][]byte)}
+}
+
+func (m *Memory) Put(key string, data []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.items[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *Memory) Get(key string) ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	data, ok := m.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.items, key)
+	return nil
+}
+
+// Len 内容数量
+func (m *Memory) Len() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return len(m.items)
+}
+
+// File 文件存储，每个key保存为目录下的一个文件
+type File struct {
+	dir string
+}
+
+// NewFile 创建文件存储，目录不存在则创建
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &File{dir: dir}, nil
+}
+
+// Put 先写临时文件再重命名，防止写入过程中断导致读取到不完整的内容
+func (f *File) Put(key string, data []byte) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *File) Get(key string) ([]byte, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (f *File) Delete(key string) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// path key对应的文件路径，key不能包含路径分隔符
+func (f *File) path(key string) (string, error) {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return "", errors.New("invalid blob key: " + key)
+	}
+	return filepath.Join(f.dir, key), nil
+}
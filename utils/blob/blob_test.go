@@ -0,0 +1,51 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blob
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+)
+
+func testStore(t *testing.T, store Store) {
+	data := []byte("large payload")
+	key := Key(data)
+	assert.Equal(t, 64, len(key))
+	assert.Nil(t, store.Put(key, data))
+	v, err := store.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), string(v))
+
+	assert.Nil(t, store.Delete(key))
+	_, err = store.Get(key)
+	assert.Equal(t, ErrNotFound, err)
+	//删除不存在的key不报错
+	assert.Nil(t, store.Delete(key))
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestFile(t *testing.T) {
+	store, err := NewFile(t.TempDir())
+	assert.Nil(t, err)
+	testStore(t, store)
+	assert.NotNil(t, store.Put("../escape", []byte("x")))
+	_, err = store.Get("a/b")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package clock 时钟抽象
+// 延迟、批量、定时等和时间相关的组件通过 types.Config.Clock 获取时间和创建定时器，
+// 测试时使用 Virtual 虚拟时钟快进时间，不需要真实等待
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock 时钟接口
+type Clock interface {
+	//Now 当前时间
+	Now() time.Time
+	//AfterFunc 等待d时间后，在独立的协程执行f
+	AfterFunc(d time.Duration, f func()) Timer
+	//NewTicker 创建周期为d的定时器
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer 定时器
+type Timer interface {
+	//Stop 停止定时器，如果定时器已经触发或者已经停止，返回false
+	Stop() bool
+}
+
+// Ticker 周期定时器
+type Ticker interface {
+	//C 定时器通道
+	C() <-chan time.Time
+	//Stop 停止定时器
+	Stop()
+}
+
+// System 系统时钟
+var System Clock = systemClock{}
+
+type systemClock struct {
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return &systemTicker{ticker: time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t *systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t *systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// Virtual 虚拟时钟，时间只在调用 Advance 或者 Set 时前进
+// 到期的定时器按照到期时间顺序在调用 Advance 的协程中同步执行，执行时Now()返回该定时器的到期时间
+type Virtual struct {
+	now    time.Time
+	seq    int
+	timers []*virtualTimer
+	mu     sync.Mutex
+}
+
+// NewVirtual 创建以start为当前时间的虚拟时钟
+func NewVirtual(start time.Time) *Virtual {
+	return &Virtual{now: start}
+}
+
+// virtualTimer 虚拟时钟定时器，period>0表示周期定时器
+type virtualTimer struct {
+	clock  *Virtual
+	when   time.Time
+	seq    int
+	period time.Duration
+	f      func()
+	ch     chan time.Time
+}
+
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.now
+}
+
+func (v *Virtual) AfterFunc(d time.Duration, f func()) Timer {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.schedule(&virtualTimer{clock: v, when: v.now.Add(d), f: f})
+}
+
+func (v *Virtual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return &virtualTicker{timer: v.schedule(&virtualTimer{clock: v, when: v.now.Add(d), period: d, ch: make(chan time.Time, 1)})}
+}
+
+// schedule 添加定时器，调用方需要持有锁
+func (v *Virtual) schedule(t *virtualTimer) *virtualTimer {
+	v.seq++
+	t.seq = v.seq
+	v.timers = append(v.timers, t)
+	sort.SliceStable(v.timers, func(i, j int) bool {
+		if !v.timers[i].when.Equal(v.timers[j].when) {
+			return v.timers[i].when.Before(v.timers[j].when)
+		}
+		return v.timers[i].seq < v.timers[j].seq
+	})
+	return t
+}
+
+// remove 删除定时器，调用方需要持有锁
+func (v *Virtual) remove(t *virtualTimer) bool {
+	for i, item := range v.timers {
+		if item == t {
+			v.timers = append(v.timers[:i], v.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance 时间前进d，并依次触发到期的定时器
+func (v *Virtual) Advance(d time.Duration) {
+	v.Set(v.Now().Add(d))
+}
+
+// Set 时间前进到t，并依次触发到期的定时器，t早于当前时间则忽略
+func (v *Virtual) Set(t time.Time) {
+	for {
+		v.mu.Lock()
+		if len(v.timers) == 0 || v.timers[0].when.After(t) {
+			if t.After(v.now) {
+				v.now = t
+			}
+			v.mu.Unlock()
+			return
+		}
+		timer := v.timers[0]
+		v.timers = v.timers[1:]
+		if timer.when.After(v.now) {
+			v.now = timer.when
+		}
+		if timer.period > 0 {
+			timer.when = timer.when.Add(timer.period)
+			v.schedule(timer)
+		}
+		now := v.now
+		v.mu.Unlock()
+
+		if timer.ch != nil {
+			//和time.Ticker一样，接收方处理不及时则丢弃
+			select {
+			case timer.ch <- now:
+			default:
+			}
+		} else {
+			timer.f()
+		}
+	}
+}
+
+// Pending 未触发的定时器数量，测试可以用来等待组件创建定时器
+func (v *Virtual) Pending() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.timers)
+}
+
+// WaitPending 等待未触发的定时器数量达到n，超时返回false
+func (v *Virtual) WaitPending(n int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for v.Pending() < n {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+func (t *virtualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
+
+// virtualTicker 虚拟时钟周期定时器
+type virtualTicker struct {
+	timer *virtualTimer
+}
+
+func (t *virtualTicker) C() <-chan time.Time {
+	return t.timer.ch
+}
+
+func (t *virtualTicker) Stop() {
+	t.timer.Stop()
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clock
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"testing"
+	"time"
+)
+
+func TestVirtual(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	v := NewVirtual(start)
+	assert.Equal(t, start, v.Now())
+
+	var fired []string
+	v.AfterFunc(time.Second*2, func() {
+		fired = append(fired, "b")
+		assert.Equal(t, start.Add(time.Second*2), v.Now())
+	})
+	v.AfterFunc(time.Second, func() {
+		fired = append(fired, "a")
+		//定时器回调中创建的定时器，到期后在同一次Advance中触发
+		v.AfterFunc(time.Millisecond*500, func() {
+			fired = append(fired, "a2")
+		})
+	})
+	stopped := v.AfterFunc(time.Second*3, func() {
+		fired = append(fired, "c")
+	})
+	assert.Equal(t, 3, v.Pending())
+
+	v.Advance(time.Millisecond * 999)
+	assert.Equal(t, 0, len(fired))
+
+	v.Advance(time.Second * 2)
+	assert.Equal(t, []string{"a", "a2", "b"}, fired)
+	assert.Equal(t, start.Add(time.Millisecond*2999), v.Now())
+
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+	v.Advance(time.Hour)
+	assert.Equal(t, 3, len(fired))
+
+	//时间不能后退
+	v.Set(start)
+	assert.Equal(t, start.Add(time.Hour+time.Millisecond*2999), v.Now())
+}
+
+func TestVirtualTicker(t *testing.T) {
+	v := NewVirtual(time.Unix(0, 0))
+	ticker := v.NewTicker(time.Minute)
+	v.Advance(time.Minute)
+	assert.Equal(t, time.Unix(60, 0), <-ticker.C())
+	//接收方没有及时处理则丢弃
+	v.Advance(time.Minute * 3)
+	assert.Equal(t, time.Unix(120, 0), <-ticker.C())
+	select {
+	case <-ticker.C():
+		t.Fatal("tick should be dropped")
+	default:
+	}
+	ticker.Stop()
+	assert.Equal(t, 0, v.Pending())
+	v.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker stopped")
+	default:
+	}
+}
+
+func TestVirtualWaitPending(t *testing.T) {
+	v := NewVirtual(time.Now())
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		v.AfterFunc(time.Second, func() {})
+	}()
+	assert.True(t, v.WaitPending(1, time.Second))
+	assert.False(t, v.WaitPending(2, time.Millisecond*10))
+}
+
+func TestSystem(t *testing.T) {
+	done := make(chan struct{})
+	System.AfterFunc(time.Millisecond, func() {
+		close(done)
+	})
+	<-done
+	ticker := System.NewTicker(time.Millisecond)
+	<-ticker.C()
+	ticker.Stop()
+	assert.True(t, !System.Now().IsZero())
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kv
+
+import (
+	"encoding/json"
+	"github.com/2018yuli/rulego/utils/clock"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File 文件存储，所有条目以json格式保存在一个文件，进程重启后恢复未过期的条目
+// 每次修改都重写整个文件，适合数据量不多的场景
+type File struct {
+	path string
+	mem  *Memory
+}
+
+// NewFile 创建文件存储，如果文件存在则加载已有条目，c 为nil则使用系统时钟
+func NewFile(path string, c clock.Clock) (*File, error) {
+	s := &File{path: path, mem: NewMemory(c)}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &s.mem.entries); err != nil {
+			return nil, err
+		}
+		s.mem.removeExpired()
+	}
+	return s, nil
+}
+
+func (s *File) Get(namespace, key string) (string, bool, error) {
+	return s.mem.Get(namespace, key)
+}
+
+func (s *File) Put(namespace, key, value string, ttl time.Duration) error {
+	s.mem.lock.Lock()
+	defer s.mem.lock.Unlock()
+	s.mem.put(namespace, key, value, ttl)
+	return s.save()
+}
+
+func (s *File) Incr(namespace, key string, delta int64, ttl time.Duration) (int64, error) {
+	s.mem.lock.Lock()
+	defer s.mem.lock.Unlock()
+	v, err := s.mem.incr(namespace, key, delta, ttl)
+	if err != nil {
+		return 0, err
+	}
+	return v, s.save()
+}
+
+func (s *File) Delete(namespace, key string) error {
+	s.mem.lock.Lock()
+	defer s.mem.lock.Unlock()
+	if !s.mem.delete(namespace, key) {
+		return nil
+	}
+	return s.save()
+}
+
+// save 删除过期条目后先写临时文件再重命名，防止写入过程中断导致文件损坏，调用方需要持有锁
+func (s *File) save() error {
+	s.mem.removeExpired()
+	content, err := json.Marshal(s.mem.entries)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package kv 跨规则链共享的key/value存储
+// 任意节点通过 types.Config.GetKVStore() 访问，js脚本通过内置的store对象访问，
+// 数据按命名空间(租户)隔离，支持过期时间，可以用于不同规则链之间的协作，例如规则链A设置标志，规则链B检查标志
+package kv
+
+import (
+	"github.com/2018yuli/rulego/utils/clock"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store key/value存储接口
+type Store interface {
+	//Get 获取值，如果不存在或者已经过期ok=false
+	Get(namespace, key string) (value string, ok bool, err error)
+	//Put 保存值，ttl<=0表示永不过期
+	Put(namespace, key, value string, ttl time.Duration) error
+	//Delete 删除值
+	Delete(namespace, key string) error
+	//Incr 原子地把整数值增加delta，返回增加后的值。如果不存在则从0开始，并设置过期时间ttl，ttl<=0表示永不过期
+	Incr(namespace, key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+// Entry 存储条目
+type Entry struct {
+	Value string `json:"value"`
+	//ExpireAt 过期时间，unix毫秒，0表示永不过期
+	ExpireAt int64 `json:"expireAt,omitempty"`
+}
+
+// Memory 内存存储，过期条目在访问时删除
+type Memory struct {
+	clock   clock.Clock
+	entries map[string]map[string]Entry
+	lock    sync.RWMutex
+}
+
+// NewMemory 创建内存存储，c 用于计算过期时间，为nil则使用系统时钟
+func NewMemory(c clock.Clock) *Memory {
+	if c == nil {
+		c = clock.System
+	}
+	return &Memory{clock: c, entries: make(map[string]map[string]Entry)}
+}
+
+func (s *Memory) Get(namespace, key string) (string, bool, error) {
+	s.lock.RLock()
+	entry, ok := s.entries[namespace][key]
+	s.lock.RUnlock()
+	if !ok {
+		return "", false, nil
+	}
+	if s.expired(entry) {
+		s.lock.Lock()
+		if entry, ok = s.entries[namespace][key]; ok && s.expired(entry) {
+			delete(s.entries[namespace], key)
+		}
+		s.lock.Unlock()
+		return "", false, nil
+	}
+	return entry.Value, true, nil
+}
+
+func (s *Memory) Put(namespace, key, value string, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.put(namespace, key, value, ttl)
+	return nil
+}
+
+func (s *Memory) Delete(namespace, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.delete(namespace, key)
+	return nil
+}
+
+func (s *Memory) Incr(namespace, key string, delta int64, ttl time.Duration) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.incr(namespace, key, delta, ttl)
+}
+
+// incr 调用方需要持有锁
+func (s *Memory) incr(namespace, key string, delta int64, ttl time.Duration) (int64, error) {
+	entry, ok := s.entries[namespace][key]
+	if !ok || s.expired(entry) {
+		s.put(namespace, key, strconv.FormatInt(delta, 10), ttl)
+		return delta, nil
+	}
+	v, err := strconv.ParseInt(entry.Value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	v += delta
+	entry.Value = strconv.FormatInt(v, 10)
+	s.entries[namespace][key] = entry
+	return v, nil
+}
+
+// put 调用方需要持有锁
+func (s *Memory) put(namespace, key, value string, ttl time.Duration) {
+	entry := Entry{Value: value}
+	if ttl > 0 {
+		entry.ExpireAt = s.clock.Now().Add(ttl).UnixMilli()
+	}
+	items, ok := s.entries[namespace]
+	if !ok {
+		items = make(map[string]Entry)
+		s.entries[namespace] = items
+	}
+	items[key] = entry
+}
+
+// delete 调用方需要持有锁
+func (s *Memory) delete(namespace, key string) bool {
+	items, ok := s.entries[namespace]
+	if !ok {
+		return false
+	}
+	if _, ok = items[key]; !ok {
+		return false
+	}
+	delete(items, key)
+	if len(items) == 0 {
+		delete(s.entries, namespace)
+	}
+	return true
+}
+
+// removeExpired 删除所有过期条目，调用方需要持有锁
+func (s *Memory) removeExpired() {
+	for namespace, items := range s.entries {
+		for key, entry := range items {
+			if s.expired(entry) {
+				s.delete(namespace, key)
+			}
+		}
+	}
+}
+
+func (s *Memory) expired(entry Entry) bool {
+	return entry.ExpireAt > 0 && s.clock.Now().UnixMilli() >= entry.ExpireAt
+}
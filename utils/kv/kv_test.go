@@ -0,0 +1,95 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kv
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	testStore(t, NewMemory(vc), vc)
+}
+
+func TestFile(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	file := filepath.Join(t.TempDir(), "data", "kv.json")
+	s, err := NewFile(file, vc)
+	assert.Nil(t, err)
+	testStore(t, s, vc)
+	assert.Nil(t, s.Put("t1", "flag", "1", 0))
+	assert.Nil(t, s.Put("t1", "session", "1", time.Second))
+
+	//重新加载，过期的条目被删除
+	vc.Advance(time.Second)
+	s, err = NewFile(file, vc)
+	assert.Nil(t, err)
+	v, ok, err := s.Get("t1", "flag")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+	_, ok, _ = s.Get("t1", "session")
+	assert.False(t, ok)
+
+	assert.Nil(t, os.WriteFile(file, []byte("{"), 0644))
+	_, err = NewFile(file, vc)
+	assert.NotNil(t, err)
+}
+
+func testStore(t *testing.T, s Store, vc *clock.Virtual) {
+	_, ok, err := s.Get("t1", "a")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, s.Put("t1", "a", "1", 0))
+	assert.Nil(t, s.Put("t2", "a", "2", time.Second))
+	v, ok, _ := s.Get("t1", "a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+	v, _, _ = s.Get("t2", "a")
+	assert.Equal(t, "2", v)
+
+	vc.Advance(time.Second)
+	_, ok, _ = s.Get("t2", "a")
+	assert.False(t, ok)
+	_, ok, _ = s.Get("t1", "a")
+	assert.True(t, ok)
+
+	assert.Nil(t, s.Delete("t1", "a"))
+	assert.Nil(t, s.Delete("t1", "a"))
+	_, ok, _ = s.Get("t1", "a")
+	assert.False(t, ok)
+
+	v1, err := s.Incr("t1", "count", 2, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), v1)
+	v1, _ = s.Incr("t1", "count", -1, time.Second)
+	assert.Equal(t, int64(1), v1)
+	//过期后重新计数
+	vc.Advance(time.Second)
+	v1, _ = s.Incr("t1", "count", 1, 0)
+	assert.Equal(t, int64(1), v1)
+	_, err = s.Incr("t1", "a", 1, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Put("t1", "a", "x", 0))
+	_, err = s.Incr("t1", "a", 1, 0)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package redis Redis key/value存储，多个规则引擎实例可以共享数据
+package redis
+
+import (
+	"github.com/go-redis/redis"
+	"time"
+)
+
+// incrScript 增加整数值，新建的key设置过期时间
+var incrScript = redis.NewScript(`
+local v = redis.call("incrby", KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call("pttl", KEYS[1]) < 0 then
+	redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return v`)
+
+// Store Redis key/value存储，key格式：{prefix}{namespace}:{key}
+type Store struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewStore 使用Redis客户端创建存储，prefix 为key前缀，用于区分不同应用
+func NewStore(client *redis.Client, prefix string) *Store {
+	return &Store{client: client, prefix: prefix}
+}
+
+// New 连接Redis并创建存储
+// server Redis地址，例如：127.0.0.1:6379
+func New(server, password string, db int, prefix string) (*Store, error) {
+	client := redis.NewClient(&redis.Options{Addr: server, Password: password, DB: db})
+	if err := client.Ping().Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return NewStore(client, prefix), nil
+}
+
+func (s *Store) Get(namespace, key string) (string, bool, error) {
+	v, err := s.client.Get(s.key(namespace, key)).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return v, true, nil
+}
+
+func (s *Store) Put(namespace, key, value string, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return s.client.Set(s.key(namespace, key), value, ttl).Err()
+}
+
+func (s *Store) Incr(namespace, key string, delta int64, ttl time.Duration) (int64, error) {
+	return incrScript.Run(s.client, []string{s.key(namespace, key)}, delta, ttl.Milliseconds()).Int64()
+}
+
+func (s *Store) Delete(namespace, key string) error {
+	return s.client.Del(s.key(namespace, key)).Err()
+}
+
+// Close 关闭Redis客户端
+func (s *Store) Close() error {
+	return s.client.Close()
+}
+
+func (s *Store) key(namespace, key string) string {
+	return s.prefix + namespace + ":" + key
+}
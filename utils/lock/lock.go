@@ -0,0 +1,84 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package lock 命名锁，用于串行化对共享资源的操作
+package lock
+
+import (
+	"github.com/2018yuli/rulego/utils/clock"
+	"github.com/gofrs/uuid/v5"
+	"sync"
+	"time"
+)
+
+// Locker 命名锁接口
+type Locker interface {
+	//TryLock 尝试获取锁，不等待。ttl为锁的最长持有时间，超时自动释放，防止持有者异常导致死锁
+	//获取成功返回token，释放锁时需要使用该token
+	TryLock(name string, ttl time.Duration) (token string, ok bool, err error)
+	//Unlock 释放锁，如果锁已经过期或者被其他持有者获取则忽略
+	Unlock(name, token string) error
+}
+
+// Local 进程内共享的本地锁
+var Local = NewMemory(nil)
+
+type memoryLock struct {
+	token    string
+	expireAt time.Time
+}
+
+// Memory 进程内的命名锁
+type Memory struct {
+	clock clock.Clock
+	locks map[string]memoryLock
+	lock  sync.Mutex
+}
+
+// NewMemory 创建进程内的命名锁，c 用于计算过期时间，为nil则使用系统时钟
+func NewMemory(c clock.Clock) *Memory {
+	if c == nil {
+		c = clock.System
+	}
+	return &Memory{clock: c, locks: make(map[string]memoryLock)}
+}
+
+func (m *Memory) TryLock(name string, ttl time.Duration) (string, bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := m.clock.Now()
+	if item, ok := m.locks[name]; ok && now.Before(item.expireAt) {
+		return "", false, nil
+	}
+	token := NewToken()
+	m.locks[name] = memoryLock{token: token, expireAt: now.Add(ttl)}
+	return token, true, nil
+}
+
+func (m *Memory) Unlock(name, token string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if item, ok := m.locks[name]; ok && item.token == token {
+		delete(m.locks, name)
+	}
+	return nil
+}
+
+// NewToken 生成锁的持有者token
+func NewToken() string {
+	id, _ := uuid.NewV4()
+	return id.String()
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lock
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	vc := clock.NewVirtual(time.Now())
+	m := NewMemory(vc)
+	token, ok, err := m.TryLock("a", time.Second)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, ok, _ = m.TryLock("a", time.Second)
+	assert.False(t, ok)
+	_, ok, _ = m.TryLock("b", time.Second)
+	assert.True(t, ok)
+
+	//token不一致不释放
+	assert.Nil(t, m.Unlock("a", "other"))
+	_, ok, _ = m.TryLock("a", time.Second)
+	assert.False(t, ok)
+	assert.Nil(t, m.Unlock("a", token))
+	token, ok, _ = m.TryLock("a", time.Second)
+	assert.True(t, ok)
+
+	//过期自动释放，旧的token不能释放新的持有者
+	vc.Advance(time.Second)
+	token2, ok, _ := m.TryLock("a", time.Second)
+	assert.True(t, ok)
+	assert.Nil(t, m.Unlock("a", token))
+	_, ok, _ = m.TryLock("a", time.Second)
+	assert.False(t, ok)
+	assert.Nil(t, m.Unlock("a", token2))
+}
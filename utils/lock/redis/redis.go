@@ -0,0 +1,71 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package redis 基于Redis的分布式命名锁，多个规则引擎实例之间互斥
+package redis
+
+import (
+	"github.com/2018yuli/rulego/utils/lock"
+	"github.com/go-redis/redis"
+	"time"
+)
+
+// unlockScript 只有token一致才删除，防止释放其他持有者的锁
+var unlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+// Locker Redis分布式锁，key格式：{prefix}{name}
+type Locker struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewLocker 使用Redis客户端创建分布式锁，prefix 为key前缀
+func NewLocker(client *redis.Client, prefix string) *Locker {
+	return &Locker{client: client, prefix: prefix}
+}
+
+// New 连接Redis并创建分布式锁
+// server Redis地址，例如：127.0.0.1:6379
+func New(server, password string, db int, prefix string) (*Locker, error) {
+	client := redis.NewClient(&redis.Options{Addr: server, Password: password, DB: db})
+	if err := client.Ping().Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return NewLocker(client, prefix), nil
+}
+
+func (l *Locker) TryLock(name string, ttl time.Duration) (string, bool, error) {
+	token := lock.NewToken()
+	ok, err := l.client.SetNX(l.prefix+name, token, ttl).Result()
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return token, true, nil
+}
+
+func (l *Locker) Unlock(name, token string) error {
+	return unlockScript.Run(l.client, []string{l.prefix + name}, token).Err()
+}
+
+// Close 关闭Redis客户端
+func (l *Locker) Close() error {
+	return l.client.Close()
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package logs 按租户和规则链路由的日志
+// 通过`types.Config.LoggerFactory`为每个规则链创建日志记录器，不同租户的规则链日志写到不同的文件，并带上租户和规则链标签
+//
+//	router := logs.NewFileRouter(logs.FileRouterConfig{Dir: "./logs", Format: logs.FormatJSON})
+//	defer router.Close()
+//	config := rulego.NewConfig(types.WithLoggerFactory(router.Factory))
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/2018yuli/rulego/api/types"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 日志格式
+const (
+	//FormatText 文本格式，每条日志前加上[tenant=t1 chain=c1]标签
+	FormatText = "text"
+	//FormatJSON JSON行格式，每行包含time、level、tenant、chain和msg字段
+	FormatJSON = "json"
+)
+
+// DefaultTenant 没有设置租户的规则链日志使用的租户名
+const DefaultTenant = "default"
+
+// JSONLogger 以JSON行格式输出日志，带上租户和规则链标签
+type JSONLogger struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	scope types.LogScope
+}
+
+// NewJSONLogger 创建JSON行格式的日志记录器，并发写同一个writer的日志记录器需要使用同一个mu，为空则创建新的锁
+func NewJSONLogger(w io.Writer, mu *sync.Mutex, scope types.LogScope) *JSONLogger {
+	if mu == nil {
+		mu = &sync.Mutex{}
+	}
+	return &JSONLogger{w: w, mu: mu, scope: scope}
+}
+
+// jsonEntry JSON格式的日志
+type jsonEntry struct {
+	Time   string `json:"time"`
+	Level  string `json:"level"`
+	Tenant string `json:"tenant,omitempty"`
+	Chain  string `json:"chain,omitempty"`
+	Msg    string `json:"msg"`
+}
+
+// Printf 按info级别输出日志
+func (l *JSONLogger) Printf(format string, v ...interface{}) {
+	l.Logf(types.LogLevelInfo, format, v...)
+}
+
+// Logf 按指定级别输出日志
+func (l *JSONLogger) Logf(level string, format string, v ...interface{}) {
+	b, err := json.Marshal(jsonEntry{
+		Time:   time.Now().Format(time.RFC3339Nano),
+		Level:  level,
+		Tenant: l.scope.Tenant,
+		Chain:  l.scope.ChainId,
+		Msg:    fmt.Sprintf(format, v...),
+	})
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(append(b, '\n'))
+}
+
+// FileRouterConfig 日志文件路由配置
+type FileRouterConfig struct {
+	//Dir 日志目录，按租户分文件：Dir/租户.log，没有租户使用DefaultTenant
+	Dir string
+	//PerChain 每个规则链一个文件：Dir/租户/规则链ID.log
+	PerChain bool
+	//Format 日志格式：text、json，默认：text
+	Format string
+}
+
+// FileRouter 按租户和规则链把日志写到不同的文件，相同文件的规则链共享文件句柄
+type FileRouter struct {
+	config FileRouterConfig
+	mu     sync.Mutex
+	files  map[string]*logFile
+}
+
+type logFile struct {
+	sync.Mutex
+	file *os.File
+}
+
+func (f *logFile) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.file.Write(p)
+}
+
+// NewFileRouter 创建日志文件路由
+func NewFileRouter(config FileRouterConfig) *FileRouter {
+	if config.Format == "" {
+		config.Format = FormatText
+	}
+	return &FileRouter{config: config, files: make(map[string]*logFile)}
+}
+
+// Factory 实现`types.LoggerFactory`，打开日志文件失败返回nil，使用Config.Logger
+func (r *FileRouter) Factory(scope types.LogScope) types.Logger {
+	file, err := r.open(r.path(scope))
+	if err != nil {
+		log.Printf("open log file for %s error: %v", scope, err)
+		return nil
+	}
+	if r.config.Format == FormatJSON {
+		return NewJSONLogger(file.file, &file.Mutex, scope)
+	}
+	return types.NewScopedLogger(log.New(file, "", log.LstdFlags), scope)
+}
+
+// path 日志文件路径
+func (r *FileRouter) path(scope types.LogScope) string {
+	tenant := safeName(scope.Tenant)
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	if r.config.PerChain && scope.ChainId != "" {
+		return filepath.Join(r.config.Dir, tenant, safeName(scope.ChainId)+".log")
+	}
+	return filepath.Join(r.config.Dir, tenant+".log")
+}
+
+func (r *FileRouter) open(path string) (*logFile, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if file, ok := r.files[path]; ok {
+		return file, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	file := &logFile{file: f}
+	r.files[path] = file
+	return file, nil
+}
+
+// Close 关闭所有日志文件
+func (r *FileRouter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var firstErr error
+	for path, file := range r.files {
+		file.Lock()
+		if err := file.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		file.Unlock()
+		delete(r.files, path)
+	}
+	return firstErr
+}
+
+// safeName 替换文件名中的路径分隔符，避免租户或者规则链ID跳出日志目录
+func safeName(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(name)
+	return strings.TrimSpace(name)
+}
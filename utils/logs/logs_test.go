@@ -0,0 +1,77 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/2018yuli/rulego/api/types"
+	"github.com/2018yuli/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewJSONLogger(&buf, nil, types.LogScope{ChainId: "c1", Tenant: "t1"})
+	logger.Printf("hello %s", "world")
+	types.Logf(logger, types.LogLevelError, "error")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	var entry jsonEntry
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, types.LogLevelInfo, entry.Level)
+	assert.Equal(t, "t1", entry.Tenant)
+	assert.Equal(t, "c1", entry.Chain)
+	assert.Equal(t, "hello world", entry.Msg)
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, types.LogLevelError, entry.Level)
+}
+
+func TestFileRouter(t *testing.T) {
+	dir := t.TempDir()
+	router := NewFileRouter(FileRouterConfig{Dir: dir})
+	router.Factory(types.LogScope{ChainId: "c1", Tenant: "t1"}).Printf("from c1")
+	router.Factory(types.LogScope{ChainId: "c2", Tenant: "t1"}).Printf("from c2")
+	router.Factory(types.LogScope{ChainId: "c3"}).Printf("from c3")
+	router.Factory(types.LogScope{ChainId: "c4", Tenant: "../t2"}).Printf("from c4")
+	assert.Nil(t, router.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "t1.log"))
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(data), "[tenant=t1 chain=c1] from c1"))
+	assert.True(t, strings.Contains(string(data), "[tenant=t1 chain=c2] from c2"))
+	data, err = os.ReadFile(filepath.Join(dir, DefaultTenant+".log"))
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(data), "[chain=c3] from c3"))
+	//租户不能跳出日志目录
+	_, err = os.Stat(filepath.Join(dir, "__t2.log"))
+	assert.Nil(t, err)
+
+	//每个规则链一个文件
+	router = NewFileRouter(FileRouterConfig{Dir: dir, PerChain: true, Format: FormatJSON})
+	router.Factory(types.LogScope{ChainId: "c1", Tenant: "t1"}).Printf("from c1")
+	assert.Nil(t, router.Close())
+	data, err = os.ReadFile(filepath.Join(dir, "t1", "c1.log"))
+	assert.Nil(t, err)
+	var entry jsonEntry
+	assert.Nil(t, json.Unmarshal(bytes.TrimSpace(data), &entry))
+	assert.Equal(t, "from c1", entry.Msg)
+	assert.Equal(t, "t1", entry.Tenant)
+}
@@ -18,6 +18,8 @@ package maps
 
 import (
 	"github.com/mitchellh/mapstructure"
+	"strconv"
+	"strings"
 )
 
 // Map2Struct Decode takes an input structure and uses reflection to translate it to
@@ -39,3 +41,41 @@ func Map2Struct(input interface{}, output interface{}) error {
 	}
 	return nil
 }
+
+// Get 根据路径获取嵌套map或者数组中的值，不存在返回nil
+// 路径格式：a.b.c、a.list[0].b，兼容JSONPath的$.前缀，例如：$.a.b
+func Get(input interface{}, path string) interface{} {
+	v, _ := Lookup(input, path)
+	return v
+}
+
+// Lookup 根据路径获取嵌套map或者数组中的值，路径格式同Get，ok=false表示路径不存在，用于区分不存在和值为nil
+func Lookup(input interface{}, path string) (value interface{}, ok bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return input, true
+	}
+	current := input
+	for _, item := range strings.Split(strings.ReplaceAll(path, "[", ".["), ".") {
+		if item == "" {
+			continue
+		}
+		if strings.HasPrefix(item, "[") && strings.HasSuffix(item, "]") {
+			index, err := strconv.Atoi(item[1 : len(item)-1])
+			list, ok := current.([]interface{})
+			if err != nil || !ok || index < 0 || index >= len(list) {
+				return nil, false
+			}
+			current = list[index]
+		} else {
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = m[item]; !ok {
+				return nil, false
+			}
+		}
+	}
+	return current, true
+}
@@ -42,3 +42,33 @@ func TestMap2Struct(t *testing.T) {
 	assert.Equal(t, "lala", user.Username)
 	assert.Equal(t, "test", user.Address.Detail)
 }
+
+func TestGet(t *testing.T) {
+	m := map[string]interface{}{
+		"temperature": 41.0,
+		"sensor": map[string]interface{}{
+			"values": []interface{}{1.0, map[string]interface{}{"x": 2.0}},
+		},
+	}
+	assert.Equal(t, 41.0, Get(m, "temperature"))
+	assert.Equal(t, 41.0, Get(m, "$.temperature"))
+	assert.Equal(t, 1.0, Get(m, "sensor.values[0]"))
+	assert.Equal(t, 2.0, Get(m, "$.sensor.values[1].x"))
+	assert.Nil(t, Get(m, "sensor.values[2]"))
+	assert.Nil(t, Get(m, "sensor.name"))
+	assert.Nil(t, Get(m, "temperature.x"))
+	assert.Equal(t, m, Get(m, "$"))
+}
+
+func TestLookup(t *testing.T) {
+	m := map[string]interface{}{"remark": nil, "list": []interface{}{nil}}
+	v, ok := Lookup(m, "remark")
+	assert.True(t, ok)
+	assert.Nil(t, v)
+	_, ok = Lookup(m, "list[0]")
+	assert.True(t, ok)
+	_, ok = Lookup(m, "name")
+	assert.False(t, ok)
+	_, ok = Lookup(m, "list[1]")
+	assert.False(t, ok)
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package profile 节点执行耗时分析
+// 按节点调用栈累计多条消息的节点执行时间，生成按耗时排序的报告和火焰图折叠栈，用于找出复杂规则链中最慢的节点
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultMaxDepth 默认调用栈最大深度
+const DefaultMaxDepth = 64
+
+// Frame 调用栈中的一个节点
+type Frame struct {
+	//ChainId 规则链ID
+	ChainId string `json:"chainId"`
+	//NodeId 节点ID
+	NodeId string `json:"nodeId"`
+	//NodeType 节点类型
+	NodeType string `json:"nodeType"`
+}
+
+// String 折叠栈中的节点名称，格式：规则链ID:节点ID
+func (f Frame) String() string {
+	return f.ChainId + ":" + f.NodeId
+}
+
+// NodeProfile 节点耗时统计，时间单位为纳秒
+type NodeProfile struct {
+	Frame
+	//Count 执行次数
+	Count int64 `json:"count"`
+	//Exclusive 节点自身执行时间总和
+	Exclusive time.Duration `json:"exclusive"`
+	//Inclusive 节点以及由它触发的后续节点执行时间总和
+	Inclusive time.Duration `json:"inclusive"`
+	//AvgExclusive 节点自身平均执行时间
+	AvgExclusive time.Duration `json:"avgExclusive"`
+	//Percent 节点自身执行时间占总时间的百分比
+	Percent float64 `json:"percent"`
+}
+
+// StackProfile 调用栈耗时统计，时间单位为纳秒
+type StackProfile struct {
+	//Stack 折叠格式的调用栈，节点之间用;分隔
+	Stack string `json:"stack"`
+	//Count 栈顶节点执行次数
+	Count int64 `json:"count"`
+	//Self 栈顶节点执行时间总和
+	Self time.Duration `json:"self"`
+}
+
+// Report 耗时分析报告
+type Report struct {
+	//Total 所有节点执行时间总和
+	Total time.Duration `json:"total"`
+	//Nodes 按自身执行时间从大到小排序的节点
+	Nodes []NodeProfile `json:"nodes"`
+	//Stacks 按执行时间从大到小排序的调用栈
+	Stacks []StackProfile `json:"stacks"`
+}
+
+// Text 文本格式的报告
+func (r Report) Text() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "total: %s\n", r.Total)
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "rank\texclusive\tinclusive\tcount\tavg\tpercent\tnode\ttype")
+	for i, item := range r.Nodes {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%.2f%%\t%s\t%s\n",
+			i+1, item.Exclusive, item.Inclusive, item.Count, item.AvgExclusive, item.Percent, item.Frame, item.NodeType)
+	}
+	_ = w.Flush()
+	return buf.String()
+}
+
+// Folded 火焰图折叠栈格式，每行：调用栈 执行时间(微秒)，可以使用flamegraph.pl等工具生成火焰图
+func (r Report) Folded() string {
+	var buf bytes.Buffer
+	for _, item := range r.Stacks {
+		fmt.Fprintf(&buf, "%s %d\n", item.Stack, item.Self.Microseconds())
+	}
+	return buf.String()
+}
+
+// stackStat 调用栈累计的执行时间
+type stackStat struct {
+	frames []Frame
+	count  int64
+	self   time.Duration
+}
+
+// Profiler 节点执行耗时分析器，并发安全
+type Profiler struct {
+	lock     sync.Mutex
+	maxDepth int
+	stacks   map[string]*stackStat
+}
+
+// New 创建耗时分析器，maxDepth为调用栈最大深度，超过则只保留前maxDepth-1个节点和栈顶节点，<=0使用DefaultMaxDepth
+func New(maxDepth int) *Profiler {
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	return &Profiler{maxDepth: maxDepth, stacks: make(map[string]*stackStat)}
+}
+
+// Record 记录一次节点执行，stack为从第一个节点到当前节点的调用栈，self为当前节点自身执行时间
+func (p *Profiler) Record(stack []Frame, self time.Duration) {
+	if len(stack) == 0 {
+		return
+	}
+	if len(stack) > p.maxDepth {
+		//有环的规则链调用栈可能无限增长，保留栈顶节点的自身执行时间
+		truncated := make([]Frame, 0, p.maxDepth)
+		truncated = append(truncated, stack[:p.maxDepth-1]...)
+		stack = append(truncated, stack[len(stack)-1])
+	}
+	key := stackKey(stack)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	stat, ok := p.stacks[key]
+	if !ok {
+		stat = &stackStat{frames: append([]Frame(nil), stack...)}
+		p.stacks[key] = stat
+	}
+	stat.count++
+	stat.self += self
+}
+
+// Reset 清空已经记录的数据
+func (p *Profiler) Reset() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.stacks = make(map[string]*stackStat)
+}
+
+// Report 生成耗时分析报告
+func (p *Profiler) Report() Report {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var report Report
+	nodes := make(map[Frame]*NodeProfile)
+	for key, stat := range p.stacks {
+		report.Total += stat.self
+		report.Stacks = append(report.Stacks, StackProfile{Stack: key, Count: stat.count, Self: stat.self})
+		top := stat.frames[len(stat.frames)-1]
+		node := getNode(nodes, top)
+		node.Count += stat.count
+		node.Exclusive += stat.self
+		//递归调用的节点在同一个调用栈中只累计一次
+		seen := make(map[Frame]bool, len(stat.frames))
+		for _, frame := range stat.frames {
+			if !seen[frame] {
+				seen[frame] = true
+				getNode(nodes, frame).Inclusive += stat.self
+			}
+		}
+	}
+	for _, node := range nodes {
+		if node.Count > 0 {
+			node.AvgExclusive = node.Exclusive / time.Duration(node.Count)
+		}
+		if report.Total > 0 {
+			node.Percent = float64(node.Exclusive) * 100 / float64(report.Total)
+		}
+		report.Nodes = append(report.Nodes, *node)
+	}
+	sort.Slice(report.Nodes, func(i, j int) bool {
+		a, b := report.Nodes[i], report.Nodes[j]
+		if a.Exclusive != b.Exclusive {
+			return a.Exclusive > b.Exclusive
+		}
+		if a.Inclusive != b.Inclusive {
+			return a.Inclusive > b.Inclusive
+		}
+		return a.Frame.String() < b.Frame.String()
+	})
+	sort.Slice(report.Stacks, func(i, j int) bool {
+		if report.Stacks[i].Self != report.Stacks[j].Self {
+			return report.Stacks[i].Self > report.Stacks[j].Self
+		}
+		return report.Stacks[i].Stack < report.Stacks[j].Stack
+	})
+	return report
+}
+
+func getNode(nodes map[Frame]*NodeProfile, frame Frame) *NodeProfile {
+	node, ok := nodes[frame]
+	if !ok {
+		node = &NodeProfile{Frame: frame}
+		nodes[frame] = node
+	}
+	return node
+}
+
+// stackKey 折叠格式的调用栈
+func stackKey(stack []Frame) string {
+	items := make([]string, len(stack))
+	for i, frame := range stack {
+		items[i] = frame.String()
+	}
+	return strings.Join(items, ";")
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package profile
+
+import (
+	"encoding/json"
+	"github.com/2018yuli/rulego/test/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfiler(t *testing.T) {
+	s1 := Frame{ChainId: "c1", NodeId: "s1", NodeType: "jsFilter"}
+	s2 := Frame{ChainId: "c1", NodeId: "s2", NodeType: "restApiCall"}
+	s3 := Frame{ChainId: "c1", NodeId: "s3", NodeType: "log"}
+	p := New(0)
+	p.Record(nil, time.Second)
+	for i := 0; i < 2; i++ {
+		p.Record([]Frame{s1}, time.Millisecond)
+		p.Record([]Frame{s1, s2}, time.Millisecond*10)
+		p.Record([]Frame{s1, s2, s3}, time.Millisecond*2)
+	}
+	//s1通过环再次执行s2，s2在同一个调用栈中只累计一次总时间
+	p.Record([]Frame{s1, s2, s1, s2}, time.Millisecond*6)
+
+	report := p.Report()
+	assert.Equal(t, time.Millisecond*32, report.Total)
+	assert.Equal(t, 3, len(report.Nodes))
+	assert.Equal(t, NodeProfile{Frame: s2, Count: 3, Exclusive: time.Millisecond * 26, Inclusive: time.Millisecond * 30,
+		AvgExclusive: time.Millisecond * 26 / 3, Percent: 81.25}, report.Nodes[0])
+	assert.Equal(t, s3, report.Nodes[1].Frame)
+	assert.Equal(t, s1, report.Nodes[2].Frame)
+	assert.Equal(t, time.Millisecond*32, report.Nodes[2].Inclusive)
+	assert.Equal(t, StackProfile{Stack: "c1:s1;c1:s2", Count: 2, Self: time.Millisecond * 20}, report.Stacks[0])
+
+	assert.Equal(t, "c1:s1;c1:s2 20000\nc1:s1;c1:s2;c1:s1;c1:s2 6000\nc1:s1;c1:s2;c1:s3 4000\nc1:s1 2000\n", report.Folded())
+	text := report.Text()
+	assert.True(t, strings.HasPrefix(text, "total: 32ms\n"))
+	assert.True(t, strings.Contains(text, "c1:s2"))
+	assert.Equal(t, 5, len(strings.Split(strings.TrimSpace(text), "\n")))
+
+	b, err := json.Marshal(report)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(b), `"nodeId":"s2","nodeType":"restApiCall","count":3`))
+
+	p.Reset()
+	assert.Equal(t, 0, len(p.Report().Nodes))
+}
+
+func TestProfilerMaxDepth(t *testing.T) {
+	p := New(3)
+	var stack []Frame
+	for _, id := range []string{"s1", "s2", "s3", "s4", "s5"} {
+		stack = append(stack, Frame{ChainId: "c1", NodeId: id})
+	}
+	p.Record(stack, time.Millisecond)
+	report := p.Report()
+	assert.Equal(t, "c1:s1;c1:s2;c1:s5", report.Stacks[0].Stack)
+	assert.Equal(t, "s5", report.Nodes[0].NodeId)
+	assert.Equal(t, time.Millisecond, report.Nodes[0].Exclusive)
+	//传入的调用栈不会被修改
+	assert.Equal(t, "s3", stack[2].NodeId)
+}
@@ -0,0 +1,250 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package quarantine 毒消息检测和隔离
+// 按消息ID统计规则链处理失败次数，同一条消息失败次数达到限制后放入隔离区，
+// 之后重新投递的相同消息不再执行，避免格式错误的消息导致反复失败。隔离区的消息可以查看、重放或者删除
+package quarantine
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrQuarantined 消息已经被隔离
+var ErrQuarantined = errors.New("message quarantined")
+
+// ErrNotFound 隔离区不存在该消息
+var ErrNotFound = errors.New("quarantined message not found")
+
+// Message 被隔离的消息
+type Message struct {
+	Ts       int64                  `json:"ts"`
+	Id       string                 `json:"id"`
+	DataType string                 `json:"dataType"`
+	Type     string                 `json:"type"`
+	Data     string                 `json:"data"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// Entry 隔离记录
+type Entry struct {
+	//ChainId 规则链ID
+	ChainId string `json:"chainId"`
+	//Msg 消息
+	Msg Message `json:"msg"`
+	//Failures 失败次数
+	Failures int `json:"failures"`
+	//Error 最后一次失败的错误
+	Error string `json:"error"`
+	//Time 隔离时间
+	Time time.Time `json:"time"`
+}
+
+// Store 隔离区存储
+type Store interface {
+	//Put 保存隔离记录，存在则覆盖
+	Put(entry Entry) error
+	//Get 获取隔离记录
+	Get(chainId, msgId string) (Entry, bool, error)
+	//List 按隔离时间顺序返回规则链的隔离记录，chainId为空返回所有
+	List(chainId string) ([]Entry, error)
+	//Delete 删除隔离记录
+	Delete(chainId, msgId string) error
+}
+
+// Memory 内存隔离区存储
+type Memory struct {
+	entries map[string]Entry
+	lock    sync.RWMutex
+}
+
+// NewMemory 创建内存隔离区存储
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]Entry)}
+}
+
+func (m *Memory) Put(entry Entry) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.entries[key(entry.ChainId, entry.Msg.Id)] = entry
+	return nil
+}
+
+func (m *Memory) Get(chainId, msgId string) (Entry, bool, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	entry, ok := m.entries[key(chainId, msgId)]
+	return entry, ok, nil
+}
+
+func (m *Memory) List(chainId string) ([]Entry, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	var result []Entry
+	for _, entry := range m.entries {
+		if chainId == "" || entry.ChainId == chainId {
+			result = append(result, entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Time.Equal(result[j].Time) {
+			return result[i].Msg.Id < result[j].Msg.Id
+		}
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result, nil
+}
+
+func (m *Memory) Delete(chainId, msgId string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.entries, key(chainId, msgId))
+	return nil
+}
+
+// Config 毒消息检测配置
+type Config struct {
+	//MaxFailures 同一条消息失败多少次后隔离，默认3次
+	MaxFailures int
+	//FailureTTL 失败计数的有效期，超过该时间没有再失败则清零，默认1小时
+	FailureTTL time.Duration
+	//Store 隔离区存储，默认使用内存存储
+	Store Store
+	//Clock 时钟，默认使用系统时钟
+	Clock clock.Clock
+}
+
+// failure 消息失败计数
+type failure struct {
+	count int
+	last  time.Time
+}
+
+// Manager 毒消息管理器
+type Manager struct {
+	config   Config
+	failures map[string]*failure
+	//lastEvict 上次清除过期失败计数的时间
+	lastEvict time.Time
+	lock      sync.Mutex
+}
+
+// NewManager 创建毒消息管理器
+func NewManager(config Config) *Manager {
+	if config.MaxFailures <= 0 {
+		config.MaxFailures = 3
+	}
+	if config.FailureTTL <= 0 {
+		config.FailureTTL = time.Hour
+	}
+	if config.Store == nil {
+		config.Store = NewMemory()
+	}
+	if config.Clock == nil {
+		config.Clock = clock.System
+	}
+	return &Manager{config: config, failures: make(map[string]*failure)}
+}
+
+// Store 获取隔离区存储
+func (m *Manager) Store() Store {
+	return m.config.Store
+}
+
+// Quarantined 消息是否已经被隔离
+func (m *Manager) Quarantined(chainId, msgId string) bool {
+	_, ok, err := m.config.Store.Get(chainId, msgId)
+	return ok && err == nil
+}
+
+// Fail 记录一次处理失败，失败次数达到MaxFailures则隔离该消息并返回true
+func (m *Manager) Fail(chainId string, msg Message, err error) (bool, error) {
+	now := m.config.Clock.Now()
+	m.lock.Lock()
+	m.evict(now)
+	k := key(chainId, msg.Id)
+	item, ok := m.failures[k]
+	if !ok || now.Sub(item.last) >= m.config.FailureTTL {
+		item = &failure{}
+		m.failures[k] = item
+	}
+	item.count++
+	item.last = now
+	count := item.count
+	if count >= m.config.MaxFailures {
+		delete(m.failures, k)
+	}
+	m.lock.Unlock()
+	if count < m.config.MaxFailures {
+		return false, nil
+	}
+	entry := Entry{ChainId: chainId, Msg: msg, Failures: count, Time: now}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return true, m.config.Store.Put(entry)
+}
+
+// Succeed 消息处理成功，清零失败计数
+func (m *Manager) Succeed(chainId, msgId string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.failures, key(chainId, msgId))
+}
+
+// Failures 获取消息当前的失败次数
+func (m *Manager) Failures(chainId, msgId string) int {
+	now := m.config.Clock.Now()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if item, ok := m.failures[key(chainId, msgId)]; ok && now.Sub(item.last) < m.config.FailureTTL {
+		return item.count
+	}
+	return 0
+}
+
+// Release 从隔离区移除消息并返回，用于重放，重放的消息重新开始计数
+func (m *Manager) Release(chainId, msgId string) (Entry, error) {
+	entry, ok, err := m.config.Store.Get(chainId, msgId)
+	if err != nil {
+		return entry, err
+	}
+	if !ok {
+		return entry, ErrNotFound
+	}
+	return entry, m.config.Store.Delete(chainId, msgId)
+}
+
+// evict 清除过期的失败计数，每个有效期最多清除一次，调用方需要持有锁
+func (m *Manager) evict(now time.Time) {
+	if now.Sub(m.lastEvict) < m.config.FailureTTL {
+		return
+	}
+	m.lastEvict = now
+	for k, item := range m.failures {
+		if now.Sub(item.last) >= m.config.FailureTTL {
+			delete(m.failures, k)
+		}
+	}
+}
+
+func key(chainId, msgId string) string {
+	return chainId + "|" + msgId
+}
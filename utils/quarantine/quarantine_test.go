@@ -0,0 +1,84 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quarantine
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	m := NewManager(Config{MaxFailures: 2, FailureTTL: time.Minute, Clock: vc})
+	msg := Message{Id: "m1", Type: "TEST", Data: "{"}
+	failErr := errors.New("invalid json")
+
+	ok, err := m.Fail("chain01", msg, failErr)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, m.Failures("chain01", "m1"))
+	//不同规则链分别计数
+	assert.Equal(t, 0, m.Failures("chain02", "m1"))
+
+	//超过有效期重新计数
+	vc.Advance(time.Minute)
+	assert.Equal(t, 0, m.Failures("chain01", "m1"))
+	ok, _ = m.Fail("chain01", msg, failErr)
+	assert.False(t, ok)
+	m.Succeed("chain01", "m1")
+	assert.Equal(t, 0, m.Failures("chain01", "m1"))
+
+	ok, _ = m.Fail("chain01", msg, failErr)
+	assert.False(t, ok)
+	ok, err = m.Fail("chain01", msg, failErr)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.True(t, m.Quarantined("chain01", "m1"))
+	assert.False(t, m.Quarantined("chain02", "m1"))
+
+	entries, err := m.Store().List("chain01")
+	assert.Nil(t, err)
+	assert.Equal(t, []Entry{{ChainId: "chain01", Msg: msg, Failures: 2, Error: "invalid json", Time: vc.Now()}}, entries)
+
+	entry, err := m.Release("chain01", "m1")
+	assert.Nil(t, err)
+	assert.Equal(t, "m1", entry.Msg.Id)
+	assert.False(t, m.Quarantined("chain01", "m1"))
+	_, err = m.Release("chain01", "m1")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMemoryList(t *testing.T) {
+	store := NewMemory()
+	now := time.UnixMilli(1700000000000)
+	_ = store.Put(Entry{ChainId: "chain01", Msg: Message{Id: "m2"}, Time: now.Add(time.Second)})
+	_ = store.Put(Entry{ChainId: "chain01", Msg: Message{Id: "m1"}, Time: now})
+	_ = store.Put(Entry{ChainId: "chain02", Msg: Message{Id: "m3"}, Time: now})
+
+	entries, _ := store.List("chain01")
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "m1", entries[0].Msg.Id)
+	assert.Equal(t, "m2", entries[1].Msg.Id)
+	entries, _ = store.List("")
+	assert.Equal(t, 3, len(entries))
+	_ = store.Delete("chain01", "m1")
+	_, ok, _ := store.Get("chain01", "m1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package quota 规则链执行配额和用量统计
+// 按规则链统计执行次数、节点执行时间和外部调用次数，超过软限制则限流，超过硬限制则拒绝，用于多租户计费和防止滥用
+package quota
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded 超过规则链执行配额硬限制
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Decision 配额检查结果
+type Decision int
+
+const (
+	//Allow 允许执行
+	Allow Decision = iota
+	//Throttle 超过软限制，延迟执行
+	Throttle
+	//Reject 超过硬限制，拒绝执行
+	Reject
+)
+
+// DefaultExternalNodeTypes 默认统计为外部调用的节点类型
+var DefaultExternalNodeTypes = []string{
+	"restApiCall", "dbClient", "mqttClient", "mqttRpc", "sendEmail", "ldap", "llm", "vectorStore", "loki", "syslog",
+	"zmqClient", "pulsarProducer", "awsIotCore", "awsSns", "awsSqs", "azureIotHub", "gcpPubSub", "outbox",
+}
+
+// Limit 配额限制，0表示不限制
+type Limit struct {
+	//Executions 执行次数
+	Executions int64
+	//NodeTime 节点执行时间总和
+	NodeTime time.Duration
+	//ExternalCalls 外部调用次数
+	ExternalCalls int64
+}
+
+// exceeded 用量是否达到限制
+func (l Limit) exceeded(u Usage) bool {
+	return (l.Executions > 0 && u.Executions >= l.Executions) ||
+		(l.NodeTime > 0 && u.NodeTime >= l.NodeTime) ||
+		(l.ExternalCalls > 0 && u.ExternalCalls >= l.ExternalCalls)
+}
+
+// Config 规则链配额配置
+type Config struct {
+	//Period 统计周期，周期结束后用量清零，0表示不清零
+	Period time.Duration
+	//Soft 软限制，超过后消息延迟ThrottleDelay再执行
+	Soft Limit
+	//Hard 硬限制，超过后拒绝消息
+	Hard Limit
+	//ThrottleDelay 限流延迟，默认1秒
+	ThrottleDelay time.Duration
+}
+
+// Usage 规则链用量
+type Usage struct {
+	//Executions 执行次数
+	Executions int64 `json:"executions"`
+	//NodeTime 节点执行时间总和
+	NodeTime time.Duration `json:"nodeTime"`
+	//ExternalCalls 外部调用次数
+	ExternalCalls int64 `json:"externalCalls"`
+	//Throttled 被限流的次数
+	Throttled int64 `json:"throttled"`
+	//Rejected 被拒绝的次数
+	Rejected int64 `json:"rejected"`
+	//PeriodStart 当前统计周期开始时间
+	PeriodStart time.Time `json:"periodStart"`
+}
+
+// Manager 配额管理器，没有配置配额的规则链只统计用量
+type Manager struct {
+	clock         clock.Clock
+	configs       map[string]Config
+	usages        map[string]*Usage
+	externalTypes map[string]bool
+	lock          sync.Mutex
+}
+
+// NewManager 创建配额管理器，clock为空则使用系统时钟
+func NewManager(clk clock.Clock) *Manager {
+	if clk == nil {
+		clk = clock.System
+	}
+	m := &Manager{clock: clk, configs: make(map[string]Config), usages: make(map[string]*Usage)}
+	m.SetExternalNodeTypes(DefaultExternalNodeTypes...)
+	return m
+}
+
+// SetQuota 设置规则链配额
+func (m *Manager) SetQuota(chainId string, config Config) {
+	if config.ThrottleDelay <= 0 {
+		config.ThrottleDelay = time.Second
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.configs[chainId] = config
+}
+
+// GetQuota 获取规则链配额
+func (m *Manager) GetQuota(chainId string) (Config, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	config, ok := m.configs[chainId]
+	return config, ok
+}
+
+// RemoveQuota 删除规则链配额，不再限制
+func (m *Manager) RemoveQuota(chainId string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.configs, chainId)
+}
+
+// SetExternalNodeTypes 设置统计为外部调用的节点类型
+func (m *Manager) SetExternalNodeTypes(nodeTypes ...string) {
+	externalTypes := make(map[string]bool, len(nodeTypes))
+	for _, nodeType := range nodeTypes {
+		externalTypes[nodeType] = true
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.externalTypes = externalTypes
+}
+
+// Acquire 检查配额，允许或者限流则记录一次执行
+// 返回Throttle时调用方需要延迟delay再执行
+func (m *Manager) Acquire(chainId string) (decision Decision, delay time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	usage := m.usage(chainId)
+	config, ok := m.configs[chainId]
+	if ok && config.Hard.exceeded(*usage) {
+		usage.Rejected++
+		return Reject, 0
+	}
+	usage.Executions++
+	if ok && config.Soft.exceeded(*usage) {
+		usage.Throttled++
+		return Throttle, config.ThrottleDelay
+	}
+	return Allow, 0
+}
+
+// AddNodeTime 记录节点执行时间，如果是外部调用节点则记录一次外部调用
+func (m *Manager) AddNodeTime(chainId string, nodeType string, d time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	usage := m.usage(chainId)
+	usage.NodeTime += d
+	if m.externalTypes[nodeType] {
+		usage.ExternalCalls++
+	}
+}
+
+// Usage 获取规则链当前统计周期的用量
+func (m *Manager) Usage(chainId string) Usage {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return *m.usage(chainId)
+}
+
+// Usages 获取所有规则链当前统计周期的用量
+func (m *Manager) Usages() map[string]Usage {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	result := make(map[string]Usage, len(m.usages))
+	for chainId := range m.usages {
+		result[chainId] = *m.usage(chainId)
+	}
+	return result
+}
+
+// Reset 清零规则链用量
+func (m *Manager) Reset(chainId string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.usages, chainId)
+}
+
+// usage 获取用量，统计周期结束则清零，调用方需要持有锁
+func (m *Manager) usage(chainId string) *Usage {
+	now := m.clock.Now()
+	usage, ok := m.usages[chainId]
+	if !ok {
+		usage = &Usage{PeriodStart: now}
+		m.usages[chainId] = usage
+	} else if period := m.configs[chainId].Period; period > 0 && !now.Before(usage.PeriodStart.Add(period)) {
+		//按周期对齐，避免长时间没有消息导致周期漂移
+		start := usage.PeriodStart.Add(now.Sub(usage.PeriodStart) / period * period)
+		*usage = Usage{PeriodStart: start}
+	}
+	return usage
+}
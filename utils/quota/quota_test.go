@@ -0,0 +1,87 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quota
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"testing"
+	"time"
+)
+
+func TestManagerAcquire(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	m := NewManager(vc)
+	m.SetQuota("chain01", Config{
+		Period: time.Minute,
+		Soft:   Limit{Executions: 2},
+		Hard:   Limit{Executions: 3},
+	})
+	decision, _ := m.Acquire("chain01")
+	assert.Equal(t, Allow, decision)
+	decision, delay := m.Acquire("chain01")
+	assert.Equal(t, Throttle, decision)
+	assert.Equal(t, time.Second, delay)
+	decision, _ = m.Acquire("chain01")
+	assert.Equal(t, Throttle, decision)
+	decision, _ = m.Acquire("chain01")
+	assert.Equal(t, Reject, decision)
+
+	usage := m.Usage("chain01")
+	assert.Equal(t, int64(3), usage.Executions)
+	assert.Equal(t, int64(2), usage.Throttled)
+	assert.Equal(t, int64(1), usage.Rejected)
+
+	//没有配置配额的规则链只统计用量
+	decision, _ = m.Acquire("chain02")
+	assert.Equal(t, Allow, decision)
+	assert.Equal(t, 2, len(m.Usages()))
+
+	//周期结束后清零
+	vc.Advance(time.Minute + time.Second)
+	decision, _ = m.Acquire("chain01")
+	assert.Equal(t, Allow, decision)
+	usage = m.Usage("chain01")
+	assert.Equal(t, int64(1), usage.Executions)
+	assert.Equal(t, time.UnixMilli(1700000060000), usage.PeriodStart)
+
+	m.RemoveQuota("chain01")
+	_, ok := m.GetQuota("chain01")
+	assert.False(t, ok)
+	m.Reset("chain01")
+	assert.Equal(t, int64(0), m.Usage("chain01").Executions)
+}
+
+func TestManagerNodeTime(t *testing.T) {
+	m := NewManager(nil)
+	m.SetQuota("chain01", Config{Hard: Limit{ExternalCalls: 2}})
+	m.AddNodeTime("chain01", "jsFilter", time.Millisecond)
+	m.AddNodeTime("chain01", "restApiCall", time.Second)
+	usage := m.Usage("chain01")
+	assert.Equal(t, time.Second+time.Millisecond, usage.NodeTime)
+	assert.Equal(t, int64(1), usage.ExternalCalls)
+	decision, _ := m.Acquire("chain01")
+	assert.Equal(t, Allow, decision)
+
+	m.AddNodeTime("chain01", "restApiCall", time.Second)
+	decision, _ = m.Acquire("chain01")
+	assert.Equal(t, Reject, decision)
+
+	m.SetExternalNodeTypes("jsFilter")
+	m.AddNodeTime("chain01", "jsFilter", time.Millisecond)
+	assert.Equal(t, int64(3), m.Usage("chain01").ExternalCalls)
+}
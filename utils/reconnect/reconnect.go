@@ -0,0 +1,265 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package reconnect 长连接重连管理
+// 统一处理连接失败后的指数退避、随机抖动、最大重试次数和连接状态回调，
+// mqtt、数据库等需要保持长连接的组件使用该包，不需要各自实现重连逻辑
+package reconnect
+
+import (
+	"errors"
+	"fmt"
+	"github.com/2018yuli/rulego/utils/clock"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrStopped 重连管理器已经停止
+var ErrStopped = errors.New("reconnect manager stopped")
+
+// State 连接状态
+type State int
+
+const (
+	//Disconnected 未连接
+	Disconnected State = iota
+	//Connecting 正在连接
+	Connecting
+	//Connected 已连接
+	Connected
+)
+
+func (s State) String() string {
+	switch s {
+	case Connecting:
+		return "Connecting"
+	case Connected:
+		return "Connected"
+	default:
+		return "Disconnected"
+	}
+}
+
+// Config 重连配置
+type Config struct {
+	//InitialInterval 首次重试间隔，默认1秒
+	InitialInterval time.Duration
+	//MaxInterval 最大重试间隔，默认60秒
+	MaxInterval time.Duration
+	//Multiplier 每次重试间隔的倍数，默认2
+	Multiplier float64
+	//Jitter 重试间隔随机抖动比例，取值0-1，例如0.2表示在间隔的±20%范围内随机
+	Jitter float64
+	//MaxRetries 连接失败后最大重试次数，0表示不限制
+	MaxRetries int
+	//Clock 时钟，默认使用系统时钟
+	Clock clock.Clock
+	//OnStateChange 连接状态变化回调
+	OnStateChange func(state State, err error)
+	//Rand 随机数函数，返回[0,1)，默认使用math/rand
+	Rand func() float64
+}
+
+// Manager 重连管理器
+type Manager struct {
+	config  Config
+	connect func() error
+	state   State
+	lastErr error
+	//attempts 当前重连过程失败次数
+	attempts int
+	timer    clock.Timer
+	//done 当前重连过程结束后关闭，为空表示没有进行中的重连
+	done    chan struct{}
+	stopped bool
+	lock    sync.Mutex
+}
+
+// New 创建重连管理器，connect 建立连接，返回错误表示连接失败
+func New(config Config, connect func() error) *Manager {
+	if config.InitialInterval <= 0 {
+		config.InitialInterval = time.Second
+	}
+	if config.MaxInterval <= 0 {
+		config.MaxInterval = time.Second * 60
+	}
+	if config.Multiplier < 1 {
+		config.Multiplier = 2
+	}
+	if config.Clock == nil {
+		config.Clock = clock.System
+	}
+	if config.Rand == nil {
+		config.Rand = rand.Float64
+	}
+	return &Manager{config: config, connect: connect}
+}
+
+// Connect 建立连接，失败按照退避策略重试，阻塞直到连接成功、超过最大重试次数或者管理器停止
+func (m *Manager) Connect() error {
+	done := m.Start()
+	<-done
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.state == Connected {
+		return nil
+	}
+	return m.lastErr
+}
+
+// Start 在后台建立连接，返回的通道在连接成功或者放弃重试后关闭
+// 已经连接或者正在连接则不会重复连接
+func (m *Manager) Start() <-chan struct{} {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.stopped {
+		m.lastErr = ErrStopped
+		return closedChan()
+	}
+	if m.done != nil {
+		return m.done
+	}
+	if m.state == Connected {
+		return closedChan()
+	}
+	m.done = make(chan struct{})
+	m.attempts = 0
+	m.setState(Connecting, nil)
+	go m.attempt()
+	return m.done
+}
+
+// Disconnected 报告连接已经断开，在后台重新连接
+func (m *Manager) Disconnected(err error) {
+	m.lock.Lock()
+	if m.stopped || m.done != nil {
+		m.lock.Unlock()
+		return
+	}
+	m.lastErr = err
+	m.setState(Disconnected, err)
+	m.lock.Unlock()
+	m.Start()
+}
+
+// SetState 报告连接状态，用于自身带有重连机制的客户端，例如paho mqtt客户端，只记录状态和回调
+func (m *Manager) SetState(state State, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err != nil {
+		m.lastErr = err
+	}
+	m.setState(state, err)
+}
+
+// State 当前连接状态
+func (m *Manager) State() State {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.state
+}
+
+// HealthCheck 已经连接返回nil，否则返回最后一次连接错误
+func (m *Manager) HealthCheck() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.state == Connected {
+		return nil
+	}
+	if m.lastErr != nil {
+		return m.lastErr
+	}
+	return fmt.Errorf("not connected, state=%s", m.state)
+}
+
+// Stop 停止重连
+func (m *Manager) Stop() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.stopped = true
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	m.finish()
+}
+
+// Backoff 第attempts次失败后的重试间隔
+func (m *Manager) Backoff(attempts int) time.Duration {
+	interval := float64(m.config.InitialInterval)
+	for i := 1; i < attempts && interval < float64(m.config.MaxInterval); i++ {
+		interval *= m.config.Multiplier
+	}
+	if interval > float64(m.config.MaxInterval) {
+		interval = float64(m.config.MaxInterval)
+	}
+	if m.config.Jitter > 0 {
+		interval *= 1 + m.config.Jitter*(2*m.config.Rand()-1)
+	}
+	return time.Duration(interval)
+}
+
+// attempt 尝试连接一次，失败则按照退避策略安排下一次重试
+func (m *Manager) attempt() {
+	err := m.connect()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.timer = nil
+	if m.stopped {
+		return
+	}
+	if err == nil {
+		m.lastErr = nil
+		m.setState(Connected, nil)
+		m.finish()
+		return
+	}
+	m.lastErr = err
+	m.attempts++
+	if m.config.MaxRetries > 0 && m.attempts > m.config.MaxRetries {
+		//超过最大重试次数，放弃重连
+		m.setState(Disconnected, err)
+		m.finish()
+		return
+	}
+	m.timer = m.config.Clock.AfterFunc(m.Backoff(m.attempts), m.attempt)
+}
+
+// finish 结束当前重连过程，调用方需要持有锁
+func (m *Manager) finish() {
+	if m.done != nil {
+		close(m.done)
+		m.done = nil
+	}
+}
+
+// setState 修改状态并回调，调用方需要持有锁
+func (m *Manager) setState(state State, err error) {
+	if m.state == state {
+		return
+	}
+	m.state = state
+	if m.config.OnStateChange != nil {
+		m.config.OnStateChange(state, err)
+	}
+}
+
+func closedChan() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
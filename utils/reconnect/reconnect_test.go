@@ -0,0 +1,118 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reconnect
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/test/assert"
+	"github.com/2018yuli/rulego/utils/clock"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	m := New(Config{InitialInterval: time.Second, MaxInterval: 10 * time.Second, Rand: func() float64 { return 0.5 }}, nil)
+	assert.Equal(t, time.Second, m.Backoff(1))
+	assert.Equal(t, 2*time.Second, m.Backoff(2))
+	assert.Equal(t, 8*time.Second, m.Backoff(4))
+	assert.Equal(t, 10*time.Second, m.Backoff(5))
+	assert.Equal(t, 10*time.Second, m.Backoff(100))
+
+	m = New(Config{InitialInterval: time.Second, Jitter: 0.2, Rand: func() float64 { return 0 }}, nil)
+	assert.Equal(t, 800*time.Millisecond, m.Backoff(1))
+	m = New(Config{InitialInterval: time.Second, Jitter: 0.2, Rand: func() float64 { return 1 }}, nil)
+	assert.Equal(t, 1200*time.Millisecond, m.Backoff(1))
+}
+
+func TestConnectRetry(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	var lock sync.Mutex
+	var states []State
+	var calls int
+	m := New(Config{
+		InitialInterval: time.Second,
+		Clock:           vc,
+		OnStateChange: func(state State, err error) {
+			states = append(states, state)
+		},
+	}, func() error {
+		lock.Lock()
+		defer lock.Unlock()
+		calls++
+		if calls < 3 {
+			return errors.New("refused")
+		}
+		return nil
+	})
+	done := m.Start()
+	assert.True(t, vc.WaitPending(1, time.Second))
+	assert.Equal(t, Connecting, m.State())
+	assert.NotNil(t, m.HealthCheck())
+	vc.Advance(time.Second)
+	assert.True(t, vc.WaitPending(1, time.Second))
+	vc.Advance(2 * time.Second)
+	<-done
+	assert.Equal(t, Connected, m.State())
+	assert.Nil(t, m.HealthCheck())
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []State{Connecting, Connected}, states)
+
+	//已经连接不会重复连接
+	assert.Nil(t, m.Connect())
+	assert.Equal(t, 3, calls)
+
+	//断开后后台重连
+	m.Disconnected(errors.New("broken"))
+	assert.Nil(t, m.Connect())
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []State{Connecting, Connected, Disconnected, Connecting, Connected}, states)
+}
+
+func TestMaxRetries(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	var calls int
+	m := New(Config{Clock: vc, MaxRetries: 2}, func() error {
+		calls++
+		return errors.New("refused")
+	})
+	result := make(chan error, 1)
+	go func() {
+		result <- m.Connect()
+	}()
+	assert.True(t, vc.WaitPending(1, time.Second))
+	vc.Advance(time.Second)
+	assert.True(t, vc.WaitPending(1, time.Second))
+	vc.Advance(2 * time.Second)
+	assert.Equal(t, "refused", (<-result).Error())
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, Disconnected, m.State())
+	assert.Equal(t, "refused", m.HealthCheck().Error())
+}
+
+func TestStop(t *testing.T) {
+	vc := clock.NewVirtual(time.UnixMilli(1700000000000))
+	m := New(Config{Clock: vc}, func() error {
+		return errors.New("refused")
+	})
+	done := m.Start()
+	assert.True(t, vc.WaitPending(1, time.Second))
+	m.Stop()
+	<-done
+	assert.Equal(t, 0, vc.Pending())
+	assert.Equal(t, ErrStopped, m.Connect())
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package secrets 密钥获取和敏感配置加解密
+// 规则链导出或者持久化时，组件配置中标记了`sensitive:"true"`的字段使用AES-GCM加密，
+// 加载时使用Provider提供的密钥解密，密钥不需要保存在规则链文件中
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// EncryptedPrefix 加密值前缀
+const EncryptedPrefix = "enc:v1:"
+
+// SensitiveTag 敏感字段标签，例如：Password string `sensitive:"true"`
+const SensitiveTag = "sensitive"
+
+// ErrSecretNotFound 密钥不存在
+var ErrSecretNotFound = errors.New("secret not found")
+
+// Provider 密钥提供者，可以对接环境变量、文件或者Vault等密钥管理服务
+type Provider interface {
+	//GetSecret 获取密钥，不存在返回ErrSecretNotFound
+	GetSecret(name string) (string, error)
+}
+
+// EnvProvider 从环境变量获取密钥
+type EnvProvider struct {
+}
+
+func (p EnvProvider) GetSecret(name string) (string, error) {
+	if v, ok := os.LookupEnv(name); ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+}
+
+// MapProvider 从map获取密钥，一般用于测试
+type MapProvider map[string]string
+
+func (p MapProvider) GetSecret(name string) (string, error) {
+	if v, ok := p[name]; ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+}
+
+// IsEncrypted 是否是加密值
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedPrefix)
+}
+
+// Encrypt 使用key加密，返回：enc:v1:base64(nonce+密文)
+// key可以是任意长度的字符串，使用sha256转换成AES-256密钥
+func Encrypt(key, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密Encrypt加密的值，不是加密值则原样返回
+func Decrypt(key, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted value")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt failed: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// SensitiveFields 获取组件配置中标记了`sensitive:"true"`的字段，返回首字母小写的配置key
+// v 为组件实例，查找组件结构体中的配置结构体字段，包括匿名嵌入的结构体
+func SensitiveFields(v interface{}) []string {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		if ft := t.Field(i).Type; ft.Kind() == reflect.Struct {
+			fields = appendSensitive(fields, ft)
+		}
+	}
+	return fields
+}
+
+func appendSensitive(fields []string, t reflect.Type) []string {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = appendSensitive(fields, field.Type)
+		} else if field.Tag.Get(SensitiveTag) == "true" && field.Type.Kind() == reflect.String {
+			fields = append(fields, strings.ToLower(field.Name[:1])+field.Name[1:])
+		}
+	}
+	return fields
+}
+
+func newGCM(key string) (cipher.AEAD, error) {
+	if key == "" {
+		return nil, errors.New("encryption key can not empty")
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
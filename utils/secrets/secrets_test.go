@@ -0,0 +1,85 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package secrets
+
+import (
+	"errors"
+	"github.com/2018yuli/rulego/test/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	encrypted, err := Encrypt("key01", "root:root@tcp(127.0.0.1:3306)/test")
+	assert.Nil(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.False(t, strings.Contains(encrypted, "root"))
+
+	plaintext, err := Decrypt("key01", encrypted)
+	assert.Nil(t, err)
+	assert.Equal(t, "root:root@tcp(127.0.0.1:3306)/test", plaintext)
+
+	_, err = Decrypt("key02", encrypted)
+	assert.NotNil(t, err)
+
+	//不是加密值原样返回
+	plaintext, err = Decrypt("key01", "aa")
+	assert.Nil(t, err)
+	assert.Equal(t, "aa", plaintext)
+
+	_, err = Encrypt("", "aa")
+	assert.NotNil(t, err)
+}
+
+func TestProvider(t *testing.T) {
+	p := MapProvider{"key01": "aa"}
+	v, err := p.GetSecret("key01")
+	assert.Nil(t, err)
+	assert.Equal(t, "aa", v)
+	_, err = p.GetSecret("key02")
+	assert.True(t, errors.Is(err, ErrSecretNotFound))
+
+	_ = os.Setenv("RULEGO_TEST_SECRET", "bb")
+	defer os.Unsetenv("RULEGO_TEST_SECRET")
+	v, err = EnvProvider{}.GetSecret("RULEGO_TEST_SECRET")
+	assert.Nil(t, err)
+	assert.Equal(t, "bb", v)
+	_, err = EnvProvider{}.GetSecret("RULEGO_TEST_SECRET_NOT_FOUND")
+	assert.True(t, errors.Is(err, ErrSecretNotFound))
+}
+
+type testCredentials struct {
+	Token string `sensitive:"true"`
+}
+
+type testConfiguration struct {
+	testCredentials
+	Server   string
+	Password string `sensitive:"true"`
+	Port     int    `sensitive:"true"`
+}
+
+type testNode struct {
+	config testConfiguration
+	name   string
+}
+
+func TestSensitiveFields(t *testing.T) {
+	assert.Equal(t, []string{"token", "password"}, SensitiveFields(&testNode{}))
+	assert.Equal(t, 0, len(SensitiveFields("aa")))
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sign 规则链DSL的Ed25519签名和校验
+// 签名保存在DSL顶层的signature字段：
+//
+//	"signature": {"keyId": "publisher01", "alg": "ed25519", "value": "base64签名"}
+//
+// 签名内容为去掉signature字段后按key排序的紧凑JSON，不受缩进和字段顺序影响
+package sign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Field 签名字段名称
+const Field = "signature"
+
+// Algorithm 签名算法
+const Algorithm = "ed25519"
+
+var (
+	//ErrUnsigned DSL没有签名
+	ErrUnsigned = errors.New("dsl is not signed")
+	//ErrUntrustedKey 签名密钥不在信任列表
+	ErrUntrustedKey = errors.New("untrusted signing key")
+	//ErrInvalidSignature 签名校验失败
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Signature DSL签名
+type Signature struct {
+	//KeyId 签名密钥ID，用于在信任列表中查找公钥
+	KeyId string `json:"keyId"`
+	//Alg 签名算法，目前只支持ed25519
+	Alg string `json:"alg"`
+	//Value base64编码的签名
+	Value string `json:"value"`
+}
+
+// Sign 使用私钥签名DSL，返回带有signature字段的DSL，已有的签名会被替换
+func Sign(dsl []byte, keyId string, privateKey ed25519.PrivateKey) ([]byte, error) {
+	def, err := decode(dsl)
+	if err != nil {
+		return nil, err
+	}
+	delete(def, Field)
+	content, err := json.Marshal(def)
+	if err != nil {
+		return nil, err
+	}
+	def[Field] = Signature{
+		KeyId: keyId,
+		Alg:   Algorithm,
+		Value: base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, content)),
+	}
+	return json.MarshalIndent(def, "", "  ")
+}
+
+// Verify 使用信任的公钥校验DSL签名，trustedKeys key为密钥ID
+func Verify(dsl []byte, trustedKeys map[string]ed25519.PublicKey) error {
+	def, err := decode(dsl)
+	if err != nil {
+		return err
+	}
+	raw, ok := def[Field]
+	if !ok {
+		return ErrUnsigned
+	}
+	delete(def, Field)
+	var signature Signature
+	if v, err := json.Marshal(raw); err != nil || json.Unmarshal(v, &signature) != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+	if !strings.EqualFold(signature.Alg, Algorithm) {
+		return fmt.Errorf("%w: unsupported algorithm %s", ErrInvalidSignature, signature.Alg)
+	}
+	publicKey, ok := trustedKeys[signature.KeyId]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUntrustedKey, signature.KeyId)
+	}
+	value, err := base64.StdEncoding.DecodeString(signature.Value)
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+	content, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, content, value) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ParsePublicKey 解析base64编码的公钥
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	v, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(v) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key size: %d", len(v))
+	}
+	return ed25519.PublicKey(v), nil
+}
+
+// ParsePrivateKey 解析base64编码的私钥，支持64字节私钥或者32字节种子
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	v, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	switch len(v) {
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(v), nil
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(v), nil
+	default:
+		return nil, fmt.Errorf("invalid ed25519 private key size: %d", len(v))
+	}
+}
+
+// decode 解析DSL，数字保留原始文本，避免重新编码后签名内容变化
+func decode(dsl []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(dsl))
+	decoder.UseNumber()
+	var def map[string]interface{}
+	if err := decoder.Decode(&def); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/2018yuli/rulego/test/assert"
+	"strings"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	trusted := map[string]ed25519.PublicKey{"pub01": publicKey}
+	dsl := []byte(`{"ruleChain":{"id":"chain01","name":"测试"},"metadata":{"firstNodeIndex":0,"nodes":[{"id":"s1","type":"jsFilter","configuration":{"jsScript":"return msg.temperature > 1.50;"}}]}}`)
+
+	assert.Equal(t, ErrUnsigned, Verify(dsl, trusted))
+
+	signed, err := Sign(dsl, "pub01", privateKey)
+	assert.Nil(t, err)
+	assert.Nil(t, Verify(signed, trusted))
+
+	//重新签名替换原签名
+	signed, err = Sign(signed, "pub01", privateKey)
+	assert.Nil(t, err)
+	assert.Nil(t, Verify(signed, trusted))
+
+	//篡改内容
+	tampered := []byte(strings.Replace(string(signed), "1.50", "100", 1))
+	assert.True(t, errors.Is(Verify(tampered, trusted), ErrInvalidSignature))
+
+	//不信任的密钥
+	otherPublicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	assert.True(t, errors.Is(Verify(signed, map[string]ed25519.PublicKey{"pub02": publicKey}), ErrUntrustedKey))
+	assert.True(t, errors.Is(Verify(signed, map[string]ed25519.PublicKey{"pub01": otherPublicKey}), ErrInvalidSignature))
+}
+
+func TestParseKey(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	v, err := ParsePublicKey(base64.StdEncoding.EncodeToString(publicKey))
+	assert.Nil(t, err)
+	assert.Equal(t, publicKey, v)
+	p, err := ParsePrivateKey(base64.StdEncoding.EncodeToString(privateKey))
+	assert.Nil(t, err)
+	assert.Equal(t, privateKey, p)
+	p, err = ParsePrivateKey(base64.StdEncoding.EncodeToString(privateKey.Seed()))
+	assert.Nil(t, err)
+	assert.Equal(t, privateKey, p)
+	_, err = ParsePublicKey("aa")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// File 文件状态存储，所有状态以json格式保存在一个文件
+// 每次修改都重写整个文件，适合状态数量不多的场景
+type File struct {
+	path string
+	mem  *Memory
+}
+
+// NewFile 创建文件状态存储，如果文件存在则加载已有状态
+func NewFile(path string) (*File, error) {
+	s := &File{path: path, mem: NewMemory()}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &s.mem.values); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *File) Get(key string) (string, bool, error) {
+	return s.mem.Get(key)
+}
+
+func (s *File) Set(key string, value string) error {
+	s.mem.lock.Lock()
+	defer s.mem.lock.Unlock()
+	s.mem.values[key] = value
+	return s.save()
+}
+
+func (s *File) Delete(key string) error {
+	s.mem.lock.Lock()
+	defer s.mem.lock.Unlock()
+	if _, ok := s.mem.values[key]; !ok {
+		return nil
+	}
+	delete(s.mem.values, key)
+	return s.save()
+}
+
+// save 先写临时文件再重命名，防止写入过程中断导致文件损坏，调用方需要持有锁
+func (s *File) save() error {
+	content, err := json.Marshal(s.mem.values)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package redis Redis状态存储
+package redis
+
+import (
+	"github.com/go-redis/redis"
+)
+
+// Store Redis状态存储，多个规则引擎实例可以共享状态
+type Store struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewStore 使用Redis客户端创建状态存储，prefix 为key前缀，用于区分不同应用
+func NewStore(client *redis.Client, prefix string) *Store {
+	return &Store{client: client, prefix: prefix}
+}
+
+// New 连接Redis并创建状态存储
+// server Redis地址，例如：127.0.0.1:6379
+func New(server, password string, db int, prefix string) (*Store, error) {
+	client := redis.NewClient(&redis.Options{Addr: server, Password: password, DB: db})
+	if err := client.Ping().Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return NewStore(client, prefix), nil
+}
+
+func (s *Store) Get(key string) (string, bool, error) {
+	v, err := s.client.Get(s.prefix + key).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return v, true, nil
+}
+
+func (s *Store) Set(key string, value string) error {
+	return s.client.Set(s.prefix+key, value, 0).Err()
+}
+
+func (s *Store) Delete(key string) error {
+	return s.client.Del(s.prefix + key).Err()
+}
+
+// Close 关闭Redis客户端
+func (s *Store) Close() error {
+	return s.client.Close()
+}
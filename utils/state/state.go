@@ -0,0 +1,72 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package state 节点状态存储
+// 有状态的节点(例如计数器、最后值)通过 RuleContext.GetState/SetState 读写状态，
+// 状态作用域为规则链+节点，由 types.Config.StateStore 持久化，默认保存在内存
+package state
+
+import (
+	"strings"
+	"sync"
+)
+
+// Store 状态存储接口
+type Store interface {
+	//Get 获取状态，如果不存在ok=false
+	Get(key string) (value string, ok bool, err error)
+	//Set 保存状态
+	Set(key string, value string) error
+	//Delete 删除状态
+	Delete(key string) error
+}
+
+// Key 生成状态存储的key，格式：chainId/nodeId/key
+func Key(chainId, nodeId, key string) string {
+	return strings.Join([]string{chainId, nodeId, key}, "/")
+}
+
+// Memory 内存状态存储，进程重启后状态丢失
+type Memory struct {
+	values map[string]string
+	lock   sync.RWMutex
+}
+
+// NewMemory 创建内存状态存储
+func NewMemory() *Memory {
+	return &Memory{values: make(map[string]string)}
+}
+
+func (s *Memory) Get(key string) (string, bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	v, ok := s.values[key]
+	return v, ok, nil
+}
+
+func (s *Memory) Set(key string, value string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.values[key] = value
+	return nil
+}
+
+func (s *Memory) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.values, key)
+	return nil
+}
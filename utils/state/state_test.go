@@ -0,0 +1,64 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"github.com/2018yuli/rulego/test/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	s := NewMemory()
+	testStore(t, s)
+}
+
+func TestFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data", "state.json")
+	s, err := NewFile(file)
+	assert.Nil(t, err)
+	testStore(t, s)
+	assert.Nil(t, s.Set(Key("chain01", "s1", "dev01"), "10"))
+
+	//重新加载
+	s, err = NewFile(file)
+	assert.Nil(t, err)
+	v, ok, err := s.Get(Key("chain01", "s1", "dev01"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "10", v)
+
+	assert.Nil(t, os.WriteFile(file, []byte("{"), 0644))
+	_, err = NewFile(file)
+	assert.NotNil(t, err)
+}
+
+func testStore(t *testing.T, s Store) {
+	_, ok, err := s.Get("a")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, s.Set("a", "1"))
+	v, ok, err := s.Get("a")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+	assert.Nil(t, s.Delete("a"))
+	assert.Nil(t, s.Delete("a"))
+	_, ok, _ = s.Get("a")
+	assert.False(t, ok)
+}
@@ -153,16 +153,34 @@ func CheckHasVar(str string) bool {
 	return strings.Contains(str, "${") && strings.Contains(str, "}")
 }
 
-// ConvertDollarPlaceholder 转postgres风格占位符
+// placeholderStyles 数据库类型对应的占位符格式化函数，n从1开始
+// 没有配置的数据库(mysql、sqlite3、clickhouse等)使用?占位符，不需要转换
+var placeholderStyles = map[string]func(n int) string{
+	"postgres": dollarPlaceholder,
+}
+
+// dollarPlaceholder $1、$2风格的占位符
+func dollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// ConvertDollarPlaceholder 把?占位符转换成数据库类型对应的占位符风格，例如postgres转换成$1、$2
 func ConvertDollarPlaceholder(sql, dbType string) string {
-	if dbType == "postgres" {
-		n := 1
-		for strings.Contains(sql, "?") {
-			sql = strings.Replace(sql, "?", fmt.Sprintf("$%d", n), 1)
+	style, ok := placeholderStyles[dbType]
+	if !ok {
+		return sql
+	}
+	var sb strings.Builder
+	n := 1
+	for _, c := range sql {
+		if c == '?' {
+			sb.WriteString(style(n))
 			n++
+		} else {
+			sb.WriteRune(c)
 		}
 	}
-	return sql
+	return sb.String()
 }
 
 // RemoveBraces A function that takes a string with ${} and returns a string without them